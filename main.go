@@ -4,7 +4,7 @@ import (
 	"flag"
 	"fmt"
 	"io/ioutil"
-	"log"
+	"math"
 	"math/rand"
 	"neobench/pkg/neobench"
 	"neobench/pkg/neobench/builtin"
@@ -19,9 +19,12 @@ import (
 	"github.com/spf13/pflag"
 )
 
+// Set at build time by the Makefile, from NEOBENCH_VERSION
+var version = "dev"
+
 var fInitMode bool
 var fLatencyMode bool
-var fScale int64
+var fScale float64
 var fClients int
 var fRate float64
 var fAddress string
@@ -29,42 +32,157 @@ var fUser string
 var fPassword string
 var fEncryptionMode string
 var fDuration time.Duration
+var fTransactions uint64
+var fRamp time.Duration
+var fBurst string
+var fThinkTime string
+var fLoadProfile string
+var fPhases string
+var fRuns int
+var fAlternate string
+var fAlternateWindow time.Duration
+var fWarmup time.Duration
+var fWarmupMode string
+var fSessions string
 var fProgress time.Duration
 var fVariables map[string]string
 var fBuiltinWorkloads []string
 var fWorkloadFiles []string
 var fWorkloadScripts []string
-var fOutputFormat string
+var fOutputs []string
+var fOutputFiles []string
+var fPercentiles string
+var fTags []string
 var fPrometheusAddr string
+var fPushgateway string
+var fPushgatewayJob string
+var fInflux string
+var fInfluxToken string
+var fStatsd string
+var fStatsdPrefix string
+var fGrafanaSnapshot string
+var fHtmlReport string
+var fHgrm string
+var fTimeSeries string
+var fTimeSeriesWindow time.Duration
+var fTransactionLog string
+var fJUnit string
+var fStatementLatencies bool
+var fWorkerStats bool
+var fQuiet bool
+var fMaxAcquireP99 time.Duration
+var fAssertions []string
+var fBaseline string
+var fBaselineTolerance float64
 var fNoCheckCertificates bool
 var fDriverDebugLogging bool
 var fMaxConnLifetime time.Duration
+var fStrictParams bool
+var fAutocommit bool
+var fSweep string
+var fRateSweep string
+var fStepRate string
+var fStepClients string
+var fFindMaxRate string
+var fCollectServerMetrics bool
+var fWriteBudget string
+var fDrain time.Duration
+var fMaxErrorRate string
+var fUntilStable string
+var fStableWindow time.Duration
+var fHeadToHead bool
+var fParamsFile string
+var fDiagnoseClient bool
+var fTopology bool
+var fLogLevel string
+
+// neobench's own diagnostics; results go to the Output
+var logger *neobench.Logger
+
+// Closed once every worker of the last run is done; nil before the first run
+var lastRunDone <-chan struct{}
+
+var fParamsExhausted string
 
 func init() {
-	pflag.BoolVarP(&fInitMode, "init", "i", false, "when running built-in workloads, run their built-in dataset generator first")
-	pflag.Int64VarP(&fScale, "scale", "s", 1, "sets the `scale` variable, impact depends on workload")
+	pflag.BoolVarP(&fInitMode, "init", "i", false, "run the built-in dataset generator of built-in workloads, and the :init block of scripts that have one, first")
+	pflag.Float64VarP(&fScale, "scale", "s", 1, "sets the `scale` variable, impact depends on workload; tpcb-like and match-only accept fractions, ex: 0.1")
 	pflag.IntVarP(&fClients, "clients", "c", 1, "number of concurrent clients / sessions")
 	pflag.StringVarP(&fAddress, "address", "a", "neo4j://localhost:7687", "address to connect to")
 	pflag.StringVarP(&fUser, "user", "u", "neo4j", "username")
 	pflag.StringVarP(&fPassword, "password", "p", "neo4j", "password")
 	pflag.StringVarP(&fEncryptionMode, "encryption", "e", "auto", "whether to use encryption, `auto`, `true` or `false`")
 	pflag.DurationVarP(&fDuration, "duration", "d", 60*time.Second, "duration to run, ex: 15s, 1m, 10h")
+	pflag.Uint64VarP(&fTransactions, "transactions", "t", 0, "number of transactions each client runs, instead of running for a duration; unless -d is also set, there is no time limit")
+	pflag.StringVar(&fWriteBudget, "write-budget", "", "stop once this much has been written, in rows or bytes, ex: 1000000rows, 10GB; unless -d is also set, there is no time limit")
+	pflag.StringVar(&fUntilStable, "until-stable", "", "in throughput mode, stop the run early once the throughput has settled within this share of its mean over --stable-window, with -d as the longest it runs, ex: 2%")
+	pflag.DurationVar(&fStableWindow, "stable-window", 30*time.Second, "how long the throughput needs to have settled for with --until-stable")
+	pflag.DurationVar(&fDrain, "drain", 10*time.Second, "once the run is over, how long to wait for transactions still running to finish, which count towards the results; any still running after that are left out")
+	pflag.StringVar(&fMaxErrorRate, "max-error-rate", "", "stop the run early, keeping the results so far, once more than this share of transactions has failed, ex: 5%")
+	pflag.DurationVar(&fRamp, "ramp", 0, "treat the start of the run as ramp-up, reported separately from the steady-state results, and start clients one after the other over it rather than all at once, ex: 30s")
+	pflag.IntVar(&fRuns, "runs", 1, "run the benchmark this many times back-to-back and report the mean, min, max and standard deviation across the runs")
+	pflag.StringVar(&fAlternate, "alternate", "", "compare the workload with a second configuration, given as the options it changes, taking turns in windows of --alternate-window over -d, ex: \"-f rewritten.script\"")
+	pflag.DurationVar(&fAlternateWindow, "alternate-window", 30*time.Second, "how long each turn of --alternate runs for")
+	pflag.StringVar(&fPhases, "phases", "", "run phases one after the other, each with its own workload, clients, rate or duration as described in this file, see docs/overview.md")
+	pflag.StringVar(&fLoadProfile, "load-profile", "", "run in latency mode with the total rate changing over time as described in this file, see docs/overview.md; unless -d is also set, runs for as long as the profile")
+	pflag.StringVar(&fThinkTime, "think-time", "", "in throughput mode, pause each client for this long between transactions, fixed or drawn from a distribution, ex: 500ms, uniform:100ms-2s, exponential:1s")
+	pflag.StringVar(&fBurst, "burst", "", "alternate between running the load and idling, as <duration>-on/<duration>-off, ex: 5s-on/10s-off")
+	pflag.DurationVar(&fWarmup, "warmup", 0, "run the workload for this long before the benchmark starts, without recording results, ex: 30s")
+	pflag.StringVar(&fSessions, "sessions", "reuse", "`reuse` has each client run its transactions in one session, `per-transaction` opens a new session for each transaction, so its latency includes opening and closing it")
+	pflag.StringVar(&fWarmupMode, "warmup-mode", "generic", "`generic` warms up with different random parameters than the benchmark, `same-keys` replays the parameters the benchmark will use")
 	pflag.BoolVarP(&fLatencyMode, "latency", "l", false, "run in latency testing more rather than throughput mode")
-	pflag.Float64VarP(&fRate, "rate", "r", 1, "in latency mode (see -l) sets total transactions per second")
-	pflag.StringVarP(&fOutputFormat, "output", "o", "auto", "output format, `auto`, `interactive` or `csv`")
+	pflag.Float64VarP(&fRate, "rate", "r", defaultRate, "in latency mode (see -l) sets total transactions per second; in throughput mode, caps them")
+	pflag.StringArrayVarP(&fOutputs, "output", "o", []string{"auto"}, "output format, `auto`, `interactive`, `tui`, `csv`, `csv-append`, `json` or `markdown`, optionally followed by a file to write it to, ex: json:results.json; repeat for several outputs, at most one of them on stdout; tui shows a live dashboard, updated every second unless --progress is set; csv-append adds a row with the totals of each run to the file, ex: csv-append:runs.csv")
+	pflag.BoolVarP(&fQuiet, "quiet", "q", false, "report no progress and print only a line with the totals of each run on stdout, for shell scripts to parse; other outputs can still be written to files with -o")
+	pflag.StringVar(&fPercentiles, "percentiles", "", "latency percentiles to report, instead of the usual ones, ex: 50,90,99,99.9,99.99")
+	pflag.StringArrayVar(&fTags, "tag", []string{}, "attach key=value to the results, rows and metrics every output writes, so runs against different setups can be told apart, ex: --tag hardware=m5.xlarge; repeat for several")
+	pflag.StringArrayVar(&fOutputFiles, "output-file", []string{}, "also write the results to this file, as json, csv or markdown going by its extension, .json, .csv or .md, and as the interactive output otherwise, ex: results.json")
 
 	// Flags defining the workload to run
 	pflag.StringToStringVarP(&fVariables, "define", "D", nil, "defines variables for workload scripts and query parameters")
-	pflag.StringSliceVarP(&fBuiltinWorkloads, "builtin", "b", []string{}, "built-in workload to run 'tpcb-like' or 'ldbc-like', default is tpcb-like")
-	pflag.StringSliceVarP(&fWorkloadFiles, "file", "f", []string{}, "path to workload script file(s)")
+	pflag.StringSliceVarP(&fBuiltinWorkloads, "builtin", "b", []string{}, "built-in workload to run 'tpcb-like' or 'ldbc-like', default is tpcb-like; takes a weight or rate like -f")
+	pflag.StringSliceVarP(&fWorkloadFiles, "file", "f", []string{}, "path to workload script file(s), optionally with a weight or a rate of its own, ex: my.script@10, my.script@rate=500")
 	pflag.StringArrayVarP(&fWorkloadScripts, "script", "S", []string{}, "script(s) to run, directly specified on the command line")
+	pflag.StringVar(&fSweep, "sweep", "", "run the benchmark once for each value of a variable and compare the results, ex: batchSize=10,100,1000")
+	pflag.StringVar(&fRateSweep, "rate-sweep", "", "run in latency mode once at each of these total rates, in transactions per second, and report the latency at each, ex: 100,500,1000")
+	pflag.StringVar(&fStepRate, "step-rate", "", "run in latency mode with a total rate that goes up in steps over --duration, each reported separately, as <start>:+<increment>:<step duration>, ex: 100:+100:30s")
+	pflag.StringVar(&fFindMaxRate, "find-max-rate", "", "search for the highest total rate where a latency stays under a limit, running in latency mode at each rate tried, ex: p99<20ms")
+	pflag.StringVar(&fStepClients, "step-clients", "", "run with a number of clients that goes up in steps over --duration, each reported separately, as <start>:+<increment>:<step duration>, ex: 1:+1:30s")
+	pflag.StringVar(&fParamsFile, "params-file", "", "CSV file with a header row naming variables; each transaction gets its variables from the next row")
+	pflag.StringVar(&fParamsExhausted, "params-exhausted", "cycle", "what to do once every row in --params-file is used: `cycle` back to the start, `stop` the benchmark or pick `random` rows")
+	pflag.BoolVar(&fHeadToHead, "head-to-head", false, "compare exactly two scripts under the same load; each client alternates between them, and the report shows them side by side")
+	pflag.DurationVar(&fMaxAcquireP99, "max-acquire-p99", 0, "exit with status 3 if the p99 time transactions wait for a pooled connection is above this, ex: 5ms")
+	pflag.StringArrayVar(&fAssertions, "assert", []string{}, "exit with status 3 unless the final result meets this, one of tps, error-rate, failed, mean, max or a latency percentile like p99, compared with <, <=, > or >=, ex: --assert p99<20ms --assert tps>5000 --assert error-rate<0.1%, or for one script, ex: --assert builtin:tpcb-like:p99<20ms; failures within an error-rate or failed assertion don't fail the run")
+	pflag.StringVar(&fBaseline, "baseline", "", "compare the results to those in this file, written by an earlier run with -o json, and exit with status 3 if they regressed by more than --baseline-tolerance, ex: baseline.json")
+	pflag.Float64Var(&fBaselineTolerance, "baseline-tolerance", 10, "how much, in percent, tps in throughput mode and p50, p95 and p99 latencies in latency mode may regress compared to --baseline")
+	pflag.BoolVar(&fStrictParams, "strict-params", false, "fail if a query uses a parameter that is not defined by -D or :set, rather than sending it as null")
+	pflag.BoolVar(&fAutocommit, "autocommit", false, "run every query of every script as an auto-commit query, like :opt autocommit does for one script, rather than in transaction functions")
 
 	// Less common command line vars
 	pflag.DurationVar(&fProgress, "progress", 10*time.Second, "interval to report progress, ex: 15s, 1m, 1h")
 	pflag.BoolVar(&fNoCheckCertificates, "no-check-certificates", false, "disable TLS certificate validation, exposes your credentials to anyone on the network")
 	pflag.DurationVar(&fMaxConnLifetime, "max-conn-lifetime", 1*time.Hour, "when connections are older than this, they are ejected from the connection pool")
+	pflag.StringVar(&fLogLevel, "log-level", "info", "level of neobench's own logging to stderr, `debug`, `info`, `warn` or `error`; debug includes connection details, retries and worker lifecycle")
 	pflag.BoolVar(&fDriverDebugLogging, "driver-debug-logging", false, "enable debug-level logging for the underlying neo4j driver")
+	pflag.BoolVar(&fCollectServerMetrics, "collect-server-metrics", false, "sample heap, page cache, transaction, GC and checkpoint metrics from the server over JMX at each --progress interval")
+	pflag.BoolVar(&fTopology, "topology", false, "before the benchmark, list the cluster members, their roles and whether they're reachable; use with -d 0 to only do this")
+	pflag.BoolVar(&fStatementLatencies, "statement-latencies", false, "also record the latency of each statement in each script, and report them by script")
+	pflag.BoolVar(&fWorkerStats, "worker-stats", false, "add a table with the tps, latencies and failures of each client to the interactive output, to spot clients that lag behind the others")
+	pflag.BoolVar(&fDiagnoseClient, "diagnose-client", false, "sample GC and scheduling in neobench itself during the run, and warn if they may have inflated the latencies")
 	pflag.StringVar(&fPrometheusAddr, "prometheus", "", "enable prometheus metrics at this host:port, ex: localhost:1234, :1234")
+	pflag.StringVar(&fPushgateway, "pushgateway", "", "push metrics to this Prometheus Pushgateway at each --progress interval and when the run completes, ex: http://localhost:9091")
+	pflag.StringVar(&fPushgatewayJob, "pushgateway-job", "neobench", "job name to push metrics to --pushgateway under")
+	pflag.StringVar(&fInflux, "influx", "", "write measurements in InfluxDB line protocol at each --progress interval and when the run completes, to a write URL or a file, ex: http://localhost:8086/write?db=bench, results.lp")
+	pflag.StringVar(&fInfluxToken, "influx-token", "", "API token to authenticate to --influx with, for InfluxDB 2")
+	pflag.StringVar(&fStatsd, "statsd", "", "send counters, gauges and latency timers with DogStatsD tags over UDP to this StatsD server at each --progress interval, ex: localhost:8125")
+	pflag.StringVar(&fStatsdPrefix, "statsd-prefix", "neobench", "prefix of the metric names sent to --statsd")
+	pflag.StringVar(&fGrafanaSnapshot, "grafana-snapshot", "", "write the progress of the run to this file as a Grafana snapshot, see docs for how to publish it, ex: run.json")
+	pflag.StringVar(&fHtmlReport, "html-report", "", "write a self-contained HTML report with charts of the run to this file, ex: report.html")
+	pflag.StringVar(&fHgrm, "hgrm", "", "write latency histograms in HdrHistogram .hgrm format to <prefix>.hgrm, for all workers combined, and <prefix>-worker-<id>.hgrm, ex: results/run1")
+	pflag.StringVar(&fTimeSeries, "timeseries", "", "write tps, failures and latency percentiles by script for every --timeseries-window of the run to this CSV file, ex: timeseries.csv")
+	pflag.DurationVar(&fTimeSeriesWindow, "timeseries-window", time.Second, "length of each window in --timeseries, ex: 1s, 5s")
+	pflag.StringVar(&fTransactionLog, "transaction-log", "", "write the start, latency, script and outcome of every transaction to this CSV file, gzipped if it ends in .gz, ex: transactions.csv.gz")
+	pflag.StringVar(&fJUnit, "junit", "", "write results as JUnit XML to this file, with a test case per script and per SLA limit, like --max-acquire-p99, ex: neobench.xml")
 }
 
 func main() {
@@ -73,6 +191,7 @@ func main() {
 
 Usage:
   neobench [OPTION]... [DBNAME]
+  neobench validate [OPTION]... [SCRIPT]...
 
 Options:
 `)
@@ -84,17 +203,279 @@ Options:
 		os.Exit(1)
 	}
 
+	if fQuiet && !pflag.CommandLine.Changed("log-level") {
+		fLogLevel = "warn"
+	}
+	// Until --log-level is known, log at the default level, so a bad --log-level is reported like any other error
+	logger = neobench.NewLogger(os.Stderr, neobench.LogInfo)
+	logLevel, err := neobench.ParseLogLevel(fLogLevel)
+	if err != nil {
+		logger.Fatalf("%+v", err)
+	}
+	logger = neobench.NewLogger(os.Stderr, logLevel)
+
+	// After --, validate is the name of a database to benchmark, like any other argument there
+	if pflag.Arg(0) == "validate" && pflag.CommandLine.ArgsLenAtDash() != 0 {
+		os.Exit(validateScripts(pflag.Args()[1:]))
+	}
+
 	// If no workloads at all are specified, we run tpc-b
 	if len(fBuiltinWorkloads) == 0 && len(fWorkloadScripts) == 0 && len(fWorkloadFiles) == 0 {
 		fBuiltinWorkloads = []string{"tpcb-like"}
 	}
 
+	rateSweep, err := parseRateSweep(fRateSweep)
+	if err != nil {
+		logger.Fatalf("%+v", err)
+	}
+	if len(rateSweep) > 0 {
+		if fSweep != "" {
+			logger.Fatalf("--rate-sweep and --sweep can't be used together")
+		}
+		// Rates only mean something in latency mode; a warmup runs at the first rate
+		fLatencyMode = true
+		fRate = rateSweep[0]
+	}
+
+	var searchCondition neobench.Assertion
+	if fFindMaxRate != "" {
+		if fSweep != "" || fRateSweep != "" || fStepRate != "" || fStepClients != "" {
+			logger.Fatalf("--find-max-rate can't be used together with --sweep, --rate-sweep, --step-rate or --step-clients")
+		}
+		searchCondition, err = neobench.ParseRateSearchCondition(fFindMaxRate)
+		if err != nil {
+			logger.Fatalf("%+v", err)
+		}
+	}
+
+	var stepLoad neobench.StepLoad
+	var clientSteps []float64
+	if fStepRate != "" || fStepClients != "" {
+		if fStepRate != "" && fStepClients != "" {
+			logger.Fatalf("--step-rate and --step-clients can't be used together")
+		}
+		if fSweep != "" || fRateSweep != "" {
+			logger.Fatalf("--step-rate and --step-clients can't be used together with --sweep or --rate-sweep")
+		}
+		if fWriteBudget != "" || fTransactions > 0 {
+			logger.Fatalf("--step-rate and --step-clients run each step for a duration, so can't be used together with --write-budget or -t")
+		}
+		if fStepRate != "" {
+			stepLoad, err = neobench.ParseStepLoad("--step-rate", fStepRate, false)
+		} else {
+			stepLoad, err = neobench.ParseStepLoad("--step-clients", fStepClients, true)
+		}
+		if err != nil {
+			logger.Fatalf("%+v", err)
+		}
+		if fStepRate != "" {
+			// The steps then run as a rate sweep, just with the rates worked out from the step load
+			rateSweep = stepLoad.Values(fDuration)
+			fLatencyMode = true
+			fRate = rateSweep[0]
+		} else {
+			// A warmup runs with the clients of the first step
+			clientSteps = stepLoad.Values(fDuration)
+			fClients = int(clientSteps[0])
+		}
+	}
+
+	var phases []neobench.Phase
+	if fPhases != "" {
+		if fSweep != "" || fRateSweep != "" || fStepRate != "" || fStepClients != "" || fFindMaxRate != "" || fLoadProfile != "" {
+			logger.Fatalf("--phases can't be used together with --sweep, --rate-sweep, --step-rate, --step-clients, --find-max-rate or --load-profile")
+		}
+		phases, err = neobench.LoadPhases(fPhases)
+		if err != nil {
+			logger.Fatalf("%+v", err)
+		}
+	}
+
+	if fRuns < 1 {
+		logger.Fatalf("--runs must be at least 1, got %d", fRuns)
+	}
+	if fRuns > 1 && (fSweep != "" || fRateSweep != "" || fStepRate != "" || fStepClients != "" || fFindMaxRate != "" || fPhases != "") {
+		logger.Fatalf("--runs can't be used together with --sweep, --rate-sweep, --step-rate, --step-clients, --find-max-rate or --phases")
+	}
+
+	var alternate neobench.Phase
+	if fAlternate != "" {
+		if fSweep != "" || fRateSweep != "" || fStepRate != "" || fStepClients != "" || fFindMaxRate != "" || fPhases != "" || fRuns > 1 || fLoadProfile != "" {
+			logger.Fatalf("--alternate can't be used together with --sweep, --rate-sweep, --step-rate, --step-clients, --find-max-rate, --phases, --runs or --load-profile")
+		}
+		if fTransactions > 0 || fWriteBudget != "" || fUntilStable != "" || fBurst != "" || fRamp > 0 {
+			logger.Fatalf("--alternate splits -d into windows, so can't be used together with -t, --write-budget, --until-stable, --burst or --ramp")
+		}
+		if fAlternateWindow <= 0 {
+			logger.Fatalf("--alternate-window must be above zero, got %s", fAlternateWindow)
+		}
+		alternate, err = neobench.ParseAlternate(fAlternate)
+		if err != nil {
+			logger.Fatalf("%+v", err)
+		}
+	}
+
+	var profile *neobench.LoadProfile
+	if fLoadProfile != "" {
+		if fSweep != "" || fRateSweep != "" || fStepRate != "" || fStepClients != "" || fFindMaxRate != "" {
+			logger.Fatalf("--load-profile can't be used together with --sweep, --rate-sweep, --step-rate, --step-clients or --find-max-rate")
+		}
+		if fRamp > 0 {
+			logger.Fatalf("--load-profile and --ramp can't be used together, ramp up in the profile instead")
+		}
+		profile, err = neobench.LoadLoadProfile(fLoadProfile)
+		if err != nil {
+			logger.Fatalf("%+v", err)
+		}
+		// The profile offers the load, -r only sets the rate of a warmup
+		fLatencyMode = true
+		if !pflag.CommandLine.Changed("duration") {
+			fDuration = profile.Length()
+		}
+	}
+
+	if !fLatencyMode && !pflag.CommandLine.Changed("rate") {
+		// Throughput mode only has a rate if it's asked to be capped, otherwise it goes as fast as it can
+		fRate = 0
+	}
+
+	var burst neobench.Burst
+	if fBurst != "" {
+		burst, err = neobench.ParseBurst(fBurst)
+		if err != nil {
+			logger.Fatalf("%+v", err)
+		}
+	}
+
+	var sessionPerTransaction bool
+	switch fSessions {
+	case "reuse":
+	case "per-transaction":
+		sessionPerTransaction = true
+	default:
+		logger.Fatalf("Invalid sessions '%s', needs to be one of 'reuse' or 'per-transaction'", fSessions)
+	}
+
+	var thinkTime neobench.ThinkTime
+	if fThinkTime != "" {
+		if fLatencyMode || fFindMaxRate != "" {
+			logger.Fatalf("--think-time is for throughput mode, in latency mode -r sets when clients start their transactions")
+		}
+		thinkTime, err = neobench.ParseThinkTime(fThinkTime)
+		if err != nil {
+			logger.Fatalf("%+v", err)
+		}
+	}
+
+	var stability neobench.StabilityCheck
+	if fUntilStable != "" {
+		if fLatencyMode || fFindMaxRate != "" || fBurst != "" {
+			logger.Fatalf("--until-stable watches the throughput, so can't be used in latency mode, where -r sets it, or with --burst, which varies it")
+		}
+		stability, err = neobench.ParseStabilityCheck(fUntilStable, fStableWindow)
+		if err != nil {
+			logger.Fatalf("%+v", err)
+		}
+	}
+
+	writeBudget := neobench.WriteVolume{}
+	if fWriteBudget != "" {
+		parsed, err := neobench.ParseWriteVolume(fWriteBudget)
+		if err != nil {
+			logger.Fatalf("%+v", err)
+		}
+		writeBudget = parsed
+		if !pflag.CommandLine.Changed("duration") {
+			// Run until the budget is spent
+			fDuration = 0
+		}
+	}
+
+	maxErrorRate := 0.0
+	if fMaxErrorRate != "" {
+		parsed, err := neobench.ParseMaxErrorRate(fMaxErrorRate)
+		if err != nil {
+			logger.Fatalf("%s", err)
+		}
+		maxErrorRate = parsed
+	}
+
+	if fTransactions > 0 && !pflag.CommandLine.Changed("duration") {
+		// Run until every client has done its transactions
+		fDuration = 0
+	}
+
 	seed := time.Now().Unix()
 	scenario := describeScenario()
+	// With step loads, --duration is split between the steps, and each run is one step
+	totalDuration := fDuration
+	if stepLoad.Step > 0 {
+		fDuration = stepLoad.Step
+	}
 
-	out, err := neobench.InitOutput(fOutputFormat, fPrometheusAddr)
+	percentiles, err := neobench.ParsePercentiles(fPercentiles)
+	if err != nil {
+		logger.Fatalf("%s", err)
+	}
+	tags, err := neobench.ParseTags(fTags)
+	if err != nil {
+		logger.Fatalf("%s", err)
+	}
+	limits, err := sla()
 	if err != nil {
-		log.Fatal(err)
+		logger.Fatalf("%s", err)
+	}
+	if fQuiet && !pflag.CommandLine.Changed("output") {
+		// The summary line takes the place of -o auto on stdout
+		fOutputs = nil
+	}
+	sinks := make([]neobench.OutputSink, 0, len(fOutputs)+len(fOutputFiles))
+	for _, spec := range fOutputs {
+		sink := neobench.ParseOutputSink(spec)
+		if sink.Format == "tui" && !pflag.CommandLine.Changed("progress") {
+			// A dashboard that updates every 10 seconds isn't much of a live view
+			fProgress = time.Second
+		}
+		sinks = append(sinks, sink)
+	}
+	for _, path := range fOutputFiles {
+		sinks = append(sinks, neobench.OutputSink{Format: "auto", Path: path})
+	}
+	out, err := neobench.InitOutput(neobench.OutputConfig{
+		Sinks:               sinks,
+		Percentiles:         percentiles,
+		Tags:                tags,
+		WorkerStats:         fWorkerStats,
+		Quiet:               fQuiet,
+		PrometheusAddress:   fPrometheusAddr,
+		PushgatewayURL:      fPushgateway,
+		PushgatewayJob:      fPushgatewayJob,
+		InfluxTarget:        fInflux,
+		InfluxToken:         fInfluxToken,
+		StatsdAddress:       fStatsd,
+		StatsdPrefix:        fStatsdPrefix,
+		GrafanaSnapshotPath: fGrafanaSnapshot,
+		HtmlReportPath:      fHtmlReport,
+		HgrmPrefix:          fHgrm,
+		JUnitPath:           fJUnit,
+		SLA:                 limits,
+	})
+	if err != nil {
+		logger.Fatalf("%s", err)
+	}
+	var timeSeries *neobench.TimeSeriesWriter
+	if fTimeSeries != "" {
+		timeSeries, err = neobench.NewTimeSeriesWriter(fTimeSeries, fTimeSeriesWindow, tags)
+		if err != nil {
+			logger.Fatalf("%s", err)
+		}
+	}
+	var transactionLog *neobench.TransactionLog
+	if fTransactionLog != "" {
+		transactionLog, err = neobench.NewTransactionLog(fTransactionLog)
+		if err != nil {
+			logger.Fatalf("%s", err)
+		}
 	}
 
 	var encryptionMode neobench.EncryptionMode
@@ -106,7 +487,7 @@ Options:
 	case "false", "no", "n", "0":
 		encryptionMode = neobench.EncryptionOff
 	default:
-		log.Fatalf("Invalid encryption mode '%s', needs to be one of 'auto', 'true' or 'false'", fEncryptionMode)
+		logger.Fatalf("Invalid encryption mode '%s', needs to be one of 'auto', 'true' or 'false'", fEncryptionMode)
 	}
 
 	dbName := ""
@@ -122,71 +503,601 @@ Options:
 		}
 	})
 	if err != nil {
-		log.Fatal(err)
+		logger.Fatalf("%s", err)
 	}
+	target := driver.Target()
+	logger.Debugf("connecting to %s as %s, encryption %s, certificate checks %t, max connection lifetime %s",
+		target.String(), fUser, fEncryptionMode, !fNoCheckCertificates, fMaxConnLifetime)
 
-	variables := make(map[string]interface{})
-	variables["scale"] = fScale
-	for k, v := range fVariables {
-		intVal, err := strconv.ParseInt(v, 10, 64)
-		if err == nil {
-			variables[k] = intVal
-			continue
+	variables := commandLineVariables()
+
+	sweepVar, sweepValues, err := parseSweep(fSweep)
+	if err != nil {
+		logger.Fatalf("%+v", err)
+	}
+	if sweepVar != "" {
+		// Scripts are preflighted with the first value of the sweep
+		variables[sweepVar] = sweepValues[0]
+	}
+
+	wrk, err := createWorkload(driver, dbName, variables, seed, fBuiltinWorkloads, fWorkloadFiles, fWorkloadScripts)
+	if err != nil {
+		logger.Fatalf("%+v", err)
+	}
+	// Loaded up front, so a broken script in a later phase fails before any phase has run
+	phaseWorkloads := make([]neobench.Workload, len(phases))
+	for i, phase := range phases {
+		phaseWorkloads[i] = wrk
+		if phase.Sets("latency") && phase.LatencyMode && (!thinkTime.IsZero() || !stability.IsZero()) {
+			logger.Fatalf("phase %s runs in latency mode, which can't be used together with --think-time or --until-stable", phase.Name)
 		}
-		floatVal, err := strconv.ParseFloat(v, 64)
-		if err == nil {
-			variables[k] = floatVal
-			continue
+		if phase.SetsWorkload() {
+			phaseWorkloads[i], err = createWorkload(driver, dbName, variables, seed, phase.Builtins, phase.Files, phase.Scripts)
+			if err != nil {
+				logger.Fatalf("phase %s: %+v", phase.Name, err)
+			}
+			// Phases count on from the sequences of the ones before them, so they don't insert the same keys
+			phaseWorkloads[i].Sequences = wrk.Sequences
 		}
-		log.Fatalf("-D and --define values must be integers or floats, failing to parse '%s': %s", v, err)
 	}
 
-	wrk, err := createWorkload(driver, dbName, variables, seed)
-	if err != nil {
-		log.Fatalf("%+v", err)
+	alternateWrk, alternateClients := wrk, fClients
+	if alternate.SetsWorkload() {
+		alternateWrk, err = createWorkload(driver, dbName, variables, seed, alternate.Builtins, alternate.Files, alternate.Scripts)
+		if err != nil {
+			logger.Fatalf("--alternate: %+v", err)
+		}
+		alternateWrk.Sequences = wrk.Sequences
+	}
+	if alternate.Sets("clients") {
+		alternateClients = alternate.Clients
 	}
 
-	version, err := neo4jVersion(driver)
+	serverVersion, err := neo4jVersion(driver)
 	if err != nil {
-		log.Fatalf("%+v", err)
+		logger.Fatalf("%+v", err)
+	}
+	logger.Debugf("connected, server is Neo4j %s", serverVersion)
+	metadata := runMetadata(seed, serverVersion)
+
+	if fTopology {
+		topology, err := neobench.DiscoverTopology(driver, dbName)
+		if err != nil {
+			logger.Fatalf("%+v", err)
+		}
+		// Connect to each member directly, bypassing routing, to check we can actually reach all of them
+		topology.CheckReachability(func(address string) error {
+			memberDriver, err := neobench.NewDriver(fmt.Sprintf("bolt://%s", address), fUser, fPassword, encryptionMode, !fNoCheckCertificates)
+			if err != nil {
+				return err
+			}
+			defer memberDriver.Close()
+			return memberDriver.VerifyConnectivity()
+		})
+		fmt.Fprint(os.Stderr, topology.String())
 	}
 	if fInitMode {
-		err = initWorkload(fBuiltinWorkloads, dbName, fScale, seed, driver, out, version)
+		err = initWorkload(fBuiltinWorkloads, dbName, fScale, seed, driver, out, serverVersion)
+		if err != nil {
+			logger.Fatalf("%+v", err)
+		}
+		err = initScripts(append([]neobench.Workload{wrk, alternateWrk}, phaseWorkloads...), dbName, seed, driver, out)
 		if err != nil {
-			log.Fatalf("%+v", err)
+			logger.Fatalf("%+v", err)
 		}
 	}
 
-	if fDuration == 0 {
-		fmt.Printf("Duration (--duration) is 0, exiting without running any load\n")
+	if fDuration == 0 && writeBudget.IsZero() && fTransactions == 0 {
+		logger.Infof("Duration (--duration) is 0, exiting without running any load")
 		os.Exit(0)
 	}
 
-	if fLatencyMode {
-		result, err := runBenchmark(driver, fAddress, dbName, scenario, out, wrk, fDuration, fLatencyMode, fClients, fRate, fProgress)
+	if fDuration > 0 && fRamp >= fDuration {
+		logger.Fatalf("Ramp-up (--ramp %s) must be shorter than the run duration (--duration %s)", fRamp, fDuration)
+	}
+
+	if fWarmup > 0 {
+		warmupWrk := wrk
+		switch fWarmupMode {
+		case "generic":
+		case "same-keys":
+			// Both the warmup and the benchmark draw client seeds from a RNG seeded with the same value,
+			// so client N in the warmup generates exactly the parameters client N in the benchmark will.
+			warmupWrk.Rand = rand.New(rand.NewSource(seed))
+		default:
+			logger.Fatalf("Invalid warmup mode '%s', needs to be one of 'generic' or 'same-keys'", fWarmupMode)
+		}
+		discarded, err := runWarmup(driver, dbName, out, warmupWrk, fWarmup, fLatencyMode, fClients, fRate, thinkTime, sessionPerTransaction, seed)
 		if err != nil {
 			out.Errorf(err.Error())
 			os.Exit(1)
 		}
-		out.ReportLatency(result)
-		if result.TotalFailed() == 0 {
-			os.Exit(0)
-		} else {
+		logger.Infof("warmup ran %d transactions in %s, none of them count towards the results", discarded, fWarmup)
+	}
+
+	// What every run gets from the command line; sweeps, phases and steps change some of it for each run
+	baseRun := runConfig{
+		url:                   fAddress,
+		databaseName:          dbName,
+		scenario:              scenario,
+		out:                   out,
+		duration:              fDuration,
+		transactions:          fTransactions,
+		drain:                 fDrain,
+		ramp:                  fRamp,
+		burst:                 burst,
+		thinkTime:             thinkTime,
+		profile:               profile,
+		latencyMode:           fLatencyMode,
+		clients:               fClients,
+		rate:                  fRate,
+		progressInterval:      fProgress,
+		collectServerMetrics:  fCollectServerMetrics,
+		diagnoseClient:        fDiagnoseClient,
+		statementLatencies:    fStatementLatencies,
+		sessionPerTransaction: sessionPerTransaction,
+		writeBudget:           writeBudget,
+		maxErrorRate:          maxErrorRate,
+		stability:             stability,
+		timeSeries:            timeSeries,
+		transactionLog:        transactionLog,
+		metadata:              metadata,
+	}
+
+	if len(phases) > 0 {
+		sweep := neobench.SweepResult{
+			Scenario: scenario,
+			Variable: "phase",
+			Phases:   true,
+		}
+		for i, phase := range phases {
+			// Anything the phase doesn't set is as on the command line
+			latencyMode := fLatencyMode
+			if phase.Sets("latency") {
+				latencyMode = phase.LatencyMode
+			}
+			clients := fClients
+			if phase.Sets("clients") {
+				clients = phase.Clients
+			}
+			rate := fRate
+			if phase.Sets("rate") {
+				rate = phase.Rate
+			} else if !pflag.CommandLine.Changed("rate") {
+				// Same as on the command line, the default rate is only for latency mode
+				rate = 0
+				if latencyMode {
+					rate = defaultRate
+				}
+			}
+			// A phase that says how long to run, in time or transactions, doesn't also get the other from the command line
+			duration, transactions := fDuration, fTransactions
+			if phase.Sets("duration") || phase.Sets("transactions") {
+				duration, transactions = phase.Duration, phase.Transactions
+			}
+
+			runScenario := fmt.Sprintf("%s, phase %s: %s", scenario, phase.Name, phase.Options)
+			run := baseRun
+			run.scenario = runScenario
+			run.duration, run.transactions = duration, transactions
+			run.profile = nil
+			run.latencyMode, run.clients, run.rate = latencyMode, clients, rate
+			result, err := runBenchmark(driver, phaseWorkloads[i], run)
+			if err != nil {
+				out.Errorf(err.Error())
+				os.Exit(1)
+			}
+			if latencyMode {
+				out.ReportLatency(result)
+				sweep.LatencyMode = true
+			} else {
+				out.ReportThroughput(result)
+			}
+			sweep.Values = append(sweep.Values, phase.Name)
+			sweep.Results = append(sweep.Results, result)
+			if result.Aborted != "" {
+				// The phases after it would most likely fail the same way
+				break
+			}
+		}
+		out.ReportSweep(sweep)
+		os.Exit(exitCode(out, limits, sweep.Results...))
+	}
+
+	if fAlternate != "" {
+		configs := []struct {
+			name, scenario string
+			wrk            neobench.Workload
+			clients        int
+		}{
+			{"a", fmt.Sprintf("%s, configuration a", scenario), wrk, fClients},
+			{"b", fmt.Sprintf("%s, configuration b: %s", scenario, alternate.Options), alternateWrk, alternateClients},
+		}
+		// The windows each configuration ran in, taking turns, a first
+		windows := make([][]neobench.Result, len(configs))
+		numWindows := 2 * neobench.AlternatePairs(fDuration, fAlternateWindow)
+		for i := 0; i < numWindows; i++ {
+			config := configs[i%2]
+			// Each window drains like a run of its own, and waits for what the last one cut off, see waitForCutOff, so
+			// neither configuration's transactions run in the windows of the other
+			runScenario := fmt.Sprintf("%s, window %d of %d", config.scenario, i+1, numWindows)
+			run := baseRun
+			run.scenario = runScenario
+			run.duration, run.transactions, run.ramp = fAlternateWindow, 0, 0
+			run.profile = nil
+			run.clients = config.clients
+			result, err := runBenchmark(driver, config.wrk, run)
+			if err != nil {
+				out.Errorf(err.Error())
+				os.Exit(1)
+			}
+			windows[i%2] = append(windows[i%2], result)
+			if result.Aborted != "" {
+				// The windows after it would most likely fail the same way
+				break
+			}
+		}
+		sweep := neobench.SweepResult{
+			Scenario:    scenario,
+			Variable:    "configuration",
+			LatencyMode: fLatencyMode,
+			Alternate:   true,
+		}
+		for i, config := range configs {
+			result, err := neobench.MergeWindows(dbName, config.scenario, windows[i])
+			if err != nil {
+				out.Errorf(err.Error())
+				os.Exit(1)
+			}
+			if fLatencyMode {
+				out.ReportLatency(result)
+			} else {
+				out.ReportThroughput(result)
+			}
+			sweep.Values = append(sweep.Values, config.name)
+			sweep.Results = append(sweep.Results, result)
+		}
+		out.ReportSweep(sweep)
+		os.Exit(exitCode(out, limits, sweep.Results...))
+	}
+
+	if sweepVar != "" {
+		sweep := neobench.SweepResult{
+			Scenario:    fmt.Sprintf("%s --sweep %s", scenario, fSweep),
+			Variable:    sweepVar,
+			LatencyMode: fLatencyMode,
+		}
+		for _, value := range sweepValues {
+			wrk.Variables[sweepVar] = value
+			runScenario := fmt.Sprintf("%s -D %s=%v", scenario, sweepVar, value)
+			run := baseRun
+			run.scenario = runScenario
+			result, err := runBenchmark(driver, wrk, run)
+			if err != nil {
+				out.Errorf(err.Error())
+				os.Exit(1)
+			}
+			if fLatencyMode {
+				out.ReportLatency(result)
+			} else {
+				out.ReportThroughput(result)
+			}
+			sweep.Values = append(sweep.Values, value)
+			sweep.Results = append(sweep.Results, result)
+			if result.Aborted != "" {
+				// The rest would most likely fail the same way
+				break
+			}
+		}
+		out.ReportSweep(sweep)
+		os.Exit(exitCode(out, limits, sweep.Results...))
+	}
+
+	if len(rateSweep) > 0 {
+		sweep := neobench.SweepResult{
+			Scenario:    scenario,
+			Variable:    "rate",
+			LatencyMode: true,
+			RateSweep:   true,
+		}
+		for _, rate := range rateSweep {
+			runScenario := strings.Replace(scenario, fmt.Sprintf(" --rate-sweep %s", fRateSweep), fmt.Sprintf(" -r %.3f", rate), 1)
+			if fStepRate != "" {
+				runScenario = stepScenario(scenario, totalDuration, fDuration, fmt.Sprintf(" --step-rate %s", fStepRate), fmt.Sprintf(" -r %.3f", rate))
+			}
+			run := baseRun
+			run.scenario = runScenario
+			run.latencyMode, run.rate = true, rate
+			result, err := runBenchmark(driver, wrk, run)
+			if err != nil {
+				out.Errorf(err.Error())
+				os.Exit(1)
+			}
+			out.ReportLatency(result)
+			sweep.Values = append(sweep.Values, rate)
+			sweep.Results = append(sweep.Results, result)
+			if result.Aborted != "" {
+				// The rest would most likely fail the same way
+				break
+			}
+		}
+		out.ReportSweep(sweep)
+		os.Exit(exitCode(out, limits, sweep.Results...))
+	}
+
+	if fFindMaxRate != "" {
+		searchFlag := fmt.Sprintf(" --find-max-rate \"%s\"", fFindMaxRate)
+		// The unthrottled run gives the most the server can do, which the search bisects down from
+		unthrottled := baseRun
+		unthrottled.scenario = strings.Replace(scenario, searchFlag, "", 1)
+		unthrottled.latencyMode, unthrottled.rate = false, 0
+		ceiling, err := runBenchmark(driver, wrk, unthrottled)
+		if err != nil {
+			out.Errorf(err.Error())
+			os.Exit(1)
+		}
+		out.ReportThroughput(ceiling)
+		if ceiling.Aborted != "" {
+			// Nothing to search below
+			os.Exit(exitCode(out, limits, ceiling))
+		}
+		search, err := neobench.NewRateSearch(searchCondition, ceiling.TotalRate())
+		if err != nil {
+			out.Errorf(err.Error())
 			os.Exit(1)
 		}
+		sweep := neobench.SweepResult{
+			Scenario:         scenario,
+			Variable:         "rate",
+			LatencyMode:      true,
+			RateSweep:        true,
+			MaxRateCondition: searchCondition.Raw,
+		}
+		for rate, ok := search.Next(); ok; rate, ok = search.Next() {
+			runScenario := strings.Replace(scenario, searchFlag, fmt.Sprintf(" -l -r %.3f", rate), 1)
+			run := baseRun
+			run.scenario = runScenario
+			run.latencyMode, run.rate = true, rate
+			result, err := runBenchmark(driver, wrk, run)
+			if err != nil {
+				out.Errorf(err.Error())
+				os.Exit(1)
+			}
+			out.ReportLatency(result)
+			search.Record(rate, result)
+			sweep.Values = append(sweep.Values, rate)
+			sweep.Results = append(sweep.Results, result)
+		}
+		sweep.MaxRate = search.MaxRate()
+		out.ReportSweep(sweep)
+		// Rates above the one found are expected to break the condition, only the one found is checked
+		if search.Best() == nil {
+			out.Errorf("none of the rates tried met --find-max-rate %s", searchCondition.Raw)
+			os.Exit(exitSLABreached)
+		}
+		os.Exit(exitCode(out, limits, *search.Best()))
+	}
+
+	if len(clientSteps) > 0 {
+		sweep := neobench.SweepResult{
+			Scenario:    scenario,
+			Variable:    "clients",
+			LatencyMode: fLatencyMode,
+			ClientSteps: true,
+		}
+		for _, value := range clientSteps {
+			clients := int(value)
+			runScenario := strings.Replace(stepScenario(scenario, totalDuration, fDuration, fmt.Sprintf(" --step-clients %s", fStepClients), ""),
+				fmt.Sprintf(" -c %d", fClients), fmt.Sprintf(" -c %d", clients), 1)
+			run := baseRun
+			run.scenario = runScenario
+			run.clients = clients
+			result, err := runBenchmark(driver, wrk, run)
+			if err != nil {
+				out.Errorf(err.Error())
+				os.Exit(1)
+			}
+			if fLatencyMode {
+				out.ReportLatency(result)
+			} else {
+				out.ReportThroughput(result)
+			}
+			sweep.Values = append(sweep.Values, int64(clients))
+			sweep.Results = append(sweep.Results, result)
+			if result.Aborted != "" {
+				// The rest would most likely fail the same way
+				break
+			}
+		}
+		out.ReportSweep(sweep)
+		os.Exit(exitCode(out, limits, sweep.Results...))
+	}
+
+	if fRuns > 1 {
+		sweep := neobench.SweepResult{
+			Scenario:    scenario,
+			Variable:    "run",
+			LatencyMode: fLatencyMode,
+			Runs:        true,
+		}
+		for run := 1; run <= fRuns; run++ {
+			repeat := baseRun
+			repeat.scenario = fmt.Sprintf("%s, run %d of %d", scenario, run, fRuns)
+			result, err := runBenchmark(driver, wrk, repeat)
+			if err != nil {
+				out.Errorf(err.Error())
+				os.Exit(1)
+			}
+			if fLatencyMode {
+				out.ReportLatency(result)
+			} else {
+				out.ReportThroughput(result)
+			}
+			sweep.Values = append(sweep.Values, int64(run))
+			sweep.Results = append(sweep.Results, result)
+			if result.Aborted != "" {
+				// The rest would most likely fail the same way
+				break
+			}
+		}
+		out.ReportSweep(sweep)
+		os.Exit(exitCode(out, limits, sweep.Results...))
+	}
+
+	if fLatencyMode {
+		result, err := runBenchmark(driver, wrk, baseRun)
+		if err != nil {
+			out.Errorf(err.Error())
+			os.Exit(1)
+		}
+		out.ReportLatency(result)
+		os.Exit(exitCode(out, limits, result))
 	} else {
-		result, err := runBenchmark(driver, fAddress, dbName, scenario, out, wrk, fDuration, fLatencyMode, fClients, fRate, fProgress)
+		result, err := runBenchmark(driver, wrk, baseRun)
 		if err != nil {
 			out.Errorf(err.Error())
 			os.Exit(1)
 		}
 		out.ReportThroughput(result)
-		if result.TotalFailed() == 0 {
-			os.Exit(0)
-		} else {
-			os.Exit(1)
+		os.Exit(exitCode(out, limits, result))
+	}
+}
+
+// Everything about the run but its start and end, which runBenchmark fills in
+func runMetadata(seed int64, serverVersion string) neobench.RunMetadata {
+	flags := make(map[string]string)
+	pflag.CommandLine.VisitAll(func(f *pflag.Flag) {
+		flags[f.Name] = f.Value.String()
+	})
+	return neobench.RunMetadata{
+		NeobenchVersion: version,
+		DriverVersion:   neobench.DriverVersion(),
+		ServerVersion:   serverVersion,
+		Seed:            seed,
+		Flags:           neobench.RedactFlags(flags, "password", "influx-token"),
+	}
+}
+
+// The --rate of latency mode when none is given, in transactions per second
+const defaultRate = 1
+
+// How long a run waits for transactions the run before it cut off to finish, see waitForCutOff
+const cutOffGrace = time.Minute
+
+// Exit status when every transaction succeeded, but the results break a limit set on the command line, see neobench.SLA
+const exitSLABreached = 3
+
+// The limits set on the command line
+func sla() (neobench.SLA, error) {
+	limits := neobench.SLA{MaxAcquireP99: fMaxAcquireP99}
+	for _, raw := range fAssertions {
+		assertion, err := neobench.ParseAssertion(raw)
+		if err != nil {
+			return limits, err
+		}
+		limits.Assertions = append(limits.Assertions, assertion)
+	}
+	if fBaseline != "" {
+		if fBaselineTolerance < 0 {
+			return limits, fmt.Errorf("--baseline-tolerance can't be negative, got %g", fBaselineTolerance)
+		}
+		baseline, err := neobench.LoadBaseline(fBaseline)
+		if err != nil {
+			return limits, err
+		}
+		limits.Baseline = baseline
+		limits.BaselineTolerance = fBaselineTolerance / 100
+	}
+	return limits, nil
+}
+
+// 1 if any transaction failed, unless the SLA says how many may, exitSLABreached if the results break an SLA,
+// and 0 otherwise; reports SLA breaches
+func exitCode(out neobench.Output, limits neobench.SLA, results ...neobench.Result) int {
+	code := 0
+	for _, result := range results {
+		for _, breach := range limits.Breaches(result) {
+			out.Errorf("SLA breached: %s", breach)
+			if code == 0 {
+				code = exitSLABreached
+			}
+		}
+		if result.TotalFailed() > 0 && !limits.AllowsFailures(result) {
+			code = 1
+		}
+		if result.Aborted != "" {
+			code = 1
+		}
+	}
+	return code
+}
+
+// Values given on the command line are parsed as integers if possible, otherwise as floats
+func parseVariableValue(raw string) (interface{}, error) {
+	intVal, err := strconv.ParseInt(raw, 10, 64)
+	if err == nil {
+		return intVal, nil
+	}
+	return strconv.ParseFloat(raw, 64)
+}
+
+// Parses the value of a -D variable: a number, or anything else as a string, like -D region=eu-west. Values that
+// start like a number but aren't one, like 1O or 1e, are taken as typos; a double quoted value is always a string.
+func parseDefineValue(name, raw string) (interface{}, error) {
+	if len(raw) >= 2 && strings.HasPrefix(raw, `"`) && strings.HasSuffix(raw, `"`) {
+		return raw[1 : len(raw)-1], nil
+	}
+	value, err := parseVariableValue(raw)
+	if err == nil {
+		return value, nil
+	}
+	if numberLike := strings.TrimLeft(raw, "+-."); numberLike != "" && numberLike[0] >= '0' && numberLike[0] <= '9' {
+		return nil, fmt.Errorf("looks like a number, but isn't one; to pass it as a string, quote it, like -D '%s=\"%s\"'", name, raw)
+	}
+	return raw, nil
+}
+
+// Parses --sweep, eg. "batchSize=10,100,1000" becomes "batchSize", [10, 100, 1000]
+func parseSweep(raw string) (string, []interface{}, error) {
+	if raw == "" {
+		return "", nil, nil
+	}
+	parts := strings.SplitN(raw, "=", 2)
+	if len(parts) != 2 || parts[0] == "" || parts[1] == "" {
+		return "", nil, fmt.Errorf("--sweep must be on the form <variable>=<value>,<value>,.., got '%s'", raw)
+	}
+	values := make([]interface{}, 0)
+	for _, rawValue := range strings.Split(parts[1], ",") {
+		value, err := parseVariableValue(strings.TrimSpace(rawValue))
+		if err != nil {
+			return "", nil, fmt.Errorf("--sweep values must be integers or floats, failing to parse '%s': %s", rawValue, err)
+		}
+		values = append(values, value)
+	}
+	return parts[0], values, nil
+}
+
+// The scenario of one step of a --step-rate or --step-clients run: the step flag is swapped for what it
+// amounts to in that step, and the total duration for the duration of a step
+func stepScenario(scenario string, totalDuration, stepDuration time.Duration, stepFlag, replacement string) string {
+	runScenario := strings.Replace(scenario, stepFlag, replacement, 1)
+	return strings.Replace(runScenario, fmt.Sprintf(" -d %s", totalDuration), fmt.Sprintf(" -d %s", stepDuration), 1)
+}
+
+// Parses --rate-sweep, eg. "100,500,1000" becomes [100, 500, 1000]
+func parseRateSweep(raw string) ([]float64, error) {
+	if raw == "" {
+		return nil, nil
+	}
+	rates := make([]float64, 0)
+	for _, rawRate := range strings.Split(raw, ",") {
+		rate, err := strconv.ParseFloat(strings.TrimSpace(rawRate), 64)
+		if err != nil || rate <= 0 {
+			return nil, fmt.Errorf("--rate-sweep must be a list of rates above zero, like 100,500,1000, failing to parse '%s'", rawRate)
 		}
+		rates = append(rates, rate)
 	}
+	return rates, nil
 }
 
 func neo4jVersion(driver neo4j.Driver) (string, error) {
@@ -204,58 +1115,137 @@ func neo4jVersion(driver neo4j.Driver) (string, error) {
 	return rawVersion.(string), nil
 }
 
-func createWorkload(driver neo4j.Driver, dbName string, variables map[string]interface{}, seed int64) (neobench.Workload, error) {
+// Loads the workload of the built-ins, script files and scripts given, as with -b, -f and -S
+func createWorkload(driver neo4j.Driver, dbName string, variables map[string]interface{}, seed int64, builtins, files, inlineScripts []string) (neobench.Workload, error) {
 	var err error
 	scripts := make([]neobench.Script, 0)
 	csvLoader := neobench.NewCsvLoader()
-	for _, rawPath := range fBuiltinWorkloads {
-		path, weight := splitScriptAndWeight(rawPath)
+
+	var params *neobench.ParamsFile
+	// Scripts are preflighted with the first row of the params file standing in for the rows each transaction gets
+	preflightVars := variables
+	if fParamsFile != "" {
+		var mode neobench.ParamsExhausted
+		switch fParamsExhausted {
+		case "cycle":
+			mode = neobench.ParamsCycle
+		case "stop":
+			mode = neobench.ParamsStop
+		case "random":
+			mode = neobench.ParamsRandom
+		default:
+			return neobench.Workload{}, fmt.Errorf("invalid --params-exhausted '%s', needs to be one of 'cycle', 'stop' or 'random'", fParamsExhausted)
+		}
+		params, err = neobench.LoadParamsFile(csvLoader, fParamsFile, mode)
+		if err != nil {
+			return neobench.Workload{}, err
+		}
+		preflightVars = make(map[string]interface{})
+		for k, v := range variables {
+			preflightVars[k] = v
+		}
+		for k, v := range params.FirstRow() {
+			preflightVars[k] = v
+		}
+	}
+	for _, rawPath := range builtins {
+		path, weight, rate := splitScriptAndWeight(rawPath)
 		builtinScripts, err := loadBuiltinWorkload(path, weight)
 		if err != nil {
 			return neobench.Workload{}, errors.Wrapf(err, "failed to load script '%s'", path)
 		}
+		// Built-ins with several scripts split the rate the way they split the weight
+		for i := range builtinScripts {
+			builtinScripts[i].Rate = rate * builtinScripts[i].Weight / weight
+		}
 		scripts = append(scripts, builtinScripts...)
 	}
 
-	for _, rawPath := range fWorkloadFiles {
-		path, weight := splitScriptAndWeight(rawPath)
-		script, err := loadScriptFile(driver, dbName, variables, path, weight, csvLoader)
+	for _, rawPath := range files {
+		path, weight, rate := splitScriptAndWeight(rawPath)
+		script, err := loadScriptFile(driver, dbName, preflightVars, path, weight, csvLoader)
 		if err != nil {
 			return neobench.Workload{}, errors.Wrapf(err, "failed to load script '%s'", path)
 		}
+		script.Rate = rate
 		scripts = append(scripts, script)
 	}
 
-	for i, scriptContent := range fWorkloadScripts {
-		script, err := loadScript(driver, dbName, variables, fmt.Sprintf("-S #%d", i), scriptContent, 1.0, csvLoader)
+	for i, scriptContent := range inlineScripts {
+		script, err := loadScript(driver, dbName, preflightVars, fmt.Sprintf("-S #%d", i), scriptContent, 1.0, csvLoader)
 		if err != nil {
 			return neobench.Workload{}, errors.Wrapf(err, "failed to parse script '%s'", scriptContent)
 		}
 		scripts = append(scripts, script)
 	}
 
+	// Results are kept by script name, so scripts that give themselves the same :name would mix theirs up; the
+	// same script given twice is fine, its results are the same script's either way
+	sources := make(map[string]string)
+	for _, script := range scripts {
+		if source, taken := sources[script.Name]; taken && source != script.Source {
+			return neobench.Workload{}, fmt.Errorf("%s and %s are both named %s; give them names of their own with :name, so their results are kept apart",
+				source, script.Source, script.Name)
+		}
+		sources[script.Name] = script.Source
+	}
+
+	if fAutocommit {
+		for i := range scripts {
+			if scripts[i].ExplicitTransactions {
+				return neobench.Workload{}, fmt.Errorf("--autocommit runs each query on its own, but %s uses :begin to run queries in transactions", scripts[i].Name)
+			}
+			scripts[i].Autocommit = true
+		}
+	}
+
+	if fHeadToHead {
+		if len(scripts) != 2 {
+			return neobench.Workload{}, fmt.Errorf("--head-to-head compares exactly two scripts, but the workload has %d", len(scripts))
+		}
+		if scripts[0].Name == scripts[1].Name {
+			return neobench.Workload{}, fmt.Errorf("--head-to-head needs two different scripts, but both are %s", scripts[0].Name)
+		}
+		if scripts[0].Rate > 0 || scripts[1].Rate > 0 {
+			return neobench.Workload{}, fmt.Errorf("--head-to-head has clients take turns running both scripts, so neither can have a rate of its own")
+		}
+	}
+
 	return neobench.Workload{
-		Variables: variables,
-		Scripts:   neobench.NewScripts(scripts...),
-		Rand:      rand.New(rand.NewSource(seed)),
-		CsvLoader: csvLoader,
+		Variables:    variables,
+		Scripts:      neobench.NewScripts(scripts...),
+		Rand:         rand.New(rand.NewSource(seed)),
+		CsvLoader:    csvLoader,
+		StrictParams: fStrictParams,
+		HeadToHead:   fHeadToHead,
+		Params:       params,
+		Sequences:    neobench.NewSequences(),
 	}, err
 }
 
-// Splits command-line specified scripts-with-weight into script and weight
+// Splits command-line specified scripts-with-weight into script, weight and rate; a script with a rate of its
+// own runs at that rate on clients of its own, so its weight doesn't matter
 //
-//	-f my.script@100 becomes "myscript", 100.0
-//	-b tpcb-like@10 becomes "tpcb-like", 10.0
-func splitScriptAndWeight(raw string) (string, float64) {
+//	-f my.script@100 becomes "myscript", 100.0, 0
+//	-b tpcb-like@10 becomes "tpcb-like", 10.0, 0
+//	-f reads.script@rate=500 becomes "reads.script", 1.0, 500.0
+func splitScriptAndWeight(raw string) (string, float64, float64) {
 	parts := strings.Split(raw, "@")
 	if len(parts) < 2 {
-		return raw, 1.0
+		return raw, 1.0, 0
+	}
+	if strings.HasPrefix(parts[1], "rate=") {
+		rate, err := strconv.ParseFloat(strings.TrimPrefix(parts[1], "rate="), 64)
+		if err != nil || rate <= 0 {
+			logger.Fatalf("Failed to parse rate; value after @rate= for workload rate must be a number above zero: %s", raw)
+		}
+		return parts[0], 1.0, rate
 	}
 	weight, err := strconv.ParseFloat(parts[1], 64)
 	if err != nil {
-		log.Fatalf("Failed to parse weight; value after @ symbol for workload weight must be a number: %s", raw)
+		logger.Fatalf("Failed to parse weight; value after @ symbol for workload weight must be a number: %s", raw)
 	}
-	return parts[0], weight
+	return parts[0], weight, 0
 }
 
 func loadScriptFile(driver neo4j.Driver, dbName string, vars map[string]interface{}, path string, weight float64,
@@ -275,8 +1265,18 @@ func loadScript(driver neo4j.Driver, dbName string, vars map[string]interface{},
 		return neobench.Script{}, err
 	}
 
+	if fStrictParams {
+		if undefined := script.UndefinedParams(vars); len(undefined) > 0 {
+			return neobench.Script{}, fmt.Errorf("script uses parameters that are never defined: $%s; "+
+				"define them with -D or :set, or drop --strict-params to send them as null", strings.Join(undefined, ", $"))
+		}
+	}
+
 	readonly, err := neobench.WorkloadPreflight(driver, dbName, script, vars, csvLoader)
 	script.Readonly = readonly
+	if err == nil && script.Mode == "read" && !readonly {
+		logger.Warnf("%s has :mode read, but EXPLAIN says some of its queries write, which fails in read transactions", path)
+	}
 	return script, err
 }
 
@@ -353,64 +1353,425 @@ func describeScenario() string {
 		out.WriteString(fmt.Sprintf(" -S \"%s\"", script))
 	}
 	out.WriteString(fmt.Sprintf(" -c %d", fClients))
-	out.WriteString(fmt.Sprintf(" -s %d", fScale))
+	out.WriteString(fmt.Sprintf(" -s %s", strconv.FormatFloat(fScale, 'f', -1, 64)))
 	out.WriteString(fmt.Sprintf(" -d %s", fDuration))
+	if fTransactions > 0 {
+		out.WriteString(fmt.Sprintf(" -t %d", fTransactions))
+	}
+	if fWriteBudget != "" {
+		out.WriteString(fmt.Sprintf(" --write-budget %s", fWriteBudget))
+	}
+	if fPhases != "" {
+		out.WriteString(fmt.Sprintf(" --phases %s", fPhases))
+	}
+	if fRuns > 1 {
+		out.WriteString(fmt.Sprintf(" --runs %d", fRuns))
+	}
+	if fAlternate != "" {
+		out.WriteString(fmt.Sprintf(" --alternate \"%s\" --alternate-window %s", fAlternate, fAlternateWindow))
+	}
+	if fMaxErrorRate != "" {
+		out.WriteString(fmt.Sprintf(" --max-error-rate %s", fMaxErrorRate))
+	}
+	if fUntilStable != "" {
+		out.WriteString(fmt.Sprintf(" --until-stable %s --stable-window %s", fUntilStable, fStableWindow))
+	}
+	if fRamp > 0 {
+		out.WriteString(fmt.Sprintf(" --ramp %s", fRamp))
+	}
+	if fBurst != "" {
+		out.WriteString(fmt.Sprintf(" --burst %s", fBurst))
+	}
+	if fThinkTime != "" {
+		out.WriteString(fmt.Sprintf(" --think-time %s", fThinkTime))
+	}
+	if fSessions != "reuse" {
+		out.WriteString(fmt.Sprintf(" --sessions %s", fSessions))
+	}
 	out.WriteString(fmt.Sprintf(" -e %s", fEncryptionMode))
-	if fLatencyMode {
+	if fRateSweep != "" {
+		out.WriteString(fmt.Sprintf(" -l --rate-sweep %s", fRateSweep))
+	} else if fStepRate != "" {
+		out.WriteString(fmt.Sprintf(" -l --step-rate %s", fStepRate))
+	} else if fFindMaxRate != "" {
+		out.WriteString(fmt.Sprintf(" --find-max-rate \"%s\"", fFindMaxRate))
+	} else if fLoadProfile != "" {
+		out.WriteString(fmt.Sprintf(" -l --load-profile %s", fLoadProfile))
+	} else if fLatencyMode {
 		out.WriteString(fmt.Sprintf(" -l -r %.3f", fRate))
+	} else if fRate > 0 {
+		out.WriteString(fmt.Sprintf(" -r %.3f", fRate))
+	}
+	if fStepClients != "" {
+		out.WriteString(fmt.Sprintf(" --step-clients %s", fStepClients))
 	}
 	if fInitMode {
 		out.WriteString(" -i")
 	}
+	if fParamsFile != "" {
+		out.WriteString(fmt.Sprintf(" --params-file %s --params-exhausted %s", fParamsFile, fParamsExhausted))
+	}
+	if fHeadToHead {
+		out.WriteString(" --head-to-head")
+	}
+	if fAutocommit {
+		out.WriteString(" --autocommit")
+	}
+	if fWarmup > 0 {
+		out.WriteString(fmt.Sprintf(" --warmup %s --warmup-mode %s", fWarmup, fWarmupMode))
+	}
 	return out.String()
 }
 
-func runBenchmark(driver neo4j.Driver, url, databaseName, scenario string, out neobench.Output, wrk neobench.Workload,
-	runtime time.Duration, latencyMode bool, numClients int, rate float64, progressInterval time.Duration) (neobench.Result, error) {
+// How runBenchmark runs the workload, mostly as given on the command line
+type runConfig struct {
+	url, databaseName string
+	// Describes the run in its results, see describeScenario
+	scenario string
+	out      neobench.Output
+	// How long to run for, zero for no time limit, and how many transactions each client runs, zero for no limit
+	duration     time.Duration
+	transactions uint64
+	// See --drain and --ramp
+	drain, ramp time.Duration
+	burst       neobench.Burst
+	thinkTime   neobench.ThinkTime
+	// See --load-profile; nil to run at the rate
+	profile     *neobench.LoadProfile
+	latencyMode bool
+	clients     int
+	rate        float64
+	// See --progress
+	progressInterval time.Duration
+	// See --collect-server-metrics, --diagnose-client, --statement-latencies and --sessions
+	collectServerMetrics, diagnoseClient, statementLatencies, sessionPerTransaction bool
+	// See --write-budget, --max-error-rate and --until-stable
+	writeBudget  neobench.WriteVolume
+	maxErrorRate float64
+	stability    neobench.StabilityCheck
+	// Nil unless --timeseries and --transaction-log are given
+	timeSeries     *neobench.TimeSeriesWriter
+	transactionLog *neobench.TransactionLog
+	// Copied into the result, with the start and end of the run
+	metadata neobench.RunMetadata
+}
+
+// Why awaitCompletion stopped the run before its time, transactions or write budget were up; both are empty if it
+// didn't
+type earlyStop struct {
+	// Set if --max-error-rate stopped the run, see Result.Aborted
+	aborted string
+	// Set if --until-stable stopped it, see Result.Settled
+	settled string
+}
+
+func runBenchmark(driver neo4j.Driver, wrk neobench.Workload, config runConfig) (neobench.Result, error) {
+	waitForCutOff(config.out)
 	stopCh, stop := neobench.SetupSignalHandler()
 	defer stop()
 
-	ratePerWorkerDuration := time.Duration(0)
-	if latencyMode {
-		ratePerWorkerDuration = neobench.TotalRatePerSecondToDurationPerClient(numClients, rate)
+	config.out.BenchmarkStart(config.databaseName, config.url, config.scenario)
+
+	// Transactions that start before this point in time are considered part of ramp-up and reported separately
+	rampEnd := time.Time{}
+	if config.ramp > 0 {
+		rampEnd = time.Now().Add(config.ramp)
 	}
 
-	out.BenchmarkStart(databaseName, url, scenario)
+	// Each run replays the params files from the start
+	wrk = wrk.RestartParams()
 
-	resultChan := make(chan neobench.WorkerResult, numClients)
+	// Shared by all workers, so they stop together once the total written reaches the budget
+	var budget *neobench.WriteBudget
+	if !config.writeBudget.IsZero() {
+		budget = neobench.NewWriteBudget(config.writeBudget)
+	}
+
+	config.metadata.Start = time.Now()
+	if config.timeSeries != nil {
+		config.timeSeries.RunStart(config.scenario, config.metadata.Start)
+	}
+	if config.transactionLog != nil {
+		config.transactionLog.RunStart(config.scenario)
+	}
+
+	// Scripts with a rate of their own get clients of their own, so there may be more clients than numClients
+	clients := wrk.PlanClients(config.clients, config.latencyMode, config.rate, config.profile)
+	// Shared by all workers, so they pause together, with --burst and on SIGUSR1 until SIGUSR2
+	var burstGate *neobench.Gate
+	if config.burst.On > 0 {
+		burstGate = neobench.NewGate()
+	}
+	pauseGate := neobench.NewGate()
+	resultChan := make(chan neobench.WorkerResult, len(clients))
 	resultRecorders := make([]*neobench.ResultRecorder, 0)
+	// The collectors that run until stopped
 	var wg sync.WaitGroup
-	for i := 0; i < numClients; i++ {
-		wg.Add(1)
-		recorder := neobench.NewResultRecorder(int64(i))
+	// The workers, which may still be running a transaction once stopped, see --drain; closed once every worker is done
+	var workersWg sync.WaitGroup
+	workersDone := make(chan struct{})
+	for i, client := range clients {
+		workersWg.Add(1)
+		recorder := neobench.NewResultRecorder(int64(i), rampEnd)
+		if config.timeSeries != nil {
+			recorder.EnableWindowReports()
+		}
+		if config.transactionLog != nil {
+			recorder.EnableTransactionLog(config.transactionLog)
+		}
 		resultRecorders = append(resultRecorders, recorder)
-		worker := neobench.NewWorker(driver, int64(i))
+		worker := neobench.NewWorker(driver, int64(i), config.statementLatencies, logger)
+		worker.StartAfter(neobench.RampStartDelay(int64(i), len(clients), config.ramp))
+		if client.CapRate {
+			worker.CapRate()
+		}
+		if client.Schedule != nil {
+			worker.ShareSchedule(client.Schedule)
+		}
+		if !config.thinkTime.IsZero() {
+			// Apart from the workload's random numbers, so pausing doesn't change the parameters it generates, but
+			// from the same seed, so runs with that seed pause the same way
+			worker.ThinkBetween(config.thinkTime, rand.New(rand.NewSource(config.metadata.Seed+int64(i))))
+		}
+		if config.sessionPerTransaction {
+			worker.SessionPerTransaction()
+		}
+		if burstGate != nil {
+			worker.UseGate(burstGate)
+		}
+		worker.UseGate(pauseGate)
+		if client.Profile != nil {
+			worker.FollowProfile(client.Profile, client.Clients)
+		}
 		workerId := i
-		clientWork := wrk.NewClient()
+		client := client
 		go func() {
-			defer wg.Done()
-			result := worker.RunBenchmark(clientWork, databaseName, ratePerWorkerDuration, 0, stopCh, recorder)
+			defer workersWg.Done()
+			result := worker.RunBenchmark(client.Workload, config.databaseName, client.TransactionRate, config.transactions, stopCh, budget, recorder)
 			resultChan <- result
 			if result.Error != nil {
-				out.Errorf("worker %d crashed: %s", workerId, result.Error)
+				config.out.Errorf("worker %d crashed: %s", workerId, result.Error)
 				stop()
 			}
 		}()
 	}
 
-	deadline := time.Now().Add(runtime)
-	awaitCompletion(stopCh, deadline, out, databaseName, scenario, progressInterval, resultRecorders)
+	go func() {
+		workersWg.Wait()
+		close(workersDone)
+	}()
+	lastRunDone = workersDone
+
+	if burstGate != nil {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			config.burst.Run(burstGate, stopCh)
+		}()
+	}
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		neobench.PauseOnSignals(pauseGate, logger, stopCh)
+	}()
+
+	var serverMetrics *neobench.ServerMetricsCollector
+	if config.collectServerMetrics {
+		serverMetrics = neobench.NewServerMetricsCollector(driver, config.progressInterval)
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			serverMetrics.Run(stopCh)
+		}()
+	}
+
+	var clientDiagnostics *neobench.ClientDiagnosticsCollector
+	if config.diagnoseClient {
+		clientDiagnostics = neobench.NewClientDiagnosticsCollector(config.progressInterval)
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			clientDiagnostics.Run(stopCh)
+		}()
+	}
+
+	// A zero deadline means there is no time limit, only the write budget or the number of transactions
+	deadline := time.Time{}
+	if config.duration > 0 {
+		deadline = time.Now().Add(config.duration)
+	}
+	throughputSamples, stopped := awaitCompletion(config, stopCh, deadline, rampEnd, budget, wrk.Params.Exhausted(), workersDone, int64(config.transactions)*int64(len(clients)), resultRecorders)
 	stop()
 	wg.Wait()
+	// Transactions still running get until the end of the drain to finish, and count towards the results
+	drainTimer := time.NewTimer(config.drain)
+	select {
+	case <-workersDone:
+	case <-drainTimer.C:
+	}
+	drainTimer.Stop()
+	config.metadata.End = time.Now()
+	if config.transactionLog != nil {
+		if err := config.transactionLog.RunEnd(); err != nil {
+			config.out.Errorf("%s", err)
+		}
+	}
+	if config.timeSeries != nil {
+		// The last window ends with the run, so nothing recorded after the last full window goes missing
+		recordTimeSeriesWindow(config.timeSeries, config.out, config.databaseName, config.scenario, time.Now(), resultRecorders)
+	}
 
-	return collectResults(databaseName, scenario, out, numClients, resultChan)
+	result, err := collectResults(config.databaseName, config.scenario, config.out, resultChan, resultRecorders, config.drain)
+	if wrk.HeadToHead {
+		for _, script := range wrk.Scripts.Scripts {
+			result.HeadToHead = append(result.HeadToHead, script.Name)
+		}
+	}
+	for _, script := range wrk.Scripts.Scripts {
+		if script.Description == "" {
+			continue
+		}
+		if result.Descriptions == nil {
+			result.Descriptions = make(map[string]string)
+		}
+		result.Descriptions[script.Name] = script.Description
+	}
+	if serverMetrics != nil {
+		samples, metricsErr := serverMetrics.Samples()
+		if metricsErr != nil {
+			config.out.Errorf("server metrics are incomplete or missing, sampling failed: %s", metricsErr)
+		}
+		result.ServerMetrics = samples
+	}
+	if clientDiagnostics != nil {
+		diagnostics := clientDiagnostics.Diagnostics()
+		result.ClientDiagnostics = &diagnostics
+	}
+	result.Metadata = &config.metadata
+	result.ThroughputSamples = throughputSamples
+	result.Aborted = stopped.aborted
+	result.Settled = stopped.settled
+	return result, err
 }
 
-func collectResults(databaseName, scenario string, out neobench.Output, concurrency int, resultChan chan neobench.WorkerResult) (neobench.Result, error) {
-	// Collect results
-	results := make([]neobench.WorkerResult, 0, concurrency)
-	for i := 0; i < concurrency; i++ {
-		results = append(results, <-resultChan)
+// Transactions cut off at the end of a run, see collectResults, are still running against the database; the next
+// run of a sweep, phase, step or --alternate waits for them, up to cutOffGrace, so they don't skew its results
+func waitForCutOff(out neobench.Output) {
+	if lastRunDone == nil {
+		return
+	}
+	select {
+	case <-lastRunDone:
+		return
+	default:
+	}
+	neobench.Noticef(out, "waiting up to %s for the transactions cut off at the end of the last run to finish", cutOffGrace)
+	timer := time.NewTimer(cutOffGrace)
+	defer timer.Stop()
+	select {
+	case <-lastRunDone:
+	case <-timer.C:
+		out.Errorf("transactions cut off at the end of the last run were still running %s later, and may skew the results of this one", cutOffGrace)
+	}
+}
+
+// Runs the workload for the given duration and throws the results away; used to get caches and connection
+// pools into a representative state before measuring. Returns how many transactions it threw away.
+func runWarmup(driver neo4j.Driver, databaseName string, out neobench.Output, wrk neobench.Workload,
+	warmup time.Duration, latencyMode bool, numClients int, rate float64, thinkTime neobench.ThinkTime, sessionPerTransaction bool, seed int64) (int64, error) {
+	stopCh, stop := neobench.SetupSignalHandler()
+	defer stop()
+
+	wrk = wrk.RestartParams()
+	clients := wrk.PlanClients(numClients, latencyMode, rate, nil)
+	crashed := make(chan error, len(clients))
+	recorders := make([]*neobench.ResultRecorder, 0, len(clients))
+	var wg sync.WaitGroup
+	for i, client := range clients {
+		wg.Add(1)
+		recorder := neobench.NewResultRecorder(int64(i), time.Time{})
+		recorders = append(recorders, recorder)
+		worker := neobench.NewWorker(driver, int64(i), false, logger)
+		if client.CapRate {
+			worker.CapRate()
+		}
+		if client.Schedule != nil {
+			worker.ShareSchedule(client.Schedule)
+		}
+		if !thinkTime.IsZero() {
+			worker.ThinkBetween(thinkTime, rand.New(rand.NewSource(seed+int64(i))))
+		}
+		if sessionPerTransaction {
+			worker.SessionPerTransaction()
+		}
+		client := client
+		go func() {
+			defer wg.Done()
+			result := worker.RunBenchmark(client.Workload, databaseName, client.TransactionRate, 0, stopCh, nil, recorder)
+			if result.Error != nil {
+				crashed <- result.Error
+				stop()
+			}
+		}()
+	}
+
+	start := time.Now()
+	deadline := start.Add(warmup)
+	for now := start; now.Before(deadline); now = time.Now() {
+		select {
+		case <-stopCh:
+			wg.Wait()
+			select {
+			case err := <-crashed:
+				return 0, errors.Wrap(err, "worker crashed during warmup")
+			default:
+				return 0, fmt.Errorf("interrupted during warmup")
+			}
+		default:
+		}
+		out.ReportInitProgress(neobench.ProgressReport{
+			Section:      "warmup",
+			Step:         "running workload",
+			Completeness: now.Sub(start).Seconds() / warmup.Seconds(),
+		})
+		time.Sleep(time.Millisecond * 100)
+	}
+	stop()
+	wg.Wait()
+	discarded := int64(0)
+	for _, r := range recorders {
+		discarded += r.Completed()
+	}
+	return discarded, nil
+}
+
+// Workers that haven't stopped by now, after the drain, are cut off with what they recorded so far
+func collectResults(databaseName, scenario string, out neobench.Output, resultChan chan neobench.WorkerResult, recorders []*neobench.ResultRecorder, drain time.Duration) (neobench.Result, error) {
+	results := make([]neobench.WorkerResult, 0, len(recorders))
+	stopped := make(map[int64]bool)
+	for len(results) < len(recorders) {
+		select {
+		case res := <-resultChan:
+			results = append(results, res)
+			stopped[res.WorkerId] = true
+			continue
+		default:
+		}
+		now := time.Now()
+		cutOff := 0
+		for i, recorder := range recorders {
+			if stopped[int64(i)] {
+				continue
+			}
+			res, running := recorder.CutOff(now)
+			results = append(results, res)
+			if running {
+				cutOff++
+			}
+		}
+		if cutOff > 0 {
+			out.Errorf("%d transactions were still running %s after the run was over, and are left out of the results, see --drain", cutOff, drain)
+		}
 	}
 
 	total := neobench.NewResult(databaseName, scenario)
@@ -420,13 +1781,15 @@ func collectResults(databaseName, scenario string, out neobench.Output, concurre
 			out.Errorf("Worker failed: %v", res.Error)
 			continue
 		}
-		total.Add(res)
+		if err := total.Add(res); err != nil {
+			return total, err
+		}
 	}
 
 	return total, nil
 }
 
-func initWorkload(paths []string, dbName string, scale, seed int64, driver neo4j.Driver, out neobench.Output, version string) error {
+func initWorkload(paths []string, dbName string, scale float64, seed int64, driver neo4j.Driver, out neobench.Output, version string) error {
 	for _, path := range paths {
 		if path == "tpcb-like" {
 			return builtin.InitTPCBLike(scale, dbName, driver, out, version)
@@ -435,39 +1798,236 @@ func initWorkload(paths []string, dbName string, scale, seed int64, driver neo4j
 			return builtin.InitTPCBLike(scale, dbName, driver, out, version)
 		}
 		if path == "ldbc-like" {
-			return builtin.InitLDBCLike(scale, seed, dbName, driver, out, version)
+			return builtin.InitLDBCLike(int64(scale), seed, dbName, driver, out, version)
 		}
 	}
 	return nil
 }
 
-func awaitCompletion(stopCh chan struct{}, deadline time.Time, out neobench.Output, databaseName, scenario string, progressInterval time.Duration, recorders []*neobench.ResultRecorder) {
-	nextProgressReport := time.Now().Add(progressInterval)
-	originalDelta := deadline.Sub(time.Now()).Seconds()
-	for {
-		select {
-		case <-stopCh:
+// The variables scripts get from --scale and -D
+func commandLineVariables() map[string]interface{} {
+	variables := make(map[string]interface{})
+	if fScale <= 0 {
+		logger.Fatalf("Scale (--scale %g) must be greater than 0", fScale)
+	}
+	if fScale == math.Trunc(fScale) {
+		// Whole scales stay integers, so scripts doing integer math with $scale keep working as before
+		variables["scale"] = int64(fScale)
+	} else {
+		for _, path := range fBuiltinWorkloads {
+			if strings.HasPrefix(path, "ldbc-like") {
+				logger.Fatalf("The ldbc-like workload needs a whole number --scale, got %g", fScale)
+			}
+		}
+		variables["scale"] = fScale
+	}
+	for k, v := range fVariables {
+		value, err := parseDefineValue(k, v)
+		if err != nil {
+			logger.Fatalf("-D %s=%s: %s", k, v, err)
+		}
+		variables[k] = value
+	}
+	return variables
+}
+
+// Parses the scripts given after validate, with -f, -S or -b, and prints what's wrong with them, without
+// connecting to a database: where they fail to parse, and which variables they use that nothing defines.
+// Returns the exit status, 1 if any of the scripts has a problem.
+func validateScripts(paths []string) int {
+	variables := commandLineVariables()
+	sweepVar, sweepValues, err := parseSweep(fSweep)
+	if err != nil {
+		logger.Fatalf("%+v", err)
+	}
+	if sweepVar != "" {
+		variables[sweepVar] = sweepValues[0]
+	}
+	if fParamsFile != "" {
+		params, err := neobench.LoadParamsFile(neobench.NewCsvLoader(), fParamsFile, neobench.ParamsCycle)
+		if err != nil {
+			logger.Fatalf("%+v", err)
+		}
+		for k, v := range params.FirstRow() {
+			variables[k] = v
+		}
+	}
+
+	type source struct {
+		name    string
+		content string
+		err     error
+	}
+	var sources []source
+	for _, rawPath := range append(paths, fWorkloadFiles...) {
+		path, _, _ := splitScriptAndWeight(rawPath)
+		content, err := ioutil.ReadFile(path)
+		if err != nil {
+			err = fmt.Errorf("failed to read workload file at %s: %s", path, err)
+		}
+		sources = append(sources, source{name: path, content: string(content), err: err})
+	}
+	for i, content := range fWorkloadScripts {
+		sources = append(sources, source{name: fmt.Sprintf("-S #%d", i), content: content})
+	}
+	if len(sources) == 0 && len(fBuiltinWorkloads) == 0 {
+		logger.Fatalf("validate needs scripts to check, like neobench validate transfer.script, or with -f, -S or -b")
+	}
+
+	status := 0
+	check := func(name string, script neobench.Script, err error) {
+		if err != nil {
+			fmt.Printf("%s: %s\n", name, err)
+			status = 1
 			return
-		default:
 		}
+		validation := script.Validate(variables)
+		if len(validation.Undefined) > 0 {
+			fmt.Printf("%s: uses variables that are never defined: $%s; define them with -D, :set or :params\n",
+				name, strings.Join(validation.Undefined, ", $"))
+			status = 1
+		} else {
+			fmt.Printf("%s: ok\n", name)
+		}
+		if len(validation.Variables) > 0 {
+			fmt.Printf("  variables: $%s\n", strings.Join(validation.Variables, ", $"))
+		}
+	}
+	for _, rawPath := range fBuiltinWorkloads {
+		path, weight, _ := splitScriptAndWeight(rawPath)
+		scripts, err := loadBuiltinWorkload(path, weight)
+		if err != nil {
+			check("builtin:"+path, neobench.Script{}, err)
+			continue
+		}
+		for _, script := range scripts {
+			check(script.Source, script, nil)
+		}
+	}
+	for _, src := range sources {
+		if src.err != nil {
+			check(src.name, neobench.Script{}, src.err)
+			continue
+		}
+		script, err := neobench.Parse(src.name, src.content, 1)
+		check(src.name, script, err)
+	}
+	return status
+}
 
-		now := time.Now()
-		delta := deadline.Sub(now)
-		if delta < 2*time.Second {
-			time.Sleep(delta)
-			break
+// Runs the :init block of each script of the workloads that has one, one script after the other; a
+// script in the workloads of several phases is set up once
+func initScripts(workloads []neobench.Workload, dbName string, seed int64, driver neo4j.Driver, out neobench.Output) error {
+	done := make(map[string]bool)
+	for _, wrk := range workloads {
+		for _, script := range wrk.Scripts.Scripts {
+			if script.Init == nil || done[script.Source] {
+				continue
+			}
+			done[script.Source] = true
+			if err := neobench.RunScriptInit(driver, dbName, wrk, script, seed, out); err != nil {
+				return err
+			}
 		}
+	}
+	return nil
+}
+
+// Returns the throughput sampled every neobench.ThroughputSampleInterval once ramp-up is over, and why the run
+// stopped early, if --max-error-rate or --until-stable stopped it; transactions is the total of all clients
+func awaitCompletion(config runConfig, stopCh chan struct{}, deadline, rampEnd time.Time, budget *neobench.WriteBudget, paramsExhausted, workersDone <-chan struct{}, transactions int64, recorders []*neobench.ResultRecorder) ([]float64, earlyStop) {
+	nextProgressReport := time.Now().Add(config.progressInterval)
+	sampler := neobench.NewThroughputSampler(neobench.ThroughputSampleInterval)
+	var nextWindow time.Time
+	if config.timeSeries != nil {
+		nextWindow = time.Now().Add(config.timeSeries.Window)
+	}
+	originalDelta := deadline.Sub(time.Now()).Seconds()
+
+	// Wake up exactly at the deadline, when the write budget or params file is used up, when every worker has
+	// done its transactions, or when asked to stop, whichever comes first; the ticker is to check if it's time for a
+	// progress report, and if too many transactions have failed to go on
+	var deadlineCh <-chan time.Time
+	if !deadline.IsZero() {
+		deadlineTimer := time.NewTimer(deadline.Sub(time.Now()))
+		defer deadlineTimer.Stop()
+		deadlineCh = deadlineTimer.C
+	}
+	ticker := time.NewTicker(time.Millisecond * 100)
+	defer ticker.Stop()
 
-		if now.After(nextProgressReport) {
-			nextProgressReport = nextProgressReport.Add(progressInterval)
-			checkpoint := neobench.NewResult(databaseName, scenario)
+	for {
+		select {
+		case <-stopCh:
+			return sampler.Samples, earlyStop{}
+		case <-deadlineCh:
+			return sampler.Samples, earlyStop{}
+		case <-budget.Exhausted():
+			return sampler.Samples, earlyStop{}
+		case <-paramsExhausted:
+			return sampler.Samples, earlyStop{}
+		case <-workersDone:
+			return sampler.Samples, earlyStop{}
+		case now := <-ticker.C:
+			if !now.Before(rampEnd) {
+				completed := int64(0)
+				for _, r := range recorders {
+					completed += r.Completed()
+				}
+				sampler.Sample(now, completed)
+				if settled := config.stability.Settled(sampler.Samples); settled != "" {
+					neobench.Noticef(config.out, "stopping early, %s", settled)
+					return sampler.Samples, earlyStop{settled: settled}
+				}
+			}
+			if config.maxErrorRate > 0 {
+				recorded, failed := int64(0), int64(0)
+				for _, r := range recorders {
+					recorded += r.Recorded()
+					failed += r.Failed()
+				}
+				if aborted := neobench.ErrorRateAbort(config.maxErrorRate, recorded, failed); aborted != "" {
+					config.out.Errorf("stopping early, %s", aborted)
+					return sampler.Samples, earlyStop{aborted: aborted}
+				}
+			}
+			if config.timeSeries != nil && !now.Before(nextWindow) {
+				nextWindow = nextWindow.Add(config.timeSeries.Window)
+				recordTimeSeriesWindow(config.timeSeries, config.out, config.databaseName, config.scenario, now, recorders)
+			}
+			if now.Before(nextProgressReport) {
+				continue
+			}
+			nextProgressReport = nextProgressReport.Add(config.progressInterval)
+			checkpoint := neobench.NewResult(config.databaseName, config.scenario)
 			for _, r := range recorders {
-				checkpoint.Add(r.ProgressReport(time.Now()))
+				if err := checkpoint.Add(r.ProgressReport(now)); err != nil {
+					config.out.Errorf("failed to create progress report: %s", err)
+				}
 			}
 
-			completeness := 1 - delta.Seconds()/originalDelta
-			out.ReportWorkloadProgress(completeness, checkpoint)
+			completeness := budget.Progress()
+			if transactions > 0 {
+				recorded := int64(0)
+				for _, r := range recorders {
+					recorded += r.Recorded()
+				}
+				completeness = math.Max(completeness, float64(recorded)/float64(transactions))
+			}
+			if !deadline.IsZero() {
+				completeness = math.Max(completeness, 1-deadline.Sub(now).Seconds()/originalDelta)
+			}
+			config.out.ReportWorkloadProgress(completeness, checkpoint)
+		}
+	}
+}
+
+func recordTimeSeriesWindow(timeSeries *neobench.TimeSeriesWriter, out neobench.Output, databaseName, scenario string, now time.Time, recorders []*neobench.ResultRecorder) {
+	window := neobench.NewResult(databaseName, scenario)
+	for _, r := range recorders {
+		if err := window.Add(r.WindowReport(now)); err != nil {
+			out.Errorf("failed to create time series window: %s", err)
 		}
-		time.Sleep(time.Millisecond * 100)
 	}
+	timeSeries.Record(now, window)
 }
@@ -0,0 +1,37 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseSweep(t *testing.T) {
+	for raw, expected := range map[string]struct {
+		variable string
+		values   []interface{}
+	}{
+		"":                      {"", nil},
+		"batchSize=10,100,1000": {"batchSize", []interface{}{int64(10), int64(100), int64(1000)}},
+		"ratio= 0.5 , 1.5":      {"ratio", []interface{}{0.5, 1.5}},
+		// Integers stay integers next to floats
+		"x=1,2.5": {"x", []interface{}{int64(1), 2.5}},
+	} {
+		variable, values, err := parseSweep(raw)
+		assert.NoError(t, err, raw)
+		assert.Equal(t, expected.variable, variable, raw)
+		assert.Equal(t, expected.values, values, raw)
+	}
+
+	for raw, expected := range map[string]string{
+		"batchSize":   "--sweep must be on the form <variable>=<value>,<value>,.., got 'batchSize'",
+		"batchSize=":  "--sweep must be on the form <variable>=<value>,<value>,.., got 'batchSize='",
+		"=10,100":     "--sweep must be on the form <variable>=<value>,<value>,.., got '=10,100'",
+		"x=1,,2":      "--sweep values must be integers or floats, failing to parse '': strconv.ParseFloat: parsing \"\": invalid syntax",
+		"x=1,abc":     "--sweep values must be integers or floats, failing to parse 'abc': strconv.ParseFloat: parsing \"abc\": invalid syntax",
+		"mode=fast,1": "--sweep values must be integers or floats, failing to parse 'fast': strconv.ParseFloat: parsing \"fast\": invalid syntax",
+	} {
+		_, _, err := parseSweep(raw)
+		assert.EqualError(t, err, expected, raw)
+	}
+}
@@ -0,0 +1,29 @@
+package neobench
+
+import "fmt"
+
+// Transactions a run records before --max-error-rate is checked, so a failure or two at the start can't abort it
+const MaxErrorRateMinTransactions = 100
+
+// Parses --max-error-rate, a percentage like 5% or a fraction like 0.05
+func ParseMaxErrorRate(raw string) (float64, error) {
+	rate, err := parseFraction(raw)
+	if err != nil || rate <= 0 || rate >= 1 {
+		return 0, fmt.Errorf("--max-error-rate should be above 0%% and below 100%%, like 5%% or 0.05, got %s", raw)
+	}
+	return rate, nil
+}
+
+// Why a run with failed out of recorded transactions should stop under --max-error-rate; empty if it shouldn't,
+// or if maxErrorRate is zero
+func ErrorRateAbort(maxErrorRate float64, recorded, failed int64) string {
+	if maxErrorRate <= 0 || recorded < MaxErrorRateMinTransactions {
+		return ""
+	}
+	errorRate := float64(failed) / float64(recorded)
+	if errorRate <= maxErrorRate {
+		return ""
+	}
+	return fmt.Sprintf("%d of %d transactions failed, an error rate of %.2f%%, above --max-error-rate %.2f%%",
+		failed, recorded, errorRate*100, maxErrorRate*100)
+}
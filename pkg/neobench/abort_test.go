@@ -0,0 +1,58 @@
+package neobench
+
+import (
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseMaxErrorRate(t *testing.T) {
+	rate, err := ParseMaxErrorRate("5%")
+	assert.NoError(t, err)
+	assert.Equal(t, 0.05, rate)
+	rate, err = ParseMaxErrorRate("0.1")
+	assert.NoError(t, err)
+	assert.Equal(t, 0.1, rate)
+
+	for _, raw := range []string{"0%", "100%", "1.5", "lots"} {
+		_, err = ParseMaxErrorRate(raw)
+		assert.EqualError(t, err, "--max-error-rate should be above 0% and below 100%, like 5% or 0.05, got "+raw)
+	}
+}
+
+func TestErrorRateAbort(t *testing.T) {
+	// Not before there are enough transactions to go by
+	assert.Equal(t, "", ErrorRateAbort(0.05, MaxErrorRateMinTransactions-1, MaxErrorRateMinTransactions-1))
+	assert.Equal(t, "", ErrorRateAbort(0.05, 200, 10))
+	assert.Equal(t, "", ErrorRateAbort(0, 200, 200))
+	assert.Equal(t, "11 of 200 transactions failed, an error rate of 5.50%, above --max-error-rate 5.00%",
+		ErrorRateAbort(0.05, 200, 11))
+}
+
+func TestRecorderCountsFailures(t *testing.T) {
+	recorder := NewResultRecorder(0, time.Now().Add(time.Hour))
+	assert.NoError(t, recorder.record("s", time.Now(), time.Millisecond, uowOutcome{succeeded: true}))
+	assert.NoError(t, recorder.record("s", time.Now(), time.Millisecond, uowOutcome{succeeded: false, failureGroup: "unknown", err: os.ErrClosed}))
+
+	// Ramp-up included
+	assert.Equal(t, int64(2), recorder.Recorded())
+	assert.Equal(t, int64(1), recorder.Failed())
+}
+
+func TestAbortedResultsAreMarkedPartial(t *testing.T) {
+	result := rateSearchResult(t, 10, time.Millisecond)
+	result.Aborted = "11 of 200 transactions failed, an error rate of 5.50%, above --max-error-rate 5.00%"
+
+	s := strings.Builder{}
+	(&InteractiveOutput{OutStream: &s}).ReportLatency(result)
+	assert.Contains(t, s.String(), "Aborted: 11 of 200 transactions failed, an error rate of 5.50%, above --max-error-rate 5.00%; these results are partial\n")
+
+	condition, err := ParseRateSearchCondition("p99<20ms")
+	assert.NoError(t, err)
+	search, err := NewRateSearch(condition, 1000)
+	assert.NoError(t, err)
+	assert.False(t, search.Record(10, result))
+}
@@ -0,0 +1,172 @@
+package neobench
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// The second configuration of --alternate, which takes turns with the one from the command line in windows of
+// --alternate-window, so both see the same server over the course of the run; noise that comes and goes, like
+// a checkpoint or another tenant, hits both rather than just whichever ran second. It can set -b, -f, -S and
+// -c, the rest is the same for both.
+func ParseAlternate(options string) (Phase, error) {
+	alternate, err := parsePhase("b", options)
+	if err != nil {
+		reason := strings.SplitN(err.Error(), "; phases can set", 2)[0]
+		return alternate, fmt.Errorf("--alternate: %s; it can set -b, -f, -S and -c", reason)
+	}
+	for _, option := range []string{"duration", "transactions", "latency", "rate"} {
+		if alternate.Sets(option) {
+			return alternate, fmt.Errorf("--alternate can set -b, -f, -S and -c, everything else is the same for both configurations, got --%s", option)
+		}
+	}
+	if !alternate.SetsWorkload() && !alternate.Sets("clients") {
+		return alternate, fmt.Errorf("--alternate needs to set at least one of -b, -f, -S or -c, otherwise both configurations are the same")
+	}
+	return alternate, nil
+}
+
+// How many windows of each configuration fit in the duration of the run, rounded to a whole number of pairs, so
+// both run for the same time; at least one each
+func AlternatePairs(duration, window time.Duration) int {
+	pairs := int((duration + window) / (2 * window))
+	if pairs < 1 {
+		return 1
+	}
+	return pairs
+}
+
+// Pools the results of the windows one configuration of --alternate ran in into one result, as if it had been one
+// run of the time it ran for. Rates are over the time of all the windows together, from their metadata, as a
+// window can be cut short, and a script may not run in every window. Server metrics and throughput samples are
+// those of each window one after the other, and client diagnostics are those of the last window.
+func MergeWindows(databaseName, scenario string, windows []Result) (Result, error) {
+	merged := NewResult(databaseName, scenario)
+	if len(windows) == 0 {
+		return merged, nil
+	}
+	var length time.Duration
+	for i, window := range windows {
+		if window.Metadata == nil {
+			return merged, fmt.Errorf("window %d has no metadata to tell how long it ran for", i+1)
+		}
+		length += window.Metadata.End.Sub(window.Metadata.Start)
+		for _, script := range window.Scripts {
+			into := merged.Scripts[script.ScriptName]
+			if into == nil {
+				into = &ScriptResult{ScriptName: script.ScriptName, Latencies: newLatencyHistogram()}
+				merged.Scripts[script.ScriptName] = into
+			}
+			if err := into.mergeWindow(script); err != nil {
+				return merged, err
+			}
+		}
+		for i, worker := range window.Workers {
+			if i == len(merged.Workers) {
+				merged.Workers = append(merged.Workers, WorkerStats{WorkerId: worker.WorkerId, Latencies: newLatencyHistogram()})
+			}
+			into := &merged.Workers[i]
+			into.Succeeded += worker.Succeeded
+			into.Failed += worker.Failed
+			if err := mergeHistograms(into.Latencies, worker.Latencies); err != nil {
+				return merged, errors.Wrapf(err, "failed to combine latencies of worker %d", worker.WorkerId)
+			}
+		}
+		for name, group := range window.FailedByErrorGroup {
+			merged.FailedByErrorGroup[name] = merged.FailedByErrorGroup[name].merge(group)
+		}
+		for code, count := range window.LockErrors {
+			merged.LockErrors[code] += count
+		}
+		if err := mergeHistograms(merged.AcquireLatencies, window.AcquireLatencies); err != nil {
+			return merged, errors.Wrapf(err, "failed to combine acquire latencies")
+		}
+		merged.ServerMetrics = append(merged.ServerMetrics, window.ServerMetrics...)
+		merged.ThroughputSamples = append(merged.ThroughputSamples, window.ThroughputSamples...)
+		merged.HeadToHead = window.HeadToHead
+		merged.Descriptions = window.Descriptions
+		if window.ClientDiagnostics != nil {
+			merged.ClientDiagnostics = window.ClientDiagnostics
+		}
+		if window.Aborted != "" {
+			merged.Aborted = window.Aborted
+		}
+		if window.Settled != "" {
+			merged.Settled = window.Settled
+		}
+	}
+	if seconds := length.Seconds(); seconds > 0 {
+		for _, script := range merged.Scripts {
+			script.Rate = float64(script.Succeeded+script.Failed) / seconds
+			script.RowsWrittenRate = float64(script.RowsWritten) / seconds
+			script.BytesWrittenRate = float64(script.BytesWritten) / seconds
+		}
+		for i := range merged.Workers {
+			merged.Workers[i].Rate = float64(merged.Workers[i].Succeeded+merged.Workers[i].Failed) / seconds
+		}
+	}
+	if first, last := windows[0].Metadata, windows[len(windows)-1].Metadata; first != nil && last != nil {
+		metadata := *first
+		metadata.End = last.End
+		merged.Metadata = &metadata
+	}
+	return merged, nil
+}
+
+// Adds the results of a script in one of the windows, see MergeWindows, which works out the rates once they're all in
+func (r *ScriptResult) mergeWindow(window *ScriptResult) error {
+	r.Succeeded += window.Succeeded
+	r.Failed += window.Failed
+	r.Retried += window.Retried
+	r.Deadlocks += window.Deadlocks
+	r.RowsWritten += window.RowsWritten
+	r.BytesWritten += window.BytesWritten
+	if err := mergeHistograms(r.Latencies, window.Latencies); err != nil {
+		return errors.Wrapf(err, "failed to combine latencies for %s", r.ScriptName)
+	}
+	if err := mergeOptionalHistogram(&r.ServerLatencies, window.ServerLatencies); err != nil {
+		return errors.Wrapf(err, "failed to combine server latencies for %s", r.ScriptName)
+	}
+	if err := mergeOptionalHistogram(&r.UncorrectedLatencies, window.UncorrectedLatencies); err != nil {
+		return errors.Wrapf(err, "failed to combine uncorrected latencies for %s", r.ScriptName)
+	}
+	for _, statement := range window.Statements {
+		into := r.getOrCreateStatementResult(Statement{Command: statement.Command, Query: statement.Query})
+		if err := mergeHistograms(into.Latencies, statement.Latencies); err != nil {
+			return errors.Wrapf(err, "failed to combine latencies for statement %d of %s", statement.Command, r.ScriptName)
+		}
+	}
+	return nil
+}
+
+// The two configurations side by side, with the difference of the second relative to the first, and the scripts
+// each ran
+func writeAlternateReport(sweep SweepResult, s *strings.Builder) {
+	s.WriteString("== Alternating windows ==\n")
+	s.WriteString(fmt.Sprintf("Scenario: %s\n", sweep.Scenario))
+	if len(sweep.Results) != 2 {
+		return
+	}
+	totals := make([]*ScriptResult, 2)
+	for i, result := range sweep.Results {
+		totals[i] = &ScriptResult{
+			ScriptName: fmt.Sprintf("%v", sweep.Values[i]),
+			Succeeded:  result.TotalSucceeded(),
+			Failed:     result.TotalFailed(),
+			Rate:       result.TotalRate(),
+			Latencies:  result.CombinedLatencies(),
+		}
+		scripts := make([]string, 0, len(result.Scripts))
+		for name := range result.Scripts {
+			scripts = append(scripts, name)
+		}
+		sort.Strings(scripts)
+		s.WriteString(fmt.Sprintf("  %s: %s\n", totals[i].ScriptName, strings.Join(scripts, ", ")))
+	}
+	s.WriteString("\n")
+	writeSideBySide(totals[0], totals[1], s)
+}
@@ -0,0 +1,119 @@
+package neobench
+
+import (
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseAlternate(t *testing.T) {
+	alternate, err := ParseAlternate("-f rewritten.script -c 4")
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"rewritten.script"}, alternate.Files)
+	assert.Equal(t, 4, alternate.Clients)
+
+	for raw, expected := range map[string]string{
+		"-d 10s":         "--alternate can set -b, -f, -S and -c, everything else is the same for both configurations, got --duration",
+		"-f a.script -l": "--alternate can set -b, -f, -S and -c, everything else is the same for both configurations, got --latency",
+		"":               "--alternate needs to set at least one of -b, -f, -S or -c, otherwise both configurations are the same",
+		"--sweep x=1":    "--alternate: unknown flag: --sweep; it can set -b, -f, -S and -c",
+	} {
+		_, err := ParseAlternate(raw)
+		assert.EqualError(t, err, expected, raw)
+	}
+}
+
+func TestAlternatePairs(t *testing.T) {
+	assert.Equal(t, 1, AlternatePairs(time.Minute, 30*time.Second))
+	assert.Equal(t, 10, AlternatePairs(10*time.Minute, 30*time.Second))
+	// Rounded to the nearest whole pair, and at least one
+	assert.Equal(t, 2, AlternatePairs(100*time.Second, 30*time.Second))
+	assert.Equal(t, 1, AlternatePairs(time.Second, 30*time.Second))
+}
+
+// A window of the given length that ran the script count times, and failed it once
+func testWindow(t *testing.T, start time.Time, length time.Duration, script string, count int) Result {
+	w := NewWorkerResult(0)
+	for i := 0; i < count; i++ {
+		assert.NoError(t, w.record(script, time.Millisecond, uowOutcome{succeeded: true}))
+	}
+	assert.NoError(t, w.record(script, time.Millisecond, uowOutcome{succeeded: false, failureGroup: "unknown", err: os.ErrClosed}))
+	w.calculateRate(length)
+	result := NewResult("", "")
+	assert.NoError(t, result.Add(w))
+	result.Metadata = &RunMetadata{Start: start, End: start.Add(length)}
+	return result
+}
+
+func TestMergeWindowsPoolsTheWindowsOfAConfiguration(t *testing.T) {
+	start := time.Date(2020, 1, 1, 1, 1, 1, 1, time.UTC)
+	windows := []Result{
+		testWindow(t, start, time.Second, "a", 2),
+		testWindow(t, start.Add(2*time.Second), time.Second, "a", 4),
+	}
+
+	merged, err := MergeWindows("db", "-c 1, configuration a", windows)
+	assert.NoError(t, err)
+	assert.Equal(t, "-c 1, configuration a", merged.Scenario)
+	script := merged.Scripts["a"]
+	assert.Equal(t, int64(6), script.Succeeded)
+	assert.Equal(t, int64(2), script.Failed)
+	// 3 and 5 transactions per second
+	assert.InDelta(t, 4, script.Rate, 0.001)
+	assert.Equal(t, int64(6), script.Latencies.TotalCount())
+	assert.Len(t, merged.Workers, 1)
+	assert.Equal(t, int64(6), merged.Workers[0].Succeeded)
+	assert.Equal(t, int64(2), merged.FailedByErrorGroup["unknown"].Count)
+}
+
+func TestMergeWindowsTakesRatesOverTheTimeOfAllWindows(t *testing.T) {
+	start := time.Date(2020, 1, 1, 1, 1, 1, 1, time.UTC)
+	// The last window stopped early, and b only ran in the first
+	first := testWindow(t, start, 3*time.Second, "a", 5)
+	second := testWindow(t, start.Add(6*time.Second), time.Second, "a", 1)
+	second.Settled = "throughput within 5% over the last 3 samples"
+	b := NewWorkerResult(1)
+	assert.NoError(t, b.record("b", time.Millisecond, uowOutcome{succeeded: true}))
+	b.calculateRate(3 * time.Second)
+	assert.NoError(t, first.Add(b))
+
+	merged, err := MergeWindows("db", "-c 2, configuration a", []Result{first, second})
+	assert.NoError(t, err)
+	// 8 transactions in 4 seconds, rather than the mean of 2 and 2
+	assert.InDelta(t, 2, merged.Scripts["a"].Rate, 0.001)
+	// 1 transaction in 4 seconds, rather than the mean of a third and none
+	assert.InDelta(t, 0.25, merged.Scripts["b"].Rate, 0.001)
+	assert.InDelta(t, 2, merged.Workers[0].Rate, 0.001)
+	assert.Equal(t, "throughput within 5% over the last 3 samples", merged.Settled)
+}
+
+func TestAlternateReportPutsTheConfigurationsSideBySide(t *testing.T) {
+	sweep := SweepResult{Scenario: "-c 1 --alternate \"-f b.script\"", Variable: "configuration", Alternate: true}
+	for _, config := range []struct {
+		name, script string
+		count        int
+	}{{"a", "a", 2}, {"b", "b", 3}} {
+		w := NewWorkerResult(0)
+		for i := 0; i < config.count; i++ {
+			assert.NoError(t, w.record(config.script, time.Millisecond, uowOutcome{succeeded: true}))
+		}
+		w.calculateRate(time.Second)
+		result := NewResult("", "")
+		assert.NoError(t, result.Add(w))
+		sweep.Values = append(sweep.Values, config.name)
+		sweep.Results = append(sweep.Results, result)
+	}
+
+	s := strings.Builder{}
+	(&InteractiveOutput{OutStream: &s}).ReportSweep(sweep)
+	out := s.String()
+	assert.True(t, strings.HasPrefix(out, "== Alternating windows ==\n"+
+		"Scenario: -c 1 --alternate \"-f b.script\"\n"+
+		"  a: a\n"+
+		"  b: b\n\n"), out)
+	assert.Contains(t, out, "  succeeded 2     3     +50.0%")
+	assert.Contains(t, out, "  tps       2.000 3.000 +50.0%")
+}
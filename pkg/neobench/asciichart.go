@@ -0,0 +1,104 @@
+package neobench
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// Size of the latency chart at the end of interactive results, in characters, not counting axes and labels
+const (
+	latencyChartWidth  = 60
+	latencyChartHeight = 10
+)
+
+// p50 and p99 latency, in milliseconds, of the progress interval ending at elapsed
+type latencyPoint struct {
+	elapsed  time.Duration
+	p50, p99 float64
+}
+
+func newLatencyPoint(elapsed time.Duration, checkpoint Result) (latencyPoint, bool) {
+	if checkpoint.TotalSucceeded() == 0 {
+		return latencyPoint{}, false
+	}
+	latencies := checkpoint.CombinedLatencies()
+	return latencyPoint{
+		elapsed: elapsed,
+		p50:     float64(latencies.ValueAtQuantile(50)) / 1000.0,
+		p99:     float64(latencies.ValueAtQuantile(99)) / 1000.0,
+	}, true
+}
+
+// Plots p50 and p99 over the run as characters, so a run that degrades over time stands out in the terminal.
+// With more points than fit across, each column has the highest values of the points it covers, so spikes
+// aren't averaged away.
+func writeLatencyChart(points []latencyPoint, s *strings.Builder) {
+	if len(points) < 2 {
+		return
+	}
+	columns := make([]latencyPoint, 0, latencyChartWidth)
+	if len(points) <= latencyChartWidth {
+		columns = append(columns, points...)
+	} else {
+		for i := 0; i < latencyChartWidth; i++ {
+			covered := points[i*len(points)/latencyChartWidth : (i+1)*len(points)/latencyChartWidth]
+			column := covered[0]
+			for _, p := range covered[1:] {
+				column.elapsed = p.elapsed
+				if p.p50 > column.p50 {
+					column.p50 = p.p50
+				}
+				if p.p99 > column.p99 {
+					column.p99 = p.p99
+				}
+			}
+			columns = append(columns, column)
+		}
+	}
+
+	max := 0.0
+	for _, c := range columns {
+		if c.p99 > max {
+			max = c.p99
+		}
+		if c.p50 > max {
+			max = c.p50
+		}
+	}
+	if max == 0 {
+		max = 1
+	}
+	grid := make([][]rune, latencyChartHeight)
+	for i := range grid {
+		grid[i] = []rune(strings.Repeat(" ", len(columns)))
+	}
+	row := func(v float64) int {
+		return int(float64(latencyChartHeight-1)*(1-v/max) + 0.5)
+	}
+	for x, c := range columns {
+		grid[row(c.p50)][x] = 'o'
+		// Drawn last, so it wins where the two meet
+		grid[row(c.p99)][x] = '*'
+	}
+
+	labels := make(map[int]string)
+	for _, y := range []int{0, (latencyChartHeight - 1) / 2, latencyChartHeight - 1} {
+		labels[y] = fmt.Sprintf("%.3f", max*(1-float64(y)/float64(latencyChartHeight-1)))
+	}
+	labelWidth := len(labels[0])
+
+	s.WriteString("Latency over time (ms):\n")
+	for y, line := range grid {
+		s.WriteString(fmt.Sprintf("  %*s |%s\n", labelWidth, labels[y], strings.TrimRight(string(line), " ")))
+	}
+	s.WriteString(fmt.Sprintf("  %*s +%s\n", labelWidth, "", strings.Repeat("-", len(columns))))
+	first := columns[0].elapsed.Round(time.Second).String()
+	last := columns[len(columns)-1].elapsed.Round(time.Second).String()
+	gap := len(columns) - len(first) - len(last)
+	if gap < 1 {
+		gap = 1
+	}
+	s.WriteString(fmt.Sprintf("  %*s  %s%s%s\n", labelWidth, "", first, strings.Repeat(" ", gap), last))
+	s.WriteString(fmt.Sprintf("  %*s  * p99  o p50\n", labelWidth, ""))
+}
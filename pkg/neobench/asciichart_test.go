@@ -0,0 +1,49 @@
+package neobench
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestInteractiveResultsChartLatencyOverTime(t *testing.T) {
+	clock := time.Date(2020, 1, 1, 1, 1, 1, 0, time.UTC)
+	out := strings.Builder{}
+	o := &InteractiveOutput{ErrStream: &strings.Builder{}, OutStream: &out, now: func() time.Time { return clock }}
+	o.BenchmarkStart("", "neo4j://localhost:7687", "")
+
+	total := NewResult("", "")
+	for i := 1; i <= 10; i++ {
+		clock = clock.Add(10 * time.Second)
+		w := NewWorkerResult(0)
+		// Degrades halfway through
+		latency := time.Millisecond
+		if i > 5 {
+			latency = 10 * time.Millisecond
+		}
+		assert.NoError(t, w.record("a", latency, uowOutcome{succeeded: true}))
+		assert.NoError(t, w.record("a", 2*latency, uowOutcome{succeeded: true}))
+		checkpoint := NewResult("", "")
+		assert.NoError(t, checkpoint.Add(w))
+		o.ReportWorkloadProgress(float64(i)/10, checkpoint)
+		assert.NoError(t, total.Add(w))
+	}
+	o.ReportLatency(total)
+
+	assert.Contains(t, out.String(), "Latency over time (ms):\n"+
+		"  20.015 |     *****\n"+
+		"         |\n"+
+		"         |\n"+
+		"         |\n"+
+		"  11.119 |\n"+
+		"         |     ooooo\n"+
+		"         |\n"+
+		"         |\n"+
+		"         |*****\n"+
+		"   0.000 |ooooo\n"+
+		"         +----------\n"+
+		"          10s  1m40s\n"+
+		"          * p99  o p50\n")
+}
@@ -0,0 +1,105 @@
+package neobench
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"os"
+
+	"github.com/pkg/errors"
+)
+
+// Results of an earlier run to compare against, see --baseline; read from the output of -o json. Runs are
+// compared to the baseline result with the same scenario: throughput runs on their total rate, latency runs on
+// the p50, p95 and p99 of each script, since latencies from throughput runs are not meaningful, see
+// docs/overview.md.
+type Baseline struct {
+	Path string
+	// By scenario; if the file has several results for a scenario, the last one is used
+	results map[string]baselineResult
+}
+
+type baselineResult struct {
+	// "throughput" or "latency"
+	Mode string `json:"mode"`
+	jsonResult
+}
+
+func LoadBaseline(path string) (*Baseline, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to open --baseline")
+	}
+	defer file.Close()
+
+	b := &Baseline{Path: path, results: make(map[string]baselineResult)}
+	scanner := bufio.NewScanner(file)
+	// Results with many scripts and workers make for long lines
+	scanner.Buffer(make([]byte, 64*1024), 64*1024*1024)
+	for line := 1; scanner.Scan(); line++ {
+		var doc struct {
+			Type string `json:"type"`
+			baselineResult
+		}
+		if err := json.Unmarshal(scanner.Bytes(), &doc); err != nil {
+			return nil, errors.Wrapf(err, "failed to parse --baseline %s, line %d; it should be written by -o json", path, line)
+		}
+		if doc.Type == "result" {
+			b.results[doc.Scenario] = doc.baselineResult
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, errors.Wrapf(err, "failed to read --baseline")
+	}
+	if len(b.results) == 0 {
+		return nil, fmt.Errorf("--baseline %s has no results; it should be written by -o json", path)
+	}
+	return b, nil
+}
+
+// Compares the result to the baseline; tolerance is the fraction it may regress by, eg. 0.1 for 10%
+func (b *Baseline) check(result Result, tolerance float64) []SLACheck {
+	base, found := b.results[result.Scenario]
+	if !found {
+		return []SLACheck{{
+			Name:   "baseline",
+			Breach: fmt.Sprintf("--baseline %s has no result for the scenario %s, so it can't be compared", b.Path, result.Scenario),
+		}}
+	}
+
+	checks := make([]SLACheck, 0)
+	if base.Mode != "latency" {
+		check := SLACheck{Name: "baseline-tps"}
+		rate := result.TotalRate()
+		if rate < base.Rate*(1-tolerance) {
+			check.Breach = fmt.Sprintf("tps was %.3f, %.1f%% below %.3f in --baseline %s, more than the --baseline-tolerance of %g%%",
+				rate, 100*(base.Rate-rate)/base.Rate, base.Rate, b.Path, tolerance*100)
+		}
+		return append(checks, check)
+	}
+
+	for _, baseScript := range base.Scripts {
+		script, found := result.Scripts[baseScript.Name]
+		if !found || script.Succeeded == 0 || baseScript.Latencies == nil {
+			continue
+		}
+		for _, q := range []struct {
+			name     string
+			quantile float64
+			base     float64
+		}{
+			{"p50", 50, baseScript.Latencies.P50},
+			{"p95", 95, baseScript.Latencies.P95},
+			{"p99", 99, baseScript.Latencies.P99},
+		} {
+			check := SLACheck{Name: fmt.Sprintf("baseline-%s-%s", baseScript.Name, q.name)}
+			latency := float64(script.Latencies.ValueAtQuantile(q.quantile)) / 1000.0
+			if q.base > 0 && latency > q.base*(1+tolerance) {
+				check.Breach = fmt.Sprintf("%s %s was %.3fms, %.1f%% above %.3fms in --baseline %s, more than the --baseline-tolerance of %g%%",
+					baseScript.Name, q.name, latency, 100*(latency-q.base)/q.base, q.base, b.Path, tolerance*100)
+			}
+			checks = append(checks, check)
+		}
+	}
+	return checks
+}
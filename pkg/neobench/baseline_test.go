@@ -0,0 +1,57 @@
+package neobench
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestBaselineFlagsRegressionsBeyondTolerance(t *testing.T) {
+	dir, err := ioutil.TempDir("", "baseline")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	run := func(scenario string, latency, elapsed time.Duration) Result {
+		w := NewWorkerResult(0)
+		for i := 0; i < 10; i++ {
+			assert.NoError(t, w.record("a", latency, uowOutcome{succeeded: true}))
+		}
+		w.calculateRate(elapsed)
+		result := NewResult("", scenario)
+		assert.NoError(t, result.Add(w))
+		return result
+	}
+
+	path := filepath.Join(dir, "baseline.json")
+	file, err := os.Create(path)
+	assert.NoError(t, err)
+	o := &JsonOutput{ErrStream: ioutil.Discard, OutStream: file}
+	o.ReportWorkloadProgress(0.5, run("-c 1", time.Millisecond, time.Second))
+	o.ReportThroughput(run("-c 1", time.Millisecond, time.Second))
+	o.ReportLatency(run("-c 1 -l -r 10", time.Millisecond, time.Second))
+	assert.NoError(t, file.Close())
+
+	baseline, err := LoadBaseline(path)
+	assert.NoError(t, err)
+	sla := SLA{Baseline: baseline, BaselineTolerance: 0.1}
+
+	assert.Equal(t, []SLACheck{{Name: "baseline-tps"}}, sla.Check(run("-c 1", 5*time.Millisecond, 1050*time.Millisecond)))
+	assert.Equal(t, []string{
+		"tps was 8.000, 20.0% below 10.000 in --baseline " + path + ", more than the --baseline-tolerance of 10%",
+	}, sla.Breaches(run("-c 1", time.Millisecond, 1250*time.Millisecond)))
+
+	// Latency runs are judged on latency, not on their rate, which is set by -r
+	assert.Empty(t, sla.Breaches(run("-c 1 -l -r 10", 1050*time.Microsecond, 2*time.Second)))
+	breaches := sla.Breaches(run("-c 1 -l -r 10", 1200*time.Microsecond, time.Second))
+	if assert.Len(t, breaches, 3) {
+		assert.Equal(t, "a p50 was 1.200ms, 20.0% above 1.000ms in --baseline "+path+", more than the --baseline-tolerance of 10%", breaches[0])
+	}
+
+	assert.Equal(t, []string{
+		"--baseline " + path + " has no result for the scenario -c 2, so it can't be compared",
+	}, sla.Breaches(run("-c 2", time.Millisecond, time.Second)))
+}
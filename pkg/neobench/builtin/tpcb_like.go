@@ -8,9 +8,9 @@ import (
 )
 
 const TPCBLike = `
-:set aid random(1, 100000 * $scale)
-:set bid random(1, 1 * $scale)
-:set tid random(1, 10 * $scale)
+:set aid random(1, greatest(1, round(100000 * $scale)))
+:set bid random(1, greatest(1, round(1 * $scale)))
+:set tid random(1, greatest(1, round(10 * $scale)))
 :set delta random(-5000, 5000)
 
 MATCH (account:Account {aid:$aid}) 
@@ -23,14 +23,24 @@ CREATE (:History { tid: $tid, bid: $bid, aid: $aid, delta: $delta, mtime: timest
 `
 
 const MatchOnly = `
-:set aid random(1, 100000 * $scale)
+:set aid random(1, greatest(1, round(100000 * $scale)))
 MATCH (account:Account {aid:$aid}) RETURN account.balance;
 `
 
-func InitTPCBLike(scale int64, dbName string, driver neo4j.Driver, out neobench.Output, version string) error {
-	numBranches := 1 * scale
-	numTellers := 10 * scale
-	numAccounts := 100000 * scale
+// Number of entities at the given scale, rounded to the nearest whole number, but never less than one; the
+// workload scripts do the same with the scale variable, so they pick ids that exist
+func scaledCount(base int64, scale float64) int64 {
+	n := int64(math.Round(float64(base) * scale))
+	if n < 1 {
+		return 1
+	}
+	return n
+}
+
+func InitTPCBLike(scale float64, dbName string, driver neo4j.Driver, out neobench.Output, version string) error {
+	numBranches := scaledCount(1, scale)
+	numTellers := scaledCount(10, scale)
+	numAccounts := scaledCount(100000, scale)
 	session := driver.NewSession(neo4j.SessionConfig{
 		AccessMode:   neo4j.AccessModeWrite,
 		DatabaseName: dbName,
@@ -26,20 +26,47 @@ func TestParseTpcBLike(t *testing.T) {
 			Params: map[string]interface{}{"aid": int64(90704), "delta": int64(-3348)},
 		},
 		{
-			Query:  "MATCH (account:Account {aid:$aid}) RETURN account.balance",
-			Params: map[string]interface{}{"aid": int64(90704)},
+			Query:   "MATCH (account:Account {aid:$aid}) RETURN account.balance",
+			Params:  map[string]interface{}{"aid": int64(90704)},
+			Command: 1,
 		},
 		{
-			Query:  "MATCH (teller:Tellers {tid: $tid}) SET teller.balance = teller.balance + $delta",
-			Params: map[string]interface{}{"delta": int64(-3348), "tid": int64(1)},
+			Query:   "MATCH (teller:Tellers {tid: $tid}) SET teller.balance = teller.balance + $delta",
+			Params:  map[string]interface{}{"delta": int64(-3348), "tid": int64(1)},
+			Command: 2,
 		},
 		{
-			Query:  "MATCH (branch:Branch {bid: $bid}) SET branch.balance = branch.balance + $delta",
-			Params: map[string]interface{}{"bid": int64(1), "delta": int64(-3348)},
+			Query:   "MATCH (branch:Branch {bid: $bid}) SET branch.balance = branch.balance + $delta",
+			Params:  map[string]interface{}{"bid": int64(1), "delta": int64(-3348)},
+			Command: 3,
 		},
 		{
-			Query:  "CREATE (:History { tid: $tid, bid: $bid, aid: $aid, delta: $delta, mtime: timestamp() })",
-			Params: map[string]interface{}{"aid": int64(90704), "bid": int64(1), "delta": int64(-3348), "tid": int64(1)},
+			Query:   "CREATE (:History { tid: $tid, bid: $bid, aid: $aid, delta: $delta, mtime: timestamp() })",
+			Params:  map[string]interface{}{"aid": int64(90704), "bid": int64(1), "delta": int64(-3348), "tid": int64(1)},
+			Command: 4,
 		},
 	}, uow.Statements)
 }
+
+func TestTpcBLikeFractionalScale(t *testing.T) {
+	assert.Equal(t, int64(1), scaledCount(1, 0.01))
+	assert.Equal(t, int64(5), scaledCount(10, 0.45))
+	assert.Equal(t, int64(50000), scaledCount(100000, 0.5))
+	assert.Equal(t, int64(150000), scaledCount(100000, 1.5))
+
+	// The script picks ids within what init created at the same scale
+	script, err := neobench.Parse("builtin:tpcb-like", TPCBLike, 1)
+	assert.NoError(t, err)
+	r := rand.New(rand.NewSource(1337))
+	for i := 0; i < 100; i++ {
+		uow, err := script.Eval(neobench.ScriptContext{
+			Vars: map[string]interface{}{"scale": 0.01},
+			Rand: r,
+		})
+		assert.NoError(t, err)
+		params := uow.Statements[len(uow.Statements)-1].Params
+		assert.LessOrEqual(t, params["aid"].(int64), scaledCount(100000, 0.01))
+		assert.LessOrEqual(t, params["tid"].(int64), scaledCount(10, 0.01))
+		assert.Equal(t, int64(1), params["bid"])
+	}
+}
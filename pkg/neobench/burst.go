@@ -0,0 +1,114 @@
+package neobench
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Holds workers between transactions while it's closed, shared by all workers of a run; see --burst and
+// PauseOnSignals
+type Gate struct {
+	mut sync.Mutex
+	// Closed while the gate is open, so waiting on it returns right away; a fresh channel while it's closed,
+	// which Open closes to let the waiting workers through
+	open   chan struct{}
+	closed bool
+}
+
+func NewGate() *Gate {
+	open := make(chan struct{})
+	close(open)
+	return &Gate{open: open}
+}
+
+func (g *Gate) Close() {
+	g.mut.Lock()
+	defer g.mut.Unlock()
+	if !g.closed {
+		g.open = make(chan struct{})
+		g.closed = true
+	}
+}
+
+func (g *Gate) Open() {
+	g.mut.Lock()
+	defer g.mut.Unlock()
+	if g.closed {
+		close(g.open)
+		g.closed = false
+	}
+}
+
+func (g *Gate) IsClosed() bool {
+	g.mut.Lock()
+	defer g.mut.Unlock()
+	return g.closed
+}
+
+// Blocks while the gate is closed; false if stopCh said to stop first
+func (g *Gate) Wait(stopCh <-chan struct{}) bool {
+	g.mut.Lock()
+	open := g.open
+	g.mut.Unlock()
+	select {
+	case <-open:
+		return true
+	case <-stopCh:
+		return false
+	}
+}
+
+// Alternates between load and idle, see --burst, eg. "5s-on/10s-off" runs for five seconds, then idles for ten
+type Burst struct {
+	On, Off time.Duration
+}
+
+// Parses a --burst value, <duration>-on/<duration>-off
+func ParseBurst(raw string) (Burst, error) {
+	form := fmt.Errorf("--burst must be on the form <duration>-on/<duration>-off, like 5s-on/10s-off, got '%s'", raw)
+	parts := strings.Split(strings.TrimSpace(raw), "/")
+	if len(parts) != 2 || !strings.HasSuffix(parts[0], "-on") || !strings.HasSuffix(parts[1], "-off") {
+		return Burst{}, form
+	}
+	on, err := time.ParseDuration(strings.TrimSuffix(parts[0], "-on"))
+	if err != nil {
+		return Burst{}, form
+	}
+	off, err := time.ParseDuration(strings.TrimSuffix(parts[1], "-off"))
+	if err != nil {
+		return Burst{}, form
+	}
+	if on <= 0 || off <= 0 {
+		return Burst{}, fmt.Errorf("--burst needs both durations above zero, got '%s'", raw)
+	}
+	return Burst{On: on, Off: off}, nil
+}
+
+// Opens and closes the gate on the burst's schedule, starting with the load, until stopCh says to stop
+func (b Burst) Run(gate *Gate, stopCh <-chan struct{}) {
+	defer gate.Open()
+	for {
+		if !waitOrStop(b.On, stopCh) {
+			return
+		}
+		gate.Close()
+		if !waitOrStop(b.Off, stopCh) {
+			return
+		}
+		gate.Open()
+	}
+}
+
+// Waits for d; false if stopCh said to stop first
+func waitOrStop(d time.Duration, stopCh <-chan struct{}) bool {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-stopCh:
+		return false
+	case <-timer.C:
+		return true
+	}
+}
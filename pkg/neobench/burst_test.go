@@ -0,0 +1,87 @@
+package neobench
+
+import (
+	"math/rand"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseBurst(t *testing.T) {
+	b, err := ParseBurst("5s-on/1m-off")
+	assert.NoError(t, err)
+	assert.Equal(t, Burst{On: 5 * time.Second, Off: time.Minute}, b)
+
+	_, err = ParseBurst("5s/10s")
+	assert.EqualError(t, err, "--burst must be on the form <duration>-on/<duration>-off, like 5s-on/10s-off, got '5s/10s'")
+	_, err = ParseBurst("10s-off/5s-on")
+	assert.EqualError(t, err, "--burst must be on the form <duration>-on/<duration>-off, like 5s-on/10s-off, got '10s-off/5s-on'")
+	_, err = ParseBurst("0s-on/5s-off")
+	assert.EqualError(t, err, "--burst needs both durations above zero, got '0s-on/5s-off'")
+}
+
+func TestGateHoldsWaitersWhileClosed(t *testing.T) {
+	gate := NewGate()
+	stopCh := make(chan struct{})
+	assert.True(t, gate.Wait(stopCh))
+
+	gate.Close()
+	assert.True(t, gate.IsClosed())
+	passed := make(chan bool)
+	go func() {
+		passed <- gate.Wait(stopCh)
+	}()
+	select {
+	case <-passed:
+		t.Fatal("got through a closed gate")
+	case <-time.After(20 * time.Millisecond):
+	}
+	gate.Open()
+	assert.True(t, <-passed)
+	assert.False(t, gate.IsClosed())
+
+	gate.Close()
+	close(stopCh)
+	assert.False(t, gate.Wait(stopCh))
+}
+
+func TestBurstAlternatesTheGate(t *testing.T) {
+	gate := NewGate()
+	stopCh := make(chan struct{})
+	done := make(chan struct{})
+	go func() {
+		Burst{On: 20 * time.Millisecond, Off: time.Second}.Run(gate, stopCh)
+		close(done)
+	}()
+
+	assert.False(t, gate.IsClosed())
+	time.Sleep(100 * time.Millisecond)
+	assert.True(t, gate.IsClosed())
+	close(stopCh)
+	<-done
+	// Stopping opens the gate, so nobody is left waiting on it
+	assert.False(t, gate.IsClosed())
+}
+
+func TestWorkerStopsWhileHeldAtTheGate(t *testing.T) {
+	r := rand.New(rand.NewSource(1337))
+	stopCh := make(chan struct{})
+	clock := &fakeSpaceTimeContinuum{}
+	clock.currentTime = time.Date(2020, 1, 1, 1, 1, 1, 1, time.UTC)
+	w := Worker{
+		workerId: 0,
+		driver:   &fakeDriver{clock: clock, r: r, minLatency: time.Millisecond, maxLatency: time.Millisecond},
+		now:      clock.now,
+		sleep:    clock.sleepUnlessStopped,
+	}
+	gate := NewGate()
+	gate.Close()
+	w.UseGate(gate)
+	close(stopCh)
+
+	result := w.RunBenchmark(newTestWorkload(r), "", 0, 100, stopCh, nil, NewResultRecorder(0, time.Time{}))
+
+	assert.NoError(t, result.Error)
+	assert.Empty(t, result.Scripts)
+}
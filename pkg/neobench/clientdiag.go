@@ -0,0 +1,142 @@
+package neobench
+
+import (
+	"fmt"
+	"runtime"
+	"sync"
+	"time"
+)
+
+// What neobench itself was doing during a run, see --diagnose-client. If the client is starved for CPU or
+// pausing for GC, some of the latency it reports is its own rather than the server's.
+type ClientDiagnostics struct {
+	Elapsed       time.Duration
+	GOMAXPROCS    int
+	MaxGoroutines int
+	NumGC         uint32
+	GCPauseTotal  time.Duration
+	GCPauseMax    time.Duration
+	// Fraction of available CPU the client has spent on GC since it started
+	GCCPUFraction float64
+	// How late the most delayed wakeup of a periodic timer was; when the Go scheduler has more runnable
+	// goroutines than it has threads to run them on, workers see the same delays between the server
+	// responding and them noticing
+	SchedulingLagMax time.Duration
+}
+
+// Thresholds above which the client is likely to be distorting measurements
+const (
+	diagMaxGCPauseFraction = 0.01
+	diagMaxGCPause         = 10 * time.Millisecond
+	diagMaxGCCPUFraction   = 0.05
+	diagMaxSchedulingLag   = 10 * time.Millisecond
+)
+
+// Human-readable descriptions of ways the client may have affected the measurements; empty if none
+func (d ClientDiagnostics) Warnings() []string {
+	warnings := make([]string, 0)
+	if d.Elapsed > 0 && float64(d.GCPauseTotal)/float64(d.Elapsed) > diagMaxGCPauseFraction {
+		warnings = append(warnings, fmt.Sprintf("the client was paused for GC for %s, %.1f%% of the run; "+
+			"latencies include these pauses", d.GCPauseTotal, 100*float64(d.GCPauseTotal)/float64(d.Elapsed)))
+	}
+	if d.GCPauseMax > diagMaxGCPause {
+		warnings = append(warnings, fmt.Sprintf("the longest client GC pause was %s; latency outliers around "+
+			"that may be the client, not the server", d.GCPauseMax))
+	}
+	if d.GCCPUFraction > diagMaxGCCPUFraction {
+		warnings = append(warnings, fmt.Sprintf("the client spent %.1f%% of its CPU on GC", d.GCCPUFraction*100))
+	}
+	if d.SchedulingLagMax > diagMaxSchedulingLag {
+		warnings = append(warnings, fmt.Sprintf("client goroutines waited up to %s to be scheduled, with %d goroutines "+
+			"on GOMAXPROCS=%d; latencies may be inflated by the client, try fewer clients or more CPUs",
+			d.SchedulingLagMax, d.MaxGoroutines, d.GOMAXPROCS))
+	}
+	return warnings
+}
+
+// Samples the Go runtime while a benchmark runs, see ClientDiagnostics
+type ClientDiagnosticsCollector struct {
+	// How often to sample goroutines; reading memory stats briefly stops the world, so we only do that
+	// at the start and end
+	interval time.Duration
+	// How often to probe for scheduling delays
+	probeInterval time.Duration
+
+	mut         sync.Mutex
+	diagnostics ClientDiagnostics
+}
+
+func NewClientDiagnosticsCollector(interval time.Duration) *ClientDiagnosticsCollector {
+	return &ClientDiagnosticsCollector{
+		interval:      interval,
+		probeInterval: 10 * time.Millisecond,
+	}
+}
+
+// Samples until stopCh is closed
+func (c *ClientDiagnosticsCollector) Run(stopCh <-chan struct{}) {
+	start := time.Now()
+	var before runtime.MemStats
+	runtime.ReadMemStats(&before)
+
+	maxGoroutines := runtime.NumGoroutine()
+	maxLag := time.Duration(0)
+	nextSample := start.Add(c.interval)
+	ticker := time.NewTicker(c.probeInterval)
+	defer ticker.Stop()
+
+loop:
+	for {
+		select {
+		case <-stopCh:
+			break loop
+		case now := <-ticker.C:
+			// now is when the tick was sent, so this is how long it took for this goroutine to get to run
+			if lag := time.Now().Sub(now); lag > maxLag {
+				maxLag = lag
+			}
+			if now.After(nextSample) {
+				if n := runtime.NumGoroutine(); n > maxGoroutines {
+					maxGoroutines = n
+				}
+				nextSample = nextSample.Add(c.interval)
+			}
+		}
+	}
+
+	var after runtime.MemStats
+	runtime.ReadMemStats(&after)
+
+	c.mut.Lock()
+	defer c.mut.Unlock()
+	c.diagnostics = ClientDiagnostics{
+		Elapsed:          time.Now().Sub(start),
+		GOMAXPROCS:       runtime.GOMAXPROCS(0),
+		MaxGoroutines:    maxGoroutines,
+		NumGC:            after.NumGC - before.NumGC,
+		GCPauseTotal:     time.Duration(after.PauseTotalNs - before.PauseTotalNs),
+		GCPauseMax:       maxGCPause(&after, before.NumGC),
+		GCCPUFraction:    after.GCCPUFraction,
+		SchedulingLagMax: maxLag,
+	}
+}
+
+func (c *ClientDiagnosticsCollector) Diagnostics() ClientDiagnostics {
+	c.mut.Lock()
+	defer c.mut.Unlock()
+	return c.diagnostics
+}
+
+// Longest pause of GC cycles after sinceGC; the runtime only keeps the last 256 pauses, so for runs with more
+// GCs than that this is the longest of the most recent ones
+func maxGCPause(stats *runtime.MemStats, sinceGC uint32) time.Duration {
+	max := time.Duration(0)
+	for gc := stats.NumGC; gc > sinceGC && stats.NumGC-gc < uint32(len(stats.PauseNs)); gc-- {
+		// PauseNs is a circular buffer, with the pause of GC number n at (n+255)%256
+		pause := time.Duration(stats.PauseNs[(gc+uint32(len(stats.PauseNs))-1)%uint32(len(stats.PauseNs))])
+		if pause > max {
+			max = pause
+		}
+	}
+	return max
+}
@@ -0,0 +1,61 @@
+package neobench
+
+import (
+	"github.com/stretchr/testify/assert"
+	"runtime"
+	"testing"
+	"time"
+)
+
+func TestClientDiagnosticsWarnings(t *testing.T) {
+	healthy := ClientDiagnostics{
+		Elapsed:          time.Minute,
+		GOMAXPROCS:       8,
+		MaxGoroutines:    20,
+		NumGC:            10,
+		GCPauseTotal:     10 * time.Millisecond,
+		GCPauseMax:       time.Millisecond,
+		GCCPUFraction:    0.001,
+		SchedulingLagMax: time.Millisecond,
+	}
+	assert.Empty(t, healthy.Warnings())
+
+	starved := healthy
+	starved.GOMAXPROCS = 1
+	starved.MaxGoroutines = 500
+	starved.SchedulingLagMax = 50 * time.Millisecond
+	assert.Equal(t, []string{"client goroutines waited up to 50ms to be scheduled, with 500 goroutines on GOMAXPROCS=1; " +
+		"latencies may be inflated by the client, try fewer clients or more CPUs"}, starved.Warnings())
+
+	collecting := healthy
+	collecting.GCPauseTotal = 6 * time.Second
+	collecting.GCPauseMax = 20 * time.Millisecond
+	assert.Len(t, collecting.Warnings(), 2)
+}
+
+func TestMaxGCPause(t *testing.T) {
+	stats := runtime.MemStats{NumGC: 258}
+	// GC number n is at (n+255)%256
+	stats.PauseNs[(257+255)%256] = uint64(5 * time.Millisecond)
+	stats.PauseNs[(258+255)%256] = uint64(2 * time.Millisecond)
+	stats.PauseNs[(256+255)%256] = uint64(9 * time.Millisecond)
+
+	assert.Equal(t, 5*time.Millisecond, maxGCPause(&stats, 256))
+	assert.Equal(t, 9*time.Millisecond, maxGCPause(&stats, 255))
+	assert.Equal(t, time.Duration(0), maxGCPause(&stats, 258))
+}
+
+func TestClientDiagnosticsCollector(t *testing.T) {
+	c := NewClientDiagnosticsCollector(10 * time.Millisecond)
+	stopCh := make(chan struct{})
+	go func() {
+		time.Sleep(50 * time.Millisecond)
+		close(stopCh)
+	}()
+	c.Run(stopCh)
+
+	d := c.Diagnostics()
+	assert.True(t, d.Elapsed >= 50*time.Millisecond)
+	assert.Equal(t, runtime.GOMAXPROCS(0), d.GOMAXPROCS)
+	assert.True(t, d.MaxGoroutines > 0)
+}
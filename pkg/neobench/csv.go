@@ -1,6 +1,7 @@
 package neobench
 
 import (
+	"bufio"
 	"encoding/csv"
 	"fmt"
 	"github.com/pkg/errors"
@@ -37,7 +38,22 @@ func (l *CsvLoader) getCached(name string) ([]interface{}, bool) {
 }
 
 func (l *CsvLoader) Load(name string) ([]interface{}, error) {
-	if cached, found := l.getCached(name); found {
+	return l.load(name, name, readCsv)
+}
+
+// The values of a file with one on each line, like exported ids, see sample(..); numbers are read as numbers, as
+// in Load, and empty lines are skipped
+func (l *CsvLoader) LoadValues(name string) ([]interface{}, error) {
+	values, err := l.load("values:"+name, name, readValues)
+	if err == nil && len(values) == 0 {
+		return nil, fmt.Errorf("'%s' has no values in it, it needs one on each line", name)
+	}
+	return values, err
+}
+
+// Loads the file name into the cache slot key, unless it's already there
+func (l *CsvLoader) load(key, name string, read func(f io.Reader, name string) ([]interface{}, error)) ([]interface{}, error) {
+	if cached, found := l.getCached(key); found {
 		return cached, nil
 	}
 
@@ -47,7 +63,7 @@ func (l *CsvLoader) Load(name string) ([]interface{}, error) {
 	defer l.m.Unlock()
 
 	// Someone else may have had time, while we dropped the lock, to do the load
-	cached, found := l.cache[name]
+	cached, found := l.cache[key]
 	if found {
 		return cached, nil
 	}
@@ -59,6 +75,16 @@ func (l *CsvLoader) Load(name string) ([]interface{}, error) {
 	}
 	defer f.Close()
 
+	out, err := read(f, name)
+	if err != nil {
+		return nil, err
+	}
+	l.cache[key] = out
+
+	return out, nil
+}
+
+func readCsv(f io.Reader, name string) ([]interface{}, error) {
 	csvFile := csv.NewReader(f)
 	csvFile.ReuseRecord = true
 	csvFile.TrimLeadingSpace = true
@@ -79,9 +105,20 @@ func (l *CsvLoader) Load(name string) ([]interface{}, error) {
 		}
 		out = append(out, row)
 	}
+	return out, nil
+}
 
-	l.cache[name] = out
-
+func readValues(f io.Reader, name string) ([]interface{}, error) {
+	out := make([]interface{}, 0)
+	lines := bufio.NewScanner(f)
+	for lines.Scan() {
+		if line := strings.TrimSpace(lines.Text()); line != "" {
+			out = append(out, csvParseCell(line))
+		}
+	}
+	if err := lines.Err(); err != nil {
+		return nil, errors.Wrapf(err, "error while reading '%s'", name)
+	}
 	return out, nil
 }
 
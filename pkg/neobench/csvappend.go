@@ -0,0 +1,160 @@
+package neobench
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// Appends a row per run to a CSV file, see -o csv-append, so runs from different days pile up in one file to
+// chart or query later. Unlike -o csv, which has a row per script, the row has the totals across all scripts,
+// along with when the run finished, its scenario, and the neobench and Neo4j versions and seed it ran with.
+// The header is written if the file is new or empty; if the file has a different header, eg. from other
+// --percentiles, we refuse to append to it rather than mix up columns.
+type CsvAppendOutput struct {
+	ErrStream io.Writer
+	Path      string
+	// See --percentiles
+	Percentiles Percentiles
+	// See --tag; each tag gets a column, after the others
+	Tags Tags
+
+	now          func() time.Time
+	databaseName string
+}
+
+func NewCsvAppendOutput(path string, percentiles Percentiles, tags Tags, errStream io.Writer) (*CsvAppendOutput, error) {
+	o := &CsvAppendOutput{ErrStream: errStream, Path: path, Percentiles: percentiles, Tags: tags, now: time.Now}
+	header, err := readCsvHeader(path)
+	if err != nil {
+		return nil, err
+	}
+	if header != "" && header != o.header() {
+		return nil, fmt.Errorf("can't append to %s, its columns are %s rather than %s; append to a new file, or use the same --percentiles and --tag keys as the runs already in it",
+			path, header, o.header())
+	}
+	return o, nil
+}
+
+// The first line of the file, or empty if the file doesn't exist or is empty
+func readCsvHeader(path string) (string, error) {
+	file, err := os.Open(path)
+	if os.IsNotExist(err) {
+		return "", nil
+	}
+	if err != nil {
+		return "", errors.Wrapf(err, "failed to open %s to append to", path)
+	}
+	defer file.Close()
+	header, err := bufio.NewReader(file).ReadString('\n')
+	if err != nil && err != io.EOF {
+		return "", errors.Wrapf(err, "failed to read %s to append to", path)
+	}
+	return strings.TrimRight(header, "\r\n"), nil
+}
+
+func (o *CsvAppendOutput) percentiles() Percentiles {
+	return o.Percentiles.or(Percentiles{50, 95, 99}).below(100)
+}
+
+func (o *CsvAppendOutput) header() string {
+	columns := []string{"time", "scenario", "db", "mode", "neobench_version", "server_version", "seed", "succeeded", "failed", "retried", "rate", "mean"}
+	for _, q := range o.percentiles() {
+		columns = append(columns, percentileColumn(q))
+	}
+	columns = append(columns, "p100")
+	return strings.Join(append(columns, o.Tags.csvColumnNames()...), ",")
+}
+
+func (o *CsvAppendOutput) BenchmarkStart(databaseName, url, scenario string) {
+	o.databaseName = databaseName
+}
+
+func (o *CsvAppendOutput) ReportInitProgress(report ProgressReport) {
+}
+
+func (o *CsvAppendOutput) ReportWorkloadProgress(completeness float64, checkpoint Result) {
+}
+
+func (o *CsvAppendOutput) ReportThroughput(result Result) {
+	o.appendRow(result, "throughput")
+}
+
+func (o *CsvAppendOutput) ReportLatency(result Result) {
+	o.appendRow(result, "latency")
+}
+
+func (o *CsvAppendOutput) appendRow(result Result, mode string) {
+	latencies := result.CombinedLatencies()
+	neobenchVersion, serverVersion, seed := "", "", ""
+	if m := result.Metadata; m != nil {
+		neobenchVersion, serverVersion, seed = csvQuote(m.NeobenchVersion), csvQuote(m.ServerVersion), fmt.Sprintf("%d", m.Seed)
+	}
+	row := []string{
+		o.now().UTC().Format(time.RFC3339),
+		csvQuote(result.Scenario),
+		csvQuote(o.databaseName),
+		mode,
+		neobenchVersion,
+		serverVersion,
+		seed,
+		fmtFloat(result.TotalSucceeded()),
+		fmtFloat(result.TotalFailed()),
+		fmtFloat(result.TotalRetried()),
+		fmtFloat(result.TotalRate()),
+		fmtFloat(latencies.Mean() / 1000.0),
+	}
+	for _, q := range o.percentiles() {
+		row = append(row, fmtFloat(float64(latencies.ValueAtQuantile(q))/1000.0))
+	}
+	row = append(row, fmtFloat(float64(latencies.Max())/1000.0))
+	row = append(row, o.Tags.csvValues()...)
+
+	s := strings.Builder{}
+	// Checked again, since the file may have been created or emptied since we started
+	header, err := readCsvHeader(o.Path)
+	if err != nil {
+		o.warn(err)
+		return
+	}
+	if header == "" {
+		s.WriteString(o.header())
+		s.WriteString("\n")
+	}
+	s.WriteString(strings.Join(row, ","))
+	s.WriteString("\n")
+
+	file, err := os.OpenFile(o.Path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+	if err != nil {
+		o.warn(err)
+		return
+	}
+	defer file.Close()
+	if _, err := file.WriteString(s.String()); err != nil {
+		o.warn(err)
+	}
+}
+
+func (o *CsvAppendOutput) warn(err error) {
+	if _, err := fmt.Fprintf(o.ErrStream, "WARNING: failed to append results to %s: %s\n", o.Path, err); err != nil {
+		panic(err)
+	}
+}
+
+func (o *CsvAppendOutput) ReportSweep(sweep SweepResult) {
+}
+
+func (o *CsvAppendOutput) Errorf(format string, a ...interface{}) {
+}
+
+// Quotes the value as a CSV field, doubling any quotes in it, as scenarios can have quoted flag values
+func csvQuote(value string) string {
+	return "\"" + strings.Replace(value, "\"", "\"\"", -1) + "\""
+}
+
+var _ Output = &CsvAppendOutput{}
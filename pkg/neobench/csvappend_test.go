@@ -0,0 +1,52 @@
+package neobench
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCsvAppendAddsARowPerRun(t *testing.T) {
+	dir, err := ioutil.TempDir("", "neobench")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "runs.csv")
+
+	for i, scenario := range []string{"-c 1", `-c 2 -D "x=1"`} {
+		o, err := NewCsvAppendOutput(path, nil, nil, ioutil.Discard)
+		assert.NoError(t, err)
+		o.now = func() time.Time { return time.Date(2020, 1, 1+i, 2, 0, 0, 0, time.UTC) }
+		w := NewWorkerResult(0)
+		assert.NoError(t, w.record("a", time.Millisecond, uowOutcome{succeeded: true}))
+		w.calculateRate(time.Second)
+		result := NewResult("neo4j", scenario)
+		assert.NoError(t, result.Add(w))
+		result.Metadata = &RunMetadata{NeobenchVersion: "1.0.0", ServerVersion: "4.4.3", Seed: int64(1234 + i)}
+		o.BenchmarkStart("neo4j", "neo4j://localhost:7687", scenario)
+		o.ReportThroughput(result)
+	}
+
+	written, err := ioutil.ReadFile(path)
+	assert.NoError(t, err)
+	assert.Equal(t, "time,scenario,db,mode,neobench_version,server_version,seed,succeeded,failed,retried,rate,mean,p50,p95,p99,p100\n"+
+		"2020-01-01T02:00:00Z,\"-c 1\",\"neo4j\",throughput,\"1.0.0\",\"4.4.3\",1234,1.000,0.000,0.000,1.000,1.000,1.000,1.000,1.000,1.000\n"+
+		"2020-01-02T02:00:00Z,\"-c 2 -D \"\"x=1\"\"\",\"neo4j\",throughput,\"1.0.0\",\"4.4.3\",1235,1.000,0.000,0.000,1.000,1.000,1.000,1.000,1.000,1.000\n",
+		string(written))
+}
+
+func TestCsvAppendRefusesFilesWithOtherColumns(t *testing.T) {
+	dir, err := ioutil.TempDir("", "neobench")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "runs.csv")
+	assert.NoError(t, ioutil.WriteFile(path, []byte("time,scenario,db,mode,neobench_version,server_version,seed,succeeded,failed,retried,rate,mean,p50,p100\n"), 0644))
+
+	_, err = NewCsvAppendOutput(path, nil, nil, ioutil.Discard)
+	assert.Error(t, err)
+	_, err = NewCsvAppendOutput(path, Percentiles{50}, nil, ioutil.Discard)
+	assert.NoError(t, err)
+}
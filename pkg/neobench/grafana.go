@@ -0,0 +1,232 @@
+package neobench
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"sort"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// Writes the progress samples of a run to a file as a Grafana snapshot, see --grafana-snapshot. The file is
+// the body the Grafana snapshot API expects, with the data embedded in the panels, so it can be published
+// without Grafana having access to any datasource:
+//
+//	curl -XPOST -H 'Content-Type: application/json' -d @<file> http://grafana:3000/api/snapshots
+//
+// The file is rewritten with everything sampled so far each time a run completes.
+type GrafanaSnapshotOutput struct {
+	Path string
+	// See --tag; these become the dashboard's tags, as key=value
+	Tags Tags
+
+	scenario string
+	samples  []grafanaSample
+	now      func() time.Time
+}
+
+// One progress report
+type grafanaSample struct {
+	time    time.Time
+	scripts map[string]grafanaScriptSample
+	failed  int64
+}
+
+type grafanaScriptSample struct {
+	rate float64
+	// Percentiles in milliseconds; unset if nothing succeeded in the interval
+	p50, p95, p99 *float64
+}
+
+func NewGrafanaSnapshotOutput(path string) *GrafanaSnapshotOutput {
+	return &GrafanaSnapshotOutput{
+		Path: path,
+		now:  time.Now,
+	}
+}
+
+func (g *GrafanaSnapshotOutput) BenchmarkStart(databaseName, url, scenario string) {
+	g.scenario = scenario
+}
+
+func (g *GrafanaSnapshotOutput) ReportInitProgress(report ProgressReport) {
+}
+
+func (g *GrafanaSnapshotOutput) ReportWorkloadProgress(completeness float64, checkpoint Result) {
+	sample := grafanaSample{
+		time:    g.now(),
+		scripts: make(map[string]grafanaScriptSample, len(checkpoint.Scripts)),
+		failed:  checkpoint.TotalFailed(),
+	}
+	for name, script := range checkpoint.Scripts {
+		s := grafanaScriptSample{rate: script.Rate}
+		if script.Succeeded > 0 {
+			quantile := func(q float64) *float64 {
+				ms := float64(script.Latencies.ValueAtQuantile(q)) / 1000.0
+				return &ms
+			}
+			s.p50, s.p95, s.p99 = quantile(50), quantile(95), quantile(99)
+		}
+		sample.scripts[name] = s
+	}
+	g.samples = append(g.samples, sample)
+}
+
+func (g *GrafanaSnapshotOutput) ReportThroughput(result Result) {
+	g.write(false)
+}
+
+func (g *GrafanaSnapshotOutput) ReportLatency(result Result) {
+	g.write(true)
+}
+
+func (g *GrafanaSnapshotOutput) ReportSweep(sweep SweepResult) {
+}
+
+func (g *GrafanaSnapshotOutput) Errorf(format string, a ...interface{}) {
+}
+
+func (g *GrafanaSnapshotOutput) write(latencyMode bool) {
+	content, err := json.MarshalIndent(g.snapshot(latencyMode), "", "  ")
+	if err != nil {
+		panic(errors.Wrap(err, "failed to encode grafana snapshot"))
+	}
+	if err := ioutil.WriteFile(g.Path, content, 0644); err != nil {
+		panic(errors.Wrapf(err, "failed to write grafana snapshot to %s", g.Path))
+	}
+}
+
+// Latencies are only meaningful in latency mode, so in throughput mode the snapshot leaves them out
+func (g *GrafanaSnapshotOutput) snapshot(latencyMode bool) map[string]interface{} {
+	scriptNames := make([]string, 0)
+	seen := make(map[string]bool)
+	for _, sample := range g.samples {
+		for name := range sample.scripts {
+			if !seen[name] {
+				seen[name] = true
+				scriptNames = append(scriptNames, name)
+			}
+		}
+	}
+	sort.Strings(scriptNames)
+
+	throughput := make([]grafanaSeries, 0, len(scriptNames)+1)
+	total := grafanaSeries{Target: "total"}
+	for _, sample := range g.samples {
+		rate := 0.0
+		for _, s := range sample.scripts {
+			rate += s.rate
+		}
+		total.add(sample.time, &rate)
+	}
+	throughput = append(throughput, total)
+	for _, name := range scriptNames {
+		series := grafanaSeries{Target: name}
+		for _, sample := range g.samples {
+			rate := sample.scripts[name].rate
+			series.add(sample.time, &rate)
+		}
+		throughput = append(throughput, series)
+	}
+
+	failures := grafanaSeries{Target: "failed"}
+	for _, sample := range g.samples {
+		failed := float64(sample.failed)
+		failures.add(sample.time, &failed)
+	}
+
+	panels := []map[string]interface{}{
+		grafanaGraphPanel(1, 0, "Throughput", "tps", "short", throughput),
+	}
+	if latencyMode {
+		latencies := make([]grafanaSeries, 0, 3*len(scriptNames))
+		for _, name := range scriptNames {
+			for _, p := range []struct {
+				name  string
+				value func(s grafanaScriptSample) *float64
+			}{
+				{"p50", func(s grafanaScriptSample) *float64 { return s.p50 }},
+				{"p95", func(s grafanaScriptSample) *float64 { return s.p95 }},
+				{"p99", func(s grafanaScriptSample) *float64 { return s.p99 }},
+			} {
+				series := grafanaSeries{Target: fmt.Sprintf("%s %s", name, p.name)}
+				for _, sample := range g.samples {
+					series.add(sample.time, p.value(sample.scripts[name]))
+				}
+				latencies = append(latencies, series)
+			}
+		}
+		panels = append(panels, grafanaGraphPanel(2, 8, "Latency", "latency", "ms", latencies))
+	}
+	panels = append(panels, grafanaGraphPanel(3, 8*len(panels), "Failures", "failed transactions", "short", []grafanaSeries{failures}))
+
+	from, to := g.now(), g.now()
+	if len(g.samples) > 0 {
+		from, to = g.samples[0].time, g.samples[len(g.samples)-1].time
+	}
+	title := "neobench"
+	if g.scenario != "" {
+		title = fmt.Sprintf("neobench %s", g.scenario)
+	}
+	return map[string]interface{}{
+		"name":    title,
+		"expires": 0,
+		"dashboard": map[string]interface{}{
+			"title":         title,
+			"tags":          grafanaTags(g.Tags),
+			"editable":      false,
+			"schemaVersion": 16,
+			"time": map[string]interface{}{
+				"from": from.UTC().Format(time.RFC3339),
+				"to":   to.UTC().Format(time.RFC3339),
+			},
+			"panels": panels,
+		},
+	}
+}
+
+func grafanaTags(tags Tags) []string {
+	out := make([]string, 0, len(tags))
+	for _, tag := range tags {
+		out = append(out, tag.Key+"="+tag.Value)
+	}
+	return out
+}
+
+// A series in the format Grafana embeds in snapshot panels: datapoints are [value, unix millis], with a null
+// value for gaps
+type grafanaSeries struct {
+	Target     string           `json:"target"`
+	Datapoints [][2]interface{} `json:"datapoints"`
+}
+
+func (s *grafanaSeries) add(t time.Time, value *float64) {
+	var v interface{}
+	if value != nil {
+		v = *value
+	}
+	s.Datapoints = append(s.Datapoints, [2]interface{}{v, t.UnixNano() / int64(time.Millisecond)})
+}
+
+func grafanaGraphPanel(id, y int, title, label, unit string, series []grafanaSeries) map[string]interface{} {
+	return map[string]interface{}{
+		"id":            id,
+		"type":          "graph",
+		"title":         title,
+		"gridPos":       map[string]interface{}{"x": 0, "y": y, "w": 24, "h": 8},
+		"lines":         true,
+		"linewidth":     1,
+		"nullPointMode": "null",
+		"legend":        map[string]interface{}{"show": true, "values": true, "max": true, "avg": true},
+		"xaxis":         map[string]interface{}{"mode": "time", "show": true},
+		"yaxes": []map[string]interface{}{
+			{"format": unit, "label": label, "show": true, "min": 0},
+			{"format": "short", "show": false},
+		},
+		"snapshotData": series,
+	}
+}
+
+var _ Output = &GrafanaSnapshotOutput{}
@@ -0,0 +1,89 @@
+package neobench
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestGrafanaSnapshotEmbedsProgressSamples(t *testing.T) {
+	dir, err := ioutil.TempDir("", "grafana")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	clock := time.Date(2020, 1, 1, 1, 1, 1, 0, time.UTC)
+	g := NewGrafanaSnapshotOutput(filepath.Join(dir, "snapshot.json"))
+	g.now = func() time.Time { return clock }
+	g.BenchmarkStart("", "neo4j://localhost:7687", "-s 1")
+
+	for _, latency := range []time.Duration{time.Millisecond, 2 * time.Millisecond} {
+		w := NewWorkerResult(0)
+		assert.NoError(t, w.record("a", latency, uowOutcome{succeeded: true}))
+		assert.NoError(t, w.record("a", latency, uowOutcome{succeeded: false, failureGroup: "unknown", err: os.ErrClosed}))
+		w.calculateRate(time.Second)
+		checkpoint := NewResult("", "")
+		assert.NoError(t, checkpoint.Add(w))
+		g.ReportWorkloadProgress(0, checkpoint)
+		clock = clock.Add(10 * time.Second)
+	}
+	g.ReportLatency(NewResult("", ""))
+
+	content, err := ioutil.ReadFile(g.Path)
+	assert.NoError(t, err)
+	var snapshot struct {
+		Name      string
+		Dashboard struct {
+			Time   struct{ From, To string }
+			Panels []struct {
+				Title        string
+				SnapshotData []grafanaSeriesJson
+			}
+		}
+	}
+	assert.NoError(t, json.Unmarshal(content, &snapshot))
+
+	assert.Equal(t, "neobench -s 1", snapshot.Name)
+	assert.Equal(t, "2020-01-01T01:01:01Z", snapshot.Dashboard.Time.From)
+	assert.Equal(t, "2020-01-01T01:01:11Z", snapshot.Dashboard.Time.To)
+	if assert.Len(t, snapshot.Dashboard.Panels, 3) {
+		throughput, latency, failures := snapshot.Dashboard.Panels[0], snapshot.Dashboard.Panels[1], snapshot.Dashboard.Panels[2]
+		assert.Equal(t, "Throughput", throughput.Title)
+		assert.Equal(t, []grafanaSeriesJson{
+			{Target: "total", Datapoints: [][2]float64{{2, 1577840461000}, {2, 1577840471000}}},
+			{Target: "a", Datapoints: [][2]float64{{2, 1577840461000}, {2, 1577840471000}}},
+		}, throughput.SnapshotData)
+		assert.Equal(t, "Latency", latency.Title)
+		assert.Equal(t, grafanaSeriesJson{Target: "a p99", Datapoints: [][2]float64{{1, 1577840461000}, {2, 1577840471000}}},
+			latency.SnapshotData[2])
+		assert.Equal(t, "Failures", failures.Title)
+		assert.Equal(t, []grafanaSeriesJson{
+			{Target: "failed", Datapoints: [][2]float64{{1, 1577840461000}, {1, 1577840471000}}},
+		}, failures.SnapshotData)
+	}
+}
+
+func TestGrafanaSnapshotLeavesOutLatenciesInThroughputMode(t *testing.T) {
+	g := NewGrafanaSnapshotOutput("")
+	w := NewWorkerResult(0)
+	assert.NoError(t, w.record("a", time.Millisecond, uowOutcome{succeeded: true}))
+	checkpoint := NewResult("", "")
+	assert.NoError(t, checkpoint.Add(w))
+	g.ReportWorkloadProgress(0, checkpoint)
+
+	panels := g.snapshot(false)["dashboard"].(map[string]interface{})["panels"].([]map[string]interface{})
+	titles := make([]string, 0)
+	for _, panel := range panels {
+		titles = append(titles, panel["title"].(string))
+	}
+	assert.Equal(t, []string{"Throughput", "Failures"}, titles)
+}
+
+type grafanaSeriesJson struct {
+	Target     string
+	Datapoints [][2]float64
+}
@@ -0,0 +1,80 @@
+package neobench
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/neo4j/neo4j-go-driver/v4/neo4j"
+	"github.com/pkg/errors"
+)
+
+// Failures of :gset, and of the commands after it that use what it set, are grouped under this, see groupError
+const GsetFailureGroup = "gset"
+
+// What a :gset fails the transaction with when the query before it didn't return what it needs, or when the rest
+// of the script fails to evaluate with what it did return
+type gsetError struct {
+	err error
+}
+
+func (e *gsetError) Error() string {
+	return e.err.Error()
+}
+
+// Where the evaluation of a script stopped, after a query with :gset, since the commands after it can use what
+// the query returns; see UnitOfWork.resume
+type pausedEval struct {
+	commands []Command
+	// Index in commands of the command to go on from
+	next int
+	ctx  ScriptContext
+}
+
+// Sets the variables the :gset of the last statement sets, from the first record the statement returned, nil if
+// it returned none, and evaluates the rest of the script with them, up to the next :gset
+func (u *UnitOfWork) resume(first *neo4j.Record) error {
+	paused := u.paused
+	u.paused = nil
+	columns := u.Statements[len(u.Statements)-1].Gset
+	if first == nil {
+		return &gsetError{fmt.Errorf(":gset %s got no rows from the query before it", strings.Join(columns, " "))}
+	}
+	for _, column := range columns {
+		value, found := first.Get(column)
+		if !found {
+			return &gsetError{fmt.Errorf(":gset %s needs the query before it to return %s, it returned %s",
+				strings.Join(columns, " "), column, strings.Join(first.Keys, ", "))}
+		}
+		paused.ctx.Vars[column] = value
+	}
+	if err := u.evalFrom(paused.commands, paused.next, paused.ctx); err != nil {
+		return &gsetError{errors.Wrapf(err, "after :gset %s", strings.Join(columns, " "))}
+	}
+	return nil
+}
+
+// A copy of the unit as it is now, for the worker to go back to when the driver retries a transaction that
+// resumed the evaluation of the script, see restore
+func (u *UnitOfWork) snapshot() UnitOfWork {
+	saved := *u
+	if u.paused != nil {
+		paused := *u.paused
+		paused.ctx.Vars = make(map[string]interface{}, len(u.paused.ctx.Vars))
+		for name, value := range u.paused.ctx.Vars {
+			paused.ctx.Vars[name] = value
+		}
+		saved.paused = &paused
+	}
+	if u.RolledBack != nil {
+		saved.RolledBack = make(map[int]bool, len(u.RolledBack))
+		for transaction, rolledBack := range u.RolledBack {
+			saved.RolledBack[transaction] = rolledBack
+		}
+	}
+	return saved
+}
+
+// Sets the unit back to a snapshot of it; the snapshot stays as it is, so it can be restored again
+func (u *UnitOfWork) restore(saved UnitOfWork) {
+	*u = saved.snapshot()
+}
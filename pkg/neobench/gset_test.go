@@ -0,0 +1,106 @@
+package neobench
+
+import (
+	"math/rand"
+	"testing"
+
+	"github.com/neo4j/neo4j-go-driver/v4/neo4j"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestGsetStopsTheScriptUntilTheQueryHasRun(t *testing.T) {
+	script, err := Parse("gset", `MATCH (a:Account {aid: 1}) RETURN a.aid AS aid, a.balance AS balance;
+:gset aid, balance
+:set balance $balance - 10
+MATCH (a:Account {aid: $aid}) SET a.balance = $balance;`, 1)
+	assert.NoError(t, err)
+	assert.True(t, script.Gsets)
+
+	uow, err := script.Eval(ScriptContext{
+		Vars: map[string]interface{}{},
+		Rand: rand.New(rand.NewSource(1337)),
+	})
+	assert.NoError(t, err)
+	assert.Len(t, uow.Statements, 1)
+	assert.Equal(t, []string{"aid", "balance"}, uow.Statements[0].Gset)
+
+	err = uow.resume(&neo4j.Record{Keys: []string{"aid", "balance"}, Values: []interface{}{int64(1), int64(100)}})
+	assert.NoError(t, err)
+	assert.Len(t, uow.Statements, 2)
+	assert.Equal(t, Statement{
+		Query:   "MATCH (a:Account {aid: $aid}) SET a.balance = $balance",
+		Params:  map[string]interface{}{"aid": int64(1), "balance": int64(90)},
+		Command: 1,
+	}, uow.Statements[1])
+	assert.Len(t, uow.transactions(), 1)
+}
+
+func TestGsetFailsWithoutTheColumnsItSets(t *testing.T) {
+	script, err := Parse("gset", "MATCH (a:Account {aid: 1}) RETURN a.balance AS balance;\n:gset aid balance\nRETURN $aid;", 1)
+	assert.NoError(t, err)
+	for _, tc := range []struct {
+		record   *neo4j.Record
+		expected string
+	}{
+		{nil, ":gset aid balance got no rows from the query before it"},
+		{&neo4j.Record{Keys: []string{"balance"}, Values: []interface{}{int64(100)}},
+			":gset aid balance needs the query before it to return aid, it returned balance"},
+	} {
+		uow, err := script.Eval(ScriptContext{
+			Vars: map[string]interface{}{},
+			Rand: rand.New(rand.NewSource(1337)),
+		})
+		assert.NoError(t, err)
+
+		err = uow.resume(tc.record)
+		assert.EqualError(t, err, tc.expected)
+		assert.Equal(t, GsetFailureGroup, groupError(err))
+	}
+}
+
+func TestGsetInPreflightEvaluatesTheWholeScript(t *testing.T) {
+	script, err := Parse("gset", `MATCH (a:Account {aid: 1}) RETURN a.aid AS aid;
+:gset aid
+MATCH (a:Account {aid: $aid}) RETURN a;`, 1)
+	assert.NoError(t, err)
+	uow, err := script.Eval(ScriptContext{
+		PreflightMode: true,
+		Vars:          map[string]interface{}{},
+		Rand:          rand.New(rand.NewSource(1337)),
+	})
+	assert.NoError(t, err)
+	assert.Len(t, uow.Statements, 2)
+	assert.Equal(t, map[string]interface{}{"aid": nil}, uow.Statements[1].Params)
+	assert.False(t, uow.unchecked)
+	assert.Empty(t, script.UndefinedParams(map[string]interface{}{}))
+
+	// The null :gset sets in preflight mode doesn't add up, so the queries after can't be checked
+	script, err = Parse("gset", `MATCH (a:Account {aid: 1}) RETURN a.balance AS balance;
+:gset balance
+:set balance $balance - 10
+MATCH (a:Account {aid: 1}) SET a.balance = $balance;`, 1)
+	assert.NoError(t, err)
+	uow, err = script.Eval(ScriptContext{
+		PreflightMode: true,
+		Vars:          map[string]interface{}{},
+		Rand:          rand.New(rand.NewSource(1337)),
+	})
+	assert.NoError(t, err)
+	assert.Len(t, uow.Statements, 1)
+	assert.True(t, uow.unchecked)
+}
+
+func TestGsetErrors(t *testing.T) {
+	for script, expected := range map[string]string{
+		":gset aid":                                           ":gset needs a query right before it, whose first row it sets variables from (at gset:1:6)",
+		"RETURN 1 AS aid;\n:set a 1\n:gset aid":               ":gset needs a query right before it, whose first row it sets variables from (at gset:3:6)",
+		"RETURN 1 AS aid;\n:gset":                             ":gset needs the columns to set variables from, like :gset balance (at gset:2:6)",
+		":if true\nRETURN 1 AS aid;\n:gset aid\n:endif":       ":gset can't be inside an :if or a :for, only in the script around them (at gset:3:6)",
+		":for i in [1]\nRETURN 1 AS aid;\n:gset aid\n:endfor": ":gset can't be inside an :if or a :for, only in the script around them (at gset:3:6)",
+		":opt shuffle\nRETURN 1 AS aid;\n:gset aid":           ":gset can't be used with :opt shuffle, the queries after it need to run after it (at gset:3:6)",
+		"RETURN 1 AS aid;\n:gset aid\n:opt shuffle":           ":opt shuffle can't be used with :gset, the queries after it need to run after it (at gset:3:13)",
+	} {
+		_, err := Parse("gset", script, 1)
+		assert.EqualError(t, err, expected, script)
+	}
+}
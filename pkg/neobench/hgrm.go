@@ -0,0 +1,139 @@
+package neobench
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"math"
+
+	"github.com/codahale/hdrhistogram"
+	"github.com/pkg/errors"
+)
+
+// Latencies are recorded in microseconds and written in milliseconds
+const hgrmValueUnitRatio = 1000.0
+
+// Percentile lines written per halving of the distance to 100%, as HdrHistogram's own tools default to
+const hgrmTicksPerHalfDistance = 5
+
+// Writes the latency histograms of a run in the .hgrm percentile distribution format, see --hgrm, for plotting
+// with HdrHistogram's plotter or comparing runs: the latencies of all scripts and workers combined to
+// <prefix>.hgrm, and those of each worker to <prefix>-worker-<id>.hgrm. The files are rewritten each time a run
+// completes.
+type HgrmOutput struct {
+	Prefix string
+}
+
+func (h *HgrmOutput) BenchmarkStart(databaseName, url, scenario string) {
+}
+
+func (h *HgrmOutput) ReportInitProgress(report ProgressReport) {
+}
+
+func (h *HgrmOutput) ReportWorkloadProgress(completeness float64, checkpoint Result) {
+}
+
+func (h *HgrmOutput) ReportThroughput(result Result) {
+	h.write(result)
+}
+
+func (h *HgrmOutput) ReportLatency(result Result) {
+	h.write(result)
+}
+
+func (h *HgrmOutput) ReportSweep(sweep SweepResult) {
+}
+
+func (h *HgrmOutput) Errorf(format string, a ...interface{}) {
+}
+
+func (h *HgrmOutput) write(result Result) {
+	writeHgrmFile(fmt.Sprintf("%s.hgrm", h.Prefix), result.CombinedLatencies())
+	for _, worker := range result.Workers {
+		writeHgrmFile(fmt.Sprintf("%s-worker-%d.hgrm", h.Prefix, worker.WorkerId), worker.Latencies)
+	}
+}
+
+func writeHgrmFile(path string, histo *hdrhistogram.Histogram) {
+	s := bytes.Buffer{}
+	if err := writeHgrm(histo, &s); err != nil {
+		panic(err)
+	}
+	if err := ioutil.WriteFile(path, s.Bytes(), 0644); err != nil {
+		panic(errors.Wrapf(err, "failed to write histogram to %s", path))
+	}
+}
+
+// Writes histo as HdrHistogram's outputPercentileDistribution does, so the result reads the same to tools that
+// parse it. Percentile levels step closer together towards 100%, hgrmTicksPerHalfDistance of them each time the
+// distance to 100% halves, and each line has the highest value equivalent to the one at that level.
+func writeHgrm(histo *hdrhistogram.Histogram, w io.Writer) error {
+	precision := histo.SignificantFigures()
+	valueFormat := fmt.Sprintf("%%12.%df", precision)
+
+	if _, err := fmt.Fprintf(w, "%12s %14s %10s %14s\n\n", "Value", "Percentile", "TotalCount", "1/(1-Percentile)"); err != nil {
+		return err
+	}
+
+	total := histo.TotalCount()
+	if total > 0 {
+		level := 0.0
+		count := int64(0)
+		lastValue := int64(0)
+	iterating:
+		for _, bar := range histo.Distribution() {
+			if bar.Count == 0 {
+				continue
+			}
+			count += bar.Count
+			lastValue = bar.To
+			percentile := 100.0 * float64(count) / float64(total)
+			for level <= percentile {
+				_, err := fmt.Fprintf(w, valueFormat+" %2.12f %10d %14.2f\n", float64(bar.To)/hgrmValueUnitRatio,
+					level/100, count, 1/(1-level/100))
+				if err != nil {
+					return err
+				}
+				// Once everything is counted, the last line covers the rest
+				if count >= total {
+					break iterating
+				}
+				halfDistance := math.Trunc(math.Pow(2, math.Trunc(math.Log2(100.0/(100.0-level)))+1))
+				level += 100.0 / (hgrmTicksPerHalfDistance * halfDistance)
+			}
+		}
+		if _, err := fmt.Fprintf(w, valueFormat+" %2.12f %10d\n", float64(lastValue)/hgrmValueUnitRatio, 1.0, total); err != nil {
+			return err
+		}
+	}
+
+	bucketCount, subBucketCount := hgrmBuckets(histo)
+	_, err := fmt.Fprintf(w, "#[Mean    = "+valueFormat+", StdDeviation   = "+valueFormat+"]\n"+
+		"#[Max     = "+valueFormat+", Total count    = %12d]\n"+
+		"#[Buckets = %12d, SubBuckets     = %12d]\n",
+		histo.Mean()/hgrmValueUnitRatio, histo.StdDev()/hgrmValueUnitRatio,
+		float64(histo.Max())/hgrmValueUnitRatio, total,
+		bucketCount, subBucketCount)
+	return err
+}
+
+// The histogram library doesn't expose its bucket layout, so this works it out from the configuration the same
+// way the library does
+func hgrmBuckets(histo *hdrhistogram.Histogram) (bucketCount, subBucketCount int64) {
+	largestValueWithSingleUnitResolution := 2 * math.Pow10(int(histo.SignificantFigures()))
+	subBucketCount = int64(math.Pow(2, math.Ceil(math.Log2(largestValueWithSingleUnitResolution))))
+	unitMagnitude := int64(0)
+	if histo.LowestTrackableValue() > 1 {
+		unitMagnitude = int64(math.Floor(math.Log2(float64(histo.LowestTrackableValue()))))
+	}
+	smallestUntrackableValue := subBucketCount << uint(unitMagnitude)
+	bucketCount = 1
+	for smallestUntrackableValue < histo.HighestTrackableValue() {
+		smallestUntrackableValue <<= 1
+		bucketCount++
+	}
+	return bucketCount, subBucketCount
+}
+
+var _ Output = &HgrmOutput{}
@@ -0,0 +1,67 @@
+package neobench
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestWriteHgrm(t *testing.T) {
+	histo := newLatencyHistogram()
+	for i := int64(1); i <= 4; i++ {
+		assert.NoError(t, histo.RecordValue(i*1000))
+	}
+
+	s := bytes.Buffer{}
+	assert.NoError(t, writeHgrm(histo, &s))
+
+	assert.Equal(t, strings.Join([]string{
+		"       Value     Percentile TotalCount 1/(1-Percentile)",
+		"",
+		"       1.000 0.000000000000          1           1.00",
+		"       1.000 0.100000000000          1           1.11",
+		"       1.000 0.200000000000          1           1.25",
+		"       2.000 0.300000000000          2           1.43",
+		"       2.000 0.400000000000          2           1.67",
+		"       2.000 0.500000000000          2           2.00",
+		"       3.001 0.550000000000          3           2.22",
+		"       3.001 0.600000000000          3           2.50",
+		"       3.001 0.650000000000          3           2.86",
+		"       3.001 0.700000000000          3           3.33",
+		"       3.001 0.750000000000          3           4.00",
+		"       4.001 0.775000000000          4           4.44",
+		"       4.001 1.000000000000          4",
+		"#[Mean    =        2.501, StdDeviation   =        1.118]",
+		"#[Max     =        4.001, Total count    =            4]",
+		"#[Buckets =           22, SubBuckets     =         2048]",
+		"",
+	}, "\n"), s.String())
+}
+
+func TestHgrmOutputWritesCombinedAndPerWorkerHistograms(t *testing.T) {
+	dir, err := ioutil.TempDir("", "hgrm")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	result := NewResult("", "")
+	for id, latency := range []time.Duration{time.Millisecond, 2 * time.Millisecond} {
+		w := NewWorkerResult(int64(id))
+		assert.NoError(t, w.record("s", latency, uowOutcome{succeeded: true}))
+		assert.NoError(t, result.Add(w))
+	}
+	h := &HgrmOutput{Prefix: filepath.Join(dir, "run")}
+	h.ReportLatency(result)
+
+	for path, count := range map[string]string{"run.hgrm": "2", "run-worker-0.hgrm": "1", "run-worker-1.hgrm": "1"} {
+		content, err := ioutil.ReadFile(filepath.Join(dir, path))
+		if assert.NoError(t, err) {
+			assert.Regexp(t, `Total count    =\s+`+count+`\]`, string(content), path)
+		}
+	}
+}
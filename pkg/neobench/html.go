@@ -0,0 +1,446 @@
+package neobench
+
+import (
+	"bytes"
+	"fmt"
+	"html/template"
+	"io/ioutil"
+	"math"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// Writes a self-contained HTML report of a run to a file, see --html-report: the scenario, a summary table,
+// throughput over time and, in latency mode, the latency distribution of each script. Charts are inline SVG,
+// so the file can be opened or mailed around without anything else.
+//
+// The file is rewritten each time a run completes, with the throughput of every progress report so far and the
+// results of the run that just completed.
+type HtmlReportOutput struct {
+	Path string
+	// See --tag
+	Tags Tags
+
+	databaseName string
+	url          string
+	scenario     string
+	start        time.Time
+	samples      []htmlSample
+	now          func() time.Time
+}
+
+// One progress report
+type htmlSample struct {
+	time  time.Time
+	total float64
+	rates map[string]float64
+}
+
+// Quantiles along the x axis of the latency distribution chart, evenly spaced, so the tail gets as much room as
+// the median
+var htmlDistributionQuantiles = []float64{0, 50, 75, 90, 95, 99, 99.9, 99.99, 99.999, 100}
+
+var htmlColors = []string{"#1f77b4", "#ff7f0e", "#2ca02c", "#d62728", "#9467bd", "#8c564b", "#e377c2", "#7f7f7f"}
+
+// Chart dimensions, in pixels
+const (
+	htmlChartWidth  = 800
+	htmlChartHeight = 300
+	htmlChartLeft   = 70
+	htmlChartRight  = 20
+	htmlChartTop    = 20
+	htmlChartBottom = 50
+)
+
+func NewHtmlReportOutput(path string) *HtmlReportOutput {
+	return &HtmlReportOutput{
+		Path: path,
+		now:  time.Now,
+	}
+}
+
+func (h *HtmlReportOutput) BenchmarkStart(databaseName, url, scenario string) {
+	if databaseName == "" {
+		databaseName = "<default>"
+	}
+	h.databaseName = databaseName
+	// The report is meant to be passed around, so it leaves out any credentials in --address
+	h.url = redactURL(url)
+	h.scenario = scenario
+	h.start = h.now()
+}
+
+func (h *HtmlReportOutput) ReportInitProgress(report ProgressReport) {
+}
+
+func (h *HtmlReportOutput) ReportWorkloadProgress(completeness float64, checkpoint Result) {
+	sample := htmlSample{
+		time:  h.now(),
+		total: checkpoint.TotalRate(),
+		rates: make(map[string]float64, len(checkpoint.Scripts)),
+	}
+	for name, script := range checkpoint.Scripts {
+		sample.rates[name] = script.Rate
+	}
+	h.samples = append(h.samples, sample)
+}
+
+func (h *HtmlReportOutput) ReportThroughput(result Result) {
+	h.write(result, false)
+}
+
+func (h *HtmlReportOutput) ReportLatency(result Result) {
+	h.write(result, true)
+}
+
+func (h *HtmlReportOutput) ReportSweep(sweep SweepResult) {
+}
+
+func (h *HtmlReportOutput) Errorf(format string, a ...interface{}) {
+}
+
+func (h *HtmlReportOutput) write(result Result, latencyMode bool) {
+	content, err := h.render(result, latencyMode)
+	if err != nil {
+		panic(errors.Wrap(err, "failed to render html report"))
+	}
+	if err := ioutil.WriteFile(h.Path, content, 0644); err != nil {
+		panic(errors.Wrapf(err, "failed to write html report to %s", h.Path))
+	}
+}
+
+type htmlReport struct {
+	Title        string
+	DatabaseName string
+	URL          string
+	Scenario     string
+	Tags         string
+	Generated    string
+	Mode         string
+	Succeeded    int64
+	Failed       int64
+	Rate         string
+	LatencyMode  bool
+	Scripts      [][]string
+	Failures     []htmlFailureGroup
+	Charts       []htmlChart
+	// Set from Result.Metadata, if it has it
+	Run     string
+	Started string
+	Flags   []string
+}
+
+type htmlFailureGroup struct {
+	Group    string
+	Count    int64
+	LastSeen string
+	Sources  []string
+	Samples  []string
+}
+
+func (h *HtmlReportOutput) render(result Result, latencyMode bool) ([]byte, error) {
+	scenario := result.Scenario
+	if scenario == "" {
+		scenario = h.scenario
+	}
+	report := htmlReport{
+		Title:        "neobench report",
+		DatabaseName: h.databaseName,
+		URL:          h.url,
+		Scenario:     scenario,
+		Tags:         h.Tags.String(),
+		Generated:    h.now().UTC().Format(time.RFC3339),
+		Mode:         "throughput",
+		Succeeded:    result.TotalSucceeded(),
+		Failed:       result.TotalFailed(),
+		Rate:         fmt.Sprintf("%.3f", result.TotalRate()),
+		LatencyMode:  latencyMode,
+	}
+	if latencyMode {
+		report.Mode = "latency"
+	}
+	if m := result.Metadata; m != nil {
+		report.Run = m.summary()
+		report.Started = fmt.Sprintf("%s, for %s", m.Start.UTC().Format(time.RFC3339), m.End.Sub(m.Start).Round(time.Millisecond))
+		report.Flags = m.sortedFlags()
+	}
+
+	for _, script := range sortedScripts(result) {
+		row := []string{script.ScriptName, fmt.Sprintf("%d", script.Succeeded), fmt.Sprintf("%d", script.Failed), fmt.Sprintf("%.3f", script.Rate)}
+		if latencyMode {
+			for _, q := range []float64{50, 95, 99} {
+				row = append(row, formatQuantile(script, q))
+			}
+			if script.Succeeded > 0 {
+				row = append(row, fmt.Sprintf("%.3f", float64(script.Latencies.Max())/1000.0))
+			} else {
+				row = append(row, "-")
+			}
+		}
+		report.Scripts = append(report.Scripts, row)
+	}
+
+	for _, name := range sortedFailureGroups(result) {
+		group := result.FailedByErrorGroup[name]
+		failures := htmlFailureGroup{Group: name, Count: group.Count, Samples: group.Samples}
+		if !group.LastSeen.IsZero() {
+			failures.LastSeen = group.LastSeen.Format("15:04:05")
+		}
+		for _, source := range group.Sources {
+			failures.Sources = append(failures.Sources, fmt.Sprintf("%d %s", source.Count, describeFailureSource(source)))
+		}
+		if len(failures.Samples) == 0 && group.FirstFailure != nil {
+			failures.Samples = []string{group.FirstFailure.Error()}
+		}
+		report.Failures = append(report.Failures, failures)
+	}
+
+	report.Charts = append(report.Charts, h.throughputChart())
+	if latencyMode && result.TotalSucceeded() > 0 {
+		report.Charts = append(report.Charts, latencyDistributionChart(result))
+	}
+
+	out := bytes.Buffer{}
+	if err := htmlReportTemplate.Execute(&out, report); err != nil {
+		return nil, err
+	}
+	return out.Bytes(), nil
+}
+
+// Total tps and, if there is more than one script, tps by script, against seconds since the benchmark started
+func (h *HtmlReportOutput) throughputChart() htmlChart {
+	scriptNames := make([]string, 0)
+	seen := make(map[string]bool)
+	for _, sample := range h.samples {
+		for name := range sample.rates {
+			if !seen[name] {
+				seen[name] = true
+				scriptNames = append(scriptNames, name)
+			}
+		}
+	}
+	sort.Strings(scriptNames)
+
+	total := htmlSeriesData{name: "total"}
+	for _, sample := range h.samples {
+		total.add(sample.time.Sub(h.start).Seconds(), sample.total)
+	}
+	series := []htmlSeriesData{total}
+	if len(scriptNames) > 1 {
+		for _, name := range scriptNames {
+			s := htmlSeriesData{name: name}
+			for _, sample := range h.samples {
+				s.add(sample.time.Sub(h.start).Seconds(), sample.rates[name])
+			}
+			series = append(series, s)
+		}
+	}
+	return newHtmlChart("Throughput over time", "seconds", "tps", series, nil)
+}
+
+// Latency at each of htmlDistributionQuantiles, by script
+func latencyDistributionChart(result Result) htmlChart {
+	labels := make([]string, 0, len(htmlDistributionQuantiles))
+	for _, q := range htmlDistributionQuantiles {
+		switch q {
+		case 0:
+			labels = append(labels, "min")
+		case 100:
+			labels = append(labels, "max")
+		default:
+			labels = append(labels, fmt.Sprintf("p%g", q))
+		}
+	}
+	series := make([]htmlSeriesData, 0, len(result.Scripts))
+	for _, script := range sortedScripts(result) {
+		if script.Succeeded == 0 {
+			continue
+		}
+		s := htmlSeriesData{name: script.ScriptName}
+		for i, q := range htmlDistributionQuantiles {
+			// ValueAtQuantile(0) is the bottom of the lowest bucket, which is 0 rather than the lowest value
+			value := script.Latencies.ValueAtQuantile(q)
+			if q == 0 {
+				value = script.Latencies.Min()
+			}
+			s.add(float64(i), float64(value)/1000.0)
+		}
+		series = append(series, s)
+	}
+	return newHtmlChart("Latency distribution", "percentile", "latency (ms)", series, labels)
+}
+
+type htmlSeriesData struct {
+	name string
+	x, y []float64
+}
+
+func (s *htmlSeriesData) add(x, y float64) {
+	s.x = append(s.x, x)
+	s.y = append(s.y, y)
+}
+
+// A line chart laid out for the template, all positions in pixels
+type htmlChart struct {
+	Title  string
+	XLabel string
+	YLabel string
+	Width  int
+	Height int
+	// The plot area
+	Left, Right, Top, Bottom int
+	XTicks                   []htmlTick
+	YTicks                   []htmlTick
+	Series                   []htmlSeries
+}
+
+type htmlTick struct {
+	Pos   float64
+	Label string
+}
+
+type htmlSeries struct {
+	Name   string
+	Color  string
+	Points string
+}
+
+// Scales the series to fit the plot area, with the y axis from 0. If xLabels is set, they label x = 0, 1, 2..
+// in place of numeric ticks.
+func newHtmlChart(title, xLabel, yLabel string, data []htmlSeriesData, xLabels []string) htmlChart {
+	c := htmlChart{
+		Title:  title,
+		XLabel: xLabel,
+		YLabel: yLabel,
+		Width:  htmlChartWidth,
+		Height: htmlChartHeight,
+		Left:   htmlChartLeft,
+		Right:  htmlChartWidth - htmlChartRight,
+		Top:    htmlChartTop,
+		Bottom: htmlChartHeight - htmlChartBottom,
+	}
+
+	minX, maxX, maxY := math.Inf(1), math.Inf(-1), 0.0
+	for _, s := range data {
+		for i := range s.x {
+			minX = math.Min(minX, s.x[i])
+			maxX = math.Max(maxX, s.x[i])
+			maxY = math.Max(maxY, s.y[i])
+		}
+	}
+	if xLabels != nil {
+		minX, maxX = 0, float64(len(xLabels)-1)
+	}
+	if math.IsInf(minX, 0) {
+		minX, maxX = 0, 1
+	}
+	if maxX <= minX {
+		maxX = minX + 1
+	}
+	if maxY <= 0 {
+		maxY = 1
+	}
+	maxY *= 1.1
+
+	xPos := func(x float64) float64 {
+		return float64(c.Left) + (x-minX)/(maxX-minX)*float64(c.Right-c.Left)
+	}
+	yPos := func(y float64) float64 {
+		return float64(c.Bottom) - y/maxY*float64(c.Bottom-c.Top)
+	}
+
+	if xLabels != nil {
+		for i, label := range xLabels {
+			c.XTicks = append(c.XTicks, htmlTick{Pos: xPos(float64(i)), Label: label})
+		}
+	} else {
+		for i := 0; i <= 5; i++ {
+			x := minX + (maxX-minX)*float64(i)/5
+			c.XTicks = append(c.XTicks, htmlTick{Pos: xPos(x), Label: fmt.Sprintf("%.3g", x)})
+		}
+	}
+	for i := 0; i <= 5; i++ {
+		y := maxY * float64(i) / 5
+		c.YTicks = append(c.YTicks, htmlTick{Pos: yPos(y), Label: fmt.Sprintf("%.3g", y)})
+	}
+
+	for i, s := range data {
+		points := make([]string, 0, len(s.x))
+		for j := range s.x {
+			points = append(points, fmt.Sprintf("%.1f,%.1f", xPos(s.x[j]), yPos(s.y[j])))
+		}
+		c.Series = append(c.Series, htmlSeries{
+			Name:   s.name,
+			Color:  htmlColors[i%len(htmlColors)],
+			Points: strings.Join(points, " "),
+		})
+	}
+	return c
+}
+
+var htmlReportTemplate = template.Must(template.New("report").Funcs(template.FuncMap{
+	"legendY": func(i int) int { return htmlChartTop + 5 + i*16 },
+}).Parse(`<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<title>{{.Title}}: {{.Scenario}}</title>
+<style>
+body { font-family: sans-serif; margin: 2em; color: #222; }
+table { border-collapse: collapse; margin-bottom: 1.5em; }
+th, td { border: 1px solid #ccc; padding: 4px 10px; text-align: left; }
+th { background: #f3f3f3; }
+td.num { text-align: right; font-family: monospace; }
+code { background: #f3f3f3; padding: 2px 4px; }
+svg text { font-size: 12px; fill: #444; }
+</style>
+</head>
+<body>
+<h1>{{.Title}}</h1>
+<table>
+<tr><th>Database</th><td>{{.DatabaseName}}</td></tr>
+<tr><th>URL</th><td>{{.URL}}</td></tr>
+<tr><th>Scenario</th><td><code>{{.Scenario}}</code></td></tr>
+{{if .Tags}}<tr><th>Tags</th><td>{{.Tags}}</td></tr>
+{{end}}<tr><th>Mode</th><td>{{.Mode}}</td></tr>
+{{if .Run}}<tr><th>Run</th><td>{{.Run}}</td></tr>
+<tr><th>Started</th><td>{{.Started}}</td></tr>
+<tr><th>Flags</th><td><details><summary>{{len .Flags}} flags</summary>{{range .Flags}}<code>{{.}}</code><br>
+{{end}}</details></td></tr>
+{{end}}<tr><th>Generated</th><td>{{.Generated}}</td></tr>
+</table>
+
+<h2>Results</h2>
+<p>{{.Succeeded}} successful transactions, {{.Failed}} failed. (Total of {{.Rate}} per second)</p>
+<table>
+<tr><th>script</th><th>succeeded</th><th>failed</th><th>tps</th>{{if .LatencyMode}}<th>p50(ms)</th><th>p95(ms)</th><th>p99(ms)</th><th>max(ms)</th>{{end}}</tr>
+{{range .Scripts}}<tr>{{range $i, $cell := .}}{{if $i}}<td class="num">{{$cell}}</td>{{else}}<td>{{$cell}}</td>{{end}}{{end}}</tr>
+{{end}}</table>
+{{if .Failures}}
+<h2>Failures</h2>
+<table>
+<tr><th>group</th><th>count</th><th>last seen</th><th>where</th><th>examples</th></tr>
+{{range .Failures}}<tr><td>{{.Group}}</td><td class="num">{{.Count}}</td><td>{{.LastSeen}}</td><td>{{range .Sources}}<div>{{.}}</div>{{end}}</td><td>{{range .Samples}}<div>{{.}}</div>{{end}}</td></tr>
+{{end}}</table>
+{{end}}
+{{range .Charts}}
+<h2>{{.Title}}</h2>
+<svg xmlns="http://www.w3.org/2000/svg" width="{{.Width}}" height="{{.Height}}" viewBox="0 0 {{.Width}} {{.Height}}">
+<line x1="{{.Left}}" y1="{{.Bottom}}" x2="{{.Right}}" y2="{{.Bottom}}" stroke="#444"/>
+<line x1="{{.Left}}" y1="{{.Top}}" x2="{{.Left}}" y2="{{.Bottom}}" stroke="#444"/>
+{{$c := .}}{{range .YTicks}}<line x1="{{$c.Left}}" y1="{{.Pos}}" x2="{{$c.Right}}" y2="{{.Pos}}" stroke="#eee"/><text x="{{$c.Left}}" y="{{.Pos}}" dx="-6" dy="4" text-anchor="end">{{.Label}}</text>
+{{end}}{{range .XTicks}}<text x="{{.Pos}}" y="{{$c.Bottom}}" dy="16" text-anchor="middle">{{.Label}}</text>
+{{end}}<text x="{{$c.Right}}" y="{{$c.Height}}" dy="-6" text-anchor="end">{{.XLabel}}</text>
+<text x="14" y="{{$c.Top}}" transform="rotate(-90 14 {{$c.Top}})" text-anchor="end">{{.YLabel}}</text>
+{{range $i, $s := .Series}}<polyline fill="none" stroke="{{$s.Color}}" stroke-width="2" points="{{$s.Points}}"><title>{{$s.Name}}</title></polyline>
+<text x="{{$c.Right}}" y="{{legendY $i}}" text-anchor="end" style="fill: {{$s.Color}}">{{$s.Name}}</text>
+{{end}}</svg>
+{{end}}
+</body>
+</html>
+`))
+
+var _ Output = &HtmlReportOutput{}
@@ -0,0 +1,211 @@
+package neobench
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// Writes a measurement per script at each progress report, and one more at completion, in InfluxDB line protocol,
+// see --influx. Progress points go to the "neobench" measurement and cover the interval since the previous one;
+// the point written at completion goes to "neobench_result" and covers the whole run.
+type InfluxOutput struct {
+	ErrStream io.Writer
+	// See --tag; points get these as tags, next to script and database
+	Tags Tags
+
+	write func(lines []byte) error
+	// Called after the final result of each run is written, nil if there is nothing to close
+	close func() error
+	now   func() time.Time
+}
+
+// target is either an InfluxDB write URL, like http://localhost:8086/write?db=bench, which gets each batch of
+// points POSTed to it, or a file, which points are appended to. token is sent as an InfluxDB 2 API token, if set.
+func NewInfluxOutput(target, token string, errStream io.Writer) (*InfluxOutput, error) {
+	o := &InfluxOutput{ErrStream: errStream, now: time.Now}
+	if strings.HasPrefix(target, "http://") || strings.HasPrefix(target, "https://") {
+		o.write = func(lines []byte) error {
+			return postInflux(target, token, lines)
+		}
+		return o, nil
+	}
+
+	open := func() (*os.File, error) {
+		file, err := os.OpenFile(target, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+		return file, errors.Wrapf(err, "failed to open --influx file")
+	}
+	file, err := open()
+	if err != nil {
+		return nil, err
+	}
+	o.write = func(lines []byte) (err error) {
+		// Closed after the final result of a run; with --sweep, the next run appends to it again
+		if file == nil {
+			if file, err = open(); err != nil {
+				return err
+			}
+		}
+		_, err = file.Write(lines)
+		return err
+	}
+	o.close = func() error {
+		if file == nil {
+			return nil
+		}
+		defer func() { file = nil }()
+		if err := file.Sync(); err != nil {
+			file.Close()
+			return err
+		}
+		return file.Close()
+	}
+	return o, nil
+}
+
+func postInflux(url, token string, lines []byte) error {
+	req, err := http.NewRequest(http.MethodPost, url, bytes.NewReader(lines))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "text/plain; charset=utf-8")
+	if token != "" {
+		req.Header.Set("Authorization", fmt.Sprintf("Token %s", token))
+	}
+	res, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+	if res.StatusCode/100 != 2 {
+		body, _ := ioutil.ReadAll(io.LimitReader(res.Body, 1024))
+		return fmt.Errorf("%s: %s", res.Status, strings.TrimSpace(string(body)))
+	}
+	return nil
+}
+
+func (o *InfluxOutput) BenchmarkStart(databaseName, url, scenario string) {
+}
+
+func (o *InfluxOutput) ReportInitProgress(report ProgressReport) {
+}
+
+func (o *InfluxOutput) ReportWorkloadProgress(completeness float64, checkpoint Result) {
+	o.writePoints("neobench", checkpoint)
+}
+
+func (o *InfluxOutput) ReportThroughput(result Result) {
+	o.writePoints("neobench_result", result)
+	o.finish()
+}
+
+func (o *InfluxOutput) ReportLatency(result Result) {
+	o.writePoints("neobench_result", result)
+	o.finish()
+}
+
+func (o *InfluxOutput) ReportSweep(sweep SweepResult) {
+}
+
+func (o *InfluxOutput) Errorf(format string, a ...interface{}) {
+}
+
+// A database that is down shouldn't stop the benchmark; we say so and carry on
+func (o *InfluxOutput) writePoints(measurement string, result Result) {
+	lines := influxLines(measurement, result, o.Tags, o.now())
+	if len(lines) == 0 {
+		return
+	}
+	if err := o.write(lines); err != nil {
+		o.warn(err)
+	}
+}
+
+func (o *InfluxOutput) finish() {
+	if o.close == nil {
+		return
+	}
+	if err := o.close(); err != nil {
+		o.warn(err)
+	}
+}
+
+func (o *InfluxOutput) warn(err error) {
+	if _, err := fmt.Fprintf(o.ErrStream, "WARNING: failed to write to --influx: %s\n", err); err != nil {
+		panic(err)
+	}
+}
+
+// One line per script; latency fields are in milliseconds, and left out if nothing succeeded
+func influxLines(measurement string, result Result, tags Tags, now time.Time) []byte {
+	s := bytes.Buffer{}
+	for _, script := range sortedScripts(result) {
+		s.WriteString(influxEscape(measurement, ", "))
+		s.WriteString(",script=")
+		s.WriteString(influxEscape(script.ScriptName, ",= "))
+		if result.DatabaseName != "" {
+			s.WriteString(",database=")
+			s.WriteString(influxEscape(result.DatabaseName, ",= "))
+		}
+		for _, tag := range tags {
+			// Empty tag values aren't allowed in line protocol
+			if tag.Value == "" {
+				continue
+			}
+			s.WriteString(",")
+			s.WriteString(tag.Key)
+			s.WriteString("=")
+			s.WriteString(influxEscape(tag.Value, ",= "))
+		}
+
+		fields := map[string]string{
+			"tps":       fmt.Sprintf("%g", script.Rate),
+			"succeeded": fmt.Sprintf("%di", script.Succeeded),
+			"failed":    fmt.Sprintf("%di", script.Failed),
+		}
+		if script.Succeeded > 0 {
+			fields["p50"] = fmt.Sprintf("%g", float64(script.Latencies.ValueAtQuantile(50))/1000.0)
+			fields["p95"] = fmt.Sprintf("%g", float64(script.Latencies.ValueAtQuantile(95))/1000.0)
+			fields["p99"] = fmt.Sprintf("%g", float64(script.Latencies.ValueAtQuantile(99))/1000.0)
+		}
+		names := make([]string, 0, len(fields))
+		for name := range fields {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		for i, name := range names {
+			if i == 0 {
+				s.WriteString(" ")
+			} else {
+				s.WriteString(",")
+			}
+			s.WriteString(name)
+			s.WriteString("=")
+			s.WriteString(fields[name])
+		}
+		s.WriteString(fmt.Sprintf(" %d\n", now.UnixNano()))
+	}
+	return s.Bytes()
+}
+
+// Line protocol escapes special characters with a backslash; which ones are special depends on where they are
+func influxEscape(raw, special string) string {
+	var b strings.Builder
+	for _, r := range raw {
+		if strings.ContainsRune(special, r) {
+			b.WriteRune('\\')
+		}
+		b.WriteRune(r)
+	}
+	return b.String()
+}
+
+var _ Output = &InfluxOutput{}
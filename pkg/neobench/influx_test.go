@@ -0,0 +1,88 @@
+package neobench
+
+import (
+	"bytes"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestInfluxLines(t *testing.T) {
+	w := NewWorkerResult(0)
+	assert.NoError(t, w.record("my script,v2", 2*time.Millisecond, uowOutcome{succeeded: true}))
+	assert.NoError(t, w.record("my script,v2", 2*time.Millisecond, uowOutcome{succeeded: false, failureGroup: "unknown", err: os.ErrClosed}))
+	assert.NoError(t, w.record("failing", 2*time.Millisecond, uowOutcome{succeeded: false, failureGroup: "unknown", err: os.ErrClosed}))
+	w.calculateRate(time.Second)
+	result := NewResult("neo4j", "")
+	assert.NoError(t, result.Add(w))
+
+	lines := influxLines("neobench", result, nil, time.Unix(1, 0))
+
+	assert.Equal(t, "neobench,script=failing,database=neo4j failed=1i,succeeded=0i,tps=1 1000000000\n"+
+		"neobench,script=my\\ script\\,v2,database=neo4j failed=1i,p50=2,p95=2,p99=2,succeeded=1i,tps=2 1000000000\n",
+		string(lines))
+}
+
+func TestInfluxPostsToWriteUrl(t *testing.T) {
+	var body []byte
+	var auth, query string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ = ioutil.ReadAll(r.Body)
+		auth = r.Header.Get("Authorization")
+		query = r.URL.RawQuery
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer server.Close()
+
+	errStream := &bytes.Buffer{}
+	o, err := NewInfluxOutput(server.URL+"/api/v2/write?org=o&bucket=b", "secret", errStream)
+	assert.NoError(t, err)
+	o.ReportLatency(influxTestResult(t))
+
+	assert.Contains(t, string(body), "neobench_result,script=s ")
+	assert.Equal(t, "Token secret", auth)
+	assert.Equal(t, "org=o&bucket=b", query)
+	assert.Empty(t, errStream.String())
+}
+
+func TestInfluxAppendsToFile(t *testing.T) {
+	dir, err := ioutil.TempDir("", "influx")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "results.lp")
+
+	errStream := &bytes.Buffer{}
+	o, err := NewInfluxOutput(path, "", errStream)
+	assert.NoError(t, err)
+	o.ReportWorkloadProgress(0.5, influxTestResult(t))
+	o.ReportThroughput(influxTestResult(t))
+
+	content, err := ioutil.ReadFile(path)
+	assert.NoError(t, err)
+	lines := bytes.Split(bytes.TrimSpace(content), []byte("\n"))
+	if assert.Len(t, lines, 2) {
+		assert.True(t, bytes.HasPrefix(lines[0], []byte("neobench,script=s ")))
+		assert.True(t, bytes.HasPrefix(lines[1], []byte("neobench_result,script=s ")))
+	}
+
+	// The file is closed after each final result, and opened again for the next run of a sweep
+	o.ReportLatency(influxTestResult(t))
+	content, err = ioutil.ReadFile(path)
+	assert.NoError(t, err)
+	assert.Len(t, bytes.Split(bytes.TrimSpace(content), []byte("\n")), 3)
+	assert.Empty(t, errStream.String())
+}
+
+func influxTestResult(t *testing.T) Result {
+	w := NewWorkerResult(0)
+	assert.NoError(t, w.record("s", time.Millisecond, uowOutcome{succeeded: true}))
+	result := NewResult("", "")
+	assert.NoError(t, result.Add(w))
+	return result
+}
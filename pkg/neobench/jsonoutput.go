@@ -0,0 +1,450 @@
+package neobench
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+	"time"
+
+	"github.com/codahale/hdrhistogram"
+)
+
+// Writes results as JSON, one document per line on OutStream, for feeding into dashboards and other tools; see
+// docs/overview.md for the format. Progress and errors go to ErrStream as plain text, like for the CSV output.
+type JsonOutput struct {
+	ErrStream io.Writer
+	OutStream io.Writer
+	// See --percentiles; latencies have these in addition to the usual ones
+	Percentiles Percentiles
+	// See --tag; every document has these
+	Tags Tags
+	// Used to rate-limit progress reporting
+	LastProgressReport ProgressReport
+	LastProgressTime   time.Time
+}
+
+// Every line on OutStream is one of these, told apart by Type
+type jsonDocument struct {
+	// "progress", "result" or "sweep"
+	Type string `json:"type"`
+	// "throughput" or "latency"; unset for progress
+	Mode         string  `json:"mode,omitempty"`
+	Completeness float64 `json:"completeness,omitempty"`
+	// See --tag
+	Tags map[string]string `json:"tags,omitempty"`
+	*jsonResult
+	Sweep *jsonSweep `json:"sweep,omitempty"`
+}
+
+type jsonResult struct {
+	DatabaseName  string                 `json:"database"`
+	Scenario      string                 `json:"scenario"`
+	Succeeded     int64                  `json:"succeeded"`
+	Failed        int64                  `json:"failed"`
+	Retried       int64                  `json:"retried"`
+	Rate          float64                `json:"rate"`
+	RowsWritten   int64                  `json:"rows_written"`
+	BytesWritten  int64                  `json:"bytes_written"`
+	Scripts       []jsonScript           `json:"scripts"`
+	Workers       []jsonWorker           `json:"workers"`
+	Failures      []jsonFailureGroup     `json:"failures"`
+	LockErrors    map[string]int64       `json:"lock_errors"`
+	Acquire       *jsonLatencies         `json:"acquire_latency_ms,omitempty"`
+	HeadToHead    []string               `json:"head_to_head,omitempty"`
+	ServerMetrics []jsonServerMetrics    `json:"server_metrics,omitempty"`
+	Client        *jsonClientDiagnostics `json:"client_diagnostics,omitempty"`
+	Ramp          *jsonResult            `json:"ramp,omitempty"`
+	Metadata      *jsonMetadata          `json:"metadata,omitempty"`
+	Throughput    *jsonThroughputStats   `json:"throughput_stats,omitempty"`
+	Aborted       string                 `json:"aborted,omitempty"`
+	Settled       string                 `json:"settled,omitempty"`
+}
+
+type jsonScript struct {
+	Name         string         `json:"name"`
+	Description  string         `json:"description,omitempty"`
+	Succeeded    int64          `json:"succeeded"`
+	Failed       int64          `json:"failed"`
+	Retried      int64          `json:"retried"`
+	Deadlocks    int64          `json:"deadlocks"`
+	Rate         float64        `json:"rate"`
+	RowsWritten  int64          `json:"rows_written"`
+	BytesWritten int64          `json:"bytes_written"`
+	Latencies    *jsonLatencies `json:"latency_ms"`
+	// What the server says it spent on the statements, see ScriptResult.ServerLatencies
+	ServerLatencies *jsonLatencies `json:"server_latency_ms,omitempty"`
+	// From when transactions actually started, rather than when they were scheduled to
+	UncorrectedLatencies *jsonLatencies `json:"uncorrected_latency_ms,omitempty"`
+	// Only set with --statement-latencies
+	Statements []jsonStatement `json:"statements,omitempty"`
+}
+
+type jsonStatement struct {
+	Command   int            `json:"command"`
+	Query     string         `json:"query"`
+	Latencies *jsonLatencies `json:"latency_ms"`
+}
+
+type jsonWorker struct {
+	WorkerId  int64          `json:"id"`
+	Succeeded int64          `json:"succeeded"`
+	Failed    int64          `json:"failed"`
+	Rate      float64        `json:"rate"`
+	Latencies *jsonLatencies `json:"latency_ms"`
+}
+
+type jsonFailureGroup struct {
+	Group   string `json:"group"`
+	Count   int64  `json:"count"`
+	Example string `json:"example"`
+	// Neo4j error code; unset if the server didn't send the error
+	Code     string     `json:"code,omitempty"`
+	LastSeen *time.Time `json:"last_seen,omitempty"`
+	// A few distinct error messages, the first of them the example
+	Samples []string            `json:"samples"`
+	Sources []jsonFailureSource `json:"sources"`
+}
+
+// Command is -1 for failures outside any statement, eg. on commit
+type jsonFailureSource struct {
+	Script  string `json:"script"`
+	Command int    `json:"command"`
+	Query   string `json:"query,omitempty"`
+	Count   int64  `json:"count"`
+}
+
+// In milliseconds; unset if nothing was recorded
+type jsonLatencies struct {
+	Min    float64 `json:"min"`
+	Mean   float64 `json:"mean"`
+	Max    float64 `json:"max"`
+	Stddev float64 `json:"stddev"`
+	P50    float64 `json:"p50"`
+	P75    float64 `json:"p75"`
+	P95    float64 `json:"p95"`
+	P99    float64 `json:"p99"`
+	P99999 float64 `json:"p99.999"`
+	// Those asked for with --percentiles, eg. p99.9
+	Percentiles map[string]float64 `json:"percentiles,omitempty"`
+}
+
+type jsonServerMetrics struct {
+	Time               time.Time `json:"time"`
+	HeapUsedBytes      int64     `json:"heap_used_bytes"`
+	PageCacheHitRatio  float64   `json:"page_cache_hit_ratio"`
+	ActiveTransactions int64     `json:"active_transactions"`
+	// Since the server started
+	GcCollections        int64 `json:"gc_collections"`
+	GcTimeMillis         int64 `json:"gc_time_ms"`
+	Checkpoints          int64 `json:"checkpoints"`
+	CheckpointTimeMillis int64 `json:"checkpoint_time_ms"`
+}
+
+// Durations in milliseconds
+type jsonClientDiagnostics struct {
+	Elapsed          float64  `json:"elapsed_ms"`
+	GOMAXPROCS       int      `json:"gomaxprocs"`
+	MaxGoroutines    int      `json:"max_goroutines"`
+	NumGC            uint32   `json:"num_gc"`
+	GCPauseTotal     float64  `json:"gc_pause_total_ms"`
+	GCPauseMax       float64  `json:"gc_pause_max_ms"`
+	GCCPUFraction    float64  `json:"gc_cpu_fraction"`
+	SchedulingLagMax float64  `json:"scheduling_lag_max_ms"`
+	Warnings         []string `json:"warnings"`
+}
+
+type jsonMetadata struct {
+	NeobenchVersion string            `json:"neobench_version"`
+	DriverVersion   string            `json:"driver_version"`
+	ServerVersion   string            `json:"server_version"`
+	Seed            int64             `json:"seed"`
+	Start           time.Time         `json:"start"`
+	End             time.Time         `json:"end"`
+	Flags           map[string]string `json:"flags"`
+}
+
+// Transactions per second across the samples of a run, see ThroughputStats
+type jsonThroughputStats struct {
+	Samples  int     `json:"samples"`
+	Interval float64 `json:"interval_s"`
+	Mean     float64 `json:"mean"`
+	Stddev   float64 `json:"stddev"`
+	Low      float64 `json:"ci95_low"`
+	High     float64 `json:"ci95_high"`
+}
+
+type jsonSweep struct {
+	Scenario string `json:"scenario"`
+	// "rate" for --rate-sweep
+	Variable string        `json:"variable"`
+	Values   []interface{} `json:"values"`
+	Results  []*jsonResult `json:"results"`
+	// For --find-max-rate; max_rate is 0 if no rate met the condition
+	MaxRateCondition string   `json:"max_rate_condition,omitempty"`
+	MaxRate          *float64 `json:"max_rate,omitempty"`
+	// For --runs
+	RunSummary *jsonRunSummary `json:"run_summary,omitempty"`
+}
+
+type jsonRunSummary struct {
+	Rate jsonRunStats `json:"rate"`
+	P50  jsonRunStats `json:"p50_ms"`
+	P99  jsonRunStats `json:"p99_ms"`
+}
+
+type jsonRunStats struct {
+	Mean   float64 `json:"mean"`
+	Min    float64 `json:"min"`
+	Max    float64 `json:"max"`
+	Stddev float64 `json:"stddev"`
+}
+
+func (o *JsonOutput) BenchmarkStart(databaseName, url, scenario string) {
+	if databaseName == "" {
+		databaseName = "<default>"
+	}
+	_, err := fmt.Fprintf(o.ErrStream,
+		"Starting workload on database %s against %s\n"+
+			"Scenario: %s\n", databaseName, url, scenario)
+	if err != nil {
+		panic(err)
+	}
+}
+
+func (o *JsonOutput) ReportInitProgress(report ProgressReport) {
+	now := time.Now()
+	if report.Section == o.LastProgressReport.Section && report.Step == o.LastProgressReport.Step && now.Sub(o.LastProgressTime).Seconds() < 10 {
+		return
+	}
+	o.LastProgressReport = report
+	o.LastProgressTime = now
+	_, err := fmt.Fprintf(o.ErrStream, "[%s][%s] %.02f%%\n", report.Section, report.Step, report.Completeness*100)
+	if err != nil {
+		panic(err)
+	}
+}
+
+func (o *JsonOutput) ReportWorkloadProgress(completeness float64, checkpoint Result) {
+	_, err := fmt.Fprintf(o.ErrStream, "[workload] %.02f%% done\n", completeness*100)
+	if err != nil {
+		panic(err)
+	}
+	o.write(jsonDocument{Type: "progress", Completeness: completeness, jsonResult: toJsonResult(checkpoint, o.Percentiles, false)})
+}
+
+func (o *JsonOutput) ReportThroughput(result Result) {
+	o.write(jsonDocument{Type: "result", Mode: "throughput", jsonResult: toJsonResult(result, o.Percentiles, false)})
+}
+
+func (o *JsonOutput) ReportLatency(result Result) {
+	o.write(jsonDocument{Type: "result", Mode: "latency", jsonResult: toJsonResult(result, o.Percentiles, true)})
+}
+
+func (o *JsonOutput) ReportSweep(sweep SweepResult) {
+	mode := "throughput"
+	if sweep.LatencyMode {
+		mode = "latency"
+	}
+	results := make([]*jsonResult, 0, len(sweep.Results))
+	for _, result := range sweep.Results {
+		results = append(results, toJsonResult(result, o.Percentiles, sweep.LatencyMode))
+	}
+	doc := &jsonSweep{
+		Scenario: sweep.Scenario,
+		Variable: sweep.Variable,
+		Values:   sweep.Values,
+		Results:  results,
+	}
+	if sweep.MaxRateCondition != "" {
+		maxRate := sweep.MaxRate
+		doc.MaxRateCondition, doc.MaxRate = sweep.MaxRateCondition, &maxRate
+	}
+	if sweep.Runs {
+		summary := SummarizeRuns(sweep.Results)
+		doc.RunSummary = &jsonRunSummary{Rate: jsonRunStats(summary.Rate), P50: jsonRunStats(summary.P50), P99: jsonRunStats(summary.P99)}
+	}
+	o.write(jsonDocument{Type: "sweep", Mode: mode, Sweep: doc})
+}
+
+func (o *JsonOutput) Noticef(format string, a ...interface{}) {
+	_, err := fmt.Fprintf(o.ErrStream, "%s\n", fmt.Sprintf(format, a...))
+	if err != nil {
+		panic(err)
+	}
+}
+
+func (o *JsonOutput) Errorf(format string, a ...interface{}) {
+	_, err := fmt.Fprintf(o.ErrStream, "ERROR: %s\n", fmt.Sprintf(format, a...))
+	if err != nil {
+		panic(err)
+	}
+}
+
+func (o *JsonOutput) write(doc jsonDocument) {
+	if len(o.Tags) > 0 {
+		doc.Tags = o.Tags.asMap()
+	}
+	content, err := json.Marshal(doc)
+	if err != nil {
+		panic(err)
+	}
+	if _, err := fmt.Fprintf(o.OutStream, "%s\n", content); err != nil {
+		panic(err)
+	}
+}
+
+// The uncorrected latencies are only told apart from the others in latency mode, where transactions have a
+// scheduled start; progress reports leave them out, since they don't say which mode the run is in
+func toJsonResult(result Result, percentiles Percentiles, latencyMode bool) *jsonResult {
+	written := result.TotalWritten()
+	out := &jsonResult{
+		DatabaseName: result.DatabaseName,
+		Scenario:     result.Scenario,
+		Succeeded:    result.TotalSucceeded(),
+		Failed:       result.TotalFailed(),
+		Retried:      result.TotalRetried(),
+		Rate:         result.TotalRate(),
+		RowsWritten:  written.Rows,
+		BytesWritten: written.Bytes,
+		Scripts:      make([]jsonScript, 0, len(result.Scripts)),
+		Workers:      make([]jsonWorker, 0, len(result.Workers)),
+		Failures:     make([]jsonFailureGroup, 0, len(result.FailedByErrorGroup)),
+		LockErrors:   result.LockErrors,
+		Acquire:      toJsonLatencies(result.AcquireLatencies, nil),
+		HeadToHead:   result.HeadToHead,
+		Aborted:      result.Aborted,
+		Settled:      result.Settled,
+	}
+	if out.LockErrors == nil {
+		out.LockErrors = make(map[string]int64)
+	}
+	for _, script := range sortedScripts(result) {
+		js := jsonScript{
+			Name:            script.ScriptName,
+			Description:     result.Descriptions[script.ScriptName],
+			Succeeded:       script.Succeeded,
+			Failed:          script.Failed,
+			Retried:         script.Retried,
+			Deadlocks:       script.Deadlocks,
+			Rate:            script.Rate,
+			RowsWritten:     script.RowsWritten,
+			BytesWritten:    script.BytesWritten,
+			Latencies:       toJsonLatencies(script.Latencies, percentiles),
+			ServerLatencies: toJsonLatencies(script.ServerLatencies, percentiles),
+		}
+		if latencyMode {
+			js.UncorrectedLatencies = toJsonLatencies(script.UncorrectedLatencies, percentiles)
+		}
+		for _, statement := range script.Statements {
+			js.Statements = append(js.Statements, jsonStatement{
+				Command:   statement.Command,
+				Query:     statement.Query,
+				Latencies: toJsonLatencies(statement.Latencies, percentiles),
+			})
+		}
+		sort.Slice(js.Statements, func(i, j int) bool {
+			return js.Statements[i].Command < js.Statements[j].Command
+		})
+		out.Scripts = append(out.Scripts, js)
+	}
+	for _, worker := range result.Workers {
+		out.Workers = append(out.Workers, jsonWorker{
+			WorkerId:  worker.WorkerId,
+			Succeeded: worker.Succeeded,
+			Failed:    worker.Failed,
+			Rate:      worker.Rate,
+			Latencies: toJsonLatencies(worker.Latencies, percentiles),
+		})
+	}
+	for _, name := range sortedFailureGroups(result) {
+		group := result.FailedByErrorGroup[name]
+		jg := jsonFailureGroup{
+			Group:   name,
+			Count:   group.Count,
+			Code:    group.Code,
+			Samples: group.Samples,
+			Sources: make([]jsonFailureSource, 0, len(group.Sources)),
+		}
+		if group.FirstFailure != nil {
+			jg.Example = group.FirstFailure.Error()
+		}
+		if !group.LastSeen.IsZero() {
+			lastSeen := group.LastSeen
+			jg.LastSeen = &lastSeen
+		}
+		if jg.Samples == nil {
+			jg.Samples = []string{}
+		}
+		for _, source := range group.Sources {
+			jg.Sources = append(jg.Sources, jsonFailureSource(source))
+		}
+		out.Failures = append(out.Failures, jg)
+	}
+	for _, sample := range result.ServerMetrics {
+		out.ServerMetrics = append(out.ServerMetrics, jsonServerMetrics(sample))
+	}
+	if d := result.ClientDiagnostics; d != nil {
+		ms := func(d time.Duration) float64 {
+			return float64(d.Microseconds()) / 1000.0
+		}
+		out.Client = &jsonClientDiagnostics{
+			Elapsed:          ms(d.Elapsed),
+			GOMAXPROCS:       d.GOMAXPROCS,
+			MaxGoroutines:    d.MaxGoroutines,
+			NumGC:            d.NumGC,
+			GCPauseTotal:     ms(d.GCPauseTotal),
+			GCPauseMax:       ms(d.GCPauseMax),
+			GCCPUFraction:    d.GCCPUFraction,
+			SchedulingLagMax: ms(d.SchedulingLagMax),
+			Warnings:         d.Warnings(),
+		}
+	}
+	if result.Ramp != nil {
+		out.Ramp = toJsonResult(*result.Ramp, percentiles, latencyMode)
+	}
+	if stats, ok := throughputStats(result.ThroughputSamples); ok {
+		out.Throughput = &jsonThroughputStats{
+			Samples:  stats.Samples,
+			Interval: ThroughputSampleInterval.Seconds(),
+			Mean:     stats.Mean,
+			Stddev:   stats.Stddev,
+			Low:      stats.Low,
+			High:     stats.High,
+		}
+	}
+	if m := result.Metadata; m != nil {
+		metadata := jsonMetadata(*m)
+		out.Metadata = &metadata
+	}
+	return out
+}
+
+func toJsonLatencies(histo *hdrhistogram.Histogram, percentiles Percentiles) *jsonLatencies {
+	if histo == nil || histo.TotalCount() == 0 {
+		return nil
+	}
+	ms := func(micros int64) float64 {
+		return float64(micros) / 1000.0
+	}
+	latencies := &jsonLatencies{
+		Min:    ms(histo.Min()),
+		Mean:   histo.Mean() / 1000.0,
+		Max:    ms(histo.Max()),
+		Stddev: histo.StdDev() / 1000.0,
+		P50:    ms(histo.ValueAtQuantile(50)),
+		P75:    ms(histo.ValueAtQuantile(75)),
+		P95:    ms(histo.ValueAtQuantile(95)),
+		P99:    ms(histo.ValueAtQuantile(99)),
+		P99999: ms(histo.ValueAtQuantile(99.999)),
+	}
+	if len(percentiles) > 0 {
+		latencies.Percentiles = make(map[string]float64, len(percentiles))
+		for _, q := range percentiles {
+			latencies.Percentiles["p"+percentileLabel(q)] = ms(histo.ValueAtQuantile(q))
+		}
+	}
+	return latencies
+}
+
+var _ Output = &JsonOutput{}
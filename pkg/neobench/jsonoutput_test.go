@@ -0,0 +1,126 @@
+package neobench
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestJsonOutputWritesFullResult(t *testing.T) {
+	w := NewWorkerResult(3)
+	assert.NoError(t, w.record("a", time.Millisecond, uowOutcome{succeeded: true, acquired: true, acquire: time.Millisecond}))
+	assert.NoError(t, w.record("a", time.Millisecond, uowOutcome{succeeded: false, failureGroup: "unknown", err: fmt.Errorf("boom")}))
+	w.calculateRate(time.Second)
+	result := NewResult("neo4j", "-c 1")
+	assert.NoError(t, result.Add(w))
+
+	out := bytes.Buffer{}
+	o := &JsonOutput{OutStream: &out, ErrStream: &bytes.Buffer{}}
+	o.ReportLatency(result)
+
+	var doc map[string]interface{}
+	assert.NoError(t, json.Unmarshal(out.Bytes(), &doc))
+	assert.Equal(t, "result", doc["type"])
+	assert.Equal(t, "latency", doc["mode"])
+	assert.Equal(t, "-c 1", doc["scenario"])
+	assert.Equal(t, float64(1), doc["succeeded"])
+	assert.Equal(t, float64(1), doc["failed"])
+
+	script := doc["scripts"].([]interface{})[0].(map[string]interface{})
+	assert.Equal(t, "a", script["name"])
+	assert.Equal(t, float64(2), script["rate"])
+	assert.Equal(t, float64(1), script["latency_ms"].(map[string]interface{})["p99"])
+	assert.NotContains(t, script, "description")
+
+	worker := doc["workers"].([]interface{})[0].(map[string]interface{})
+	assert.Equal(t, float64(3), worker["id"])
+	assert.Equal(t, float64(1), worker["succeeded"])
+
+	assert.Equal(t, []interface{}{map[string]interface{}{
+		"group":   "unknown",
+		"count":   float64(1),
+		"example": "boom",
+		"samples": []interface{}{"boom"},
+		"sources": []interface{}{map[string]interface{}{"script": "a", "command": float64(-1), "count": float64(1)}},
+	}}, doc["failures"])
+	assert.Equal(t, float64(1), doc["acquire_latency_ms"].(map[string]interface{})["max"])
+	assert.NotContains(t, doc, "ramp")
+}
+
+func TestJsonOutputOnlyHasUncorrectedLatenciesInLatencyMode(t *testing.T) {
+	w := NewWorkerResult(0)
+	assert.NoError(t, w.record("a", 3*time.Millisecond, uowOutcome{succeeded: true, startedLate: 2 * time.Millisecond}))
+	result := NewResult("neo4j", "-c 1")
+	assert.NoError(t, result.Add(w))
+
+	for _, tc := range []struct {
+		report      func(o *JsonOutput)
+		uncorrected bool
+	}{
+		{func(o *JsonOutput) { o.ReportLatency(result) }, true},
+		{func(o *JsonOutput) { o.ReportThroughput(result) }, false},
+		{func(o *JsonOutput) { o.ReportWorkloadProgress(0.5, result) }, false},
+	} {
+		out := bytes.Buffer{}
+		tc.report(&JsonOutput{OutStream: &out, ErrStream: &bytes.Buffer{}})
+		var doc map[string]interface{}
+		assert.NoError(t, json.Unmarshal(out.Bytes(), &doc))
+		script := doc["scripts"].([]interface{})[0].(map[string]interface{})
+		if tc.uncorrected {
+			assert.Equal(t, float64(1), script["uncorrected_latency_ms"].(map[string]interface{})["max"])
+		} else {
+			assert.NotContains(t, script, "uncorrected_latency_ms")
+		}
+	}
+}
+
+func TestJsonOutputWritesOneDocumentPerLine(t *testing.T) {
+	out := bytes.Buffer{}
+	o := &JsonOutput{OutStream: &out, ErrStream: &bytes.Buffer{}}
+	o.ReportWorkloadProgress(0.5, NewResult("", ""))
+	o.ReportThroughput(NewResult("", ""))
+	o.ReportSweep(SweepResult{Variable: "batchSize", Values: []interface{}{int64(10)}, Results: []Result{NewResult("", "")}})
+
+	lines := bytes.Split(bytes.TrimSpace(out.Bytes()), []byte("\n"))
+	types := make([]string, 0)
+	for _, line := range lines {
+		var doc map[string]interface{}
+		assert.NoError(t, json.Unmarshal(line, &doc))
+		types = append(types, doc["type"].(string))
+	}
+	assert.Equal(t, []string{"progress", "result", "sweep"}, types)
+}
+
+func TestJsonOutputIncludesRunMetadata(t *testing.T) {
+	result := NewResult("neo4j", "-c 1")
+	start := time.Date(2020, 1, 1, 1, 0, 0, 0, time.UTC)
+	result.Metadata = &RunMetadata{
+		NeobenchVersion: "1.0.0",
+		DriverVersion:   "v4.3.3",
+		ServerVersion:   "4.4.3",
+		Seed:            1234,
+		Start:           start,
+		End:             start.Add(time.Minute),
+		Flags:           map[string]string{"clients": "4"},
+	}
+
+	out := bytes.Buffer{}
+	o := &JsonOutput{OutStream: &out, ErrStream: &bytes.Buffer{}}
+	o.ReportThroughput(result)
+
+	var doc map[string]interface{}
+	assert.NoError(t, json.Unmarshal(out.Bytes(), &doc))
+	assert.Equal(t, map[string]interface{}{
+		"neobench_version": "1.0.0",
+		"driver_version":   "v4.3.3",
+		"server_version":   "4.4.3",
+		"seed":             float64(1234),
+		"start":            "2020-01-01T01:00:00Z",
+		"end":              "2020-01-01T01:01:00Z",
+		"flags":            map[string]interface{}{"clients": "4"},
+	}, doc["metadata"])
+}
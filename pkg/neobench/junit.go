@@ -0,0 +1,199 @@
+package neobench
+
+import (
+	"encoding/xml"
+	"fmt"
+	"io/ioutil"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// Writes results as JUnit XML, see --junit, so CI servers like Jenkins and GitLab show them in their test
+// reports. Each run is a test suite, with a test case per script, which fails if any of its transactions
+// failed, and one per SLA limit, which fails if the run breaks it. The file is rewritten each time a run
+// completes, with a suite for every run so far.
+type JUnitOutput struct {
+	Path string
+	SLA  SLA
+	// See --percentiles
+	Percentiles Percentiles
+	// See --tag; each suite has these as properties
+	Tags Tags
+
+	start  time.Time
+	suites []junitSuite
+	now    func() time.Time
+}
+
+type junitSuites struct {
+	XMLName  xml.Name     `xml:"testsuites"`
+	Tests    int          `xml:"tests,attr"`
+	Failures int          `xml:"failures,attr"`
+	Suites   []junitSuite `xml:"testsuite"`
+}
+
+type junitSuite struct {
+	Name       string          `xml:"name,attr"`
+	Tests      int             `xml:"tests,attr"`
+	Failures   int             `xml:"failures,attr"`
+	Time       string          `xml:"time,attr"`
+	Timestamp  string          `xml:"timestamp,attr"`
+	Properties []junitProperty `xml:"properties>property,omitempty"`
+	Cases      []junitCase     `xml:"testcase"`
+}
+
+type junitProperty struct {
+	Name  string `xml:"name,attr"`
+	Value string `xml:"value,attr"`
+}
+
+type junitCase struct {
+	ClassName string        `xml:"classname,attr"`
+	Name      string        `xml:"name,attr"`
+	Time      string        `xml:"time,attr"`
+	Failure   *junitFailure `xml:"failure,omitempty"`
+	SystemOut string        `xml:"system-out,omitempty"`
+}
+
+type junitFailure struct {
+	Message string `xml:"message,attr"`
+	Type    string `xml:"type,attr"`
+	Text    string `xml:",chardata"`
+}
+
+func NewJUnitOutput(path string, sla SLA) *JUnitOutput {
+	return &JUnitOutput{
+		Path: path,
+		SLA:  sla,
+		now:  time.Now,
+	}
+}
+
+func (j *JUnitOutput) BenchmarkStart(databaseName, url, scenario string) {
+	j.start = j.now()
+}
+
+func (j *JUnitOutput) ReportInitProgress(report ProgressReport) {
+}
+
+func (j *JUnitOutput) ReportWorkloadProgress(completeness float64, checkpoint Result) {
+}
+
+func (j *JUnitOutput) ReportThroughput(result Result) {
+	j.suites = append(j.suites, j.suite(result, false))
+	j.write()
+}
+
+func (j *JUnitOutput) ReportLatency(result Result) {
+	j.suites = append(j.suites, j.suite(result, true))
+	j.write()
+}
+
+func (j *JUnitOutput) ReportSweep(sweep SweepResult) {
+}
+
+func (j *JUnitOutput) Errorf(format string, a ...interface{}) {
+}
+
+func (j *JUnitOutput) suite(result Result, latencyMode bool) junitSuite {
+	elapsed := fmt.Sprintf("%.3f", j.now().Sub(j.start).Seconds())
+	databaseName := result.DatabaseName
+	if databaseName == "" {
+		databaseName = "<default>"
+	}
+	mode := "throughput"
+	if latencyMode {
+		mode = "latency"
+	}
+	suite := junitSuite{
+		Name:      fmt.Sprintf("neobench %s", result.Scenario),
+		Time:      elapsed,
+		Timestamp: j.start.UTC().Format("2006-01-02T15:04:05"),
+		Properties: []junitProperty{
+			{Name: "database", Value: databaseName},
+			{Name: "scenario", Value: result.Scenario},
+			{Name: "mode", Value: mode},
+		},
+	}
+	for _, tag := range j.Tags {
+		suite.Properties = append(suite.Properties, junitProperty{Name: "tag." + tag.Key, Value: tag.Value})
+	}
+	if m := result.Metadata; m != nil {
+		suite.Properties = append(suite.Properties,
+			junitProperty{Name: "neobench.version", Value: m.NeobenchVersion},
+			junitProperty{Name: "driver.version", Value: m.DriverVersion},
+			junitProperty{Name: "server.version", Value: m.ServerVersion},
+			junitProperty{Name: "seed", Value: fmt.Sprintf("%d", m.Seed)},
+			junitProperty{Name: "start", Value: m.Start.UTC().Format(time.RFC3339)},
+			junitProperty{Name: "end", Value: m.End.UTC().Format(time.RFC3339)})
+		for _, name := range m.sortedFlagNames() {
+			suite.Properties = append(suite.Properties, junitProperty{Name: "flag." + name, Value: m.Flags[name]})
+		}
+	}
+
+	for _, script := range sortedScripts(result) {
+		c := junitCase{
+			ClassName: "neobench.scripts",
+			Name:      script.ScriptName,
+			Time:      elapsed,
+		}
+		summary := strings.Builder{}
+		if latencyMode && script.Succeeded > 0 {
+			summarizeLatency(script, j.Percentiles, &summary, "")
+		} else {
+			summary.WriteString(fmt.Sprintf("%d successful transactions, %d failed. (Total of %.3f per second)\n",
+				script.Succeeded, script.Failed, script.Rate))
+		}
+		c.SystemOut = summary.String()
+		if script.Failed > 0 {
+			c.Failure = &junitFailure{
+				Message: fmt.Sprintf("%d of %d transactions failed", script.Failed, script.Succeeded+script.Failed),
+				Type:    "FailedTransactions",
+			}
+			report := strings.Builder{}
+			writeErrorReport(result, &report)
+			c.Failure.Text = report.String()
+		}
+		suite.Cases = append(suite.Cases, c)
+	}
+
+	for _, check := range j.SLA.Check(result) {
+		c := junitCase{
+			ClassName: "neobench.sla",
+			Name:      check.Name,
+			Time:      elapsed,
+		}
+		if check.Breach != "" {
+			c.Failure = &junitFailure{Message: check.Breach, Type: "SLABreached"}
+		}
+		suite.Cases = append(suite.Cases, c)
+	}
+
+	suite.Tests = len(suite.Cases)
+	for _, c := range suite.Cases {
+		if c.Failure != nil {
+			suite.Failures++
+		}
+	}
+	return suite
+}
+
+func (j *JUnitOutput) write() {
+	all := junitSuites{Suites: j.suites}
+	for _, suite := range j.suites {
+		all.Tests += suite.Tests
+		all.Failures += suite.Failures
+	}
+	content, err := xml.MarshalIndent(all, "", "  ")
+	if err != nil {
+		panic(errors.Wrap(err, "failed to encode junit report"))
+	}
+	content = append([]byte(xml.Header), append(content, '\n')...)
+	if err := ioutil.WriteFile(j.Path, content, 0644); err != nil {
+		panic(errors.Wrapf(err, "failed to write junit report to %s", j.Path))
+	}
+}
+
+var _ Output = &JUnitOutput{}
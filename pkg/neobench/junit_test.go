@@ -0,0 +1,78 @@
+package neobench
+
+import (
+	"encoding/xml"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestJUnitReportsFailedScriptsAndSLABreaches(t *testing.T) {
+	dir, err := ioutil.TempDir("", "junit")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	clock := time.Date(2020, 1, 1, 1, 1, 1, 0, time.UTC)
+	j := NewJUnitOutput(filepath.Join(dir, "neobench.xml"), SLA{MaxAcquireP99: time.Millisecond})
+	j.now = func() time.Time { return clock }
+	j.BenchmarkStart("", "neo4j://localhost:7687", "-l -r 10")
+	clock = clock.Add(time.Minute)
+
+	w := NewWorkerResult(0)
+	assert.NoError(t, w.record("a", time.Millisecond, uowOutcome{succeeded: true, acquired: true, acquire: 5 * time.Millisecond}))
+	assert.NoError(t, w.record("b", time.Millisecond, uowOutcome{succeeded: true}))
+	assert.NoError(t, w.record("b", time.Millisecond, uowOutcome{succeeded: false, failureGroup: "unknown", err: os.ErrClosed}))
+	result := NewResult("", "-l -r 10")
+	assert.NoError(t, result.Add(w))
+	j.ReportLatency(result)
+
+	content, err := ioutil.ReadFile(j.Path)
+	assert.NoError(t, err)
+	var report junitSuites
+	assert.NoError(t, xml.Unmarshal(content, &report))
+
+	assert.Equal(t, 3, report.Tests)
+	assert.Equal(t, 2, report.Failures)
+	if assert.Len(t, report.Suites, 1) {
+		suite := report.Suites[0]
+		assert.Equal(t, "neobench -l -r 10", suite.Name)
+		assert.Equal(t, "60.000", suite.Time)
+		assert.Equal(t, "2020-01-01T01:01:01", suite.Timestamp)
+		if assert.Len(t, suite.Cases, 3) {
+			a, b, acquire := suite.Cases[0], suite.Cases[1], suite.Cases[2]
+			assert.Equal(t, "a", a.Name)
+			assert.Nil(t, a.Failure)
+			assert.Contains(t, a.SystemOut, "P99.000: 1.000ms")
+
+			assert.Equal(t, "b", b.Name)
+			if assert.NotNil(t, b.Failure) {
+				assert.Equal(t, "1 of 2 transactions failed", b.Failure.Message)
+				assert.Contains(t, b.Failure.Text, "unknown: 1 failures")
+			}
+
+			assert.Equal(t, "neobench.sla", acquire.ClassName)
+			assert.Equal(t, "max-acquire-p99", acquire.Name)
+			if assert.NotNil(t, acquire.Failure) {
+				assert.Contains(t, acquire.Failure.Message, "connection acquisition p99 was 5.003ms, above --max-acquire-p99 1ms")
+			}
+		}
+	}
+}
+
+func TestJUnitOnlyHasCasesForSLALimitsThatAreSet(t *testing.T) {
+	j := NewJUnitOutput("", SLA{})
+	w := NewWorkerResult(0)
+	assert.NoError(t, w.record("a", time.Millisecond, uowOutcome{succeeded: true}))
+	result := NewResult("", "")
+	assert.NoError(t, result.Add(w))
+
+	suite := j.suite(result, false)
+
+	assert.Equal(t, 1, suite.Tests)
+	assert.Equal(t, 0, suite.Failures)
+	assert.Equal(t, "1 successful transactions, 0 failed. (Total of 0.000 per second)\n", suite.Cases[0].SystemOut)
+}
@@ -0,0 +1,155 @@
+package neobench
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// The total rate offered over time, see --load-profile, as segments one after another from the start of the run.
+// In a file, each line is a segment, either holding a rate or ramping to it from where the segment before ended,
+// which is 0 for the first:
+//
+//	# ramp up, hold, and a spike
+//	1000 over 5m
+//	1000 for 10m
+//	3000 for 1m
+//	1000 for 5m
+//
+// After the last segment, its rate holds for as long as the run goes on.
+type LoadProfile struct {
+	Segments []LoadSegment
+}
+
+type LoadSegment struct {
+	// Rates at the start and end of the segment, in total transactions per second; the same unless it's a ramp
+	From, To float64
+	Length   time.Duration
+}
+
+func LoadLoadProfile(path string) (*LoadProfile, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to open --load-profile")
+	}
+	defer file.Close()
+	profile, err := ParseLoadProfile(file)
+	if err != nil {
+		return nil, errors.Wrapf(err, "invalid --load-profile %s", path)
+	}
+	return profile, nil
+}
+
+func ParseLoadProfile(in io.Reader) (*LoadProfile, error) {
+	profile := &LoadProfile{}
+	previous := 0.0
+	scanner := bufio.NewScanner(in)
+	for lineNo := 1; scanner.Scan(); lineNo++ {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		fields := strings.Fields(line)
+		if len(fields) != 3 || (fields[1] != "for" && fields[1] != "over") {
+			return nil, fmt.Errorf("line %d should be <rate> for <duration> or <rate> over <duration>, like 1000 for 10m, got '%s'", lineNo, line)
+		}
+		rate, err := strconv.ParseFloat(fields[0], 64)
+		if err != nil || rate < 0 {
+			return nil, fmt.Errorf("line %d has a rate that isn't a number of transactions per second, '%s'", lineNo, fields[0])
+		}
+		length, err := time.ParseDuration(fields[2])
+		if err != nil || length <= 0 {
+			return nil, fmt.Errorf("line %d has a duration that isn't above zero, like 30s or 5m, '%s'", lineNo, fields[2])
+		}
+		from := rate
+		if fields[1] == "over" {
+			from = previous
+		}
+		profile.Segments = append(profile.Segments, LoadSegment{From: from, To: rate, Length: length})
+		previous = rate
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	if len(profile.Segments) == 0 {
+		return nil, fmt.Errorf("no segments, expected lines like 1000 for 10m")
+	}
+	return profile, nil
+}
+
+// How long the segments take altogether
+func (p *LoadProfile) Length() time.Duration {
+	total := time.Duration(0)
+	for _, segment := range p.Segments {
+		total += segment.Length
+	}
+	return total
+}
+
+// The total rate at the given time into the run
+func (p *LoadProfile) RateAt(elapsed time.Duration) float64 {
+	start := time.Duration(0)
+	for _, segment := range p.Segments {
+		if elapsed < start+segment.Length {
+			return segment.From + (segment.To-segment.From)*float64(elapsed-start)/float64(segment.Length)
+		}
+		start += segment.Length
+	}
+	return p.Segments[len(p.Segments)-1].To
+}
+
+// When a client gets its next transaction, given when it got its last one, both as time into the run, for a
+// client with share of the total rate. Arrivals follow the integral of the rate, so a client gets one transaction
+// per 1/share transactions offered in total, even as the rate changes in between; false if the rate drops to zero
+// for good before the next one.
+func (p *LoadProfile) Next(last time.Duration, share float64) (time.Duration, bool) {
+	// Transactions still to be offered to this client until the next one is due
+	remaining := 1.0
+	start := time.Duration(0)
+	for i := 0; ; i++ {
+		var segment LoadSegment
+		if i < len(p.Segments) {
+			segment = p.Segments[i]
+		} else {
+			// The last rate holds forever
+			final := p.Segments[len(p.Segments)-1].To
+			if final == 0 {
+				return 0, false
+			}
+			segment = LoadSegment{From: final, To: final, Length: time.Duration(math.MaxInt64 - int64(start))}
+		}
+		end := start + segment.Length
+		if last >= end {
+			start = end
+			continue
+		}
+		from := last
+		if from < start {
+			from = start
+		}
+		// Rates over the rest of the segment, in transactions per second for this client, with the slope of the ramp
+		rate := p.RateAt(from) * share
+		slope := (segment.To - segment.From) * share / segment.Length.Seconds()
+		left := (end - from).Seconds()
+		if area := rate*left + slope*left*left/2; area < remaining {
+			remaining -= area
+			start = end
+			continue
+		}
+		// Solves rate*x + slope/2*x^2 = remaining for the time x into the rest of the segment
+		var x float64
+		if slope == 0 {
+			x = remaining / rate
+		} else {
+			x = (-rate + math.Sqrt(math.Max(0, rate*rate+2*slope*remaining))) / slope
+		}
+		return from + time.Duration(x*float64(time.Second)), true
+	}
+}
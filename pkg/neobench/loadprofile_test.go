@@ -0,0 +1,134 @@
+package neobench
+
+import (
+	"math/rand"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseLoadProfile(t *testing.T) {
+	profile, err := ParseLoadProfile(strings.NewReader(`# ramp up, hold, and a spike
+1000 over 5m
+1000 for 10m
+
+3000 for 1m
+0 over 30s
+`))
+	assert.NoError(t, err)
+	assert.Equal(t, []LoadSegment{
+		{From: 0, To: 1000, Length: 5 * time.Minute},
+		{From: 1000, To: 1000, Length: 10 * time.Minute},
+		{From: 3000, To: 3000, Length: time.Minute},
+		{From: 3000, To: 0, Length: 30 * time.Second},
+	}, profile.Segments)
+	assert.Equal(t, 16*time.Minute+30*time.Second, profile.Length())
+	assert.Equal(t, float64(500), profile.RateAt(150*time.Second))
+	assert.Equal(t, float64(3000), profile.RateAt(15*time.Minute))
+	assert.Equal(t, float64(0), profile.RateAt(time.Hour))
+
+	_, err = ParseLoadProfile(strings.NewReader("1000 until 5m"))
+	assert.EqualError(t, err, "line 1 should be <rate> for <duration> or <rate> over <duration>, like 1000 for 10m, got '1000 until 5m'")
+	_, err = ParseLoadProfile(strings.NewReader("# nothing\nfast for 5m"))
+	assert.EqualError(t, err, "line 2 has a rate that isn't a number of transactions per second, 'fast'")
+	_, err = ParseLoadProfile(strings.NewReader("1000 for 0s"))
+	assert.EqualError(t, err, "line 1 has a duration that isn't above zero, like 30s or 5m, '0s'")
+	_, err = ParseLoadProfile(strings.NewReader("# nothing"))
+	assert.EqualError(t, err, "no segments, expected lines like 1000 for 10m")
+}
+
+func TestLoadProfileSchedulesByTheIntegralOfTheRate(t *testing.T) {
+	steady := &LoadProfile{Segments: []LoadSegment{{From: 10, To: 10, Length: time.Second}}}
+	next, ok := steady.Next(0, 1)
+	assert.True(t, ok)
+	assert.Equal(t, 100*time.Millisecond, next)
+	// Half the rate, as one of two clients
+	next, _ = steady.Next(0, 0.5)
+	assert.Equal(t, 200*time.Millisecond, next)
+	// The last rate holds after the profile ends
+	next, _ = steady.Next(time.Hour, 1)
+	assert.Equal(t, time.Hour+100*time.Millisecond, next)
+
+	// From 0 to 2 tps over 10 seconds, the first transaction is offered once the area under the ramp is 1
+	ramp := &LoadProfile{Segments: []LoadSegment{{From: 0, To: 2, Length: 10 * time.Second}}}
+	next, ok = ramp.Next(0, 1)
+	assert.True(t, ok)
+	assert.InDelta(t, 3162*time.Millisecond, next, float64(time.Millisecond))
+
+	// Idle segments are skipped over, and a profile that ends at 0 ends the run
+	idle := &LoadProfile{Segments: []LoadSegment{
+		{From: 1, To: 1, Length: time.Second},
+		{From: 0, To: 0, Length: time.Minute},
+		{From: 1, To: 1, Length: 2 * time.Second},
+		{From: 0, To: 0, Length: time.Second},
+	}}
+	next, _ = idle.Next(0, 1)
+	assert.Equal(t, time.Second, next)
+	next, _ = idle.Next(time.Second, 1)
+	assert.Equal(t, 62*time.Second, next)
+	next, _ = idle.Next(62*time.Second, 1)
+	assert.Equal(t, 63*time.Second, next)
+	_, ok = idle.Next(63*time.Second, 1)
+	assert.False(t, ok)
+}
+
+func TestWorkerFollowsTheProfile(t *testing.T) {
+	r := rand.New(rand.NewSource(1337))
+	clock := &fakeSpaceTimeContinuum{}
+	clock.currentTime = time.Date(2020, 1, 1, 1, 1, 1, 1, time.UTC)
+	w := Worker{
+		workerId: 0,
+		driver:   &fakeDriver{clock: clock, r: r, minLatency: time.Millisecond, maxLatency: time.Millisecond},
+		now:      clock.now,
+		sleep:    clock.sleepUnlessStopped,
+	}
+	// 20 for the client, half of 40 in total, then 40 more at double the rate, and then nothing
+	w.FollowProfile(&LoadProfile{Segments: []LoadSegment{
+		{From: 40, To: 40, Length: time.Second},
+		{From: 80, To: 80, Length: time.Second},
+		{From: 0, To: 0, Length: time.Second},
+	}}, 2)
+
+	result := w.RunBenchmark(newTestWorkload(r), "", 0, 0, make(chan struct{}), nil, NewResultRecorder(0, time.Time{}))
+
+	assert.NoError(t, result.Error)
+	script := result.Scripts["workertest"]
+	assert.InDelta(t, 60, script.Succeeded, 1)
+	// Done with a millisecond to spare in every slot, so nothing queues up
+	assert.InDelta(t, 1000, script.Latencies.Max(), 10)
+}
+
+func TestWorkerStopsDuringAnIdleSegment(t *testing.T) {
+	r := rand.New(rand.NewSource(1337))
+	clock := &fakeSpaceTimeContinuum{}
+	clock.currentTime = time.Date(2020, 1, 1, 1, 1, 1, 1, time.UTC)
+	start := clock.currentTime
+	w := Worker{
+		workerId: 0,
+		driver:   &fakeDriver{clock: clock, r: r, minLatency: time.Millisecond, maxLatency: time.Millisecond},
+		now:      clock.now,
+		sleep:    clock.sleepUnlessStopped,
+	}
+	w.FollowProfile(&LoadProfile{Segments: []LoadSegment{
+		{From: 0, To: 0, Length: 10 * time.Minute},
+		{From: 10, To: 10, Length: time.Second},
+	}}, 1)
+	stopCh := make(chan struct{})
+	close(stopCh)
+
+	result := w.RunBenchmark(newTestWorkload(r), "", 0, 0, stopCh, nil, NewResultRecorder(0, time.Time{}))
+
+	assert.NoError(t, result.Error)
+	assert.Empty(t, result.Scripts)
+	// Stopped rather than sleeping through the idle segment
+	assert.Equal(t, start, clock.currentTime)
+}
+
+func TestSleepUnlessStopped(t *testing.T) {
+	stopCh := make(chan struct{})
+	assert.True(t, sleepUnlessStopped(time.Millisecond, stopCh))
+	close(stopCh)
+	assert.False(t, sleepUnlessStopped(time.Hour, stopCh))
+}
@@ -0,0 +1,99 @@
+package neobench
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+type LogLevel int
+
+const (
+	LogDebug LogLevel = 0
+	LogInfo  LogLevel = 1
+	LogWarn  LogLevel = 2
+	LogError LogLevel = 3
+)
+
+var logLevelNames = []string{"DEBUG", "INFO", "WARN", "ERROR"}
+
+func (l LogLevel) String() string {
+	if l < LogDebug || l > LogError {
+		return fmt.Sprintf("LogLevel(%d)", int(l))
+	}
+	return logLevelNames[l]
+}
+
+func ParseLogLevel(raw string) (LogLevel, error) {
+	for i, name := range logLevelNames {
+		if strings.EqualFold(raw, name) {
+			return LogLevel(i), nil
+		}
+	}
+	return LogInfo, fmt.Errorf("invalid log level '%s', needs to be one of 'debug', 'info', 'warn' or 'error'", raw)
+}
+
+// Logs neobench's own diagnostics, like connection details and worker lifecycle; benchmark results go to
+// Output, never here. Safe for concurrent use. A nil logger discards everything, so components that can
+// log don't need one in tests.
+type Logger struct {
+	level LogLevel
+	out   io.Writer
+	now   func() time.Time
+	exit  func(code int)
+
+	mut sync.Mutex
+}
+
+func NewLogger(out io.Writer, level LogLevel) *Logger {
+	return &Logger{
+		level: level,
+		out:   out,
+		now:   time.Now,
+		exit:  os.Exit,
+	}
+}
+
+func (l *Logger) Debugf(format string, a ...interface{}) {
+	l.logf(LogDebug, format, a...)
+}
+
+func (l *Logger) Infof(format string, a ...interface{}) {
+	l.logf(LogInfo, format, a...)
+}
+
+func (l *Logger) Warnf(format string, a ...interface{}) {
+	l.logf(LogWarn, format, a...)
+}
+
+func (l *Logger) Errorf(format string, a ...interface{}) {
+	l.logf(LogError, format, a...)
+}
+
+// Logs at error level, whatever the configured level, and exits with status 1
+func (l *Logger) Fatalf(format string, a ...interface{}) {
+	if l == nil {
+		os.Exit(1)
+	}
+	l.write(LogError, format, a...)
+	l.exit(1)
+}
+
+func (l *Logger) logf(level LogLevel, format string, a ...interface{}) {
+	if l == nil || level < l.level {
+		return
+	}
+	l.write(level, format, a...)
+}
+
+func (l *Logger) write(level LogLevel, format string, a ...interface{}) {
+	l.mut.Lock()
+	defer l.mut.Unlock()
+	_, err := fmt.Fprintf(l.out, "%s %-5s %s\n", l.now().Format(time.RFC3339), level, fmt.Sprintf(format, a...))
+	if err != nil {
+		panic(err)
+	}
+}
@@ -0,0 +1,51 @@
+package neobench
+
+import (
+	"bytes"
+	"github.com/stretchr/testify/assert"
+	"testing"
+	"time"
+)
+
+func TestLoggerFiltersByLevel(t *testing.T) {
+	out := &bytes.Buffer{}
+	l := NewLogger(out, LogWarn)
+	l.now = func() time.Time { return time.Date(2020, 1, 1, 1, 1, 1, 0, time.UTC) }
+
+	l.Debugf("connecting to %s", "localhost")
+	l.Infof("connected")
+	l.Warnf("slow connection: %dms", 500)
+	l.Errorf("connection lost")
+
+	assert.Equal(t, "2020-01-01T01:01:01Z WARN  slow connection: 500ms\n"+
+		"2020-01-01T01:01:01Z ERROR connection lost\n", out.String())
+}
+
+func TestLoggerFatalAlwaysLogs(t *testing.T) {
+	out := &bytes.Buffer{}
+	exitCode := -1
+	l := NewLogger(out, LogError)
+	l.exit = func(code int) { exitCode = code }
+
+	l.Fatalf("failed")
+
+	assert.Contains(t, out.String(), "ERROR failed")
+	assert.Equal(t, 1, exitCode)
+}
+
+func TestNilLoggerDiscards(t *testing.T) {
+	var l *Logger
+	l.Debugf("nothing happens")
+	l.Errorf("nothing happens")
+}
+
+func TestParseLogLevel(t *testing.T) {
+	level, err := ParseLogLevel("debug")
+	assert.NoError(t, err)
+	assert.Equal(t, LogDebug, level)
+	level, err = ParseLogLevel("WARN")
+	assert.NoError(t, err)
+	assert.Equal(t, LogWarn, level)
+	_, err = ParseLogLevel("verbose")
+	assert.EqualError(t, err, "invalid log level 'verbose', needs to be one of 'debug', 'info', 'warn' or 'error'")
+}
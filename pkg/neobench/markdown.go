@@ -0,0 +1,257 @@
+package neobench
+
+import (
+	"fmt"
+	"io"
+	"strings"
+	"time"
+)
+
+// Writes results as GitHub-flavored Markdown on OutStream, see -o markdown, for pasting into PR descriptions
+// and issue comments: the scenario, a table by script with tps and latency percentiles, and the errors, if
+// any. Progress and errors go to ErrStream as plain text, like for the CSV output.
+type MarkdownOutput struct {
+	ErrStream io.Writer
+	OutStream io.Writer
+	// See --percentiles
+	Percentiles Percentiles
+	// See --tag
+	Tags Tags
+	// Used to rate-limit progress reporting
+	LastProgressReport ProgressReport
+	LastProgressTime   time.Time
+
+	databaseName string
+}
+
+func (o *MarkdownOutput) BenchmarkStart(databaseName, url, scenario string) {
+	if databaseName == "" {
+		databaseName = "<default>"
+	}
+	o.databaseName = databaseName
+	_, err := fmt.Fprintf(o.ErrStream,
+		"Starting workload on database %s against %s\n"+
+			"Scenario: %s\n", databaseName, url, scenario)
+	if err != nil {
+		panic(err)
+	}
+}
+
+func (o *MarkdownOutput) ReportInitProgress(report ProgressReport) {
+	now := time.Now()
+	if report.Section == o.LastProgressReport.Section && report.Step == o.LastProgressReport.Step && now.Sub(o.LastProgressTime).Seconds() < 10 {
+		return
+	}
+	o.LastProgressReport = report
+	o.LastProgressTime = now
+	_, err := fmt.Fprintf(o.ErrStream, "[%s][%s] %.02f%%\n", report.Section, report.Step, report.Completeness*100)
+	if err != nil {
+		panic(err)
+	}
+}
+
+func (o *MarkdownOutput) ReportWorkloadProgress(completeness float64, checkpoint Result) {
+	_, err := fmt.Fprintf(o.ErrStream, "[workload] %.02f%% done\n", completeness*100)
+	if err != nil {
+		panic(err)
+	}
+}
+
+func (o *MarkdownOutput) ReportThroughput(result Result) {
+	o.writeResult(result, "throughput")
+}
+
+func (o *MarkdownOutput) ReportLatency(result Result) {
+	o.writeResult(result, "latency")
+}
+
+func (o *MarkdownOutput) writeResult(result Result, mode string) {
+	s := strings.Builder{}
+	s.WriteString("### neobench results\n\n")
+	s.WriteString(fmt.Sprintf("- Scenario: %s\n", markdownCode(result.Scenario)))
+	s.WriteString(fmt.Sprintf("- Database: %s\n", markdownCode(o.databaseName)))
+	o.writeTags(&s)
+	if result.Metadata != nil {
+		s.WriteString(fmt.Sprintf("- Run: %s\n", markdownEscape(result.Metadata.summary())))
+	}
+	s.WriteString(fmt.Sprintf("- Mode: %s\n", mode))
+	if result.Aborted != "" {
+		s.WriteString(fmt.Sprintf("- Aborted: %s; these results are partial\n", markdownEscape(result.Aborted)))
+	}
+	if result.Settled != "" {
+		s.WriteString(fmt.Sprintf("- Stopped early: %s\n", markdownEscape(result.Settled)))
+	}
+	s.WriteString("\n")
+
+	percentiles := o.Percentiles.or(Percentiles{50, 95, 99}).below(100)
+	header := []string{"script", "tps", "succeeded", "failed"}
+	align := []string{"---", "---:", "---:", "---:"}
+	for _, q := range percentiles {
+		header = append(header, fmt.Sprintf("p%s (ms)", percentileLabel(q)))
+		align = append(align, "---:")
+	}
+	header = append(header, "max (ms)")
+	align = append(align, "---:")
+	rows := [][]string{header, align}
+
+	scripts := sortedScripts(result)
+	if len(scripts) > 1 {
+		scripts = append(scripts, &ScriptResult{
+			ScriptName: "**total**",
+			Succeeded:  result.TotalSucceeded(),
+			Failed:     result.TotalFailed(),
+			Rate:       result.TotalRate(),
+			Latencies:  result.CombinedLatencies(),
+		})
+	}
+	for _, script := range scripts {
+		row := []string{
+			markdownEscape(script.ScriptName),
+			fmt.Sprintf("%.3f", script.Rate),
+			fmt.Sprintf("%d", script.Succeeded),
+			fmt.Sprintf("%d", script.Failed),
+		}
+		for _, q := range percentiles {
+			row = append(row, formatQuantile(script, q))
+		}
+		if script.Succeeded > 0 {
+			row = append(row, fmt.Sprintf("%.3f", float64(script.Latencies.Max())/1000.0))
+		} else {
+			row = append(row, "-")
+		}
+		rows = append(rows, row)
+	}
+	writeMarkdownTable(rows, &s)
+	if stats, ok := throughputStats(result.ThroughputSamples); ok {
+		s.WriteString(fmt.Sprintf("\nThroughput: mean %.3f tps, stddev %.3f, 95%% CI %.3f to %.3f, over %d samples of %s\n",
+			stats.Mean, stats.Stddev, stats.Low, stats.High, stats.Samples, ThroughputSampleInterval))
+	}
+	if mode == "throughput" && result.TotalSucceeded() > 0 {
+		s.WriteString("\n_" + markdownEscape(strings.TrimSpace(closedLoopNote)) + "_\n")
+	}
+
+	if result.TotalFailed() > 0 {
+		s.WriteString(fmt.Sprintf("\n%d of %d transactions failed:\n\n", result.TotalFailed(), result.TotalFailed()+result.TotalSucceeded()))
+		rows := [][]string{{"error", "count", "example"}, {"---", "---:", "---"}}
+		for _, name := range sortedFailureGroups(result) {
+			group := result.FailedByErrorGroup[name]
+			example := ""
+			if group.FirstFailure != nil {
+				example = group.FirstFailure.Error()
+			}
+			rows = append(rows, []string{markdownCode(name), fmt.Sprintf("%d", group.Count), markdownEscape(example)})
+		}
+		writeMarkdownTable(rows, &s)
+	}
+	s.WriteString("\n")
+
+	if _, err := fmt.Fprint(o.OutStream, s.String()); err != nil {
+		panic(err)
+	}
+}
+
+func (o *MarkdownOutput) ReportSweep(sweep SweepResult) {
+	s := strings.Builder{}
+	s.WriteString("### neobench sweep\n\n")
+	s.WriteString(fmt.Sprintf("- Scenario: %s\n", markdownCode(sweep.Scenario)))
+	if sweep.MaxRateCondition != "" {
+		s.WriteString(fmt.Sprintf("- %s\n", markdownEscape(maxRateHeadline(sweep))))
+	}
+	o.writeTags(&s)
+	s.WriteString("\n")
+
+	variable := markdownCode(sweep.Variable)
+	if sweep.RateSweep {
+		variable = "target (tps)"
+	}
+	rows := [][]string{
+		{variable, "tps", "succeeded", "failed", "p50 (ms)", "p99 (ms)"},
+		{"---:", "---:", "---:", "---:", "---:", "---:"},
+	}
+	for i, result := range sweep.Results {
+		total := &ScriptResult{Succeeded: result.TotalSucceeded(), Latencies: result.CombinedLatencies()}
+		rows = append(rows, []string{
+			markdownEscape(fmt.Sprintf("%v", sweep.Values[i])),
+			fmt.Sprintf("%.3f", result.TotalRate()),
+			fmt.Sprintf("%d", result.TotalSucceeded()),
+			fmt.Sprintf("%d", result.TotalFailed()),
+			formatQuantile(total, 50),
+			formatQuantile(total, 99),
+		})
+	}
+	writeMarkdownTable(rows, &s)
+	s.WriteString("\n")
+
+	if sweep.Runs {
+		summary := SummarizeRuns(sweep.Results)
+		rows = [][]string{
+			{"across runs", "tps", "p50 (ms)", "p99 (ms)"},
+			{"---", "---:", "---:", "---:"},
+			{"mean", fmt.Sprintf("%.3f", summary.Rate.Mean), fmt.Sprintf("%.3f", summary.P50.Mean), fmt.Sprintf("%.3f", summary.P99.Mean)},
+			{"min", fmt.Sprintf("%.3f", summary.Rate.Min), fmt.Sprintf("%.3f", summary.P50.Min), fmt.Sprintf("%.3f", summary.P99.Min)},
+			{"max", fmt.Sprintf("%.3f", summary.Rate.Max), fmt.Sprintf("%.3f", summary.P50.Max), fmt.Sprintf("%.3f", summary.P99.Max)},
+			{"stddev", fmt.Sprintf("%.3f", summary.Rate.Stddev), fmt.Sprintf("%.3f", summary.P50.Stddev), fmt.Sprintf("%.3f", summary.P99.Stddev)},
+		}
+		writeMarkdownTable(rows, &s)
+		s.WriteString("\n")
+	}
+
+	if _, err := fmt.Fprint(o.OutStream, s.String()); err != nil {
+		panic(err)
+	}
+}
+
+func (o *MarkdownOutput) writeTags(s *strings.Builder) {
+	if len(o.Tags) == 0 {
+		return
+	}
+	tags := make([]string, 0, len(o.Tags))
+	for _, tag := range o.Tags {
+		tags = append(tags, markdownCode(tag.Key+"="+tag.Value))
+	}
+	s.WriteString(fmt.Sprintf("- Tags: %s\n", strings.Join(tags, ", ")))
+}
+
+func (o *MarkdownOutput) Noticef(format string, a ...interface{}) {
+	_, err := fmt.Fprintf(o.ErrStream, "%s\n", fmt.Sprintf(format, a...))
+	if err != nil {
+		panic(err)
+	}
+}
+
+func (o *MarkdownOutput) Errorf(format string, a ...interface{}) {
+	_, err := fmt.Fprintf(o.ErrStream, "ERROR: %s\n", fmt.Sprintf(format, a...))
+	if err != nil {
+		panic(err)
+	}
+}
+
+// The first row is the header, the second the alignment row
+func writeMarkdownTable(rows [][]string, s *strings.Builder) {
+	for _, row := range rows {
+		s.WriteString("| ")
+		s.WriteString(strings.Join(row, " | "))
+		s.WriteString(" |\n")
+	}
+}
+
+// Pipes end table cells and line breaks end rows, wherever they are
+func markdownEscape(text string) string {
+	return strings.NewReplacer("|", "\\|", "\n", " ").Replace(text)
+}
+
+// As inline code, with as many backticks around it as it takes for the ones in text not to end it
+func markdownCode(text string) string {
+	text = strings.TrimSpace(text)
+	fence := "`"
+	for strings.Contains(text, fence) {
+		fence += "`"
+	}
+	if fence == "`" {
+		return fence + markdownEscape(text) + fence
+	}
+	// Spaces keep a backtick at either end of text from merging with the fence
+	return fmt.Sprintf("%s %s %s", fence, markdownEscape(text), fence)
+}
+
+var _ Output = &MarkdownOutput{}
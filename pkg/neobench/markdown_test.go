@@ -0,0 +1,39 @@
+package neobench
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMarkdownOutputRendersResultsAsTables(t *testing.T) {
+	w := NewWorkerResult(0)
+	assert.NoError(t, w.record("a", time.Millisecond, uowOutcome{succeeded: true}))
+	assert.NoError(t, w.record("a", 3*time.Millisecond, uowOutcome{succeeded: true}))
+	assert.NoError(t, w.record("b|c", time.Millisecond, uowOutcome{failureGroup: "unknown", err: fmt.Errorf("boom\nmore")}))
+	w.calculateRate(time.Second)
+	result := NewResult("neo4j", " -c 1 -S \"RETURN `x`;\"")
+	assert.NoError(t, result.Add(w))
+
+	out := strings.Builder{}
+	o := &MarkdownOutput{ErrStream: &strings.Builder{}, OutStream: &out}
+	o.BenchmarkStart("", "neo4j://localhost:7687", result.Scenario)
+	o.ReportLatency(result)
+
+	assert.Equal(t, "### neobench results\n\n"+
+		"- Scenario: `` -c 1 -S \"RETURN `x`;\" ``\n"+
+		"- Database: `<default>`\n"+
+		"- Mode: latency\n\n"+
+		"| script | tps | succeeded | failed | p50 (ms) | p95 (ms) | p99 (ms) | max (ms) |\n"+
+		"| --- | ---: | ---: | ---: | ---: | ---: | ---: | ---: |\n"+
+		"| a | 2.000 | 2 | 0 | 1.000 | 3.001 | 3.001 | 3.001 |\n"+
+		"| b\\|c | 1.000 | 0 | 1 | - | - | - | - |\n"+
+		"| **total** | 3.000 | 2 | 1 | 1.000 | 3.001 | 3.001 | 3.001 |\n"+
+		"\n1 of 3 transactions failed:\n\n"+
+		"| error | count | example |\n"+
+		"| --- | ---: | --- |\n"+
+		"| `unknown` | 1 | boom more |\n\n", out.String())
+}
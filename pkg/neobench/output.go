@@ -8,8 +8,13 @@ import (
 	"github.com/prometheus/client_golang/prometheus/promauto"
 	"github.com/prometheus/client_golang/prometheus/promhttp"
 	"io"
+	"io/ioutil"
+	"math"
 	"net/http"
 	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
 	"strings"
 	"time"
 )
@@ -27,8 +32,46 @@ type Result struct {
 
 	FailedByErrorGroup map[string]FailureGroup
 
+	// Lock errors by server error code, including errors the driver retried
+	LockErrors map[string]int64
+
+	// How long transactions waited for the driver to start them, see WorkerResult.AcquireLatencies
+	AcquireLatencies *hdrhistogram.Histogram
+
 	// Results by script
 	Scripts map[string]*ScriptResult
+
+	// Totals for each worker that was added to this result, in the order they were added
+	Workers []WorkerStats
+
+	// If the run had a ramp-up region, results for transactions started during ramp-up; these
+	// are excluded from the headline numbers above
+	Ramp *Result
+
+	// Server-side metrics sampled during the run, if --collect-server-metrics was set
+	ServerMetrics []ServerMetricsSample
+
+	// Names of the two scripts compared, in the order they were given, if --head-to-head was set
+	HeadToHead []string
+
+	// What the scripts that have a :description do, by script name
+	Descriptions map[string]string
+
+	// What the Go runtime in neobench itself was doing during the run, if --diagnose-client was set
+	ClientDiagnostics *ClientDiagnostics
+
+	// Versions, seed, timing and flags of the run; unset for progress checkpoints
+	Metadata *RunMetadata
+
+	// Transactions per second in each ThroughputSampleInterval of the run, after ramp-up, see ThroughputStats
+	ThroughputSamples []float64
+
+	// Why the run was stopped before it was done, if --max-error-rate stopped it; the results cover the run
+	// up to then
+	Aborted string
+
+	// How the throughput settled, if --until-stable stopped the run once it did
+	Settled string
 }
 
 func NewResult(databaseName, scenario string) Result {
@@ -36,6 +79,8 @@ func NewResult(databaseName, scenario string) Result {
 		DatabaseName:       databaseName,
 		Scenario:           scenario,
 		FailedByErrorGroup: make(map[string]FailureGroup),
+		LockErrors:         make(map[string]int64),
+		AcquireLatencies:   newLatencyHistogram(),
 		Scripts:            make(map[string]*ScriptResult),
 	}
 }
@@ -54,6 +99,14 @@ func (r *Result) TotalFailed() (n int64) {
 	return
 }
 
+// Attempts that failed and were tried again, see ScriptResult.Retried
+func (r *Result) TotalRetried() (n int64) {
+	for _, s := range r.Scripts {
+		n += s.Retried
+	}
+	return
+}
+
 func (r *Result) TotalRate() (n float64) {
 	for _, s := range r.Scripts {
 		n += s.Rate
@@ -61,35 +114,155 @@ func (r *Result) TotalRate() (n float64) {
 	return
 }
 
-func (r *Result) Add(res WorkerResult) {
+func (r *Result) TotalWritten() (n WriteVolume) {
+	for _, s := range r.Scripts {
+		n.Rows += s.RowsWritten
+		n.Bytes += s.BytesWritten
+	}
+	return
+}
+
+// Rows and bytes written per second
+func (r *Result) TotalWriteRate() (rows, bytes float64) {
+	for _, s := range r.Scripts {
+		rows += s.RowsWrittenRate
+		bytes += s.BytesWrittenRate
+	}
+	return
+}
+
+// Totals for one worker, across all the scripts it ran
+type WorkerStats struct {
+	WorkerId  int64
+	Succeeded int64
+	Failed    int64
+	Rate      float64
+	Latencies *hdrhistogram.Histogram
+}
+
+// Latencies of all scripts together
+func (r *Result) CombinedLatencies() *hdrhistogram.Histogram {
+	combined := newLatencyHistogram()
+	for _, s := range r.Scripts {
+		if err := mergeHistograms(combined, s.Latencies); err != nil {
+			// Scripts only get histograms from newLatencyHistogram, so this is a bug
+			panic(errors.Wrapf(err, "failed to combine latencies for %s", s.ScriptName))
+		}
+	}
+	return combined
+}
+
+// Merges a worker result into this one; fails if the latency histograms are not compatible
+func (r *Result) Add(res WorkerResult) error {
+	worker := WorkerStats{WorkerId: res.WorkerId, Latencies: newLatencyHistogram()}
 	for _, workerScriptResult := range res.Scripts {
 		combinedScriptResult := r.Scripts[workerScriptResult.ScriptName]
 		if combinedScriptResult == nil {
 			r.Scripts[workerScriptResult.ScriptName] = &ScriptResult{
-				ScriptName: workerScriptResult.ScriptName,
-				Latencies:  hdrhistogram.Import(workerScriptResult.Latencies.Export()),
-				Rate:       workerScriptResult.Rate,
-				Succeeded:  workerScriptResult.Succeeded,
-				Failed:     workerScriptResult.Failed,
+				ScriptName:           workerScriptResult.ScriptName,
+				Latencies:            hdrhistogram.Import(workerScriptResult.Latencies.Export()),
+				ServerLatencies:      importOptionalHistogram(workerScriptResult.ServerLatencies),
+				UncorrectedLatencies: importOptionalHistogram(workerScriptResult.UncorrectedLatencies),
+				Rate:                 workerScriptResult.Rate,
+				Succeeded:            workerScriptResult.Succeeded,
+				Failed:               workerScriptResult.Failed,
+				Retried:              workerScriptResult.Retried,
+				Deadlocks:            workerScriptResult.Deadlocks,
+
+				RowsWritten:      workerScriptResult.RowsWritten,
+				BytesWritten:     workerScriptResult.BytesWritten,
+				RowsWrittenRate:  workerScriptResult.RowsWrittenRate,
+				BytesWrittenRate: workerScriptResult.BytesWrittenRate,
 			}
 		} else {
 			combinedScriptResult.Rate += workerScriptResult.Rate
 			combinedScriptResult.Succeeded += workerScriptResult.Succeeded
 			combinedScriptResult.Failed += workerScriptResult.Failed
-			combinedScriptResult.Latencies.Merge(workerScriptResult.Latencies)
+			if err := mergeOptionalHistogram(&combinedScriptResult.ServerLatencies, workerScriptResult.ServerLatencies); err != nil {
+				return errors.Wrapf(err, "failed to combine server latencies for %s from worker %d", workerScriptResult.ScriptName, res.WorkerId)
+			}
+			if err := mergeOptionalHistogram(&combinedScriptResult.UncorrectedLatencies, workerScriptResult.UncorrectedLatencies); err != nil {
+				return errors.Wrapf(err, "failed to combine uncorrected latencies for %s from worker %d", workerScriptResult.ScriptName, res.WorkerId)
+			}
+			combinedScriptResult.Retried += workerScriptResult.Retried
+			combinedScriptResult.Deadlocks += workerScriptResult.Deadlocks
+			combinedScriptResult.RowsWritten += workerScriptResult.RowsWritten
+			combinedScriptResult.BytesWritten += workerScriptResult.BytesWritten
+			combinedScriptResult.RowsWrittenRate += workerScriptResult.RowsWrittenRate
+			combinedScriptResult.BytesWrittenRate += workerScriptResult.BytesWrittenRate
+			if err := mergeHistograms(combinedScriptResult.Latencies, workerScriptResult.Latencies); err != nil {
+				return errors.Wrapf(err, "failed to combine latencies for %s from worker %d", workerScriptResult.ScriptName, res.WorkerId)
+			}
+		}
+		combinedScriptResult = r.Scripts[workerScriptResult.ScriptName]
+		for _, statement := range workerScriptResult.Statements {
+			combined := combinedScriptResult.getOrCreateStatementResult(Statement{Command: statement.Command, Query: statement.Query})
+			if err := mergeHistograms(combined.Latencies, statement.Latencies); err != nil {
+				return errors.Wrapf(err, "failed to combine latencies for statement %d of %s from worker %d",
+					statement.Command, workerScriptResult.ScriptName, res.WorkerId)
+			}
+		}
+		worker.Succeeded += workerScriptResult.Succeeded
+		worker.Failed += workerScriptResult.Failed
+		worker.Rate += workerScriptResult.Rate
+		if err := mergeHistograms(worker.Latencies, workerScriptResult.Latencies); err != nil {
+			return errors.Wrapf(err, "failed to combine latencies for %s from worker %d", workerScriptResult.ScriptName, res.WorkerId)
 		}
 	}
+	r.Workers = append(r.Workers, worker)
 	for name, group := range res.FailedByErrorGroup {
-		existing, found := r.FailedByErrorGroup[name]
-		if found {
-			r.FailedByErrorGroup[name] = FailureGroup{
-				Count:        existing.Count + group.Count,
-				FirstFailure: existing.FirstFailure,
-			}
-		} else {
-			r.FailedByErrorGroup[name] = group
+		r.FailedByErrorGroup[name] = r.FailedByErrorGroup[name].merge(group)
+	}
+	for code, n := range res.LockErrors {
+		r.LockErrors[code] += n
+	}
+	if res.AcquireLatencies != nil {
+		if err := mergeHistograms(r.AcquireLatencies, res.AcquireLatencies); err != nil {
+			return errors.Wrapf(err, "failed to combine acquire latencies from worker %d", res.WorkerId)
 		}
 	}
+	if res.Ramp != nil {
+		if r.Ramp == nil {
+			ramp := NewResult(r.DatabaseName, r.Scenario)
+			r.Ramp = &ramp
+		}
+		return r.Ramp.Add(*res.Ramp)
+	}
+	return nil
+}
+
+func importOptionalHistogram(histo *hdrhistogram.Histogram) *hdrhistogram.Histogram {
+	if histo == nil {
+		return nil
+	}
+	return hdrhistogram.Import(histo.Export())
+}
+
+// Like mergeHistograms, for histograms that may be nil
+func mergeOptionalHistogram(into **hdrhistogram.Histogram, from *hdrhistogram.Histogram) error {
+	if from == nil {
+		return nil
+	}
+	if *into == nil {
+		*into = newLatencyHistogram()
+	}
+	return mergeHistograms(*into, from)
+}
+
+// hdrhistogram.Merge silently drops or misplaces values if the two histograms have different bounds or
+// precision, so check that they match first
+func mergeHistograms(into, from *hdrhistogram.Histogram) error {
+	if into.LowestTrackableValue() != from.LowestTrackableValue() ||
+		into.HighestTrackableValue() != from.HighestTrackableValue() ||
+		into.SignificantFigures() != from.SignificantFigures() {
+		return fmt.Errorf("histograms have different configurations: [%d, %d] at %d significant figures vs [%d, %d] at %d significant figures",
+			into.LowestTrackableValue(), into.HighestTrackableValue(), into.SignificantFigures(),
+			from.LowestTrackableValue(), from.HighestTrackableValue(), from.SignificantFigures())
+	}
+	if dropped := into.Merge(from); dropped > 0 {
+		return fmt.Errorf("%d values could not be merged", dropped)
+	}
+	return nil
 }
 
 // Result for one script; normally a workload is just one script, but we allow workloads to be made up of
@@ -103,6 +276,65 @@ type ScriptResult struct {
 	Failed    int64
 	Succeeded int64
 	Latencies *hdrhistogram.Histogram
+
+	// Of the transactions that succeeded, the time the server says it spent on their statements, from the
+	// result summaries; the rest of Latencies is the client, the driver and the network. Transactions the
+	// server sent no timings for are left out. Nil for results not recorded by a worker.
+	ServerLatencies *hdrhistogram.Histogram
+
+	// Of the transactions that succeeded, the latency from when they actually started rather than when they
+	// were scheduled to, which is what Latencies has; this is what a load generator would report if it didn't
+	// correct for coordinated omission, see docs/overview.md. Nil for results not recorded by a worker.
+	UncorrectedLatencies *hdrhistogram.Histogram
+
+	// Attempts that hit a transient error and were tried again, whether or not the transaction went on to
+	// succeed; Failed only counts transactions whose last attempt failed. Deadlocks counts the attempts that
+	// failed on a detected deadlock, retried or not.
+	Retried   int64
+	Deadlocks int64
+
+	// Volume written by succeeded transactions, see WriteVolume, and the rate per second of that
+	RowsWritten      int64
+	BytesWritten     int64
+	RowsWrittenRate  float64
+	BytesWrittenRate float64
+
+	// Latencies of each statement in the script, by StatementResult.Command; only set with --statement-latencies
+	Statements map[int]*StatementResult
+}
+
+// Latencies of one statement in a script, across the units of work that succeeded. These cover running the
+// statement and consuming its result; committing the transaction is not part of any statement.
+type StatementResult struct {
+	// Id of the query in the script that emits the statement, see Statement.Command
+	Command int
+	// The query as it ran the first time, for telling statements apart in reports
+	Query     string
+	Latencies *hdrhistogram.Histogram
+}
+
+// Results of running the same scenario once for each of a list of values of one variable, see --sweep
+type SweepResult struct {
+	Scenario string
+	Variable string
+	// Values[i] is the value of Variable that produced Results[i]
+	Values      []interface{}
+	Results     []Result
+	LatencyMode bool
+	// Set for --rate-sweep and --step-rate; Values are then target rates as float64, in total transactions per second
+	RateSweep bool
+	// Set for --step-clients; Values are then client counts as int64, and Variable is "clients"
+	ClientSteps bool
+	// Set for --phases; Values are then the names of the phases, and Variable is "phase"
+	Phases bool
+	// Set for --runs; Values are then run numbers as int64, from 1, and Variable is "run"
+	Runs bool
+	// Set for --alternate; Values are then the names of the two configurations, and Variable is "configuration"
+	Alternate bool
+	// Set for --find-max-rate, to the condition as given; MaxRate is the highest rate that met it, zero if none did,
+	// and Values are the rates tried, in the order they ran
+	MaxRateCondition string
+	MaxRate          float64
 }
 
 type Output interface {
@@ -116,57 +348,286 @@ type Output interface {
 	ReportThroughput(result Result)
 	// Called at workload completion if running in Latency mode; this is the final result
 	ReportLatency(result Result)
+	// Called when running with --sweep, after each run has been reported with ReportThroughput or ReportLatency
+	ReportSweep(sweep SweepResult)
 	// Called if the workload or setup fails
 	Errorf(format string, a ...interface{})
 }
 
-// Creates the output specified by name; if prometheusAddress is set, also starts
-// that as an output, returning an output that publishes to both
-// TODO(jake): Maybe this would be nicer with `name` a comma-separated list, eg. csv,prometheus
-func InitOutput(name, prometheusAddress string) (Output, error) {
+// Implemented by outputs that can add a table by worker to what they report, for --worker-stats; the others
+// leave it out
+type WorkerStatsOutput interface {
+	ShowWorkerStats()
+}
+
+// Implemented by outputs that tell whoever runs neobench about things that aren't errors, like a run stopping
+// early once it settled; see Noticef
+type NoticeOutput interface {
+	Noticef(format string, a ...interface{})
+}
+
+// Sends the message to the output if it takes notices; the others only report errors
+func Noticef(out Output, format string, a ...interface{}) {
+	if notices, ok := out.(NoticeOutput); ok {
+		notices.Noticef(format, a...)
+	}
+}
+
+// Where results go, from the command line; anything left empty is not used
+type OutputConfig struct {
+	// See -o and --output-file; if none of these go to stdout, the auto format is written there as well
+	Sinks []OutputSink
+	// See --percentiles; if empty, each output reports its usual percentiles
+	Percentiles Percentiles
+	// See --tag; every output attaches these to what it writes
+	Tags Tags
+	// See --worker-stats; adds a table by worker to the interactive output
+	WorkerStats bool
+	// See --quiet; rather than the auto format, stdout gets a summary line per run, and no sink can go there
+	Quiet bool
+	// See --prometheus
+	PrometheusAddress string
+	// See --pushgateway and --pushgateway-job
+	PushgatewayURL string
+	PushgatewayJob string
+	// See --influx and --influx-token
+	InfluxTarget string
+	InfluxToken  string
+	// See --statsd and --statsd-prefix
+	StatsdAddress string
+	StatsdPrefix  string
+	// See --grafana-snapshot
+	GrafanaSnapshotPath string
+	// See --html-report
+	HtmlReportPath string
+	// See --hgrm
+	HgrmPrefix string
+	// See --junit; failures in the report include breaches of SLA
+	JUnitPath string
+	SLA       SLA
+}
+
+// Where -o writes a results format
+type OutputSink struct {
+	// 'auto', 'interactive', 'tui', 'csv', 'csv-append', 'json' or 'markdown'
+	Format string
+	// File to write to, or empty for stdout
+	Path string
+}
+
+// Parses -o values, a format optionally followed by the file to write it to, ex: json:results.json
+func ParseOutputSink(spec string) OutputSink {
+	parts := strings.SplitN(spec, ":", 2)
+	if len(parts) == 1 {
+		return OutputSink{Format: parts[0]}
+	}
+	return OutputSink{Format: parts[0], Path: parts[1]}
+}
+
+// Creates the outputs specified by the config's Sinks, and any other outputs configured alongside them, returning
+// an output that publishes to all of them
+func InitOutput(config OutputConfig) (Output, error) {
+	sinks := config.Sinks
+	onStdout := 0
+	for _, sink := range sinks {
+		if sink.Path == "" {
+			onStdout++
+		}
+	}
+	if config.Quiet && onStdout > 0 {
+		return nil, fmt.Errorf("--quiet only prints a summary line on stdout, write the other outputs to files, ex: -o json:results.json")
+	}
+	if onStdout > 1 {
+		return nil, fmt.Errorf("only one output can be written to stdout, write the others to files, ex: -o json:results.json")
+	}
+	if onStdout == 0 && !config.Quiet {
+		// Results only go to files; whoever is at the terminal still wants to see how things are going
+		sinks = append([]OutputSink{{Format: "auto"}}, sinks...)
+	}
+
+	delegates := make([]Output, 0, len(sinks)+1)
+	if config.Quiet {
+		delegates = append(delegates, &QuietOutput{ErrStream: os.Stderr, OutStream: os.Stdout, Percentiles: config.Percentiles})
+	}
+	for _, sink := range sinks {
+		output, err := newSinkOutput(sink, config.Percentiles, config.Tags)
+		if err != nil {
+			return nil, err
+		}
+		delegates = append(delegates, output)
+	}
+	if config.WorkerStats {
+		for _, output := range delegates {
+			if workerStats, ok := output.(WorkerStatsOutput); ok {
+				workerStats.ShowWorkerStats()
+			}
+		}
+	}
+	if config.PrometheusAddress != "" {
+		InitPrometheus(config.PrometheusAddress)
+		delegates = append(delegates, NewPrometheusOutput(config.Tags))
+	}
+	if config.PushgatewayURL != "" {
+		pushgateway, err := NewPushgatewayOutput(config.PushgatewayURL, config.PushgatewayJob, config.Tags, os.Stderr)
+		if err != nil {
+			return nil, err
+		}
+		delegates = append(delegates, pushgateway)
+	}
+	if config.InfluxTarget != "" {
+		influx, err := NewInfluxOutput(config.InfluxTarget, config.InfluxToken, os.Stderr)
+		if err != nil {
+			return nil, err
+		}
+		influx.Tags = config.Tags
+		delegates = append(delegates, influx)
+	}
+	if config.StatsdAddress != "" {
+		statsd, err := NewStatsdOutput(config.StatsdAddress, config.StatsdPrefix, os.Stderr)
+		if err != nil {
+			return nil, err
+		}
+		statsd.Tags = config.Tags
+		delegates = append(delegates, statsd)
+	}
+	if config.GrafanaSnapshotPath != "" {
+		grafana := NewGrafanaSnapshotOutput(config.GrafanaSnapshotPath)
+		grafana.Tags = config.Tags
+		delegates = append(delegates, grafana)
+	}
+	if config.HtmlReportPath != "" {
+		html := NewHtmlReportOutput(config.HtmlReportPath)
+		html.Tags = config.Tags
+		delegates = append(delegates, html)
+	}
+	if config.HgrmPrefix != "" {
+		delegates = append(delegates, &HgrmOutput{Prefix: config.HgrmPrefix})
+	}
+	if config.JUnitPath != "" {
+		junit := NewJUnitOutput(config.JUnitPath, config.SLA)
+		junit.Percentiles = config.Percentiles
+		junit.Tags = config.Tags
+		delegates = append(delegates, junit)
+	}
+	if len(delegates) == 1 {
+		return delegates[0], nil
+	}
+	return &CombinedOutput{
+		delegates: delegates,
+	}, nil
+}
+
+func newSinkOutput(sink OutputSink, percentiles Percentiles, tags Tags) (Output, error) {
+	name := sink.Format
 	if name == "auto" {
-		fi, _ := os.Stdout.Stat()
-		if fi.Mode()&os.ModeCharDevice == 0 {
+		if sink.Path != "" {
+			name = formatForPath(sink.Path)
+		} else if fi, _ := os.Stdout.Stat(); fi.Mode()&os.ModeCharDevice == 0 {
 			name = "csv"
 		} else {
 			name = "interactive"
 		}
 	}
+	if name != "interactive" && name != "csv" && name != "csv-append" && name != "tui" && name != "json" && name != "markdown" {
+		return nil, fmt.Errorf("unknown output format: %s, supported formats are 'auto', 'interactive', 'tui', 'csv', 'csv-append', 'json' and 'markdown'", name)
+	}
+	if name == "csv-append" {
+		// Appends to the file rather than creating it, so it's handled apart from the other formats
+		if sink.Path == "" {
+			return nil, fmt.Errorf("csv-append adds a row to a file, give it the file to append to, ex: -o csv-append:runs.csv")
+		}
+		return NewCsvAppendOutput(sink.Path, percentiles, tags, os.Stderr)
+	}
 
-	var output Output
-	if name == "interactive" {
-		output = &InteractiveOutput{
-			ErrStream: os.Stderr,
-			OutStream: os.Stdout,
+	var errStream, outStream io.Writer = os.Stderr, os.Stdout
+	if sink.Path != "" {
+		if name == "tui" {
+			return nil, fmt.Errorf("the tui output is a live dashboard, it can't be written to a file: %s", sink.Path)
 		}
-	} else if name == "csv" {
-		output = &CsvOutput{
-			ErrStream: os.Stderr,
-			OutStream: os.Stdout,
+		file, err := os.Create(sink.Path)
+		if err != nil {
+			return nil, errors.Wrapf(err, "failed to open output file")
 		}
-	} else {
-		return nil, fmt.Errorf("unknown output format: %s, supported formats are 'auto', 'interactive' and 'csv'", name)
+		// Progress and errors are for the terminal, the output on stdout has them
+		errStream, outStream = ioutil.Discard, file
 	}
 
-	if prometheusAddress != "" {
-		InitPrometheus(prometheusAddress)
-		output = &CombinedOutput{
-			delegates: []Output{output, NewPrometheusOutput()},
-		}
+	switch name {
+	case "interactive":
+		return &InteractiveOutput{ErrStream: errStream, OutStream: outStream, Percentiles: percentiles, Tags: tags}, nil
+	case "csv":
+		return &CsvOutput{ErrStream: errStream, OutStream: outStream, Percentiles: percentiles, Tags: tags}, nil
+	case "tui":
+		tui := NewTuiOutput(errStream, outStream)
+		tui.Percentiles = percentiles
+		tui.Tags = tags
+		return tui, nil
+	case "markdown":
+		return &MarkdownOutput{ErrStream: errStream, OutStream: outStream, Percentiles: percentiles, Tags: tags}, nil
+	default:
+		return &JsonOutput{ErrStream: errStream, OutStream: outStream, Percentiles: percentiles, Tags: tags}, nil
 	}
+}
 
-	return output, nil
+// The format --output-file writes, going by the extension of the path
+func formatForPath(path string) string {
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".json":
+		return "json"
+	case ".csv":
+		return "csv"
+	case ".md":
+		return "markdown"
+	default:
+		return "interactive"
+	}
 }
 
 type InteractiveOutput struct {
 	ErrStream io.Writer
 	OutStream io.Writer
+	// See --percentiles
+	Percentiles Percentiles
+	// See --tag
+	Tags Tags
+	// See --worker-stats
+	WorkerStats bool
 	// Used to rate-limit progress reporting
 	LastProgressReport ProgressReport
 	LastProgressTime   time.Time
+
+	// Latency at each progress report of the current run, for the chart in the results
+	start          time.Time
+	latencyHistory []latencyPoint
+	// Defaults to time.Now
+	now func() time.Time
+}
+
+func writeTagsLine(tags Tags, s *strings.Builder) {
+	if len(tags) > 0 {
+		s.WriteString(fmt.Sprintf("Tags: %s\n", tags))
+	}
+}
+
+func writeStoppedEarlyLine(result Result, s *strings.Builder) {
+	if result.Aborted != "" {
+		s.WriteString(fmt.Sprintf("Aborted: %s; these results are partial\n", result.Aborted))
+	}
+	if result.Settled != "" {
+		s.WriteString(fmt.Sprintf("Stopped early: %s\n", result.Settled))
+	}
+}
+
+func (o *InteractiveOutput) clock() time.Time {
+	if o.now == nil {
+		return time.Now()
+	}
+	return o.now()
 }
 
 func (o *InteractiveOutput) BenchmarkStart(databaseName, url, scenario string) {
+	o.start = o.clock()
+	o.latencyHistory = nil
 	if databaseName == "" {
 		databaseName = "<default>"
 	}
@@ -179,6 +640,9 @@ func (o *InteractiveOutput) BenchmarkStart(databaseName, url, scenario string) {
 }
 
 func (o *InteractiveOutput) ReportWorkloadProgress(completeness float64, checkpoint Result) {
+	if point, ok := newLatencyPoint(o.clock().Sub(o.start), checkpoint); ok {
+		o.latencyHistory = append(o.latencyHistory, point)
+	}
 	_, err := fmt.Fprintf(o.ErrStream, "[%.02f%%] %.02f tps / %d failures\n", completeness*100, checkpoint.TotalRate(), checkpoint.TotalFailed())
 	if err != nil {
 		panic(err)
@@ -203,15 +667,37 @@ func (o *InteractiveOutput) ReportThroughput(result Result) {
 
 	s.WriteString("== Results ==\n")
 	s.WriteString(fmt.Sprintf("Scenario: %s\n", result.Scenario))
+	writeTagsLine(o.Tags, &s)
+	writeStoppedEarlyLine(result, &s)
 	s.WriteString(fmt.Sprintf("%d successful transactions, %d failed. (Total of %.3f per second)\n", result.TotalSucceeded(), result.TotalFailed(), result.TotalRate()))
-	s.WriteString("\n")
-	for _, script := range result.Scripts {
-		s.WriteString(fmt.Sprintf("  [%s]: %.03f total transactions per second\n", script.ScriptName, script.Rate))
+	writeThroughputStats(result, &s)
+	if len(result.Scripts) == 1 {
+		writeSingleScript(result, o.Percentiles, &s)
+	}
+	writeScriptBreakdown(result, o.Percentiles, &s)
+	if result.TotalSucceeded() > 0 {
+		s.WriteString(closedLoopNote)
+	}
+	writeStatementReport(result, &s)
+	writeServerTimeReport(result, &s)
+	if o.WorkerStats {
+		writeWorkerReport(result, o.Percentiles, &s)
+	}
+	if len(o.latencyHistory) > 1 {
+		s.WriteString("\n")
+		writeLatencyChart(o.latencyHistory, &s)
 	}
 	s.WriteString("\n")
 	writeErrorReport(result, &s)
+	writeLockReport(result, &s)
+	writeAcquireReport(result, &s)
+	writeHeadToHeadReport(result, &s)
+	writeIngestReport(result, &s)
+	writeRampReport(result, &s)
+	writeServerMetricsReport(result, &s)
+	writeClientDiagnosticsReport(result, &s)
 
-	_, err := fmt.Fprintf(o.OutStream, s.String())
+	_, err := fmt.Fprint(o.OutStream, s.String())
 	if err != nil {
 		panic(err)
 	}
@@ -223,17 +709,42 @@ func (o *InteractiveOutput) ReportLatency(result Result) {
 	s.WriteString("== Results ==\n")
 
 	s.WriteString(fmt.Sprintf("Scenario: %s\n", result.Scenario))
+	writeTagsLine(o.Tags, &s)
+	writeStoppedEarlyLine(result, &s)
 	s.WriteString(fmt.Sprintf("%d successful transactions, %d failed. (Total of %.3f per second)\n", result.TotalSucceeded(), result.TotalFailed(), result.TotalRate()))
+	writeThroughputStats(result, &s)
+
+	writeScriptBreakdown(result, o.Percentiles, &s)
+	writeStatementReport(result, &s)
+	writeServerTimeReport(result, &s)
+	if o.WorkerStats {
+		writeWorkerReport(result, o.Percentiles, &s)
+	}
 
 	if result.TotalSucceeded() > 0 {
-		for _, workload := range result.Scripts {
+		for _, workload := range sortedScripts(result) {
 			s.WriteString("\n")
 			s.WriteString(fmt.Sprintf("-- Script: %s --\n\n", workload.ScriptName))
-			summarizeLatency(workload, &s, "  ")
+			if description := result.Descriptions[workload.ScriptName]; description != "" {
+				s.WriteString(fmt.Sprintf("  %s\n\n", description))
+			}
+			summarizeLatency(workload, o.Percentiles, &s, "  ")
 		}
 	}
+	writeCoordinatedOmissionReport(result, o.Percentiles, &s)
+	if len(o.latencyHistory) > 1 {
+		s.WriteString("\n")
+		writeLatencyChart(o.latencyHistory, &s)
+	}
 	s.WriteString("\n")
 	writeErrorReport(result, &s)
+	writeLockReport(result, &s)
+	writeAcquireReport(result, &s)
+	writeHeadToHeadReport(result, &s)
+	writeIngestReport(result, &s)
+	writeRampReport(result, &s)
+	writeServerMetricsReport(result, &s)
+	writeClientDiagnosticsReport(result, &s)
 
 	_, err := fmt.Fprint(o.OutStream, s.String())
 	if err != nil {
@@ -241,7 +752,448 @@ func (o *InteractiveOutput) ReportLatency(result Result) {
 	}
 }
 
-func summarizeLatency(script *ScriptResult, s *strings.Builder, indent string) {
+func (o *InteractiveOutput) ReportSweep(sweep SweepResult) {
+	s := strings.Builder{}
+
+	if sweep.RateSweep {
+		writeRateSweepReport(sweep, &s)
+		if _, err := fmt.Fprint(o.OutStream, s.String()); err != nil {
+			panic(err)
+		}
+		return
+	}
+	if sweep.Alternate {
+		writeAlternateReport(sweep, &s)
+		if _, err := fmt.Fprint(o.OutStream, s.String()); err != nil {
+			panic(err)
+		}
+		return
+	}
+	if sweep.Runs {
+		writeRunsReport(sweep, &s)
+		if _, err := fmt.Fprint(o.OutStream, s.String()); err != nil {
+			panic(err)
+		}
+		return
+	}
+
+	if sweep.ClientSteps {
+		s.WriteString("== Client steps ==\n")
+	} else if sweep.Phases {
+		s.WriteString("== Phases ==\n")
+	} else {
+		s.WriteString(fmt.Sprintf("== Sweep over $%s ==\n", sweep.Variable))
+	}
+	s.WriteString(fmt.Sprintf("Scenario: %s\n", sweep.Scenario))
+	writeTagsLine(o.Tags, &s)
+	s.WriteString("\n")
+	header := []string{sweep.Variable, "script", "succeeded", "failed", "tps"}
+	if sweep.LatencyMode {
+		header = append(header, "p50(ms)", "p99(ms)")
+	}
+	rows := [][]string{header}
+	for i, result := range sweep.Results {
+		for _, script := range sortedScripts(result) {
+			row := []string{
+				fmt.Sprintf("%v", sweep.Values[i]),
+				script.ScriptName,
+				fmt.Sprintf("%d", script.Succeeded),
+				fmt.Sprintf("%d", script.Failed),
+				fmt.Sprintf("%.3f", script.Rate),
+			}
+			if sweep.LatencyMode {
+				row = append(row,
+					fmt.Sprintf("%.3f", float64(script.Latencies.ValueAtQuantile(50))/1000.0),
+					fmt.Sprintf("%.3f", float64(script.Latencies.ValueAtQuantile(99))/1000.0))
+			}
+			rows = append(rows, row)
+		}
+	}
+	writeTable(rows, &s)
+
+	_, err := fmt.Fprint(o.OutStream, s.String())
+	if err != nil {
+		panic(err)
+	}
+}
+
+// Below this fraction of the target rate, we say the server did not keep up
+const rateSweepSaturated = 0.95
+
+// The throughput-latency curve: one row per target rate, with latencies across all scripts
+func writeRateSweepReport(sweep SweepResult, s *strings.Builder) {
+	s.WriteString("== Rate sweep ==\n")
+	s.WriteString(fmt.Sprintf("Scenario: %s\n", sweep.Scenario))
+	if sweep.MaxRateCondition != "" {
+		s.WriteString(maxRateHeadline(sweep) + "\n")
+	}
+	s.WriteString("\n")
+	rows := [][]string{{"target(tps)", "achieved(tps)", "succeeded", "failed", "p50(ms)", "p99(ms)", ""}}
+	saturated := false
+	for i, result := range sweep.Results {
+		target := sweep.Values[i].(float64)
+		latencies := result.CombinedLatencies()
+		mark := ""
+		if result.TotalRate() < target*rateSweepSaturated {
+			mark = "*"
+			saturated = true
+		}
+		rows = append(rows, []string{
+			fmt.Sprintf("%.3f", target),
+			fmt.Sprintf("%.3f", result.TotalRate()),
+			fmt.Sprintf("%d", result.TotalSucceeded()),
+			fmt.Sprintf("%d", result.TotalFailed()),
+			fmt.Sprintf("%.3f", float64(latencies.ValueAtQuantile(50))/1000.0),
+			fmt.Sprintf("%.3f", float64(latencies.ValueAtQuantile(99))/1000.0),
+			mark,
+		})
+	}
+	writeTable(rows, s)
+	if saturated {
+		s.WriteString(fmt.Sprintf("\n* below %.0f%% of the target rate; the server did not keep up, "+
+			"so latencies here include time spent queued behind earlier transactions\n", rateSweepSaturated*100))
+	}
+}
+
+// Like "Max sustainable rate: 450.000 tps with p99<20ms"
+func maxRateHeadline(sweep SweepResult) string {
+	if sweep.MaxRate == 0 {
+		return fmt.Sprintf("Max sustainable rate: none of the rates tried met %s", sweep.MaxRateCondition)
+	}
+	return fmt.Sprintf("Max sustainable rate: %.3f tps with %s", sweep.MaxRate, sweep.MaxRateCondition)
+}
+
+// Scripts in a result ordered by name, for reports where a stable order matters
+func sortedScripts(result Result) []*ScriptResult {
+	scripts := make([]*ScriptResult, 0, len(result.Scripts))
+	for _, script := range result.Scripts {
+		scripts = append(scripts, script)
+	}
+	sort.Slice(scripts, func(i, j int) bool {
+		return scripts[i].ScriptName < scripts[j].ScriptName
+	})
+	return scripts
+}
+
+// Writes rows as a plain-text table with left-aligned, space-padded columns; the first row is the header
+func writeTable(rows [][]string, s *strings.Builder) {
+	widths := make([]int, 0)
+	for _, row := range rows {
+		for i, cell := range row {
+			if i >= len(widths) {
+				widths = append(widths, 0)
+			}
+			if len(cell) > widths[i] {
+				widths[i] = len(cell)
+			}
+		}
+	}
+	for _, row := range rows {
+		s.WriteString(" ")
+		for i, cell := range row {
+			s.WriteString(fmt.Sprintf(" %-*s", widths[i], cell))
+		}
+		s.WriteString("\n")
+	}
+}
+
+// Latencies from throughput runs are measured, but each client waits for its transaction before starting the
+// next, so they can't be compared to latencies at a given rate; this goes below them in throughput reports
+const closedLoopNote = "Latencies are closed-loop: each client waits for its last transaction to finish before starting the next, " +
+	"so they leave out queueing. Use --latency for latencies at a given --rate.\n"
+
+// A row per script, so with several scripts in the mix it's clear which is slow or failing; share is the
+// script's part of all transactions, which follows the weights scripts are picked with. If anything was
+// retried, retries and deadlocks get columns of their own, see retryRate. With a single script, the row would
+// only repeat the totals, so there is no table, just the retries, if any. Starts with a blank line, if it writes
+// anything.
+func writeScriptBreakdown(result Result, percentiles Percentiles, s *strings.Builder) {
+	percentiles = percentiles.or(Percentiles{50, 95, 99})
+	total := result.TotalSucceeded() + result.TotalFailed()
+	retries := hasRetries(result)
+	if len(result.Scripts) < 2 {
+		for _, script := range result.Scripts {
+			if retries {
+				s.WriteString(fmt.Sprintf("\n%d retried, a retry rate of %.1f%%, %d deadlocks\n",
+					script.Retried, retryRate(script)*100, script.Deadlocks))
+			}
+		}
+		return
+	}
+	header := []string{"script", "share", "succeeded", "failed"}
+	if retries {
+		header = append(header, "retried", "retry rate", "deadlocks")
+	}
+	header = append(header, "tps")
+	percentiles = percentiles.below(100)
+	for _, q := range percentiles {
+		header = append(header, fmt.Sprintf("p%s(ms)", percentileLabel(q)))
+	}
+	rows := [][]string{append(header, "max(ms)")}
+	for _, script := range sortedScripts(result) {
+		share := 0.0
+		if total > 0 {
+			share = float64(script.Succeeded+script.Failed) / float64(total)
+		}
+		max := "-"
+		if script.Succeeded > 0 {
+			max = fmt.Sprintf("%.3f", float64(script.Latencies.Max())/1000.0)
+		}
+		row := []string{
+			script.ScriptName,
+			fmt.Sprintf("%.1f%%", share*100),
+			fmt.Sprintf("%d", script.Succeeded),
+			fmt.Sprintf("%d", script.Failed),
+		}
+		if retries {
+			row = append(row,
+				fmt.Sprintf("%d", script.Retried),
+				fmt.Sprintf("%.1f%%", retryRate(script)*100),
+				fmt.Sprintf("%d", script.Deadlocks))
+		}
+		row = append(row, fmt.Sprintf("%.3f", script.Rate))
+		for _, q := range percentiles {
+			row = append(row, formatQuantile(script, q))
+		}
+		rows = append(rows, append(row, max))
+	}
+	s.WriteString("\nBy script:\n")
+	writeTable(rows, s)
+	for _, script := range sortedScripts(result) {
+		if description := result.Descriptions[script.ScriptName]; description != "" {
+			s.WriteString(fmt.Sprintf("  %s: %s\n", script.ScriptName, description))
+		}
+	}
+}
+
+// A row per worker; every worker runs the same mix, so workers far apart usually mean some of them are routed
+// to a slower member or wait on the connection pool, which the merged latencies hide
+func writeWorkerReport(result Result, percentiles Percentiles, s *strings.Builder) {
+	if len(result.Workers) == 0 {
+		return
+	}
+	percentiles = percentiles.or(Percentiles{50, 95, 99}).below(100)
+	header := []string{"worker", "succeeded", "failed", "tps"}
+	for _, q := range percentiles {
+		header = append(header, fmt.Sprintf("p%s(ms)", percentileLabel(q)))
+	}
+	rows := [][]string{append(header, "max(ms)")}
+	workers := make([]WorkerStats, len(result.Workers))
+	copy(workers, result.Workers)
+	sort.Slice(workers, func(i, j int) bool {
+		return workers[i].WorkerId < workers[j].WorkerId
+	})
+	minRate, maxRate := math.Inf(1), math.Inf(-1)
+	minP99, maxP99 := math.Inf(1), math.Inf(-1)
+	for _, worker := range workers {
+		asScript := &ScriptResult{Succeeded: worker.Succeeded, Latencies: worker.Latencies}
+		row := []string{
+			fmt.Sprintf("%d", worker.WorkerId),
+			fmt.Sprintf("%d", worker.Succeeded),
+			fmt.Sprintf("%d", worker.Failed),
+			fmt.Sprintf("%.3f", worker.Rate),
+		}
+		for _, q := range percentiles {
+			row = append(row, formatQuantile(asScript, q))
+		}
+		max := "-"
+		if worker.Succeeded > 0 {
+			max = fmt.Sprintf("%.3f", float64(worker.Latencies.Max())/1000.0)
+			p99 := float64(worker.Latencies.ValueAtQuantile(99)) / 1000.0
+			minP99, maxP99 = math.Min(minP99, p99), math.Max(maxP99, p99)
+		}
+		minRate, maxRate = math.Min(minRate, worker.Rate), math.Max(maxRate, worker.Rate)
+		rows = append(rows, append(row, max))
+	}
+	s.WriteString("\n")
+	s.WriteString("By worker:\n")
+	writeTable(rows, s)
+	if len(workers) > 1 {
+		s.WriteString(fmt.Sprintf("  tps ranges from %.3f to %.3f", minRate, maxRate))
+		if !math.IsInf(minP99, 1) {
+			s.WriteString(fmt.Sprintf(", p99 from %.3fms to %.3fms", minP99, maxP99))
+		}
+		s.WriteString("; workers run the same mix, so a wide spread points at routing or the connection pool\n")
+	}
+}
+
+func hasServerTimes(result Result) bool {
+	for _, script := range result.Scripts {
+		if script.ServerLatencies != nil && script.ServerLatencies.TotalCount() > 0 {
+			return true
+		}
+	}
+	return false
+}
+
+// Splits the mean latency of each script into the time the server says it spent on the statements and the
+// rest, which is neobench, the driver, the network and committing. If the rest is most of it, the client is
+// likely the bottleneck rather than the database.
+func writeServerTimeReport(result Result, s *strings.Builder) {
+	if !hasServerTimes(result) {
+		return
+	}
+	rows := [][]string{{"script", "mean(ms)", "server(ms)", "client(ms)", "server share", "server p50(ms)", "server p99(ms)"}}
+	for _, script := range sortedScripts(result) {
+		server := script.ServerLatencies
+		if server == nil || server.TotalCount() == 0 {
+			continue
+		}
+		mean, serverMean := script.Latencies.Mean()/1000.0, server.Mean()/1000.0
+		share := 0.0
+		if mean > 0 {
+			share = serverMean / mean
+		}
+		rows = append(rows, []string{
+			script.ScriptName,
+			fmt.Sprintf("%.3f", mean),
+			fmt.Sprintf("%.3f", serverMean),
+			fmt.Sprintf("%.3f", mean-serverMean),
+			fmt.Sprintf("%.1f%%", share*100),
+			fmt.Sprintf("%.3f", float64(server.ValueAtQuantile(50))/1000.0),
+			fmt.Sprintf("%.3f", float64(server.ValueAtQuantile(99))/1000.0),
+		})
+	}
+	s.WriteString("\n")
+	s.WriteString("Server vs client time, of successful transactions:\n")
+	writeTable(rows, s)
+}
+
+// Drill-down into the statements of each script, with --statement-latencies; share is each statement's part
+// of the time spent in all statements of its script, so it shows which statement dominates
+func writeStatementReport(result Result, s *strings.Builder) {
+	for _, script := range sortedScripts(result) {
+		if len(script.Statements) == 0 {
+			continue
+		}
+		statements := make([]*StatementResult, 0, len(script.Statements))
+		totalTime := 0.0
+		for _, statement := range script.Statements {
+			statements = append(statements, statement)
+			totalTime += statement.Latencies.Mean() * float64(statement.Latencies.TotalCount())
+		}
+		sort.Slice(statements, func(i, j int) bool {
+			return statements[i].Command < statements[j].Command
+		})
+
+		s.WriteString(fmt.Sprintf("\nStatements in %s:\n", script.ScriptName))
+		rows := [][]string{{"#", "share", "mean(ms)", "p50(ms)", "p95(ms)", "p99(ms)", "max(ms)", "query"}}
+		for i, statement := range statements {
+			histo := statement.Latencies
+			share := 0.0
+			if totalTime > 0 {
+				share = histo.Mean() * float64(histo.TotalCount()) / totalTime
+			}
+			rows = append(rows, []string{
+				fmt.Sprintf("%d", i+1),
+				fmt.Sprintf("%.1f%%", share*100),
+				fmt.Sprintf("%.3f", histo.Mean()/1000.0),
+				fmt.Sprintf("%.3f", float64(histo.ValueAtQuantile(50))/1000.0),
+				fmt.Sprintf("%.3f", float64(histo.ValueAtQuantile(95))/1000.0),
+				fmt.Sprintf("%.3f", float64(histo.ValueAtQuantile(99))/1000.0),
+				fmt.Sprintf("%.3f", float64(histo.Max())/1000.0),
+				abbreviateQuery(statement.Query),
+			})
+		}
+		writeTable(rows, s)
+	}
+}
+
+// The query on one line, cut short if it's long
+func abbreviateQuery(query string) string {
+	short := strings.Join(strings.Fields(query), " ")
+	if len(short) > 60 {
+		short = short[:57] + "..."
+	}
+	return short
+}
+
+// Latency percentiles to report, see --percentiles; where none are given, each report has its usual ones
+type Percentiles []float64
+
+// Parses --percentiles, eg. "50,90,99,99.9,99.99"; empty means none are given
+func ParsePercentiles(raw string) (Percentiles, error) {
+	if raw == "" {
+		return nil, nil
+	}
+	percentiles := make(Percentiles, 0)
+	for _, rawValue := range strings.Split(raw, ",") {
+		q, err := strconv.ParseFloat(strings.TrimSpace(rawValue), 64)
+		if err != nil {
+			return nil, fmt.Errorf("--percentiles must be numbers, failing to parse '%s': %s", rawValue, err)
+		}
+		// The minimum is reported separately; the histogram has nothing meaningful at 0
+		if q <= 0 || q > 100 {
+			return nil, fmt.Errorf("--percentiles must be above 0 and at most 100, got %s", rawValue)
+		}
+		// CSV columns leave out the dot, so 9.99 and 99.9 would both be p999
+		for _, other := range percentiles {
+			if percentileColumn(other) == percentileColumn(q) {
+				return nil, fmt.Errorf("--percentiles %s and %s would both be reported as %s, give each percentile once",
+					percentileLabel(other), percentileLabel(q), percentileColumn(q))
+			}
+		}
+		percentiles = append(percentiles, q)
+	}
+	sort.Float64s(percentiles)
+	return percentiles, nil
+}
+
+func (p Percentiles) or(defaults Percentiles) Percentiles {
+	if len(p) == 0 {
+		return defaults
+	}
+	return p
+}
+
+// Leaves out max and anything above, for reports that show the maximum anyway
+func (p Percentiles) below(max float64) Percentiles {
+	below := make(Percentiles, 0, len(p))
+	for _, q := range p {
+		if q < max {
+			below = append(below, q)
+		}
+	}
+	return below
+}
+
+// The percentile as it appears in column names, eg. 99.9
+func percentileLabel(q float64) string {
+	return strconv.FormatFloat(q, 'f', -1, 64)
+}
+
+// The percentile as it appears in names that can't have a dot, like CSV columns, eg. p999 for 99.9
+func percentileColumn(q float64) string {
+	return "p" + strings.Replace(percentileLabel(q), ".", "", 1)
+}
+
+// What the by-script table would say about the only script of a throughput run, other than the totals: its
+// latencies and its description
+func writeSingleScript(result Result, percentiles Percentiles, s *strings.Builder) {
+	for _, script := range result.Scripts {
+		if script.Succeeded > 0 {
+			latencies := make([]string, 0)
+			for _, q := range percentiles.or(Percentiles{50, 95, 99}).below(100) {
+				latencies = append(latencies, fmt.Sprintf("p%s %sms", percentileLabel(q), formatQuantile(script, q)))
+			}
+			latencies = append(latencies, fmt.Sprintf("max %.3fms", float64(script.Latencies.Max())/1000.0))
+			s.WriteString(fmt.Sprintf("Latencies: %s\n", strings.Join(latencies, ", ")))
+		}
+		if description := result.Descriptions[script.ScriptName]; description != "" {
+			s.WriteString(fmt.Sprintf("%s: %s\n", script.ScriptName, description))
+		}
+	}
+}
+
+// The latency at quantile q in milliseconds, or - if nothing succeeded
+func formatQuantile(script *ScriptResult, q float64) string {
+	if script.Succeeded == 0 {
+		return "-"
+	}
+	return fmt.Sprintf("%.3f", float64(script.Latencies.ValueAtQuantile(q))/1000.0)
+}
+
+func summarizeLatency(script *ScriptResult, percentiles Percentiles, s *strings.Builder, indent string) {
 	histo := script.Latencies
 	lines := []string{
 		fmt.Sprintf("%d successful transactions, %d failed. (Total of %.3f per second)\n", script.Succeeded, script.Failed, script.Rate),
@@ -249,12 +1201,9 @@ func summarizeLatency(script *ScriptResult, s *strings.Builder, indent string) {
 			float64(histo.Max())/1000.0, float64(histo.Min())/1000.0, histo.Mean()/1000.0, histo.StdDev()/1000.0),
 		fmt.Sprintf("Latency distribution:\n"),
 		fmt.Sprintf("  P00.000: %.03fms\n", float64(histo.Min())/1000.0),
-		fmt.Sprintf("  P25.000: %.03fms\n", float64(histo.ValueAtQuantile(25))/1000.0),
-		fmt.Sprintf("  P50.000: %.03fms\n", float64(histo.ValueAtQuantile(50))/1000.0),
-		fmt.Sprintf("  P75.000: %.03fms\n", float64(histo.ValueAtQuantile(75))/1000.0),
-		fmt.Sprintf("  P95.000: %.03fms\n", float64(histo.ValueAtQuantile(95))/1000.0),
-		fmt.Sprintf("  P99.000: %.03fms\n", float64(histo.ValueAtQuantile(99))/1000.0),
-		fmt.Sprintf("  P99.999: %.03fms\n", float64(histo.ValueAtQuantile(99.999))/1000.0),
+	}
+	for _, q := range percentiles.or(Percentiles{25, 50, 75, 95, 99, 99.999}) {
+		lines = append(lines, fmt.Sprintf("  P%06.3f: %.03fms\n", q, float64(histo.ValueAtQuantile(q))/1000.0))
 	}
 	for _, line := range lines {
 		s.WriteString(indent)
@@ -262,18 +1211,281 @@ func summarizeLatency(script *ScriptResult, s *strings.Builder, indent string) {
 	}
 }
 
+// Latency mode measures from when each transaction was scheduled to start; this puts that next to the latency
+// from when they actually started, so it's clear how much of the tail is transactions queueing behind slow
+// ones. Throughput runs don't schedule transactions, so this only makes sense for latency runs.
+func writeCoordinatedOmissionReport(result Result, percentiles Percentiles, s *strings.Builder) {
+	percentiles = percentiles.or(Percentiles{50, 95, 99}).below(100)
+	header := []string{"script", "latency"}
+	for _, q := range percentiles {
+		header = append(header, fmt.Sprintf("p%s(ms)", percentileLabel(q)))
+	}
+	rows := [][]string{append(header, "max(ms)")}
+	for _, script := range sortedScripts(result) {
+		if script.Succeeded == 0 || script.UncorrectedLatencies == nil || script.UncorrectedLatencies.TotalCount() == 0 {
+			continue
+		}
+		for _, histo := range []struct {
+			name      string
+			latencies *hdrhistogram.Histogram
+		}{{"corrected", script.Latencies}, {"uncorrected", script.UncorrectedLatencies}} {
+			row := []string{script.ScriptName, histo.name}
+			for _, q := range percentiles {
+				row = append(row, fmt.Sprintf("%.3f", float64(histo.latencies.ValueAtQuantile(q))/1000.0))
+			}
+			rows = append(rows, append(row, fmt.Sprintf("%.3f", float64(histo.latencies.Max())/1000.0)))
+		}
+	}
+	if len(rows) == 1 {
+		return
+	}
+	s.WriteString("\n")
+	s.WriteString("Coordinated omission, latency from the scheduled start (corrected) and the actual start (uncorrected):\n")
+	writeTable(rows, s)
+}
+
+func hasRetries(result Result) bool {
+	for _, script := range result.Scripts {
+		if script.Retried > 0 || script.Deadlocks > 0 {
+			return true
+		}
+	}
+	return false
+}
+
+// The fraction of attempts that failed and were tried again; with no contention this is 0, and it climbs
+// towards 1 as more and more attempts get in each other's way
+func retryRate(script *ScriptResult) float64 {
+	attempts := script.Succeeded + script.Failed + script.Retried
+	if attempts == 0 {
+		return 0
+	}
+	return float64(script.Retried) / float64(attempts)
+}
+
 func writeErrorReport(result Result, s *strings.Builder) {
 	s.WriteString(fmt.Sprintf("Error stats:\n"))
+	if retried := result.TotalRetried(); retried > 0 {
+		total := &ScriptResult{Succeeded: result.TotalSucceeded(), Failed: result.TotalFailed(), Retried: retried}
+		deadlocks := int64(0)
+		for _, script := range result.Scripts {
+			deadlocks += script.Deadlocks
+		}
+		s.WriteString(fmt.Sprintf("  Retried attempts: %d (%.3f %% of attempts, %d deadlocks); these are not failures unless the last attempt failed too\n",
+			retried, 100*retryRate(total), deadlocks))
+	}
 	if result.TotalFailed() == 0 {
 		s.WriteString(fmt.Sprintf("  No errors!\n"))
 	} else {
 		s.WriteString(fmt.Sprintf("  Failed transactions: %d (%.3f %%)\n", result.TotalFailed(), 100*float64(result.TotalFailed())/float64(result.TotalFailed()+result.TotalSucceeded())))
 		s.WriteString(fmt.Sprintf("\n"))
 		s.WriteString(fmt.Sprintf("  Causes:\n"))
-		for name, info := range result.FailedByErrorGroup {
-			s.WriteString(fmt.Sprintf("    %s: %d failures\n", name, info.Count))
-			s.WriteString(fmt.Sprintf("      (ex: %s)\n", info.FirstFailure))
+		for _, name := range sortedFailureGroups(result) {
+			info := result.FailedByErrorGroup[name]
+			s.WriteString(fmt.Sprintf("    %s: %d failures", name, info.Count))
+			if !info.LastSeen.IsZero() {
+				s.WriteString(fmt.Sprintf(", last at %s", info.LastSeen.Format("15:04:05")))
+			}
+			s.WriteString("\n")
+			for _, source := range info.Sources {
+				s.WriteString(fmt.Sprintf("      %d %s\n", source.Count, describeFailureSource(source)))
+			}
+			if len(info.Samples) == 0 {
+				s.WriteString(fmt.Sprintf("      (ex: %s)\n", info.FirstFailure))
+			}
+			for _, sample := range info.Samples {
+				s.WriteString(fmt.Sprintf("      (ex: %s)\n", sample))
+			}
+		}
+	}
+}
+
+// Names of the failure groups in the result, the most common first
+func sortedFailureGroups(result Result) []string {
+	names := make([]string, 0, len(result.FailedByErrorGroup))
+	for name := range result.FailedByErrorGroup {
+		names = append(names, name)
+	}
+	sort.Slice(names, func(i, j int) bool {
+		a, b := result.FailedByErrorGroup[names[i]], result.FailedByErrorGroup[names[j]]
+		if a.Count != b.Count {
+			return a.Count > b.Count
+		}
+		return names[i] < names[j]
+	})
+	return names
+}
+
+func describeFailureSource(source FailureSource) string {
+	if source.Command < 0 {
+		return fmt.Sprintf("in %s, outside any statement, eg. on commit", source.Script)
+	}
+	return fmt.Sprintf("in %s, at %s", source.Script, abbreviateQuery(source.Query))
+}
+
+// Lock errors get their own section, since most of them are retried by the driver and never show up as failures
+func writeLockReport(result Result, s *strings.Builder) {
+	if len(result.LockErrors) == 0 {
+		return
+	}
+	codes := make([]string, 0, len(result.LockErrors))
+	total := int64(0)
+	for code, n := range result.LockErrors {
+		codes = append(codes, code)
+		total += n
+	}
+	sort.Strings(codes)
+	s.WriteString("\n")
+	s.WriteString(fmt.Sprintf("Lock contention: %d lock errors, including attempts the driver retried\n", total))
+	for _, code := range codes {
+		s.WriteString(fmt.Sprintf("  %s: %d\n", code, result.LockErrors[code]))
+	}
+}
+
+func writeAcquireReport(result Result, s *strings.Builder) {
+	histo := result.AcquireLatencies
+	if histo == nil || histo.TotalCount() == 0 {
+		return
+	}
+	s.WriteString("\n")
+	s.WriteString("Connection acquisition:\n")
+	s.WriteString(fmt.Sprintf("  p50: %.03fms, p99: %.03fms, max: %.03fms\n", float64(histo.ValueAtQuantile(50))/1000.0,
+		float64(histo.ValueAtQuantile(99))/1000.0, float64(histo.Max())/1000.0))
+}
+
+// Compares the two scripts of a --head-to-head run side by side, with the second relative to the first
+func writeHeadToHeadReport(result Result, s *strings.Builder) {
+	if len(result.HeadToHead) != 2 {
+		return
+	}
+	a, b := result.Scripts[result.HeadToHead[0]], result.Scripts[result.HeadToHead[1]]
+	if a == nil || b == nil {
+		return
+	}
+	s.WriteString("\n")
+	s.WriteString("Head-to-head:\n")
+	writeSideBySide(a, b, s)
+}
+
+// A table of how a and b did, with the difference of b relative to a
+func writeSideBySide(a, b *ScriptResult, s *strings.Builder) {
+	rows := [][]string{{"", a.ScriptName, b.ScriptName, "difference"}}
+	addRow := func(name string, format string, av, bv float64) {
+		diff := "-"
+		if av != 0 {
+			diff = fmt.Sprintf("%+.1f%%", (bv-av)/av*100)
+		}
+		rows = append(rows, []string{name, fmt.Sprintf(format, av), fmt.Sprintf(format, bv), diff})
+	}
+	addRow("succeeded", "%.0f", float64(a.Succeeded), float64(b.Succeeded))
+	addRow("failed", "%.0f", float64(a.Failed), float64(b.Failed))
+	addRow("tps", "%.3f", a.Rate, b.Rate)
+	if a.Succeeded > 0 && b.Succeeded > 0 {
+		addRow("mean(ms)", "%.3f", a.Latencies.Mean()/1000.0, b.Latencies.Mean()/1000.0)
+		for _, q := range []float64{50, 95, 99} {
+			addRow(fmt.Sprintf("p%.0f(ms)", q), "%.3f",
+				float64(a.Latencies.ValueAtQuantile(q))/1000.0, float64(b.Latencies.ValueAtQuantile(q))/1000.0)
+		}
+		addRow("max(ms)", "%.3f", float64(a.Latencies.Max())/1000.0, float64(b.Latencies.Max())/1000.0)
+	}
+	writeTable(rows, s)
+}
+
+// Reports how much data was written and at what rate, if the workload wrote anything
+func writeIngestReport(result Result, s *strings.Builder) {
+	written := result.TotalWritten()
+	if written.IsZero() {
+		return
+	}
+	rowRate, byteRate := result.TotalWriteRate()
+	s.WriteString("\n")
+	s.WriteString("Ingest:\n")
+	s.WriteString(fmt.Sprintf("  %d rows written (%.3f rows/s)\n", written.Rows, rowRate))
+	s.WriteString(fmt.Sprintf("  ~%.3f MB of parameters written (%.3f MB/s)\n", float64(written.Bytes)/1000/1000, byteRate/1000/1000))
+}
+
+// Summarizes the ramp-up region, if there was one; the rest of the report covers steady state only
+func writeRampReport(result Result, s *strings.Builder) {
+	if result.Ramp == nil {
+		return
+	}
+	ramp := result.Ramp
+	s.WriteString("\n")
+	s.WriteString("Ramp-up (not included in the results above):\n")
+	s.WriteString(fmt.Sprintf("  %d successful transactions, %d failed. (Total of %.3f per second)\n", ramp.TotalSucceeded(), ramp.TotalFailed(), ramp.TotalRate()))
+	for _, script := range ramp.Scripts {
+		if script.Succeeded == 0 {
+			s.WriteString(fmt.Sprintf("  [%s]: %.03f tps\n", script.ScriptName, script.Rate))
+			continue
+		}
+		s.WriteString(fmt.Sprintf("  [%s]: %.03f tps, p50: %.03fms, p99: %.03fms\n", script.ScriptName, script.Rate,
+			float64(script.Latencies.ValueAtQuantile(50))/1000.0, float64(script.Latencies.ValueAtQuantile(99))/1000.0))
+	}
+}
+
+func writeServerMetricsReport(result Result, s *strings.Builder) {
+	if len(result.ServerMetrics) == 0 {
+		return
+	}
+	s.WriteString("\n")
+	s.WriteString(fmt.Sprintf("Server metrics (%d samples):\n", len(result.ServerMetrics)))
+	s.WriteString(SummarizeServerMetrics(result.ServerMetrics))
+	s.WriteString("\n")
+	start := result.ServerMetrics[0].Time
+	// GC and checkpoints as they went up since the previous sample, so the row they happened in stands out
+	rows := [][]string{{"time", "heap_used_mb", "page_cache_hit_ratio", "open_transactions", "gc_ms", "checkpoints", "checkpoint_ms"}}
+	for i, sample := range result.ServerMetrics {
+		prev := sample
+		if i > 0 {
+			prev = result.ServerMetrics[i-1]
+		}
+		delta := func(prev, next int64) string {
+			if d, ok := serverMetricDelta(prev, next); ok && i > 0 {
+				return fmt.Sprintf("%d", d)
+			}
+			return "-"
 		}
+		rows = append(rows, []string{
+			fmt.Sprintf("+%s", sample.Time.Sub(start).Round(time.Second)),
+			fmt.Sprintf("%.2f", float64(sample.HeapUsedBytes)/1024/1024),
+			fmt.Sprintf("%.4f", sample.PageCacheHitRatio),
+			fmt.Sprintf("%d", sample.ActiveTransactions),
+			delta(prev.GcTimeMillis, sample.GcTimeMillis),
+			delta(prev.Checkpoints, sample.Checkpoints),
+			delta(prev.CheckpointTimeMillis, sample.CheckpointTimeMillis),
+		})
+	}
+	writeTable(rows, s)
+}
+
+func writeClientDiagnosticsReport(result Result, s *strings.Builder) {
+	if result.ClientDiagnostics == nil {
+		return
+	}
+	d := result.ClientDiagnostics
+	s.WriteString("\n")
+	s.WriteString("Client diagnostics:\n")
+	s.WriteString(fmt.Sprintf("  Goroutines: up to %d, on GOMAXPROCS=%d\n", d.MaxGoroutines, d.GOMAXPROCS))
+	s.WriteString(fmt.Sprintf("  GC: %d cycles, %s paused in total, longest pause %s, %.2f%% of CPU\n",
+		d.NumGC, d.GCPauseTotal, d.GCPauseMax, d.GCCPUFraction*100))
+	s.WriteString(fmt.Sprintf("  Scheduling delay: up to %s\n", d.SchedulingLagMax))
+	warnings := d.Warnings()
+	if len(warnings) == 0 {
+		s.WriteString("  The client does not appear to have affected the measurements\n")
+	}
+	for _, warning := range warnings {
+		s.WriteString(fmt.Sprintf("  WARNING: %s\n", warning))
+	}
+}
+
+func (o *InteractiveOutput) ShowWorkerStats() {
+	o.WorkerStats = true
+}
+
+func (o *InteractiveOutput) Noticef(format string, a ...interface{}) {
+	_, err := fmt.Fprintf(o.ErrStream, "%s\n", fmt.Sprintf(format, a...))
+	if err != nil {
+		panic(err)
 	}
 }
 
@@ -289,6 +1501,10 @@ func (o *InteractiveOutput) Errorf(format string, a ...interface{}) {
 type CsvOutput struct {
 	ErrStream io.Writer
 	OutStream io.Writer
+	// See --percentiles
+	Percentiles Percentiles
+	// See --tag; each tag gets a column, after the others
+	Tags Tags
 	// Used to rate-limit progress reporting
 	LastProgressReport ProgressReport
 	LastProgressTime   time.Time
@@ -305,10 +1521,12 @@ func (o *CsvOutput) BenchmarkStart(databaseName, url, scenario string) {
 		panic(err)
 	}
 
-	columnNames := make([]string, 0, len(csvColumns))
-	for _, col := range csvColumns {
+	columns := csvColumns(o.Percentiles)
+	columnNames := make([]string, 0, len(columns))
+	for _, col := range columns {
 		columnNames = append(columnNames, col.name)
 	}
+	columnNames = append(columnNames, o.Tags.csvColumnNames()...)
 	_, err = fmt.Fprintf(o.OutStream, "%s\n", strings.Join(columnNames, ","))
 	if err != nil {
 		panic(err)
@@ -333,11 +1551,17 @@ func (o *CsvOutput) ReportWorkloadProgress(completeness float64, checkpoint Resu
 	if err != nil {
 		panic(err)
 	}
-	o.ReportLatency(checkpoint)
+	o.writeLatencyRow(checkpoint)
 }
 
 func (o *CsvOutput) ReportThroughput(result Result) {
-	columns := []string{"script", "succeeded", "failed", "transactions_per_second"}
+	columns := []string{"script", "succeeded", "failed", "transactions_per_second", "retried", "deadlocks"}
+	// Named so they aren't mistaken for the latencies of -l runs, see closedLoopNote
+	percentiles := o.Percentiles.or(Percentiles{50, 95, 99}).below(100)
+	for _, q := range percentiles {
+		columns = append(columns, "closed_loop_"+percentileColumn(q))
+	}
+	columns = append(columns, o.Tags.csvColumnNames()...)
 
 	s := strings.Builder{}
 	separator := ","
@@ -349,6 +1573,11 @@ func (o *CsvOutput) ReportThroughput(result Result) {
 			float64(script.Succeeded),
 			float64(script.Failed),
 			script.Rate,
+			float64(script.Retried),
+			float64(script.Deadlocks),
+		}
+		for _, q := range percentiles {
+			row = append(row, float64(script.Latencies.ValueAtQuantile(q))/1000.0)
 		}
 		s.WriteString(fmt.Sprintf("\"%s\",", script.ScriptName))
 		for i, cell := range row {
@@ -357,6 +1586,10 @@ func (o *CsvOutput) ReportThroughput(result Result) {
 			}
 			s.WriteString(fmt.Sprintf("%.03f", cell))
 		}
+		for _, value := range o.Tags.csvValues() {
+			s.WriteString(separator)
+			s.WriteString(value)
+		}
 		s.WriteString("\n")
 	}
 
@@ -371,22 +1604,57 @@ func (o *CsvOutput) ReportThroughput(result Result) {
 			panic(err)
 		}
 	}
+	o.writeSupplementaryReports(result)
 }
 
 func (o *CsvOutput) ReportLatency(result Result) {
 	o.writeLatencyRow(result)
+	o.writeSupplementaryReports(result)
+}
+
+func hasStatementResults(result Result) bool {
+	for _, script := range result.Scripts {
+		if len(script.Statements) > 0 {
+			return true
+		}
+	}
+	return false
+}
+
+// The CSV on stdout only has steady-state numbers, the other reports go to stderr with the other human-readable bits
+func (o *CsvOutput) writeSupplementaryReports(result Result) {
+	if result.Ramp == nil && len(result.ServerMetrics) == 0 && result.TotalWritten().IsZero() && len(result.HeadToHead) == 0 &&
+		result.ClientDiagnostics == nil && len(result.LockErrors) == 0 && !hasStatementResults(result) && !hasServerTimes(result) {
+		return
+	}
+	s := strings.Builder{}
+	writeStatementReport(result, &s)
+	writeServerTimeReport(result, &s)
+	writeLockReport(result, &s)
+	writeHeadToHeadReport(result, &s)
+	writeIngestReport(result, &s)
+	writeRampReport(result, &s)
+	writeServerMetricsReport(result, &s)
+	writeClientDiagnosticsReport(result, &s)
+	if _, err := fmt.Fprint(o.ErrStream, s.String()); err != nil {
+		panic(err)
+	}
 }
 
 func (o *CsvOutput) writeLatencyRow(result Result) {
 	s := strings.Builder{}
 
 	for _, script := range result.Scripts {
-		for i, col := range csvColumns {
+		for i, col := range csvColumns(o.Percentiles) {
 			if i != 0 {
 				s.WriteString(",")
 			}
 			s.WriteString(col.value(result, script))
 		}
+		for _, value := range o.Tags.csvValues() {
+			s.WriteString(",")
+			s.WriteString(value)
+		}
 		s.WriteString("\n")
 	}
 
@@ -404,44 +1672,96 @@ func (o *CsvOutput) writeLatencyRow(result Result) {
 	}
 }
 
+func (o *CsvOutput) ReportSweep(sweep SweepResult) {
+	s := strings.Builder{}
+	if sweep.RateSweep {
+		s.WriteString(strings.Join(append([]string{"target_rate", "achieved_rate", "succeeded", "failed", "p50", "p99"}, o.Tags.csvColumnNames()...), ","))
+		s.WriteString("\n")
+		for i, result := range sweep.Results {
+			latencies := result.CombinedLatencies()
+			s.WriteString(strings.Join(append([]string{
+				fmtFloat(sweep.Values[i]),
+				fmtFloat(result.TotalRate()),
+				fmtFloat(result.TotalSucceeded()),
+				fmtFloat(result.TotalFailed()),
+				fmtFloat(float64(latencies.ValueAtQuantile(50)) / 1000.0),
+				fmtFloat(float64(latencies.ValueAtQuantile(99)) / 1000.0),
+			}, o.Tags.csvValues()...), ","))
+			s.WriteString("\n")
+		}
+		if _, err := fmt.Fprint(o.OutStream, s.String()); err != nil {
+			panic(err)
+		}
+		return
+	}
+	s.WriteString(strings.Join(append([]string{"variable", "value", "script", "succeeded", "failed", "rate", "p50", "p99"}, o.Tags.csvColumnNames()...), ","))
+	s.WriteString("\n")
+	for i, result := range sweep.Results {
+		for _, script := range sortedScripts(result) {
+			s.WriteString(strings.Join(append([]string{
+				fmt.Sprintf("\"%s\"", sweep.Variable),
+				fmt.Sprintf("%v", sweep.Values[i]),
+				fmt.Sprintf("\"%s\"", script.ScriptName),
+				fmtFloat(script.Succeeded),
+				fmtFloat(script.Failed),
+				fmtFloat(script.Rate),
+				fmtFloat(float64(script.Latencies.ValueAtQuantile(50)) / 1000.0),
+				fmtFloat(float64(script.Latencies.ValueAtQuantile(99)) / 1000.0),
+			}, o.Tags.csvValues()...), ","))
+			s.WriteString("\n")
+		}
+	}
+	if _, err := fmt.Fprint(o.OutStream, s.String()); err != nil {
+		panic(err)
+	}
+}
+
 func fmtFloat(v interface{}) string {
 	switch v.(type) {
 	case int64:
 		return fmt.Sprintf("%.3f", float64(v.(int64)))
 	case float64:
 		return fmt.Sprintf("%.3f", v.(float64))
+	case string:
+		// Names of --phases
+		return v.(string)
 	}
 	return fmt.Sprintf("%v?", v)
 }
 
-var csvColumns = []struct {
+type csvColumn struct {
 	name  string
 	value func(r Result, s *ScriptResult) string
-}{
-	{"db", func(r Result, s *ScriptResult) string { return fmt.Sprintf("\"%s\"", r.DatabaseName) }},
-	{"script", func(r Result, s *ScriptResult) string { return fmt.Sprintf("\"%s\"", s.ScriptName) }},
-	{"rate", func(r Result, s *ScriptResult) string { return fmtFloat(s.Rate) }},
-	{"succeeded", func(r Result, s *ScriptResult) string { return fmtFloat(s.Latencies.TotalCount()) }},
-	{"failed", func(r Result, s *ScriptResult) string { return fmtFloat(s.Failed) }},
-	{"mean", func(r Result, s *ScriptResult) string { return fmtFloat(s.Latencies.Mean() / 1000.0) }},
-	{"stdev", func(r Result, s *ScriptResult) string { return fmtFloat(s.Latencies.StdDev()) }},
-	{"p0", func(r Result, s *ScriptResult) string { return fmtFloat(float64(s.Latencies.Min()) / 1000.0) }},
-	{"p25", func(r Result, s *ScriptResult) string {
-		return fmtFloat(float64(s.Latencies.ValueAtQuantile(25)) / 1000.0)
-	}},
-	{"p50", func(r Result, s *ScriptResult) string {
-		return fmtFloat(float64(s.Latencies.ValueAtQuantile(50)) / 1000.0)
-	}},
-	{"p75", func(r Result, s *ScriptResult) string {
-		return fmtFloat(float64(s.Latencies.ValueAtQuantile(75)) / 1000.0)
-	}},
-	{"p99", func(r Result, s *ScriptResult) string {
-		return fmtFloat(float64(s.Latencies.ValueAtQuantile(99)) / 1000.0)
-	}},
-	{"p99999", func(r Result, s *ScriptResult) string {
-		return fmtFloat(float64(s.Latencies.ValueAtQuantile(99.999)) / 1000.0)
-	}},
-	{"p100", func(r Result, s *ScriptResult) string { return fmtFloat(float64(s.Latencies.Max()) / 1000.0) }},
+}
+
+func csvColumns(percentiles Percentiles) []csvColumn {
+	columns := []csvColumn{
+		{"db", func(r Result, s *ScriptResult) string { return fmt.Sprintf("\"%s\"", r.DatabaseName) }},
+		{"script", func(r Result, s *ScriptResult) string { return fmt.Sprintf("\"%s\"", s.ScriptName) }},
+		{"rate", func(r Result, s *ScriptResult) string { return fmtFloat(s.Rate) }},
+		{"succeeded", func(r Result, s *ScriptResult) string { return fmtFloat(s.Latencies.TotalCount()) }},
+		{"failed", func(r Result, s *ScriptResult) string { return fmtFloat(s.Failed) }},
+		{"mean", func(r Result, s *ScriptResult) string { return fmtFloat(s.Latencies.Mean() / 1000.0) }},
+		{"stdev", func(r Result, s *ScriptResult) string { return fmtFloat(s.Latencies.StdDev()) }},
+		{"p0", func(r Result, s *ScriptResult) string { return fmtFloat(float64(s.Latencies.Min()) / 1000.0) }},
+	}
+	for _, q := range percentiles.or(Percentiles{25, 50, 75, 99, 99.999}).below(100) {
+		q := q
+		columns = append(columns, csvColumn{percentileColumn(q), func(r Result, s *ScriptResult) string {
+			return fmtFloat(float64(s.Latencies.ValueAtQuantile(q)) / 1000.0)
+		}})
+	}
+	return append(columns,
+		csvColumn{"p100", func(r Result, s *ScriptResult) string { return fmtFloat(float64(s.Latencies.Max()) / 1000.0) }},
+		csvColumn{"retried", func(r Result, s *ScriptResult) string { return fmtFloat(s.Retried) }},
+		csvColumn{"deadlocks", func(r Result, s *ScriptResult) string { return fmtFloat(s.Deadlocks) }})
+}
+
+func (o *CsvOutput) Noticef(format string, a ...interface{}) {
+	_, err := fmt.Fprintf(o.ErrStream, "%s\n", fmt.Sprintf(format, a...))
+	if err != nil {
+		panic(err)
+	}
 }
 
 func (o *CsvOutput) Errorf(format string, a ...interface{}) {
@@ -467,15 +1787,18 @@ type PrometheusOutput struct {
 	totalFailedCounter    prometheus.Counter
 }
 
-func NewPrometheusOutput() *PrometheusOutput {
+// tags are added to the metrics as labels, see --tag
+func NewPrometheusOutput(tags Tags) *PrometheusOutput {
 	return &PrometheusOutput{
 		totalSucceededCounter: promauto.NewCounter(prometheus.CounterOpts{
-			Name: "neobench_successful_transactions_total",
-			Help: "The total number of successful transactions",
+			Name:        "neobench_successful_transactions_total",
+			Help:        "The total number of successful transactions",
+			ConstLabels: tags.asMap(),
 		}),
 		totalFailedCounter: promauto.NewCounter(prometheus.CounterOpts{
-			Name: "neobench_failed_transactions_total",
-			Help: "The total number of failed transactions",
+			Name:        "neobench_failed_transactions_total",
+			Help:        "The total number of failed transactions",
+			ConstLabels: tags.asMap(),
 		}),
 	}
 }
@@ -497,6 +1820,9 @@ func (p *PrometheusOutput) ReportThroughput(result Result) {
 func (p *PrometheusOutput) ReportLatency(result Result) {
 }
 
+func (p *PrometheusOutput) ReportSweep(sweep SweepResult) {
+}
+
 func (p *PrometheusOutput) Errorf(format string, a ...interface{}) {
 }
 
@@ -537,9 +1863,21 @@ func (c *CombinedOutput) ReportLatency(result Result) {
 	}
 }
 
+func (c *CombinedOutput) ReportSweep(sweep SweepResult) {
+	for _, d := range c.delegates {
+		d.ReportSweep(sweep)
+	}
+}
+
 func (c *CombinedOutput) Errorf(format string, a ...interface{}) {
 	for _, d := range c.delegates {
-		d.Errorf(format, a)
+		d.Errorf(format, a...)
+	}
+}
+
+func (c *CombinedOutput) Noticef(format string, a ...interface{}) {
+	for _, d := range c.delegates {
+		Noticef(d, format, a...)
 	}
 }
 
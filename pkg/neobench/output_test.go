@@ -0,0 +1,398 @@
+package neobench
+
+import (
+	"bytes"
+	"fmt"
+	"github.com/codahale/hdrhistogram"
+	"github.com/stretchr/testify/assert"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestResultAddMergesLatencies(t *testing.T) {
+	a, b := NewWorkerResult(0), NewWorkerResult(1)
+	assert.NoError(t, a.record("s", 1000, uowOutcome{succeeded: true}))
+	assert.NoError(t, b.record("s", 3000, uowOutcome{succeeded: true}))
+
+	total := NewResult("", "")
+	assert.NoError(t, total.Add(a))
+	assert.NoError(t, total.Add(b))
+
+	assert.Equal(t, int64(2), total.Scripts["s"].Succeeded)
+	assert.Equal(t, int64(2), total.Scripts["s"].Latencies.TotalCount())
+}
+
+func TestResultAddRejectsIncompatibleHistograms(t *testing.T) {
+	a, b := NewWorkerResult(0), NewWorkerResult(1)
+	assert.NoError(t, a.record("s", 1000, uowOutcome{succeeded: true}))
+	b.Scripts["s"] = &ScriptResult{ScriptName: "s", Latencies: hdrhistogram.New(0, 1000, 5)}
+
+	total := NewResult("", "")
+	assert.NoError(t, total.Add(a))
+	assert.EqualError(t, total.Add(b), "failed to combine latencies for s from worker 1: histograms have different "+
+		"configurations: [0, 3600000000] at 3 significant figures vs [0, 1000] at 5 significant figures")
+}
+
+func TestHeadToHeadReport(t *testing.T) {
+	w := NewWorkerResult(0)
+	assert.NoError(t, w.record("a", time.Millisecond, uowOutcome{succeeded: true}))
+	assert.NoError(t, w.record("b", 2*time.Millisecond, uowOutcome{succeeded: true}))
+	result := NewResult("", "")
+	assert.NoError(t, result.Add(w))
+	result.HeadToHead = []string{"a", "b"}
+
+	s := strings.Builder{}
+	writeHeadToHeadReport(result, &s)
+
+	assert.Contains(t, s.String(), "Head-to-head:")
+	assert.Regexp(t, `p50\(ms\)\s+1\.000\s+2\.000\s+\+100\.0%`, s.String())
+}
+
+func TestLockReportCountsRetriedLockErrors(t *testing.T) {
+	deadlock := "Neo.TransientError.Transaction.DeadlockDetected"
+	w := NewWorkerResult(0)
+	// Succeeded after the driver retried two deadlocks, then failed after one more
+	assert.NoError(t, w.record("s", time.Millisecond, uowOutcome{succeeded: true, lockErrors: []string{deadlock, deadlock}}))
+	assert.NoError(t, w.record("s", time.Millisecond, uowOutcome{succeeded: false, failureGroup: "unknown",
+		err: fmt.Errorf("deadlock"), lockErrors: []string{deadlock}}))
+	result := NewResult("", "")
+	assert.NoError(t, result.Add(w))
+
+	s := strings.Builder{}
+	writeLockReport(result, &s)
+
+	assert.Equal(t, "\nLock contention: 3 lock errors, including attempts the driver retried\n"+
+		"  Neo.TransientError.Transaction.DeadlockDetected: 3\n", s.String())
+}
+
+func TestRetriesAreReportedApartFromFailures(t *testing.T) {
+	deadlock := "Neo.TransientError.Transaction.DeadlockDetected"
+	w := NewWorkerResult(0)
+	// Succeeded on the third attempt after two deadlocks, then ran out of retries after a lock timeout
+	assert.NoError(t, w.record("s", time.Millisecond, uowOutcome{succeeded: true, lockErrors: []string{deadlock, deadlock}, retried: 2}))
+	assert.NoError(t, w.record("s", time.Millisecond, uowOutcome{succeeded: false, failureGroup: "unknown", err: fmt.Errorf("lock timeout"),
+		lockErrors: []string{"Neo.TransientError.Transaction.LockAcquisitionTimeout"}, retried: 1}))
+	assert.NoError(t, w.record("t", time.Millisecond, uowOutcome{succeeded: true}))
+	result := NewResult("", "")
+	assert.NoError(t, result.Add(w))
+
+	script := result.Scripts["s"]
+	assert.Equal(t, int64(1), script.Failed)
+	assert.Equal(t, int64(3), script.Retried)
+	assert.Equal(t, int64(2), script.Deadlocks)
+	assert.Equal(t, int64(3), result.TotalRetried())
+
+	s := strings.Builder{}
+	writeScriptBreakdown(result, nil, &s)
+	assert.Regexp(t, `script\s+share\s+succeeded\s+failed\s+retried\s+retry rate\s+deadlocks\s+tps`, s.String())
+	assert.Regexp(t, `\n\s+s\s+66\.7%\s+1\s+1\s+3\s+60\.0%\s+2\s`, s.String())
+	assert.Regexp(t, `\n\s+t\s+33\.3%\s+1\s+0\s+0\s+0\.0%\s+0\s`, s.String())
+
+	s.Reset()
+	writeErrorReport(result, &s)
+	assert.Contains(t, s.String(), "  Retried attempts: 3 (50.000 % of attempts, 2 deadlocks); these are not failures unless the last attempt failed too\n"+
+		"  Failed transactions: 1 (33.333 %)\n")
+}
+
+func TestScriptBreakdownLeavesOutRetriesIfThereWereNone(t *testing.T) {
+	w := NewWorkerResult(0)
+	assert.NoError(t, w.record("s", time.Millisecond, uowOutcome{succeeded: true}))
+	result := NewResult("", "")
+	assert.NoError(t, result.Add(w))
+
+	s := strings.Builder{}
+	writeScriptBreakdown(result, nil, &s)
+	assert.NotContains(t, s.String(), "retried")
+}
+
+func TestScriptBreakdownIsLeftOutForASingleScript(t *testing.T) {
+	w := NewWorkerResult(0)
+	assert.NoError(t, w.record("s", time.Millisecond, uowOutcome{succeeded: true}))
+	result := NewResult("", "")
+	assert.NoError(t, result.Add(w))
+
+	s := strings.Builder{}
+	writeScriptBreakdown(result, nil, &s)
+	assert.Equal(t, "", s.String())
+
+	w = NewWorkerResult(1)
+	assert.NoError(t, w.record("s", time.Millisecond, uowOutcome{succeeded: true, lockErrors: []string{"Neo.TransientError.Transaction.DeadlockDetected"}, retried: 1}))
+	assert.NoError(t, result.Add(w))
+	writeScriptBreakdown(result, nil, &s)
+	assert.Equal(t, "\n1 retried, a retry rate of 33.3%, 1 deadlocks\n", s.String())
+}
+
+func TestWorkerReportShowsSkewBetweenWorkers(t *testing.T) {
+	result := NewResult("", "")
+	// Added out of order, like workers finishing in any order
+	for _, worker := range []struct {
+		id      int64
+		latency time.Duration
+	}{{1, 10 * time.Millisecond}, {0, 2 * time.Millisecond}} {
+		w := NewWorkerResult(worker.id)
+		assert.NoError(t, w.record("s", worker.latency, uowOutcome{succeeded: true}))
+		w.calculateRate(time.Second)
+		assert.NoError(t, result.Add(w))
+	}
+
+	out := &bytes.Buffer{}
+	(&InteractiveOutput{ErrStream: ioutil.Discard, OutStream: out, WorkerStats: true}).ReportThroughput(result)
+	assert.Contains(t, out.String(), "By worker:\n"+
+		"  worker succeeded failed tps   p50(ms) p95(ms) p99(ms) max(ms)\n"+
+		"  0      1         0      1.000 2.000   2.000   2.000   2.000  \n"+
+		"  1      1         0      1.000 10.007  10.007  10.007  10.007 \n"+
+		"  tps ranges from 1.000 to 1.000, p99 from 2.000ms to 10.007ms; workers run the same mix, so a wide spread points at routing or the connection pool\n")
+
+	out.Reset()
+	(&InteractiveOutput{ErrStream: ioutil.Discard, OutStream: out}).ReportThroughput(result)
+	assert.NotContains(t, out.String(), "By worker")
+}
+
+func TestThroughputReportLabelsLatenciesAsClosedLoop(t *testing.T) {
+	w := NewWorkerResult(0)
+	assert.NoError(t, w.record("s", 3*time.Millisecond, uowOutcome{succeeded: true}))
+	result := NewResult("", "")
+	assert.NoError(t, result.Add(w))
+
+	throughput, latency := &bytes.Buffer{}, &bytes.Buffer{}
+	(&InteractiveOutput{ErrStream: ioutil.Discard, OutStream: throughput}).ReportThroughput(result)
+	(&InteractiveOutput{ErrStream: ioutil.Discard, OutStream: latency}).ReportLatency(result)
+
+	assert.Contains(t, throughput.String(), "Latencies: p50 3.001ms, p95 3.001ms, p99 3.001ms, max 3.001ms\n"+closedLoopNote)
+	assert.NotContains(t, latency.String(), "closed-loop")
+}
+
+func TestServerTimeReportSplitsLatencyIntoServerAndClient(t *testing.T) {
+	w := NewWorkerResult(0)
+	assert.NoError(t, w.record("s", 4*time.Millisecond, uowOutcome{succeeded: true, server: serverTime{total: 3 * time.Millisecond, known: true}}))
+	assert.NoError(t, w.record("s", 2*time.Millisecond, uowOutcome{succeeded: true, server: serverTime{total: time.Millisecond, known: true}}))
+	// The server sent no timings for this one
+	assert.NoError(t, w.record("t", time.Millisecond, uowOutcome{succeeded: true}))
+	result := NewResult("", "")
+	assert.NoError(t, result.Add(w))
+
+	s := strings.Builder{}
+	writeServerTimeReport(result, &s)
+
+	assert.Contains(t, s.String(), "Server vs client time, of successful transactions:\n")
+	assert.Regexp(t, `\n\s+s\s+3\.001\s+2\.001\s+1\.000\s+66\.7%\s+1\.000\s+3\.001`, s.String())
+	assert.NotRegexp(t, `\n\s+t\s`, s.String())
+}
+
+func TestCoordinatedOmissionReportComparesCorrectedAndUncorrected(t *testing.T) {
+	w := NewWorkerResult(0)
+	assert.NoError(t, w.record("s", 2*time.Millisecond, uowOutcome{succeeded: true}))
+	// Scheduled 8ms before it could start, behind a slow one
+	assert.NoError(t, w.record("s", 10*time.Millisecond, uowOutcome{succeeded: true, startedLate: 8 * time.Millisecond}))
+	result := NewResult("", "")
+	assert.NoError(t, result.Add(w))
+
+	s := strings.Builder{}
+	writeCoordinatedOmissionReport(result, Percentiles{50}, &s)
+
+	assert.Equal(t, "\nCoordinated omission, latency from the scheduled start (corrected) and the actual start (uncorrected):\n"+
+		"  script latency     p50(ms) max(ms)\n"+
+		"  s      corrected   2.000   10.007 \n"+
+		"  s      uncorrected 2.000   2.000  \n", s.String())
+}
+
+func TestRateSweepReportMarksSaturatedRates(t *testing.T) {
+	sweep := SweepResult{Scenario: "-l --rate-sweep 100,1000", RateSweep: true, LatencyMode: true}
+	for _, step := range []struct {
+		target   float64
+		achieved float64
+		latency  time.Duration
+	}{{100, 100, time.Millisecond}, {1000, 500, 10 * time.Millisecond}} {
+		w := NewWorkerResult(0)
+		assert.NoError(t, w.record("a", step.latency, uowOutcome{succeeded: true}))
+		assert.NoError(t, w.record("b", step.latency, uowOutcome{succeeded: true}))
+		w.calculateRate(time.Duration(float64(2*time.Second) / step.achieved))
+		result := NewResult("", "")
+		assert.NoError(t, result.Add(w))
+		sweep.Values = append(sweep.Values, step.target)
+		sweep.Results = append(sweep.Results, result)
+	}
+
+	s := strings.Builder{}
+	writeRateSweepReport(sweep, &s)
+
+	assert.Regexp(t, `100\.000\s+100\.000\s+2\s+0\s+1\.000\s+1\.000\s*\n`, s.String())
+	assert.Regexp(t, `1000\.000\s+500\.000\s+2\s+0\s+10\.0\d\d\s+10\.0\d\d\s+\*\n`, s.String())
+	assert.Contains(t, s.String(), "* below 95% of the target rate")
+}
+
+func TestClientStepsReportHasARowPerStep(t *testing.T) {
+	sweep := SweepResult{Scenario: "-c 1 --step-clients 1:+1:30s", Variable: "clients", ClientSteps: true}
+	for _, clients := range []int64{1, 2} {
+		w := NewWorkerResult(0)
+		assert.NoError(t, w.record("s", time.Millisecond, uowOutcome{succeeded: true}))
+		w.calculateRate(time.Second)
+		result := NewResult("", "")
+		assert.NoError(t, result.Add(w))
+		sweep.Values = append(sweep.Values, clients)
+		sweep.Results = append(sweep.Results, result)
+	}
+
+	out := strings.Builder{}
+	o := &InteractiveOutput{ErrStream: &strings.Builder{}, OutStream: &out}
+	o.ReportSweep(sweep)
+
+	assert.Equal(t, "== Client steps ==\n"+
+		"Scenario: -c 1 --step-clients 1:+1:30s\n\n"+
+		"  clients script succeeded failed tps  \n"+
+		"  1       s      1         0      1.000\n"+
+		"  2       s      1         0      1.000\n", out.String())
+}
+
+func TestThroughputReportBreaksDownResultsByScript(t *testing.T) {
+	w := NewWorkerResult(0)
+	for i := 0; i < 3; i++ {
+		assert.NoError(t, w.record("fast", time.Millisecond, uowOutcome{succeeded: true}))
+	}
+	assert.NoError(t, w.record("slow", 100*time.Millisecond, uowOutcome{succeeded: false, failureGroup: "unknown", err: fmt.Errorf("timeout")}))
+	w.calculateRate(time.Second)
+	result := NewResult("", "")
+	assert.NoError(t, result.Add(w))
+
+	out := strings.Builder{}
+	o := &InteractiveOutput{ErrStream: &strings.Builder{}, OutStream: &out}
+	o.ReportThroughput(result)
+
+	assert.Contains(t, out.String(), "By script:\n"+
+		"  script share succeeded failed tps   p50(ms) p95(ms) p99(ms) max(ms)\n"+
+		"  fast   75.0% 3         0      3.000 1.000   1.000   1.000   1.000  \n"+
+		"  slow   25.0% 0         1      1.000 -       -       -       -      \n")
+}
+
+func TestThroughputReportDescribesScripts(t *testing.T) {
+	w := NewWorkerResult(0)
+	assert.NoError(t, w.record("transfer", time.Millisecond, uowOutcome{succeeded: true}))
+	assert.NoError(t, w.record("lookup", time.Millisecond, uowOutcome{succeeded: true}))
+	w.calculateRate(time.Second)
+	result := NewResult("", "")
+	assert.NoError(t, result.Add(w))
+	result.Descriptions = map[string]string{"transfer": "Moves money between two accounts"}
+
+	out := strings.Builder{}
+	o := &InteractiveOutput{ErrStream: &strings.Builder{}, OutStream: &out}
+	o.ReportThroughput(result)
+
+	assert.Contains(t, out.String(), "  transfer: Moves money between two accounts\n")
+	assert.NotContains(t, out.String(), "lookup:")
+}
+
+func TestStatementReportShowsWhichStatementDominates(t *testing.T) {
+	match := Statement{Query: "MATCH (a:Account {aid: $aid})\n  RETURN a.balance", Command: 1}
+	create := Statement{Query: "CREATE (:History {delta: $delta})", Command: 3}
+	result := NewResult("", "")
+	for id := int64(0); id < 2; id++ {
+		w := NewWorkerResult(id)
+		assert.NoError(t, w.record("tpcb", 10*time.Millisecond, uowOutcome{succeeded: true, statements: []statementTiming{
+			{statement: match, latency: time.Millisecond},
+			{statement: create, latency: 3 * time.Millisecond},
+		}}))
+		assert.NoError(t, result.Add(w))
+	}
+
+	s := strings.Builder{}
+	writeStatementReport(result, &s)
+
+	assert.Equal(t, "\nStatements in tpcb:\n"+
+		"  # share mean(ms) p50(ms) p95(ms) p99(ms) max(ms) query                                         \n"+
+		"  1 25.0% 1.000    1.000   1.000   1.000   1.000   MATCH (a:Account {aid: $aid}) RETURN a.balance\n"+
+		"  2 75.0% 3.001    3.001   3.001   3.001   3.001   CREATE (:History {delta: $delta})             \n", s.String())
+}
+
+func TestOutputSinksWriteResultsToFiles(t *testing.T) {
+	dir, err := ioutil.TempDir("", "output")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	w := NewWorkerResult(0)
+	assert.NoError(t, w.record("a", time.Millisecond, uowOutcome{succeeded: true}))
+	w.calculateRate(time.Second)
+	result := NewResult("", "")
+	assert.NoError(t, result.Add(w))
+
+	jsonPath, csvPath := filepath.Join(dir, "results.json"), filepath.Join(dir, "results.csv")
+	for _, sink := range []OutputSink{ParseOutputSink("json:" + jsonPath), {Format: "auto", Path: csvPath}} {
+		output, err := newSinkOutput(sink, nil, nil)
+		assert.NoError(t, err)
+		output.ReportThroughput(result)
+	}
+
+	content, err := ioutil.ReadFile(jsonPath)
+	assert.NoError(t, err)
+	assert.Contains(t, string(content), `{"type":"result","mode":"throughput"`)
+	content, err = ioutil.ReadFile(csvPath)
+	assert.NoError(t, err)
+	assert.Equal(t, "script,succeeded,failed,transactions_per_second,retried,deadlocks,closed_loop_p50,closed_loop_p95,closed_loop_p99\n\"a\",1.000,0.000,1.000,0.000,0.000,1.000,1.000,1.000\n", string(content))
+}
+
+func TestOnlyOneOutputGoesToStdout(t *testing.T) {
+	_, err := InitOutput(OutputConfig{Sinks: []OutputSink{ParseOutputSink("interactive"), ParseOutputSink("json")}})
+	assert.EqualError(t, err, "only one output can be written to stdout, write the others to files, ex: -o json:results.json")
+
+	_, err = newSinkOutput(ParseOutputSink("tui:dashboard.txt"), nil, nil)
+	assert.EqualError(t, err, "the tui output is a live dashboard, it can't be written to a file: dashboard.txt")
+}
+
+func TestWorkerStatsGoToTheOutputsThatShowThem(t *testing.T) {
+	out, err := InitOutput(OutputConfig{Sinks: []OutputSink{ParseOutputSink("interactive")}, WorkerStats: true})
+	assert.NoError(t, err)
+	assert.True(t, out.(*InteractiveOutput).WorkerStats)
+
+	out, err = InitOutput(OutputConfig{Sinks: []OutputSink{ParseOutputSink("interactive")}})
+	assert.NoError(t, err)
+	assert.False(t, out.(*InteractiveOutput).WorkerStats)
+}
+
+func TestNoticesGoToTheOutputsThatTakeThem(t *testing.T) {
+	errs := &strings.Builder{}
+	out := &CombinedOutput{delegates: []Output{
+		&InteractiveOutput{ErrStream: errs, OutStream: ioutil.Discard},
+		&HgrmOutput{},
+	}}
+	Noticef(out, "stopping early, %s", "settled")
+	assert.Equal(t, "stopping early, settled\n", errs.String())
+}
+
+func TestPercentilesReplaceTheUsualOnes(t *testing.T) {
+	percentiles, err := ParsePercentiles("99.99, 50,99.9,100")
+	assert.NoError(t, err)
+	assert.Equal(t, Percentiles{50, 99.9, 99.99, 100}, percentiles)
+	_, err = ParsePercentiles("0,50")
+	assert.EqualError(t, err, "--percentiles must be above 0 and at most 100, got 0")
+	_, err = ParsePercentiles("99.9,50,99.9")
+	assert.EqualError(t, err, "--percentiles 99.9 and 99.9 would both be reported as p999, give each percentile once")
+	_, err = ParsePercentiles("9.99,99.9")
+	assert.EqualError(t, err, "--percentiles 9.99 and 99.9 would both be reported as p999, give each percentile once")
+
+	w := NewWorkerResult(0)
+	for i := 1; i <= 1000; i++ {
+		assert.NoError(t, w.record("a", time.Duration(i)*time.Millisecond, uowOutcome{succeeded: true}))
+	}
+	w.calculateRate(time.Second)
+	result := NewResult("", "")
+	assert.NoError(t, result.Add(w))
+
+	out := strings.Builder{}
+	o := &InteractiveOutput{ErrStream: &strings.Builder{}, OutStream: &out, Percentiles: percentiles}
+	o.ReportLatency(result)
+	assert.Contains(t, out.String(), "  script latency     p50(ms) p99.9(ms) p99.99(ms) max(ms) \n")
+	assert.Contains(t, out.String(), "  Latency distribution:\n"+
+		"    P00.000: 1.000ms\n"+
+		"    P50.000: 500.223ms\n"+
+		"    P99.900: 999.423ms\n"+
+		"    P99.990: 1000.447ms\n"+
+		"    P100.000: 1000.447ms\n")
+
+	csv := strings.Builder{}
+	c := &CsvOutput{ErrStream: &strings.Builder{}, OutStream: &csv, Percentiles: percentiles}
+	c.BenchmarkStart("", "", "")
+	assert.Equal(t, "db,script,rate,succeeded,failed,mean,stdev,p0,p50,p999,p9999,p100,retried,deadlocks\n", csv.String())
+}
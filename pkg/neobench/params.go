@@ -0,0 +1,134 @@
+package neobench
+
+import (
+	"fmt"
+	"math/rand"
+	"sync"
+)
+
+// What to do once every row in a params file has been used, see --params-exhausted
+type ParamsExhausted int
+
+const (
+	// Start over from the first row
+	ParamsCycle ParamsExhausted = 0
+	// End the benchmark
+	ParamsStop ParamsExhausted = 1
+	// Keep going with rows picked at random from the file
+	ParamsRandom ParamsExhausted = 2
+)
+
+// Rows of parameters replayed from a CSV file, one row per transaction, see --params-file. The first line of the
+// file names the columns, each row after that binds those names as variables for one transaction.
+//
+// Rows are handed out in order across all clients, so with ParamsStop each row is used exactly once.
+type ParamsFile struct {
+	Path    string
+	Columns []string
+	Mode    ParamsExhausted
+
+	rows []map[string]interface{}
+
+	mut       sync.Mutex
+	next      int
+	exhausted chan struct{}
+}
+
+func LoadParamsFile(loader *CsvLoader, path string, mode ParamsExhausted) (*ParamsFile, error) {
+	raw, err := loader.Load(path)
+	if err != nil {
+		return nil, err
+	}
+	if len(raw) < 2 {
+		return nil, fmt.Errorf("params file '%s' needs a header row naming the columns and at least one row of parameters", path)
+	}
+
+	header := raw[0].([]interface{})
+	columns := make([]string, len(header))
+	for i, cell := range header {
+		name, ok := cell.(string)
+		if !ok || name == "" {
+			return nil, fmt.Errorf("params file '%s': column %d in the header row is '%v', but needs to be a variable name", path, i+1, cell)
+		}
+		columns[i] = name
+	}
+
+	rows := make([]map[string]interface{}, 0, len(raw)-1)
+	for lineNo, line := range raw[1:] {
+		cells := line.([]interface{})
+		if len(cells) != len(columns) {
+			return nil, fmt.Errorf("params file '%s': row %d has %d values, but the header row names %d columns", path, lineNo+2, len(cells), len(columns))
+		}
+		row := make(map[string]interface{}, len(columns))
+		for i, name := range columns {
+			row[name] = cells[i]
+		}
+		rows = append(rows, row)
+	}
+
+	return &ParamsFile{
+		Path:      path,
+		Columns:   columns,
+		Mode:      mode,
+		rows:      rows,
+		exhausted: make(chan struct{}),
+	}, nil
+}
+
+// A copy of this params file that starts over from the first row; each benchmark run gets its own, so a
+// warmup does not use up the rows of the run that follows it. A nil params file restarts as nil.
+func (p *ParamsFile) Restart() *ParamsFile {
+	if p == nil {
+		return nil
+	}
+	return &ParamsFile{
+		Path:      p.Path,
+		Columns:   p.Columns,
+		Mode:      p.Mode,
+		rows:      p.rows,
+		exhausted: make(chan struct{}),
+	}
+}
+
+// The first row; used to stand in for the file when scripts are checked before the run
+func (p *ParamsFile) FirstRow() map[string]interface{} {
+	return p.rows[0]
+}
+
+// The next row of parameters; returns false if the file is used up and the mode is ParamsStop. The returned
+// map is shared, don't modify it.
+func (p *ParamsFile) Next(r *rand.Rand) (map[string]interface{}, bool) {
+	p.mut.Lock()
+	defer p.mut.Unlock()
+	if p.next >= len(p.rows) {
+		switch p.Mode {
+		case ParamsStop:
+			if p.next == len(p.rows) {
+				close(p.exhausted)
+				p.next++
+			}
+			return nil, false
+		case ParamsRandom:
+			return p.rows[r.Intn(len(p.rows))], true
+		default:
+			p.next = 0
+		}
+	}
+	row := p.rows[p.next]
+	p.next++
+	return row, true
+}
+
+// A row picked at random, whatever the mode; the returned map is shared, don't modify it.
+func (p *ParamsFile) Pick(r *rand.Rand) map[string]interface{} {
+	return p.rows[r.Intn(len(p.rows))]
+}
+
+// Closed once a client asks for a row after the last one in ParamsStop mode; for a nil params file this
+// returns a nil channel, which never fires
+func (p *ParamsFile) Exhausted() <-chan struct{} {
+	if p == nil {
+		return nil
+	}
+	return p.exhausted
+}
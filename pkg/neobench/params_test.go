@@ -0,0 +1,90 @@
+package neobench
+
+import (
+	"github.com/stretchr/testify/assert"
+	"math/rand"
+	"testing"
+)
+
+func TestParamsFileExhaustion(t *testing.T) {
+	loader := fakeCsvLoader(map[string]string{
+		"params.csv": "id,name\n1,alice\n2,bob\n",
+	})
+	r := rand.New(rand.NewSource(1337))
+
+	nextIds := func(p *ParamsFile, n int) []interface{} {
+		ids := make([]interface{}, 0)
+		for i := 0; i < n; i++ {
+			row, ok := p.Next(r)
+			if !ok {
+				ids = append(ids, nil)
+				continue
+			}
+			ids = append(ids, row["id"])
+		}
+		return ids
+	}
+
+	cycle, err := LoadParamsFile(loader, "params.csv", ParamsCycle)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"id", "name"}, cycle.Columns)
+	assert.Equal(t, []interface{}{int64(1), int64(2), int64(1), int64(2)}, nextIds(cycle, 4))
+
+	stop, err := LoadParamsFile(loader, "params.csv", ParamsStop)
+	assert.NoError(t, err)
+	assert.Equal(t, []interface{}{int64(1), int64(2), nil, nil}, nextIds(stop, 4))
+	select {
+	case <-stop.Exhausted():
+	default:
+		t.Fatal("expected params file to be exhausted")
+	}
+	// Each run starts over
+	assert.Equal(t, []interface{}{int64(1)}, nextIds(stop.Restart(), 1))
+
+	random, err := LoadParamsFile(loader, "params.csv", ParamsRandom)
+	assert.NoError(t, err)
+	ids := nextIds(random, 20)
+	assert.Equal(t, []interface{}{int64(1), int64(2)}, ids[:2])
+	for _, id := range ids[2:] {
+		assert.Contains(t, []interface{}{int64(1), int64(2)}, id)
+	}
+}
+
+func TestParamsFileValidation(t *testing.T) {
+	loader := fakeCsvLoader(map[string]string{
+		"empty.csv":     "id\n",
+		"numeric.csv":   "id,2\n1,2\n",
+		"malformed.csv": "id,name\n1\n",
+	})
+
+	_, err := LoadParamsFile(loader, "empty.csv", ParamsCycle)
+	assert.EqualError(t, err, "params file 'empty.csv' needs a header row naming the columns and at least one row of parameters")
+	_, err = LoadParamsFile(loader, "numeric.csv", ParamsCycle)
+	assert.EqualError(t, err, "params file 'numeric.csv': column 2 in the header row is '2', but needs to be a variable name")
+	_, err = LoadParamsFile(loader, "malformed.csv", ParamsCycle)
+	assert.Error(t, err)
+}
+
+func TestParamsFileBindsVariables(t *testing.T) {
+	loader := fakeCsvLoader(map[string]string{
+		"params.csv": "id\n7\n",
+	})
+	params, err := LoadParamsFile(loader, "params.csv", ParamsStop)
+	assert.NoError(t, err)
+	script, err := Parse("test", "RETURN $id;", 1)
+	assert.NoError(t, err)
+	wrk := Workload{
+		Variables: map[string]interface{}{"id": int64(1)},
+		Scripts:   NewScripts(script),
+		Rand:      rand.New(rand.NewSource(1337)),
+		Params:    params,
+	}
+	client := wrk.NewClient()
+
+	uow, err := client.Next(0)
+	assert.NoError(t, err)
+	assert.Equal(t, int64(7), uow.Statements[0].Params["id"])
+
+	_, err = client.Next(0)
+	assert.Equal(t, ErrParamsExhausted, err)
+}
@@ -0,0 +1,36 @@
+//go:build !windows
+// +build !windows
+
+package neobench
+
+import (
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+)
+
+// Closes the gate on SIGUSR1 and opens it again on SIGUSR2, until stopCh says to stop, so operators can pause the
+// load for a maintenance action and watch the database recover once it resumes, all within one run
+func PauseOnSignals(gate *Gate, log *Logger, stopCh <-chan struct{}) {
+	sigCh := make(chan os.Signal, 2)
+	signal.Notify(sigCh, syscall.SIGUSR1, syscall.SIGUSR2)
+	defer signal.Stop(sigCh)
+
+	var pausedAt time.Time
+	for {
+		select {
+		case <-stopCh:
+			return
+		case sig := <-sigCh:
+			if sig == syscall.SIGUSR1 && !gate.IsClosed() {
+				pausedAt = time.Now()
+				gate.Close()
+				log.Infof("paused on SIGUSR1, send SIGUSR2 to resume")
+			} else if sig == syscall.SIGUSR2 && gate.IsClosed() {
+				gate.Open()
+				log.Infof("resumed on SIGUSR2 after %s paused", time.Since(pausedAt).Round(time.Millisecond))
+			}
+		}
+	}
+}
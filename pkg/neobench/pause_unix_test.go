@@ -0,0 +1,48 @@
+//go:build !windows
+// +build !windows
+
+package neobench
+
+import (
+	"os"
+	"os/signal"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPausesOnSignals(t *testing.T) {
+	// Keeps a signal sent before PauseOnSignals is listening from killing the test
+	guard := make(chan os.Signal, 16)
+	signal.Notify(guard, syscall.SIGUSR1, syscall.SIGUSR2)
+	defer signal.Stop(guard)
+
+	gate := NewGate()
+	stopCh := make(chan struct{})
+	done := make(chan struct{})
+	go func() {
+		PauseOnSignals(gate, nil, stopCh)
+		close(done)
+	}()
+
+	assert.True(t, sendUntil(syscall.SIGUSR1, gate.IsClosed))
+	assert.True(t, sendUntil(syscall.SIGUSR2, func() bool { return !gate.IsClosed() }))
+	close(stopCh)
+	<-done
+}
+
+// Sends the signal to ourselves until the condition holds, for up to a second
+func sendUntil(sig syscall.Signal, condition func() bool) bool {
+	for deadline := time.Now().Add(time.Second); time.Now().Before(deadline); {
+		if err := syscall.Kill(os.Getpid(), sig); err != nil {
+			return false
+		}
+		time.Sleep(10 * time.Millisecond)
+		if condition() {
+			return true
+		}
+	}
+	return false
+}
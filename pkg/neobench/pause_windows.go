@@ -0,0 +1,5 @@
+package neobench
+
+// Windows has no SIGUSR1 or SIGUSR2, so runs can't be paused there
+func PauseOnSignals(gate *Gate, log *Logger, stopCh <-chan struct{}) {
+}
@@ -0,0 +1,162 @@
+package neobench
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/spf13/pflag"
+)
+
+// One of the runs of --phases, which run one after the other on the same connections. In a file, each line is a
+// phase, a name and the options it runs with, which take the place of the same options on the command line:
+//
+//	# load, then measure
+//	load: -f load.script -c 8 -t 10000
+//	steady: -b tpcb-like -c 16 -l -r 500 -d 10m
+//
+// A phase that sets any of -b, -f or -S runs only the workload it sets; one that sets none of them runs the one
+// from the command line.
+type Phase struct {
+	Name string
+	// The options as given, eg. "-b tpcb-like -c 16 -d 5m"
+	Options string
+
+	Builtins, Files, Scripts []string
+	Clients                  int
+	Duration                 time.Duration
+	Transactions             uint64
+	LatencyMode              bool
+	Rate                     float64
+
+	// Long names of the options the phase sets
+	set map[string]bool
+}
+
+// Whether the phase sets the option with this long name, eg. "clients"; if not, the phase runs with the value from
+// the command line
+func (p Phase) Sets(option string) bool {
+	return p.set[option]
+}
+
+// Whether the phase sets a workload of its own with -b, -f or -S
+func (p Phase) SetsWorkload() bool {
+	return p.Sets("builtin") || p.Sets("file") || p.Sets("script")
+}
+
+func LoadPhases(path string) ([]Phase, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to open --phases")
+	}
+	defer file.Close()
+	phases, err := ParsePhases(file)
+	if err != nil {
+		return nil, errors.Wrapf(err, "invalid --phases %s", path)
+	}
+	return phases, nil
+}
+
+func ParsePhases(in io.Reader) ([]Phase, error) {
+	phases := make([]Phase, 0)
+	names := make(map[string]bool)
+	scanner := bufio.NewScanner(in)
+	for lineNo := 1; scanner.Scan(); lineNo++ {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		colon := strings.Index(line, ":")
+		if colon < 1 || strings.ContainsAny(line[:colon], " \t") {
+			return nil, fmt.Errorf("line %d should be a name, a colon and options, like steady: -c 16 -d 10m, got '%s'", lineNo, line)
+		}
+		name := line[:colon]
+		if names[name] {
+			return nil, fmt.Errorf("line %d has the name of a phase before it, '%s'", lineNo, name)
+		}
+		names[name] = true
+		phase, err := parsePhase(name, strings.TrimSpace(line[colon+1:]))
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %s", lineNo, err)
+		}
+		phases = append(phases, phase)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	if len(phases) == 0 {
+		return nil, fmt.Errorf("no phases, expected lines like steady: -c 16 -d 10m")
+	}
+	return phases, nil
+}
+
+func parsePhase(name, options string) (Phase, error) {
+	phase := Phase{Name: name, Options: options, set: make(map[string]bool)}
+	args, err := splitOptions(options)
+	if err != nil {
+		return phase, err
+	}
+	fs := pflag.NewFlagSet(name, pflag.ContinueOnError)
+	fs.SetOutput(ioutil.Discard)
+	fs.StringSliceVarP(&phase.Builtins, "builtin", "b", nil, "")
+	fs.StringSliceVarP(&phase.Files, "file", "f", nil, "")
+	fs.StringArrayVarP(&phase.Scripts, "script", "S", nil, "")
+	fs.IntVarP(&phase.Clients, "clients", "c", 0, "")
+	fs.DurationVarP(&phase.Duration, "duration", "d", 0, "")
+	fs.Uint64VarP(&phase.Transactions, "transactions", "t", 0, "")
+	fs.BoolVarP(&phase.LatencyMode, "latency", "l", false, "")
+	fs.Float64VarP(&phase.Rate, "rate", "r", 0, "")
+	if err := fs.Parse(args); err != nil {
+		return phase, fmt.Errorf("%s; phases can set -b, -f, -S, -c, -d, -t, -l and -r", err)
+	}
+	if fs.NArg() > 0 {
+		return phase, fmt.Errorf("phases only take options, got '%s'", fs.Arg(0))
+	}
+	fs.Visit(func(f *pflag.Flag) {
+		phase.set[f.Name] = true
+	})
+	if phase.Sets("clients") && phase.Clients < 1 {
+		return phase, fmt.Errorf("-c must be at least 1, got %d", phase.Clients)
+	}
+	return phase, nil
+}
+
+// Splits options on whitespace, keeping anything in single or double quotes together, like a shell would
+func splitOptions(raw string) ([]string, error) {
+	args := make([]string, 0)
+	current := strings.Builder{}
+	inArg := false
+	var quote rune
+	for _, c := range raw {
+		switch {
+		case quote != 0 && c == quote:
+			quote = 0
+		case quote != 0:
+			current.WriteRune(c)
+		case c == '"' || c == '\'':
+			quote = c
+			inArg = true
+		case c == ' ' || c == '\t':
+			if inArg {
+				args = append(args, current.String())
+				current.Reset()
+				inArg = false
+			}
+		default:
+			current.WriteRune(c)
+			inArg = true
+		}
+	}
+	if quote != 0 {
+		return nil, fmt.Errorf("unterminated %c quote", quote)
+	}
+	if inArg {
+		args = append(args, current.String())
+	}
+	return args, nil
+}
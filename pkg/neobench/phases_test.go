@@ -0,0 +1,77 @@
+package neobench
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParsePhases(t *testing.T) {
+	phases, err := ParsePhases(strings.NewReader(`# load, then measure
+load: -f load.script -c 8 -t 10000
+
+steady: -b tpcb-like --clients 16 -l -r 500 -d 10m
+probe: -S "RETURN 1;" -S 'RETURN "two";'
+`))
+	assert.NoError(t, err)
+	assert.Len(t, phases, 3)
+
+	load := phases[0]
+	assert.Equal(t, "load", load.Name)
+	assert.Equal(t, "-f load.script -c 8 -t 10000", load.Options)
+	assert.Equal(t, []string{"load.script"}, load.Files)
+	assert.Equal(t, 8, load.Clients)
+	assert.Equal(t, uint64(10000), load.Transactions)
+	assert.True(t, load.SetsWorkload())
+	assert.True(t, load.Sets("transactions"))
+	assert.False(t, load.Sets("duration"))
+	assert.False(t, load.Sets("latency"))
+
+	steady := phases[1]
+	assert.Equal(t, []string{"tpcb-like"}, steady.Builtins)
+	assert.Equal(t, 16, steady.Clients)
+	assert.True(t, steady.LatencyMode)
+	assert.Equal(t, float64(500), steady.Rate)
+	assert.Equal(t, 10*time.Minute, steady.Duration)
+
+	assert.Equal(t, []string{"RETURN 1;", `RETURN "two";`}, phases[2].Scripts)
+}
+
+func TestParsePhasesRejectsWhatItCantRun(t *testing.T) {
+	for raw, expected := range map[string]string{
+		"-c 4":                      "line 1 should be a name, a colon and options, like steady: -c 16 -d 10m, got '-c 4'",
+		"warm up: -c 4":             "line 1 should be a name, a colon and options, like steady: -c 16 -d 10m, got 'warm up: -c 4'",
+		"a: -c 4\na: -c 8":          "line 2 has the name of a phase before it, 'a'",
+		"a: --sweep x=1,2":          "line 1: unknown flag: --sweep; phases can set -b, -f, -S, -c, -d, -t, -l and -r",
+		"a: -c 4 extra":             "line 1: phases only take options, got 'extra'",
+		"a: -c 0":                   "line 1: -c must be at least 1, got 0",
+		"a: -S \"RETURN 1;":         "line 1: unterminated \" quote",
+		"# nothing but a comment\n": "no phases, expected lines like steady: -c 16 -d 10m",
+	} {
+		_, err := ParsePhases(strings.NewReader(raw))
+		assert.EqualError(t, err, expected, raw)
+	}
+}
+
+func TestPhasesReportHasARowPerPhase(t *testing.T) {
+	sweep := SweepResult{Scenario: "--phases phases.txt", Variable: "phase", Phases: true}
+	for _, name := range []string{"load", "steady"} {
+		w := NewWorkerResult(0)
+		assert.NoError(t, w.record("a", time.Millisecond, uowOutcome{succeeded: true}))
+		w.calculateRate(time.Second)
+		result := NewResult("", "")
+		assert.NoError(t, result.Add(w))
+		sweep.Values = append(sweep.Values, name)
+		sweep.Results = append(sweep.Results, result)
+	}
+
+	s := strings.Builder{}
+	(&InteractiveOutput{OutStream: &s}).ReportSweep(sweep)
+	assert.Equal(t, "== Phases ==\n"+
+		"Scenario: --phases phases.txt\n\n"+
+		"  phase  script succeeded failed tps  \n"+
+		"  load   a      1         0      1.000\n"+
+		"  steady a      1         0      1.000\n", s.String())
+}
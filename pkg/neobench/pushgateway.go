@@ -0,0 +1,132 @@
+package neobench
+
+import (
+	"fmt"
+	"io"
+	"net/url"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/push"
+)
+
+// Pushes metrics to a Prometheus Pushgateway at each progress report and once more when the run completes, see
+// --pushgateway. Unlike PrometheusOutput, this works for runs that Prometheus can't scrape, like ones on a laptop
+// or in a short-lived CI container.
+//
+// Progress pushes describe the interval since the previous one; the final push has the results of the whole
+// run, with neobench_completed set to 1. Tags, see --tag, are pushed as grouping labels, so runs with different
+// tags don't replace each other's metrics on the gateway; grouping labels are part of the push URL, so their
+// values can't have characters that would need escaping there, like / or spaces.
+type PushgatewayOutput struct {
+	ErrStream io.Writer
+
+	pusher *push.Pusher
+
+	succeeded *prometheus.CounterVec
+	failed    *prometheus.CounterVec
+	rate      *prometheus.GaugeVec
+	latency   *prometheus.GaugeVec
+	completed prometheus.Gauge
+}
+
+func NewPushgatewayOutput(target, job string, tags Tags, errStream io.Writer) (*PushgatewayOutput, error) {
+	for _, tag := range tags {
+		if url.PathEscape(tag.Value) != tag.Value {
+			return nil, fmt.Errorf("--tag %s=%s can't be pushed to --pushgateway, which puts tag values in the URL; "+
+				"use a value without /, spaces or other characters URLs escape", tag.Key, tag.Value)
+		}
+	}
+	p := &PushgatewayOutput{
+		ErrStream: errStream,
+		succeeded: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "neobench_successful_transactions_total",
+			Help: "The total number of successful transactions",
+		}, []string{"script"}),
+		failed: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "neobench_failed_transactions_total",
+			Help: "The total number of failed transactions",
+		}, []string{"script"}),
+		rate: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Name: "neobench_transactions_per_second",
+			Help: "Transactions per second, over the last progress interval or, once completed, the whole run",
+		}, []string{"script"}),
+		latency: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Name: "neobench_latency_milliseconds",
+			Help: "Latency percentiles, over the last progress interval or, once completed, the whole run",
+		}, []string{"script", "quantile"}),
+		completed: prometheus.NewGauge(prometheus.GaugeOpts{
+			Name: "neobench_completed",
+			Help: "1 once the run has completed and the other metrics describe the whole run, 0 before that",
+		}),
+	}
+	// Our own registry, so we don't push whatever else is in the default one, and don't clash with --prometheus
+	registry := prometheus.NewRegistry()
+	registry.MustRegister(p.succeeded, p.failed, p.rate, p.latency, p.completed)
+	p.pusher = push.New(target, job).Gatherer(registry)
+	for _, tag := range tags {
+		p.pusher = p.pusher.Grouping(tag.Key, tag.Value)
+	}
+	return p, nil
+}
+
+func (p *PushgatewayOutput) BenchmarkStart(databaseName, url, scenario string) {
+	p.completed.Set(0)
+}
+
+func (p *PushgatewayOutput) ReportInitProgress(report ProgressReport) {
+}
+
+func (p *PushgatewayOutput) ReportWorkloadProgress(completeness float64, checkpoint Result) {
+	for _, script := range checkpoint.Scripts {
+		p.succeeded.WithLabelValues(script.ScriptName).Add(float64(script.Succeeded))
+		p.failed.WithLabelValues(script.ScriptName).Add(float64(script.Failed))
+	}
+	p.setRates(checkpoint)
+	p.push()
+}
+
+func (p *PushgatewayOutput) ReportThroughput(result Result) {
+	p.complete(result)
+}
+
+func (p *PushgatewayOutput) ReportLatency(result Result) {
+	p.complete(result)
+}
+
+func (p *PushgatewayOutput) ReportSweep(sweep SweepResult) {
+}
+
+func (p *PushgatewayOutput) Errorf(format string, a ...interface{}) {
+}
+
+func (p *PushgatewayOutput) complete(result Result) {
+	p.setRates(result)
+	p.completed.Set(1)
+	p.push()
+}
+
+func (p *PushgatewayOutput) setRates(result Result) {
+	for _, script := range result.Scripts {
+		p.rate.WithLabelValues(script.ScriptName).Set(script.Rate)
+		if script.Succeeded == 0 {
+			continue
+		}
+		for _, q := range []struct {
+			label    string
+			quantile float64
+		}{{"0.5", 50}, {"0.95", 95}, {"0.99", 99}} {
+			p.latency.WithLabelValues(script.ScriptName, q.label).Set(float64(script.Latencies.ValueAtQuantile(q.quantile)) / 1000.0)
+		}
+	}
+}
+
+// A gateway that is down shouldn't stop the benchmark; we say so and carry on
+func (p *PushgatewayOutput) push() {
+	if err := p.pusher.Push(); err != nil {
+		if _, err := fmt.Fprintf(p.ErrStream, "WARNING: failed to push metrics to the pushgateway: %s\n", err); err != nil {
+			panic(err)
+		}
+	}
+}
+
+var _ Output = &PushgatewayOutput{}
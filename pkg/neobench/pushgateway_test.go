@@ -0,0 +1,76 @@
+package neobench
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPushgatewayPushesProgressAndFinalResult(t *testing.T) {
+	paths := make([]string, 0)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		paths = append(paths, r.Method+" "+r.URL.Path)
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer server.Close()
+
+	p, err := NewPushgatewayOutput(server.URL, "nightly", nil, &bytes.Buffer{})
+	assert.NoError(t, err)
+	p.BenchmarkStart("", "", "")
+
+	w := NewWorkerResult(0)
+	assert.NoError(t, w.record("a", 2*time.Millisecond, uowOutcome{succeeded: true}))
+	w.calculateRate(time.Second)
+	checkpoint := NewResult("", "")
+	assert.NoError(t, checkpoint.Add(w))
+	p.ReportWorkloadProgress(0.5, checkpoint)
+	assert.Equal(t, float64(0), testutil.ToFloat64(p.completed))
+	assert.Equal(t, float64(1), testutil.ToFloat64(p.succeeded.WithLabelValues("a")))
+	assert.Equal(t, float64(1), testutil.ToFloat64(p.rate.WithLabelValues("a")))
+	assert.Equal(t, float64(2), testutil.ToFloat64(p.latency.WithLabelValues("a", "0.99")))
+
+	p.ReportLatency(checkpoint)
+	assert.Equal(t, float64(1), testutil.ToFloat64(p.completed))
+
+	assert.Equal(t, []string{"PUT /metrics/job/nightly", "PUT /metrics/job/nightly"}, paths)
+}
+
+func TestPushgatewayGroupsByTags(t *testing.T) {
+	paths := make([]string, 0)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		paths = append(paths, r.URL.Path)
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer server.Close()
+
+	p, err := NewPushgatewayOutput(server.URL, "nightly", Tags{{"hardware", "m5"}}, &bytes.Buffer{})
+	assert.NoError(t, err)
+	p.ReportThroughput(NewResult("", ""))
+
+	assert.Equal(t, []string{"/metrics/job/nightly/hardware/m5"}, paths)
+
+	_, err = NewPushgatewayOutput(server.URL, "nightly", Tags{{"branch", "feature/x"}}, &bytes.Buffer{})
+	assert.EqualError(t, err, "--tag branch=feature/x can't be pushed to --pushgateway, which puts tag values in the URL; "+
+		"use a value without /, spaces or other characters URLs escape")
+	_, err = NewPushgatewayOutput(server.URL, "nightly", Tags{{"hardware", "m5 xlarge"}}, &bytes.Buffer{})
+	assert.Error(t, err)
+}
+
+func TestPushgatewayFailureDoesNotStopTheRun(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusInternalServerError)
+	}))
+	defer server.Close()
+
+	errStream := &bytes.Buffer{}
+	p, err := NewPushgatewayOutput(server.URL, "nightly", nil, errStream)
+	assert.NoError(t, err)
+	p.ReportThroughput(NewResult("", ""))
+
+	assert.Contains(t, errStream.String(), "WARNING: failed to push metrics to the pushgateway")
+}
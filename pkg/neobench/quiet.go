@@ -0,0 +1,84 @@
+package neobench
+
+import (
+	"fmt"
+	"io"
+	"strings"
+)
+
+// Prints nothing but a line with the totals of each run, see --quiet, for shell scripts that parse the output.
+// The line is space-separated key=value pairs, in the same order every time:
+//
+//	mode=throughput succeeded=1200 failed=0 tps=120.000 p50_ms=1.234 p95_ms=2.345 p99_ms=3.456 max_ms=4.567
+//
+// Latencies are in milliseconds, with percentiles following --percentiles, and left out if nothing succeeded.
+// Errors still go to ErrStream, progress isn't reported at all.
+type QuietOutput struct {
+	ErrStream io.Writer
+	OutStream io.Writer
+	// See --percentiles
+	Percentiles Percentiles
+}
+
+func (o *QuietOutput) BenchmarkStart(databaseName, url, scenario string) {
+}
+
+func (o *QuietOutput) ReportInitProgress(report ProgressReport) {
+}
+
+func (o *QuietOutput) ReportWorkloadProgress(completeness float64, checkpoint Result) {
+}
+
+func (o *QuietOutput) ReportThroughput(result Result) {
+	o.writeSummary(result, "throughput")
+}
+
+func (o *QuietOutput) ReportLatency(result Result) {
+	o.writeSummary(result, "latency")
+}
+
+func (o *QuietOutput) writeSummary(result Result, mode string) {
+	fields := []string{
+		"mode=" + mode,
+		fmt.Sprintf("succeeded=%d", result.TotalSucceeded()),
+		fmt.Sprintf("failed=%d", result.TotalFailed()),
+		fmt.Sprintf("tps=%.3f", result.TotalRate()),
+	}
+	if result.TotalSucceeded() > 0 {
+		latencies := result.CombinedLatencies()
+		for _, q := range o.Percentiles.or(Percentiles{50, 95, 99}).below(100) {
+			fields = append(fields, fmt.Sprintf("%s_ms=%.3f", percentileColumn(q), float64(latencies.ValueAtQuantile(q))/1000.0))
+		}
+		fields = append(fields, fmt.Sprintf("max_ms=%.3f", float64(latencies.Max())/1000.0))
+	}
+	if _, err := fmt.Fprintln(o.OutStream, strings.Join(fields, " ")); err != nil {
+		panic(err)
+	}
+}
+
+// Each run of the sweep already had its line; a --find-max-rate search ends with one for what it found,
+// mode=search max_rate=450.000, where 0 means no rate met the condition
+func (o *QuietOutput) ReportSweep(sweep SweepResult) {
+	if sweep.MaxRateCondition == "" {
+		return
+	}
+	if _, err := fmt.Fprintf(o.OutStream, "mode=search max_rate=%.3f\n", sweep.MaxRate); err != nil {
+		panic(err)
+	}
+}
+
+func (o *QuietOutput) Noticef(format string, a ...interface{}) {
+	_, err := fmt.Fprintf(o.ErrStream, "%s\n", fmt.Sprintf(format, a...))
+	if err != nil {
+		panic(err)
+	}
+}
+
+func (o *QuietOutput) Errorf(format string, a ...interface{}) {
+	_, err := fmt.Fprintf(o.ErrStream, "ERROR: %s\n", fmt.Sprintf(format, a...))
+	if err != nil {
+		panic(err)
+	}
+}
+
+var _ Output = &QuietOutput{}
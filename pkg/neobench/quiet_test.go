@@ -0,0 +1,40 @@
+package neobench
+
+import (
+	"bytes"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestQuietOutputPrintsOnlyASummaryLine(t *testing.T) {
+	w := NewWorkerResult(0)
+	assert.NoError(t, w.record("a", time.Millisecond, uowOutcome{succeeded: true}))
+	assert.NoError(t, w.record("b", 2*time.Millisecond, uowOutcome{succeeded: true}))
+	assert.NoError(t, w.record("b", time.Millisecond, uowOutcome{succeeded: false, failureGroup: "unknown", err: fmt.Errorf("boom")}))
+	w.calculateRate(time.Second)
+	result := NewResult("", "-c 1")
+	assert.NoError(t, result.Add(w))
+
+	out, errs := &bytes.Buffer{}, &bytes.Buffer{}
+	o := &QuietOutput{ErrStream: errs, OutStream: out, Percentiles: Percentiles{50, 99.9}}
+	o.BenchmarkStart("neo4j", "neo4j://localhost:7687", "-c 1")
+	o.ReportWorkloadProgress(0.5, result)
+	o.ReportLatency(result)
+	o.ReportLatency(NewResult("", "-c 1"))
+
+	assert.Equal(t, "mode=latency succeeded=2 failed=1 tps=3.000 p50_ms=1.000 p999_ms=2.000 max_ms=2.000\n"+
+		"mode=latency succeeded=0 failed=0 tps=0.000\n", out.String())
+	assert.Empty(t, errs.String())
+}
+
+func TestQuietLeavesStdoutToTheSummaryLine(t *testing.T) {
+	_, err := InitOutput(OutputConfig{Quiet: true, Sinks: []OutputSink{ParseOutputSink("json")}})
+	assert.EqualError(t, err, "--quiet only prints a summary line on stdout, write the other outputs to files, ex: -o json:results.json")
+
+	out, err := InitOutput(OutputConfig{Quiet: true})
+	assert.NoError(t, err)
+	assert.IsType(t, &QuietOutput{}, out)
+}
@@ -0,0 +1,75 @@
+package neobench
+
+import (
+	"fmt"
+	"strings"
+)
+
+// The search stops once the highest rate that met the condition is within this fraction of the lowest that didn't
+const rateSearchTolerance = 0.05
+
+// Each probe is a run of its own, so the search is cut short rather than going on for hours
+const rateSearchMaxProbes = 10
+
+// Finds the highest total rate where a latency condition holds, see --find-max-rate, by bisecting between zero
+// and the rate of an unthrottled run. A rate is sustainable if the run at it kept up, see rateSweepSaturated,
+// and met the condition; past the rate the server can keep up with, latencies climb steeply, so the search
+// closes in on the knee of the throughput-latency curve.
+type RateSearch struct {
+	Condition Assertion
+
+	// Highest rate found sustainable so far, with its result, and lowest found not to be
+	low, high float64
+	best      *Result
+	probes    int
+}
+
+// Parses the --find-max-rate condition, a latency metric that must stay under a value, ex: p99<20ms
+func ParseRateSearchCondition(raw string) (Assertion, error) {
+	a, err := ParseAssertion(raw)
+	latency := a.Metric == "mean" || a.Metric == "max" || strings.HasPrefix(a.Metric, "p")
+	if err != nil || !latency || (a.Operator != "<" && a.Operator != "<=") {
+		return Assertion{}, fmt.Errorf("--find-max-rate should be a latency, like p99, mean or max, < or <= and a value, ex: p99<20ms, got %s", raw)
+	}
+	return a, nil
+}
+
+// ceiling is the rate of an unthrottled run, which no target rate can do better than
+func NewRateSearch(condition Assertion, ceiling float64) (*RateSearch, error) {
+	if ceiling <= 0 {
+		return nil, fmt.Errorf("the unthrottled run for --find-max-rate completed no transactions, so there is no rate to search from")
+	}
+	return &RateSearch{Condition: condition, high: ceiling}, nil
+}
+
+// The rate to probe next; false once the search is done
+func (s *RateSearch) Next() (float64, bool) {
+	if s.probes >= rateSearchMaxProbes || s.high-s.low <= s.high*rateSearchTolerance {
+		return 0, false
+	}
+	return (s.low + s.high) / 2, true
+}
+
+// Records the result of the run at rate, and returns whether the rate was sustainable; a run cut short by
+// --max-error-rate never is
+func (s *RateSearch) Record(rate float64, result Result) bool {
+	s.probes++
+	sustainable := result.Aborted == "" && result.TotalRate() >= rate*rateSweepSaturated && s.Condition.check(result) == ""
+	if sustainable {
+		s.low = rate
+		s.best = &result
+	} else {
+		s.high = rate
+	}
+	return sustainable
+}
+
+// The highest rate found sustainable, zero if none was
+func (s *RateSearch) MaxRate() float64 {
+	return s.low
+}
+
+// The result of the run at MaxRate; nil if no rate was sustainable
+func (s *RateSearch) Best() *Result {
+	return s.best
+}
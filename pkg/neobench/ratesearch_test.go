@@ -0,0 +1,105 @@
+package neobench
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// A run that achieved the given rate, with every transaction taking latency
+func rateSearchResult(t *testing.T, achieved float64, latency time.Duration) Result {
+	w := NewWorkerResult(0)
+	for i := 0; i < 10; i++ {
+		assert.NoError(t, w.record("s", latency, uowOutcome{succeeded: true}))
+	}
+	w.calculateRate(time.Duration(float64(10*time.Second) / achieved))
+	result := NewResult("", "")
+	assert.NoError(t, result.Add(w))
+	return result
+}
+
+func TestRateSearchFindsTheKnee(t *testing.T) {
+	condition, err := ParseRateSearchCondition("p99<20ms")
+	assert.NoError(t, err)
+	search, err := NewRateSearch(condition, 1000)
+	assert.NoError(t, err)
+
+	// The server keeps up to 800 tps, but latency goes past the limit above 600
+	tried := make([]float64, 0)
+	for rate, ok := search.Next(); ok; rate, ok = search.Next() {
+		tried = append(tried, rate)
+		achieved, latency := rate, 5*time.Millisecond
+		if rate > 600 {
+			latency = 50 * time.Millisecond
+		}
+		if rate > 800 {
+			achieved = 800
+		}
+		search.Record(rate, rateSearchResult(t, achieved, latency))
+	}
+
+	assert.Equal(t, []float64{500, 750, 625, 562.5, 593.75}, tried)
+	assert.Equal(t, 593.75, search.MaxRate())
+	assert.InDelta(t, 593.75, search.Best().TotalRate(), 0.01)
+}
+
+func TestRateSearchNeedsTheServerToKeepUp(t *testing.T) {
+	condition, err := ParseRateSearchCondition("p99<20ms")
+	assert.NoError(t, err)
+	search, err := NewRateSearch(condition, 1000)
+	assert.NoError(t, err)
+
+	// Latencies are fine, but at half of what was asked for
+	assert.False(t, search.Record(500, rateSearchResult(t, 250, time.Millisecond)))
+	assert.True(t, search.Record(250, rateSearchResult(t, 250, time.Millisecond)))
+	assert.Equal(t, float64(250), search.MaxRate())
+}
+
+func TestRateSearchWithNothingSustainable(t *testing.T) {
+	condition, err := ParseRateSearchCondition("p99<1ms")
+	assert.NoError(t, err)
+	search, err := NewRateSearch(condition, 1000)
+	assert.NoError(t, err)
+
+	probes := 0
+	for rate, ok := search.Next(); ok; rate, ok = search.Next() {
+		probes++
+		search.Record(rate, rateSearchResult(t, rate, 10*time.Millisecond))
+	}
+
+	assert.Equal(t, rateSearchMaxProbes, probes)
+	assert.Equal(t, float64(0), search.MaxRate())
+	assert.Nil(t, search.Best())
+
+	_, err = NewRateSearch(condition, 0)
+	assert.Error(t, err)
+}
+
+func TestParseRateSearchCondition(t *testing.T) {
+	a, err := ParseRateSearchCondition("p99.9<=1.5s")
+	assert.NoError(t, err)
+	assert.Equal(t, Assertion{Raw: "p99.9<=1.5s", Metric: "p99.9", Operator: "<=", Value: 1500, percentile: 99.9}, a)
+
+	for _, raw := range []string{"tps>100", "p99>20ms", "p99"} {
+		_, err = ParseRateSearchCondition(raw)
+		assert.EqualError(t, err, "--find-max-rate should be a latency, like p99, mean or max, < or <= and a value, ex: p99<20ms, got "+raw)
+	}
+}
+
+func TestRateSweepReportLeadsWithTheMaxRate(t *testing.T) {
+	sweep := SweepResult{Scenario: "--find-max-rate \"p99<20ms\"", RateSweep: true, LatencyMode: true,
+		MaxRateCondition: "p99<20ms", MaxRate: 500}
+	sweep.Values = append(sweep.Values, float64(500))
+	sweep.Results = append(sweep.Results, rateSearchResult(t, 500, time.Millisecond))
+
+	s := strings.Builder{}
+	writeRateSweepReport(sweep, &s)
+
+	assert.Contains(t, s.String(), "Scenario: --find-max-rate \"p99<20ms\"\nMax sustainable rate: 500.000 tps with p99<20ms\n\n")
+
+	out := strings.Builder{}
+	(&QuietOutput{OutStream: &out}).ReportSweep(SweepResult{MaxRateCondition: "p99<20ms"})
+	assert.Equal(t, "mode=search max_rate=0.000\n", out.String())
+}
@@ -0,0 +1,97 @@
+package neobench
+
+import (
+	"fmt"
+
+	"github.com/neo4j/neo4j-go-driver/v4/neo4j"
+	"github.com/pkg/errors"
+)
+
+// Failures of :assert are grouped under this rather than under an error code, see groupError
+const AssertionFailureGroup = "assertion"
+
+// Names the records of the statement go by in an :assert
+const RowCountVar = "rowcount"
+const RowsVar = "rows"
+const RowVar = "row"
+
+// An :assert on what a statement returned; it's checked once the statement has run, with the variables of the
+// script as they were when the statement was evaluated, and the records it returned as $rows, the first of them
+// as $row and how many there were as $rowcount
+type ResultAssertion struct {
+	Expr Expression
+	// As written in the script
+	Text string
+	ctx  *ScriptContext
+}
+
+// What a failed :assert fails the transaction with
+type assertionError struct {
+	assertion string
+	rows      int
+}
+
+func (e *assertionError) Error() string {
+	rows := "rows"
+	if e.rows == 1 {
+		rows = "row"
+	}
+	return fmt.Sprintf(":assert %s failed, the query returned %d %s", e.assertion, e.rows, rows)
+}
+
+// Sets up the assertions of a query, with a copy of the variables as they are now, since later commands of the
+// script can change them before the query runs
+func newResultAssertions(parsed []ResultAssertion, ctx *ScriptContext) []ResultAssertion {
+	if len(parsed) == 0 {
+		return nil
+	}
+	checkCtx := *ctx
+	checkCtx.Vars = make(map[string]interface{}, len(ctx.Vars)+3)
+	for name, value := range ctx.Vars {
+		checkCtx.Vars[name] = value
+	}
+	assertions := make([]ResultAssertion, 0, len(parsed))
+	for _, assertion := range parsed {
+		assertion.ctx = &checkCtx
+		assertions = append(assertions, assertion)
+	}
+	return assertions
+}
+
+// Checks the records a statement returned against its assertions; the error is an assertionError if one of them
+// was false
+func checkResult(assertions []ResultAssertion, records []*neo4j.Record) error {
+	if len(assertions) == 0 {
+		return nil
+	}
+	rows := make([]interface{}, 0, len(records))
+	for _, record := range records {
+		row := make(map[string]interface{}, len(record.Keys))
+		for i, key := range record.Keys {
+			row[key] = record.Values[i]
+		}
+		rows = append(rows, row)
+	}
+	var first interface{}
+	if len(rows) > 0 {
+		first = rows[0]
+	}
+	for _, assertion := range assertions {
+		ctx := assertion.ctx
+		ctx.Vars[RowCountVar] = int64(len(rows))
+		ctx.Vars[RowsVar] = rows
+		ctx.Vars[RowVar] = first
+		result, err := assertion.Expr.Eval(ctx)
+		if err != nil {
+			return errors.Wrapf(err, "in :assert %s", assertion.Text)
+		}
+		holds, ok := result.(bool)
+		if !ok {
+			return fmt.Errorf(":assert needs an expression that is true or false, got %v from %s", result, assertion.Text)
+		}
+		if !holds {
+			return &assertionError{assertion: assertion.Text, rows: len(rows)}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,66 @@
+package neobench
+
+import (
+	"math/rand"
+	"testing"
+
+	"github.com/neo4j/neo4j-go-driver/v4/neo4j"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestResultAssertions(t *testing.T) {
+	records := []*neo4j.Record{
+		{Keys: []string{"aid", "balance"}, Values: []interface{}{int64(7), int64(100)}},
+		{Keys: []string{"aid", "balance"}, Values: []interface{}{int64(8), int64(-5)}},
+	}
+	for assertion, expected := range map[string]string{
+		`$rowcount = 2`:                     "",
+		`$rowcount == 1`:                    ":assert $rowcount == 1 failed, the query returned 2 rows",
+		`$row["aid"] = $aid`:                "",
+		`$row["balance"] >= 0`:              "",
+		`$rows[1]["balance"] >= 0`:          ":assert $rows[1][\"balance\"] >= 0 failed, the query returned 2 rows",
+		`len($rows) = $rowcount`:            "",
+		`$row["aid"] = 8`:                   ":assert $row[\"aid\"] = 8 failed, the query returned 2 rows",
+		`$rowcount + 1`:                     ":assert needs an expression that is true or false, got 3 from $rowcount + 1",
+		`[x in $rows | $x["aid"]] = [7, 8]`: "",
+	} {
+		script, err := Parse("assert", "MATCH (a:Account) RETURN a.aid AS aid, a.balance AS balance;\n:assert "+assertion, 1)
+		assert.NoError(t, err, assertion)
+		uow, err := script.Eval(ScriptContext{
+			Vars: map[string]interface{}{"aid": int64(7)},
+			Rand: rand.New(rand.NewSource(1337)),
+		})
+		assert.NoError(t, err, assertion)
+
+		err = checkResult(uow.Statements[0].Assertions, records)
+		if expected == "" {
+			assert.NoError(t, err, assertion)
+		} else {
+			assert.EqualError(t, err, expected, assertion)
+		}
+	}
+}
+
+func TestResultAssertionsSeeVariablesAsTheyWereForTheQuery(t *testing.T) {
+	script, err := Parse("assert", `:set n 1
+RETURN 1 AS n;
+:assert $row["n"] = $n
+:set n 2`, 1)
+	assert.NoError(t, err)
+	uow, err := script.Eval(ScriptContext{
+		Vars: map[string]interface{}{},
+		Rand: rand.New(rand.NewSource(1337)),
+	})
+	assert.NoError(t, err)
+
+	err = checkResult(uow.Statements[0].Assertions, []*neo4j.Record{{Keys: []string{"n"}, Values: []interface{}{int64(1)}}})
+	assert.NoError(t, err)
+}
+
+func TestResultAssertionMustFollowAQuery(t *testing.T) {
+	for _, script := range []string{":assert $rowcount = 1", "RETURN 1;\n:set a 1\n:assert $rowcount = 1"} {
+		_, err := Parse("assert", script, 1)
+		assert.Error(t, err, script)
+		assert.Contains(t, err.Error(), ":assert needs a query right before it, whose result it checks", script)
+	}
+}
@@ -0,0 +1,64 @@
+package neobench
+
+import (
+	"math/rand"
+	"strings"
+	"time"
+
+	"github.com/neo4j/neo4j-go-driver/v4/neo4j"
+	"github.com/pkg/errors"
+)
+
+// How long a script waits before it retries a transaction, see RetryPolicy
+type Backoff int
+
+const (
+	// 10ms before the first retry, doubling with each retry after, up to a second
+	BackoffExponential Backoff = 0
+	// 10ms more before each retry than before the one before it
+	BackoffLinear Backoff = 1
+	// Retries right away
+	BackoffNone Backoff = 2
+)
+
+// How a script retries transactions that fail with a transient error, set by :retry. Without one, the driver
+// retries transactions for up to 30 seconds, and the auto-commit queries of a unit have 20 attempts between
+// them, for any error.
+type RetryPolicy struct {
+	// How many times a transaction, or an auto-commit query, is tried again after its first attempt; zero to fail
+	// it on the first error
+	Retries int
+	Backoff Backoff
+}
+
+// How long to wait before the given retry, counting from 1, with up to a fifth more on top, drawn from r, so
+// clients that failed together don't all retry together
+func (p RetryPolicy) delay(retry int, r *rand.Rand) time.Duration {
+	var delay time.Duration
+	switch p.Backoff {
+	case BackoffExponential:
+		delay = time.Second
+		if retry <= 7 {
+			delay = 10 * time.Millisecond << (retry - 1)
+		}
+	case BackoffLinear:
+		delay = time.Duration(retry) * 10 * time.Millisecond
+	case BackoffNone:
+		return 0
+	}
+	return delay + time.Duration(r.Int63n(int64(delay/5)+1))
+}
+
+// Whether a retry policy retries a transaction that failed with the error: transient errors are retried, like
+// deadlocks and lock timeouts, other than the transaction being terminated, as are lost connections, though
+// retryTransaction doesn't retry those on commit
+func retryable(err error) bool {
+	if neoErr, ok := errors.Cause(err).(*neo4j.Neo4jError); ok {
+		switch neoErr.Code {
+		case "Neo.TransientError.Transaction.Terminated", "Neo.TransientError.Transaction.LockClientStopped":
+			return false
+		}
+		return strings.HasPrefix(neoErr.Code, "Neo.TransientError.")
+	}
+	return neo4j.IsConnectivityError(errors.Cause(err))
+}
@@ -0,0 +1,81 @@
+package neobench
+
+import (
+	"fmt"
+	"math/rand"
+	"testing"
+	"time"
+
+	"github.com/neo4j/neo4j-go-driver/v4/neo4j"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseRetry(t *testing.T) {
+	for script, expected := range map[string]*RetryPolicy{
+		"RETURN 1;":                          nil,
+		":retry 5\nRETURN 1;":                {Retries: 5, Backoff: BackoffExponential},
+		":retry 0\nRETURN 1;":                {Retries: 0, Backoff: BackoffExponential},
+		":retry 3 backoff=linear\nRETURN 1;": {Retries: 3, Backoff: BackoffLinear},
+		"RETURN 1;\n:retry 3 backoff=none":   {Retries: 3, Backoff: BackoffNone},
+		":retry 10 backoff = exp\nRETURN 1;": {Retries: 10, Backoff: BackoffExponential},
+	} {
+		parsed, err := Parse("retry", script, 1)
+		assert.NoError(t, err, script)
+		assert.Equal(t, expected, parsed.Retry, script)
+	}
+
+	for script, expected := range map[string]string{
+		":retry":                ":retry needs how many times to retry a failed transaction, like :retry 5, got '' (at retry:1:7)",
+		":retry -1":             ":retry needs how many times to retry a failed transaction, like :retry 5, got '-' (at retry:1:9)",
+		":retry 5 jitter=on":    ":retry can set backoff=exp, backoff=linear or backoff=none after the number of retries, got 'jitter' (at retry:1:16)",
+		":retry 5 backoff=fast": ":retry backoff needs to be exp, linear or none, got 'fast' (at retry:1:22)",
+	} {
+		_, err := Parse("retry", script, 1)
+		assert.EqualError(t, err, expected, script)
+	}
+}
+
+func TestRetryPolicyDelay(t *testing.T) {
+	for _, tc := range []struct {
+		policy RetryPolicy
+		retry  int
+		least  time.Duration
+	}{
+		{RetryPolicy{Backoff: BackoffExponential}, 1, 10 * time.Millisecond},
+		{RetryPolicy{Backoff: BackoffExponential}, 3, 40 * time.Millisecond},
+		{RetryPolicy{Backoff: BackoffExponential}, 20, time.Second},
+		{RetryPolicy{Backoff: BackoffLinear}, 1, 10 * time.Millisecond},
+		{RetryPolicy{Backoff: BackoffLinear}, 3, 30 * time.Millisecond},
+		{RetryPolicy{Backoff: BackoffNone}, 3, 0},
+	} {
+		delay := tc.policy.delay(tc.retry, rand.New(rand.NewSource(1337)))
+		assert.GreaterOrEqual(t, int64(delay), int64(tc.least), "%v, retry %d", tc.policy, tc.retry)
+		assert.LessOrEqual(t, int64(delay), int64(tc.least+tc.least/5), "%v, retry %d", tc.policy, tc.retry)
+	}
+
+	// The jitter comes from the random source it's given, so the same seed backs off the same
+	policy := RetryPolicy{Backoff: BackoffExponential}
+	assert.Equal(t, policy.delay(5, rand.New(rand.NewSource(42))), policy.delay(5, rand.New(rand.NewSource(42))))
+}
+
+func TestRetryable(t *testing.T) {
+	for err, expected := range map[error]bool{
+		&neo4j.Neo4jError{Code: "Neo.TransientError.Transaction.DeadlockDetected"}:  true,
+		&neo4j.Neo4jError{Code: "Neo.TransientError.Transaction.LockClientStopped"}: false,
+		&neo4j.Neo4jError{Code: "Neo.ClientError.Transaction.TransactionTimedOut"}:  false,
+		&neo4j.ConnectivityError{}:                  true,
+		fmt.Errorf("something else"):                false,
+		&assertionError{assertion: "$rowcount = 1"}: false,
+	} {
+		assert.Equal(t, expected, retryable(err), "%v", err)
+	}
+}
+
+func TestRetryPolicyBeginsReadOnlyTransactionsInReadSessions(t *testing.T) {
+	script, err := Parse("retry", ":retry 3\nMATCH (a) RETURN a;", 1)
+	assert.NoError(t, err)
+	script.Readonly = true
+	uow, err := script.Eval(ScriptContext{Vars: map[string]interface{}{}, Rand: rand.New(rand.NewSource(1337))})
+	assert.NoError(t, err)
+	assert.Equal(t, neo4j.AccessModeRead, uow.AccessMode)
+}
@@ -0,0 +1,94 @@
+package neobench
+
+import (
+	"fmt"
+	"math"
+	"strings"
+)
+
+// Spread of a number across the runs of --runs
+type RunStats struct {
+	Mean, Min, Max, Stddev float64
+}
+
+// How the runs of --runs compare, by total rate and by median and p99 latency across all scripts, in milliseconds
+type RunSummary struct {
+	Rate, P50, P99 RunStats
+}
+
+func SummarizeRuns(results []Result) RunSummary {
+	rates := make([]float64, 0, len(results))
+	p50s := make([]float64, 0, len(results))
+	p99s := make([]float64, 0, len(results))
+	for _, result := range results {
+		latencies := result.CombinedLatencies()
+		rates = append(rates, result.TotalRate())
+		p50s = append(p50s, float64(latencies.ValueAtQuantile(50))/1000.0)
+		p99s = append(p99s, float64(latencies.ValueAtQuantile(99))/1000.0)
+	}
+	return RunSummary{Rate: runStats(rates), P50: runStats(p50s), P99: runStats(p99s)}
+}
+
+// The standard deviation is of the sample, so it's zero for a single run
+func runStats(values []float64) RunStats {
+	if len(values) == 0 {
+		return RunStats{}
+	}
+	stats := RunStats{Min: values[0], Max: values[0]}
+	sum := 0.0
+	for _, v := range values {
+		sum += v
+		stats.Min = math.Min(stats.Min, v)
+		stats.Max = math.Max(stats.Max, v)
+	}
+	stats.Mean = sum / float64(len(values))
+	if len(values) > 1 {
+		squares := 0.0
+		for _, v := range values {
+			squares += (v - stats.Mean) * (v - stats.Mean)
+		}
+		stats.Stddev = math.Sqrt(squares / float64(len(values)-1))
+	}
+	return stats
+}
+
+// A row per run with its totals, then the mean, min, max and stddev across them
+func writeRunsReport(sweep SweepResult, s *strings.Builder) {
+	s.WriteString("== Runs ==\n")
+	s.WriteString(fmt.Sprintf("Scenario: %s\n", sweep.Scenario))
+	s.WriteString("\n")
+	rows := [][]string{{"run", "succeeded", "failed", "tps", "p50(ms)", "p99(ms)"}}
+	for i, result := range sweep.Results {
+		latencies := result.CombinedLatencies()
+		rows = append(rows, []string{
+			fmt.Sprintf("%v", sweep.Values[i]),
+			fmt.Sprintf("%d", result.TotalSucceeded()),
+			fmt.Sprintf("%d", result.TotalFailed()),
+			fmt.Sprintf("%.3f", result.TotalRate()),
+			fmt.Sprintf("%.3f", float64(latencies.ValueAtQuantile(50))/1000.0),
+			fmt.Sprintf("%.3f", float64(latencies.ValueAtQuantile(99))/1000.0),
+		})
+	}
+	writeTable(rows, s)
+	s.WriteString("\n")
+
+	summary := SummarizeRuns(sweep.Results)
+	rows = [][]string{{"", "tps", "p50(ms)", "p99(ms)"}}
+	for _, stat := range []struct {
+		name  string
+		value func(RunStats) float64
+	}{
+		{"mean", func(r RunStats) float64 { return r.Mean }},
+		{"min", func(r RunStats) float64 { return r.Min }},
+		{"max", func(r RunStats) float64 { return r.Max }},
+		{"stddev", func(r RunStats) float64 { return r.Stddev }},
+	} {
+		rows = append(rows, []string{
+			stat.name,
+			fmt.Sprintf("%.3f", stat.value(summary.Rate)),
+			fmt.Sprintf("%.3f", stat.value(summary.P50)),
+			fmt.Sprintf("%.3f", stat.value(summary.P99)),
+		})
+	}
+	writeTable(rows, s)
+}
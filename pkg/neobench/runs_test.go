@@ -0,0 +1,48 @@
+package neobench
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRunStats(t *testing.T) {
+	stats := runStats([]float64{2, 4, 4, 4, 5, 5, 7, 9})
+	assert.Equal(t, float64(5), stats.Mean)
+	assert.Equal(t, float64(2), stats.Min)
+	assert.Equal(t, float64(9), stats.Max)
+	assert.InDelta(t, 2.138, stats.Stddev, 0.001)
+
+	assert.Equal(t, RunStats{Mean: 3, Min: 3, Max: 3}, runStats([]float64{3}))
+}
+
+func TestRunsReportHasARowPerRunAndTheSpreadAcrossThem(t *testing.T) {
+	sweep := SweepResult{Scenario: "-c 1 --runs 2", Variable: "run", Runs: true}
+	for run, count := range []int{1, 3} {
+		w := NewWorkerResult(0)
+		for i := 0; i < count; i++ {
+			assert.NoError(t, w.record("a", time.Millisecond, uowOutcome{succeeded: true}))
+		}
+		w.calculateRate(time.Second)
+		result := NewResult("", "")
+		assert.NoError(t, result.Add(w))
+		sweep.Values = append(sweep.Values, int64(run+1))
+		sweep.Results = append(sweep.Results, result)
+	}
+
+	summary := SummarizeRuns(sweep.Results)
+	assert.Equal(t, float64(2), summary.Rate.Mean)
+	assert.InDelta(t, 1.414, summary.Rate.Stddev, 0.001)
+	assert.InDelta(t, 1, summary.P99.Mean, 0.01)
+
+	s := strings.Builder{}
+	(&InteractiveOutput{OutStream: &s}).ReportSweep(sweep)
+	out := s.String()
+	assert.True(t, strings.HasPrefix(out, "== Runs ==\nScenario: -c 1 --runs 2\n\n"), out)
+	assert.Contains(t, out, "  1   1         0      1.000")
+	assert.Contains(t, out, "  2   3         0      3.000")
+	assert.Contains(t, out, "  mean   2.000")
+	assert.Contains(t, out, "  stddev 1.414")
+}
@@ -0,0 +1,58 @@
+package neobench
+
+import (
+	"sync"
+	"time"
+)
+
+// Hands out the intended start times of transactions at a total rate, shared by the clients of a run in latency
+// mode, like a token bucket: whichever client is free takes the next start time. A client that stalls on a slow
+// transaction doesn't hold up the ones due after it, the others take them, so the total rate is what was asked for
+// as long as any client is free, and it doesn't have to divide evenly between the clients. When all of them are
+// busy, start times queue up, and latencies are from when each transaction was due, as without the schedule.
+//
+// Clients join as they start, each adding its share of the rate, so with --ramp the rate builds up over the ramp-up.
+type Schedule struct {
+	mut sync.Mutex
+	// Total transactions per second once all clients have joined
+	rate    float64
+	clients int
+	joined  int
+	// When the first client joined, and the time after it the next transaction is due, in nanoseconds; kept as a
+	// float, so intervals that aren't whole nanoseconds don't add up to a drift
+	origin time.Time
+	next   float64
+}
+
+func NewSchedule(rate float64, clients int) *Schedule {
+	return &Schedule{rate: rate, clients: clients}
+}
+
+// Adds a client, once it's ready to run
+func (s *Schedule) Join(now time.Time) {
+	s.mut.Lock()
+	defer s.mut.Unlock()
+	if s.joined == 0 {
+		s.origin = now
+	}
+	s.joined++
+}
+
+// Takes the next start time
+func (s *Schedule) Next() time.Time {
+	s.mut.Lock()
+	defer s.mut.Unlock()
+	start := s.origin.Add(time.Duration(s.next))
+	s.next += float64(time.Second) * float64(s.clients) / (s.rate * float64(s.joined))
+	return start
+}
+
+// Drops the start times due before now, so nothing that was due while the clients were held off, see Gate, is
+// offered all at once afterwards
+func (s *Schedule) Skip(now time.Time) {
+	s.mut.Lock()
+	defer s.mut.Unlock()
+	if elapsed := float64(now.Sub(s.origin)); elapsed > s.next {
+		s.next = elapsed
+	}
+}
@@ -0,0 +1,60 @@
+package neobench
+
+import (
+	"math/rand"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestScheduleHandsOutStartTimesAtTheTotalRate(t *testing.T) {
+	start := time.Date(2020, 1, 1, 1, 1, 1, 0, time.UTC)
+	// 3 tps between two clients doesn't divide evenly into whole microseconds, let alone per client
+	s := NewSchedule(3, 2)
+	s.Join(start)
+	// Only one client so far, which gets its share, half the rate
+	assert.Equal(t, start, s.Next())
+	assert.Equal(t, start.Add(666666666), s.Next())
+	s.Join(start.Add(time.Second))
+	assert.Equal(t, start.Add(1333333333), s.Next())
+	for i := 0; i < 2999; i++ {
+		s.Next()
+	}
+	// 3000 more at 3 tps, without drifting
+	assert.Equal(t, start.Add(1333333333+1000*time.Second), s.Next())
+}
+
+func TestScheduleSkipsWhatWasDueWhileHeldOff(t *testing.T) {
+	start := time.Date(2020, 1, 1, 1, 1, 1, 0, time.UTC)
+	s := NewSchedule(10, 1)
+	s.Join(start)
+	s.Next()
+	s.Skip(start.Add(time.Minute))
+	assert.Equal(t, start.Add(time.Minute), s.Next())
+	assert.Equal(t, start.Add(time.Minute+100*time.Millisecond), s.Next())
+	// Skipping to a time already past changes nothing
+	s.Skip(start)
+	assert.Equal(t, start.Add(time.Minute+200*time.Millisecond), s.Next())
+}
+
+func TestWorkerKeepsToTheSchedule(t *testing.T) {
+	r := rand.New(rand.NewSource(1337))
+	clock := &fakeSpaceTimeContinuum{}
+	clock.currentTime = time.Date(2020, 1, 1, 1, 1, 1, 1, time.UTC)
+	w := Worker{
+		workerId: 0,
+		driver:   &fakeDriver{clock: clock, r: r, minLatency: time.Millisecond, maxLatency: time.Millisecond},
+		now:      clock.now,
+		sleep:    clock.sleepUnlessStopped,
+	}
+	w.ShareSchedule(NewSchedule(3, 1))
+
+	start := clock.now()
+	result := w.RunBenchmark(newTestWorkload(r), "", time.Hour, 31, make(chan struct{}), nil, NewResultRecorder(0, time.Time{}))
+
+	assert.NoError(t, result.Error)
+	assert.Equal(t, int64(31), result.Scripts["workertest"].Succeeded)
+	// The transaction rate is ignored; the last of 31 starts 10 seconds in and takes a millisecond
+	assert.Equal(t, 10*time.Second+time.Millisecond, clock.now().Sub(start))
+}
@@ -1,11 +1,16 @@
 package neobench
 
 import (
+	cryptorand "crypto/rand"
 	"fmt"
+	"github.com/neo4j/neo4j-go-driver/v4/neo4j"
 	"github.com/pkg/errors"
+	"io/ioutil"
 	"math"
 	"math/rand"
+	"os"
 	"path/filepath"
+	"reflect"
 	"sort"
 	"strconv"
 	"strings"
@@ -18,11 +23,26 @@ func Parse(filename, script string, weight float64) (Script, error) {
 
 	var output = Script{
 		Name:       filename,
+		Source:     filename,
 		Readonly:   false, // this is determined by running explain on the query
 		Autocommit: false, // this is updated by setting `\opt autocommit` in your script
 		Weight:     weight,
 	}
 
+	if end := parseCommands(&output, c); end != "" {
+		c.fail(unmatchedEnd(end))
+	}
+
+	if c.err != nil {
+		return Script{}, c.err
+	}
+
+	return output, nil
+}
+
+// Parses commands into s.Commands up to the end of the script, or up to an :elif, :else, :endif or :endfor, which
+// it gives back the name of, for the :if or :for it belongs to
+func parseCommands(s *Script, c *parseContext) string {
 	for !c.done {
 		tok := c.PeekToken()
 		if tok == scanner.EOF {
@@ -32,32 +52,278 @@ func Parse(filename, script string, weight float64) (Script, error) {
 				"to align with the rest of the Neo4j ecosystem"))
 			break
 		} else if tok == ':' {
-			parseMetaCommand(&output, c)
+			if end := parseMetaCommand(s, c); end != "" {
+				return end
+			}
 		} else if tok == '\n' {
 			c.Next()
 		} else {
-			output.Commands = append(output.Commands, command(c))
+			query := command(c).(QueryCommand)
+			query.Id = c.nextStatementId()
+			if c.annotation != "" {
+				query.Autocommit, query.Timeout = c.annotations.Autocommit, c.annotations.Timeout
+				c.annotation, c.annotations = "", QueryCommand{}
+			}
+			s.Commands = append(s.Commands, query)
 		}
 	}
+	if c.annotation != "" {
+		c.fail(annotationWithoutQuery(c.annotation))
+	}
+	return ""
+}
 
-	if c.err != nil {
-		return Script{}, c.err
+// The options :autocommit and :timeout set for the query after them, see parseContext.annotations
+func (c *parseContext) annotated(cmd string) *QueryCommand {
+	if c.annotation == "" {
+		c.annotation = cmd
+	}
+	return &c.annotations
+}
+
+func annotationWithoutQuery(cmd string) error {
+	if cmd == "timeout" {
+		return fmt.Errorf(":timeout needs a query after it, which it bounds the transaction of")
 	}
+	return fmt.Errorf(":autocommit needs a query after it, which it runs as an auto-commit query")
+}
 
-	return output, nil
+// Parses the duration of :timeout or :opt timeout, to the end of the line
+func parseTimeout(c *parseContext, cmd string) time.Duration {
+	raw := restOfLine(c)
+	timeout, err := time.ParseDuration(raw)
+	if err != nil {
+		c.fail(fmt.Errorf("%s needs a duration, like %s 500ms, got '%s'", cmd, cmd, raw))
+		return 0
+	}
+	if timeout < time.Millisecond {
+		// The driver sends timeouts in whole milliseconds, and leaves out ones that round down to none
+		c.fail(fmt.Errorf("%s needs to be at least 1ms, got %s", cmd, raw))
+		return 0
+	}
+	return timeout
+}
+
+// Parses the policy of :retry <retries> [backoff=exp|linear|none]
+func parseRetry(c *parseContext) *RetryPolicy {
+	tok, content := c.Next()
+	retries, err := strconv.Atoi(content)
+	if tok != scanner.Int || err != nil {
+		c.fail(fmt.Errorf(":retry needs how many times to retry a failed transaction, like :retry 5, got '%s'", content))
+		return nil
+	}
+	policy := &RetryPolicy{Retries: retries}
+	switch c.PeekToken() {
+	case '\n', scanner.EOF:
+		return policy
+	}
+	if option := ident(c); option != "backoff" {
+		c.fail(fmt.Errorf(":retry can set backoff=exp, backoff=linear or backoff=none after the number of retries, got '%s'", option))
+		return nil
+	}
+	expect(c, '=')
+	switch backoff := ident(c); backoff {
+	case "exp":
+		policy.Backoff = BackoffExponential
+	case "linear":
+		policy.Backoff = BackoffLinear
+	case "none":
+		policy.Backoff = BackoffNone
+	default:
+		c.fail(fmt.Errorf(":retry backoff needs to be exp, linear or none, got '%s'", backoff))
+		return nil
+	}
+	return policy
 }
 
-func parseMetaCommand(s *Script, c *parseContext) {
+func parseMetaCommand(s *Script, c *parseContext) string {
 	expect(c, ':')
 	cmd := ident(c)
+	if c.annotation != "" && cmd != "autocommit" && cmd != "timeout" {
+		c.fail(annotationWithoutQuery(c.annotation))
+		return ""
+	}
 
 	switch cmd {
+	case "if":
+		parseIf(s, c)
+	case "for":
+		parseFor(s, c)
+	case "include":
+		parseInclude(s, c)
+	case "shell", "setshell":
+		varName := ""
+		if cmd == "setshell" {
+			varName = ident(c)
+		}
+		line := restOfLine(c)
+		if line == "" {
+			c.fail(fmt.Errorf(":%s needs a command to run, like :%s echo hello", cmd, cmd))
+			return ""
+		}
+		s.Commands = append(s.Commands, ShellCommand{
+			Line:    line,
+			VarName: varName,
+		})
+	case "params":
+		tok, content := c.Next()
+		if tok != scanner.String && tok != scanner.RawString {
+			c.fail(fmt.Errorf(":params needs a quoted path to a CSV file, like :params \"ids.csv\", got '%s'", content))
+			return ""
+		}
+		path, err := strconv.Unquote(content)
+		if err != nil {
+			c.fail(errors.Wrapf(err, "invalid string in :params"))
+			return ""
+		}
+		random := false
+		switch c.PeekToken() {
+		case '\n', scanner.EOF:
+			break
+		default:
+			_, order := c.Next()
+			switch order {
+			case "sequential":
+			case "random":
+				random = true
+			default:
+				c.fail(fmt.Errorf(":params command must use 'sequential' or 'random' row selection - or none. got: %s", order))
+				return ""
+			}
+		}
+		absPath, err := absPath(c.s.Filename, path)
+		if err != nil {
+			c.fail(errors.Wrapf(err, "failed resolving path %s relative to %s in :params", path, c.s.Filename))
+			return ""
+		}
+		// Loaded as the script is parsed, so a broken file fails right away, and the columns are known to
+		// --strict-params
+		file, err := LoadParamsFile(NewCsvLoader(), absPath, ParamsCycle)
+		if err != nil {
+			c.fail(err)
+			return ""
+		}
+		s.Commands = append(s.Commands, ParamsCommand{
+			File:   file,
+			Random: random,
+		})
+	case "autocommit":
+		c.annotated(cmd).Autocommit = true
+	case "timeout":
+		timeout := parseTimeout(c, ":timeout")
+		c.annotated(cmd).Timeout = timeout
+	case "assert":
+		var query QueryCommand
+		ok := false
+		if len(s.Commands) > 0 {
+			query, ok = s.Commands[len(s.Commands)-1].(QueryCommand)
+		}
+		if !ok {
+			c.fail(fmt.Errorf(":assert needs a query right before it, whose result it checks"))
+			return ""
+		}
+		text := c.src[c.s.Pos().Offset:]
+		if nl := strings.IndexByte(text, '\n'); nl != -1 {
+			text = text[:nl]
+		}
+		query.Assertions = append(query.Assertions, ResultAssertion{Expr: expr(c), Text: strings.TrimSpace(text)})
+		s.Commands[len(s.Commands)-1] = query
+	case "gset":
+		var query QueryCommand
+		ok := false
+		if len(s.Commands) > 0 {
+			query, ok = s.Commands[len(s.Commands)-1].(QueryCommand)
+		}
+		if !ok {
+			c.fail(fmt.Errorf(":gset needs a query right before it, whose first row it sets variables from"))
+			return ""
+		}
+		if c.init {
+			c.fail(fmt.Errorf(":gset can't be inside an :init, which is evaluated before any of it runs"))
+			return ""
+		}
+		if c.blocks > 0 {
+			// The script is evaluated up to the query, and goes on from the command after once it has run,
+			// which only works for commands at the top of the script
+			c.fail(fmt.Errorf(":gset can't be inside an :if or a :for, only in the script around them"))
+			return ""
+		}
+		if s.Shuffle {
+			c.fail(fmt.Errorf(":gset can't be used with :opt shuffle, the queries after it need to run after it"))
+			return ""
+		}
+		for tok := c.PeekToken(); tok != '\n' && tok != scanner.EOF; tok = c.PeekToken() {
+			query.Gset = append(query.Gset, ident(c))
+			if c.PeekToken() == ',' {
+				c.Next()
+			}
+		}
+		if len(query.Gset) == 0 {
+			c.fail(fmt.Errorf(":gset needs the columns to set variables from, like :gset balance"))
+			return ""
+		}
+		s.Commands[len(s.Commands)-1] = query
+		s.Gsets = true
+	case "name", "description":
+		text := restOfLine(c)
+		if text == "" && cmd == "name" {
+			c.fail(fmt.Errorf(":name needs a name for the script to go by in reports, like :name account-transfer"))
+			return ""
+		} else if text == "" {
+			c.fail(fmt.Errorf(":description needs to say what the script does, like :description Moves money between two accounts"))
+			return ""
+		}
+		if cmd == "name" && strings.HasPrefix(text, "builtin:") {
+			c.fail(fmt.Errorf(":name can't start with builtin:, which is for the built-in scripts, got %s", text))
+			return ""
+		}
+		if cmd == "name" {
+			s.Name = text
+		} else {
+			s.Description = text
+		}
+	case "retry":
+		s.Retry = parseRetry(c)
+	case "mode":
+		mode := ident(c)
+		if mode != "read" && mode != "write" {
+			c.fail(fmt.Errorf(":mode needs to be read or write, like :mode read, got '%s'", mode))
+			return ""
+		}
+		s.Mode = mode
+	case "begin":
+		if s.Autocommit {
+			c.fail(fmt.Errorf(":begin can't be used with :opt autocommit, which commits each query on its own"))
+			return ""
+		}
+		s.Commands = append(s.Commands, BeginCommand{})
+		s.ExplicitTransactions = true
+	case "commit", "rollback":
+		s.Commands = append(s.Commands, EndCommand{Rollback: cmd == "rollback"})
+	case "elif", "else", "endif", "endfor", "endinit":
+		return cmd
+	case "init":
+		parseInit(s, c)
 	case "opt":
 		opt := ident(c)
 
 		switch opt {
 		case "autocommit":
+			if s.ExplicitTransactions {
+				c.fail(fmt.Errorf(":opt autocommit can't be used with :begin, which runs queries in transactions"))
+				return ""
+			}
 			s.Autocommit = true
+		case "shuffle":
+			if s.Gsets {
+				c.fail(fmt.Errorf(":opt shuffle can't be used with :gset, the queries after it need to run after it"))
+				return ""
+			}
+			s.Shuffle = true
+		case "discard":
+			s.DiscardResults = true
+		case "timeout":
+			s.Timeout = parseTimeout(c, ":opt timeout")
 		default:
 			c.fail(fmt.Errorf("unexpected opt: '%s'", opt))
 		}
@@ -68,13 +334,31 @@ func parseMetaCommand(s *Script, c *parseContext) {
 			VarName:    varName,
 			Expression: setExpr,
 		})
+	case "define":
+		name := ident(c)
+		if c.PeekToken() == '=' {
+			c.Next()
+		}
+		tok, content := c.Next()
+		if tok != scanner.String && tok != scanner.RawString {
+			c.fail(fmt.Errorf(":define needs a quoted cypher fragment, like :define %s \"MATCH (n)\", got '%s'", name, content))
+			return ""
+		}
+		fragment, err := strconv.Unquote(content)
+		if err != nil {
+			c.fail(errors.Wrapf(err, "invalid string in :define %s", name))
+			return ""
+		}
+		c.macros[name] = fragment
 	case "sleep":
 		durationBase := expr(c)
 		unit := time.Second
+		unitGiven := false
 		switch c.PeekToken() {
 		case '\n', scanner.EOF:
 			break
 		default:
+			unitGiven = true
 			_, unitStr := c.Next()
 			switch unitStr {
 			case "s":
@@ -88,12 +372,216 @@ func parseMetaCommand(s *Script, c *parseContext) {
 			}
 		}
 		s.Commands = append(s.Commands, SleepCommand{
-			Duration: durationBase,
-			Unit:     unit,
+			Duration:  durationBase,
+			Unit:      unit,
+			UnitGiven: unitGiven,
+		})
+	case "lock":
+		label := ident(c)
+		expect(c, '.')
+		property := ident(c)
+		keys := expr(c)
+		order := LockAscending
+		switch c.PeekToken() {
+		case '\n', scanner.EOF:
+			break
+		default:
+			_, orderStr := c.Next()
+			switch orderStr {
+			case "asc":
+				order = LockAscending
+			case "desc":
+				order = LockDescending
+			case "given":
+				order = LockGiven
+			default:
+				c.fail(fmt.Errorf(":lock command must use 'asc', 'desc' or 'given' order argument - or none. got: %s", orderStr))
+			}
+		}
+		s.Commands = append(s.Commands, LockCommand{
+			Id:       c.nextStatementId(),
+			Label:    label,
+			Property: property,
+			Keys:     keys,
+			Order:    order,
 		})
 	default:
 		c.fail(fmt.Errorf("unexpected meta command: '%s'", cmd))
 	}
+	return ""
+}
+
+// Parses the branches of an :if, up to its :endif; the :if itself is already read
+func parseIf(s *Script, c *parseContext) {
+	outer := s.Commands
+	cmd := IfCommand{}
+	condition, inElse := expr(c), false
+	for !c.done {
+		s.Commands = nil
+		c.blocks++
+		end := parseCommands(s, c)
+		c.blocks--
+		if inElse {
+			cmd.Else = s.Commands
+		} else {
+			cmd.Branches = append(cmd.Branches, IfBranch{Condition: condition, Commands: s.Commands})
+		}
+		switch end {
+		case "elif", "else":
+			if inElse {
+				c.fail(fmt.Errorf(":%s after :else, :else needs to be the last branch of an :if", end))
+			} else if end == "elif" {
+				condition = expr(c)
+			} else {
+				inElse = true
+			}
+		case "endif":
+			s.Commands = append(outer, cmd)
+			return
+		case "":
+			c.fail(fmt.Errorf(":if without an :endif after it"))
+		default:
+			c.fail(fmt.Errorf(":%s before the :endif of the :if it's in", end))
+		}
+	}
+}
+
+// Parses the body of a :for, up to its :endfor; the :for itself is already read
+func parseFor(s *Script, c *parseContext) {
+	outer := s.Commands
+	varName := ident(c)
+	if in := ident(c); in != "in" {
+		c.fail(fmt.Errorf(":for needs a list to loop over, like :for i in range(1, 10), got '%s' rather than 'in'", in))
+		return
+	}
+	items := expr(c)
+	s.Commands = nil
+	c.blocks++
+	end := parseCommands(s, c)
+	c.blocks--
+	switch end {
+	case "endfor":
+		s.Commands = append(outer, ForCommand{VarName: varName, Items: items, Commands: s.Commands})
+	case "":
+		c.fail(fmt.Errorf(":for without an :endfor after it"))
+	default:
+		c.fail(unmatchedEnd(end))
+	}
+}
+
+// Parses the body of an :init, up to its :endinit, into a script of its own, see Script.Init; the :init itself is
+// already read
+func parseInit(s *Script, c *parseContext) {
+	if c.blocks > 0 || c.init {
+		c.fail(fmt.Errorf(":init can't be inside an :if, a :for or another :init, only in the script around them"))
+		return
+	}
+	if s.Init != nil {
+		c.fail(fmt.Errorf(":init can only be given once in a script, put everything it does in the one :init"))
+		return
+	}
+	init := Script{Name: s.Name, Source: s.Source, Weight: 1}
+	c.init = true
+	end := parseCommands(&init, c)
+	c.init = false
+	switch end {
+	case "endinit":
+		s.Init = &init
+	case "":
+		c.fail(fmt.Errorf(":init without an :endinit after it"))
+	default:
+		c.fail(unmatchedEnd(end))
+	}
+}
+
+// The text up to the end of the line, as written, without the whitespace around it; // is part of the text
+// rather than a comment, as in a URL
+func restOfLine(c *parseContext) string {
+	originalWhitespace, originalMode := c.s.Whitespace, c.s.Mode
+	defer func() {
+		c.s.Whitespace, c.s.Mode = originalWhitespace, originalMode
+	}()
+	c.s.Whitespace = 0
+	c.s.Mode &^= scanner.ScanComments | scanner.SkipComments
+	var b strings.Builder
+	tok, content := c.Next()
+	for ; tok != '\n' && tok != scanner.EOF; tok, content = c.Next() {
+		b.WriteString(content)
+	}
+	c.Push(tok, content)
+	return strings.TrimSpace(b.String())
+}
+
+// Parses the script at the path of an :include into s, as if it was written out where the :include is; it shares
+// the :define fragments of the script that includes it, and its relative paths are relative to itself
+func parseInclude(s *Script, c *parseContext) {
+	var path string
+	if tok, content := c.Next(); tok == scanner.String || tok == scanner.RawString {
+		var err error
+		if path, err = strconv.Unquote(content); err != nil {
+			c.fail(errors.Wrapf(err, "invalid string in :include"))
+			return
+		}
+	} else {
+		// An unquoted path runs to the end of the line, and can't have spaces in it
+		var b strings.Builder
+		for ; tok != '\n' && tok != scanner.EOF; tok, content = c.Next() {
+			b.WriteString(content)
+		}
+		c.Push(tok, content)
+		path = b.String()
+	}
+	if path == "" {
+		c.fail(fmt.Errorf(":include needs the path of a script, like :include common-setup.script"))
+		return
+	}
+	absPath, err := absPath(c.s.Filename, path)
+	if err != nil {
+		c.fail(errors.Wrapf(err, "failed resolving path %s relative to %s in :include", path, c.s.Filename))
+		return
+	}
+	chain := c.including
+	if len(chain) == 0 {
+		self, _ := filepath.Abs(c.s.Filename)
+		chain = []string{self}
+	}
+	for _, including := range chain {
+		if including == absPath {
+			c.fail(fmt.Errorf(":include of %s includes itself", path))
+			return
+		}
+	}
+	content, err := ioutil.ReadFile(absPath)
+	if err != nil {
+		c.fail(errors.Wrapf(err, "failed to read %s in :include", path))
+		return
+	}
+
+	included := newParseContext(string(content), absPath)
+	included.macros = c.macros
+	included.statements = c.statements
+	included.blocks = c.blocks
+	included.init = c.init
+	included.including = append(append([]string{}, chain...), absPath)
+	if end := parseCommands(s, included); end != "" {
+		included.fail(unmatchedEnd(end))
+	}
+	if included.err != nil {
+		c.fail(errors.Wrapf(included.err, "in :include %s", path))
+	}
+}
+
+func unmatchedEnd(end string) error {
+	if end == "endfor" {
+		return fmt.Errorf(":endfor without a :for before it")
+	} else if end == "endinit" {
+		return fmt.Errorf(":endinit without an :init before it")
+	}
+	return fmt.Errorf(":%s without an :if before it", end)
+}
+
+func invalidEscape(str string) error {
+	return fmt.Errorf("%s has a backslash that isn't an escape like \\n or \\', write a backslash as \\\\", str)
 }
 
 func command(c *parseContext) Command {
@@ -104,6 +592,22 @@ func command(c *parseContext) Command {
 	c.s.Whitespace = 0
 	var b strings.Builder
 	for tok, content := c.Next(); tok != ';' && tok != scanner.EOF; tok, content = c.Next() {
+		if tok == '@' {
+			// @name is replaced with the fragment given by an earlier `:define name "<fragment>"`; not :name, which
+			// is a meta command at the start of a line and a label or relationship type inside a query
+			name, err := tryIdent(c)
+			if err != nil {
+				c.fail(fmt.Errorf("expected macro name after '@': %s", err))
+				return QueryCommand{}
+			}
+			fragment, found := c.macros[name]
+			if !found {
+				c.fail(fmt.Errorf("@%s is not defined, define it with :define %s \"<cypher fragment>\" before it's used", name, name))
+				return QueryCommand{}
+			}
+			b.WriteString(fragment)
+			continue
+		}
 		b.WriteString(content)
 	}
 	query := b.String()
@@ -196,30 +700,89 @@ func tryIdent(c *parseContext) (string, error) {
 	return "", fmt.Errorf("expected identifier, got '%s'", scanner.TokenString(tok))
 }
 
+// Expressions bind as in Cypher, loosest first: OR, AND, NOT, comparisons, + and -, then *, / and %, then unary
+// minus, and indexing tightest
 func expr(c *parseContext) Expression {
+	lhs := conjunction(c)
+	for isKeyword(c, "or") {
+		c.Next()
+		lhs = binaryExpr("or", lhs, conjunction(c))
+	}
+	return lhs
+}
+
+func conjunction(c *parseContext) Expression {
+	lhs := negation(c)
+	for isKeyword(c, "and") {
+		c.Next()
+		lhs = binaryExpr("and", lhs, negation(c))
+	}
+	return lhs
+}
+
+func negation(c *parseContext) Expression {
+	if isKeyword(c, "not") {
+		c.Next()
+		return Expression{Kind: callExpr, Payload: CallExpr{name: "not", args: []Expression{negation(c)}}}
+	}
+	return comparison(c)
+}
+
+func comparison(c *parseContext) Expression {
+	lhs := sum(c)
+	op := comparisonOperator(c)
+	if op == "" {
+		return lhs
+	}
+	return binaryExpr(op, lhs, sum(c))
+}
+
+// Takes the comparison operator up next, if there is one: =, ==, <>, !=, <, <=, > or >=
+func comparisonOperator(c *parseContext) string {
+	switch c.PeekToken() {
+	case '=':
+		c.Next()
+		if c.PeekToken() == '=' {
+			// == reads as =, for those used to it from elsewhere
+			c.Next()
+		}
+		return "="
+	case '!':
+		c.Next()
+		expect(c, '=')
+		return "<>"
+	case '<':
+		c.Next()
+		switch c.PeekToken() {
+		case '=':
+			c.Next()
+			return "<="
+		case '>':
+			c.Next()
+			return "<>"
+		}
+		return "<"
+	case '>':
+		c.Next()
+		if c.PeekToken() == '=' {
+			c.Next()
+			return ">="
+		}
+		return ">"
+	}
+	return ""
+}
+
+func sum(c *parseContext) Expression {
 	lhs := term(c)
 	for {
 		tok := c.PeekToken()
 		if tok == '+' {
 			c.Next()
-			rhs := term(c)
-			lhs = Expression{
-				Kind: callExpr,
-				Payload: CallExpr{
-					name: "+",
-					args: []Expression{lhs, rhs},
-				},
-			}
+			lhs = binaryExpr("+", lhs, term(c))
 		} else if tok == '-' {
 			c.Next()
-			rhs := term(c)
-			lhs = Expression{
-				Kind: callExpr,
-				Payload: CallExpr{
-					name: "-",
-					args: []Expression{lhs, rhs},
-				},
-			}
+			lhs = binaryExpr("-", lhs, term(c))
 		} else {
 			return lhs
 		}
@@ -227,59 +790,89 @@ func expr(c *parseContext) Expression {
 }
 
 func term(c *parseContext) Expression {
-	lhs := factor(c)
+	lhs := unary(c)
 	for {
 		tok := c.PeekToken()
 		if tok == '*' {
 			c.Next()
-			rhs := factor(c)
-			lhs = Expression{
-				Kind: callExpr,
-				Payload: CallExpr{
-					name: "*",
-					args: []Expression{lhs, rhs},
-				},
-			}
+			lhs = binaryExpr("*", lhs, unary(c))
 		} else if tok == '/' {
 			c.Next()
-			rhs := factor(c)
-			lhs = Expression{
-				Kind: callExpr,
-				Payload: CallExpr{
-					name: "/",
-					args: []Expression{lhs, rhs},
-				},
-			}
+			lhs = binaryExpr("/", lhs, unary(c))
 		} else if tok == '%' {
 			c.Next()
-			rhs := factor(c)
-			lhs = Expression{
-				Kind: callExpr,
-				Payload: CallExpr{
-					name: "%",
-					args: []Expression{lhs, rhs},
-				},
-			}
-		} else if tok == '[' {
-			c.Next()
-			index := expr(c)
-			expect(c, ']')
-			lhs = Expression{
-				Kind: sliceExpr,
-				Payload: SliceExpr{
-					src: lhs,
-					i:   index,
-				},
-			}
+			lhs = binaryExpr("%", lhs, unary(c))
 		} else {
 			return lhs
 		}
 	}
 }
 
+func unary(c *parseContext) Expression {
+	if c.PeekToken() != '-' {
+		return index(c)
+	}
+	c.Next()
+	tok, content := c.Peek()
+	if tok == scanner.Int {
+		c.Next()
+		intVal, err := strconv.Atoi(content)
+		if err != nil {
+			c.fail(err)
+			return Expression{}
+		}
+		return Expression{Kind: intExpr, Payload: int64(-1 * intVal)}
+	} else if tok == scanner.Float {
+		c.Next()
+		floatVal, err := strconv.ParseFloat(content, 64)
+		if err != nil {
+			c.fail(err)
+			return Expression{}
+		}
+		return Expression{Kind: floatExpr, Payload: -1.0 * floatVal}
+	}
+	// Anything else is taken from zero, so it keeps its type
+	return binaryExpr("-", Expression{Kind: intExpr, Payload: int64(0)}, unary(c))
+}
+
+func index(c *parseContext) Expression {
+	src := factor(c)
+	for c.PeekToken() == '[' {
+		c.Next()
+		i := expr(c)
+		expect(c, ']')
+		src = Expression{
+			Kind: sliceExpr,
+			Payload: SliceExpr{
+				src: src,
+				i:   i,
+			},
+		}
+	}
+	return src
+}
+
+func binaryExpr(op string, lhs, rhs Expression) Expression {
+	return Expression{
+		Kind: callExpr,
+		Payload: CallExpr{
+			name: op,
+			args: []Expression{lhs, rhs},
+		},
+	}
+}
+
+// Whether the next token is this keyword, in any case, like Cypher
+func isKeyword(c *parseContext, keyword string) bool {
+	tok, content := c.Peek()
+	return tok == scanner.Ident && strings.EqualFold(content, keyword)
+}
+
 func factor(c *parseContext) Expression {
 	tok, content := c.Next()
-	if tok == scanner.Ident {
+	if tok == scanner.Ident && c.PeekToken() != '(' && (strings.EqualFold(content, "true") || strings.EqualFold(content, "false")) {
+		return Expression{Kind: boolExpr, Payload: strings.EqualFold(content, "true")}
+	} else if tok == scanner.Ident {
 		funcName := content
 		var args []Expression
 		expect(c, '(')
@@ -289,6 +882,15 @@ func factor(c *parseContext) Expression {
 				expect(c, ',')
 			}
 			args = append(args, expr(c))
+			if funcName == "weighted_choice" {
+				// weighted_choice(value: weight, ..); the weights follow the values they belong to in args
+				if c.PeekToken() != ':' {
+					c.fail(fmt.Errorf("weighted_choice(..) needs a weight after each value, like weighted_choice(\"read\": 90, \"write\": 10)"))
+					return Expression{}
+				}
+				c.Next()
+				args = append(args, expr(c))
+			}
 			if c.done {
 				return Expression{}
 			}
@@ -314,7 +916,17 @@ func factor(c *parseContext) Expression {
 		}
 		return Expression{Kind: floatExpr, Payload: floatVal}
 	} else if tok == scanner.String {
+		// Double quoted strings are taken as written, so "\d+" stays a regular expression
 		return Expression{Kind: stringExpr, Payload: content[1 : len(content)-1]}
+	} else if tok == scanner.Char {
+		// 'single quoted', as in Cypher, with escapes
+		str := content[1 : len(content)-1]
+		str, err := strconv.Unquote(`"` + strings.ReplaceAll(strings.ReplaceAll(str, `\'`, `'`), `"`, `\"`) + `"`)
+		if err != nil {
+			c.fail(invalidEscape(content))
+			return Expression{}
+		}
+		return Expression{Kind: stringExpr, Payload: str}
 	} else if tok == '(' {
 		innerExp := expr(c)
 		expect(c, ')')
@@ -377,7 +989,7 @@ func factor(c *parseContext) Expression {
 			if len(out) > 0 {
 				expect(c, ',')
 			}
-			key := ident(c)
+			key := mapKey(c)
 			expect(c, ':')
 			value := expr(c)
 			out[key] = value
@@ -394,6 +1006,17 @@ func factor(c *parseContext) Expression {
 	}
 }
 
+// Keys of map literals are identifiers, like in Cypher, or quoted strings, for keys that aren't
+func mapKey(c *parseContext) string {
+	tok, content := c.Peek()
+	if tok == scanner.String || tok == scanner.Char {
+		c.Next()
+		return content[1 : len(content)-1]
+	}
+	return ident(c)
+}
+
+// [i in range(1,10) | $i * 2], [i in $ids WHERE $i % 2 = 0] or both, like in Cypher
 func listComprehension(c *parseContext) Expression {
 	itemName := ident(c)
 	maybeIn := ident(c)
@@ -403,14 +1026,24 @@ func listComprehension(c *parseContext) Expression {
 		return Expression{}
 	}
 	srcExpr := expr(c)
-	expect(c, '|')
-	outExpr := expr(c)
+	var filter *Expression
+	if isKeyword(c, "where") {
+		c.Next()
+		where := expr(c)
+		filter = &where
+	}
+	outExpr := Expression{Kind: varExpr, Payload: itemName}
+	if c.PeekToken() == '|' {
+		c.Next()
+		outExpr = expr(c)
+	}
 	expect(c, ']')
 	return Expression{
 		Kind: listCompExpr,
 		Payload: ListCompExpr{
 			itemName: itemName,
 			src:      srcExpr,
+			filter:   filter,
 			out:      outExpr,
 		},
 	}
@@ -445,6 +1078,8 @@ const (
 	callExpr ExprKind = 8
 	// payload string (varname)
 	varExpr ExprKind = 9
+	// payload bool
+	boolExpr ExprKind = 10
 )
 
 func (e ExprKind) String() string {
@@ -462,6 +1097,7 @@ var exprKindNames = []string{
 	sliceExpr:    "slice",
 	callExpr:     "call",
 	varExpr:      "var",
+	boolExpr:     "boolean",
 }
 
 type Expression struct {
@@ -471,7 +1107,7 @@ type Expression struct {
 
 func (e Expression) Eval(ctx *ScriptContext) (interface{}, error) {
 	switch e.Kind {
-	case intExpr, floatExpr, stringExpr:
+	case intExpr, floatExpr, stringExpr, boolExpr:
 		return e.Payload, nil
 	case listExpr:
 		innerExprs := e.Payload.([]Expression)
@@ -520,6 +1156,8 @@ func (e Expression) String() string {
 		return fmt.Sprintf("%f", e.Payload)
 	case stringExpr:
 		return fmt.Sprintf("\"%s\"", e.Payload)
+	case boolExpr:
+		return fmt.Sprintf("%t", e.Payload)
 	case mapExpr, listExpr:
 		return fmt.Sprintf("%v", e.Payload)
 	case sliceExpr:
@@ -535,7 +1173,8 @@ func (e Expression) String() string {
 	}
 }
 
-// Intended to be expanded into a richer slicing system, for now just simple indexing
+// Intended to be expanded into a richer slicing system, for now just simple indexing, of lists by position and of
+// maps, like the records in an :assert, by key
 type SliceExpr struct {
 	src Expression
 	i   Expression
@@ -550,16 +1189,23 @@ func (s SliceExpr) Eval(ctx *ScriptContext) (interface{}, error) {
 	if err != nil {
 		return nil, err
 	}
-	src, ok := srcRaw.([]interface{})
-	if !ok {
-		return nil, fmt.Errorf("slicing only work on lists, got %v", srcRaw)
-	}
-
 	iRaw, err := s.i.Eval(ctx)
 	if err != nil {
 		return nil, errors.Wrapf(err, "in slice %s", s.String())
 	}
 
+	if m, ok := srcRaw.(map[string]interface{}); ok {
+		key, ok := iRaw.(string)
+		if !ok {
+			return nil, fmt.Errorf("maps can only be indexed by string keys, got %v in %s", iRaw, s.String())
+		}
+		return m[key], nil
+	}
+	src, ok := srcRaw.([]interface{})
+	if !ok {
+		return nil, fmt.Errorf("slicing only work on lists and maps, got %v", srcRaw)
+	}
+
 	iNum, err := asNumber(iRaw)
 	if err != nil {
 		return nil, errors.Wrapf(err, "expected integer as slice argument in %s", s.String())
@@ -568,20 +1214,32 @@ func (s SliceExpr) Eval(ctx *ScriptContext) (interface{}, error) {
 		return nil, fmt.Errorf("floats can't be used as indexes in slices, in %s", s.String())
 	}
 	i := iNum.iVal
-
+	// Negative indexes count from the end, and indexes past either end give nothing, like in Cypher
+	if i < 0 {
+		i += int64(len(src))
+	}
+	if i < 0 || i >= int64(len(src)) {
+		return nil, nil
+	}
 	return src[i], nil
 }
 
-// [i in range(1,10) | i * 2]
+// [i in range(1,10) WHERE $i > 2 | $i * 2]
 type ListCompExpr struct {
 	itemName string
 	// Expression that yields a list
 	src Expression
-	// Evaluated once for each item in src, with item named itemName
+	// Evaluated once for each item in src, with item named itemName; items it's false for are left out. Nil if the
+	// comprehension has no WHERE
+	filter *Expression
+	// Evaluated once for each item in src that's kept, with item named itemName
 	out Expression
 }
 
 func (s ListCompExpr) String() string {
+	if s.filter != nil {
+		return fmt.Sprintf("[%s in %s WHERE %s | %s]", s.itemName, s.src.String(), s.filter.String(), s.out.String())
+	}
 	return fmt.Sprintf("[%s in %s | %s]", s.itemName, s.src.String(), s.out.String())
 }
 
@@ -595,23 +1253,39 @@ func (s ListCompExpr) Eval(ctx *ScriptContext) (interface{}, error) {
 		return nil, fmt.Errorf("source in list comprehension must be a list, got %v from %s", src, s.src)
 	}
 
-	out := make([]interface{}, len(src))
+	out := make([]interface{}, 0, len(src))
 	innerCtx := ScriptContext{
-		Script:    ctx.Script,
-		Stderr:    ctx.Stderr,
-		Vars:      make(map[string]interface{}),
-		Rand:      ctx.Rand,
-		CsvLoader: ctx.CsvLoader,
+		PreflightMode: ctx.PreflightMode,
+		Script:        ctx.Script,
+		Stderr:        ctx.Stderr,
+		Vars:          make(map[string]interface{}),
+		Rand:          ctx.Rand,
+		CsvLoader:     ctx.CsvLoader,
+		Sequences:     ctx.Sequences,
 	}
 	for k, v := range ctx.Vars {
 		innerCtx.Vars[k] = v
 	}
 	for i := range src {
 		innerCtx.Vars[s.itemName] = src[i]
-		out[i], err = s.out.Eval(&innerCtx)
+		if s.filter != nil {
+			keepRaw, err := s.filter.Eval(&innerCtx)
+			if err != nil {
+				return nil, errors.Wrapf(err, "when evaluating %s=%v in %s", s.itemName, src[i], s.String())
+			}
+			keep, ok := keepRaw.(bool)
+			if !ok {
+				return nil, fmt.Errorf("WHERE in list comprehension must be true or false, got %v from %s", keepRaw, s.filter)
+			}
+			if !keep {
+				continue
+			}
+		}
+		item, err := s.out.Eval(&innerCtx)
 		if err != nil {
 			return nil, errors.Wrapf(err, "when evaluating %s=%v in %s", s.itemName, src[i], s.String())
 		}
+		out = append(out, item)
 	}
 	return out, nil
 }
@@ -623,7 +1297,11 @@ type CallExpr struct {
 
 func (f CallExpr) String() string {
 	args := make([]string, 0, len(f.args))
-	for _, a := range f.args {
+	for i, a := range f.args {
+		if f.name == "weighted_choice" && i%2 == 1 {
+			args[len(args)-1] += ": " + a.String()
+			continue
+		}
 		args = append(args, a.String())
 	}
 	return fmt.Sprintf("%s(%s)", f.name, strings.Join(args, ", "))
@@ -682,6 +1360,16 @@ func (f CallExpr) Eval(ctx *ScriptContext) (interface{}, error) {
 		} else {
 			return a.iVal, nil
 		}
+	case "round":
+		a, err := f.argAsNumber(0, ctx)
+		if err != nil {
+			return nil, fmt.Errorf("in %s: %s", f.String(), err)
+		}
+		if a.isDouble {
+			return int64(math.Round(a.val)), nil
+		} else {
+			return a.iVal, nil
+		}
 	case "debug":
 		a, err := f.argAsNumber(0, ctx)
 		if err != nil {
@@ -701,23 +1389,52 @@ func (f CallExpr) Eval(ctx *ScriptContext) (interface{}, error) {
 			return a.iVal, nil
 		}
 	// TODO: Align with name cypher uses
-	case "len":
+	case "len", "size":
 		if len(f.args) == 0 {
-			return nil, fmt.Errorf("len(..) requires an argument")
+			return nil, fmt.Errorf("%s(..) requires an argument", f.name)
 		}
 		rawSrc, err := f.args[0].Eval(ctx)
 		if err != nil {
 			return nil, errors.Wrapf(err, "in %s", f.String())
 		}
+		if str, ok := rawSrc.(string); ok {
+			return int64(len([]rune(str))), nil
+		}
+		if m, ok := rawSrc.(map[string]interface{}); ok {
+			return int64(len(m)), nil
+		}
 		src, ok := rawSrc.([]interface{})
 		if !ok {
-			return nil, fmt.Errorf("argument to len(..) needs to be a list, in %s", f.String())
+			return nil, fmt.Errorf("argument to %s(..) needs to be a list, a map or a string, in %s", f.name, f.String())
 		}
 		return int64(len(src)), nil
-	case "double":
-		a, err := f.argAsNumber(0, ctx)
-		if err != nil {
-			return nil, fmt.Errorf("in %s: %s", f.String(), err)
+	case "keys":
+		if len(f.args) == 0 {
+			return nil, fmt.Errorf("keys(..) requires an argument")
+		}
+		rawSrc, err := f.args[0].Eval(ctx)
+		if err != nil {
+			return nil, errors.Wrapf(err, "in %s", f.String())
+		}
+		m, ok := rawSrc.(map[string]interface{})
+		if !ok {
+			return nil, fmt.Errorf("argument to keys(..) needs to be a map, in %s", f.String())
+		}
+		// Sorted, so scripts get the same list each time
+		keys := make([]string, 0, len(m))
+		for key := range m {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+		out := make([]interface{}, len(keys))
+		for i, key := range keys {
+			out[i] = key
+		}
+		return out, nil
+	case "double":
+		a, err := f.argAsNumber(0, ctx)
+		if err != nil {
+			return nil, fmt.Errorf("in %s: %s", f.String(), err)
 		}
 		return a.val, nil
 	case "greatest":
@@ -783,6 +1500,300 @@ func (f CallExpr) Eval(ctx *ScriptContext) (interface{}, error) {
 		return min.iVal, nil
 	case "pi":
 		return math.Pi, nil
+	case "now":
+		return time.Now().UTC(), nil
+	case "epoch_millis":
+		t := time.Now()
+		if len(f.args) > 0 {
+			value, err := f.args[0].Eval(ctx)
+			if err != nil {
+				return nil, errors.Wrapf(err, "in %s", f.String())
+			}
+			var ok bool
+			if t, ok = asTime(value); !ok {
+				return nil, fmt.Errorf("epoch_millis(..) takes a datetime or a date, got %v, in %s", value, f.String())
+			}
+		}
+		return t.UnixNano() / int64(time.Millisecond), nil
+	case "datetime", "date":
+		t := time.Now().UTC()
+		if len(f.args) > 0 {
+			value, err := f.args[0].Eval(ctx)
+			if err != nil {
+				return nil, errors.Wrapf(err, "in %s", f.String())
+			}
+			if t, err = toTime(value); err != nil {
+				return nil, fmt.Errorf("in %s: %s", f.String(), err)
+			}
+		}
+		if f.name == "date" {
+			return neo4j.DateOf(t), nil
+		}
+		return t, nil
+	case "date_add":
+		if len(f.args) != 3 {
+			return nil, fmt.Errorf("date_add(..) takes a datetime or a date, an amount and a unit, in %s", f.String())
+		}
+		value, err := f.args[0].Eval(ctx)
+		if err != nil {
+			return nil, errors.Wrapf(err, "in %s", f.String())
+		}
+		t, ok := asTime(value)
+		if !ok {
+			return nil, fmt.Errorf("date_add(..) needs a datetime or a date to add to, got %v, in %s", value, f.String())
+		}
+		amount, err := f.argAsNumber(1, ctx)
+		if err != nil {
+			return nil, fmt.Errorf("in %s: %s", f.String(), err)
+		}
+		unit, err := f.argAsTimeUnit(2, ctx)
+		if err != nil {
+			return nil, fmt.Errorf("in %s: %s", f.String(), err)
+		}
+		t = t.Add(time.Duration(amount.val * float64(unit)))
+		if _, isDate := value.(neo4j.Date); isDate {
+			// Dates stay dates, so anything less than a day is dropped
+			return neo4j.DateOf(t), nil
+		}
+		return t, nil
+	case "date_diff":
+		if len(f.args) != 3 {
+			return nil, fmt.Errorf("date_diff(..) takes two datetimes or dates and a unit, in %s", f.String())
+		}
+		times := make([]time.Time, 2)
+		for i := range times {
+			value, err := f.args[i].Eval(ctx)
+			if err != nil {
+				return nil, errors.Wrapf(err, "in %s", f.String())
+			}
+			var ok bool
+			if times[i], ok = asTime(value); !ok {
+				return nil, fmt.Errorf("date_diff(..) needs datetimes or dates, got %v, in %s", value, f.String())
+			}
+		}
+		unit, err := f.argAsTimeUnit(2, ctx)
+		if err != nil {
+			return nil, fmt.Errorf("in %s: %s", f.String(), err)
+		}
+		return int64(times[1].Sub(times[0]) / unit), nil
+	case "random_datetime":
+		if len(f.args) != 2 {
+			return nil, fmt.Errorf("random_datetime(..) takes the earliest and the latest datetime to pick from, in %s", f.String())
+		}
+		times := make([]time.Time, 2)
+		for i := range times {
+			value, err := f.args[i].Eval(ctx)
+			if err != nil {
+				return nil, errors.Wrapf(err, "in %s", f.String())
+			}
+			if times[i], err = toTime(value); err != nil {
+				return nil, fmt.Errorf("in %s: %s", f.String(), err)
+			}
+		}
+		span := times[1].Sub(times[0]) / time.Millisecond
+		if span < 0 {
+			return nil, fmt.Errorf("random_datetime(..) needs the earliest datetime first, in %s", f.String())
+		}
+		return times[0].Add(time.Duration(ctx.Rand.Int63n(int64(span)+1)) * time.Millisecond), nil
+	case "duration":
+		if len(f.args) != 1 {
+			return nil, fmt.Errorf("duration(..) takes a map of the parts of the duration, like duration({days: 1, hours: 12}), in %s", f.String())
+		}
+		value, err := f.args[0].Eval(ctx)
+		if err != nil {
+			return nil, errors.Wrapf(err, "in %s", f.String())
+		}
+		parts, ok := value.(map[string]interface{})
+		if !ok {
+			return nil, fmt.Errorf("duration(..) takes a map of the parts of the duration, like duration({days: 1, hours: 12}), got %v, in %s", value, f.String())
+		}
+		d, err := durationOf(parts)
+		if err != nil {
+			return nil, fmt.Errorf("in %s: %s", f.String(), err)
+		}
+		return d, nil
+	case "random_duration":
+		if len(f.args) != 3 {
+			return nil, fmt.Errorf("random_duration(..) takes the least and the most units and a unit, in %s", f.String())
+		}
+		lb, err := f.argAsNumber(0, ctx)
+		if err != nil {
+			return nil, fmt.Errorf("in %s: %s", f.String(), err)
+		}
+		ub, err := f.argAsNumber(1, ctx)
+		if err != nil {
+			return nil, fmt.Errorf("in %s: %s", f.String(), err)
+		}
+		if lb.isDouble || ub.isDouble || lb.iVal > ub.iVal {
+			return nil, fmt.Errorf("random_duration(..) needs the least and the most units as integers, least first, in %s", f.String())
+		}
+		if ub.iVal-lb.iVal+1 <= 0 {
+			return nil, fmt.Errorf("random_duration(..) can't pick from more than %d units, in %s", int64(math.MaxInt64), f.String())
+		}
+		unit, err := f.argAsTimeUnit(2, ctx)
+		if err != nil {
+			return nil, fmt.Errorf("in %s: %s", f.String(), err)
+		}
+		n := lb.iVal + ctx.Rand.Int63n(ub.iVal-lb.iVal+1)
+		if unit >= 24*time.Hour {
+			// Days and weeks are days, like Cypher's durations, rather than so many hours
+			return neo4j.DurationOf(0, n*int64(unit/(24*time.Hour)), 0, 0), nil
+		}
+		return durationOfTime(0, 0, time.Duration(n)*unit), nil
+	case "point":
+		if len(f.args) != 1 {
+			return nil, fmt.Errorf("point(..) takes a map of the coordinates, like point({latitude: 55.6, longitude: 13.0}) or point({x: 1, y: 2}), in %s", f.String())
+		}
+		value, err := f.args[0].Eval(ctx)
+		if err != nil {
+			return nil, errors.Wrapf(err, "in %s", f.String())
+		}
+		coordinates, ok := value.(map[string]interface{})
+		if !ok {
+			return nil, fmt.Errorf("point(..) takes a map of the coordinates, like point({latitude: 55.6, longitude: 13.0}) or point({x: 1, y: 2}), got %v, in %s", value, f.String())
+		}
+		p, err := pointOf(coordinates)
+		if err != nil {
+			return nil, fmt.Errorf("in %s: %s", f.String(), err)
+		}
+		return p, nil
+	case "random_point":
+		if len(f.args) != 4 {
+			return nil, fmt.Errorf("random_point(..) takes the bounding box to pick from, as the least latitude and longitude and then the greatest, in %s", f.String())
+		}
+		bounds := make([]float64, 4)
+		for i := range bounds {
+			bound, err := f.argAsNumber(i, ctx)
+			if err != nil {
+				return nil, fmt.Errorf("in %s: %s", f.String(), err)
+			}
+			bounds[i] = bound.val
+		}
+		minLat, minLong, maxLat, maxLong := bounds[0], bounds[1], bounds[2], bounds[3]
+		if minLat > maxLat || minLong > maxLong || minLat < -90 || maxLat > 90 || minLong < -180 || maxLong > 180 {
+			return nil, fmt.Errorf("random_point(..) needs the least latitude and longitude before the greatest, with latitudes from -90 to 90 and longitudes from -180 to 180, in %s", f.String())
+		}
+		return neo4j.Point2D{
+			X:            minLong + ctx.Rand.Float64()*(maxLong-minLong),
+			Y:            minLat + ctx.Rand.Float64()*(maxLat-minLat),
+			SpatialRefId: sridWGS84,
+		}, nil
+	case "choice", "random_choice":
+		if len(f.args) == 0 {
+			return nil, fmt.Errorf("choice(..) needs at least one value to choose from, in %s", f.String())
+		}
+		if len(f.args) > 1 {
+			// Only the chosen value is evaluated, so the others don't draw random numbers
+			value, err := f.args[ctx.Rand.Intn(len(f.args))].Eval(ctx)
+			if err != nil {
+				return nil, errors.Wrapf(err, "in %s", f.String())
+			}
+			return value, nil
+		}
+		value, err := f.args[0].Eval(ctx)
+		if err != nil {
+			return nil, errors.Wrapf(err, "in %s", f.String())
+		}
+		list, ok := value.([]interface{})
+		if !ok {
+			return value, nil
+		}
+		if len(list) == 0 {
+			return nil, fmt.Errorf("choice(..) can't choose from an empty list, in %s", f.String())
+		}
+		return list[ctx.Rand.Intn(len(list))], nil
+	case "weighted_choice":
+		if len(f.args) == 0 {
+			return nil, fmt.Errorf("weighted_choice(..) needs at least one value to choose from, like weighted_choice(\"read\": 90, \"write\": 10), in %s", f.String())
+		}
+		weights := make([]float64, 0, len(f.args)/2)
+		total := 0.0
+		for i := 1; i < len(f.args); i += 2 {
+			weight, err := f.argAsNumber(i, ctx)
+			if err != nil {
+				return nil, fmt.Errorf("in %s: %s", f.String(), err)
+			}
+			if weight.val < 0 {
+				return nil, fmt.Errorf("weights of weighted_choice(..) can't be negative, got %s, in %s", f.args[i].String(), f.String())
+			}
+			weights = append(weights, weight.val)
+			total += weight.val
+		}
+		if total <= 0 {
+			return nil, fmt.Errorf("weighted_choice(..) needs at least one weight above zero, in %s", f.String())
+		}
+		roll := ctx.Rand.Float64() * total
+		chosen := len(weights) - 1
+		for i, weight := range weights {
+			if roll < weight {
+				chosen = i
+				break
+			}
+			roll -= weight
+		}
+		value, err := f.args[chosen*2].Eval(ctx)
+		if err != nil {
+			return nil, errors.Wrapf(err, "in %s", f.String())
+		}
+		return value, nil
+	case "sequence":
+		name, err := f.argAsString(0, ctx)
+		if err != nil {
+			return nil, fmt.Errorf("in %s: %s", f.String(), err)
+		}
+		start := int64(1)
+		if len(f.args) > 1 {
+			startArg, err := f.argAsNumber(1, ctx)
+			if err != nil {
+				return nil, fmt.Errorf("in %s: %s", f.String(), err)
+			}
+			if startArg.isDouble {
+				return nil, fmt.Errorf("sequence(..) needs to start at an integer, in %s", f.String())
+			}
+			start = startArg.iVal
+		}
+		if ctx.PreflightMode {
+			// Checking the script doesn't use up a value
+			return start, nil
+		}
+		if ctx.Sequences == nil {
+			return nil, fmt.Errorf("sequence(..) needs the counters of a workload to count with, in %s", f.String())
+		}
+		return ctx.Sequences.Next(name, start), nil
+	case "random_string":
+		length, err := f.argAsNumber(0, ctx)
+		if err != nil {
+			return nil, fmt.Errorf("in %s: %s", f.String(), err)
+		}
+		if length.isDouble || length.iVal < 0 {
+			return nil, fmt.Errorf("random_string(..) needs a length that is an integer no less than zero, in %s", f.String())
+		}
+		if length.iVal > maxRandomStringLength {
+			return nil, fmt.Errorf("random_string(..) can make strings of at most %d characters, in %s", maxRandomStringLength, f.String())
+		}
+		return randomString(ctx.Rand, length.iVal), nil
+	case "random_text":
+		lb, err := f.argAsNumber(0, ctx)
+		if err != nil {
+			return nil, fmt.Errorf("in %s: %s", f.String(), err)
+		}
+		ub, err := f.argAsNumber(1, ctx)
+		if err != nil {
+			return nil, fmt.Errorf("in %s: %s", f.String(), err)
+		}
+		if lb.isDouble || ub.isDouble || lb.iVal < 0 || ub.iVal < lb.iVal {
+			return nil, fmt.Errorf("random_text(..) needs integer word counts, with 0 <= minWords <= maxWords, in %s", f.String())
+		}
+		if ub.iVal > maxRandomTextWords {
+			return nil, fmt.Errorf("random_text(..) can make at most %d words, in %s", maxRandomTextWords, f.String())
+		}
+		return randomText(ctx.Rand, lb.iVal+ctx.Rand.Int63n(ub.iVal-lb.iVal+1)), nil
+	case "uuid", "randomUUID":
+		id, err := randomUUID()
+		if err != nil {
+			return nil, fmt.Errorf("in %s: %s", f.String(), err)
+		}
+		return id, nil
 	case "sqrt":
 		a, err := f.argAsNumber(0, ctx)
 		if err != nil {
@@ -856,6 +1867,30 @@ func (f CallExpr) Eval(ctx *ScriptContext) (interface{}, error) {
 
 		min, max := lb.iVal, ub.iVal
 		return gaussianRand(ctx.Rand, min, max, param.val)
+	case "random_zipfian":
+		lb, err := f.argAsNumber(0, ctx)
+		if err != nil {
+			return nil, fmt.Errorf("in %s: %s", f.String(), err)
+		}
+		ub, err := f.argAsNumber(1, ctx)
+		if err != nil {
+			return nil, fmt.Errorf("in %s: %s", f.String(), err)
+		}
+		param, err := f.argAsNumber(2, ctx)
+		if err != nil {
+			return nil, fmt.Errorf("in %s: %s", f.String(), err)
+		}
+
+		if lb.isDouble || ub.isDouble {
+			return nil, fmt.Errorf("interval for random() must be integers, not doubles, in %s", f.String())
+		}
+
+		if lb.iVal == ub.iVal {
+			return lb.iVal, nil
+		}
+
+		min, max := lb.iVal, ub.iVal
+		return ZipfianRand(ctx.Rand, min, max, param.val)
 	case "range":
 		lb, err := f.argAsNumber(0, ctx)
 		if err != nil {
@@ -901,11 +1936,113 @@ func (f CallExpr) Eval(ctx *ScriptContext) (interface{}, error) {
 		if err != nil {
 			return nil, errors.Wrap(err, "csv(..) takes string as argument")
 		}
-		absPath, err := absPath(ctx.Script.Name, path)
+		absPath, err := absPath(ctx.Script.Source, path)
 		if err != nil {
-			return nil, errors.Wrapf(err, "failed resolving path %s relative to %s in %s", path, ctx.Script.Name, f.String())
+			return nil, errors.Wrapf(err, "failed resolving path %s relative to %s in %s", path, ctx.Script.Source, f.String())
 		}
 		return ctx.CsvLoader.Load(absPath)
+	case "sample":
+		path, err := f.argAsString(0, ctx)
+		if err != nil {
+			return nil, fmt.Errorf("in %s: %s", f.String(), err)
+		}
+		absPath, err := absPath(ctx.Script.Source, path)
+		if err != nil {
+			return nil, errors.Wrapf(err, "failed resolving path %s relative to %s in %s", path, ctx.Script.Source, f.String())
+		}
+		values, err := ctx.CsvLoader.LoadValues(absPath)
+		if err != nil {
+			return nil, errors.Wrapf(err, "in %s", f.String())
+		}
+		return values[ctx.Rand.Intn(len(values))], nil
+	case "format":
+		format, err := f.argAsString(0, ctx)
+		if err != nil {
+			return nil, fmt.Errorf("in %s: %s", f.String(), err)
+		}
+		args := make([]interface{}, 0, len(f.args)-1)
+		for _, arg := range f.args[1:] {
+			value, err := arg.Eval(ctx)
+			if err != nil {
+				return nil, errors.Wrapf(err, "in %s", f.String())
+			}
+			args = append(args, value)
+		}
+		out := fmt.Sprintf(format, args...)
+		// fmt marks arguments that don't fit the format with %!, rather than failing
+		if strings.Contains(out, "%!") && !strings.Contains(format, "%!") {
+			return nil, fmt.Errorf("the arguments don't match the format, got '%s', in %s", out, f.String())
+		}
+		return out, nil
+	case "substring", "substr":
+		str, err := f.argAsString(0, ctx)
+		if err != nil {
+			return nil, fmt.Errorf("in %s: %s", f.String(), err)
+		}
+		start, err := f.argAsNumber(1, ctx)
+		if err != nil {
+			return nil, fmt.Errorf("in %s: %s", f.String(), err)
+		}
+		// Counted in characters, from zero, like in Cypher
+		runes := []rune(str)
+		length := Number{iVal: int64(len(runes))}
+		if len(f.args) > 2 {
+			length, err = f.argAsNumber(2, ctx)
+			if err != nil {
+				return nil, fmt.Errorf("in %s: %s", f.String(), err)
+			}
+		}
+		if start.isDouble || length.isDouble || start.iVal < 0 || length.iVal < 0 {
+			return nil, fmt.Errorf("start and length need to be integers no less than zero, in %s", f.String())
+		}
+		if start.iVal >= int64(len(runes)) {
+			return "", nil
+		}
+		end := int64(len(runes))
+		if length.iVal < end-start.iVal {
+			end = start.iVal + length.iVal
+		}
+		return string(runes[start.iVal:end]), nil
+	case "toString":
+		if len(f.args) == 0 {
+			return nil, fmt.Errorf("toString(..) requires an argument")
+		}
+		value, err := f.args[0].Eval(ctx)
+		if err != nil {
+			return nil, errors.Wrapf(err, "in %s", f.String())
+		}
+		str, err := toString(value)
+		if err != nil {
+			return nil, fmt.Errorf("in %s: %s", f.String(), err)
+		}
+		return str, nil
+	case "toUpper", "toLower", "trim":
+		str, err := f.argAsString(0, ctx)
+		if err != nil {
+			return nil, fmt.Errorf("in %s: %s", f.String(), err)
+		}
+		switch f.name {
+		case "toUpper":
+			return strings.ToUpper(str), nil
+		case "toLower":
+			return strings.ToLower(str), nil
+		default:
+			return strings.TrimSpace(str), nil
+		}
+	case "replace":
+		str, err := f.argAsString(0, ctx)
+		if err != nil {
+			return nil, fmt.Errorf("in %s: %s", f.String(), err)
+		}
+		search, err := f.argAsString(1, ctx)
+		if err != nil {
+			return nil, fmt.Errorf("in %s: %s", f.String(), err)
+		}
+		replacement, err := f.argAsString(2, ctx)
+		if err != nil {
+			return nil, fmt.Errorf("in %s: %s", f.String(), err)
+		}
+		return strings.ReplaceAll(str, search, replacement), nil
 	case "*":
 		a, err := f.argAsNumber(0, ctx)
 		if err != nil {
@@ -961,6 +2098,17 @@ func (f CallExpr) Eval(ctx *ScriptContext) (interface{}, error) {
 			return nil, errors.Wrapf(err, "in %s", f.String())
 		}
 
+		// Lists concatenate, and anything else added to a list is added as an item, like in Cypher
+		aList, aIsList := a.([]interface{})
+		bList, bIsList := b.([]interface{})
+		if aIsList && bIsList {
+			return append(append(make([]interface{}, 0, len(aList)+len(bList)), aList...), bList...), nil
+		} else if aIsList {
+			return append(append(make([]interface{}, 0, len(aList)+1), aList...), b), nil
+		} else if bIsList {
+			return append([]interface{}{a}, bList...), nil
+		}
+
 		_, aIsString := a.(string)
 		_, bIsString := b.(string)
 
@@ -1005,17 +2153,167 @@ func (f CallExpr) Eval(ctx *ScriptContext) (interface{}, error) {
 		} else {
 			return a.iVal - b.iVal, nil
 		}
+	case "=", "<>":
+		a, err := f.args[0].Eval(ctx)
+		if err != nil {
+			return nil, errors.Wrapf(err, "in %s", f.String())
+		}
+		b, err := f.args[1].Eval(ctx)
+		if err != nil {
+			return nil, errors.Wrapf(err, "in %s", f.String())
+		}
+		return equalValues(a, b) == (f.name == "="), nil
+	case "<", "<=", ">", ">=":
+		a, err := f.args[0].Eval(ctx)
+		if err != nil {
+			return nil, errors.Wrapf(err, "in %s", f.String())
+		}
+		b, err := f.args[1].Eval(ctx)
+		if err != nil {
+			return nil, errors.Wrapf(err, "in %s", f.String())
+		}
+		order, err := compareValues(a, b)
+		if err != nil {
+			return nil, fmt.Errorf("in %s: %s", f.String(), err)
+		}
+		switch f.name {
+		case "<":
+			return order < 0, nil
+		case "<=":
+			return order <= 0, nil
+		case ">":
+			return order > 0, nil
+		default:
+			return order >= 0, nil
+		}
+	case "and", "or":
+		// Like Cypher, the right side is only evaluated if the left doesn't settle it
+		a, err := f.argAsBool(0, ctx)
+		if err != nil {
+			return nil, fmt.Errorf("in %s: %s", f.String(), err)
+		}
+		if a == (f.name == "or") {
+			return a, nil
+		}
+		b, err := f.argAsBool(1, ctx)
+		if err != nil {
+			return nil, fmt.Errorf("in %s: %s", f.String(), err)
+		}
+		return b, nil
+	case "not":
+		a, err := f.argAsBool(0, ctx)
+		if err != nil {
+			return nil, fmt.Errorf("in %s: %s", f.String(), err)
+		}
+		return !a, nil
 	default:
 		return nil, fmt.Errorf("unknown function: %s", f.String())
 	}
 }
 
+// Units of date_add and date_diff; months and years are left out, since they aren't all the same length
+var timeUnits = map[string]time.Duration{
+	"ms": time.Millisecond,
+	"s":  time.Second,
+	"m":  time.Minute,
+	"h":  time.Hour,
+	"d":  24 * time.Hour,
+	"w":  7 * 24 * time.Hour,
+}
+
+func (f CallExpr) argAsTimeUnit(i int, ctx *ScriptContext) (time.Duration, error) {
+	name, err := f.argAsString(i, ctx)
+	if err != nil {
+		return 0, err
+	}
+	unit, found := timeUnits[name]
+	if !found {
+		return 0, fmt.Errorf("unit needs to be one of \"ms\", \"s\", \"m\", \"h\", \"d\" or \"w\", got \"%s\"", name)
+	}
+	return unit, nil
+}
+
+func (f CallExpr) argAsBool(i int, ctx *ScriptContext) (bool, error) {
+	if len(f.args) <= i {
+		return false, fmt.Errorf("expected at least %d arguments, got %d", i+1, len(f.args))
+	}
+	value, err := f.args[i].Eval(ctx)
+	if err != nil {
+		return false, err
+	}
+	b, ok := value.(bool)
+	if !ok {
+		return false, fmt.Errorf("expected a boolean, got %s (which is %T)", f.args[i].String(), value)
+	}
+	return b, nil
+}
+
+// Numbers are equal if they have the same value, whether they are integers or floats; anything else needs to be the
+// same type and value
+func equalValues(a, b interface{}) bool {
+	aNum, aErr := asNumber(a)
+	bNum, bErr := asNumber(b)
+	if aErr == nil && bErr == nil {
+		if aNum.isDouble || bNum.isDouble {
+			return aNum.val == bNum.val
+		}
+		return aNum.iVal == bNum.iVal
+	}
+	return reflect.DeepEqual(a, b)
+}
+
+// Orders two numbers, two strings, or two datetimes or dates; -1 if a comes first, 0 if they are equal and 1 if b comes first
+func compareValues(a, b interface{}) (int, error) {
+	aNum, aErr := asNumber(a)
+	bNum, bErr := asNumber(b)
+	if aErr == nil && bErr == nil {
+		if !aNum.isDouble && !bNum.isDouble {
+			if aNum.iVal < bNum.iVal {
+				return -1, nil
+			} else if aNum.iVal > bNum.iVal {
+				return 1, nil
+			}
+			return 0, nil
+		}
+		if aNum.val < bNum.val {
+			return -1, nil
+		} else if aNum.val > bNum.val {
+			return 1, nil
+		}
+		return 0, nil
+	}
+	aStr, aIsString := a.(string)
+	bStr, bIsString := b.(string)
+	if aIsString && bIsString {
+		return strings.Compare(aStr, bStr), nil
+	}
+	aTime, aIsTime := asTime(a)
+	bTime, bIsTime := asTime(b)
+	if aIsTime && bIsTime {
+		if aTime.Before(bTime) {
+			return -1, nil
+		} else if aTime.After(bTime) {
+			return 1, nil
+		}
+		return 0, nil
+	}
+	return 0, fmt.Errorf("can only order two numbers, two strings or two datetimes, got %v (%T) and %v (%T)", a, a, b, b)
+}
+
 func toString(val interface{}) (string, error) {
 	switch val.(type) {
 	case string:
 		return val.(string), nil
 	case int, int32, int64:
 		return fmt.Sprintf("%d", val), nil
+	case float64:
+		return strconv.FormatFloat(val.(float64), 'f', -1, 64), nil
+	case time.Time:
+		return val.(time.Time).Format(time.RFC3339Nano), nil
+	case neo4j.Date:
+		return time.Time(val.(neo4j.Date)).Format("2006-01-02"), nil
+	case neo4j.Duration:
+		return val.(neo4j.Duration).String(), nil
 	case bool:
 		if val.(bool) {
 			return "true", nil
@@ -1027,6 +2325,179 @@ func toString(val interface{}) (string, error) {
 	}
 }
 
+// A random, version 4, UUID, like Cypher's randomUUID(). These come from crypto/rand rather than the script's
+// random numbers, which are seeded, so no two clients, warmups or runs with the same seed generate the same one.
+func randomUUID() (string, error) {
+	var b [16]byte
+	if _, err := cryptorand.Read(b[:]); err != nil {
+		return "", errors.Wrap(err, "failed to generate uuid")
+	}
+	b[6] = (b[6] & 0x0f) | 0x40
+	b[8] = (b[8] & 0x3f) | 0x80
+	return fmt.Sprintf("%x-%x-%x-%x-%x", b[0:4], b[4:6], b[6:8], b[8:10], b[10:16]), nil
+}
+
+// The longest string random_string(..) makes, eight megabytes; as with random_text(..), above that it is more likely
+// a mistake than a property anyone means to write
+const maxRandomStringLength = 8 << 20
+
+const randomStringAlphabet = "abcdefghijklmnopqrstuvwxyzABCDEFGHIJKLMNOPQRSTUVWXYZ0123456789"
+
+// Letters and digits, one byte each, so the length is the size in bytes too
+func randomString(r *rand.Rand, length int64) string {
+	out := make([]byte, length)
+	for i := range out {
+		out[i] = randomStringAlphabet[r.Intn(len(randomStringAlphabet))]
+	}
+	return string(out)
+}
+
+// The most words random_text(..) makes, which is around six megabytes of text; above that it is more likely a
+// mistake than a property anyone means to write
+const maxRandomTextWords = 1 << 20
+
+// Words of random_text(..); lorem ipsum, so text reads as filler wherever it ends up
+var randomTextWords = strings.Fields(`lorem ipsum dolor sit amet consectetur adipiscing elit sed do eiusmod tempor
+incididunt ut labore et dolore magna aliqua enim ad minim veniam quis nostrud exercitation ullamco laboris nisi
+aliquip ex ea commodo consequat duis aute irure in reprehenderit voluptate velit esse cillum eu fugiat nulla
+pariatur excepteur sint occaecat cupidatat non proident sunt culpa qui officia deserunt mollit anim id est laborum`)
+
+func randomText(r *rand.Rand, words int64) string {
+	var b strings.Builder
+	for i := int64(0); i < words; i++ {
+		if i > 0 {
+			b.WriteByte(' ')
+		}
+		b.WriteString(randomTextWords[r.Intn(len(randomTextWords))])
+	}
+	return b.String()
+}
+
+// Datetimes are time.Time, in UTC, and dates neo4j.Date, so they are sent to the server as the Cypher types
+func asTime(value interface{}) (time.Time, bool) {
+	switch v := value.(type) {
+	case time.Time:
+		return v, true
+	case neo4j.Date:
+		return time.Time(v), true
+	}
+	return time.Time{}, false
+}
+
+// Reads a datetime or a date, milliseconds since the epoch, or an ISO 8601 string, like "2021-03-04T10:00:00Z" or
+// "2021-03-04"
+func toTime(value interface{}) (time.Time, error) {
+	if t, ok := asTime(value); ok {
+		return t, nil
+	}
+	switch v := value.(type) {
+	case int64:
+		return time.Unix(0, v*int64(time.Millisecond)).UTC(), nil
+	case string:
+		for _, layout := range []string{time.RFC3339Nano, "2006-01-02T15:04:05", "2006-01-02"} {
+			if t, err := time.Parse(layout, v); err == nil {
+				return t.UTC(), nil
+			}
+		}
+		return time.Time{}, fmt.Errorf("expected an ISO 8601 datetime or date, like \"2021-03-04T10:00:00Z\" or \"2021-03-04\", got \"%s\"", v)
+	}
+	return time.Time{}, fmt.Errorf("expected a datetime, a date, milliseconds since the epoch or an ISO 8601 string, got %v", value)
+}
+
+// The coordinate reference systems of points, as Neo4j numbers them
+const (
+	sridWGS84       uint32 = 4326
+	sridWGS843D     uint32 = 4979
+	sridCartesian   uint32 = 7203
+	sridCartesian3D uint32 = 9157
+)
+
+// A point of a map of its coordinates, as in Cypher: latitude, longitude and optionally height for a geographic
+// point, or x, y and optionally z for a cartesian one
+func pointOf(coordinates map[string]interface{}) (interface{}, error) {
+	values := make(map[string]float64, len(coordinates))
+	for name, raw := range coordinates {
+		n, err := asNumber(raw)
+		if err != nil {
+			return nil, errors.Wrapf(err, "coordinate %s needs to be a number", name)
+		}
+		values[name] = n.val
+	}
+	has := func(names ...string) bool {
+		for _, name := range names {
+			if _, found := coordinates[name]; !found {
+				return false
+			}
+		}
+		return len(coordinates) == len(names)
+	}
+	switch {
+	case has("latitude", "longitude"):
+		return neo4j.Point2D{X: values["longitude"], Y: values["latitude"], SpatialRefId: sridWGS84}, nil
+	case has("latitude", "longitude", "height"):
+		return neo4j.Point3D{X: values["longitude"], Y: values["latitude"], Z: values["height"], SpatialRefId: sridWGS843D}, nil
+	case has("x", "y"):
+		return neo4j.Point2D{X: values["x"], Y: values["y"], SpatialRefId: sridCartesian}, nil
+	case has("x", "y", "z"):
+		return neo4j.Point3D{X: values["x"], Y: values["y"], Z: values["z"], SpatialRefId: sridCartesian3D}, nil
+	}
+	return nil, fmt.Errorf("a point needs latitude and longitude, and optionally height, or x and y, and optionally z, got %v", coordinates)
+}
+
+// How long each part of a duration is; years and months are months, and weeks are days, since they aren't all
+// the same length
+var durationParts = map[string]struct {
+	months, days int64
+	time         time.Duration
+}{
+	"years":        {months: 12},
+	"months":       {months: 1},
+	"weeks":        {days: 7},
+	"days":         {days: 1},
+	"hours":        {time: time.Hour},
+	"minutes":      {time: time.Minute},
+	"seconds":      {time: time.Second},
+	"milliseconds": {time: time.Millisecond},
+}
+
+// A duration of a map of its parts, as in Cypher, like {days: 1, hours: 12}; years, months, weeks and days need to
+// be whole, the rest can be fractional
+func durationOf(parts map[string]interface{}) (neo4j.Duration, error) {
+	var months, days int64
+	var total time.Duration
+	for name, raw := range parts {
+		part, found := durationParts[name]
+		if !found {
+			return neo4j.Duration{}, fmt.Errorf("a duration is made of years, months, weeks, days, hours, minutes, seconds and milliseconds, got %s", name)
+		}
+		n, err := asNumber(raw)
+		if err != nil {
+			return neo4j.Duration{}, errors.Wrapf(err, "%s of a duration need to be a number", name)
+		}
+		if part.time == 0 {
+			if n.isDouble {
+				return neo4j.Duration{}, fmt.Errorf("%s of a duration need to be a whole number, got %v", name, raw)
+			}
+			months += n.iVal * part.months
+			days += n.iVal * part.days
+			continue
+		}
+		total += time.Duration(n.val * float64(part.time))
+	}
+	return durationOfTime(months, days, total), nil
+}
+
+// The duration of so many months and days and then the time on top, with the nanoseconds of negative times taken
+// from the second before, as the driver expects
+func durationOfTime(months, days int64, total time.Duration) neo4j.Duration {
+	seconds, nanos := int64(total/time.Second), int(total%time.Second)
+	if nanos < 0 {
+		seconds--
+		nanos += int(time.Second)
+	}
+	return neo4j.DurationOf(months, days, seconds, nanos)
+}
+
 // Range, inclusive on both bounds to match cypher
 func rangeFn(min, max int64) (interface{}, error) {
 	out := make([]interface{}, 0, max-min)
@@ -1131,6 +2602,42 @@ func ExponentialRand(random *rand.Rand, min, max int64, parameter float64) (int6
 	return min + int64(float64(max-min+1)*randVal), nil
 }
 
+const minZipfianParam = 1.001
+const maxZipfianParam = 1000.0
+
+/* translated from pgbench.c; min is the most frequent value, then min + 1, and so on */
+func ZipfianRand(random *rand.Rand, min, max int64, parameter float64) (int64, error) {
+	if parameter < minZipfianParam || parameter > maxZipfianParam {
+		return 0, fmt.Errorf("random_zipfian 'parameter' argument must be between %.3f and %.0f, got %g", minZipfianParam, maxZipfianParam, parameter)
+	}
+	if min > max {
+		/* there'd be nothing to draw from, and the loop below would never end */
+		return 0, fmt.Errorf("random_zipfian(..) needs the least value before the greatest, got %d and %d", min, max)
+	}
+	n := max - min + 1
+
+	/*
+	 * Rejection-inversion, from Luc Devroye, "Non-Uniform Random Variate Generation", p. 550-551;
+	 * gives x in [1, n]
+	 */
+	b := math.Pow(2.0, parameter-1.0)
+	var x float64
+	for {
+		/* random variates */
+		u := random.Float64()
+		v := random.Float64()
+
+		x = math.Floor(math.Pow(u, -1.0/(parameter-1.0)))
+
+		t := math.Pow(1.0+1.0/x, parameter-1.0)
+		/* reject if too large or out of bound */
+		if v*x*(t-1.0)/(b-1.0) <= t/b && x <= float64(n) {
+			break
+		}
+	}
+	return min - 1 + int64(x), nil
+}
+
 // Hacky first stab at dealing with runtime coercion, refactor as needed
 type Number struct {
 	isDouble bool
@@ -1161,6 +2668,12 @@ type parseToken struct {
 
 type parseContext struct {
 	s scanner.Scanner
+	// What s reads from, to quote parts of it in errors
+	src string
+	// How many :if and :for blocks the commands being parsed are in
+	blocks int
+	// Whether the commands being parsed are in the :init of the script
+	init bool
 	// The stack is used for peeking and backtracking;
 	// it only comes into play if you call Peek or manually manipulate it.
 	// When calling Next(), it first checks (and pops) the stack before it goes
@@ -1168,6 +2681,17 @@ type parseContext struct {
 	stack []parseToken
 	done  bool
 	err   error
+	// Cypher fragments defined with :define, substituted into queries at parse time
+	macros map[string]string
+	// How many queries and :locks the script has so far, :include files and all, see QueryCommand.Id
+	statements *int
+	// Absolute paths of the script and the ones it's parsing through :include, outermost first, to catch includes
+	// that loop; empty outside of an :include
+	including []string
+	// Set by :autocommit and :timeout for the query after them, along with the first of these that is waiting for
+	// that query, to say which one misses it
+	annotations QueryCommand
+	annotation  string
 }
 
 func newParseContext(in, name string) *parseContext {
@@ -1175,12 +2699,33 @@ func newParseContext(in, name string) *parseContext {
 	s.Init(strings.NewReader(in))
 	s.Filename = name
 	s.Whitespace ^= 1 << '\n' // don't skip newlines
+	s.Error = func(s *scanner.Scanner, msg string) {
+		// Single quotes are strings in Cypher and in expressions, rather than Go characters
+		if msg == "invalid char literal" {
+			return
+		}
+		pos := s.Position
+		if !pos.IsValid() {
+			pos = s.Pos()
+		}
+		fmt.Fprintf(os.Stderr, "%s: %s\n", pos, msg)
+	}
 
 	return &parseContext{
-		s: s,
+		s:      s,
+		src:    in,
+		macros: make(map[string]string),
+		// Starts at zero, so a script with a single query has it as statement 0
+		statements: new(int),
 	}
 }
 
+func (c *parseContext) nextStatementId() int {
+	id := *c.statements
+	*c.statements++
+	return id
+}
+
 func (t *parseContext) Peek() (rune, string) {
 	if len(t.stack) == 0 {
 		token := t.s.Scan()
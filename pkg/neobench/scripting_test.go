@@ -3,9 +3,15 @@ package neobench
 import (
 	"bytes"
 	"fmt"
+	"github.com/neo4j/neo4j-go-driver/v4/neo4j"
 	"github.com/stretchr/testify/assert"
+	"io/ioutil"
 	"math"
 	"math/rand"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
 	"testing"
 	"time"
 )
@@ -42,6 +48,7 @@ func TestSleepDuration(t *testing.T) {
 	tests := map[string]struct {
 		expectSleepDuration time.Duration
 		expectError         error
+		expectEvalError     error
 	}{
 		":sleep 10": {
 			expectSleepDuration: 10 * time.Second,
@@ -61,7 +68,26 @@ func TestSleepDuration(t *testing.T) {
 		":sleep 10 days": {
 			expectError: fmt.Errorf(":sleep command must use 'us', 'ms', or 's' unit argument - or none. got: days (at testSleep:':sleep 10 days':1:15)"),
 		},
+		":sleep 1.5 ms": {
+			expectSleepDuration: 1500 * time.Microsecond,
+		},
+		":sleep $pause ms": {
+			expectSleepDuration: 25 * time.Millisecond,
+		},
+		":sleep $think": {
+			expectSleepDuration: 250 * time.Millisecond,
+		},
+		":sleep $think ms": {
+			expectEvalError: fmt.Errorf(":sleep with a unit needs a number, got '250ms', which has a unit of its own"),
+		},
+		":sleep \"soon\"": {
+			expectEvalError: fmt.Errorf(":sleep needs a number or a duration like \"250ms\", got 'soon'"),
+		},
+		":sleep -5 ms": {
+			expectEvalError: fmt.Errorf(":sleep can't sleep for a negative duration, got -5ms"),
+		},
 	}
+	vars := map[string]interface{}{"pause": int64(25), "think": "250ms"}
 
 	for given, tc := range tests {
 		given, tc := given, tc
@@ -75,8 +101,13 @@ func TestSleepDuration(t *testing.T) {
 
 			assert.NoError(t, err)
 			cmd := script.Commands[0].(SleepCommand)
-			actualDurationBase, err := cmd.Duration.Eval(nil)
-			assert.Equal(t, tc.expectSleepDuration, time.Duration(actualDurationBase.(int64))*cmd.Unit)
+			actualDuration, err := cmd.Evaluate(&ScriptContext{Vars: vars})
+			if tc.expectEvalError != nil {
+				assert.Equal(t, tc.expectEvalError, err)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, tc.expectSleepDuration, actualDuration)
 		})
 	}
 }
@@ -92,6 +123,25 @@ func TestExpressions(t *testing.T) {
 		"\"Hello\"":             "Hello",
 		"\"Hello\" + 123":       "Hello123",
 		"123 + \"Hello\" + 123": "123Hello123",
+		"\"a\" + 1.5":           "a1.5",
+		"\"\\d+\"":              `\d+`,
+		"\"a\\nb\"":             `a\nb`,
+		"'it\\'s'":              "it's",
+		"'a\\nb'":               "a\nb",
+
+		// Strings
+		"format(\"user-%05d\", 42)":                       "user-00042",
+		"format(\"%s:%d\", \"a\", $scale + 1)":            "a:2",
+		"substring(\"neobench\", 3)":                      "bench",
+		"substring(\"neobench\", 0, 3)":                   "neo",
+		"substr(\"neobench\", 3, 100)":                    "bench",
+		"substring(\"neobench\", 20)":                     "",
+		"substring(\"neobench\", 3, 9223372036854775807)": "bench",
+		"toString(42) + toString(1.5)":                    "421.5",
+		"toUpper(\"abc\") + toLower(\"DEF\")":             "ABCdef",
+		"trim(\"  padded \")":                             "padded",
+		"replace(\"a-b-c\", \"-\", \"+\")":                "a+b+c",
+		"len(\"héllo\")":                                  int64(5),
 
 		// Composites
 		"[1, 2, [3]]":    []interface{}{int64(1), int64(2), []interface{}{int64(3)}},
@@ -140,8 +190,60 @@ func TestExpressions(t *testing.T) {
 		"[1,2][1]":             int64(2),
 		"range(1, 5)[abs(-1)]": int64(2),
 
+		"2 * $somelist[1]": int64(4),
+
+		// Unary minus
+		"-(1 + 2)":      int64(-3),
+		"-$scale * 2":   int64(-2),
+		"1 - -$scale":   int64(2),
+		"-abs(-1.5)":    -1.5,
+		"-len([1]) + 1": int64(0),
+
+		// Nested calls
+		"abs(least(-3, 2) * 2)":                      int64(6),
+		"1 + $scale * (len(range(1, least(10, 4))))": int64(5),
+
+		// Comparisons
+		"1 < 2":              true,
+		"2 <= 2":             true,
+		"3 > 4":              false,
+		"1 >= 1.5":           false,
+		"1 = 1.0":            true,
+		"1 <> 2":             true,
+		"1 != 1":             false,
+		"\"a\" < \"b\"":      true,
+		"\"a\" = \"a\"":      true,
+		"\"a\" = 1":          false,
+		"[1, 2] = $somelist": true,
+		"1 + 1 = 2 * 1":      true,
+
+		// Booleans
+		"true":                    true,
+		"FALSE":                   false,
+		"1 < 2 AND 2 < 3":         true,
+		"1 > 2 or 2 < 3":          true,
+		"NOT 1 = 2":               true,
+		"not true and false":      false,
+		"false or true and false": false,
+
 		// List comprehension
-		"[ i in range(1,3) | $i ]": []interface{}{int64(1), int64(2), int64(3)},
+		"[ i in range(1,3) | $i ]":                       []interface{}{int64(1), int64(2), int64(3)},
+		"[ i in range(1,6) WHERE $i % 2 = 0 ]":           []interface{}{int64(2), int64(4), int64(6)},
+		"[ i in range(1,6) WHERE $i > 4 | $i * 10 ]":     []interface{}{int64(50), int64(60)},
+		"[ i in range(1,2) | {aid: $i, delta: $i * 2} ]": []interface{}{map[string]interface{}{"aid": int64(1), "delta": int64(2)}, map[string]interface{}{"aid": int64(2), "delta": int64(4)}},
+
+		// Lists and maps
+		"{\"first name\": \"Bob\", 'age': 42}": map[string]interface{}{"first name": "Bob", "age": int64(42)},
+		"{aid: 1, tags: [\"a\", \"b\"]}":       map[string]interface{}{"aid": int64(1), "tags": []interface{}{"a", "b"}},
+		"[1, 2] + [3]":                         []interface{}{int64(1), int64(2), int64(3)},
+		"$somelist + 3":                        []interface{}{int64(1), int64(2), int64(3)},
+		"0 + $somelist":                        []interface{}{int64(0), int64(1), int64(2)},
+		"$somelist[-1]":                        int64(2),
+		"$somelist[2]":                         nil,
+		"{aid: 1}[\"aid\"]":                    int64(1),
+		"keys({b: 1, a: 2})":                   []interface{}{"a", "b"},
+		"size({b: 1, a: 2})":                   int64(2),
+		"len({})":                              int64(0),
 
 		// Functions
 		"abs(-17)":   int64(17),
@@ -149,27 +251,61 @@ func TestExpressions(t *testing.T) {
 		"csv(\"/data.csv\")": []interface{}{
 			[]interface{}{"row1", int64(1), 1.3},
 			[]interface{}{"row2", int64(2), 1.0}},
-		"double(5432)":                   float64(5432),
-		"double(5432.0)":                 float64(5432),
-		"greatest(5, 4, 3, 2)":           int64(5),
-		"greatest(-5, -4, -3, -2)":       int64(-2),
-		"greatest(5, 4, 3, 2.0, 8)":      float64(8),
-		"least(5, 4, 3, 2)":              int64(2),
-		"least(5, 4, 3, 2.0, 8)":         2.0,
-		"least(-5, -4, -3, -2)":          int64(-5),
-		"len([1,2,3])":                   int64(3),
-		"len([])":                        int64(0),
-		"int(5.4 + 3.8)":                 int64(9),
-		"int(5 + 4)":                     int64(9),
-		"pi()":                           math.Pi,
-		"random(1, 5)":                   int64(3),
-		"random_gaussian(1, 10, 2.5)":    int64(3),
-		"random_exponential(1, 10, 2.5)": int64(4),
-		"range(1, 5)":                    []interface{}{int64(1), int64(2), int64(3), int64(4), int64(5)},
+		"sample(\"/ids.txt\")":                     int64(42),
+		"double(5432)":                             float64(5432),
+		"double(5432.0)":                           float64(5432),
+		"greatest(5, 4, 3, 2)":                     int64(5),
+		"greatest(-5, -4, -3, -2)":                 int64(-2),
+		"greatest(5, 4, 3, 2.0, 8)":                float64(8),
+		"least(5, 4, 3, 2)":                        int64(2),
+		"least(5, 4, 3, 2.0, 8)":                   2.0,
+		"least(-5, -4, -3, -2)":                    int64(-5),
+		"len([1,2,3])":                             int64(3),
+		"len([])":                                  int64(0),
+		"int(5.4 + 3.8)":                           int64(9),
+		"int(5 + 4)":                               int64(9),
+		"round(5.5)":                               int64(6),
+		"round(5.4)":                               int64(5),
+		"round(-5.5)":                              int64(-6),
+		"round(7)":                                 int64(7),
+		"pi()":                                     math.Pi,
+		"random(1, 5)":                             int64(3),
+		"random_gaussian(1, 10, 2.5)":              int64(3),
+		"random_exponential(1, 10, 2.5)":           int64(4),
+		"random_zipfian(1, 10, 1.5)":               int64(2),
+		"choice('read', 'write', 'scan')":          "write",
+		"random_choice([1, 2, 3])":                 int64(2),
+		"weighted_choice('read': 90, 'write': 10)": "read",
+		"weighted_choice(1: 0, 2: 0.5)":            int64(2),
+		"choice(\"only\")":                         "only",
+		"'it\\'s' + ' \"quoted\"'":                 "it's \"quoted\"",
+		"range(1, 5)":                              []interface{}{int64(1), int64(2), int64(3), int64(4), int64(5)},
 		"random_matrix(2, [1,5], [5,8])": []interface{}{
 			[]interface{}{int64(3), int64(5)},
 			[]interface{}{int64(1), int64(5)}},
-		"sqrt(2.0)": 1.414213562,
+		"sqrt(2.0)":               1.414213562,
+		"datetime(1614852000000)": time.Date(2021, 3, 4, 10, 0, 0, 0, time.UTC),
+		"datetime(\"2021-03-04T11:00:00+01:00\")":                      time.Date(2021, 3, 4, 10, 0, 0, 0, time.UTC),
+		"epoch_millis(datetime(\"2021-03-04T10:00:00Z\"))":             int64(1614852000000),
+		"date(\"2021-03-04T23:00:00Z\")":                               neo4j.DateOf(time.Date(2021, 3, 4, 0, 0, 0, 0, time.UTC)),
+		"date_add(datetime(\"2021-03-04T10:00:00Z\"), -90, \"m\")":     time.Date(2021, 3, 4, 8, 30, 0, 0, time.UTC),
+		"date_add(date(\"2021-02-28\"), 36, \"h\")":                    neo4j.DateOf(time.Date(2021, 3, 1, 0, 0, 0, 0, time.UTC)),
+		"date_diff(date(\"2021-03-04\"), date(\"2021-03-18\"), \"w\")": int64(2),
+		"datetime(1000) < datetime(2000)":                              true,
+		"toString(date_add(datetime(0), 1.5, \"s\"))":                  "1970-01-01T00:00:01.5Z",
+		"random_datetime(\"2021-03-04\", \"2021-03-05\")":              time.Date(2021, 3, 4, 9, 31, 45, 487000000, time.UTC),
+		"random_datetime(datetime(0), datetime(0))":                    time.Unix(0, 0).UTC(),
+		"duration({days: 14, hours: 16, minutes: 12})":                 neo4j.DurationOf(0, 14, 58320, 0),
+		"duration({years: 1, months: 2, weeks: 1, seconds: 1.5})":      neo4j.DurationOf(14, 7, 1, 500000000),
+		"duration({milliseconds: -1500})":                              neo4j.DurationOf(0, 0, -2, 500000000),
+		"toString(duration({days: 1, seconds: 30}))":                   "P0M1DT30S",
+		"random_duration(1, 30, \"d\")":                                neo4j.DurationOf(0, 17, 0, 0),
+		"random_duration(100, 100, \"ms\")":                            neo4j.DurationOf(0, 0, 0, 100000000),
+		"point({latitude: 55.6, longitude: 13.0})":                     neo4j.Point2D{X: 13.0, Y: 55.6, SpatialRefId: 4326},
+		"point({latitude: 55.6, longitude: 13.0, height: 100})":        neo4j.Point3D{X: 13.0, Y: 55.6, Z: 100, SpatialRefId: 4979},
+		"point({x: 1, y: 2.5})":                                        neo4j.Point2D{X: 1, Y: 2.5, SpatialRefId: 7203},
+		"point({x: 1, y: 2, z: 3})":                                    neo4j.Point3D{X: 1, Y: 2, Z: 3, SpatialRefId: 9157},
+		"random_point(55, 12, 56, 14)":                                 neo4j.Point2D{X: 13.257477084264405, Y: 55.34221479613056, SpatialRefId: 4326},
 	}
 
 	for expr, expected := range tc {
@@ -189,6 +325,7 @@ RETURN {v};`, expr), 1)
 				CsvLoader: fakeCsvLoader(map[string]string{
 					"/data.csv": `row1, 1, 1.3
 "row2", 2, 1.0`,
+					"/ids.txt": "42\n",
 				}),
 			})
 			assert.NoError(t, err, "%+v", err)
@@ -202,6 +339,187 @@ RETURN {v};`, expr), 1)
 	}
 }
 
+func TestBatchParameters(t *testing.T) {
+	script, err := Parse("batch", `:set rows [i in range(1, 100) | {aid: random(1, 1000), delta: random(-10, 10)}]
+UNWIND $rows AS row
+MATCH (a:Account {aid: row.aid}) SET a.balance = a.balance + row.delta;`, 1)
+	assert.NoError(t, err)
+	uow, err := script.Eval(ScriptContext{Vars: map[string]interface{}{}, Rand: rand.New(rand.NewSource(1337))})
+	assert.NoError(t, err)
+
+	rows := uow.Statements[0].Params["rows"].([]interface{})
+	assert.Len(t, rows, 100)
+	for _, row := range rows {
+		assert.Len(t, row, 2)
+		assert.IsType(t, int64(0), row.(map[string]interface{})["aid"])
+		assert.IsType(t, int64(0), row.(map[string]interface{})["delta"])
+	}
+}
+
+func TestInvalidStringEscape(t *testing.T) {
+	_, err := Parse("escape", ":set pattern '\\d+'\nRETURN 1;", 1)
+	assert.EqualError(t, err, `'\d+' has a backslash that isn't an escape like \n or \', write a backslash as \\ (at escape:1:19)`)
+}
+
+func TestZipfianRand(t *testing.T) {
+	r := rand.New(rand.NewSource(1337))
+	counts := make(map[int64]int)
+	for i := 0; i < 10000; i++ {
+		v, err := ZipfianRand(r, 5, 104, 1.5)
+		assert.NoError(t, err)
+		assert.True(t, v >= 5 && v <= 104, v)
+		counts[v]++
+	}
+	// The lowest values are the most frequent, and the first is about 2^1.5 times as frequent as the second
+	assert.True(t, counts[5] > counts[6] && counts[6] > counts[7], "%v", counts)
+	assert.InDelta(t, math.Pow(2, 1.5), float64(counts[5])/float64(counts[6]), 0.4)
+
+	_, err := ZipfianRand(r, 1, 10, 1.0)
+	assert.EqualError(t, err, "random_zipfian 'parameter' argument must be between 1.001 and 1000, got 1")
+}
+
+func TestUUIDsAreUniqueAcrossClientsWithTheSameSeed(t *testing.T) {
+	script, err := Parse("uuid", ":set id uuid()\n:set other randomUUID()\nRETURN $id, $other;", 1)
+	assert.NoError(t, err)
+	format := regexp.MustCompile(`^[0-9a-f]{8}-[0-9a-f]{4}-4[0-9a-f]{3}-[89ab][0-9a-f]{3}-[0-9a-f]{12}$`)
+	seen := make(map[string]bool)
+	for i := 0; i < 100; i++ {
+		uow, err := script.Eval(ScriptContext{Vars: map[string]interface{}{}, Rand: rand.New(rand.NewSource(1337))})
+		assert.NoError(t, err)
+		for _, name := range []string{"id", "other"} {
+			id := uow.Statements[0].Params[name].(string)
+			assert.Regexp(t, format, id)
+			assert.False(t, seen[id], id)
+			seen[id] = true
+		}
+	}
+}
+
+func TestWeightedChoice(t *testing.T) {
+	script, err := Parse("weighted", ":set op weighted_choice('read': 90, 'write': 9, 'scan': 1)\nRETURN $op;", 1)
+	assert.NoError(t, err)
+	r := rand.New(rand.NewSource(1337))
+	counts := make(map[interface{}]int)
+	for i := 0; i < 10000; i++ {
+		uow, err := script.Eval(ScriptContext{Vars: map[string]interface{}{}, Rand: r})
+		assert.NoError(t, err)
+		counts[uow.Statements[0].Params["op"]]++
+	}
+	assert.InDelta(t, 9000, counts["read"], 150)
+	assert.InDelta(t, 900, counts["write"], 100)
+	assert.InDelta(t, 100, counts["scan"], 40)
+
+	_, err = Parse("weighted", ":set op weighted_choice('read', 'write')\nRETURN $op;", 1)
+	assert.EqualError(t, err, "weighted_choice(..) needs a weight after each value, like weighted_choice(\"read\": 90, \"write\": 10) (at weighted:1:32)")
+}
+
+func TestRandomStringAndText(t *testing.T) {
+	script, err := Parse("payload", ":set s random_string(16)\n:set text random_text(3, 5)\n:set none random_string(0)\n:set two random_text(2, 2)\nRETURN $s, $text, $none, $two;", 1)
+	assert.NoError(t, err)
+	r := rand.New(rand.NewSource(1337))
+	words := make(map[int]bool)
+	for i := 0; i < 100; i++ {
+		uow, err := script.Eval(ScriptContext{Vars: map[string]interface{}{}, Rand: r})
+		assert.NoError(t, err)
+		params := uow.Statements[0].Params
+		words[len(strings.Fields(params["text"].(string)))] = true
+		assert.Len(t, strings.Fields(params["two"].(string)), 2)
+		assert.Regexp(t, regexp.MustCompile(`^[a-zA-Z0-9]{16}$`), params["s"])
+		assert.Regexp(t, regexp.MustCompile(`^[a-z]+( [a-z]+){2,4}$`), params["text"])
+		assert.Equal(t, "", params["none"])
+	}
+	// Both bounds are included
+	assert.Equal(t, map[int]bool{3: true, 4: true, 5: true}, words)
+
+	for expr, expected := range map[string]string{
+		"random_string(-1)":                   "random_string(..) needs a length that is an integer no less than zero",
+		"random_string(1.5)":                  "random_string(..) needs a length that is an integer no less than zero",
+		"random_string(9223372036854775807)":  "random_string(..) can make strings of at most 8388608 characters",
+		"random_text(5, 3)":                   "random_text(..) needs integer word counts, with 0 <= minWords <= maxWords",
+		"random_text(0, 9223372036854775807)": "random_text(..) can make at most 1048576 words",
+	} {
+		script, err := Parse("payload", fmt.Sprintf(":set v %s\nRETURN 1;", expr), 1)
+		assert.NoError(t, err, expr)
+		_, err = script.Eval(ScriptContext{Vars: map[string]interface{}{}, Rand: r})
+		assert.Error(t, err, expr)
+		if err != nil {
+			assert.Contains(t, err.Error(), expected, expr)
+		}
+	}
+}
+
+func TestSample(t *testing.T) {
+	script, err := Parse("/bench/sample.script", ":set id sample(\"ids.txt\")\nMATCH (n) WHERE id(n) = $id RETURN n;", 1)
+	assert.NoError(t, err)
+	loader := fakeCsvLoader(map[string]string{
+		"/bench/ids.txt":   "17\n\n4:abc:9\n  23  \n",
+		"/bench/empty.txt": "\n",
+	})
+	r := rand.New(rand.NewSource(1337))
+	seen := make(map[interface{}]bool)
+	for i := 0; i < 100; i++ {
+		uow, err := script.Eval(ScriptContext{Script: script, Vars: map[string]interface{}{}, Rand: r, CsvLoader: loader})
+		assert.NoError(t, err)
+		seen[uow.Statements[0].Params["id"]] = true
+	}
+	assert.Equal(t, map[interface{}]bool{int64(17): true, "4:abc:9": true, int64(23): true}, seen)
+
+	script, err = Parse("/bench/sample.script", ":set id sample(\"empty.txt\")\nRETURN $id;", 1)
+	assert.NoError(t, err)
+	_, err = script.Eval(ScriptContext{Script: script, Vars: map[string]interface{}{}, Rand: r, CsvLoader: loader})
+	assert.EqualError(t, err, "in sample(\"empty.txt\"): '/bench/empty.txt' has no values in it, it needs one on each line")
+}
+
+func TestNowIsTheClientClock(t *testing.T) {
+	script, err := Parse("now", ":set since date_add(now(), -1, \"h\")\n:set ms epoch_millis()\nRETURN $since, $ms;", 1)
+	assert.NoError(t, err)
+	before := time.Now()
+	uow, err := script.Eval(ScriptContext{Vars: map[string]interface{}{}, Rand: rand.New(rand.NewSource(1337))})
+	assert.NoError(t, err)
+	since := uow.Statements[0].Params["since"].(time.Time)
+	assert.Equal(t, time.UTC, since.Location())
+	assert.WithinDuration(t, before.Add(-time.Hour), since, time.Second)
+	assert.InDelta(t, before.UnixNano()/int64(time.Millisecond), uow.Statements[0].Params["ms"].(int64), 1000)
+}
+
+func TestExpressionTypeErrors(t *testing.T) {
+	for expr, expected := range map[string]string{
+		"1 < \"a\"":                           "can only order two numbers, two strings or two datetimes, got 1 (int64) and a (string)",
+		"1 AND true":                          "expected a boolean, got 1 (which is int64)",
+		"NOT 0":                               "expected a boolean, got 0 (which is int64)",
+		"format(\"%d\", \"a\")":               "the arguments don't match the format, got '%!d(string=a)'",
+		"choice()":                            "choice(..) needs at least one value to choose from",
+		"weighted_choice(1: -1, 2: 1)":        "weights of weighted_choice(..) can't be negative, got -1, in weighted_choice(1: -1, 2: 1)",
+		"weighted_choice(1: 0)":               "weighted_choice(..) needs at least one weight above zero",
+		"choice([])":                          "choice(..) can't choose from an empty list",
+		"substring(\"a\", -1)":                "start and length need to be integers no less than zero",
+		"datetime(\"soon\")":                  "expected an ISO 8601 datetime or date",
+		"date_add(1, 1, \"d\")":               "date_add(..) needs a datetime or a date to add to, got 1",
+		"date_diff(now(), now(), \"M\")":      "unit needs to be one of \"ms\", \"s\", \"m\", \"h\", \"d\" or \"w\", got \"M\"",
+		"keys([1])":                           "argument to keys(..) needs to be a map",
+		"random_datetime(now(), datetime(0))": "random_datetime(..) needs the earliest datetime first",
+		"duration({fortnights: 1})":           "a duration is made of years, months, weeks, days, hours, minutes, seconds and milliseconds, got fortnights",
+		"duration({days: 1.5})":               "days of a duration need to be a whole number, got 1.5",
+		"duration(\"P1D\")":                   "duration(..) takes a map of the parts of the duration",
+		"random_duration(5, 1, \"s\")":        "needs the least and the most units as integers, least first",
+		"random_duration(-1, 9223372036854775807, \"ms\")": "random_duration(..) can't pick from more than 9223372036854775807 units",
+		"point({latitude: 1})":                             "a point needs latitude and longitude, and optionally height, or x and y, and optionally z",
+		"point({x: \"a\", y: 1})":                          "coordinate x needs to be a number",
+		"random_point(10, 10, 0, 0)":                       "random_point(..) needs the least latitude and longitude before the greatest",
+		"random_point(0, 170, 10, 190)":                    "with latitudes from -90 to 90 and longitudes from -180 to 180",
+		"random_zipfian(10, 1, 1.5)":                       "random_zipfian(..) needs the least value before the greatest, got 10 and 1",
+		"[i in [1] WHERE $i | $i]":                         "WHERE in list comprehension must be true or false, got 1",
+	} {
+		script, err := Parse("expr", fmt.Sprintf(":set v %s\nRETURN 1;", expr), 1)
+		assert.NoError(t, err, expr)
+		_, err = script.Eval(ScriptContext{Vars: map[string]interface{}{}, Rand: rand.New(rand.NewSource(1337))})
+		assert.Error(t, err, expr)
+		if err != nil {
+			assert.Contains(t, err.Error(), expected, expr)
+		}
+	}
+}
+
 func TestDebugFunction(t *testing.T) {
 	vars := map[string]interface{}{"scale": int64(1)}
 	script, err := Parse("test:debug(..)", ":set blah debug(1337) * 10\nRETURN { blah };", 1)
@@ -317,8 +635,676 @@ MATCH (b);`, 1)
 			Params: map[string]interface{}{},
 		},
 		{
-			Query:  "MATCH (b)",
+			Query:   "MATCH (b)",
+			Params:  map[string]interface{}{},
+			Command: 1,
+		},
+	}, uow.Statements)
+}
+
+func TestDefineMacro(t *testing.T) {
+	script, err := Parse("define", `
+:define account "MATCH (account:Account {aid: $aid})"
+:define balance = "account.balance"
+:set aid 1
+
+@account RETURN @balance;
+@account SET @balance = @balance + 1 RETURN "@account";`, 1)
+
+	assert.NoError(t, err)
+	uow, err := script.Eval(ScriptContext{
+		Vars: map[string]interface{}{},
+		Rand: rand.New(rand.NewSource(1337)),
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, []Statement{
+		{
+			Query:   "MATCH (account:Account {aid: $aid}) RETURN account.balance",
+			Params:  map[string]interface{}{"aid": int64(1)},
+			Command: 0,
+		},
+		{
+			Query:   "MATCH (account:Account {aid: $aid}) SET account.balance = account.balance + 1 RETURN \"@account\"",
+			Params:  map[string]interface{}{"aid": int64(1)},
+			Command: 1,
+		},
+	}, uow.Statements)
+}
+
+// Fragments are referenced with @name, since :name is already a label inside a query and a meta command on a line
+// of its own
+func TestDefineMacroLeavesLabelsAlone(t *testing.T) {
+	script, err := Parse("define", `
+:define Account "MATCH (a:Account {aid: 1})"
+
+@Account MATCH (b:Account)-[:Account]->(a) RETURN b;`, 1)
+
+	assert.NoError(t, err)
+	uow, err := script.Eval(ScriptContext{
+		Vars: map[string]interface{}{},
+		Rand: rand.New(rand.NewSource(1337)),
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, []Statement{
+		{
+			Query:  "MATCH (a:Account {aid: 1}) MATCH (b:Account)-[:Account]->(a) RETURN b",
 			Params: map[string]interface{}{},
 		},
 	}, uow.Statements)
+
+	_, err = Parse("define", `
+:define account "MATCH (account:Account {aid: $aid})"
+:account RETURN account;`, 1)
+	assert.Error(t, err)
+	if err != nil {
+		assert.Contains(t, err.Error(), "unexpected meta command: 'account'")
+	}
+}
+
+func TestUndefinedMacro(t *testing.T) {
+	_, err := Parse("define", `RETURN @nope;`, 1)
+
+	assert.EqualError(t, err, "@nope is not defined, define it with :define nope \"<cypher fragment>\" before it's used (at define:1:13)")
+}
+
+func TestShuffle(t *testing.T) {
+	script, err := Parse("shuffle", `:opt shuffle
+:set a 1
+RETURN 1;
+RETURN $a;
+RETURN 3;`, 1)
+	assert.NoError(t, err)
+
+	r := rand.New(rand.NewSource(1337))
+	orders := make(map[string]bool)
+	for i := 0; i < 20; i++ {
+		uow, err := script.Eval(ScriptContext{
+			Vars: map[string]interface{}{},
+			Rand: r,
+		})
+		assert.NoError(t, err)
+		assert.ElementsMatch(t, []Statement{
+			{Query: "RETURN 1", Params: map[string]interface{}{}, Command: 0},
+			{Query: "RETURN $a", Params: map[string]interface{}{"a": int64(1)}, Command: 1},
+			{Query: "RETURN 3", Params: map[string]interface{}{}, Command: 2},
+		}, uow.Statements)
+		order := ""
+		for _, stmt := range uow.Statements {
+			order += stmt.Query
+		}
+		orders[order] = true
+	}
+	assert.Greater(t, len(orders), 1)
+}
+
+func TestDiscardResults(t *testing.T) {
+	script, err := Parse("discard", ":opt discard\nCREATE (n);", 1)
+	assert.NoError(t, err)
+	assert.True(t, script.DiscardResults)
+
+	uow, err := script.Eval(ScriptContext{
+		Vars: map[string]interface{}{},
+		Rand: rand.New(rand.NewSource(1337)),
+	})
+	assert.NoError(t, err)
+	assert.True(t, uow.DiscardResults)
+}
+
+func TestLock(t *testing.T) {
+	tests := map[string]struct {
+		expectKeys  []interface{}
+		expectError string
+	}{
+		":lock Account.aid [3, 1, 2]": {
+			expectKeys: []interface{}{int64(1), int64(2), int64(3)},
+		},
+		":lock Account.aid [3, 1, 2] asc": {
+			expectKeys: []interface{}{int64(1), int64(2), int64(3)},
+		},
+		":lock Account.aid [3, 1, 2] desc": {
+			expectKeys: []interface{}{int64(3), int64(2), int64(1)},
+		},
+		":lock Account.aid [3, 1, 2] given": {
+			expectKeys: []interface{}{int64(3), int64(1), int64(2)},
+		},
+		":lock Account.aid 7": {
+			expectKeys: []interface{}{int64(7)},
+		},
+		`:lock Account.aid ["b", "a"]`: {
+			expectKeys: []interface{}{"a", "b"},
+		},
+		`:lock Account.aid [1, "a"]`: {
+			expectError: ":lock Account.aid can't order its keys: keys need to be all numbers or all strings, got a and 1",
+		},
+	}
+
+	for given, tc := range tests {
+		given, tc := given, tc
+		t.Run(given, func(t *testing.T) {
+			script, err := Parse("lock", given+"\nRETURN 1;", 1)
+			assert.NoError(t, err)
+
+			uow, err := script.Eval(ScriptContext{
+				Vars: map[string]interface{}{},
+				Rand: rand.New(rand.NewSource(1337)),
+			})
+			if tc.expectError != "" {
+				assert.EqualError(t, err, tc.expectError)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, []Statement{
+				{
+					Query:  "UNWIND $nbLockKeys AS key MATCH (n:`Account` {`aid`: key}) SET n._lock = n._lock",
+					Params: map[string]interface{}{"nbLockKeys": tc.expectKeys},
+					Lock:   true,
+				},
+				{Query: "RETURN 1", Params: map[string]interface{}{}, Command: 1},
+			}, uow.Statements)
+		})
+	}
+}
+
+func TestLockInvalidOrder(t *testing.T) {
+	_, err := Parse("lock", ":lock Account.aid [1, 2] sideways", 1)
+	assert.EqualError(t, err, ":lock command must use 'asc', 'desc' or 'given' order argument - or none. got: sideways (at lock:1:34)")
+}
+
+func TestShuffleKeepsLocksInPlace(t *testing.T) {
+	script, err := Parse("shuffle", `:opt shuffle
+:lock Account.aid [1, 2]
+RETURN 1;
+RETURN 2;
+RETURN 3;`, 1)
+	assert.NoError(t, err)
+
+	r := rand.New(rand.NewSource(1337))
+	for i := 0; i < 20; i++ {
+		uow, err := script.Eval(ScriptContext{
+			Vars: map[string]interface{}{},
+			Rand: r,
+		})
+		assert.NoError(t, err)
+		assert.True(t, uow.Statements[0].Lock)
+	}
+}
+
+func TestTransactions(t *testing.T) {
+	script, err := Parse("transactions", `:begin
+CREATE (a);
+CREATE (b);
+:commit
+RETURN 1;
+:begin
+CREATE (c);
+:rollback
+RETURN 2;`, 1)
+	assert.NoError(t, err)
+	assert.True(t, script.ExplicitTransactions)
+
+	uow, err := script.Eval(ScriptContext{
+		Vars: map[string]interface{}{},
+		Rand: rand.New(rand.NewSource(1337)),
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, []Statement{
+		{Query: "CREATE (a)", Params: map[string]interface{}{}, Command: 0, Transaction: 0},
+		{Query: "CREATE (b)", Params: map[string]interface{}{}, Command: 1, Transaction: 0},
+		{Query: "RETURN 1", Params: map[string]interface{}{}, Command: 2, Transaction: 1},
+		{Query: "CREATE (c)", Params: map[string]interface{}{}, Command: 3, Transaction: 2},
+		{Query: "RETURN 2", Params: map[string]interface{}{}, Command: 4, Transaction: 3},
+	}, uow.Statements)
+	assert.Equal(t, map[int]bool{2: true}, uow.RolledBack)
+	assert.Len(t, uow.transactions(), 4)
+	assert.True(t, uow.transactions()[2].rolledBack)
+}
+
+func TestWithoutBeginTheScriptIsOneTransaction(t *testing.T) {
+	script, err := Parse("transactions", "CREATE (a);\nRETURN 1;", 1)
+	assert.NoError(t, err)
+
+	uow, err := script.Eval(ScriptContext{
+		Vars: map[string]interface{}{},
+		Rand: rand.New(rand.NewSource(1337)),
+	})
+	assert.NoError(t, err)
+	assert.Len(t, uow.transactions(), 1)
+	assert.Len(t, uow.transactions()[0].statements, 2)
+}
+
+func TestShuffleKeepsStatementsInTheirTransaction(t *testing.T) {
+	script, err := Parse("shuffle", `:opt shuffle
+:begin
+RETURN 1;
+RETURN 2;
+:commit
+:begin
+RETURN 3;
+RETURN 4;
+:commit`, 1)
+	assert.NoError(t, err)
+
+	r := rand.New(rand.NewSource(1337))
+	for i := 0; i < 20; i++ {
+		uow, err := script.Eval(ScriptContext{
+			Vars: map[string]interface{}{},
+			Rand: r,
+		})
+		assert.NoError(t, err)
+		txs := uow.transactions()
+		assert.Len(t, txs, 2)
+		assert.ElementsMatch(t, []string{"RETURN 1", "RETURN 2"}, []string{txs[0].statements[0].Query, txs[0].statements[1].Query})
+		assert.ElementsMatch(t, []string{"RETURN 3", "RETURN 4"}, []string{txs[1].statements[0].Query, txs[1].statements[1].Query})
+	}
+}
+
+func TestAutocommitQuery(t *testing.T) {
+	script, err := Parse("autocommit", `CREATE (a);
+:autocommit
+CREATE (b);
+CREATE (c);
+RETURN 1;`, 1)
+	assert.NoError(t, err)
+
+	uow, err := script.Eval(ScriptContext{
+		Vars: map[string]interface{}{},
+		Rand: rand.New(rand.NewSource(1337)),
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, []Statement{
+		{Query: "CREATE (a)", Params: map[string]interface{}{}, Command: 0, Transaction: 0},
+		{Query: "CREATE (b)", Params: map[string]interface{}{}, Command: 1, Transaction: 1, Autocommit: true},
+		{Query: "CREATE (c)", Params: map[string]interface{}{}, Command: 2, Transaction: 2},
+		{Query: "RETURN 1", Params: map[string]interface{}{}, Command: 3, Transaction: 2},
+	}, uow.Statements)
+	assert.Len(t, uow.transactions(), 3)
+	assert.True(t, uow.transactions()[1].autocommit)
+
+	_, err = Parse("autocommit", "RETURN 1;\n:autocommit\n", 1)
+	assert.EqualError(t, err, ":autocommit needs a query after it, which it runs as an auto-commit query (at autocommit:3:1)")
+
+	script, err = Parse("autocommit", ":begin\n:autocommit\nRETURN 1;\n:commit", 1)
+	assert.NoError(t, err)
+	_, err = script.Eval(ScriptContext{Vars: map[string]interface{}{}, Rand: rand.New(rand.NewSource(1337))})
+	assert.EqualError(t, err, ":autocommit query inside :begin; it commits on its own, so it needs to be outside of the transaction")
+}
+
+func TestOptAutocommitRunsEachQueryOnItsOwn(t *testing.T) {
+	script, err := Parse("autocommit", ":opt autocommit\nCREATE (a);\nCREATE (b);", 1)
+	assert.NoError(t, err)
+
+	uow, err := script.Eval(ScriptContext{
+		Vars: map[string]interface{}{},
+		Rand: rand.New(rand.NewSource(1337)),
+	})
+	assert.NoError(t, err)
+	txs := uow.transactions()
+	assert.Len(t, txs, 2)
+	assert.True(t, txs[0].autocommit)
+	assert.True(t, txs[1].autocommit)
+}
+
+func TestMode(t *testing.T) {
+	for given, expected := range map[string]struct {
+		readonly   bool
+		accessMode neo4j.AccessMode
+	}{
+		"RETURN 1;":               {readonly: true, accessMode: neo4j.AccessModeWrite},
+		":mode read\nCREATE (n);": {readonly: true, accessMode: neo4j.AccessModeRead},
+		":mode write\nRETURN 1;":  {readonly: false, accessMode: neo4j.AccessModeWrite},
+	} {
+		script, err := Parse("mode", given, 1)
+		assert.NoError(t, err)
+		// As if EXPLAIN said the script only reads
+		script.Readonly = true
+
+		uow, err := script.Eval(ScriptContext{
+			Vars: map[string]interface{}{},
+			Rand: rand.New(rand.NewSource(1337)),
+		})
+		assert.NoError(t, err)
+		assert.Equal(t, expected.readonly, uow.Readonly, given)
+		assert.Equal(t, expected.accessMode, uow.AccessMode, given)
+	}
+
+	_, err := Parse("mode", ":mode follower\nRETURN 1;", 1)
+	assert.EqualError(t, err, ":mode needs to be read or write, like :mode read, got 'follower' (at mode:1:15)")
+}
+
+func TestNameAndDescription(t *testing.T) {
+	script, err := Parse("transfer.script", ":name account-transfer\n:description Moves money between two accounts\nRETURN 1;", 1)
+	assert.NoError(t, err)
+	assert.Equal(t, "account-transfer", script.Name)
+	assert.Equal(t, "transfer.script", script.Source)
+	assert.Equal(t, "Moves money between two accounts", script.Description)
+
+	uow, err := script.Eval(ScriptContext{
+		Vars: map[string]interface{}{},
+		Rand: rand.New(rand.NewSource(1337)),
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, "account-transfer", uow.ScriptName)
+
+	script, err = Parse("transfer.script", "RETURN 1;", 1)
+	assert.NoError(t, err)
+	assert.Equal(t, "transfer.script", script.Name)
+
+	_, err = Parse("name", ":name\nRETURN 1;", 1)
+	assert.EqualError(t, err, ":name needs a name for the script to go by in reports, like :name account-transfer (at name:2:1)")
+	_, err = Parse("name", "RETURN 1;\n:description  ", 1)
+	assert.EqualError(t, err, ":description needs to say what the script does, like :description Moves money between two accounts (at name:2:15)")
+	_, err = Parse("name", ":name builtin:tpcb-like\nRETURN 1;", 1)
+	assert.EqualError(t, err, ":name can't start with builtin:, which is for the built-in scripts, got builtin:tpcb-like (at name:2:1)")
+}
+
+func TestNamedScriptReadsFilesNextToIt(t *testing.T) {
+	dir, err := ioutil.TempDir("", "name")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+	assert.NoError(t, ioutil.WriteFile(filepath.Join(dir, "accounts.csv"), []byte("1,alice\n2,bob\n"), 0644))
+
+	script, err := Parse(filepath.Join(dir, "transfer.script"), `:name account-transfer
+:set accounts csv("accounts.csv")
+:set account sample("accounts.csv")
+RETURN $accounts, $account;`, 1)
+	assert.NoError(t, err)
+	uow, err := script.Eval(ScriptContext{
+		Script:    script,
+		Vars:      map[string]interface{}{},
+		Rand:      rand.New(rand.NewSource(1337)),
+		CsvLoader: NewCsvLoader(),
+	})
+	assert.NoError(t, err)
+	assert.Len(t, uow.Statements[0].Params["accounts"], 2)
+	assert.NotNil(t, uow.Statements[0].Params["account"])
+}
+
+func TestTimeout(t *testing.T) {
+	script, err := Parse("timeout", `:opt timeout 2s
+RETURN 1;
+:timeout 500ms
+RETURN 2;
+:autocommit
+:timeout 100ms
+RETURN 3;
+RETURN 4;`, 1)
+	assert.NoError(t, err)
+	assert.Equal(t, 2*time.Second, script.Timeout)
+
+	uow, err := script.Eval(ScriptContext{
+		Vars: map[string]interface{}{},
+		Rand: rand.New(rand.NewSource(1337)),
+	})
+	assert.NoError(t, err)
+	txs := uow.transactions()
+	assert.Len(t, txs, 3)
+	// The tightest timeout of its queries bounds the transaction
+	assert.Equal(t, 500*time.Millisecond, txs[0].timeout)
+	assert.Equal(t, 100*time.Millisecond, txs[1].timeout)
+	assert.True(t, txs[1].autocommit)
+	assert.Equal(t, 2*time.Second, txs[2].timeout)
+
+	for given, expected := range map[string]string{
+		":timeout soon\nRETURN 1;":             ":timeout needs a duration, like :timeout 500ms, got 'soon' (at timeout:2:1)",
+		":opt timeout 500us\nRETURN 1;":        ":opt timeout needs to be at least 1ms, got 500us (at timeout:2:1)",
+		"RETURN 1;\n:timeout 1s\n":             ":timeout needs a query after it, which it bounds the transaction of (at timeout:3:1)",
+		":timeout 1s\n:autocommit\n:set a 1\n": ":timeout needs a query after it, which it bounds the transaction of (at timeout:3:5)",
+	} {
+		_, err := Parse("timeout", given, 1)
+		assert.EqualError(t, err, expected, given)
+	}
+}
+
+func TestTransactionErrors(t *testing.T) {
+	for script, expected := range map[string]string{
+		":begin\n:begin\nRETURN 1;\n:commit": ":begin inside a transaction; transactions don't nest, end the one before with :commit or :rollback",
+		"RETURN 1;\n:commit":                 ":commit without a :begin before it",
+		":rollback":                          ":rollback without a :begin before it",
+		":begin\nRETURN 1;":                  ":begin without a :commit or :rollback to end the transaction it starts",
+	} {
+		parsed, err := Parse("transactions", script, 1)
+		assert.NoError(t, err, script)
+		_, err = parsed.Eval(ScriptContext{
+			Vars: map[string]interface{}{},
+			Rand: rand.New(rand.NewSource(1337)),
+		})
+		assert.EqualError(t, err, expected, script)
+	}
+
+	_, err := Parse("transactions", ":opt autocommit\n:begin\nRETURN 1;\n:commit", 1)
+	assert.EqualError(t, err, ":begin can't be used with :opt autocommit, which commits each query on its own (at transactions:2:7)")
+	_, err = Parse("transactions", ":begin\nRETURN 1;\n:commit\n:opt autocommit", 1)
+	assert.EqualError(t, err, ":opt autocommit can't be used with :begin, which runs queries in transactions (at transactions:4:16)")
+}
+
+func TestIf(t *testing.T) {
+	script, err := Parse("if", `:if $roll < 30
+RETURN "read";
+:elif $roll < 90
+:set id $roll * 10
+:if $id = 500
+RETURN "update", $id;
+:endif
+RETURN "create", $id;
+:else
+RETURN "delete";
+:endif
+RETURN "done";`, 1)
+	assert.NoError(t, err)
+
+	for roll, expected := range map[int64][]string{
+		10: {`RETURN "read"`, `RETURN "done"`},
+		50: {`RETURN "update", $id`, `RETURN "create", $id`, `RETURN "done"`},
+		60: {`RETURN "create", $id`, `RETURN "done"`},
+		95: {`RETURN "delete"`, `RETURN "done"`},
+	} {
+		uow, err := script.Eval(ScriptContext{
+			Vars: map[string]interface{}{"roll": roll},
+			Rand: rand.New(rand.NewSource(1337)),
+		})
+		assert.NoError(t, err)
+		queries := make([]string, 0, len(uow.Statements))
+		for _, stmt := range uow.Statements {
+			queries = append(queries, stmt.Query)
+		}
+		assert.Equal(t, expected, queries, roll)
+	}
+}
+
+func TestIfRunsEveryBranchInPreflight(t *testing.T) {
+	script, err := Parse("if", `:if false
+CREATE ();
+:else
+RETURN 1;
+:endif`, 1)
+	assert.NoError(t, err)
+
+	uow, err := script.Eval(ScriptContext{
+		PreflightMode: true,
+		Vars:          map[string]interface{}{},
+		Rand:          rand.New(rand.NewSource(1337)),
+	})
+	assert.NoError(t, err)
+	assert.Len(t, uow.Statements, 2)
+}
+
+func TestIfErrors(t *testing.T) {
+	for script, expected := range map[string]string{
+		":if true\nRETURN 1;":                    ":if without an :endif after it",
+		"RETURN 1;\n:endif":                      ":endif without an :if before it",
+		":if true\n:endfor\n:endif":              ":endfor before the :endif of the :if it's in",
+		":if true\n:else\n:elif false\n:endif":   ":elif after :else, :else needs to be the last branch of an :if",
+		":if true\n:else\n:else\n:endif":         ":else after :else, :else needs to be the last branch of an :if",
+		":if true\n:if false\n:endif\nRETURN 1;": ":if without an :endif after it",
+	} {
+		_, err := Parse("if", script, 1)
+		assert.Error(t, err, script)
+		if err != nil {
+			assert.Contains(t, err.Error(), expected, script)
+		}
+	}
+
+	script, err := Parse("if", ":if 1\nRETURN 1;\n:endif", 1)
+	assert.NoError(t, err)
+	_, err = script.Eval(ScriptContext{Vars: map[string]interface{}{}, Rand: rand.New(rand.NewSource(1337))})
+	assert.EqualError(t, err, ":if and :elif need a boolean condition, got 1 (which is int64)")
+}
+
+func TestFor(t *testing.T) {
+	script, err := Parse("for", `:set friends random(1, 4)
+:for i in range(1, $friends)
+:set other $i * 10
+MATCH (a {id: 1}), (b {id: $other}) CREATE (a)-[:KNOWS]->(b);
+:endfor
+:for name in ["a", "b"]
+:if $name = "b"
+RETURN $name;
+:endif
+:endfor`, 1)
+	assert.NoError(t, err)
+
+	uow, err := script.Eval(ScriptContext{
+		Vars: map[string]interface{}{},
+		Rand: rand.New(rand.NewSource(1337)),
+	})
+	assert.NoError(t, err)
+	others := make([]interface{}, 0)
+	for _, stmt := range uow.Statements[:len(uow.Statements)-1] {
+		others = append(others, stmt.Params["other"])
+	}
+	assert.Equal(t, []interface{}{int64(10), int64(20)}, others)
+	assert.Equal(t, map[string]interface{}{"name": "b"}, uow.Statements[len(uow.Statements)-1].Params)
+}
+
+func TestForRunsOnceInPreflight(t *testing.T) {
+	script, err := Parse("for", ":for i in []\nCREATE ({id: $i});\n:endfor", 1)
+	assert.NoError(t, err)
+
+	uow, err := script.Eval(ScriptContext{Vars: map[string]interface{}{}, Rand: rand.New(rand.NewSource(1337))})
+	assert.NoError(t, err)
+	assert.Len(t, uow.Statements, 0)
+
+	uow, err = script.Eval(ScriptContext{PreflightMode: true, Vars: map[string]interface{}{}, Rand: rand.New(rand.NewSource(1337))})
+	assert.NoError(t, err)
+	assert.Len(t, uow.Statements, 1)
+}
+
+func TestForErrors(t *testing.T) {
+	for script, expected := range map[string]string{
+		":for i in [1]\nRETURN 1;":          ":for without an :endfor after it",
+		"RETURN 1;\n:endfor":                ":endfor without a :for before it",
+		":for i in [1]\n:endif\n:endfor":    ":endif without an :if before it",
+		":for i of [1]\nRETURN 1;\n:endfor": ":for needs a list to loop over, like :for i in range(1, 10), got 'of' rather than 'in'",
+	} {
+		_, err := Parse("for", script, 1)
+		assert.Error(t, err, script)
+		if err != nil {
+			assert.Contains(t, err.Error(), expected, script)
+		}
+	}
+
+	script, err := Parse("for", ":for i in 3\nRETURN 1;\n:endfor", 1)
+	assert.NoError(t, err)
+	_, err = script.Eval(ScriptContext{Vars: map[string]interface{}{}, Rand: rand.New(rand.NewSource(1337))})
+	assert.EqualError(t, err, ":for needs a list to loop over, got 3 (which is int64)")
+}
+
+func TestInclude(t *testing.T) {
+	dir, err := ioutil.TempDir("", "include")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+	assert.NoError(t, os.MkdirAll(filepath.Join(dir, "common"), 0755))
+	assert.NoError(t, ioutil.WriteFile(filepath.Join(dir, "common", "setup.script"), []byte(`:set aid random(1, 100)
+:define account "MATCH (a:Account {aid: $aid})"
+:include keys.script
+`), 0644))
+	assert.NoError(t, ioutil.WriteFile(filepath.Join(dir, "common", "keys.script"), []byte(`:set key "k-" + $aid`), 0644))
+
+	script, err := Parse(filepath.Join(dir, "main.script"), `:include common/setup.script
+:include "common/keys.script"
+@account RETURN a, $key;`, 1)
+	assert.NoError(t, err)
+	uow, err := script.Eval(ScriptContext{Vars: map[string]interface{}{}, Rand: rand.New(rand.NewSource(1337))})
+	assert.NoError(t, err)
+	assert.Equal(t, []Statement{{
+		Query:   "MATCH (a:Account {aid: $aid}) RETURN a, $key",
+		Params:  map[string]interface{}{"aid": int64(56), "key": "k-56"},
+		Command: 0,
+	}}, uow.Statements)
+}
+
+func TestIncludeErrors(t *testing.T) {
+	dir, err := ioutil.TempDir("", "include")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+	assert.NoError(t, ioutil.WriteFile(filepath.Join(dir, "loop.script"), []byte(":include main.script\n"), 0644))
+	assert.NoError(t, ioutil.WriteFile(filepath.Join(dir, "open.script"), []byte(":if true\n"), 0644))
+
+	for script, expected := range map[string]string{
+		":include loop.script":    "in :include loop.script: :include of main.script includes itself",
+		":include open.script":    "in :include open.script: :if without an :endif after it",
+		":include missing.script": "failed to read missing.script in :include",
+		":include":                ":include needs the path of a script",
+	} {
+		_, err := Parse(filepath.Join(dir, "main.script"), script, 1)
+		assert.Error(t, err, script)
+		if err != nil {
+			assert.Contains(t, err.Error(), expected, script)
+		}
+	}
+}
+
+func TestParamsCommand(t *testing.T) {
+	dir, err := ioutil.TempDir("", "params")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+	assert.NoError(t, ioutil.WriteFile(filepath.Join(dir, "accounts.csv"), []byte("aid,name\n1,alice\n2,bob\n3,carol\n"), 0644))
+
+	ids := func(script Script, preflight bool, n int) []interface{} {
+		r := rand.New(rand.NewSource(1337))
+		out := make([]interface{}, 0, n)
+		for i := 0; i < n; i++ {
+			uow, err := script.Eval(ScriptContext{PreflightMode: preflight, Vars: map[string]interface{}{}, Rand: r})
+			assert.NoError(t, err)
+			out = append(out, uow.Statements[0].Params["aid"])
+		}
+		return out
+	}
+
+	sequential, err := Parse(filepath.Join(dir, "main.script"), ":params \"accounts.csv\"\nMATCH (a {aid: $aid, name: $name}) RETURN a;", 1)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{}, sequential.UndefinedParams(map[string]interface{}{}))
+	assert.Equal(t, []interface{}{int64(1), int64(1)}, ids(sequential, true, 2))
+	assert.Equal(t, []interface{}{int64(1), int64(2), int64(3), int64(1)}, ids(sequential, false, 4))
+
+	// Each run starts over from the first row, in :if and :for blocks too
+	nested, err := Parse(filepath.Join(dir, "main.script"), ":if true\n:for i in [1]\n:params \"accounts.csv\"\n:endfor\n:endif\nRETURN $aid;", 1)
+	assert.NoError(t, err)
+	wrk := Workload{Scripts: NewScripts(nested)}
+	assert.Equal(t, []interface{}{int64(1), int64(2)}, ids(wrk.RestartParams().Scripts.Scripts[0], false, 2))
+	assert.Equal(t, []interface{}{int64(1), int64(2)}, ids(wrk.RestartParams().Scripts.Scripts[0], false, 2))
+
+	random, err := Parse(filepath.Join(dir, "main.script"), ":params \"accounts.csv\" random\nMATCH (a {aid: $aid}) RETURN a;", 1)
+	assert.NoError(t, err)
+	assert.Equal(t, []interface{}{int64(2), int64(3), int64(3), int64(3)}, ids(random, false, 4))
+}
+
+func TestParamsCommandErrors(t *testing.T) {
+	dir, err := ioutil.TempDir("", "params")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+	assert.NoError(t, ioutil.WriteFile(filepath.Join(dir, "empty.csv"), []byte("aid\n"), 0644))
+
+	for script, expected := range map[string]string{
+		":params accounts.csv":        ":params needs a quoted path to a CSV file, like :params \"ids.csv\", got 'accounts'",
+		":params \"empty.csv\"":       "needs a header row naming the columns and at least one row of parameters",
+		":params \"empty.csv\" often": ":params command must use 'sequential' or 'random' row selection - or none. got: often",
+		":params \"missing.csv\"":     "failed to read csv",
+	} {
+		_, err := Parse(filepath.Join(dir, "main.script"), script, 1)
+		assert.Error(t, err, script)
+		if err != nil {
+			assert.Contains(t, err.Error(), expected, script)
+		}
+	}
 }
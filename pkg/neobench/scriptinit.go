@@ -0,0 +1,96 @@
+package neobench
+
+import (
+	"fmt"
+	"math/rand"
+	"os"
+
+	"github.com/neo4j/neo4j-go-driver/v4/neo4j"
+	"github.com/pkg/errors"
+)
+
+// Runs the :init block of the script, if it has one, as -i asks for: once, with the variables of the workload and
+// a random source of its own, so the benchmark draws the same numbers with and without -i. Each query is a
+// transaction of its own, unless the block groups them with :begin and :commit, and runs once the one before it
+// has committed, so the schema can be created before the data that needs it.
+func RunScriptInit(driver neo4j.Driver, dbName string, wrk Workload, script Script, seed int64, out Output) error {
+	if script.Init == nil {
+		return nil
+	}
+	init := *script.Init
+	if !init.Autocommit {
+		init.ExplicitTransactions = true
+	}
+	uow, err := init.Eval(ScriptContext{
+		StrictParams: wrk.StrictParams,
+		Script:       init,
+		Stderr:       os.Stderr,
+		Vars:         createVars(wrk.Variables, 0),
+		Rand:         rand.New(rand.NewSource(seed)),
+		CsvLoader:    wrk.CsvLoader,
+		Sequences:    wrk.Sequences,
+	})
+	if err != nil {
+		return errors.Wrapf(err, "failed to evaluate the :init of %s", script.Name)
+	}
+
+	session := driver.NewSession(neo4j.SessionConfig{
+		AccessMode:   neo4j.AccessModeWrite,
+		DatabaseName: dbName,
+	})
+	defer session.Close()
+
+	transactions := uow.transactions()
+	for i, transaction := range transactions {
+		out.ReportInitProgress(ProgressReport{
+			Section:      "init",
+			Step:         fmt.Sprintf("%s: transaction %d of %d", script.Name, i+1, len(transactions)),
+			Completeness: float64(i) / float64(len(transactions)),
+		})
+		if err := runInitTransaction(session, transaction); err != nil {
+			return errors.Wrapf(err, "failed to run the :init of %s", script.Name)
+		}
+	}
+	out.ReportInitProgress(ProgressReport{
+		Section:      "init",
+		Step:         fmt.Sprintf("%s: done", script.Name),
+		Completeness: 1,
+	})
+	return nil
+}
+
+func runInitTransaction(session neo4j.Session, transaction unitTransaction) error {
+	var configure []func(*neo4j.TransactionConfig)
+	if transaction.timeout > 0 {
+		configure = append(configure, neo4j.WithTxTimeout(transaction.timeout))
+	}
+	if transaction.autocommit {
+		s := transaction.statements[0]
+		res, err := session.Run(s.Query, s.Params, configure...)
+		if err != nil {
+			return err
+		}
+		_, err = res.Consume()
+		return err
+	}
+	// Errors are passed on as they are, so the driver retries the transient ones
+	_, err := session.WriteTransaction(func(tx neo4j.Transaction) (interface{}, error) {
+		for _, s := range transaction.statements {
+			res, err := tx.Run(s.Query, s.Params)
+			if err != nil {
+				return nil, err
+			}
+			if _, err := res.Consume(); err != nil {
+				return nil, err
+			}
+		}
+		if transaction.rolledBack {
+			return nil, errRollback
+		}
+		return nil, nil
+	}, configure...)
+	if err == errRollback {
+		return nil
+	}
+	return err
+}
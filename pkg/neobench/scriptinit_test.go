@@ -0,0 +1,98 @@
+package neobench
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/neo4j/neo4j-go-driver/v4/neo4j"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestInitIsNotPartOfTheScript(t *testing.T) {
+	script, err := Parse("init", `:init
+CREATE CONSTRAINT ON (a:Account) ASSERT a.aid IS UNIQUE;
+:for batch in range(0, 1)
+UNWIND range($batch * 10, $batch * 10 + 9) AS aid CREATE (:Account {aid: aid, balance: 0});
+:endfor
+:endinit
+:set aid random(1, 20)
+MATCH (a:Account {aid: $aid}) RETURN a.balance;`, 1)
+	assert.NoError(t, err)
+	assert.Len(t, script.Commands, 2)
+	assert.NotNil(t, script.Init)
+	assert.Len(t, script.Init.Commands, 2)
+	assert.Equal(t, "init", script.Init.Name)
+}
+
+func TestInitErrors(t *testing.T) {
+	for script, expected := range map[string]string{
+		":init\nCREATE (a);":                           ":init without an :endinit after it (at init:2:12)",
+		":endinit":                                     ":endinit without an :init before it (at init:1:9)",
+		":init\n:endinit\n:init\n:endinit":             ":init can only be given once in a script, put everything it does in the one :init (at init:3:6)",
+		":if true\n:init\n:endinit\n:endif":            ":init can't be inside an :if, a :for or another :init, only in the script around them (at init:2:6)",
+		":init\n:init\n:endinit\n:endinit":             ":init can't be inside an :if, a :for or another :init, only in the script around them (at init:2:6)",
+		":init\n:if true\nCREATE (a);\n:endinit":       ":endinit before the :endif of the :if it's in (at init:4:9)",
+		":init\nRETURN 1 AS aid;\n:gset aid\n:endinit": ":gset can't be inside an :init, which is evaluated before any of it runs (at init:3:6)",
+	} {
+		_, err := Parse("init", script, 1)
+		assert.EqualError(t, err, expected, script)
+	}
+}
+
+func TestRunScriptInit(t *testing.T) {
+	for _, tc := range []struct {
+		script   string
+		expected []string
+	}{
+		// Without :begin, each query is a transaction of its own, so the schema is in place before the data
+		{":init\nCREATE INDEX FOR (a:Account) ON (a.aid);\nCREATE (:Account {aid: 1});\n:endinit\nRETURN 1;",
+			[]string{"CREATE INDEX FOR (a:Account) ON (a.aid)", "commit", "CREATE (:Account {aid: 1})", "commit"}},
+		{":init\n:begin\nCREATE (a);\nCREATE (b);\n:commit\n:endinit\nRETURN 1;",
+			[]string{"CREATE (a)", "CREATE (b)", "commit"}},
+		{":init\n:autocommit\nCREATE (a);\nCREATE (b);\n:endinit\nRETURN 1;",
+			[]string{"auto-commit CREATE (a)", "CREATE (b)", "commit"}},
+		{":init\n:opt autocommit\nCREATE (a);\nCREATE (b);\n:endinit\nRETURN 1;",
+			[]string{"auto-commit CREATE (a)", "auto-commit CREATE (b)"}},
+		{":init\n:begin\nCREATE (a);\n:rollback\n:endinit\nRETURN 1;",
+			[]string{"CREATE (a)", "rollback"}},
+		{"RETURN 1;", nil},
+	} {
+		script, err := Parse("init", tc.script, 1)
+		assert.NoError(t, err, tc.script)
+		driver := &recordingDriver{}
+		out := &InteractiveOutput{ErrStream: &strings.Builder{}, OutStream: &strings.Builder{}}
+
+		err = RunScriptInit(driver, "neo4j", Workload{Variables: map[string]interface{}{"scale": int64(1)}}, script, 1337, out)
+		assert.NoError(t, err, tc.script)
+		assert.Equal(t, tc.expected, driver.log, tc.script)
+	}
+}
+
+func TestRunScriptInitUsesTheWorkloadVariables(t *testing.T) {
+	script, err := Parse("init", ":init\n:set accounts 100000 * $scale\n:timeout 1m\nUNWIND range(1, $accounts) AS aid CREATE (:Account {aid: aid});\n:endinit\nRETURN 1;", 1)
+	assert.NoError(t, err)
+	driver := &recordingDriver{}
+	out := &InteractiveOutput{ErrStream: &strings.Builder{}, OutStream: &strings.Builder{}}
+
+	err = RunScriptInit(driver, "neo4j", Workload{Variables: map[string]interface{}{"scale": int64(2)}}, script, 1337, out)
+	assert.NoError(t, err)
+	assert.Equal(t, []neo4j.AccessMode{neo4j.AccessModeWrite}, driver.modes)
+	assert.Equal(t, []time.Duration{time.Minute}, driver.timeouts)
+	assert.Contains(t, out.ErrStream.(*strings.Builder).String(), "[init][init: transaction 1 of 1] 0.00%")
+
+	err = RunScriptInit(driver, "neo4j", Workload{Variables: map[string]interface{}{}}, script, 1337, out)
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), "failed to evaluate the :init of init")
+	assert.Contains(t, err.Error(), "this variable is not defined: scale")
+}
+
+func TestRunScriptInitFails(t *testing.T) {
+	script, err := Parse("init", ":init\nCREATE (a);\n:endinit\nRETURN 1;", 1)
+	assert.NoError(t, err)
+	driver := &recordingDriver{deadlocks: 1}
+	out := &InteractiveOutput{ErrStream: &strings.Builder{}, OutStream: &strings.Builder{}}
+
+	err = RunScriptInit(driver, "neo4j", Workload{Variables: map[string]interface{}{}}, script, 1337, out)
+	assert.EqualError(t, err, "failed to run the :init of init: Neo4jError: Neo.TransientError.Transaction.DeadlockDetected (deadlock)")
+}
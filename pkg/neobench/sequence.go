@@ -0,0 +1,35 @@
+package neobench
+
+import (
+	"sync"
+	"sync/atomic"
+)
+
+// Counters shared by all clients of a workload, see sequence(..) in scripts; each hands out every integer from
+// where it starts, once, in increasing order, so it can key inserts without two clients writing the same key.
+// They carry on across a warmup and the runs after it, rather than starting over, so keys stay unique.
+type Sequences struct {
+	mut      sync.RWMutex
+	counters map[string]*int64
+}
+
+func NewSequences() *Sequences {
+	return &Sequences{counters: make(map[string]*int64)}
+}
+
+// The next value of the named sequence; start is the first value it gives, and is ignored once the sequence has one
+func (s *Sequences) Next(name string, start int64) int64 {
+	s.mut.RLock()
+	counter, found := s.counters[name]
+	s.mut.RUnlock()
+	if !found {
+		s.mut.Lock()
+		if counter, found = s.counters[name]; !found {
+			counter = new(int64)
+			*counter = start - 1
+			s.counters[name] = counter
+		}
+		s.mut.Unlock()
+	}
+	return atomic.AddInt64(counter, 1)
+}
@@ -0,0 +1,52 @@
+package neobench
+
+import (
+	"math/rand"
+	"sort"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSequencesAreDenseAndUniqueAcrossClients(t *testing.T) {
+	script, err := Parse("sequence", ":set id sequence(\"account\")\n:set order sequence(\"order\", 1000)\nCREATE (:Account {id: $id, order: $order});", 1)
+	assert.NoError(t, err)
+	wrk := Workload{
+		Scripts:   NewScripts(script),
+		Rand:      rand.New(rand.NewSource(1337)),
+		Sequences: NewSequences(),
+	}
+
+	// Checking the script doesn't use up a value
+	uow, err := script.Eval(ScriptContext{PreflightMode: true, Vars: map[string]interface{}{}, Rand: wrk.Rand})
+	assert.NoError(t, err)
+	assert.Equal(t, int64(1), uow.Statements[0].Params["id"])
+
+	var mut sync.Mutex
+	var wg sync.WaitGroup
+	ids, orders := make([]int, 0), make([]int, 0)
+	for c := 0; c < 4; c++ {
+		client := wrk.NewClient()
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := 0; i < 250; i++ {
+				uow, err := client.Next(0)
+				assert.NoError(t, err)
+				mut.Lock()
+				ids = append(ids, int(uow.Statements[0].Params["id"].(int64)))
+				orders = append(orders, int(uow.Statements[0].Params["order"].(int64)))
+				mut.Unlock()
+			}
+		}()
+	}
+	wg.Wait()
+
+	sort.Ints(ids)
+	sort.Ints(orders)
+	for i := range ids {
+		assert.Equal(t, i+1, ids[i])
+		assert.Equal(t, i+1000, orders[i])
+	}
+}
@@ -0,0 +1,306 @@
+package neobench
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/neo4j/neo4j-go-driver/v4/neo4j"
+	"github.com/pkg/errors"
+)
+
+// One sample of server-side metrics; metrics the server didn't expose are set to -1
+type ServerMetricsSample struct {
+	Time               time.Time
+	HeapUsedBytes      int64
+	PageCacheHitRatio  float64
+	ActiveTransactions int64
+	// Collections and time spent in them, summed over the garbage collectors, since the server started
+	GcCollections int64
+	GcTimeMillis  int64
+	// Checkpoints and time spent in them, summed over the databases, since the server started; these come from
+	// the server's own metrics, which are only on JMX with metrics.jmx.enabled in Neo4j Enterprise
+	Checkpoints          int64
+	CheckpointTimeMillis int64
+}
+
+// Periodically samples metrics from the Neo4j server over JMX while a benchmark runs, so latency spikes
+// can be correlated with things like GC pauses, checkpoints or page cache misses.
+type ServerMetricsCollector struct {
+	driver   neo4j.Driver
+	interval time.Duration
+	now      func() time.Time
+
+	mut     sync.Mutex
+	samples []ServerMetricsSample
+	// Set if sampling failed; we stop sampling at that point, since it likely means the server
+	// doesn't allow it, and keep whatever samples we got
+	err error
+}
+
+func NewServerMetricsCollector(driver neo4j.Driver, interval time.Duration) *ServerMetricsCollector {
+	return &ServerMetricsCollector{
+		driver:   driver,
+		interval: interval,
+		now:      time.Now,
+	}
+}
+
+// Samples at the configured interval until stopCh is closed or sampling fails
+func (c *ServerMetricsCollector) Run(stopCh <-chan struct{}) {
+	session := c.driver.NewSession(neo4j.SessionConfig{AccessMode: neo4j.AccessModeRead})
+	defer session.Close()
+
+	ticker := time.NewTicker(c.interval)
+	defer ticker.Stop()
+	for {
+		sample, err := c.sample(session)
+		c.mut.Lock()
+		if err != nil {
+			c.err = err
+			c.mut.Unlock()
+			return
+		}
+		c.samples = append(c.samples, sample)
+		c.mut.Unlock()
+
+		select {
+		case <-stopCh:
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// Samples collected so far, and the error that stopped sampling, if any
+func (c *ServerMetricsCollector) Samples() ([]ServerMetricsSample, error) {
+	c.mut.Lock()
+	defer c.mut.Unlock()
+	out := make([]ServerMetricsSample, len(c.samples))
+	copy(out, c.samples)
+	return out, c.err
+}
+
+func (c *ServerMetricsCollector) sample(session neo4j.Session) (ServerMetricsSample, error) {
+	sample := ServerMetricsSample{
+		Time:                 c.now(),
+		HeapUsedBytes:        -1,
+		PageCacheHitRatio:    -1,
+		ActiveTransactions:   -1,
+		GcCollections:        -1,
+		GcTimeMillis:         -1,
+		Checkpoints:          -1,
+		CheckpointTimeMillis: -1,
+	}
+
+	beans, err := queryJmx(session, "java.lang:type=Memory")
+	if err != nil {
+		return sample, err
+	}
+	for _, attrs := range beans {
+		if used, ok := jmxAttribute(attrs, "HeapMemoryUsage", "used").(int64); ok {
+			sample.HeapUsedBytes = used
+		}
+	}
+
+	beans, err = queryJmx(session, "org.neo4j:name=Page cache,*")
+	if err != nil {
+		return sample, err
+	}
+	for _, attrs := range beans {
+		if ratio, ok := jmxAttribute(attrs, "HitRatio").(float64); ok {
+			sample.PageCacheHitRatio = ratio
+		}
+	}
+
+	// One bean per database, so we sum them up
+	beans, err = queryJmx(session, "org.neo4j:name=Transactions,*")
+	if err != nil {
+		return sample, err
+	}
+	for _, attrs := range beans {
+		if open, ok := jmxAttribute(attrs, "NumberOfOpenTransactions").(int64); ok {
+			if sample.ActiveTransactions < 0 {
+				sample.ActiveTransactions = 0
+			}
+			sample.ActiveTransactions += open
+		}
+	}
+
+	// One bean per collector, eg. for the young and old generations
+	beans, err = queryJmx(session, "java.lang:type=GarbageCollector,*")
+	if err != nil {
+		return sample, err
+	}
+	for _, attrs := range beans {
+		if count, ok := jmxAttribute(attrs, "CollectionCount").(int64); ok {
+			sample.GcCollections = addAvailable(sample.GcCollections, count)
+		}
+		if millis, ok := jmxAttribute(attrs, "CollectionTime").(int64); ok {
+			sample.GcTimeMillis = addAvailable(sample.GcTimeMillis, millis)
+		}
+	}
+
+	// Metric names have the database in them, eg. neo4j.neo4j.check_point.events
+	beans, err = queryJmx(session, "neo4j.metrics:name=*.check_point.events")
+	if err != nil {
+		return sample, err
+	}
+	for _, attrs := range beans {
+		if count, ok := jmxMetricValue(attrs); ok {
+			sample.Checkpoints = addAvailable(sample.Checkpoints, count)
+		}
+	}
+	beans, err = queryJmx(session, "neo4j.metrics:name=*.check_point.total_time")
+	if err != nil {
+		return sample, err
+	}
+	for _, attrs := range beans {
+		if millis, ok := jmxMetricValue(attrs); ok {
+			sample.CheckpointTimeMillis = addAvailable(sample.CheckpointTimeMillis, millis)
+		}
+	}
+
+	return sample, nil
+}
+
+// Adds v to a metric that is -1 until something reports it
+func addAvailable(metric, v int64) int64 {
+	if metric < 0 {
+		return v
+	}
+	return metric + v
+}
+
+// The server's metrics are on JMX as counters, with a Count attribute, or as gauges, with a Value attribute,
+// depending on the metric and the Neo4j version
+func jmxMetricValue(attributes map[string]interface{}) (int64, bool) {
+	for _, name := range []string{"Count", "Value"} {
+		if v, ok := jmxAttribute(attributes, name).(int64); ok {
+			return v, true
+		}
+	}
+	return 0, false
+}
+
+// Returns the attribute maps of all JMX beans matching the given name pattern
+func queryJmx(session neo4j.Session, pattern string) ([]map[string]interface{}, error) {
+	res, err := session.Run("CALL dbms.queryJmx($pattern) YIELD attributes RETURN attributes",
+		map[string]interface{}{"pattern": pattern})
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to query JMX for %s", pattern)
+	}
+	records, err := res.Collect()
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to query JMX for %s", pattern)
+	}
+	out := make([]map[string]interface{}, 0, len(records))
+	for _, record := range records {
+		if attrs, ok := record.Values[0].(map[string]interface{}); ok {
+			out = append(out, attrs)
+		}
+	}
+	return out, nil
+}
+
+// dbms.queryJmx gives attributes as {<name>: {description: .., value: <value>}}, where composite values
+// are themselves {description: .., properties: {<key>: <value>}}. This digs out the value at the given path,
+// or returns nil if it's not there.
+func jmxAttribute(attributes map[string]interface{}, name string, compositeKeys ...string) interface{} {
+	attr, ok := attributes[name].(map[string]interface{})
+	if !ok {
+		return nil
+	}
+	value := attr["value"]
+	for _, key := range compositeKeys {
+		composite, ok := value.(map[string]interface{})
+		if !ok {
+			return nil
+		}
+		properties, ok := composite["properties"].(map[string]interface{})
+		if !ok {
+			return nil
+		}
+		value = properties[key]
+	}
+	return value
+}
+
+// Describes a series of samples as min/mean/max per metric, skipping metrics that were never available. GC and
+// checkpoints are counted from the start of the server, so they are summarized by how much each went up
+// between samples, and in total over the run.
+func SummarizeServerMetrics(samples []ServerMetricsSample) string {
+	s := strings.Builder{}
+	heap, pageCache, txs := &stat{}, &stat{}, &stat{}
+	gcTime, checkpoints, checkpointTime := &stat{}, &stat{}, &stat{}
+	for i, sample := range samples {
+		if i > 0 {
+			prev := samples[i-1]
+			if d, ok := serverMetricDelta(prev.GcTimeMillis, sample.GcTimeMillis); ok {
+				gcTime.add(float64(d))
+			}
+			if d, ok := serverMetricDelta(prev.Checkpoints, sample.Checkpoints); ok {
+				checkpoints.add(float64(d))
+			}
+			if d, ok := serverMetricDelta(prev.CheckpointTimeMillis, sample.CheckpointTimeMillis); ok {
+				checkpointTime.add(float64(d))
+			}
+		}
+		if sample.HeapUsedBytes >= 0 {
+			heap.add(float64(sample.HeapUsedBytes) / 1024 / 1024)
+		}
+		if sample.PageCacheHitRatio >= 0 {
+			pageCache.add(sample.PageCacheHitRatio * 100)
+		}
+		if sample.ActiveTransactions >= 0 {
+			txs.add(float64(sample.ActiveTransactions))
+		}
+	}
+	heap.write(&s, "Heap used (MB)")
+	pageCache.write(&s, "Page cache hit ratio (%)")
+	txs.write(&s, "Open transactions")
+	gcTime.writeWithTotal(&s, "GC time per sample interval (ms)")
+	checkpoints.writeWithTotal(&s, "Checkpoints per sample interval")
+	checkpointTime.writeWithTotal(&s, "Checkpoint time per sample interval (ms)")
+	return s.String()
+}
+
+// How much a counter of the server went up between two samples; not ok if either sample doesn't have it
+func serverMetricDelta(prev, next int64) (int64, bool) {
+	if prev < 0 || next < 0 {
+		return 0, false
+	}
+	return next - prev, true
+}
+
+type stat struct {
+	n, sum, min, max float64
+}
+
+func (st *stat) add(v float64) {
+	if st.n == 0 || v < st.min {
+		st.min = v
+	}
+	if st.n == 0 || v > st.max {
+		st.max = v
+	}
+	st.n++
+	st.sum += v
+}
+
+func (st *stat) write(s *strings.Builder, name string) {
+	if st.n == 0 {
+		s.WriteString(fmt.Sprintf("  %s: not available\n", name))
+		return
+	}
+	s.WriteString(fmt.Sprintf("  %s: min %.2f, mean %.2f, max %.2f\n", name, st.min, st.sum/st.n, st.max))
+}
+
+func (st *stat) writeWithTotal(s *strings.Builder, name string) {
+	if st.n == 0 {
+		st.write(s, name)
+		return
+	}
+	s.WriteString(fmt.Sprintf("  %s: min %.2f, mean %.2f, max %.2f, total %.2f\n", name, st.min, st.sum/st.n, st.max, st.sum))
+}
@@ -0,0 +1,60 @@
+package neobench
+
+import (
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestJmxAttribute(t *testing.T) {
+	attributes := map[string]interface{}{
+		"HitRatio": map[string]interface{}{
+			"description": "Ratio of hits to total number of lookups",
+			"value":       0.98,
+		},
+		"HeapMemoryUsage": map[string]interface{}{
+			"description": "Heap memory usage",
+			"value": map[string]interface{}{
+				"description": "java.lang.management.MemoryUsage",
+				"properties": map[string]interface{}{
+					"used": int64(1337),
+				},
+			},
+		},
+	}
+
+	assert.Equal(t, 0.98, jmxAttribute(attributes, "HitRatio"))
+	assert.Equal(t, int64(1337), jmxAttribute(attributes, "HeapMemoryUsage", "used"))
+	assert.Nil(t, jmxAttribute(attributes, "HeapMemoryUsage", "max"))
+	assert.Nil(t, jmxAttribute(attributes, "NoSuchAttribute"))
+	assert.Nil(t, jmxAttribute(attributes, "HitRatio", "used"))
+}
+
+func TestSummarizeServerMetrics(t *testing.T) {
+	summary := SummarizeServerMetrics([]ServerMetricsSample{
+		{HeapUsedBytes: 1024 * 1024, PageCacheHitRatio: 0.5, ActiveTransactions: -1, GcTimeMillis: 100, Checkpoints: 4, CheckpointTimeMillis: -1},
+		{HeapUsedBytes: 3 * 1024 * 1024, PageCacheHitRatio: 1, ActiveTransactions: -1, GcTimeMillis: 100, Checkpoints: 4, CheckpointTimeMillis: -1},
+		{HeapUsedBytes: 2 * 1024 * 1024, PageCacheHitRatio: 0.75, ActiveTransactions: -1, GcTimeMillis: 160, Checkpoints: 5, CheckpointTimeMillis: -1},
+	})
+
+	assert.Equal(t, `  Heap used (MB): min 1.00, mean 2.00, max 3.00
+  Page cache hit ratio (%): min 50.00, mean 75.00, max 100.00
+  Open transactions: not available
+  GC time per sample interval (ms): min 0.00, mean 30.00, max 60.00, total 60.00
+  Checkpoints per sample interval: min 0.00, mean 0.50, max 1.00, total 1.00
+  Checkpoint time per sample interval (ms): not available
+`, summary)
+}
+
+func TestJmxMetricValueReadsCountersAndGauges(t *testing.T) {
+	counter := map[string]interface{}{"Count": map[string]interface{}{"value": int64(3)}}
+	gauge := map[string]interface{}{"Value": map[string]interface{}{"value": int64(250)}}
+
+	v, ok := jmxMetricValue(counter)
+	assert.True(t, ok)
+	assert.Equal(t, int64(3), v)
+	v, ok = jmxMetricValue(gauge)
+	assert.True(t, ok)
+	assert.Equal(t, int64(250), v)
+	_, ok = jmxMetricValue(map[string]interface{}{})
+	assert.False(t, ok)
+}
@@ -0,0 +1,12 @@
+//go:build !windows
+// +build !windows
+
+package neobench
+
+import "os/exec"
+
+// Runs the line of a :shell or :setshell with sh, so it can use pipes, quotes and the like; sh reads the
+// variables the line uses from its environment
+func shellCommand(line string) *exec.Cmd {
+	return exec.Command("sh", "-c", line)
+}
@@ -0,0 +1,80 @@
+//go:build !windows
+// +build !windows
+
+package neobench
+
+import (
+	"bytes"
+	"math/rand"
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestShell(t *testing.T) {
+	script, err := Parse("shell", `:set n 20
+:shell echo "generating $n" >&2
+:setshell total echo $(( $n + 1 ))
+:setshell name printf '  alice\n'
+RETURN $total, $name;`, 1)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{}, script.UndefinedParams(map[string]interface{}{}))
+
+	stderr := bytes.NewBuffer(nil)
+	uow, err := script.Eval(ScriptContext{Stderr: stderr, Vars: map[string]interface{}{}, Rand: rand.New(rand.NewSource(1337))})
+	assert.NoError(t, err)
+	assert.Equal(t, "generating 20\n", stderr.String())
+	assert.Equal(t, map[string]interface{}{"total": int64(21), "name": "alice"}, uow.Statements[0].Params)
+
+	// Checking the script doesn't run anything
+	uow, err = script.Eval(ScriptContext{PreflightMode: true, Stderr: stderr, Vars: map[string]interface{}{}, Rand: rand.New(rand.NewSource(1337))})
+	assert.NoError(t, err)
+	assert.Equal(t, "generating 20\n", stderr.String())
+	assert.Equal(t, map[string]interface{}{"total": nil, "name": nil}, uow.Statements[0].Params)
+}
+
+func TestShellPassesVariablesInTheEnvironment(t *testing.T) {
+	assert.NoError(t, os.Setenv("NEOBENCH_SHELL_TEST", "from the environment"))
+	defer os.Unsetenv("NEOBENCH_SHELL_TEST")
+	script, err := Parse("shell", `:set v "a; exit 3"
+:setshell quoted echo "$v"
+:setshell braced echo "${v}!"
+:setshell env echo $NEOBENCH_SHELL_TEST
+RETURN $quoted, $braced, $env;`, 1)
+	assert.NoError(t, err)
+
+	uow, err := script.Eval(ScriptContext{Vars: map[string]interface{}{}, Rand: rand.New(rand.NewSource(1337))})
+	assert.NoError(t, err)
+	assert.Equal(t, map[string]interface{}{
+		"quoted": "a; exit 3",
+		"braced": "a; exit 3!",
+		"env":    "from the environment",
+	}, uow.Statements[0].Params)
+}
+
+func TestShellErrors(t *testing.T) {
+	for script, expected := range map[string]string{
+		":shell exit 3\nRETURN 1;":      ":shell exit 3 failed: exit status 3",
+		":setshell v false\nRETURN $v;": ":setshell v false failed: exit status 1",
+	} {
+		parsed, err := Parse("shell", script, 1)
+		assert.NoError(t, err, script)
+		_, err = parsed.Eval(ScriptContext{Vars: map[string]interface{}{}, Rand: rand.New(rand.NewSource(1337))})
+		assert.EqualError(t, err, expected, script)
+	}
+
+	_, err := Parse("shell", ":shell\nRETURN 1;", 1)
+	assert.EqualError(t, err, ":shell needs a command to run, like :shell echo hello (at shell:2:1)")
+}
+
+func TestShellKeepsSlashes(t *testing.T) {
+	script, err := Parse("shell", `:name fetch // list
+:description Fetches http://localhost:7474/db first
+:shell curl -s http://localhost:7474/db/neo4j
+RETURN 1;`, 1)
+	assert.NoError(t, err)
+	assert.Equal(t, "fetch // list", script.Name)
+	assert.Equal(t, "Fetches http://localhost:7474/db first", script.Description)
+	assert.Equal(t, ShellCommand{Line: "curl -s http://localhost:7474/db/neo4j"}, script.Commands[0])
+}
@@ -0,0 +1,9 @@
+package neobench
+
+import "os/exec"
+
+// Runs the line of a :shell or :setshell with cmd, which is what Windows has in place of sh. cmd doesn't read
+// $var, so each is rewritten to !var!, which /V:ON expands once the line is parsed, so the value can't add to it
+func shellCommand(line string) *exec.Cmd {
+	return exec.Command("cmd", "/V:ON", "/C", shellVarPattern.ReplaceAllString(line, "!${1}${2}!"))
+}
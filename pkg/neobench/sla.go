@@ -0,0 +1,225 @@
+package neobench
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Limits a run needs to stay within to pass, set on the command line; the zero value has no limits
+type SLA struct {
+	// Limit on the p99 time transactions waited for a connection, see --max-acquire-p99
+	MaxAcquireP99 time.Duration
+	// Results to stay within BaselineTolerance of, see --baseline
+	Baseline *Baseline
+	// Fraction the results may regress by compared to Baseline, eg. 0.1 for 10%, see --baseline-tolerance
+	BaselineTolerance float64
+	// See --assert
+	Assertions []Assertion
+}
+
+// One limit set in an SLA, and whether a result stays within it
+type SLACheck struct {
+	// The flag that sets the limit, eg. "max-acquire-p99", followed by what it covers, if the flag sets several
+	// limits, eg. "baseline-tpcb-like-p99"
+	Name string
+	// Describes how the result breaks the limit; empty if it doesn't
+	Breach string
+}
+
+// Checks the result against each limit that is set
+func (s SLA) Check(result Result) []SLACheck {
+	checks := make([]SLACheck, 0)
+	if s.MaxAcquireP99 > 0 {
+		check := SLACheck{Name: "max-acquire-p99"}
+		if result.AcquireLatencies != nil && result.AcquireLatencies.TotalCount() > 0 {
+			p99 := time.Duration(result.AcquireLatencies.ValueAtQuantile(99)) * time.Microsecond
+			if p99 > s.MaxAcquireP99 {
+				check.Breach = fmt.Sprintf("connection acquisition p99 was %s, above --max-acquire-p99 %s; "+
+					"the connection pool is likely too small for the number of clients", p99, s.MaxAcquireP99)
+			}
+		}
+		checks = append(checks, check)
+	}
+	if s.Baseline != nil {
+		checks = append(checks, s.Baseline.check(result, s.BaselineTolerance)...)
+	}
+	for _, a := range s.Assertions {
+		checks = append(checks, SLACheck{Name: "assert-" + a.Raw, Breach: a.check(result)})
+	}
+	return checks
+}
+
+// If an assertion sets how many transactions of a script may fail, or of the whole run, failures within that
+// don't fail the run by themselves; the result only passes if each script that had failures has such a limit
+func (s SLA) AllowsFailures(result Result) bool {
+	for name, script := range result.Scripts {
+		if script.Failed == 0 {
+			continue
+		}
+		allowed := false
+		for _, a := range s.Assertions {
+			if (a.Metric == "error-rate" || a.Metric == "failed") && (a.Script == "" || a.Script == name) {
+				allowed = true
+			}
+		}
+		if !allowed {
+			return false
+		}
+	}
+	return true
+}
+
+// Describes each limit the result breaks; empty if it breaks none
+func (s SLA) Breaches(result Result) []string {
+	breaches := make([]string, 0)
+	for _, check := range s.Check(result) {
+		if check.Breach != "" {
+			breaches = append(breaches, check.Breach)
+		}
+	}
+	return breaches
+}
+
+// A limit on a metric of the final result, see --assert, eg. p99<20ms
+type Assertion struct {
+	// As given on the command line
+	Raw string
+	// The script the assertion checks, as in --assert builtin:tpcb-like:p99<20ms; empty for the whole run
+	Script string
+	// "tps", "error-rate", "failed", "mean", "max" or a latency percentile, like "p99" or "p99.9"
+	Metric string
+	// "<", "<=", ">" or ">="
+	Operator string
+	// Milliseconds for latencies, a fraction for error-rate
+	Value float64
+	// Set for percentile metrics
+	percentile float64
+}
+
+var assertionOperators = []string{"<=", ">=", "<", ">"}
+
+// Parses --assert values, a metric, an operator and a value, ex: p99<20ms, tps>5000, error-rate<0.1%
+func ParseAssertion(raw string) (Assertion, error) {
+	a := Assertion{Raw: strings.Replace(raw, " ", "", -1)}
+	at := -1
+	for _, op := range assertionOperators {
+		if i := strings.Index(a.Raw, op); i > 0 && (at == -1 || i < at) {
+			at, a.Operator = i, op
+		}
+	}
+	if at == -1 {
+		return a, fmt.Errorf("--assert should be a metric, one of <, <=, > or >=, and a value, ex: p99<20ms, got %s", raw)
+	}
+	a.Metric = a.Raw[:at]
+	value := a.Raw[at+len(a.Operator):]
+	if i := strings.LastIndex(a.Metric, ":"); i >= 0 {
+		// Script names, like builtin:tpcb-like, can have colons of their own, metrics can't
+		a.Script, a.Metric = a.Metric[:i], a.Metric[i+1:]
+		if a.Script == "" {
+			return a, fmt.Errorf("--assert needs a script name before the ':', like builtin:tpcb-like:p99<20ms, got %s", raw)
+		}
+	}
+
+	var err error
+	switch {
+	case a.Metric == "tps" || a.Metric == "failed":
+		a.Value, err = strconv.ParseFloat(value, 64)
+	case a.Metric == "error-rate":
+		a.Value, err = parseFraction(value)
+	case a.Metric == "mean" || a.Metric == "max" || strings.HasPrefix(a.Metric, "p"):
+		if strings.HasPrefix(a.Metric, "p") {
+			a.percentile, err = strconv.ParseFloat(a.Metric[1:], 64)
+			if err != nil || a.percentile <= 0 || a.percentile > 100 {
+				return a, fmt.Errorf("--assert percentiles must be above 0 and at most 100, like p99 or p99.9, got %s", a.Metric)
+			}
+		}
+		a.Value, err = parseMilliseconds(value)
+	default:
+		return a, fmt.Errorf("--assert can check tps, error-rate, failed, mean, max or a latency percentile like p99, got %s", a.Metric)
+	}
+	if err != nil {
+		return a, fmt.Errorf("--assert %s has a value that can't be parsed, %s", raw, value)
+	}
+	return a, nil
+}
+
+// Percentages like 5% or plain fractions like 0.05
+func parseFraction(raw string) (float64, error) {
+	if strings.HasSuffix(raw, "%") {
+		percent, err := strconv.ParseFloat(strings.TrimSuffix(raw, "%"), 64)
+		return percent / 100, err
+	}
+	return strconv.ParseFloat(raw, 64)
+}
+
+// Durations like 20ms or 1.5s; plain numbers are milliseconds
+func parseMilliseconds(raw string) (float64, error) {
+	if ms, err := strconv.ParseFloat(raw, 64); err == nil {
+		return ms, nil
+	}
+	d, err := time.ParseDuration(raw)
+	if err != nil {
+		return 0, err
+	}
+	return float64(d.Microseconds()) / 1000.0, nil
+}
+
+// Describes how the result breaks the assertion; empty if it doesn't
+func (a Assertion) check(result Result) string {
+	metric := a.Metric
+	if a.Script != "" {
+		script, found := result.Scripts[a.Script]
+		if !found {
+			return fmt.Sprintf("%s didn't run, so there is nothing to check --assert %s against", a.Script, a.Raw)
+		}
+		result.Scripts = map[string]*ScriptResult{a.Script: script}
+		metric = fmt.Sprintf("%s of %s", a.Metric, a.Script)
+	}
+	var actual float64
+	var formatted string
+	switch a.Metric {
+	case "tps":
+		actual = result.TotalRate()
+		formatted = fmt.Sprintf("%.3f", actual)
+	case "failed":
+		actual = float64(result.TotalFailed())
+		formatted = fmt.Sprintf("%d", result.TotalFailed())
+	case "error-rate":
+		total := result.TotalSucceeded() + result.TotalFailed()
+		if total > 0 {
+			actual = float64(result.TotalFailed()) / float64(total)
+		}
+		formatted = fmt.Sprintf("%.3f%%", actual*100)
+	default:
+		if result.TotalSucceeded() == 0 {
+			return fmt.Sprintf("no transactions succeeded, so there is no %s latency to check --assert %s against", metric, a.Raw)
+		}
+		latencies := result.CombinedLatencies()
+		switch a.Metric {
+		case "mean":
+			actual = latencies.Mean() / 1000.0
+		case "max":
+			actual = float64(latencies.Max()) / 1000.0
+		default:
+			actual = float64(latencies.ValueAtQuantile(a.percentile)) / 1000.0
+		}
+		formatted = fmt.Sprintf("%.3fms", actual)
+	}
+	holds := false
+	switch a.Operator {
+	case "<":
+		holds = actual < a.Value
+	case "<=":
+		holds = actual <= a.Value
+	case ">":
+		holds = actual > a.Value
+	case ">=":
+		holds = actual >= a.Value
+	}
+	if holds {
+		return ""
+	}
+	return fmt.Sprintf("%s was %s, breaking --assert %s", metric, formatted, a.Raw)
+}
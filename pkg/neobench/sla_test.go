@@ -0,0 +1,122 @@
+package neobench
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMaxAcquireP99(t *testing.T) {
+	w := NewWorkerResult(0)
+	for i := 0; i < 100; i++ {
+		acquire := time.Millisecond
+		if i == 99 {
+			acquire = 50 * time.Millisecond
+		}
+		assert.NoError(t, w.record("s", 10*time.Millisecond, uowOutcome{succeeded: true, acquired: true, acquire: acquire}))
+	}
+	// Auto-commit units don't measure acquisition, and are left out
+	assert.NoError(t, w.record("s", 10*time.Millisecond, uowOutcome{succeeded: true}))
+	result := NewResult("", "")
+	assert.NoError(t, result.Add(w))
+	assert.Equal(t, int64(100), result.AcquireLatencies.TotalCount())
+
+	assert.Empty(t, SLA{}.Breaches(result))
+	assert.Empty(t, SLA{MaxAcquireP99: 2 * time.Millisecond}.Breaches(result))
+
+	assert.NoError(t, w.record("s", 10*time.Millisecond, uowOutcome{succeeded: true, acquired: true, acquire: 50 * time.Millisecond}))
+	result = NewResult("", "")
+	assert.NoError(t, result.Add(w))
+	assert.Equal(t, []string{"connection acquisition p99 was 50.015ms, above --max-acquire-p99 2ms; " +
+		"the connection pool is likely too small for the number of clients"}, SLA{MaxAcquireP99: 2 * time.Millisecond}.Breaches(result))
+}
+
+func TestParseAssertion(t *testing.T) {
+	a, err := ParseAssertion("p99.9 <= 1.5s")
+	assert.NoError(t, err)
+	assert.Equal(t, Assertion{Raw: "p99.9<=1.5s", Metric: "p99.9", Operator: "<=", Value: 1500, percentile: 99.9}, a)
+	a, err = ParseAssertion("error-rate<0.1%")
+	assert.NoError(t, err)
+	assert.Equal(t, "<", a.Operator)
+	assert.InDelta(t, 0.001, a.Value, 0.0000001)
+	a, err = ParseAssertion("tps>5000")
+	assert.NoError(t, err)
+	assert.Equal(t, Assertion{Raw: "tps>5000", Metric: "tps", Operator: ">", Value: 5000}, a)
+
+	_, err = ParseAssertion("p99")
+	assert.EqualError(t, err, "--assert should be a metric, one of <, <=, > or >=, and a value, ex: p99<20ms, got p99")
+	_, err = ParseAssertion("latency<20ms")
+	assert.EqualError(t, err, "--assert can check tps, error-rate, failed, mean, max or a latency percentile like p99, got latency")
+	_, err = ParseAssertion("p0<20ms")
+	assert.EqualError(t, err, "--assert percentiles must be above 0 and at most 100, like p99 or p99.9, got p0")
+	_, err = ParseAssertion("p99<fast")
+	assert.EqualError(t, err, "--assert p99<fast has a value that can't be parsed, fast")
+
+	a, err = ParseAssertion("builtin:tpcb-like:p99<20ms")
+	assert.NoError(t, err)
+	assert.Equal(t, Assertion{Raw: "builtin:tpcb-like:p99<20ms", Script: "builtin:tpcb-like", Metric: "p99", Operator: "<", Value: 20, percentile: 99}, a)
+	_, err = ParseAssertion(":p99<20ms")
+	assert.EqualError(t, err, "--assert needs a script name before the ':', like builtin:tpcb-like:p99<20ms, got :p99<20ms")
+}
+
+func TestAssertionsAreCheckedAgainstTheResult(t *testing.T) {
+	w := NewWorkerResult(0)
+	for i := 0; i < 99; i++ {
+		assert.NoError(t, w.record("s", 10*time.Millisecond, uowOutcome{succeeded: true}))
+	}
+	assert.NoError(t, w.record("s", 10*time.Millisecond, uowOutcome{succeeded: false, failureGroup: "unknown", err: fmt.Errorf("boom")}))
+	w.calculateRate(time.Second)
+	result := NewResult("", "")
+	assert.NoError(t, result.Add(w))
+
+	var assertions []Assertion
+	for _, raw := range []string{"p99<20ms", "tps>=100", "error-rate<0.1%", "error-rate<2%", "max<5"} {
+		a, err := ParseAssertion(raw)
+		assert.NoError(t, err)
+		assertions = append(assertions, a)
+	}
+	sla := SLA{Assertions: assertions}
+	assert.Equal(t, []SLACheck{
+		{Name: "assert-p99<20ms"},
+		{Name: "assert-tps>=100"},
+		{Name: "assert-error-rate<0.1%", Breach: "error-rate was 1.000%, breaking --assert error-rate<0.1%"},
+		{Name: "assert-error-rate<2%"},
+		{Name: "assert-max<5", Breach: "max was 10.007ms, breaking --assert max<5"},
+	}, sla.Check(result))
+	assert.True(t, sla.AllowsFailures(result))
+	assert.False(t, SLA{Assertions: assertions[:1]}.AllowsFailures(result))
+
+	assert.Equal(t, []string{"no transactions succeeded, so there is no p99 latency to check --assert p99<20ms against"},
+		SLA{Assertions: assertions[:1]}.Breaches(NewResult("", "")))
+}
+
+func TestAssertionsForOneScript(t *testing.T) {
+	w := NewWorkerResult(0)
+	for i := 0; i < 10; i++ {
+		assert.NoError(t, w.record("reads", time.Millisecond, uowOutcome{succeeded: true}))
+		assert.NoError(t, w.record("writes", 30*time.Millisecond, uowOutcome{succeeded: true}))
+	}
+	assert.NoError(t, w.record("writes", 30*time.Millisecond, uowOutcome{succeeded: false, failureGroup: "unknown", err: fmt.Errorf("boom")}))
+	w.calculateRate(time.Second)
+	result := NewResult("", "")
+	assert.NoError(t, result.Add(w))
+
+	parse := func(raws ...string) SLA {
+		var sla SLA
+		for _, raw := range raws {
+			a, err := ParseAssertion(raw)
+			assert.NoError(t, err)
+			sla.Assertions = append(sla.Assertions, a)
+		}
+		return sla
+	}
+	assert.Equal(t, []string{"p99 of writes was 30.015ms, breaking --assert writes:p99<20ms", "missing didn't run, so there is nothing to check --assert missing:tps>1 against"},
+		parse("reads:p99<20ms", "writes:p99<20ms", "missing:tps>1").Breaches(result))
+
+	// Only the script whose failures the assertion sets a limit for may fail
+	assert.True(t, parse("writes:failed<=1").AllowsFailures(result))
+	assert.True(t, parse("failed<=1").AllowsFailures(result))
+	assert.False(t, parse("reads:failed<=1").AllowsFailures(result))
+}
@@ -0,0 +1,129 @@
+package neobench
+
+import (
+	"fmt"
+	"io"
+	"net"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// Keeps packets within a typical MTU, which is what the Datadog agent recommends for UDP
+const statsdMaxPacketSize = 1432
+
+// Sends metrics over UDP to a StatsD server at each progress report, see --statsd, with DogStatsD tags for the
+// script and database, so a Datadog agent picks them up alongside application metrics. Each report covers the
+// interval since the previous one:
+//
+//	<prefix>.transactions.succeeded and .failed, counters
+//	<prefix>.tps, a gauge
+//	<prefix>.latency, a timer in milliseconds
+//
+// Rather than a packet per transaction, latencies are sent as a timer value per histogram bucket, with a sample
+// rate of 1/count so the server counts each value as often as it was recorded.
+type StatsdOutput struct {
+	ErrStream io.Writer
+	Prefix    string
+	// See --tag; metrics get these as tags, next to script and database
+	Tags Tags
+
+	conn io.Writer
+}
+
+func NewStatsdOutput(address, prefix string, errStream io.Writer) (*StatsdOutput, error) {
+	conn, err := net.Dial("udp", address)
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to set up --statsd")
+	}
+	return &StatsdOutput{ErrStream: errStream, Prefix: prefix, conn: conn}, nil
+}
+
+func (o *StatsdOutput) BenchmarkStart(databaseName, url, scenario string) {
+}
+
+func (o *StatsdOutput) ReportInitProgress(report ProgressReport) {
+}
+
+func (o *StatsdOutput) ReportWorkloadProgress(completeness float64, checkpoint Result) {
+	for _, packet := range statsdPackets(statsdLines(o.Prefix, o.Tags, checkpoint)) {
+		// Nothing listening is common with UDP and shouldn't stop the benchmark; we say so and carry on
+		if _, err := o.conn.Write(packet); err != nil {
+			if _, err := fmt.Fprintf(o.ErrStream, "WARNING: failed to send to --statsd: %s\n", err); err != nil {
+				panic(err)
+			}
+			return
+		}
+	}
+}
+
+// Progress reports already covered the run, sending the totals again would count everything twice
+func (o *StatsdOutput) ReportThroughput(result Result) {
+}
+
+func (o *StatsdOutput) ReportLatency(result Result) {
+}
+
+func (o *StatsdOutput) ReportSweep(sweep SweepResult) {
+}
+
+func (o *StatsdOutput) Errorf(format string, a ...interface{}) {
+}
+
+func statsdLines(prefix string, tags Tags, result Result) []string {
+	lines := make([]string, 0)
+	for _, script := range sortedScripts(result) {
+		suffix := "|#script:" + statsdTagValue(script.ScriptName)
+		if result.DatabaseName != "" {
+			suffix += ",database:" + statsdTagValue(result.DatabaseName)
+		}
+		for _, tag := range tags {
+			suffix += "," + tag.Key + ":" + statsdTagValue(tag.Value)
+		}
+		lines = append(lines,
+			fmt.Sprintf("%s.transactions.succeeded:%d|c%s", prefix, script.Succeeded, suffix),
+			fmt.Sprintf("%s.transactions.failed:%d|c%s", prefix, script.Failed, suffix),
+			fmt.Sprintf("%s.tps:%g|g%s", prefix, script.Rate, suffix))
+		if script.Latencies == nil {
+			continue
+		}
+		for _, bar := range script.Latencies.Distribution() {
+			if bar.Count == 0 {
+				continue
+			}
+			rate := ""
+			if bar.Count > 1 {
+				rate = fmt.Sprintf("|@%g", 1/float64(bar.Count))
+			}
+			lines = append(lines, fmt.Sprintf("%s.latency:%g|ms%s%s", prefix, float64(bar.To)/1000.0, rate, suffix))
+		}
+	}
+	return lines
+}
+
+// Commas separate tags and pipes separate the parts of a line, so neither can be in a tag
+func statsdTagValue(raw string) string {
+	return strings.NewReplacer(",", "_", "|", "_", "\n", "_").Replace(raw)
+}
+
+// Packs lines into as few packets as fit, one metric per line
+func statsdPackets(lines []string) [][]byte {
+	packets := make([][]byte, 0)
+	packet := strings.Builder{}
+	for _, line := range lines {
+		if packet.Len() > 0 && packet.Len()+1+len(line) > statsdMaxPacketSize {
+			packets = append(packets, []byte(packet.String()))
+			packet.Reset()
+		}
+		if packet.Len() > 0 {
+			packet.WriteString("\n")
+		}
+		packet.WriteString(line)
+	}
+	if packet.Len() > 0 {
+		packets = append(packets, []byte(packet.String()))
+	}
+	return packets
+}
+
+var _ Output = &StatsdOutput{}
@@ -0,0 +1,61 @@
+package neobench
+
+import (
+	"bytes"
+	"net"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestStatsdLines(t *testing.T) {
+	w := NewWorkerResult(0)
+	assert.NoError(t, w.record("a,b", 2*time.Millisecond, uowOutcome{succeeded: true}))
+	assert.NoError(t, w.record("a,b", 2*time.Millisecond, uowOutcome{succeeded: true}))
+	assert.NoError(t, w.record("a,b", 5*time.Millisecond, uowOutcome{succeeded: true}))
+	assert.NoError(t, w.record("a,b", time.Millisecond, uowOutcome{succeeded: false, failureGroup: "unknown", err: os.ErrClosed}))
+	w.calculateRate(time.Second)
+	result := NewResult("neo4j", "")
+	assert.NoError(t, result.Add(w))
+
+	assert.Equal(t, []string{
+		"nb.transactions.succeeded:3|c|#script:a_b,database:neo4j",
+		"nb.transactions.failed:1|c|#script:a_b,database:neo4j",
+		"nb.tps:4|g|#script:a_b,database:neo4j",
+		"nb.latency:2|ms|@0.5|#script:a_b,database:neo4j",
+		"nb.latency:5.003|ms|#script:a_b,database:neo4j",
+	}, statsdLines("nb", nil, result))
+}
+
+func TestStatsdSendsProgressOverUdp(t *testing.T) {
+	server, err := net.ListenPacket("udp", "127.0.0.1:0")
+	assert.NoError(t, err)
+	defer server.Close()
+
+	o, err := NewStatsdOutput(server.LocalAddr().String(), "neobench", &bytes.Buffer{})
+	assert.NoError(t, err)
+	o.ReportWorkloadProgress(0.5, influxTestResult(t))
+
+	assert.NoError(t, server.SetReadDeadline(time.Now().Add(5*time.Second)))
+	buf := make([]byte, statsdMaxPacketSize)
+	n, _, err := server.ReadFrom(buf)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{
+		"neobench.transactions.succeeded:1|c|#script:s",
+		"neobench.transactions.failed:0|c|#script:s",
+		"neobench.tps:0|g|#script:s",
+		"neobench.latency:1|ms|#script:s",
+	}, strings.Split(string(buf[:n]), "\n"))
+}
+
+func TestStatsdPacketsStayWithinMaxSize(t *testing.T) {
+	lines := []string{strings.Repeat("a", 1000), strings.Repeat("b", 431), strings.Repeat("c", 432), "d"}
+	packets := statsdPackets(lines)
+	assert.Equal(t, [][]byte{
+		[]byte(lines[0] + "\n" + lines[1]),
+		[]byte(lines[2] + "\n" + lines[3]),
+	}, packets)
+}
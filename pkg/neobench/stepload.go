@@ -0,0 +1,60 @@
+package neobench
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Load that goes up in steps, see --step-rate and --step-clients, eg. "100:+100:30s" starts at 100 and adds
+// 100 every 30 seconds. Each step is a run of its own, so it's reported separately, and the steps together
+// fill --duration.
+type StepLoad struct {
+	Start     float64
+	Increment float64
+	// How long each step runs for
+	Step time.Duration
+}
+
+// Parses a --step-rate or --step-clients value, <start>:+<increment>:<step duration>; flag is the name used in
+// errors. Client counts need to be whole numbers, so pass integers to require them.
+func ParseStepLoad(flag, raw string, integers bool) (StepLoad, error) {
+	form := fmt.Errorf("%s must be on the form <start>:+<increment>:<step duration>, like 100:+100:30s, got '%s'", flag, raw)
+	parts := strings.Split(raw, ":")
+	if len(parts) != 3 || !strings.HasPrefix(strings.TrimSpace(parts[1]), "+") {
+		return StepLoad{}, form
+	}
+	start, err := strconv.ParseFloat(strings.TrimSpace(parts[0]), 64)
+	if err != nil {
+		return StepLoad{}, form
+	}
+	increment, err := strconv.ParseFloat(strings.TrimPrefix(strings.TrimSpace(parts[1]), "+"), 64)
+	if err != nil {
+		return StepLoad{}, form
+	}
+	step, err := time.ParseDuration(strings.TrimSpace(parts[2]))
+	if err != nil {
+		return StepLoad{}, form
+	}
+	if start <= 0 || increment <= 0 || step <= 0 {
+		return StepLoad{}, fmt.Errorf("%s needs a start, increment and step duration above zero, got '%s'", flag, raw)
+	}
+	if integers && (start != float64(int64(start)) || increment != float64(int64(increment))) {
+		return StepLoad{}, fmt.Errorf("%s needs whole numbers for the start and increment, got '%s'", flag, raw)
+	}
+	return StepLoad{Start: start, Increment: increment, Step: step}, nil
+}
+
+// The load at each step that fits in total, at least one
+func (l StepLoad) Values(total time.Duration) []float64 {
+	steps := int(total / l.Step)
+	if steps < 1 {
+		steps = 1
+	}
+	values := make([]float64, 0, steps)
+	for i := 0; i < steps; i++ {
+		values = append(values, l.Start+float64(i)*l.Increment)
+	}
+	return values
+}
@@ -0,0 +1,32 @@
+package neobench
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseStepLoad(t *testing.T) {
+	l, err := ParseStepLoad("--step-rate", "100:+50.5:30s", false)
+	assert.NoError(t, err)
+	assert.Equal(t, StepLoad{Start: 100, Increment: 50.5, Step: 30 * time.Second}, l)
+
+	_, err = ParseStepLoad("--step-rate", "100:100:30s", false)
+	assert.EqualError(t, err, "--step-rate must be on the form <start>:+<increment>:<step duration>, like 100:+100:30s, got '100:100:30s'")
+	_, err = ParseStepLoad("--step-rate", "100:+100", false)
+	assert.EqualError(t, err, "--step-rate must be on the form <start>:+<increment>:<step duration>, like 100:+100:30s, got '100:+100'")
+	_, err = ParseStepLoad("--step-rate", "0:+100:30s", false)
+	assert.EqualError(t, err, "--step-rate needs a start, increment and step duration above zero, got '0:+100:30s'")
+	_, err = ParseStepLoad("--step-clients", "1:+0.5:30s", true)
+	assert.EqualError(t, err, "--step-clients needs whole numbers for the start and increment, got '1:+0.5:30s'")
+}
+
+func TestStepLoadFillsTheDuration(t *testing.T) {
+	l := StepLoad{Start: 100, Increment: 100, Step: 30 * time.Second}
+
+	assert.Equal(t, []float64{100, 200, 300, 400}, l.Values(2*time.Minute))
+	// The remainder is too short for another step
+	assert.Equal(t, []float64{100, 200, 300, 400}, l.Values(2*time.Minute+10*time.Second))
+	assert.Equal(t, []float64{100}, l.Values(10*time.Second))
+}
@@ -0,0 +1,89 @@
+package neobench
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// A label for the run, see --tag, like hardware=m5.xlarge; outputs attach tags to everything they write, so
+// runs against different setups can be told apart without post-processing
+type Tag struct {
+	Key   string
+	Value string
+}
+
+// In the order they were given
+type Tags []Tag
+
+// Keys end up as Prometheus labels, InfluxDB tags and CSV columns, so they're held to what all of those allow
+var tagKeyPattern = regexp.MustCompile(`^[a-zA-Z_][a-zA-Z0-9_]*$`)
+
+// Outputs already label metrics with these
+var reservedTagKeys = map[string]bool{"script": true, "database": true, "quantile": true}
+
+// Parses --tag values, each key=value
+func ParseTags(specs []string) (Tags, error) {
+	tags := make(Tags, 0, len(specs))
+	for _, spec := range specs {
+		parts := strings.SplitN(spec, "=", 2)
+		if len(parts) != 2 {
+			return nil, fmt.Errorf("--tag should be key=value, ex: --tag hardware=m5.xlarge, got %s", spec)
+		}
+		tag := Tag{Key: parts[0], Value: parts[1]}
+		if !tagKeyPattern.MatchString(tag.Key) {
+			return nil, fmt.Errorf("--tag keys can only have letters, digits and underscores, and can't start with a digit, got %s", tag.Key)
+		}
+		if reservedTagKeys[tag.Key] {
+			return nil, fmt.Errorf("--tag %s is used by neobench itself, pick another key", tag.Key)
+		}
+		if _, found := tags.get(tag.Key); found {
+			return nil, fmt.Errorf("--tag %s is given more than once", tag.Key)
+		}
+		tags = append(tags, tag)
+	}
+	return tags, nil
+}
+
+func (t Tags) get(key string) (string, bool) {
+	for _, tag := range t {
+		if tag.Key == key {
+			return tag.Value, true
+		}
+	}
+	return "", false
+}
+
+func (t Tags) asMap() map[string]string {
+	out := make(map[string]string, len(t))
+	for _, tag := range t {
+		out[tag.Key] = tag.Value
+	}
+	return out
+}
+
+// Like hardware=m5.xlarge, disk=ssd
+func (t Tags) String() string {
+	parts := make([]string, 0, len(t))
+	for _, tag := range t {
+		parts = append(parts, tag.Key+"="+tag.Value)
+	}
+	return strings.Join(parts, ", ")
+}
+
+// Names for the columns tags get in CSV outputs; prefixed so they can't clash with the other columns
+func (t Tags) csvColumnNames() []string {
+	names := make([]string, 0, len(t))
+	for _, tag := range t {
+		names = append(names, "tag_"+tag.Key)
+	}
+	return names
+}
+
+func (t Tags) csvValues() []string {
+	values := make([]string, 0, len(t))
+	for _, tag := range t {
+		values = append(values, csvQuote(tag.Value))
+	}
+	return values
+}
@@ -0,0 +1,49 @@
+package neobench
+
+import (
+	"bytes"
+	"encoding/json"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseTagsKeepsTheGivenOrder(t *testing.T) {
+	tags, err := ParseTags([]string{"hardware=m5.xlarge", "disk=", "note=a=b"})
+	assert.NoError(t, err)
+	assert.Equal(t, Tags{{"hardware", "m5.xlarge"}, {"disk", ""}, {"note", "a=b"}}, tags)
+	assert.Equal(t, "hardware=m5.xlarge, disk=, note=a=b", tags.String())
+
+	_, err = ParseTags([]string{"hardware"})
+	assert.EqualError(t, err, "--tag should be key=value, ex: --tag hardware=m5.xlarge, got hardware")
+	_, err = ParseTags([]string{"1hardware=x"})
+	assert.EqualError(t, err, "--tag keys can only have letters, digits and underscores, and can't start with a digit, got 1hardware")
+	_, err = ParseTags([]string{"script=x"})
+	assert.EqualError(t, err, "--tag script is used by neobench itself, pick another key")
+	_, err = ParseTags([]string{"disk=ssd", "disk=hdd"})
+	assert.EqualError(t, err, "--tag disk is given more than once")
+}
+
+func TestTagsAreAttachedToCsvRowsAndJsonDocuments(t *testing.T) {
+	tags := Tags{{"hardware", "m5.xlarge"}}
+	w := NewWorkerResult(0)
+	assert.NoError(t, w.record("a", time.Millisecond, uowOutcome{succeeded: true}))
+	w.calculateRate(time.Second)
+	result := NewResult("", "")
+	assert.NoError(t, result.Add(w))
+
+	csv := strings.Builder{}
+	c := &CsvOutput{ErrStream: &strings.Builder{}, OutStream: &csv, Tags: tags}
+	c.ReportThroughput(result)
+	assert.Equal(t, "script,succeeded,failed,transactions_per_second,retried,deadlocks,closed_loop_p50,closed_loop_p95,closed_loop_p99,tag_hardware\n"+
+		"\"a\",1.000,0.000,1.000,0.000,0.000,1.000,1.000,1.000,\"m5.xlarge\"\n", csv.String())
+
+	out := bytes.Buffer{}
+	o := &JsonOutput{OutStream: &out, ErrStream: &bytes.Buffer{}, Tags: tags}
+	o.ReportThroughput(result)
+	var doc map[string]interface{}
+	assert.NoError(t, json.Unmarshal(out.Bytes(), &doc))
+	assert.Equal(t, map[string]interface{}{"hardware": "m5.xlarge"}, doc["tags"])
+}
@@ -0,0 +1,68 @@
+package neobench
+
+import (
+	"fmt"
+	"math/rand"
+	"strings"
+	"time"
+)
+
+// How long clients pause between transactions in throughput mode, see --think-time, to model users who read
+// what they got before they ask for more, rather than clients hammering the database back-to-back
+type ThinkTime struct {
+	// One of "fixed", "uniform" or "exponential"; empty for no think time
+	Distribution string
+	// The think time if fixed, the mean if exponential, or the bounds if uniform
+	Min, Max time.Duration
+}
+
+// Parses a --think-time value: a duration like 500ms, which is fixed, or one of fixed:500ms, uniform:100ms-2s and
+// exponential:1s, which has a mean of a second
+func ParseThinkTime(raw string) (ThinkTime, error) {
+	form := fmt.Errorf("--think-time should be a duration, or fixed:<duration>, uniform:<min>-<max> or exponential:<mean>, like 500ms or uniform:100ms-2s, got '%s'", raw)
+	distribution, value := "fixed", raw
+	if colon := strings.Index(raw, ":"); colon != -1 {
+		distribution, value = raw[:colon], raw[colon+1:]
+	}
+	t := ThinkTime{Distribution: distribution}
+	var err error
+	switch distribution {
+	case "fixed", "exponential":
+		t.Min, err = time.ParseDuration(value)
+		t.Max = t.Min
+	case "uniform":
+		bounds := strings.Split(value, "-")
+		if len(bounds) != 2 {
+			return ThinkTime{}, form
+		}
+		if t.Min, err = time.ParseDuration(bounds[0]); err == nil {
+			t.Max, err = time.ParseDuration(bounds[1])
+		}
+	default:
+		return ThinkTime{}, form
+	}
+	if err != nil {
+		return ThinkTime{}, form
+	}
+	if t.Min < 0 || t.Max < t.Min || t.Max == 0 {
+		return ThinkTime{}, fmt.Errorf("--think-time needs durations above zero, and a min no larger than the max, got '%s'", raw)
+	}
+	return t, nil
+}
+
+func (t ThinkTime) IsZero() bool {
+	return t.Distribution == ""
+}
+
+// Draws the next think time
+func (t ThinkTime) Next(r *rand.Rand) time.Duration {
+	switch t.Distribution {
+	case "fixed":
+		return t.Min
+	case "uniform":
+		return t.Min + time.Duration(r.Int63n(int64(t.Max-t.Min)+1))
+	case "exponential":
+		return time.Duration(r.ExpFloat64() * float64(t.Min))
+	}
+	return 0
+}
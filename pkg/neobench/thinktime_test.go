@@ -0,0 +1,93 @@
+package neobench
+
+import (
+	"math/rand"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseThinkTime(t *testing.T) {
+	for raw, expected := range map[string]ThinkTime{
+		"500ms":           {Distribution: "fixed", Min: 500 * time.Millisecond, Max: 500 * time.Millisecond},
+		"fixed:1s":        {Distribution: "fixed", Min: time.Second, Max: time.Second},
+		"uniform:0s-2s":   {Distribution: "uniform", Min: 0, Max: 2 * time.Second},
+		"exponential:1s":  {Distribution: "exponential", Min: time.Second, Max: time.Second},
+		"uniform:1s-1.5s": {Distribution: "uniform", Min: time.Second, Max: 1500 * time.Millisecond},
+	} {
+		parsed, err := ParseThinkTime(raw)
+		assert.NoError(t, err, raw)
+		assert.Equal(t, expected, parsed, raw)
+	}
+
+	for _, raw := range []string{"soon", "normal:1s", "uniform:1s", "exponential:often"} {
+		_, err := ParseThinkTime(raw)
+		assert.EqualError(t, err, "--think-time should be a duration, or fixed:<duration>, uniform:<min>-<max> or exponential:<mean>, like 500ms or uniform:100ms-2s, got '"+raw+"'")
+	}
+	for _, raw := range []string{"0s", "uniform:2s-1s", "-1s"} {
+		_, err := ParseThinkTime(raw)
+		assert.EqualError(t, err, "--think-time needs durations above zero, and a min no larger than the max, got '"+raw+"'")
+	}
+}
+
+func TestThinkTimeDistributions(t *testing.T) {
+	r := rand.New(rand.NewSource(1337))
+	uniform := ThinkTime{Distribution: "uniform", Min: time.Second, Max: 2 * time.Second}
+	exponential := ThinkTime{Distribution: "exponential", Min: time.Second, Max: time.Second}
+	uniformTotal, exponentialTotal := time.Duration(0), time.Duration(0)
+	for i := 0; i < 10000; i++ {
+		u := uniform.Next(r)
+		assert.True(t, u >= time.Second && u <= 2*time.Second, u)
+		uniformTotal += u
+		exponentialTotal += exponential.Next(r)
+	}
+	assert.InDelta(t, 1.5, (uniformTotal / 10000).Seconds(), 0.05)
+	assert.InDelta(t, 1, (exponentialTotal / 10000).Seconds(), 0.05)
+	assert.Equal(t, 500*time.Millisecond, ThinkTime{Distribution: "fixed", Min: 500 * time.Millisecond}.Next(r))
+	assert.Equal(t, time.Duration(0), ThinkTime{}.Next(r))
+}
+
+func TestWorkerThinksBetweenTransactions(t *testing.T) {
+	r := rand.New(rand.NewSource(1337))
+	clock := &fakeSpaceTimeContinuum{}
+	clock.currentTime = time.Date(2020, 1, 1, 1, 1, 1, 1, time.UTC)
+	w := Worker{
+		workerId: 0,
+		driver:   &fakeDriver{clock: clock, r: r, minLatency: 10 * time.Millisecond, maxLatency: 10 * time.Millisecond},
+		now:      clock.now,
+		sleep:    clock.sleepUnlessStopped,
+	}
+	w.ThinkBetween(ThinkTime{Distribution: "fixed", Min: 90 * time.Millisecond, Max: 90 * time.Millisecond}, r)
+
+	start := clock.now()
+	result := w.RunBenchmark(newTestWorkload(r), "", 0, 10, make(chan struct{}), nil, NewResultRecorder(0, time.Time{}))
+
+	assert.NoError(t, result.Error)
+	script := result.Scripts["workertest"]
+	assert.Equal(t, int64(10), script.Succeeded)
+	// Thinking takes up the time between transactions, but isn't part of their latency; there is none after the last
+	assert.Equal(t, 910*time.Millisecond, clock.now().Sub(start))
+	assert.InDelta(t, 10000, script.Latencies.Max(), 100)
+}
+
+func TestWorkerStopsThinking(t *testing.T) {
+	r := rand.New(rand.NewSource(1337))
+	clock := &fakeSpaceTimeContinuum{}
+	clock.currentTime = time.Date(2020, 1, 1, 1, 1, 1, 1, time.UTC)
+	w := Worker{
+		workerId: 0,
+		driver:   &fakeDriver{clock: clock, r: r, minLatency: 10 * time.Millisecond, maxLatency: 10 * time.Millisecond},
+		now:      clock.now,
+		// Really sleeps, so the think time can be cut short
+		sleep: sleepUnlessStopped,
+	}
+	w.ThinkBetween(ThinkTime{Distribution: "fixed", Min: time.Hour, Max: time.Hour}, r)
+	stopCh := make(chan struct{})
+	time.AfterFunc(10*time.Millisecond, func() { close(stopCh) })
+
+	result := w.RunBenchmark(newTestWorkload(r), "", 0, 0, stopCh, nil, NewResultRecorder(0, time.Time{}))
+
+	assert.NoError(t, result.Error)
+	assert.Equal(t, int64(1), result.Scripts["workertest"].Succeeded)
+}
@@ -0,0 +1,142 @@
+package neobench
+
+import (
+	"fmt"
+	"math"
+	"strings"
+	"time"
+)
+
+// How often the throughput of a run is sampled, for ThroughputStats
+const ThroughputSampleInterval = time.Second
+
+// Samples the throughput of a run as it goes, from the number of transactions completed so far at each call,
+// see ResultRecorder.Completed; the first call only sets where the first sample starts from.
+type ThroughputSampler struct {
+	Interval time.Duration
+	// Transactions per second in each interval
+	Samples []float64
+
+	started       bool
+	next          time.Time
+	lastTime      time.Time
+	lastCompleted int64
+}
+
+func NewThroughputSampler(interval time.Duration) *ThroughputSampler {
+	return &ThroughputSampler{Interval: interval}
+}
+
+// Takes a sample if an interval has passed since the last one
+func (t *ThroughputSampler) Sample(now time.Time, completed int64) {
+	if t.started && now.Before(t.next) {
+		return
+	}
+	if t.started {
+		t.Samples = append(t.Samples, float64(completed-t.lastCompleted)/now.Sub(t.lastTime).Seconds())
+	}
+	t.started = true
+	t.next = now.Add(t.Interval)
+	t.lastTime = now
+	t.lastCompleted = completed
+}
+
+// Spread of throughput across the samples of a run, to tell whether a difference between two runs is more than noise
+type ThroughputStats struct {
+	Samples int
+	Mean    float64
+	Stddev  float64
+	// 95% confidence interval of the mean
+	Low, High float64
+}
+
+// Needs at least two samples
+func throughputStats(samples []float64) (ThroughputStats, bool) {
+	n := len(samples)
+	if n < 2 {
+		return ThroughputStats{}, false
+	}
+	sum := 0.0
+	for _, v := range samples {
+		sum += v
+	}
+	mean := sum / float64(n)
+	squares := 0.0
+	for _, v := range samples {
+		squares += (v - mean) * (v - mean)
+	}
+	stddev := math.Sqrt(squares / float64(n-1))
+	margin := studentT95(n-1) * stddev / math.Sqrt(float64(n))
+	return ThroughputStats{Samples: n, Mean: mean, Stddev: stddev, Low: mean - margin, High: mean + margin}, true
+}
+
+// Two-sided 95% critical values of Student's t distribution, by degrees of freedom, from 1 to 30
+var studentT95Table = []float64{
+	12.706, 4.303, 3.182, 2.776, 2.571, 2.447, 2.365, 2.306, 2.262, 2.228,
+	2.201, 2.179, 2.160, 2.145, 2.131, 2.120, 2.110, 2.101, 2.093, 2.086,
+	2.080, 2.074, 2.069, 2.064, 2.060, 2.056, 2.052, 2.048, 2.045, 2.042,
+}
+
+// Samples are few in short runs, where the normal distribution would make the interval look tighter than it is
+func studentT95(degreesOfFreedom int) float64 {
+	if degreesOfFreedom <= len(studentT95Table) {
+		return studentT95Table[degreesOfFreedom-1]
+	}
+	return 1.960
+}
+
+// Goes below the totals, eg. "Throughput: mean 101.000 per second, stddev 2.000, 95% CI 99.000 to 103.000, over 60 samples of 1s"
+func writeThroughputStats(result Result, s *strings.Builder) {
+	stats, ok := throughputStats(result.ThroughputSamples)
+	if !ok {
+		return
+	}
+	s.WriteString(fmt.Sprintf("Throughput: mean %.3f per second, stddev %.3f, 95%% CI %.3f to %.3f, over %d samples of %s\n",
+		stats.Mean, stats.Stddev, stats.Low, stats.High, stats.Samples, ThroughputSampleInterval))
+}
+
+// Stops a run once its throughput has settled, see --until-stable: when, over the last Window samples, the 95%
+// confidence interval of the mean is within Tolerance of it, and the means of the first and second half of the
+// window are within Tolerance of each other, so a throughput that is still climbing or falling doesn't count
+type StabilityCheck struct {
+	// Fraction of the mean, eg. 0.02 for 2%; zero for no check
+	Tolerance float64
+	// Samples of ThroughputSampleInterval to judge by
+	Window int
+}
+
+// Parses --until-stable, a percentage like 2% or a fraction like 0.02, judged over window
+func ParseStabilityCheck(raw string, window time.Duration) (StabilityCheck, error) {
+	tolerance, err := parseFraction(raw)
+	if err != nil || tolerance <= 0 || tolerance >= 1 {
+		return StabilityCheck{}, fmt.Errorf("--until-stable should be above 0%% and below 100%%, like 2%% or 0.02, got %s", raw)
+	}
+	samples := int(window / ThroughputSampleInterval)
+	if samples < 4 {
+		return StabilityCheck{}, fmt.Errorf("--stable-window needs to be at least %s, got %s", 4*ThroughputSampleInterval, window)
+	}
+	return StabilityCheck{Tolerance: tolerance, Window: samples}, nil
+}
+
+func (c StabilityCheck) IsZero() bool {
+	return c.Tolerance == 0
+}
+
+// Says how the throughput settled, if it has; empty if it hasn't yet
+func (c StabilityCheck) Settled(samples []float64) string {
+	if c.IsZero() || len(samples) < c.Window {
+		return ""
+	}
+	window := samples[len(samples)-c.Window:]
+	stats, _ := throughputStats(window)
+	if stats.Mean <= 0 || stats.High-stats.Mean > c.Tolerance*stats.Mean {
+		return ""
+	}
+	first, _ := throughputStats(window[:c.Window/2])
+	second, _ := throughputStats(window[c.Window/2:])
+	if math.Abs(second.Mean-first.Mean) > c.Tolerance*stats.Mean {
+		return ""
+	}
+	return fmt.Sprintf("throughput settled at %.3f per second, within %.2f%% over the last %s, after %s",
+		stats.Mean, c.Tolerance*100, time.Duration(c.Window)*ThroughputSampleInterval, time.Duration(len(samples))*ThroughputSampleInterval)
+}
@@ -0,0 +1,69 @@
+package neobench
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestThroughputSamplerSamplesEachInterval(t *testing.T) {
+	sampler := NewThroughputSampler(time.Second)
+	start := time.Date(2020, 1, 1, 1, 1, 1, 0, time.UTC)
+	sampler.Sample(start, 10)
+	sampler.Sample(start.Add(500*time.Millisecond), 60)
+	sampler.Sample(start.Add(time.Second), 110)
+	// Ticks don't land exactly on the interval, so rates are over the actual time between samples
+	sampler.Sample(start.Add(2100*time.Millisecond), 330)
+	assert.Len(t, sampler.Samples, 2)
+	assert.InDelta(t, 100, sampler.Samples[0], 0.001)
+	assert.InDelta(t, 200, sampler.Samples[1], 0.001)
+}
+
+func TestThroughputStatsHaveAConfidenceIntervalOfTheMean(t *testing.T) {
+	_, ok := throughputStats([]float64{100})
+	assert.False(t, ok)
+
+	stats, ok := throughputStats([]float64{98, 100, 102})
+	assert.True(t, ok)
+	assert.Equal(t, 3, stats.Samples)
+	assert.InDelta(t, 100, stats.Mean, 0.001)
+	assert.InDelta(t, 2, stats.Stddev, 0.001)
+	assert.InDelta(t, 95.031, stats.Low, 0.001)
+	assert.InDelta(t, 104.969, stats.High, 0.001)
+
+	result := NewResult("", "")
+	result.ThroughputSamples = []float64{98, 100, 102}
+	s := strings.Builder{}
+	writeThroughputStats(result, &s)
+	assert.Equal(t, "Throughput: mean 100.000 per second, stddev 2.000, 95% CI 95.031 to 104.969, over 3 samples of 1s\n", s.String())
+}
+
+func TestStabilityCheckWaitsForTheThroughputToSettle(t *testing.T) {
+	check, err := ParseStabilityCheck("2%", 10*time.Second)
+	assert.NoError(t, err)
+	assert.Equal(t, StabilityCheck{Tolerance: 0.02, Window: 10}, check)
+
+	// Warming up, then around 1000 with a little noise
+	samples := []float64{200, 500, 800}
+	for i := 0; i < 9; i++ {
+		samples = append(samples, 1000+float64(i%3-1)*5)
+	}
+	assert.Equal(t, "", check.Settled(samples))
+	samples = append(samples, 1000)
+	assert.Equal(t, "throughput settled at 1000.000 per second, within 2.00% over the last 10s, after 13s", check.Settled(samples))
+
+	// Steady climbs have tight intervals, but halves that don't agree
+	climbing := make([]float64, 0)
+	for i := 0; i < 10; i++ {
+		climbing = append(climbing, 1000+float64(i)*5)
+	}
+	assert.Equal(t, "", check.Settled(climbing))
+	assert.Equal(t, "", StabilityCheck{}.Settled(samples))
+
+	_, err = ParseStabilityCheck("0%", 10*time.Second)
+	assert.EqualError(t, err, "--until-stable should be above 0% and below 100%, like 2% or 0.02, got 0%")
+	_, err = ParseStabilityCheck("2%", 3*time.Second)
+	assert.EqualError(t, err, "--stable-window needs to be at least 4s, got 3s")
+}
@@ -0,0 +1,99 @@
+package neobench
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+var timeSeriesColumns = []string{"scenario", "time", "elapsed", "script", "rate", "succeeded", "failed", "p50", "p95", "p99", "p100"}
+
+// Writes a CSV row per script for every window of a run, see --timeseries, so a run that degrades halfway
+// through can be told apart from a stable one. Windows are --timeseries-window long, except the last one of
+// each run, which ends when the run does; latencies are in milliseconds, and left empty for windows where
+// nothing succeeded.
+type TimeSeriesWriter struct {
+	Window time.Duration
+	// See --tag; each tag gets a column, after the others
+	Tags Tags
+
+	out      io.Writer
+	scenario string
+	start    time.Time
+	// Scripts seen so far in the current run, so windows where a script stalls completely still get a row
+	scripts []string
+}
+
+func NewTimeSeriesWriter(path string, window time.Duration, tags Tags) (*TimeSeriesWriter, error) {
+	if window <= 0 {
+		return nil, fmt.Errorf("--timeseries-window must be positive, got %s", window)
+	}
+	file, err := os.Create(path)
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to open --timeseries file")
+	}
+	return newTimeSeriesWriter(file, window, tags), nil
+}
+
+func newTimeSeriesWriter(out io.Writer, window time.Duration, tags Tags) *TimeSeriesWriter {
+	columns := append(append([]string{}, timeSeriesColumns...), tags.csvColumnNames()...)
+	if _, err := fmt.Fprintf(out, "%s\n", strings.Join(columns, ",")); err != nil {
+		panic(err)
+	}
+	return &TimeSeriesWriter{Window: window, Tags: tags, out: out}
+}
+
+// Called as each run starts; elapsed is counted from now
+func (w *TimeSeriesWriter) RunStart(scenario string, now time.Time) {
+	w.scenario = scenario
+	w.start = now
+	w.scripts = nil
+}
+
+// Writes the window ending at now
+func (w *TimeSeriesWriter) Record(now time.Time, window Result) {
+	for name := range window.Scripts {
+		found := false
+		for _, seen := range w.scripts {
+			found = found || seen == name
+		}
+		if !found {
+			w.scripts = append(w.scripts, name)
+		}
+	}
+	sort.Strings(w.scripts)
+
+	s := strings.Builder{}
+	for _, name := range w.scripts {
+		row := []string{
+			csvQuote(w.scenario),
+			now.UTC().Format(time.RFC3339Nano),
+			fmtFloat(now.Sub(w.start).Seconds()),
+			csvQuote(name),
+		}
+		script, found := window.Scripts[name]
+		if !found {
+			row = append(row, fmtFloat(0.0), fmtFloat(int64(0)), fmtFloat(int64(0)), "", "", "", "")
+		} else {
+			row = append(row, fmtFloat(script.Rate), fmtFloat(script.Succeeded), fmtFloat(script.Failed))
+			for _, q := range []float64{50, 95, 99, 100} {
+				if script.Succeeded == 0 {
+					row = append(row, "")
+				} else {
+					row = append(row, fmtFloat(float64(script.Latencies.ValueAtQuantile(q))/1000.0))
+				}
+			}
+		}
+		row = append(row, w.Tags.csvValues()...)
+		s.WriteString(strings.Join(row, ","))
+		s.WriteString("\n")
+	}
+	if _, err := fmt.Fprint(w.out, s.String()); err != nil {
+		panic(err)
+	}
+}
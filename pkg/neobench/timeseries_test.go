@@ -0,0 +1,39 @@
+package neobench
+
+import (
+	"bytes"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTimeSeriesWritesARowPerScriptPerWindow(t *testing.T) {
+	out := &bytes.Buffer{}
+	w := newTimeSeriesWriter(out, time.Second, nil)
+	start := time.Date(2020, 1, 1, 1, 1, 1, 0, time.UTC)
+	w.RunStart("-l -r 10", start)
+
+	first := NewWorkerResult(0)
+	assert.NoError(t, first.record("a", time.Millisecond, uowOutcome{succeeded: true}))
+	assert.NoError(t, first.record("b", 2*time.Millisecond, uowOutcome{succeeded: true}))
+	first.calculateRate(time.Second)
+	window := NewResult("", "")
+	assert.NoError(t, window.Add(first))
+	w.Record(start.Add(time.Second), window)
+
+	// b stalls completely in the second window, and a only fails
+	second := NewWorkerResult(0)
+	assert.NoError(t, second.record("a", time.Millisecond, uowOutcome{succeeded: false, failureGroup: "unknown", err: os.ErrClosed}))
+	second.calculateRate(time.Second)
+	window = NewResult("", "")
+	assert.NoError(t, window.Add(second))
+	w.Record(start.Add(2*time.Second), window)
+
+	assert.Equal(t, "scenario,time,elapsed,script,rate,succeeded,failed,p50,p95,p99,p100\n"+
+		"\"-l -r 10\",2020-01-01T01:01:02Z,1.000,\"a\",1.000,1.000,0.000,1.000,1.000,1.000,1.000\n"+
+		"\"-l -r 10\",2020-01-01T01:01:02Z,1.000,\"b\",1.000,1.000,0.000,2.000,2.000,2.000,2.000\n"+
+		"\"-l -r 10\",2020-01-01T01:01:03Z,2.000,\"a\",1.000,0.000,1.000,,,,\n"+
+		"\"-l -r 10\",2020-01-01T01:01:03Z,2.000,\"b\",0.000,0.000,0.000,,,,\n", out.String())
+}
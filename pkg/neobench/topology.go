@@ -0,0 +1,231 @@
+package neobench
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/neo4j/neo4j-go-driver/v4/neo4j"
+	"github.com/pkg/errors"
+)
+
+// One server in the cluster, or the single server of a standalone deployment
+type ClusterMember struct {
+	Id string
+	// The address neobench connects to this member on, host:port
+	BoltAddress string
+	// Role by database, ex: {"neo4j": "LEADER", "system": "FOLLOWER"}; empty for standalone servers
+	Roles map[string]string
+	// Routing roles for the targeted database given to this member in the routing table: WRITE, READ and ROUTE
+	RoutingRoles []string
+
+	// Set by CheckReachability
+	Reachable bool
+	RoundTrip time.Duration
+	Err       error
+}
+
+// Cluster members and what the driver would route to them, see --topology
+type Topology struct {
+	// Targeted database; empty for the default database
+	DatabaseName string
+	// False if the server does not know of any cluster, eg. it's a standalone server
+	Clustered bool
+	Members   []ClusterMember
+}
+
+// Asks the server for the cluster members and the routing table of the targeted database. Loading a cluster
+// means loading whatever the routing table says, so this is what the benchmark will talk to
+func DiscoverTopology(driver neo4j.Driver, databaseName string) (Topology, error) {
+	session := driver.NewSession(neo4j.SessionConfig{AccessMode: neo4j.AccessModeRead, DatabaseName: "system"})
+	defer session.Close()
+
+	var db interface{}
+	if databaseName != "" {
+		db = databaseName
+	}
+	routing, err := collectMaps(session, "CALL dbms.routing.getRoutingTable({}, $db) YIELD servers UNWIND servers AS server RETURN server",
+		map[string]interface{}{"db": db})
+	if err != nil {
+		return Topology{}, errors.Wrap(err, "failed to get routing table")
+	}
+
+	// Standalone servers don't have this procedure, or have it and return nothing; either way we make do
+	// with the routing table
+	overview, err := collectMaps(session, "CALL dbms.cluster.overview() YIELD id, addresses, databases "+
+		"RETURN {id: id, addresses: addresses, databases: databases}", nil)
+	if err != nil {
+		overview = nil
+	}
+
+	return buildTopology(databaseName, overview, routing), nil
+}
+
+func collectMaps(session neo4j.Session, query string, params map[string]interface{}) ([]map[string]interface{}, error) {
+	res, err := session.Run(query, params)
+	if err != nil {
+		return nil, err
+	}
+	records, err := res.Collect()
+	if err != nil {
+		return nil, err
+	}
+	out := make([]map[string]interface{}, 0, len(records))
+	for _, record := range records {
+		if m, ok := record.Values[0].(map[string]interface{}); ok {
+			out = append(out, m)
+		}
+	}
+	return out, nil
+}
+
+// overview is rows from dbms.cluster.overview, as {id, addresses, databases}; routing is the servers of a
+// routing table, as {addresses, role}
+func buildTopology(databaseName string, overview, routing []map[string]interface{}) Topology {
+	t := Topology{DatabaseName: databaseName, Clustered: len(overview) > 0}
+	// Index into t.Members by bolt address
+	byAddress := make(map[string]int)
+
+	for _, row := range overview {
+		member := ClusterMember{Roles: make(map[string]string)}
+		member.Id, _ = row["id"].(string)
+		if addresses, ok := row["addresses"].([]interface{}); ok {
+			for _, raw := range addresses {
+				if address, ok := raw.(string); ok && strings.HasPrefix(address, "bolt://") {
+					member.BoltAddress = strings.TrimPrefix(address, "bolt://")
+				}
+			}
+		}
+		if databases, ok := row["databases"].(map[string]interface{}); ok {
+			for db, role := range databases {
+				member.Roles[db] = fmt.Sprintf("%v", role)
+			}
+		}
+		byAddress[member.BoltAddress] = len(t.Members)
+		t.Members = append(t.Members, member)
+	}
+
+	for _, server := range routing {
+		role, _ := server["role"].(string)
+		addresses, _ := server["addresses"].([]interface{})
+		for _, raw := range addresses {
+			address, ok := raw.(string)
+			if !ok {
+				continue
+			}
+			i, found := byAddress[address]
+			if !found {
+				i = len(t.Members)
+				byAddress[address] = i
+				t.Members = append(t.Members, ClusterMember{Id: address, BoltAddress: address, Roles: make(map[string]string)})
+			}
+			t.Members[i].RoutingRoles = append(t.Members[i].RoutingRoles, role)
+		}
+	}
+
+	sort.Slice(t.Members, func(i, j int) bool {
+		return t.Members[i].BoltAddress < t.Members[j].BoltAddress
+	})
+	return t
+}
+
+// Connects to each member directly, using dial, and records whether that worked and how long it took
+func (t *Topology) CheckReachability(dial func(address string) error) {
+	for i := range t.Members {
+		member := &t.Members[i]
+		if member.BoltAddress == "" {
+			member.Err = fmt.Errorf("member does not advertise a bolt address")
+			continue
+		}
+		start := time.Now()
+		member.Err = dial(member.BoltAddress)
+		member.RoundTrip = time.Now().Sub(start)
+		member.Reachable = member.Err == nil
+	}
+}
+
+// Ways the topology looks like it will give surprising benchmark results
+func (t *Topology) Warnings() []string {
+	warnings := make([]string, 0)
+	writers, readers := 0, 0
+	for _, member := range t.Members {
+		for _, role := range member.RoutingRoles {
+			switch role {
+			case "WRITE":
+				writers++
+			case "READ":
+				readers++
+			}
+		}
+		if member.Err != nil {
+			warnings = append(warnings, fmt.Sprintf("%s is not reachable: %s", member.BoltAddress, member.Err))
+		}
+	}
+	if writers == 0 {
+		warnings = append(warnings, "the routing table has no writers, write transactions will fail")
+	}
+	if readers == 0 {
+		warnings = append(warnings, "the routing table has no readers, read transactions will fail")
+	}
+	if t.Clustered && t.DatabaseName != "" {
+		hasSecondary := false
+		for _, member := range t.Members {
+			if member.Roles[t.DatabaseName] == "READ_REPLICA" {
+				hasSecondary = true
+			}
+		}
+		if !hasSecondary {
+			warnings = append(warnings, fmt.Sprintf("no read replicas host %s, reads will be served by the core members", t.DatabaseName))
+		}
+	}
+	return warnings
+}
+
+func (t *Topology) String() string {
+	s := strings.Builder{}
+	databaseName := t.DatabaseName
+	if databaseName == "" {
+		databaseName = "<default>"
+	}
+	if t.Clustered {
+		s.WriteString(fmt.Sprintf("Cluster topology for database %s:\n", databaseName))
+	} else {
+		s.WriteString(fmt.Sprintf("Standalone server, routing for database %s:\n", databaseName))
+	}
+
+	rows := [][]string{{"address", "role", "routing", "reachable"}}
+	for _, member := range t.Members {
+		reachable := "no"
+		if member.Reachable {
+			reachable = fmt.Sprintf("yes (%s)", member.RoundTrip.Round(time.Microsecond))
+		}
+		rows = append(rows, []string{member.BoltAddress, member.describeRole(t.DatabaseName), strings.Join(member.RoutingRoles, ","), reachable})
+	}
+	writeTable(rows, &s)
+
+	for _, warning := range t.Warnings() {
+		s.WriteString(fmt.Sprintf("WARNING: %s\n", warning))
+	}
+	return s.String()
+}
+
+func (m *ClusterMember) describeRole(databaseName string) string {
+	if len(m.Roles) == 0 {
+		return "-"
+	}
+	if role, found := m.Roles[databaseName]; found {
+		return role
+	}
+	// Default database, or the member doesn't host the target database; list them all
+	dbs := make([]string, 0, len(m.Roles))
+	for db := range m.Roles {
+		dbs = append(dbs, db)
+	}
+	sort.Strings(dbs)
+	roles := make([]string, 0, len(dbs))
+	for _, db := range dbs {
+		roles = append(roles, fmt.Sprintf("%s:%s", db, m.Roles[db]))
+	}
+	return strings.Join(roles, " ")
+}
@@ -0,0 +1,59 @@
+package neobench
+
+import (
+	"fmt"
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestBuildTopology(t *testing.T) {
+	overview := []map[string]interface{}{
+		{"id": "a", "addresses": []interface{}{"bolt://core1:7687", "http://core1:7474"},
+			"databases": map[string]interface{}{"neo4j": "LEADER", "system": "FOLLOWER"}},
+		{"id": "b", "addresses": []interface{}{"bolt://core2:7687"},
+			"databases": map[string]interface{}{"neo4j": "FOLLOWER", "system": "LEADER"}},
+	}
+	routing := []map[string]interface{}{
+		{"role": "WRITE", "addresses": []interface{}{"core1:7687"}},
+		{"role": "READ", "addresses": []interface{}{"core2:7687"}},
+		{"role": "ROUTE", "addresses": []interface{}{"core1:7687", "core2:7687"}},
+	}
+
+	topology := buildTopology("neo4j", overview, routing)
+	topology.CheckReachability(func(address string) error {
+		if address == "core2:7687" {
+			return fmt.Errorf("connection refused")
+		}
+		return nil
+	})
+
+	assert.True(t, topology.Clustered)
+	assert.Len(t, topology.Members, 2)
+	assert.Equal(t, "core1:7687", topology.Members[0].BoltAddress)
+	assert.Equal(t, []string{"WRITE", "ROUTE"}, topology.Members[0].RoutingRoles)
+	assert.Equal(t, "LEADER", topology.Members[0].describeRole("neo4j"))
+	assert.Equal(t, "neo4j:LEADER system:FOLLOWER", topology.Members[0].describeRole(""))
+	assert.True(t, topology.Members[0].Reachable)
+	assert.False(t, topology.Members[1].Reachable)
+	assert.Equal(t, []string{
+		"core2:7687 is not reachable: connection refused",
+		"no read replicas host neo4j, reads will be served by the core members",
+	}, topology.Warnings())
+}
+
+func TestBuildStandaloneTopology(t *testing.T) {
+	routing := []map[string]interface{}{
+		{"role": "WRITE", "addresses": []interface{}{"localhost:7687"}},
+		{"role": "READ", "addresses": []interface{}{"localhost:7687"}},
+		{"role": "ROUTE", "addresses": []interface{}{"localhost:7687"}},
+	}
+
+	topology := buildTopology("", nil, routing)
+	topology.CheckReachability(func(address string) error { return nil })
+
+	assert.False(t, topology.Clustered)
+	assert.Len(t, topology.Members, 1)
+	assert.Equal(t, []string{"WRITE", "READ", "ROUTE"}, topology.Members[0].RoutingRoles)
+	assert.Empty(t, topology.Warnings())
+	assert.Contains(t, topology.String(), "Standalone server, routing for database <default>:")
+}
@@ -0,0 +1,179 @@
+package neobench
+
+import (
+	"fmt"
+	"io"
+	"math"
+	"strings"
+	"time"
+)
+
+// How far back the dashboard's rolling numbers and sparkline reach
+const tuiWindow = time.Minute
+
+// Characters for the sparkline, from lowest to highest
+var sparkBlocks = []rune("▁▂▃▄▅▆▇█")
+
+// Redraws a dashboard on the terminal at each progress report, see --output tui. The final results are
+// reported as by InteractiveOutput, below the last frame of the dashboard.
+type TuiOutput struct {
+	InteractiveOutput
+
+	databaseName string
+	url          string
+	scenario     string
+	start        time.Time
+	// Progress checkpoints covering the tuiWindow up to the most recent one
+	window []tuiCheckpoint
+	// Shown at the bottom of the dashboard until the run ends
+	lastError string
+
+	now func() time.Time
+}
+
+// Covers the interval from since to time
+type tuiCheckpoint struct {
+	since        time.Time
+	time         time.Time
+	completeness float64
+	result       Result
+}
+
+func NewTuiOutput(errStream, outStream io.Writer) *TuiOutput {
+	return &TuiOutput{
+		InteractiveOutput: InteractiveOutput{
+			ErrStream: errStream,
+			OutStream: outStream,
+		},
+		now: time.Now,
+	}
+}
+
+func (o *TuiOutput) BenchmarkStart(databaseName, url, scenario string) {
+	if databaseName == "" {
+		databaseName = "<default>"
+	}
+	o.databaseName = databaseName
+	o.url = url
+	o.scenario = scenario
+	o.start = o.now()
+	o.window = nil
+	o.lastError = ""
+	o.redraw()
+}
+
+func (o *TuiOutput) ReportWorkloadProgress(completeness float64, checkpoint Result) {
+	now := o.now()
+	since := o.start
+	if len(o.window) > 0 {
+		since = o.window[len(o.window)-1].time
+	}
+	o.window = append(o.window, tuiCheckpoint{since: since, time: now, completeness: completeness, result: checkpoint})
+	for len(o.window) > 0 && now.Sub(o.window[0].since) > tuiWindow {
+		o.window = o.window[1:]
+	}
+	o.redraw()
+}
+
+func (o *TuiOutput) Errorf(format string, a ...interface{}) {
+	o.lastError = fmt.Sprintf(format, a...)
+	o.InteractiveOutput.Errorf(format, a...)
+}
+
+func (o *TuiOutput) redraw() {
+	// Clear the screen and move to the top left, then draw the frame from scratch
+	_, err := fmt.Fprint(o.ErrStream, "\x1b[H\x1b[2J"+o.frame())
+	if err != nil {
+		panic(err)
+	}
+}
+
+func (o *TuiOutput) frame() string {
+	s := strings.Builder{}
+	s.WriteString(fmt.Sprintf("neobench on database %s against %s\n", o.databaseName, o.url))
+	s.WriteString(fmt.Sprintf("Scenario: %s\n", o.scenario))
+	writeTagsLine(o.Tags, &s)
+
+	if len(o.window) == 0 {
+		s.WriteString("\nWaiting for the first progress report..\n")
+		return s.String()
+	}
+	latest := o.window[len(o.window)-1]
+	s.WriteString(fmt.Sprintf("Elapsed: %s, %.1f%% done\n\n", latest.time.Sub(o.start).Round(time.Second), latest.completeness*100))
+
+	// Rolling numbers over the window; each checkpoint covers the interval since the one before it
+	rolling := NewResult(latest.result.DatabaseName, latest.result.Scenario)
+	for _, c := range o.window {
+		for _, script := range c.result.Scripts {
+			combined := rolling.Scripts[script.ScriptName]
+			if combined == nil {
+				combined = &ScriptResult{ScriptName: script.ScriptName, Latencies: newLatencyHistogram()}
+				rolling.Scripts[script.ScriptName] = combined
+			}
+			combined.Succeeded += script.Succeeded
+			combined.Failed += script.Failed
+			if err := mergeHistograms(combined.Latencies, script.Latencies); err != nil {
+				panic(err)
+			}
+		}
+	}
+	windowLength := latest.time.Sub(o.window[0].since)
+	if windowLength <= 0 {
+		windowLength = time.Second
+	}
+
+	s.WriteString(fmt.Sprintf("Last %s:\n", windowLength.Round(time.Second)))
+	rows := [][]string{{"script", "tps (now)", "tps", "p50(ms)", "p95(ms)", "p99(ms)", "errors", "error rate"}}
+	for _, script := range sortedScripts(rolling) {
+		current := 0.0
+		if s, found := latest.result.Scripts[script.ScriptName]; found {
+			current = s.Rate
+		}
+		total := script.Succeeded + script.Failed
+		errorRate := 0.0
+		if total > 0 {
+			errorRate = float64(script.Failed) / float64(total)
+		}
+		rows = append(rows, []string{
+			script.ScriptName,
+			fmt.Sprintf("%.1f", current),
+			fmt.Sprintf("%.1f", float64(total)/windowLength.Seconds()),
+			formatQuantile(script, 50),
+			formatQuantile(script, 95),
+			formatQuantile(script, 99),
+			fmt.Sprintf("%d", script.Failed),
+			fmt.Sprintf("%.2f%%", errorRate*100),
+		})
+	}
+	writeTable(rows, &s)
+
+	rates := make([]float64, 0, len(o.window))
+	for _, c := range o.window {
+		rates = append(rates, c.result.TotalRate())
+	}
+	s.WriteString(fmt.Sprintf("\n  tps %s %.1f\n", sparkline(rates), latest.result.TotalRate()))
+
+	if o.lastError != "" {
+		s.WriteString(fmt.Sprintf("\nLast error: %s\n", o.lastError))
+	}
+	return s.String()
+}
+
+// Renders values as a row of block characters, scaled so the highest value is a full block
+func sparkline(values []float64) string {
+	max := 0.0
+	for _, v := range values {
+		max = math.Max(max, v)
+	}
+	s := strings.Builder{}
+	for _, v := range values {
+		i := 0
+		if max > 0 {
+			i = int(math.Round(v / max * float64(len(sparkBlocks)-1)))
+		}
+		s.WriteRune(sparkBlocks[i])
+	}
+	return s.String()
+}
+
+var _ Output = &TuiOutput{}
@@ -0,0 +1,55 @@
+package neobench
+
+import (
+	"bytes"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTuiShowsRollingNumbersOverTheLastMinute(t *testing.T) {
+	clock := time.Date(2020, 1, 1, 1, 1, 1, 0, time.UTC)
+	errStream := &bytes.Buffer{}
+	o := NewTuiOutput(errStream, &bytes.Buffer{})
+	o.now = func() time.Time { return clock }
+	o.BenchmarkStart("", "neo4j://localhost:7687", "-c 1")
+
+	// 90 one-second intervals; the first 30 are slow and fall out of the window
+	for i := 0; i < 90; i++ {
+		latency := time.Millisecond
+		if i < 30 {
+			latency = 100 * time.Millisecond
+		}
+		w := NewWorkerResult(0)
+		assert.NoError(t, w.record("s", latency, uowOutcome{succeeded: true}))
+		if i == 89 {
+			assert.NoError(t, w.record("s", latency, uowOutcome{succeeded: false, failureGroup: "unknown", err: os.ErrClosed}))
+		}
+		w.calculateRate(time.Second)
+		checkpoint := NewResult("", "")
+		assert.NoError(t, checkpoint.Add(w))
+		clock = clock.Add(time.Second)
+		o.ReportWorkloadProgress(float64(i+1)/90, checkpoint)
+	}
+
+	frames := strings.Split(errStream.String(), "\x1b[H\x1b[2J")
+	frame := frames[len(frames)-1]
+	assert.Contains(t, frame, "Elapsed: 1m30s, 100.0% done")
+	assert.Contains(t, frame, "Last 1m0s:")
+	assert.Regexp(t, `s\s+2\.0\s+1\.0\s+1\.000\s+1\.000\s+1\.000\s+1\s+1\.64%`, frame)
+	assert.Contains(t, frame, "tps ▅▅▅▅▅▅▅▅▅▅▅▅▅▅▅▅▅▅▅▅▅▅▅▅▅▅▅▅▅▅▅▅▅▅▅▅▅▅▅▅▅▅▅▅▅▅▅▅▅▅▅▅▅▅▅▅▅▅▅█ 2.0")
+}
+
+func TestTuiShowsLastError(t *testing.T) {
+	errStream := &bytes.Buffer{}
+	o := NewTuiOutput(errStream, &bytes.Buffer{})
+	o.BenchmarkStart("", "", "")
+	o.Errorf("connection %s", "refused")
+	o.ReportWorkloadProgress(0.5, NewResult("", ""))
+
+	frames := strings.Split(errStream.String(), "\x1b[H\x1b[2J")
+	assert.Contains(t, frames[len(frames)-1], "Last error: connection refused")
+}
@@ -0,0 +1,121 @@
+package neobench
+
+import (
+	"bufio"
+	"compress/gzip"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+var transactionLogColumns = []string{"scenario", "start", "worker", "script", "latency_ms", "outcome", "failure_group", "retried"}
+
+// Writes a CSV row for every transaction of a run, see --transaction-log, for analysis the histograms don't
+// allow. Start is when the transaction was scheduled to start, which the latency is measured from, see
+// Worker.RunBenchmark; outcome is succeeded or failed, and failed ones have the group of their error. Shared by
+// all workers of a run, so rows are in the order transactions completed rather than by start.
+//
+// If the path ends in .gz, each run is written as a gzip member of its own, so the file can be decompressed
+// as soon as a run completes, even if a later run is interrupted.
+type TransactionLog struct {
+	mut sync.Mutex
+
+	file     io.Writer
+	compress bool
+	// Set between RunStart and RunEnd
+	gzip     *gzip.Writer
+	out      *bufio.Writer
+	scenario string
+	// The first write that failed in the current run; reported by RunEnd rather than failing the benchmark
+	err         error
+	wroteHeader bool
+}
+
+func NewTransactionLog(path string) (*TransactionLog, error) {
+	file, err := os.Create(path)
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to open --transaction-log file")
+	}
+	return newTransactionLog(file, strings.HasSuffix(path, ".gz")), nil
+}
+
+func newTransactionLog(file io.Writer, compress bool) *TransactionLog {
+	return &TransactionLog{file: file, compress: compress}
+}
+
+// Called as each run starts, before any worker does
+func (l *TransactionLog) RunStart(scenario string) {
+	l.mut.Lock()
+	defer l.mut.Unlock()
+	l.scenario = scenario
+	l.err = nil
+	if l.compress {
+		l.gzip = gzip.NewWriter(l.file)
+		l.out = bufio.NewWriter(l.gzip)
+	} else {
+		l.out = bufio.NewWriter(l.file)
+	}
+	if !l.wroteHeader {
+		l.write(strings.Join(transactionLogColumns, ","))
+		l.wroteHeader = true
+	}
+}
+
+func (l *TransactionLog) record(workerId int64, scriptName string, start time.Time, latency time.Duration, outcome uowOutcome) {
+	l.mut.Lock()
+	defer l.mut.Unlock()
+	if l.out == nil {
+		return
+	}
+	result, group := "succeeded", ""
+	if !outcome.succeeded {
+		result, group = "failed", csvQuote(outcome.failureGroup)
+	}
+	l.write(strings.Join([]string{
+		csvQuote(l.scenario),
+		start.UTC().Format(time.RFC3339Nano),
+		fmt.Sprintf("%d", workerId),
+		csvQuote(scriptName),
+		fmtFloat(float64(latency.Microseconds()) / 1000.0),
+		result,
+		group,
+		fmt.Sprintf("%d", outcome.retried),
+	}, ","))
+}
+
+func (l *TransactionLog) write(row string) {
+	if l.err != nil {
+		return
+	}
+	if _, err := l.out.WriteString(row + "\n"); err != nil {
+		l.err = err
+	}
+}
+
+// Called once all workers of the run are done; writes out what's buffered, and returns the first error
+// writing the run's rows, if any
+func (l *TransactionLog) RunEnd() error {
+	l.mut.Lock()
+	defer l.mut.Unlock()
+	if l.out == nil {
+		return nil
+	}
+	if err := l.out.Flush(); err != nil && l.err == nil {
+		l.err = err
+	}
+	if l.gzip != nil {
+		if err := l.gzip.Close(); err != nil && l.err == nil {
+			l.err = err
+		}
+	}
+	l.out, l.gzip = nil, nil
+	if l.err != nil {
+		return errors.Wrapf(l.err, "failed to write --transaction-log")
+	}
+	return nil
+}
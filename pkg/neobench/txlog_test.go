@@ -0,0 +1,51 @@
+package neobench
+
+import (
+	"bytes"
+	"compress/gzip"
+	"io/ioutil"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTransactionLogWritesEveryTransaction(t *testing.T) {
+	out := &bytes.Buffer{}
+	log := newTransactionLog(out, false)
+	recorder := NewResultRecorder(3, time.Time{})
+	recorder.EnableTransactionLog(log)
+	start := time.Date(2020, 1, 1, 1, 1, 1, 500000000, time.UTC)
+
+	log.RunStart("-l -r 10")
+	assert.NoError(t, recorder.record("a", start, 1500*time.Microsecond, uowOutcome{succeeded: true, retried: 1}))
+	assert.NoError(t, recorder.record("b", start.Add(time.Second), time.Millisecond, uowOutcome{succeeded: false, failureGroup: "Neo.TransientError", err: os.ErrClosed}))
+	assert.NoError(t, log.RunEnd())
+
+	assert.Equal(t, "scenario,start,worker,script,latency_ms,outcome,failure_group,retried\n"+
+		"\"-l -r 10\",2020-01-01T01:01:01.5Z,3,\"a\",1.500,succeeded,,1\n"+
+		"\"-l -r 10\",2020-01-01T01:01:02.5Z,3,\"b\",1.000,failed,\"Neo.TransientError\",0\n", out.String())
+}
+
+func TestGzippedTransactionLogHasAMemberPerRun(t *testing.T) {
+	out := &bytes.Buffer{}
+	log := newTransactionLog(out, true)
+	recorder := NewResultRecorder(0, time.Time{})
+	recorder.EnableTransactionLog(log)
+	start := time.Date(2020, 1, 1, 1, 1, 1, 0, time.UTC)
+
+	for _, scenario := range []string{"-c 1", "-c 2"} {
+		log.RunStart(scenario)
+		assert.NoError(t, recorder.record("a", start, time.Millisecond, uowOutcome{succeeded: true}))
+		assert.NoError(t, log.RunEnd())
+	}
+
+	reader, err := gzip.NewReader(out)
+	assert.NoError(t, err)
+	content, err := ioutil.ReadAll(reader)
+	assert.NoError(t, err)
+	assert.Equal(t, "scenario,start,worker,script,latency_ms,outcome,failure_group,retried\n"+
+		"\"-c 1\",2020-01-01T01:01:01Z,0,\"a\",1.000,succeeded,,0\n"+
+		"\"-c 2\",2020-01-01T01:01:01Z,0,\"a\",1.000,succeeded,,0\n", string(content))
+}
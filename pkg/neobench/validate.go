@@ -0,0 +1,147 @@
+package neobench
+
+import (
+	"os"
+	"sort"
+)
+
+// What neobench validate found in a script that parses, without running it or connecting to a database
+type Validation struct {
+	// Every variable the script and its :init use, in expressions, queries and :shell lines, sorted
+	Variables []string
+	// The variables that are used before anything defines them, sorted: neither the variables given, the built-in
+	// ones, :set, :for, :params, :gset nor :setshell. Evaluating the script fails on these, other than in queries,
+	// where they are sent as null, or fail with --strict-params
+	Undefined []string
+}
+
+// Checks which variables the script uses, and whether they are defined where they are used, given the variables
+// set on the command line; the :init is checked as a script of its own, since it's evaluated on its own
+func (s *Script) Validate(vars map[string]interface{}) Validation {
+	used := make(map[string]bool)
+	undefined := make(map[string]bool)
+	for _, commands := range [][]Command{s.initCommands(), s.Commands} {
+		walkVarUses(commands, createVars(vars, 0), func(name string, defined, param bool) {
+			used[name] = true
+			if !defined {
+				undefined[name] = true
+			}
+		})
+	}
+	return Validation{Variables: sortedNames(used), Undefined: sortedNames(undefined)}
+}
+
+// Calls use with each variable the commands read, in the order they run, with whether anything defined it before,
+// counting the variables in defined, which it adds to as it goes, and whether it's a query parameter, rather than
+// read by neobench itself. A variable set in any branch of an :if counts as defined after it.
+func walkVarUses(commands []Command, defined map[string]interface{}, use func(name string, defined, param bool)) {
+	isDefined := func(name string) bool {
+		_, found := defined[name]
+		return found
+	}
+	read := func(name string) {
+		use(name, isDefined(name), false)
+	}
+	for _, cmd := range commands {
+		switch cmd := cmd.(type) {
+		case SetCommand:
+			cmd.Expression.visitVars(nil, read)
+			defined[cmd.VarName] = true
+		case QueryCommand:
+			for _, params := range [][]string{cmd.RemoteParams, cmd.LocalParams} {
+				for _, name := range params {
+					use(name, isDefined(name), true)
+				}
+			}
+			for _, assertion := range cmd.Assertions {
+				assertion.Expr.visitVars(map[string]bool{RowCountVar: true, RowsVar: true, RowVar: true}, read)
+			}
+			for _, column := range cmd.Gset {
+				defined[column] = true
+			}
+		case IfCommand:
+			for _, branch := range cmd.Branches {
+				branch.Condition.visitVars(nil, read)
+				walkVarUses(branch.Commands, defined, use)
+			}
+			walkVarUses(cmd.Else, defined, use)
+		case ForCommand:
+			cmd.Items.visitVars(nil, read)
+			defined[cmd.VarName] = true
+			walkVarUses(cmd.Commands, defined, use)
+		case ParamsCommand:
+			for _, column := range cmd.File.Columns {
+				defined[column] = true
+			}
+		case ShellCommand:
+			for _, name := range shellVarNames(cmd.Line) {
+				// The shell reads the ones that aren't variables of the script from its environment
+				if _, inEnv := os.LookupEnv(name); inEnv && !isDefined(name) {
+					continue
+				}
+				read(name)
+			}
+			if cmd.VarName != "" {
+				defined[cmd.VarName] = true
+			}
+		case SleepCommand:
+			cmd.Duration.visitVars(nil, read)
+		case LockCommand:
+			cmd.Keys.visitVars(nil, read)
+		}
+	}
+}
+
+func (s *Script) initCommands() []Command {
+	if s.Init == nil {
+		return nil
+	}
+	return s.Init.Commands
+}
+
+// Calls visit with each variable the expression reads, other than the ones bound, like the items of list
+// comprehensions it's in
+func (e Expression) visitVars(bound map[string]bool, visit func(name string)) {
+	switch e.Kind {
+	case varExpr:
+		if name := e.Payload.(string); !bound[name] {
+			visit(name)
+		}
+	case listExpr:
+		for _, item := range e.Payload.([]Expression) {
+			item.visitVars(bound, visit)
+		}
+	case mapExpr:
+		for _, value := range e.Payload.(map[string]Expression) {
+			value.visitVars(bound, visit)
+		}
+	case sliceExpr:
+		slice := e.Payload.(SliceExpr)
+		slice.src.visitVars(bound, visit)
+		slice.i.visitVars(bound, visit)
+	case listCompExpr:
+		comprehension := e.Payload.(ListCompExpr)
+		comprehension.src.visitVars(bound, visit)
+		inner := map[string]bool{comprehension.itemName: true}
+		for name := range bound {
+			inner[name] = true
+		}
+		if comprehension.filter != nil {
+			comprehension.filter.visitVars(inner, visit)
+		}
+		comprehension.out.visitVars(inner, visit)
+	case callExpr:
+		for _, arg := range e.Payload.(CallExpr).args {
+			arg.visitVars(bound, visit)
+		}
+	}
+}
+
+func sortedNames(names map[string]bool) []string {
+	out := make([]string, 0, len(names))
+	for name := range names {
+		out = append(out, name)
+	}
+	sort.Strings(out)
+	return out
+}
@@ -0,0 +1,44 @@
+package neobench
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestValidate(t *testing.T) {
+	dir, err := ioutil.TempDir("", "validate")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+	assert.NoError(t, ioutil.WriteFile(filepath.Join(dir, "accounts.csv"), []byte("aid\n1\n"), 0644))
+
+	for _, tc := range []struct {
+		script    string
+		variables []string
+		undefined []string
+	}{
+		{"MATCH (a {aid: $aid}) RETURN a;", []string{"aid"}, []string{"aid"}},
+		{":set aid random(1, 100000 * $scale)\nMATCH (a {aid: $aid}) RETURN a;", []string{"aid", "scale"}, []string{}},
+		// Used before it's set
+		{":set a $b\n:set b 1\nRETURN $a;", []string{"a", "b"}, []string{"b"}},
+		{":set x [i in range(1, 3) WHERE $i > $min | {id: $i, at: $now}]\nRETURN $x;", []string{"min", "now", "x"}, []string{"now"}},
+		{":for i in range(1, $n)\nCREATE ({id: $i});\n:endfor", []string{"i", "n"}, []string{}},
+		{":if $flag\n:set a 1\n:else\n:sleep $pause ms\n:endif\nRETURN $a;", []string{"a", "flag", "pause"}, []string{"pause"}},
+		{":params \"accounts.csv\"\nMATCH (a {aid: $aid}) RETURN a;", []string{"aid"}, []string{}},
+		{"RETURN 1 AS balance;\n:gset balance\n:assert $rowcount = 1 AND $row[\"aid\"] = $aid\n:set b $balance", []string{"aid", "balance"}, []string{"aid"}},
+		{":lock Account.aid $ids\nRETURN 1;", []string{"ids"}, []string{"ids"}},
+		{":setshell who echo $user\nRETURN $who;", []string{"user", "who"}, []string{"user"}},
+		// The :init doesn't see what the script sets, and the other way around
+		{":init\n:set seeded 1\nCREATE ({n: $seeded, at: $aid});\n:endinit\n:set aid 1\nRETURN $seeded;", []string{"aid", "seeded"}, []string{"aid", "seeded"}},
+		{"RETURN $" + ClientIdVar + ", $n;", []string{ClientIdVar, "n"}, []string{}},
+	} {
+		script, err := Parse(filepath.Join(dir, "main.script"), tc.script, 1)
+		assert.NoError(t, err, tc.script)
+		validation := script.Validate(map[string]interface{}{"scale": int64(1), "n": int64(10), "min": int64(1), "flag": true})
+		assert.Equal(t, tc.variables, validation.Variables, tc.script)
+		assert.Equal(t, tc.undefined, validation.Undefined, tc.script)
+	}
+}
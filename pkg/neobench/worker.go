@@ -14,7 +14,30 @@ type Worker struct {
 	workerId int64
 	driver   neo4j.Driver
 	now      func() time.Time
-	sleep    func(duration time.Duration)
+	// Sleeps for the duration, or until stopCh closes, in which case it returns false, see sleepUnlessStopped
+	sleep func(duration time.Duration, stopCh <-chan struct{}) bool
+	log   *Logger
+	// Time each statement as well as each unit of work, see --statement-latencies
+	trackStatements bool
+	// How long RunBenchmark waits before it starts, see StartAfter
+	startDelay time.Duration
+	// If set, the transaction rate RunBenchmark is given is an upper bound rather than a schedule, see CapRate
+	rateCap bool
+	// RunBenchmark holds off between transactions while any of these is closed, see UseGate
+	gates []*Gate
+	// If set, transactions are scheduled by this rather than the transaction rate, see FollowProfile
+	profile      *LoadProfile
+	profileShare float64
+	// If set, transactions start when this says, rather than at the transaction rate, see ShareSchedule
+	schedule *Schedule
+	// Pauses between transactions in throughput mode, drawn from thinkRand, see ThinkBetween
+	thinkTime ThinkTime
+	thinkRand *rand.Rand
+	// Open a session for each transaction rather than one for the whole run, see SessionPerTransaction
+	sessionPerTransaction bool
+	// Draws the backoff of :retry; apart from the workload's random numbers, so retrying doesn't change the
+	// parameters it generates
+	retryRand *rand.Rand
 }
 
 // transactionRate is Time between transactions; this defines the workload rate
@@ -24,50 +47,157 @@ type Worker struct {
 //
 // If transactionRate is 0, we go as fast as we can, this is used to measure throughput
 // If numTransactions is 0, we go until stopCh tells us to stop
+// If budget is set, we also stop once it is exhausted, and add what we write to it; pass nil for no budget
 func (w *Worker) RunBenchmark(wrk ClientWorkload, databaseName string, transactionRate time.Duration,
-	numTransactions uint64, stopCh <-chan struct{}, recorder *ResultRecorder) WorkerResult {
-	session := w.driver.NewSession(neo4j.SessionConfig{
-		AccessMode:   neo4j.AccessModeWrite,
-		DatabaseName: databaseName,
-		Bookmarks:    nil,
-		FetchSize:    neo4j.FetchAll,
-	})
-	defer session.Close()
+	numTransactions uint64, stopCh <-chan struct{}, budget *WriteBudget, recorder *ResultRecorder) WorkerResult {
+	if w.startDelay > 0 {
+		w.log.Debugf("worker %d: starting in %s", w.workerId, w.startDelay)
+		delay := time.NewTimer(w.startDelay)
+		select {
+		case <-stopCh:
+			delay.Stop()
+			w.log.Debugf("worker %d: stopping, asked to stop before it started", w.workerId)
+			return recorder.Complete(w.now())
+		case <-delay.C:
+		}
+	}
+
+	// Unless the worker opens a session per transaction, it runs all of them in this one, or, for scripts with
+	// :opt discard or :mode read, in one of its own for each fetch size and access mode they need
+	sessions := make(map[sessionConfig]neo4j.Session)
+	if !w.sessionPerTransaction {
+		config := sessionConfig{fetchSize: neo4j.FetchAll}
+		sessions[config] = w.newSession(databaseName, config)
+	}
+	defer func() {
+		for _, session := range sessions {
+			session.Close()
+		}
+	}()
+
+	w.log.Debugf("worker %d: starting on database '%s'", w.workerId, databaseName)
 
 	workStartTime := w.now()
-	recorder.totalStart = workStartTime
-	recorder.currentStart = workStartTime
+	recorder.start(workStartTime)
 
 	nextStart := workStartTime
+	if w.schedule != nil {
+		w.schedule.Join(workStartTime)
+		nextStart = w.waitForSchedule(stopCh)
+	} else if w.profile != nil {
+		var ok bool
+		if nextStart, ok = w.waitForProfile(workStartTime, nextStart, stopCh); !ok {
+			w.log.Debugf("worker %d: stopping, the load profile offers nothing or asked to stop", w.workerId)
+			return recorder.Complete(w.now())
+		}
+	}
 
 	transactionCounter := uint64(0)
 
 	for {
 		select {
 		case <-stopCh:
+			w.log.Debugf("worker %d: stopping, asked to stop after %d transactions", w.workerId, transactionCounter)
+			return recorder.Complete(w.now())
+		case <-budget.Exhausted():
+			w.log.Debugf("worker %d: stopping, write budget spent after %d transactions", w.workerId, transactionCounter)
 			return recorder.Complete(w.now())
 		default:
 		}
 
+		for _, gate := range w.gates {
+			if !gate.IsClosed() {
+				continue
+			}
+			w.log.Debugf("worker %d: holding off, the gate is closed", w.workerId)
+			if !gate.Wait(stopCh) {
+				w.log.Debugf("worker %d: stopping, asked to stop after %d transactions", w.workerId, transactionCounter)
+				return recorder.Complete(w.now())
+			}
+			// Nothing was offered while the gate was closed, so the schedule picks up from now rather than
+			// counting the idle time towards the latency of the transactions after it
+			nextStart = w.now()
+			if w.schedule != nil {
+				w.schedule.Skip(nextStart)
+			}
+		}
+
 		uow, err := wrk.Next(w.workerId)
+		if err == ErrParamsExhausted {
+			w.log.Debugf("worker %d: stopping, params file exhausted after %d transactions", w.workerId, transactionCounter)
+			return recorder.Complete(w.now())
+		}
 		if err != nil {
+			w.log.Debugf("worker %d: crashed evaluating script: %s", w.workerId, err)
 			return WorkerResult{WorkerId: w.workerId, Error: err}
 		}
 
-		outcome := w.runUnit(session, uow)
+		// Used for scripts with :opt discard. The driver fetches records in batches of the session fetch size,
+		// and when a result is consumed with more records left on the server, it tells the server to discard
+		// them rather than send them. With a fetch size of 1, queries that return a row or nothing at all, like
+		// most writes, complete in one round trip as before, while larger results are never sent over the network.
+		config := sessionConfig{fetchSize: neo4j.FetchAll, accessMode: uow.AccessMode}
+		if uow.DiscardResults {
+			config.fetchSize = 1
+		}
+		unitSession := sessions[config]
+		if unitSession == nil && !w.sessionPerTransaction {
+			unitSession = w.newSession(databaseName, config)
+			sessions[config] = unitSession
+		}
+
+		recorder.begin()
+		actualStart := w.now()
+		if w.sessionPerTransaction {
+			// Opening and closing the session is part of the transaction, so its latency includes them
+			unitSession = w.newSession(databaseName, config)
+		}
+		outcome := w.runUnit(unitSession, uow, stopCh)
+		if w.sessionPerTransaction {
+			if err := unitSession.Close(); err != nil {
+				w.log.Debugf("worker %d: failed to close session: %s", w.workerId, err)
+			}
+		}
 
 		uowLatency := w.now().Sub(nextStart)
+		if actualStart.After(nextStart) {
+			outcome.startedLate = actualStart.Sub(nextStart)
+		}
 
-		if err = recorder.record(uow.ScriptName, uowLatency, outcome); err != nil {
+		if err = recorder.record(uow.ScriptName, nextStart, uowLatency, outcome); err != nil {
 			return WorkerResult{WorkerId: w.workerId, Error: err}
 		}
+		if outcome.succeeded {
+			budget.Spend(outcome.written)
+		}
 
 		transactionCounter++
 		if numTransactions != 0 && transactionCounter >= numTransactions {
+			w.log.Debugf("worker %d: stopping, completed %d transactions", w.workerId, transactionCounter)
 			return recorder.Complete(w.now())
 		}
 
-		if transactionRate > 0 {
+		if w.schedule != nil {
+			// Same as with a transaction rate, the schedule is independent of how quickly the database responds
+			nextStart = w.waitForSchedule(stopCh)
+		} else if w.profile != nil {
+			// Same as with a transaction rate, the schedule is independent of how quickly the database responds
+			var ok bool
+			if nextStart, ok = w.waitForProfile(workStartTime, nextStart, stopCh); !ok {
+				w.log.Debugf("worker %d: stopping, load profile ended or asked to stop after %d transactions",
+					w.workerId, transactionCounter)
+				return recorder.Complete(w.now())
+			}
+		} else if transactionRate > 0 && w.rateCap {
+			// A cap rather than a schedule: we wait out the rest of the slot, but never catch up on slots the
+			// database made us miss, so this is still closed-loop, just slower, and the latencies are from
+			// when each transaction actually started, the same as without a rate
+			w.think(stopCh)
+			if elapsed := w.now().Sub(nextStart); elapsed < transactionRate {
+				w.sleep(transactionRate-elapsed, stopCh)
+			}
+			nextStart = w.now()
+		} else if transactionRate > 0 {
 			// Note something critical here: We don't add the actual time the unit took,
 			// we add the *max* time it *should* have taken. This means that if the database
 			// is not keeping up with the workload, nextStart will drift further and further
@@ -79,19 +209,69 @@ func (w *Worker) RunBenchmark(wrk ClientWorkload, databaseName string, transacti
 			// then the latency numbers will grow extremely large, showing the actual wait time
 			// real users would see from when they ask the system to do something to when they get service.
 			if uowLatency < transactionRate {
-				w.sleep(transactionRate - uowLatency)
+				w.sleep(transactionRate-uowLatency, stopCh)
 			}
 			nextStart = nextStart.Add(transactionRate)
 		} else {
 			// No rate limit set, so just track when each transaction started; this effectively
 			// makes us coordinate with the database such that our workload rate exactly matches
-			// the databases ability to process - eg. this measures throughput, but makes the
-			// latencies useless
-			nextStart = time.Now()
+			// the databases ability to process - eg. this measures throughput, and the latencies
+			// are closed-loop: they leave out the queueing an independent arrival rate would cause
+			w.think(stopCh)
+			nextStart = w.now()
 		}
 	}
 }
 
+// What sessions of the worker differ in
+type sessionConfig struct {
+	fetchSize  int
+	accessMode neo4j.AccessMode
+}
+
+func (w *Worker) newSession(databaseName string, config sessionConfig) neo4j.Session {
+	return w.driver.NewSession(neo4j.SessionConfig{
+		AccessMode:   config.accessMode,
+		DatabaseName: databaseName,
+		FetchSize:    config.fetchSize,
+	})
+}
+
+// Sleeps until the start of the next transaction the schedule hands this worker, or until stopCh closes
+func (w *Worker) waitForSchedule(stopCh <-chan struct{}) time.Time {
+	nextStart := w.schedule.Next()
+	if wait := nextStart.Sub(w.now()); wait > 0 {
+		w.sleep(wait, stopCh)
+	}
+	return nextStart
+}
+
+// Sleeps until the start of the next transaction the load profile offers after last; false if it offers no more,
+// or if stopCh closed first, as an idle segment of the profile can be longer than the run
+func (w *Worker) waitForProfile(workStartTime, last time.Time, stopCh <-chan struct{}) (time.Time, bool) {
+	next, ok := w.profile.Next(last.Sub(workStartTime), w.profileShare)
+	if !ok {
+		return last, false
+	}
+	nextStart := workStartTime.Add(next)
+	if wait := nextStart.Sub(w.now()); wait > 0 && !w.sleep(wait, stopCh) {
+		return last, false
+	}
+	return nextStart, true
+}
+
+// Sleeps for the duration, or until stopCh closes, in which case it returns false; a nil stopCh never closes
+func sleepUnlessStopped(duration time.Duration, stopCh <-chan struct{}) bool {
+	timer := time.NewTimer(duration)
+	defer timer.Stop()
+	select {
+	case <-stopCh:
+		return false
+	case <-timer.C:
+		return true
+	}
+}
+
 func (w *Worker) gatherResults(workloadStats map[string]*ScriptResult, workStartTime time.Time) []ScriptResult {
 	workloadResults := make([]ScriptResult, 0, len(workloadStats))
 	for _, result := range workloadStats {
@@ -106,46 +286,182 @@ func (w *Worker) gatherResults(workloadStats map[string]*ScriptResult, workStart
 	return workloadResults
 }
 
-func (w *Worker) runUnit(session neo4j.Session, uow UnitOfWork) uowOutcome {
+// Returned from the transaction function of a transaction that ends in :rollback; the driver doesn't retry errors
+// it doesn't know, and rolls back the transaction when it resets the connection
+var errRollback = errors.New("rolled back by :rollback")
+
+// Runs the unit of work on the session; backoffs between retries are cut short, failing the unit, once stopCh
+// closes
+func (w *Worker) runUnit(session neo4j.Session, uow UnitOfWork, stopCh <-chan struct{}) uowOutcome {
+	// What the unit wrote; reset at the start of each attempt to what the transactions before wrote, since the
+	// driver retries transactions
+	written, committed := WriteVolume{}, WriteVolume{}
+
+	// Lock errors from every attempt, including ones the driver went on to retry
+	var lockErrors []string
+
+	// Time from asking the driver for a transaction to it handing us one, see WorkerResult.AcquireLatencies
+	var acquireStart time.Time
+	acquireLatency := time.Duration(0)
+
+	// Time each statement took in the transactions so far and the last attempt of this one, if trackStatements is set
+	var statements []statementTiming
+	done := 0
+
+	// The statement that failed in the last attempt, if it was a statement that failed
+	var failedStatement *Statement
+
+	// Time the server says it spent on the statements of the transactions so far and the last attempt of this one,
+	// see ScriptResult.ServerLatencies
+	server, serverDone := serverTime{}, serverTime{}
+
+	// The transaction being run; the whole unit, unless the script uses :begin, and the config it runs with
+	var current unitTransaction
+	var configure []func(*neo4j.TransactionConfig)
+	// The unit as it was before the transaction, to go back to when the driver retries it, if the evaluation of
+	// the script stopped at a :gset in it, which goes on from there as the transaction runs
+	var saved *UnitOfWork
+
+	// The driver calls the transaction function again when it retries, so count attempts to see retries
+	attempt := 0
+	acquired := false
+	retried := int64(0)
+	// Attempts of auto-commit statements that failed and were tried again, and how many attempts the auto-commit
+	// statements of the unit have left between them
+	autocommitRetried := int64(0)
+	var retries = 20
+	var lastErr error
 	transaction := func(tx neo4j.Transaction) (interface{}, error) {
 		var lastResult neo4j.Result
+		written = committed
+		statements = statements[:done]
+		failedStatement = nil
+		server = serverDone
+		attempt++
+		if !acquired {
+			acquired = true
+			acquireLatency = w.now().Sub(acquireStart)
+		}
+		if attempt > 1 && lastErr != nil {
+			w.log.Debugf("worker %d: retrying %s, attempt %d, after: %s", w.workerId, uow.ScriptName, attempt, lastErr)
+		} else if attempt > 1 {
+			// Failed on commit, which happens outside of this function, so we don't see the error
+			w.log.Debugf("worker %d: retrying %s, attempt %d, after a failed commit", w.workerId, uow.ScriptName, attempt)
+		}
+		lastErr = nil
+		if attempt > 1 && saved != nil {
+			uow.restore(*saved)
+		}
 
-		for _, s := range uow.Statements {
+		// A :gset in the transaction adds the statements after it as it runs, some of which may go in it too
+		for i := current.start; i < len(uow.Statements) && uow.Statements[i].Transaction == current.transaction; i++ {
+			s := uow.Statements[i]
+			start := w.now()
 			res, err := tx.Run(s.Query, s.Params)
 			if err != nil {
+				lastErr = err
+				lockErrors = appendLockError(lockErrors, err)
+				failedStatement = &s
 				return nil, err
 			}
-			_, err = res.(neo4j.Result).Consume()
+			records, summary, err := consume(res, s)
 			if err != nil {
+				lastErr = err
+				lockErrors = appendLockError(lockErrors, err)
+				failedStatement = &s
 				return nil, err
 			}
+			if w.trackStatements {
+				statements = append(statements, statementTiming{statement: s, latency: w.now().Sub(start)})
+			}
+			if err := checkResult(s.Assertions, records); err != nil {
+				// Not an error the driver retries, so the transaction rolls back and fails
+				lastErr = err
+				failedStatement = &s
+				return nil, err
+			}
+			written = addWritten(written, summary, s)
+			server = server.add(summary)
 			lastResult = res
+			if len(s.Gset) > 0 {
+				if err := uow.resume(first(records)); err != nil {
+					lastErr = err
+					failedStatement = &s
+					return nil, err
+				}
+			}
+		}
+		if uow.RolledBack[current.transaction] {
+			return nil, errRollback
 		}
 		return lastResult, nil
 	}
 
 	autocommitTransaction := func(session neo4j.Session) (interface{}, error) {
 		var lastResult neo4j.Result
-		var retries = 20
 		var res interface{}
 		var err error
 
-		for _, s := range uow.Statements {
+		for _, s := range current.statements {
+			s := s
+			var records []*neo4j.Record
 			var retriesThisTime = retries
+			if uow.Retry != nil {
+				retriesThisTime = uow.Retry.Retries + 1
+			}
 			for i := 0; i < retriesThisTime; i++ {
-				res, err = session.Run(s.Query, s.Params)
+				var summary neo4j.ResultSummary
+				start := w.now()
+				res, err = session.Run(s.Query, s.Params, configure...)
 				if err == nil {
-					_, err = res.(neo4j.Result).Consume()
+					records, summary, err = consume(res.(neo4j.Result), s)
 				}
 				if err == nil {
+					if w.trackStatements {
+						statements = append(statements, statementTiming{statement: s, latency: w.now().Sub(start)})
+					}
+					// Each statement commits on its own here, so unlike above, earlier statements stay written
+					written = addWritten(written, summary, s)
+					server = server.add(summary)
 					break
 				}
+				lockErrors = appendLockError(lockErrors, err)
+				if uow.Retry != nil {
+					// Each statement gets the attempts of the policy, and only errors it retries are retried
+					if i == retriesThisTime-1 || !retryable(err) {
+						break
+					}
+					autocommitRetried++
+					backoff := uow.Retry.delay(i+1, w.retryRand)
+					w.log.Debugf("worker %d: auto-commit statement in %s failed, retrying after %s, %d retries left: %s",
+						w.workerId, uow.ScriptName, backoff, retriesThisTime-i-2, err)
+					if !w.sleep(backoff, stopCh) {
+						break
+					}
+					continue
+				}
+				if i < retriesThisTime-1 {
+					autocommitRetried++
+				}
 				jitter := rand.Intn(100)
-				w.sleep(time.Duration(i*10+jitter) * time.Millisecond)
+				backoff := time.Duration(i*10+jitter) * time.Millisecond
+				w.log.Debugf("worker %d: auto-commit statement in %s failed, backing off %s, %d attempts left: %s",
+					w.workerId, uow.ScriptName, backoff, retries-1, err)
+				if !w.sleep(backoff, stopCh) {
+					break
+				}
 				retries = retries - 1
 			}
 
+			if err == nil {
+				// The statement has committed already, but the unit still fails
+				err = checkResult(s.Assertions, records)
+			}
+			if err == nil && len(s.Gset) > 0 {
+				err = uow.resume(first(records))
+			}
 			if err != nil {
+				failedStatement = &s
 				return nil, err
 			}
 
@@ -155,32 +471,164 @@ func (w *Worker) runUnit(session neo4j.Session, uow UnitOfWork) uowOutcome {
 	}
 
 	var err error
-	if uow.Readonly {
-		_, err = session.ReadTransaction(transaction)
-	} else {
-		if uow.Autocommit {
+	acquireStart = w.now()
+	for start := 0; ; {
+		current = uow.transactionAt(start)
+		saved = nil
+		if uow.paused != nil {
+			snapshot := uow.snapshot()
+			saved = &snapshot
+		}
+		attempt = 0
+		configure = nil
+		if current.timeout > 0 {
+			// The server stops the transaction once it runs for longer, and it fails with an error the driver
+			// doesn't retry
+			configure = append(configure, neo4j.WithTxTimeout(current.timeout))
+		}
+		if current.autocommit {
 			_, err = autocommitTransaction(session)
+		} else if uow.Retry != nil {
+			_, err = retryTransaction(session, *uow.Retry, transaction, configure, w.sleep, w.retryRand, stopCh)
+		} else if uow.Readonly {
+			_, err = session.ReadTransaction(transaction, configure...)
 		} else {
-			_, err = session.WriteTransaction(transaction)
+			_, err = session.WriteTransaction(transaction, configure...)
+		}
+		if attempt > 1 {
+			retried += int64(attempt - 1)
+		}
+		if err == errRollback {
+			// Its statements ran, but nothing it wrote stays written
+			err = nil
+			written = committed
+		}
+		if err != nil {
+			break
+		}
+		committed, done, serverDone = written, len(statements), server
+		// Which may be more statements than it had to begin with, see resume
+		start += len(uow.transactionAt(start).statements)
+		if start >= len(uow.Statements) {
+			break
 		}
 	}
+	retried += autocommitRetried
 
 	if err != nil {
 		return uowOutcome{
-			succeeded:    false,
-			failureGroup: groupError(err),
-			err:          err,
+			succeeded:       false,
+			failureGroup:    groupError(err),
+			err:             err,
+			failedAt:        w.now(),
+			failedStatement: failedStatement,
+			lockErrors:      lockErrors,
+			retried:         retried,
+			acquired:        acquired,
+			acquire:         acquireLatency,
+		}
+	}
+
+	return uowOutcome{succeeded: true, written: written, lockErrors: lockErrors, retried: retried, acquired: acquired,
+		acquire: acquireLatency, statements: statements, server: server}
+}
+
+// Runs a transaction function, retrying it by the given policy rather than the way the driver does, in
+// transactions begun on the session, in its access mode; r draws the backoff, and the retries stop once stopCh
+// closes
+func retryTransaction(session neo4j.Session, policy RetryPolicy, work neo4j.TransactionWork,
+	configure []func(*neo4j.TransactionConfig), sleep func(time.Duration, <-chan struct{}) bool,
+	r *rand.Rand, stopCh <-chan struct{}) (interface{}, error) {
+	for retry := 0; ; retry++ {
+		result, committing, err := tryTransaction(session, work, configure)
+		if err == nil || err == errRollback || retry == policy.Retries || !retryable(err) {
+			return result, err
+		}
+		if committing && neo4j.IsConnectivityError(errors.Cause(err)) {
+			// The server may have committed before the connection went, and running it again would write
+			// everything twice; the driver doesn't retry these either
+			return result, err
+		}
+		if !sleep(policy.delay(retry+1, r), stopCh) {
+			return result, err
+		}
+	}
+}
+
+// One attempt of retryTransaction; the transaction is committed if the function succeeds, and rolled back
+// otherwise. committing is set if it was the commit that failed.
+func tryTransaction(session neo4j.Session, work neo4j.TransactionWork,
+	configure []func(*neo4j.TransactionConfig)) (result interface{}, committing bool, err error) {
+	tx, err := session.BeginTransaction(configure...)
+	if err != nil {
+		return nil, false, err
+	}
+	result, err = work(tx)
+	if err != nil {
+		// The error of the function is what failed the transaction, not one from rolling it back
+		_ = tx.Rollback()
+		return nil, false, err
+	}
+	if err := tx.Commit(); err != nil {
+		return nil, true, err
+	}
+	return result, false, nil
+}
+
+// Consumes the result of a statement, collecting its records first if the statement has assertions to check them
+// against, or just the first of them if it has a :gset
+func consume(res neo4j.Result, s Statement) ([]*neo4j.Record, neo4j.ResultSummary, error) {
+	var records []*neo4j.Record
+	if len(s.Assertions) > 0 {
+		var err error
+		if records, err = res.Collect(); err != nil {
+			return nil, nil, err
 		}
+	} else if len(s.Gset) > 0 && res.Next() {
+		records = []*neo4j.Record{res.Record()}
+	}
+	summary, err := res.Consume()
+	return records, summary, err
+}
+
+func first(records []*neo4j.Record) *neo4j.Record {
+	if len(records) == 0 {
+		return nil
 	}
+	return records[0]
+}
+
+func addWritten(written WriteVolume, summary neo4j.ResultSummary, s Statement) WriteVolume {
+	counters := summary.Counters()
+	if !counters.ContainsUpdates() {
+		return written
+	}
+	return WriteVolume{
+		Rows:  written.Rows + int64(counters.NodesCreated()+counters.RelationshipsCreated()),
+		Bytes: written.Bytes + estimateSize(s.Params),
+	}
+}
+
+// What the server reported spending on statements: making the result available and then streaming it
+type serverTime struct {
+	total time.Duration
+	// The driver gives 0 for both timings when the server sends none, so this is only set if some statement had
+	// one that wasn't 0; a statement that really took under a millisecond on both counts is taken as unknown too
+	known bool
+}
 
-	return uowOutcome{succeeded: true}
+func (t serverTime) add(summary neo4j.ResultSummary) serverTime {
+	available, consumed := summary.ResultAvailableAfter(), summary.ResultConsumedAfter()
+	if available < 0 || consumed < 0 || (available == 0 && consumed == 0) {
+		return t
+	}
+	return serverTime{total: t.total + available + consumed, known: true}
 }
 
 // Converts a total target rate into a per-client "pacing" duration, used to slow down workers to match
 // the target rate.
 func TotalRatePerSecondToDurationPerClient(numClients int, rate float64) time.Duration {
-	ratePerWorkerPerSecond := rate / float64(numClients)
-	return time.Duration(1000*1000/ratePerWorkerPerSecond) * time.Microsecond
+	return time.Duration(float64(time.Second) * float64(numClients) / rate)
 }
 
 // Concurrent data structure; used by the worker to record progress, accessible from other threads
@@ -192,28 +640,114 @@ type ResultRecorder struct {
 	current      WorkerResult
 	currentStart time.Time
 
-	// Total since the workload started
+	// Total since the workload started, excluding anything that started before rampEnd
 	total      WorkerResult
 	totalStart time.Time
+
+	// Transactions that started before rampEnd are recorded here rather than in total, so the
+	// transient start of the run does not skew the steady-state numbers
+	ramp    WorkerResult
+	rampEnd time.Time
+
+	// Stats since the last time series window, read and reset by calling WindowReport; nil unless
+	// EnableWindowReports was called, see --timeseries
+	window      *WorkerResult
+	windowStart time.Time
+
+	// Gets a row per transaction; nil unless EnableTransactionLog was called, see --transaction-log
+	transactionLog *TransactionLog
+
+	// Transactions recorded so far that started after rampEnd, failed or not, see ThroughputSampler
+	completed int64
+	// Transactions recorded so far, ramp-up included
+	recorded int64
+	// Of the transactions recorded so far, those that failed, see --max-error-rate
+	failed int64
+
+	// Set while the worker runs a transaction, see CutOff
+	running bool
+	// Set once Complete or CutOff hands out the results; nothing is recorded after that
+	final *WorkerResult
 }
 
-func NewResultRecorder(workerId int64) *ResultRecorder {
+// rampEnd is the wall-clock time when the ramp-up region ends; pass the zero time if there is no ramp-up
+func NewResultRecorder(workerId int64, rampEnd time.Time) *ResultRecorder {
 	return &ResultRecorder{
 		current: NewWorkerResult(workerId),
 		total:   NewWorkerResult(workerId),
+		ramp:    NewWorkerResult(workerId),
+		rampEnd: rampEnd,
 	}
 }
 
-func (t *ResultRecorder) record(scriptName string, latency time.Duration, outcome uowOutcome) error {
+func (t *ResultRecorder) record(scriptName string, start time.Time, latency time.Duration, outcome uowOutcome) error {
 	t.mut.Lock()
 	defer t.mut.Unlock()
 
+	t.running = false
+	if t.final != nil {
+		// Cut off, the results are already out
+		return nil
+	}
+	t.recorded++
+	if !outcome.succeeded {
+		t.failed++
+	}
+	if t.transactionLog != nil {
+		t.transactionLog.record(t.current.WorkerId, scriptName, start, latency, outcome)
+	}
 	if err := t.current.record(scriptName, latency, outcome); err != nil {
 		return err
 	}
+	if t.window != nil {
+		if err := t.window.record(scriptName, latency, outcome); err != nil {
+			return err
+		}
+	}
+	if start.Before(t.rampEnd) {
+		return t.ramp.record(scriptName, latency, outcome)
+	}
+	t.completed++
 	return t.total.record(scriptName, latency, outcome)
 }
 
+// Transactions completed since the workload started, excluding ramp-up
+func (t *ResultRecorder) Completed() int64 {
+	t.mut.Lock()
+	defer t.mut.Unlock()
+	return t.completed
+}
+
+// Transactions completed since the workload started, ramp-up included, for progress reports with --transactions
+func (t *ResultRecorder) Recorded() int64 {
+	t.mut.Lock()
+	defer t.mut.Unlock()
+	return t.recorded
+}
+
+// Transactions failed since the workload started, ramp-up included, see --max-error-rate
+func (t *ResultRecorder) Failed() int64 {
+	t.mut.Lock()
+	defer t.mut.Unlock()
+	return t.failed
+}
+
+// Called by the worker as it starts a transaction, which it records once it's done
+func (t *ResultRecorder) begin() {
+	t.mut.Lock()
+	defer t.mut.Unlock()
+	t.running = true
+}
+
+// Called by the worker as it starts running transactions; rates are from here
+func (t *ResultRecorder) start(now time.Time) {
+	t.mut.Lock()
+	defer t.mut.Unlock()
+	t.totalStart = now
+	t.currentStart = now
+	t.windowStart = now
+}
+
 // Reports progress since last time you called this function
 func (t *ResultRecorder) ProgressReport(now time.Time) WorkerResult {
 	t.mut.Lock()
@@ -230,19 +764,81 @@ func (t *ResultRecorder) ProgressReport(now time.Time) WorkerResult {
 	return out
 }
 
+// Starts keeping stats for WindowReport; call before the worker starts
+func (t *ResultRecorder) EnableWindowReports() {
+	t.mut.Lock()
+	defer t.mut.Unlock()
+	window := NewWorkerResult(t.current.WorkerId)
+	t.window = &window
+}
+
+// Writes every transaction recorded from now on to the log; call before the worker starts
+func (t *ResultRecorder) EnableTransactionLog(log *TransactionLog) {
+	t.mut.Lock()
+	defer t.mut.Unlock()
+	t.transactionLog = log
+}
+
+// Reports stats since last time you called this function, like ProgressReport but on a schedule of its own
+func (t *ResultRecorder) WindowReport(now time.Time) WorkerResult {
+	t.mut.Lock()
+	defer t.mut.Unlock()
+
+	out := *t.window
+
+	delta := now.Sub(t.windowStart)
+	out.calculateRate(delta)
+
+	window := NewWorkerResult(out.WorkerId)
+	t.window = &window
+	t.windowStart = now
+
+	return out
+}
+
 func (t *ResultRecorder) Complete(now time.Time) WorkerResult {
 	t.mut.Lock()
 	defer t.mut.Unlock()
+	return t.complete(now)
+}
+
+// Results so far, for a worker that didn't stop in time, see --drain; anything it records after this is left out.
+// Also says if the worker was in the middle of a transaction, which is left out with it.
+func (t *ResultRecorder) CutOff(now time.Time) (WorkerResult, bool) {
+	t.mut.Lock()
+	defer t.mut.Unlock()
+	running := t.running && t.final == nil
+	return t.complete(now), running
+}
 
+func (t *ResultRecorder) complete(now time.Time) WorkerResult {
+	if t.final != nil {
+		// Whichever of Complete and CutOff came first has the results
+		return *t.final
+	}
 	out := t.total
 
-	delta := now.Sub(t.totalStart)
+	steadyStateStart := t.totalStart
+	if t.rampEnd.After(t.totalStart) {
+		ramp := t.ramp
+		rampEnd := t.rampEnd
+		if now.Before(rampEnd) {
+			rampEnd = now
+		}
+		ramp.calculateRate(rampEnd.Sub(t.totalStart))
+		out.Ramp = &ramp
+		steadyStateStart = t.rampEnd
+	}
+
+	delta := now.Sub(steadyStateStart)
 	out.calculateRate(delta)
 
 	// Not needed at the time of writing this, but since we're returning pointers
 	// (the maps etc inside t.total), clear this structures references before we exit the mutex
 	t.total = NewWorkerResult(out.WorkerId)
+	t.ramp = NewWorkerResult(out.WorkerId)
 	t.totalStart = now
+	t.final = &out
 
 	return out
 }
@@ -252,6 +848,8 @@ func NewWorkerResult(workerId int64) WorkerResult {
 		WorkerId:           workerId,
 		Scripts:            make(map[string]*ScriptResult),
 		FailedByErrorGroup: make(map[string]FailureGroup),
+		LockErrors:         make(map[string]int64),
+		AcquireLatencies:   newLatencyHistogram(),
 	}
 }
 
@@ -267,6 +865,18 @@ type WorkerResult struct {
 
 	// Failure counts by cause
 	FailedByErrorGroup map[string]FailureGroup
+
+	// Lock errors by server error code, counting each attempt, so including errors the driver retried
+	LockErrors map[string]int64
+
+	// How long transactions waited for the driver to start them, across all scripts. This is mostly waiting
+	// for a connection from the pool, so it grows when there are more clients than pooled connections. Sessions
+	// that hold a bookmark also spend a round trip on BEGIN here. Auto-commit scripts are not included.
+	AcquireLatencies *hdrhistogram.Histogram
+
+	// Set if the run had a ramp-up region; stats for transactions that started during ramp-up.
+	// These are not included in Scripts or FailedByErrorGroup above.
+	Ramp *WorkerResult
 }
 
 func (r *WorkerResult) getOrCreateScriptResult(scriptName string) *ScriptResult {
@@ -275,42 +885,91 @@ func (r *WorkerResult) getOrCreateScriptResult(scriptName string) *ScriptResult
 		return stats
 	}
 	stats = &ScriptResult{
-		ScriptName: scriptName,
-		Latencies:  hdrhistogram.New(0, 60*60*1000000, 5),
+		ScriptName:           scriptName,
+		Latencies:            newLatencyHistogram(),
+		ServerLatencies:      newLatencyHistogram(),
+		UncorrectedLatencies: newLatencyHistogram(),
 	}
 	r.Scripts[scriptName] = stats
 	return stats
 }
 
+func (r *ScriptResult) getOrCreateStatementResult(statement Statement) *StatementResult {
+	if r.Statements == nil {
+		r.Statements = make(map[int]*StatementResult)
+	}
+	stats, found := r.Statements[statement.Command]
+	if found {
+		return stats
+	}
+	stats = &StatementResult{
+		Command:   statement.Command,
+		Query:     statement.Query,
+		Latencies: newLatencyHistogram(),
+	}
+	r.Statements[statement.Command] = stats
+	return stats
+}
+
+// All latency histograms need the same configuration to be mergeable; create them through this
+func newLatencyHistogram() *hdrhistogram.Histogram {
+	// Microsecond resolution, up to one hour
+	return hdrhistogram.New(0, 60*60*1000000, 3)
+}
+
 func (r *WorkerResult) record(scriptName string, latency time.Duration, outcome uowOutcome) error {
-	stats, found := r.Scripts[scriptName]
-	if !found {
-		stats = &ScriptResult{
-			ScriptName: scriptName,
-			Latencies:  hdrhistogram.New(0, 60*60*1000000, 3),
+	stats := r.getOrCreateScriptResult(scriptName)
+
+	for _, code := range outcome.lockErrors {
+		r.LockErrors[code]++
+		if code == deadlockErrorCode {
+			stats.Deadlocks++
+		}
+	}
+	stats.Retried += outcome.retried
+	if outcome.acquired {
+		if err := r.AcquireLatencies.RecordValue(outcome.acquire.Microseconds()); err != nil {
+			return errors.Wrapf(err, "failed to record acquire latency: %s", outcome.acquire)
 		}
-		r.Scripts[scriptName] = stats
 	}
 
 	if outcome.succeeded {
 		stats.Succeeded++
+		stats.RowsWritten += outcome.written.Rows
+		stats.BytesWritten += outcome.written.Bytes
 		if err := stats.Latencies.RecordValue(latency.Microseconds()); err != nil {
 			return errors.Wrapf(err, "failed to record latency: %s", latency)
 		}
-	} else {
-		stats.Failed++
-		failedGroup, found := r.FailedByErrorGroup[outcome.failureGroup]
-		if !found {
-			r.FailedByErrorGroup[outcome.failureGroup] = FailureGroup{
-				Count:        1,
-				FirstFailure: outcome.err,
+		uncorrected := latency - outcome.startedLate
+		if err := stats.UncorrectedLatencies.RecordValue(uncorrected.Microseconds()); err != nil {
+			return errors.Wrapf(err, "failed to record uncorrected latency: %s", uncorrected)
+		}
+		if outcome.server.known {
+			if err := stats.ServerLatencies.RecordValue(outcome.server.total.Microseconds()); err != nil {
+				return errors.Wrapf(err, "failed to record server latency: %s", outcome.server.total)
 			}
-		} else {
-			r.FailedByErrorGroup[outcome.failureGroup] = FailureGroup{
-				Count:        failedGroup.Count + 1,
-				FirstFailure: failedGroup.FirstFailure,
+		}
+		for _, timing := range outcome.statements {
+			statement := stats.getOrCreateStatementResult(timing.statement)
+			if err := statement.Latencies.RecordValue(timing.latency.Microseconds()); err != nil {
+				return errors.Wrapf(err, "failed to record statement latency: %s", timing.latency)
 			}
 		}
+	} else {
+		stats.Failed++
+		source := FailureSource{Script: scriptName, Command: -1, Count: 1}
+		if outcome.failedStatement != nil {
+			source.Command = outcome.failedStatement.Command
+			source.Query = outcome.failedStatement.Query
+		}
+		r.FailedByErrorGroup[outcome.failureGroup] = r.FailedByErrorGroup[outcome.failureGroup].merge(FailureGroup{
+			Count:        1,
+			FirstFailure: outcome.err,
+			LastSeen:     outcome.failedAt,
+			Code:         errorCode(outcome.err),
+			Samples:      []string{outcome.err.Error()},
+			Sources:      []FailureSource{source},
+		})
 	}
 	return nil
 }
@@ -319,17 +978,118 @@ func (r *WorkerResult) record(scriptName string, latency time.Duration, outcome
 // workload to run.
 func (r *WorkerResult) calculateRate(delta time.Duration) {
 	for _, script := range r.Scripts {
-		script.Rate = (float64(script.Succeeded+script.Failed) / float64(delta.Microseconds())) * 1000 * 1000
+		if delta <= 0 {
+			script.Rate = 0
+			script.RowsWrittenRate = 0
+			script.BytesWrittenRate = 0
+			continue
+		}
+		seconds := float64(delta.Microseconds()) / 1000 / 1000
+		script.Rate = float64(script.Succeeded+script.Failed) / seconds
+		script.RowsWrittenRate = float64(script.RowsWritten) / seconds
+		script.BytesWrittenRate = float64(script.BytesWritten) / seconds
 	}
 }
 
-// Combines the count with the last error we saw, to help users see what the errors were
+// Failures in a run with the same cause, with examples and where they happened, to help users see what the
+// errors were
 type FailureGroup struct {
 	Count        int64
 	FirstFailure error
+	// When the latest of these failures happened
+	LastSeen time.Time
+	// The Neo4j error code, if the server sent one
+	Code string
+	// The first few distinct error messages, up to maxFailureSamples; the first one is FirstFailure
+	Samples []string
+	// Failure counts by the script and statement they happened in, in the order first seen
+	Sources []FailureSource
+}
+
+// Enough to see if errors with the same code differ, without flooding the report
+const maxFailureSamples = 3
+
+// Where failures in a FailureGroup happened
+type FailureSource struct {
+	Script string
+	// Of the statement that failed, see Statement.Command; -1 if it wasn't a statement that failed, for
+	// instance if the commit failed or no connection could be had
+	Command int
+	// The statement as it was in the first of these failures
+	Query string
+	Count int64
+}
+
+// Combines the groups; failures in g came first. The zero value is an empty group.
+func (g FailureGroup) merge(other FailureGroup) FailureGroup {
+	merged := FailureGroup{
+		Count:        g.Count + other.Count,
+		FirstFailure: g.FirstFailure,
+		LastSeen:     g.LastSeen,
+		Code:         g.Code,
+		Samples:      append([]string{}, g.Samples...),
+		Sources:      append([]FailureSource{}, g.Sources...),
+	}
+	if merged.FirstFailure == nil {
+		merged.FirstFailure = other.FirstFailure
+	}
+	if other.LastSeen.After(merged.LastSeen) {
+		merged.LastSeen = other.LastSeen
+	}
+	if merged.Code == "" {
+		merged.Code = other.Code
+	}
+	for _, sample := range other.Samples {
+		known := false
+		for _, existing := range merged.Samples {
+			known = known || existing == sample
+		}
+		if !known && len(merged.Samples) < maxFailureSamples {
+			merged.Samples = append(merged.Samples, sample)
+		}
+	}
+	for _, source := range other.Sources {
+		found := false
+		for i, existing := range merged.Sources {
+			if existing.Script == source.Script && existing.Command == source.Command {
+				merged.Sources[i].Count += source.Count
+				found = true
+				break
+			}
+		}
+		if !found {
+			merged.Sources = append(merged.Sources, source)
+		}
+	}
+	return merged
+}
+
+const deadlockErrorCode = "Neo.TransientError.Transaction.DeadlockDetected"
+
+// Error codes the server uses when a transaction could not get, or lost, a lock it was waiting for
+var lockErrorCodes = map[string]bool{
+	deadlockErrorCode: true,
+	"Neo.TransientError.Transaction.LockAcquisitionTimeout": true,
+	"Neo.TransientError.Transaction.LockClientStopped":      true,
+}
+
+func appendLockError(lockErrors []string, err error) []string {
+	if neoErr, ok := errors.Cause(err).(*neo4j.Neo4jError); ok && lockErrorCodes[neoErr.Code] {
+		return append(lockErrors, neoErr.Code)
+	}
+	return lockErrors
 }
 
 func groupError(err error) string {
+	if _, ok := errors.Cause(err).(*assertionError); ok {
+		return AssertionFailureGroup
+	}
+	if _, ok := errors.Cause(err).(*gsetError); ok {
+		return GsetFailureGroup
+	}
+	if code := errorCode(err); code != "" {
+		return code
+	}
 	msg := err.Error()
 	if strings.HasPrefix(msg, "Server error: [") {
 		return strings.Split(strings.Split(msg, "[")[1], "]")[0]
@@ -337,18 +1097,119 @@ func groupError(err error) string {
 	return "unknown"
 }
 
+// The Neo4j error code, eg. Neo.ClientError.Statement.SyntaxError, or empty if the server didn't send the error
+func errorCode(err error) string {
+	if neoErr, ok := errors.Cause(err).(*neo4j.Neo4jError); ok {
+		return neoErr.Code
+	}
+	return ""
+}
+
 type uowOutcome struct {
 	succeeded bool
 	// An opaque string used to group errors; we track counts for each unique string
 	failureGroup string
 	err          error
+	// When the unit failed, and the statement it failed in, if it failed in one
+	failedAt        time.Time
+	failedStatement *Statement
+	// Only set if succeeded
+	written WriteVolume
+	// Codes of lock errors hit on the way, one per occurrence; a unit can succeed after the driver retried these
+	lockErrors []string
+	// Attempts that failed and were tried again, by the driver or, for auto-commit units, by us; these are not
+	// failures unless the last attempt failed too
+	retried int64
+	// Set if the driver handed us a transaction, which it doesn't for auto-commit units or if it could not
+	// get a connection at all; acquire is how long that took
+	acquired bool
+	acquire  time.Duration
+	// Only set if succeeded and the worker tracks statements
+	statements []statementTiming
+	// Only set if succeeded
+	server serverTime
+	// How long after its scheduled start the unit actually started, because the one before it overran; the
+	// latency is from the scheduled start, this is what to take off for the uncorrected latency
+	startedLate time.Duration
+}
+
+type statementTiming struct {
+	statement Statement
+	latency   time.Duration
 }
 
-func NewWorker(driver neo4j.Driver, workerId int64) *Worker {
+func NewWorker(driver neo4j.Driver, workerId int64, trackStatements bool, log *Logger) *Worker {
 	return &Worker{
-		workerId: workerId,
-		driver:   driver,
-		now:      time.Now,
-		sleep:    time.Sleep,
+		workerId:        workerId,
+		driver:          driver,
+		now:             time.Now,
+		sleep:           sleepUnlessStopped,
+		log:             log,
+		trackStatements: trackStatements,
+		retryRand:       rand.New(rand.NewSource(time.Now().UnixNano() + workerId)),
+	}
+}
+
+// Makes RunBenchmark wait this long before it starts running transactions, see RampStartDelay
+func (w *Worker) StartAfter(delay time.Duration) {
+	w.startDelay = delay
+}
+
+// Makes the transaction rate RunBenchmark is given an upper bound, for -r in throughput mode: the worker never
+// goes faster, but as the database slows down it goes slower, rather than measuring the queue that builds up
+// behind a fixed schedule, which is what latency mode is for
+func (w *Worker) CapRate() {
+	w.rateCap = true
+}
+
+// Makes RunBenchmark pause for a think time between transactions, drawn from the distribution with r, see
+// --think-time; the pauses don't count towards the latencies. Only in throughput mode, with or without CapRate,
+// where the next transaction starts when the client is ready for it, rather than on a schedule.
+func (w *Worker) ThinkBetween(thinkTime ThinkTime, r *rand.Rand) {
+	w.thinkTime = thinkTime
+	w.thinkRand = r
+}
+
+// Pauses for a think time, unless stopCh closes first, as a long think time would keep the worker past the end of
+// the run
+func (w *Worker) think(stopCh <-chan struct{}) {
+	if !w.thinkTime.IsZero() {
+		w.sleep(w.thinkTime.Next(w.thinkRand), stopCh)
+	}
+}
+
+// Makes RunBenchmark open a new session for each transaction, and close it once the transaction is done, see
+// --sessions, rather than run all of them in one session; the time it takes is part of the latency
+func (w *Worker) SessionPerTransaction() {
+	w.sessionPerTransaction = true
+}
+
+// Makes RunBenchmark start transactions when the schedule says, sharing it with the other workers of the run,
+// which makes the total rate hold even if a worker stalls; the transaction rate is then ignored
+func (w *Worker) ShareSchedule(schedule *Schedule) {
+	w.schedule = schedule
+}
+
+// Makes RunBenchmark hold off between transactions while the gate is closed, see --burst and PauseOnSignals; a
+// transaction that's running when the gate closes completes first. Workers can have several gates, and hold off
+// while any of them is closed.
+func (w *Worker) UseGate(gate *Gate) {
+	w.gates = append(w.gates, gate)
+}
+
+// Makes RunBenchmark schedule transactions by the profile, see --load-profile, with this worker getting its
+// share of the rate at each point in time, as one of numClients; the transaction rate is then ignored
+func (w *Worker) FollowProfile(profile *LoadProfile, numClients int) {
+	w.profile = profile
+	w.profileShare = 1 / float64(numClients)
+}
+
+// When a client should start, so clients start one after the other over the ramp-up rather than all at once,
+// which would have them all open connections and hit cold caches at the same moment; the first client starts
+// right away and the last one ramp/numClients before the ramp-up ends
+func RampStartDelay(workerId int64, numClients int, ramp time.Duration) time.Duration {
+	if numClients <= 1 {
+		return 0
 	}
+	return time.Duration(workerId) * ramp / time.Duration(numClients)
 }
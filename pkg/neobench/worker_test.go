@@ -3,9 +3,11 @@ package neobench
 import (
 	"fmt"
 	"github.com/neo4j/neo4j-go-driver/v4/neo4j"
+	"github.com/pkg/errors"
 	"github.com/stretchr/testify/assert"
 	"math/rand"
 	"net/url"
+	"strings"
 	"testing"
 	"time"
 )
@@ -26,20 +28,188 @@ func TestMaintainsRateInFaceOfFailure(t *testing.T) {
 		workerId: 0,
 		driver:   driver,
 		now:      clock.now,
-		sleep:    clock.sleep,
+		sleep:    clock.sleepUnlessStopped,
 	}
-	rec := NewResultRecorder(0)
+	rec := NewResultRecorder(0, time.Time{})
 
 	targetRatePerSecond := float64(1)
 	txDuration := TotalRatePerSecondToDurationPerClient(1, targetRatePerSecond)
 
-	result := w.RunBenchmark(newTestWorkload(r), "", txDuration, 100, stopCh, rec)
+	result := w.RunBenchmark(newTestWorkload(r), "", txDuration, 100, stopCh, nil, rec)
 
 	assert.NoError(t, result.Error)
 	sr := result.Scripts["workertest"]
 	assert.InDelta(t, targetRatePerSecond, sr.Rate, 0.1)
 }
 
+func TestSeparatesRampFromSteadyState(t *testing.T) {
+	r := rand.New(rand.NewSource(1337))
+	stopCh := make(chan struct{})
+	clock := &fakeSpaceTimeContinuum{}
+	clock.currentTime = time.Date(2020, 1, 1, 1, 1, 1, 1, time.UTC)
+	driver := &fakeDriver{
+		clock:      clock,
+		r:          r,
+		minLatency: 2 * time.Millisecond,
+		maxLatency: 20 * time.Millisecond,
+	}
+	w := Worker{
+		workerId: 0,
+		driver:   driver,
+		now:      clock.now,
+		sleep:    clock.sleepUnlessStopped,
+	}
+	rec := NewResultRecorder(0, clock.currentTime.Add(10*time.Second))
+
+	txDuration := TotalRatePerSecondToDurationPerClient(1, 1)
+
+	result := w.RunBenchmark(newTestWorkload(r), "", txDuration, 100, stopCh, nil, rec)
+
+	assert.NoError(t, result.Error)
+	assert.Equal(t, int64(90), result.Scripts["workertest"].Succeeded)
+	assert.InDelta(t, 1, result.Scripts["workertest"].Rate, 0.1)
+	if assert.NotNil(t, result.Ramp) {
+		assert.Equal(t, int64(10), result.Ramp.Scripts["workertest"].Succeeded)
+	}
+}
+
+func TestRampStartDelayStaggersClientsOverTheRamp(t *testing.T) {
+	delays := make([]time.Duration, 0)
+	for i := int64(0); i < 4; i++ {
+		delays = append(delays, RampStartDelay(i, 4, time.Minute))
+	}
+	assert.Equal(t, []time.Duration{0, 15 * time.Second, 30 * time.Second, 45 * time.Second}, delays)
+	assert.Equal(t, time.Duration(0), RampStartDelay(0, 1, time.Minute))
+	assert.Equal(t, time.Duration(0), RampStartDelay(3, 4, 0))
+}
+
+func TestWorkerStoppedBeforeItsStartRunsNothing(t *testing.T) {
+	r := rand.New(rand.NewSource(1337))
+	stopCh := make(chan struct{})
+	clock := &fakeSpaceTimeContinuum{}
+	clock.currentTime = time.Date(2020, 1, 1, 1, 1, 1, 1, time.UTC)
+	w := Worker{
+		workerId: 0,
+		driver:   &fakeDriver{clock: clock, r: r, minLatency: time.Millisecond, maxLatency: time.Millisecond},
+		now:      clock.now,
+		sleep:    clock.sleepUnlessStopped,
+	}
+	w.StartAfter(time.Hour)
+	close(stopCh)
+
+	result := w.RunBenchmark(newTestWorkload(r), "", 0, 100, stopCh, nil, NewResultRecorder(0, time.Time{}))
+
+	assert.NoError(t, result.Error)
+	assert.Empty(t, result.Scripts)
+}
+
+func TestRunsFixedNumberOfTransactions(t *testing.T) {
+	r := rand.New(rand.NewSource(1337))
+	stopCh := make(chan struct{})
+	clock := &fakeSpaceTimeContinuum{}
+	clock.currentTime = time.Date(2020, 1, 1, 1, 1, 1, 1, time.UTC)
+	driver := &fakeDriver{
+		clock:       clock,
+		r:           r,
+		failureRate: 0.2,
+		minLatency:  time.Millisecond,
+		maxLatency:  10 * time.Millisecond,
+	}
+	w := Worker{
+		workerId: 0,
+		driver:   driver,
+		now:      clock.now,
+		sleep:    clock.sleepUnlessStopped,
+	}
+	rec := NewResultRecorder(0, time.Time{})
+
+	result := w.RunBenchmark(newTestWorkload(r), "", 0, 250, stopCh, nil, rec)
+
+	assert.NoError(t, result.Error)
+	script := result.Scripts["workertest"]
+	// Failed ones count towards the number too
+	assert.Equal(t, int64(250), script.Succeeded+script.Failed)
+	assert.Greater(t, script.Failed, int64(0))
+	assert.Equal(t, int64(250), rec.Recorded())
+}
+
+func TestRateCapIsAnUpperBound(t *testing.T) {
+	for _, c := range []struct {
+		latency time.Duration
+		rate    float64
+	}{
+		// Quick enough to go at the cap
+		{10 * time.Millisecond, 10},
+		// Too slow for the cap, so it goes as fast as the database lets it, with no queue building up
+		{200 * time.Millisecond, 5},
+	} {
+		r := rand.New(rand.NewSource(1337))
+		clock := &fakeSpaceTimeContinuum{}
+		clock.currentTime = time.Date(2020, 1, 1, 1, 1, 1, 1, time.UTC)
+		w := Worker{
+			workerId: 0,
+			driver:   &fakeDriver{clock: clock, r: r, minLatency: c.latency, maxLatency: c.latency},
+			now:      clock.now,
+			sleep:    clock.sleepUnlessStopped,
+		}
+		w.CapRate()
+
+		result := w.RunBenchmark(newTestWorkload(r), "", TotalRatePerSecondToDurationPerClient(1, 10), 50, make(chan struct{}), nil, NewResultRecorder(0, time.Time{}))
+
+		assert.NoError(t, result.Error)
+		script := result.Scripts["workertest"]
+		assert.InDelta(t, c.rate, script.Rate, 0.2)
+		assert.InDelta(t, c.latency.Microseconds(), script.Latencies.Max(), float64(c.latency.Microseconds())/100)
+	}
+}
+
+func TestRecordsLatencyUncorrectedForCoordinatedOmissionToo(t *testing.T) {
+	r := rand.New(rand.NewSource(1337))
+	stopCh := make(chan struct{})
+	clock := &fakeSpaceTimeContinuum{}
+	clock.currentTime = time.Date(2020, 1, 1, 1, 1, 1, 1, time.UTC)
+	// Every transaction overruns its one second slot by half a second, so each starts later than the last
+	driver := &fakeDriver{
+		clock:      clock,
+		r:          r,
+		minLatency: 1500 * time.Millisecond,
+		maxLatency: 1500 * time.Millisecond,
+	}
+	w := Worker{
+		workerId: 0,
+		driver:   driver,
+		now:      clock.now,
+		sleep:    clock.sleepUnlessStopped,
+	}
+	rec := NewResultRecorder(0, time.Time{})
+
+	result := w.RunBenchmark(newTestWorkload(r), "", TotalRatePerSecondToDurationPerClient(1, 1), 4, stopCh, nil, rec)
+
+	assert.NoError(t, result.Error)
+	script := result.Scripts["workertest"]
+	// Within the precision of the histograms
+	assert.InDelta(t, 1500000, script.Latencies.Min(), 5000)
+	assert.InDelta(t, 3000000, script.Latencies.Max(), 5000)
+	assert.InDelta(t, 1500000, script.UncorrectedLatencies.Min(), 5000)
+	assert.InDelta(t, 1500000, script.UncorrectedLatencies.Max(), 5000)
+}
+
+func TestWindowReportsAreIndependentOfProgressReports(t *testing.T) {
+	start := time.Date(2020, 1, 1, 1, 1, 1, 0, time.UTC)
+	rec := NewResultRecorder(0, time.Time{})
+	rec.EnableWindowReports()
+	rec.currentStart, rec.windowStart = start, start
+
+	assert.NoError(t, rec.record("s", start, time.Millisecond, uowOutcome{succeeded: true}))
+	progress := rec.ProgressReport(start.Add(time.Second))
+	assert.NoError(t, rec.record("s", start, time.Millisecond, uowOutcome{succeeded: true}))
+	window := rec.WindowReport(start.Add(2 * time.Second))
+
+	assert.Equal(t, int64(1), progress.Scripts["s"].Succeeded)
+	assert.Equal(t, int64(2), window.Scripts["s"].Succeeded)
+	assert.Equal(t, 1.0, window.Scripts["s"].Rate)
+}
+
 func newTestWorkload(r *rand.Rand) ClientWorkload {
 	script, err := Parse("workertest", `RETURN 1;`, 1)
 	if err != nil {
@@ -65,12 +235,25 @@ func (c *fakeSpaceTimeContinuum) sleep(duration time.Duration) {
 	c.currentTime = c.currentTime.Add(duration)
 }
 
+// Same as sleep, unless stopCh is already closed, in which case the clock stays where it is
+func (c *fakeSpaceTimeContinuum) sleepUnlessStopped(duration time.Duration, stopCh <-chan struct{}) bool {
+	select {
+	case <-stopCh:
+		return false
+	default:
+	}
+	c.sleep(duration)
+	return true
+}
+
 type fakeDriver struct {
 	clock       *fakeSpaceTimeContinuum
 	r           *rand.Rand
 	failureRate float64
 	minLatency  time.Duration
 	maxLatency  time.Duration
+	// Sessions opened and closed, as the driver doubles as the session
+	opened, closed int
 }
 
 func (d *fakeDriver) VerifyConnectivity() error {
@@ -86,10 +269,12 @@ func (d *fakeDriver) Session(accessMode neo4j.AccessMode, bookmarks ...string) (
 }
 
 func (d *fakeDriver) NewSession(config neo4j.SessionConfig) neo4j.Session {
+	d.opened++
 	return d
 }
 
 func (d *fakeDriver) Close() error {
+	d.closed++
 	return nil
 }
 
@@ -125,3 +310,542 @@ func (d *fakeDriver) Run(cypher string, params map[string]interface{}, configure
 var _ neo4j.Driver = &fakeDriver{}
 
 var _ neo4j.Session = &fakeDriver{}
+
+func TestSessionPerTransaction(t *testing.T) {
+	for _, perTransaction := range []bool{false, true} {
+		r := rand.New(rand.NewSource(1337))
+		clock := &fakeSpaceTimeContinuum{}
+		clock.currentTime = time.Date(2020, 1, 1, 1, 1, 1, 1, time.UTC)
+		driver := &fakeDriver{clock: clock, r: r, minLatency: time.Millisecond, maxLatency: time.Millisecond}
+		w := Worker{workerId: 0, driver: driver, now: clock.now, sleep: clock.sleepUnlessStopped}
+		if perTransaction {
+			w.SessionPerTransaction()
+		}
+
+		result := w.RunBenchmark(newTestWorkload(r), "", 0, 10, make(chan struct{}), nil, NewResultRecorder(0, time.Time{}))
+
+		assert.NoError(t, result.Error)
+		assert.Equal(t, int64(10), result.Scripts["workertest"].Succeeded)
+		if perTransaction {
+			assert.Equal(t, 10, driver.opened)
+		} else {
+			assert.Equal(t, 1, driver.opened)
+		}
+		assert.Equal(t, driver.opened, driver.closed)
+	}
+}
+
+func TestCutOffLeavesOutTheTransactionStillRunning(t *testing.T) {
+	start := time.Date(2020, 1, 1, 1, 1, 1, 1, time.UTC)
+	recorder := NewResultRecorder(0, time.Time{})
+	recorder.start(start)
+	assert.NoError(t, recorder.record("a", start, time.Millisecond, uowOutcome{succeeded: true}))
+	recorder.begin()
+
+	result, running := recorder.CutOff(start.Add(time.Second))
+	assert.True(t, running)
+	assert.Equal(t, int64(1), result.Scripts["a"].Succeeded)
+
+	// The transaction finishing after the cut-off doesn't change the results already out
+	assert.NoError(t, recorder.record("a", start, 2*time.Second, uowOutcome{succeeded: true}))
+	assert.Equal(t, int64(1), recorder.Recorded())
+	assert.Equal(t, int64(1), recorder.Complete(start.Add(3 * time.Second)).Scripts["a"].Succeeded)
+	_, running = recorder.CutOff(start.Add(4 * time.Second))
+	assert.False(t, running)
+}
+
+func TestAppendLockErrorOnlyKeepsLockCodes(t *testing.T) {
+	var lockErrors []string
+	lockErrors = appendLockError(lockErrors, &neo4j.Neo4jError{Code: "Neo.TransientError.Transaction.LockAcquisitionTimeout"})
+	lockErrors = appendLockError(lockErrors, errors.Wrap(&neo4j.Neo4jError{Code: "Neo.TransientError.Transaction.DeadlockDetected"}, "failed"))
+	lockErrors = appendLockError(lockErrors, &neo4j.Neo4jError{Code: "Neo.ClientError.Statement.SyntaxError"})
+	lockErrors = appendLockError(lockErrors, fmt.Errorf("connection reset"))
+
+	assert.Equal(t, []string{
+		"Neo.TransientError.Transaction.LockAcquisitionTimeout",
+		"Neo.TransientError.Transaction.DeadlockDetected",
+	}, lockErrors)
+}
+
+func TestFailureGroupsTrackWhereAndWhenFailuresHappened(t *testing.T) {
+	deadlock := func(msg string) error {
+		return errors.Wrap(&neo4j.Neo4jError{Code: "Neo.TransientError.Transaction.DeadlockDetected", Msg: msg}, "failed")
+	}
+	update := Statement{Query: "MATCH (a:Account {aid: $aid})\nSET a.balance = 0", Command: 2}
+	first, second := time.Date(2020, 1, 1, 10, 0, 0, 0, time.UTC), time.Date(2020, 1, 1, 10, 0, 5, 0, time.UTC)
+	assert.Equal(t, "Neo.TransientError.Transaction.DeadlockDetected", groupError(deadlock("x")))
+
+	w1 := NewWorkerResult(0)
+	for i := 0; i < 3; i++ {
+		assert.NoError(t, w1.record("a", time.Millisecond, uowOutcome{failureGroup: groupError(deadlock("x")), err: deadlock(fmt.Sprintf("lock %d", i)),
+			failedAt: first, failedStatement: &update}))
+	}
+	w2 := NewWorkerResult(1)
+	assert.NoError(t, w2.record("b", time.Millisecond, uowOutcome{failureGroup: groupError(deadlock("x")), err: deadlock("lock 3"),
+		failedAt: second}))
+	result := NewResult("", "")
+	assert.NoError(t, result.Add(w1))
+	assert.NoError(t, result.Add(w2))
+
+	group := result.FailedByErrorGroup["Neo.TransientError.Transaction.DeadlockDetected"]
+	assert.Equal(t, int64(4), group.Count)
+	assert.Equal(t, second, group.LastSeen)
+	assert.Equal(t, "Neo.TransientError.Transaction.DeadlockDetected", group.Code)
+	assert.Len(t, group.Samples, maxFailureSamples)
+	assert.Equal(t, []FailureSource{
+		{Script: "a", Command: 2, Query: update.Query, Count: 3},
+		{Script: "b", Command: -1, Count: 1},
+	}, group.Sources)
+
+	s := strings.Builder{}
+	writeErrorReport(result, &s)
+	assert.Contains(t, s.String(), "    Neo.TransientError.Transaction.DeadlockDetected: 4 failures, last at 10:00:05\n"+
+		"      3 in a, at MATCH (a:Account {aid: $aid}) SET a.balance = 0\n"+
+		"      1 in b, outside any statement, eg. on commit\n"+
+		"      (ex: failed: Neo4jError: Neo.TransientError.Transaction.DeadlockDetected (lock 0))\n")
+}
+
+func TestRunsEachTransactionOfTheScript(t *testing.T) {
+	script, err := Parse("transactions", `:begin
+CREATE (a);
+CREATE (b);
+:commit
+:begin
+CREATE (c);
+:rollback
+CREATE (d);`, 1)
+	assert.NoError(t, err)
+	uow, err := script.Eval(ScriptContext{Vars: map[string]interface{}{}, Rand: rand.New(rand.NewSource(1337))})
+	assert.NoError(t, err)
+
+	clock := &fakeSpaceTimeContinuum{}
+	driver := &recordingDriver{}
+	w := Worker{workerId: 0, driver: driver, now: clock.now, sleep: clock.sleepUnlessStopped}
+	outcome := w.runUnit(driver, uow, nil)
+
+	assert.True(t, outcome.succeeded)
+	assert.Equal(t, []string{
+		"CREATE (a)", "CREATE (b)", "commit",
+		"CREATE (c)", "rollback",
+		"CREATE (d)", "commit",
+	}, driver.log)
+	// What the rolled back transaction wrote doesn't count
+	assert.Equal(t, int64(3), outcome.written.Rows)
+}
+
+func TestRunsAutocommitQueriesOnTheirOwn(t *testing.T) {
+	script, err := Parse("autocommit", "CREATE (a);\n:autocommit\nCREATE (b);\nCREATE (c);", 1)
+	assert.NoError(t, err)
+	uow, err := script.Eval(ScriptContext{Vars: map[string]interface{}{}, Rand: rand.New(rand.NewSource(1337))})
+	assert.NoError(t, err)
+
+	clock := &fakeSpaceTimeContinuum{}
+	driver := &recordingDriver{}
+	w := Worker{workerId: 0, driver: driver, now: clock.now, sleep: clock.sleepUnlessStopped}
+	outcome := w.runUnit(driver, uow, nil)
+
+	assert.True(t, outcome.succeeded)
+	assert.Equal(t, []string{"CREATE (a)", "commit", "auto-commit CREATE (b)", "CREATE (c)", "commit"}, driver.log)
+	assert.Equal(t, int64(3), outcome.written.Rows)
+}
+
+func TestModeReadOpensReadSessions(t *testing.T) {
+	script, err := Parse("reads", ":mode read\n:autocommit\nRETURN 1;\nRETURN 2;", 1)
+	assert.NoError(t, err)
+	r := rand.New(rand.NewSource(1337))
+	clock := &fakeSpaceTimeContinuum{}
+	clock.currentTime = time.Date(2020, 1, 1, 1, 1, 1, 1, time.UTC)
+	driver := &recordingDriver{}
+	w := Worker{workerId: 0, driver: driver, now: clock.now, sleep: clock.sleepUnlessStopped}
+
+	result := w.RunBenchmark(ClientWorkload{Scripts: NewScripts(script), Rand: r}, "", 0, 2, make(chan struct{}), nil, NewResultRecorder(0, time.Time{}))
+
+	assert.NoError(t, result.Error)
+	// The session the worker starts out with, then one to read in, which it keeps using
+	assert.Equal(t, []neo4j.AccessMode{neo4j.AccessModeWrite, neo4j.AccessModeRead}, driver.modes)
+	assert.Equal(t, []string{
+		"auto-commit RETURN 1", "read", "RETURN 2", "commit",
+		"auto-commit RETURN 1", "read", "RETURN 2", "commit",
+	}, driver.log)
+}
+
+func TestRunsTransactionsWithTheirTimeout(t *testing.T) {
+	script, err := Parse("timeout", `:opt timeout 2s
+:begin
+CREATE (a);
+:commit
+:autocommit
+:timeout 100ms
+CREATE (b);`, 1)
+	assert.NoError(t, err)
+	uow, err := script.Eval(ScriptContext{Vars: map[string]interface{}{}, Rand: rand.New(rand.NewSource(1337))})
+	assert.NoError(t, err)
+
+	clock := &fakeSpaceTimeContinuum{}
+	driver := &recordingDriver{}
+	w := Worker{workerId: 0, driver: driver, now: clock.now, sleep: clock.sleepUnlessStopped}
+	outcome := w.runUnit(driver, uow, nil)
+
+	assert.True(t, outcome.succeeded)
+	assert.Equal(t, []time.Duration{2 * time.Second, 100 * time.Millisecond}, driver.timeouts)
+}
+
+func TestFailedAssertionFailsTheTransaction(t *testing.T) {
+	for _, autocommit := range []bool{false, true} {
+		opt := ""
+		if autocommit {
+			opt = ":opt autocommit\n"
+		}
+		script, err := Parse("assert", opt+"CREATE (a) RETURN 1 AS created;\n:assert $rowcount = 1\nCREATE (b) RETURN 1 AS created;\n:assert $row[\"created\"] = 2", 1)
+		assert.NoError(t, err)
+		uow, err := script.Eval(ScriptContext{Vars: map[string]interface{}{}, Rand: rand.New(rand.NewSource(1337))})
+		assert.NoError(t, err)
+
+		clock := &fakeSpaceTimeContinuum{}
+		driver := &recordingDriver{}
+		w := Worker{workerId: 0, driver: driver, now: clock.now, sleep: clock.sleepUnlessStopped}
+		outcome := w.runUnit(driver, uow, nil)
+
+		assert.False(t, outcome.succeeded)
+		assert.Equal(t, AssertionFailureGroup, outcome.failureGroup)
+		assert.EqualError(t, outcome.err, `:assert $row["created"] = 2 failed, the query returned 1 row`)
+		assert.Equal(t, 1, outcome.failedStatement.Command)
+		if autocommit {
+			assert.Equal(t, []string{"auto-commit CREATE (a) RETURN 1 AS created", "auto-commit CREATE (b) RETURN 1 AS created"}, driver.log)
+		} else {
+			assert.Equal(t, []string{"CREATE (a) RETURN 1 AS created", "CREATE (b) RETURN 1 AS created", "rollback"}, driver.log)
+		}
+	}
+}
+
+func TestGsetRunsTheRestOfTheScriptWithWhatTheQueryReturned(t *testing.T) {
+	for _, tc := range []struct {
+		script   string
+		expected []string
+		written  int64
+	}{
+		{"CREATE (a) RETURN 1 AS created;\n:gset created\n:set n $created + 1\nCREATE (b {n: $$n});",
+			[]string{"CREATE (a) RETURN 1 AS created", "CREATE (b {n: 2})", "commit"}, 2},
+		{":begin\nCREATE (a) RETURN 1 AS created;\n:gset created\n:commit\nCREATE (b {n: $$created});",
+			[]string{"CREATE (a) RETURN 1 AS created", "commit", "CREATE (b {n: 1})", "commit"}, 2},
+		{":opt autocommit\nCREATE (a) RETURN 1 AS created;\n:gset created\nCREATE (b {n: $$created});",
+			[]string{"auto-commit CREATE (a) RETURN 1 AS created", "auto-commit CREATE (b {n: 1})"}, 2},
+		{"CREATE (a) RETURN 1 AS created;\n:gset created\n:if $created = 1\n:set n 2\n:else\n:set n 3\n:endif\nCREATE (b {n: $$n}) RETURN 1 AS created;\n:gset created\nCREATE (c {n: $$created});",
+			[]string{"CREATE (a) RETURN 1 AS created", "CREATE (b {n: 2}) RETURN 1 AS created", "CREATE (c {n: 1})", "commit"}, 3},
+	} {
+		script, err := Parse("gset", tc.script, 1)
+		assert.NoError(t, err)
+		uow, err := script.Eval(ScriptContext{Vars: map[string]interface{}{}, Rand: rand.New(rand.NewSource(1337))})
+		assert.NoError(t, err)
+
+		clock := &fakeSpaceTimeContinuum{}
+		driver := &recordingDriver{}
+		w := Worker{workerId: 0, driver: driver, now: clock.now, sleep: clock.sleepUnlessStopped}
+		outcome := w.runUnit(driver, uow, nil)
+
+		assert.True(t, outcome.succeeded, tc.script)
+		assert.Equal(t, tc.expected, driver.log, tc.script)
+		assert.Equal(t, tc.written, outcome.written.Rows, tc.script)
+	}
+}
+
+func TestGsetStartsOverWhenTheTransactionIsRetried(t *testing.T) {
+	script, err := Parse("gset", "CREATE (a) RETURN 1 AS created;\n:gset created\n:set n $created + 1\nCREATE (b {n: $$n});", 1)
+	assert.NoError(t, err)
+	uow, err := script.Eval(ScriptContext{Vars: map[string]interface{}{}, Rand: rand.New(rand.NewSource(1337))})
+	assert.NoError(t, err)
+
+	clock := &fakeSpaceTimeContinuum{}
+	driver := &recordingDriver{retryOnce: true}
+	w := Worker{workerId: 0, driver: driver, now: clock.now, sleep: clock.sleepUnlessStopped}
+	outcome := w.runUnit(driver, uow, nil)
+
+	assert.True(t, outcome.succeeded)
+	assert.Equal(t, int64(1), outcome.retried)
+	assert.Equal(t, []string{
+		"CREATE (a) RETURN 1 AS created", "CREATE (b {n: 2})", "retry",
+		"CREATE (a) RETURN 1 AS created", "CREATE (b {n: 2})", "commit",
+	}, driver.log)
+	assert.Equal(t, int64(2), outcome.written.Rows)
+}
+
+func TestGsetWithoutTheColumnFailsTheTransaction(t *testing.T) {
+	script, err := Parse("gset", "CREATE (a) RETURN 1 AS created;\n:gset balance\nCREATE (b {n: $$balance});", 1)
+	assert.NoError(t, err)
+	uow, err := script.Eval(ScriptContext{Vars: map[string]interface{}{}, Rand: rand.New(rand.NewSource(1337))})
+	assert.NoError(t, err)
+
+	clock := &fakeSpaceTimeContinuum{}
+	driver := &recordingDriver{}
+	w := Worker{workerId: 0, driver: driver, now: clock.now, sleep: clock.sleepUnlessStopped}
+	outcome := w.runUnit(driver, uow, nil)
+
+	assert.False(t, outcome.succeeded)
+	assert.Equal(t, GsetFailureGroup, outcome.failureGroup)
+	assert.EqualError(t, outcome.err, ":gset balance needs the query before it to return balance, it returned created")
+	assert.Equal(t, 0, outcome.failedStatement.Command)
+	assert.Equal(t, []string{"CREATE (a) RETURN 1 AS created", "rollback"}, driver.log)
+}
+
+func TestRetryPolicyRetriesTransientErrors(t *testing.T) {
+	for _, tc := range []struct {
+		script    string
+		deadlocks int
+		succeeded bool
+		retried   int64
+		expected  []string
+	}{
+		{":retry 2 backoff=none\nCREATE (a);", 2, true, 2,
+			[]string{"begin", "CREATE (a)", "rollback", "begin", "CREATE (a)", "rollback", "begin", "CREATE (a)", "commit"}},
+		{":retry 1\nCREATE (a);", 2, false, 1,
+			[]string{"begin", "CREATE (a)", "rollback", "begin", "CREATE (a)", "rollback"}},
+		{":retry 0\nCREATE (a);", 1, false, 0,
+			[]string{"begin", "CREATE (a)", "rollback"}},
+		{":retry 1 backoff=linear\n:opt autocommit\nCREATE (a);\nCREATE (b);", 1, true, 1,
+			[]string{"auto-commit CREATE (a)", "auto-commit CREATE (a)", "auto-commit CREATE (b)"}},
+		{":retry 1 backoff=linear\n:opt autocommit\nCREATE (a);", 2, false, 1,
+			[]string{"auto-commit CREATE (a)", "auto-commit CREATE (a)"}},
+	} {
+		script, err := Parse("retry", tc.script, 1)
+		assert.NoError(t, err)
+		uow, err := script.Eval(ScriptContext{Vars: map[string]interface{}{}, Rand: rand.New(rand.NewSource(1337))})
+		assert.NoError(t, err)
+
+		clock := &fakeSpaceTimeContinuum{}
+		driver := &recordingDriver{deadlocks: tc.deadlocks}
+		w := Worker{workerId: 0, driver: driver, now: clock.now, sleep: clock.sleepUnlessStopped, retryRand: rand.New(rand.NewSource(1337))}
+		outcome := w.runUnit(driver, uow, nil)
+
+		assert.Equal(t, tc.succeeded, outcome.succeeded, tc.script)
+		assert.Equal(t, tc.retried, outcome.retried, tc.script)
+		assert.Equal(t, tc.expected, driver.log, tc.script)
+		if !tc.succeeded {
+			assert.Equal(t, "Neo.TransientError.Transaction.DeadlockDetected", outcome.failureGroup, tc.script)
+		}
+	}
+}
+
+func TestRetryPolicyDoesNotRetryOtherErrors(t *testing.T) {
+	script, err := Parse("retry", ":retry 5\nCREATE (a) RETURN 1 AS created;\n:assert $rowcount = 2", 1)
+	assert.NoError(t, err)
+	uow, err := script.Eval(ScriptContext{Vars: map[string]interface{}{}, Rand: rand.New(rand.NewSource(1337))})
+	assert.NoError(t, err)
+
+	clock := &fakeSpaceTimeContinuum{}
+	driver := &recordingDriver{}
+	w := Worker{workerId: 0, driver: driver, now: clock.now, sleep: clock.sleepUnlessStopped}
+	outcome := w.runUnit(driver, uow, nil)
+
+	assert.False(t, outcome.succeeded)
+	assert.Equal(t, int64(0), outcome.retried)
+	assert.Equal(t, []string{"begin", "CREATE (a) RETURN 1 AS created", "rollback"}, driver.log)
+}
+
+func TestRetryPolicyDoesNotRetryLostConnectionsOnCommit(t *testing.T) {
+	clock := &fakeSpaceTimeContinuum{}
+	lost := &neo4j.ConnectivityError{}
+	driver := &recordingDriver{commitErr: lost}
+	work := func(tx neo4j.Transaction) (interface{}, error) {
+		return tx.Run("CREATE (a)", nil)
+	}
+
+	_, err := retryTransaction(driver, RetryPolicy{Retries: 3}, work, nil, clock.sleepUnlessStopped,
+		rand.New(rand.NewSource(1337)), nil)
+
+	// The server may have committed, so it isn't run again
+	assert.True(t, err == lost)
+	assert.Equal(t, []string{"begin", "CREATE (a)", "commit"}, driver.log)
+}
+
+func TestRetryPolicyStopsRetryingWhenStopped(t *testing.T) {
+	clock := &fakeSpaceTimeContinuum{}
+	driver := &recordingDriver{deadlocks: 5}
+	work := func(tx neo4j.Transaction) (interface{}, error) {
+		return tx.Run("CREATE (a)", nil)
+	}
+	stopCh := make(chan struct{})
+	close(stopCh)
+
+	_, err := retryTransaction(driver, RetryPolicy{Retries: 3}, work, nil, clock.sleepUnlessStopped,
+		rand.New(rand.NewSource(1337)), stopCh)
+
+	assert.Equal(t, errDeadlock, err)
+	assert.Equal(t, []string{"begin", "CREATE (a)", "rollback"}, driver.log)
+}
+
+// Runs transaction functions, like the real driver does, against a transaction that creates a node with each
+// query, and notes down the queries, how each transaction ended and which queries ran as auto-commit ones
+type recordingDriver struct {
+	fakeDriver
+	log []string
+	// Access modes of the sessions opened, in order
+	modes []neo4j.AccessMode
+	// Timeouts of the transactions and auto-commit queries run, in order
+	timeouts []time.Duration
+	// If set, the first transaction function is called again once it's done, like the driver does when the
+	// commit fails with a transient error
+	retryOnce bool
+	// How many of the queries run fail with a deadlock before the rest go through
+	deadlocks int
+	// If set, commits of transactions begun on the session fail with this
+	commitErr error
+}
+
+var errDeadlock = &neo4j.Neo4jError{Code: "Neo.TransientError.Transaction.DeadlockDetected", Msg: "deadlock"}
+
+func (d *recordingDriver) BeginTransaction(configurers ...func(*neo4j.TransactionConfig)) (neo4j.Transaction, error) {
+	d.configure(configurers)
+	d.log = append(d.log, "begin")
+	return &recordingTransaction{driver: d}, nil
+}
+
+func (d *recordingDriver) NewSession(config neo4j.SessionConfig) neo4j.Session {
+	d.modes = append(d.modes, config.AccessMode)
+	return d
+}
+
+func (d *recordingDriver) ReadTransaction(work neo4j.TransactionWork, configurers ...func(*neo4j.TransactionConfig)) (interface{}, error) {
+	d.log = append(d.log, "read")
+	return d.WriteTransaction(work, configurers...)
+}
+
+func (d *recordingDriver) WriteTransaction(work neo4j.TransactionWork, configurers ...func(*neo4j.TransactionConfig)) (interface{}, error) {
+	d.configure(configurers)
+	if d.retryOnce {
+		d.retryOnce = false
+		if _, err := work(&recordingTransaction{driver: d}); err == nil {
+			d.log = append(d.log, "retry")
+		}
+	}
+	result, err := work(&recordingTransaction{driver: d})
+	if err != nil {
+		d.log = append(d.log, "rollback")
+		return nil, err
+	}
+	d.log = append(d.log, "commit")
+	return result, nil
+}
+
+func (d *recordingDriver) Run(cypher string, params map[string]interface{}, configurers ...func(*neo4j.TransactionConfig)) (neo4j.Result, error) {
+	d.configure(configurers)
+	d.log = append(d.log, "auto-commit "+cypher)
+	if d.deadlocks > 0 {
+		d.deadlocks--
+		return nil, errDeadlock
+	}
+	return &createdResult{}, nil
+}
+
+func (d *recordingDriver) configure(configurers []func(*neo4j.TransactionConfig)) {
+	config := neo4j.TransactionConfig{}
+	for _, configure := range configurers {
+		configure(&config)
+	}
+	d.timeouts = append(d.timeouts, config.Timeout)
+}
+
+type recordingTransaction struct {
+	neo4j.Transaction
+	driver *recordingDriver
+}
+
+func (tx *recordingTransaction) Run(cypher string, params map[string]interface{}) (neo4j.Result, error) {
+	tx.driver.log = append(tx.driver.log, cypher)
+	if tx.driver.deadlocks > 0 {
+		tx.driver.deadlocks--
+		return nil, errDeadlock
+	}
+	return &createdResult{}, nil
+}
+
+func (tx *recordingTransaction) Commit() error {
+	tx.driver.log = append(tx.driver.log, "commit")
+	return tx.driver.commitErr
+}
+
+func (tx *recordingTransaction) Rollback() error {
+	tx.driver.log = append(tx.driver.log, "rollback")
+	return nil
+}
+
+// A result that created a node and returned a row saying so, leaving out everything the worker doesn't look at
+type createdResult struct {
+	neo4j.Result
+	// Whether Next has moved past the row
+	read bool
+}
+
+func (r *createdResult) Next() bool {
+	more := !r.read
+	r.read = true
+	return more
+}
+
+func (r *createdResult) Record() *neo4j.Record {
+	return &neo4j.Record{Keys: []string{"created"}, Values: []interface{}{int64(1)}}
+}
+
+func (r createdResult) Collect() ([]*neo4j.Record, error) {
+	return []*neo4j.Record{{Keys: []string{"created"}, Values: []interface{}{int64(1)}}}, nil
+}
+
+func (r createdResult) Consume() (neo4j.ResultSummary, error) {
+	return createdSummary{}, nil
+}
+
+type createdSummary struct {
+	neo4j.ResultSummary
+}
+
+func (s createdSummary) Counters() neo4j.Counters {
+	return createdCounters{}
+}
+
+// Like the driver gives when the server sends no timings
+func (s createdSummary) ResultAvailableAfter() time.Duration {
+	return 0
+}
+
+func (s createdSummary) ResultConsumedAfter() time.Duration {
+	return 0
+}
+
+type timedSummary struct {
+	neo4j.ResultSummary
+	available, consumed time.Duration
+}
+
+func (s timedSummary) ResultAvailableAfter() time.Duration {
+	return s.available
+}
+
+func (s timedSummary) ResultConsumedAfter() time.Duration {
+	return s.consumed
+}
+
+func TestServerTimeLeavesOutStatementsWithoutTimings(t *testing.T) {
+	var server serverTime
+	server = server.add(createdSummary{})
+	assert.Equal(t, serverTime{}, server)
+
+	server = server.add(timedSummary{available: 2 * time.Millisecond, consumed: 0})
+	server = server.add(createdSummary{})
+	server = server.add(timedSummary{available: time.Millisecond, consumed: 3 * time.Millisecond})
+	assert.Equal(t, serverTime{total: 6 * time.Millisecond, known: true}, server)
+}
+
+type createdCounters struct {
+	neo4j.Counters
+}
+
+func (c createdCounters) ContainsUpdates() bool {
+	return true
+}
+
+func (c createdCounters) NodesCreated() int {
+	return 1
+}
+
+func (c createdCounters) RelationshipsCreated() int {
+	return 0
+}
@@ -3,8 +3,10 @@ package neobench
 import (
 	"fmt"
 	"io"
+	"io/ioutil"
 	"math/rand"
 	"os"
+	"regexp"
 	"sort"
 	"strings"
 	"time"
@@ -16,6 +18,14 @@ import (
 // Useful for creating sharded workloads or other logic that tie in session-esque concepts
 const WorkerIdVar = "nbWorkerId"
 
+// Which of the clients running the same scripts this is, from 0, and how many of them there are, so a script can
+// give each client a range of keys of its own; see ClientWorkload.ClientId
+const ClientIdVar = "client_id"
+const NumClientsVar = "num_clients"
+
+// How many transactions the client has started before this one in the run, from 0
+const IterationVar = "iteration"
+
 type Workload struct {
 	// set on command line and built in
 	Variables map[string]interface{}
@@ -24,8 +34,56 @@ type Workload struct {
 
 	Rand      *rand.Rand
 	CsvLoader *CsvLoader
+	// If set, queries that reference undefined parameters fail rather than having the parameter bound to null
+	StrictParams bool
+	// If set, clients take turns running each script rather than drawing them at random by weight, see --head-to-head
+	HeadToHead bool
+	// If set, each transaction gets its variables from the next row of this file, see --params-file
+	Params *ParamsFile
+	// Counters of sequence(..), shared by all clients
+	Sequences *Sequences
+}
+
+// A copy of the workload that replays its params files from the first row, both --params-file and those of the
+// :params commands in its scripts; each benchmark run gets its own, see ParamsFile.Restart
+func (w Workload) RestartParams() Workload {
+	w.Params = w.Params.Restart()
+	scripts := make([]Script, 0, len(w.Scripts.Scripts))
+	for _, script := range w.Scripts.Scripts {
+		script.Commands = restartParams(script.Commands)
+		scripts = append(scripts, script)
+	}
+	w.Scripts = NewScripts(scripts...)
+	return w
+}
+
+func restartParams(commands []Command) []Command {
+	restarted := make([]Command, 0, len(commands))
+	for _, cmd := range commands {
+		switch c := cmd.(type) {
+		case ParamsCommand:
+			c.File = c.File.Restart()
+			cmd = c
+		case IfCommand:
+			branches := make([]IfBranch, 0, len(c.Branches))
+			for _, branch := range c.Branches {
+				branch.Commands = restartParams(branch.Commands)
+				branches = append(branches, branch)
+			}
+			c.Branches, c.Else = branches, restartParams(c.Else)
+			cmd = c
+		case ForCommand:
+			c.Commands = restartParams(c.Commands)
+			cmd = c
+		}
+		restarted = append(restarted, cmd)
+	}
+	return restarted
 }
 
+// Returned by ClientWorkload.Next when the params file is used up and --params-exhausted is stop
+var ErrParamsExhausted = errors.New("params file exhausted")
+
 // Scripts in a workload, and utilities to draw a weighted random script
 type Scripts struct {
 	// Scripts sorted by weight
@@ -102,12 +160,38 @@ func (w *WeightedRandom) Draw(r *rand.Rand) interface{} {
 }
 
 type Script struct {
+	// What results of the script go by: the name it gives itself with :name, or else Source
+	Name string
 	// Either path to script provided by user, or builtin:<name>
-	Name       string
-	Readonly   bool
-	Weight     float64
-	Commands   []Command
-	Autocommit bool
+	Source string
+	// Set by :description, to say what the script does in reports
+	Description string
+	Readonly    bool
+	Weight      float64
+	Commands    []Command
+	Autocommit  bool
+	// If set, the queries in this script are run in a random order each time, see `:opt shuffle`
+	Shuffle bool
+	// If set, the server is asked to throw away query results rather than send them, see `:opt discard`
+	DiscardResults bool
+	// If set, the script marks out its own transactions with :begin and :commit or :rollback, and queries
+	// outside of those run in a transaction each, rather than the whole script being one transaction
+	ExplicitTransactions bool
+	// Set by :opt timeout; transactions of the script that run for longer are stopped by the server, and fail
+	Timeout time.Duration
+	// Set by :mode to "read" or "write"; the script's transactions then run as that, rather than as read
+	// transactions if EXPLAIN says its queries only read and as write transactions otherwise
+	Mode string
+	// If set, some of the script's queries set variables from what they return, see :gset
+	Gsets bool
+	// Set by :retry; the script's transactions are then retried by that, rather than by the driver
+	Retry *RetryPolicy
+	// The :init block of the script, as a script of its own, run once before the benchmark if -i is set; nil if
+	// the script has none
+	Init *Script
+	// If set, the script is run by clients of its own at this total rate, in transactions per second, rather
+	// than drawn by weight with the other scripts, see -f path@rate=N
+	Rate float64
 }
 
 // Context that scripts are executed in; these are not thread safe, and are re-created on each script
@@ -115,11 +199,14 @@ type Script struct {
 type ScriptContext struct {
 	// Set true to skip sleeps and other things that should not execute during preflights
 	PreflightMode bool
-	Script        Script
-	Stderr        io.Writer
-	Vars          map[string]interface{}
-	Rand          *rand.Rand
-	CsvLoader     *CsvLoader
+	// Set true to fail queries that reference undefined parameters, see Workload.StrictParams
+	StrictParams bool
+	Script       Script
+	Stderr       io.Writer
+	Vars         map[string]interface{}
+	Rand         *rand.Rand
+	CsvLoader    *CsvLoader
+	Sequences    *Sequences
 }
 
 // Evaluate this script in the given context
@@ -129,45 +216,250 @@ func (s *Script) Eval(ctx ScriptContext) (UnitOfWork, error) {
 		Readonly:   s.Readonly,
 		Autocommit: s.Autocommit,
 		Statements: nil,
+
+		DiscardResults: s.DiscardResults,
+		Timeout:        s.Timeout,
+		Retry:          s.Retry,
+		explicit:       s.ExplicitTransactions,
+	}
+	switch s.Mode {
+	case "read":
+		uow.Readonly = true
+		uow.AccessMode = neo4j.AccessModeRead
+	case "write":
+		uow.Readonly = false
+	}
+	if uow.Retry != nil && uow.Readonly {
+		// With a retry policy, transactions are begun on the session, which runs them in its access mode
+		uow.AccessMode = neo4j.AccessModeRead
 	}
 
-	for _, cmd := range s.Commands {
-		if err := cmd.Execute(&ctx, &uow); err != nil {
-			return uow, err
-		}
+	if err := uow.evalFrom(s.Commands, 0, ctx); err != nil {
+		return uow, err
+	}
+
+	if s.Shuffle {
+		// Auto-commit statements each run on their own, so with :opt autocommit, they are shuffled all together
+		shuffleStatements(ctx.Rand, uow.Statements, !s.Autocommit)
 	}
 
 	return uow, nil
 }
 
+// Executes commands from the one at from on. A query with :gset stops it, since the commands after it can use
+// what the query returns; the worker has resume go on from there once the query has run. In preflight mode
+// nothing runs, so it goes on with the variables :gset sets as null, and if the commands after it fail on that,
+// stops there, and marks the unit as unchecked.
+func (u *UnitOfWork) evalFrom(commands []Command, from int, ctx ScriptContext) error {
+	gsets := false
+	for i := from; i < len(commands); i++ {
+		emitted := len(u.Statements)
+		if err := commands[i].Execute(&ctx, u); err != nil {
+			if ctx.PreflightMode && gsets {
+				u.unchecked = true
+				return nil
+			}
+			return err
+		}
+		if len(u.Statements) == emitted || len(u.Statements[len(u.Statements)-1].Gset) == 0 {
+			continue
+		}
+		if ctx.PreflightMode {
+			gsets = true
+			for _, column := range u.Statements[len(u.Statements)-1].Gset {
+				ctx.Vars[column] = nil
+			}
+			continue
+		}
+		u.paused = &pausedEval{commands: commands, next: i + 1, ctx: ctx}
+		return nil
+	}
+	if u.open {
+		return fmt.Errorf(":begin without a :commit or :rollback to end the transaction it starts")
+	}
+	return nil
+}
+
+// Shuffles statements in place, except lock statements, which keep their positions; if byTransaction is set,
+// statements stay in the transaction they are in
+func shuffleStatements(r *rand.Rand, statements []Statement, byTransaction bool) {
+	for start := 0; start < len(statements); {
+		end := start + 1
+		for end < len(statements) && (!byTransaction || statements[end].Transaction == statements[start].Transaction) {
+			end++
+		}
+		movable := make([]int, 0, end-start)
+		for i := start; i < end; i++ {
+			if !statements[i].Lock {
+				movable = append(movable, i)
+			}
+		}
+		r.Shuffle(len(movable), func(i, j int) {
+			a, b := movable[i], movable[j]
+			statements[a], statements[b] = statements[b], statements[a]
+		})
+		start = end
+	}
+}
+
+// Lists query parameters this script uses that are neither in the given variables nor assigned by a
+// :set command before the query that uses them. The database would see these as null. Unlike Validate, this
+// leaves out the :init and the variables neobench reads itself, which fail the script rather than go unnoticed.
+func (s *Script) UndefinedParams(vars map[string]interface{}) []string {
+	undefined := make(map[string]bool)
+	walkVarUses(s.Commands, createVars(vars, 0), func(name string, defined, param bool) {
+		if param && !defined {
+			undefined[name] = true
+		}
+	})
+	return sortedNames(undefined)
+}
+
 func (s *Workload) NewClient() ClientWorkload {
-	return ClientWorkload{
-		Variables: s.Variables,
-		Scripts:   s.Scripts,
-		Rand:      rand.New(rand.NewSource(s.Rand.Int63())),
-		Stderr:    os.Stderr,
-		CsvLoader: s.CsvLoader,
+	client := ClientWorkload{
+		Variables:    s.Variables,
+		Scripts:      s.Scripts,
+		Rand:         rand.New(rand.NewSource(s.Rand.Int63())),
+		Stderr:       os.Stderr,
+		CsvLoader:    s.CsvLoader,
+		StrictParams: s.StrictParams,
+		HeadToHead:   s.HeadToHead,
+		Params:       s.Params,
+		Sequences:    s.Sequences,
+	}
+	if s.HeadToHead && len(s.Scripts.Scripts) > 0 {
+		// Clients start on a random script, so the scripts don't run in lockstep across clients
+		client.turn = client.Rand.Intn(len(s.Scripts.Scripts))
 	}
+	return client
+}
+
+// A client of a run: the workload it runs, and the time between its transactions, zero to go as fast as it can,
+// see Worker.RunBenchmark
+type ClientPlan struct {
+	Workload        ClientWorkload
+	TransactionRate time.Duration
+	// If set, TransactionRate is an upper bound rather than a schedule, see Worker.CapRate
+	CapRate bool
+	// If set, the client follows this rather than TransactionRate, as one of Clients, see Worker.FollowProfile
+	Profile *LoadProfile
+	Clients int
+	// If set, the client shares this with the other clients running at the same rate, rather than keep to
+	// TransactionRate on its own, see Worker.ShareSchedule
+	Schedule *Schedule
+}
+
+// The clients of a run. numClients of them draw from the scripts without a rate of their own: in latency mode sharing
+// a Schedule at rate, or following their share of profile if it's set, and otherwise as fast as they can, up to their
+// share of rate if it's above zero. Each script with a rate of its own, see Script.Rate, gets numClients more,
+// running only that script on a Schedule of their own at that rate, in either mode. That way a fixed background load
+// runs alongside the rest of the workload, whatever that does.
+func (s *Workload) PlanClients(numClients int, latencyMode bool, rate float64, profile *LoadProfile) []ClientPlan {
+	shared := make([]Script, 0, len(s.Scripts.Scripts))
+	paced := make([]Script, 0)
+	for _, script := range s.Scripts.Scripts {
+		if script.Rate > 0 {
+			paced = append(paced, script)
+		} else {
+			shared = append(shared, script)
+		}
+	}
+
+	plans := make([]ClientPlan, 0, numClients*(1+len(paced)))
+	if len(shared) > 0 {
+		wrk := *s
+		wrk.Scripts = NewScripts(shared...)
+		transactionRate := time.Duration(0)
+		if latencyMode || rate > 0 {
+			transactionRate = TotalRatePerSecondToDurationPerClient(numClients, rate)
+		}
+		var schedule *Schedule
+		if latencyMode && profile == nil && rate > 0 {
+			schedule = NewSchedule(rate, numClients)
+		}
+		for i := 0; i < numClients; i++ {
+			plans = append(plans, ClientPlan{Workload: wrk.NewClient().As(i, numClients), TransactionRate: transactionRate, CapRate: !latencyMode && rate > 0,
+				Profile: profile, Clients: numClients, Schedule: schedule})
+		}
+	}
+	for _, script := range paced {
+		wrk := *s
+		wrk.Scripts = NewScripts(script)
+		transactionRate := TotalRatePerSecondToDurationPerClient(numClients, script.Rate)
+		schedule := NewSchedule(script.Rate, numClients)
+		for i := 0; i < numClients; i++ {
+			plans = append(plans, ClientPlan{Workload: wrk.NewClient().As(i, numClients), TransactionRate: transactionRate, Schedule: schedule})
+		}
+	}
+	return plans
 }
 
 type ClientWorkload struct {
 	Readonly bool
 	// variables set on command line and built-in
-	Variables map[string]interface{}
-	Scripts   Scripts
-	Rand      *rand.Rand
-	Stderr    io.Writer
-	CsvLoader *CsvLoader
+	Variables    map[string]interface{}
+	Scripts      Scripts
+	Rand         *rand.Rand
+	Stderr       io.Writer
+	CsvLoader    *CsvLoader
+	StrictParams bool
+	HeadToHead   bool
+	Params       *ParamsFile
+	Sequences    *Sequences
+	// Which of NumClients clients running the same scripts this is, as $client_id and $num_clients; a script with a
+	// rate of its own has clients of its own, which count from 0 again, see PlanClients
+	ClientId, NumClients int
+	// With HeadToHead, index of the script to run next
+	turn int
+	// How many times Next has been called, see IterationVar
+	iteration int64
+}
+
+// This client, as the given one of numClients
+func (s ClientWorkload) As(clientId, numClients int) ClientWorkload {
+	s.ClientId = clientId
+	s.NumClients = numClients
+	return s
 }
 
 func (s *ClientWorkload) Next(workerId int64) (UnitOfWork, error) {
-	script := s.Scripts.Choose(s.Rand)
+	var script Script
+	if s.HeadToHead {
+		script = s.Scripts.Scripts[s.turn%len(s.Scripts.Scripts)]
+		s.turn++
+	} else {
+		script = s.Scripts.Choose(s.Rand)
+	}
+	vars := createVars(s.Variables, workerId)
+	if _, overridden := s.Variables[IterationVar]; !overridden {
+		vars[IterationVar] = s.iteration
+	}
+	s.iteration++
+	if s.NumClients > 0 {
+		if _, overridden := s.Variables[ClientIdVar]; !overridden {
+			vars[ClientIdVar] = int64(s.ClientId)
+		}
+		if _, overridden := s.Variables[NumClientsVar]; !overridden {
+			vars[NumClientsVar] = int64(s.NumClients)
+		}
+	}
+	if s.Params != nil {
+		row, ok := s.Params.Next(s.Rand)
+		if !ok {
+			return UnitOfWork{}, ErrParamsExhausted
+		}
+		for k, v := range row {
+			vars[k] = v
+		}
+	}
 	return script.Eval(ScriptContext{
-		Script:    script,
-		Stderr:    s.Stderr,
-		Vars:      createVars(s.Variables, workerId),
-		Rand:      s.Rand,
-		CsvLoader: s.CsvLoader,
+		StrictParams: s.StrictParams,
+		Script:       script,
+		Stderr:       s.Stderr,
+		Vars:         vars,
+		Rand:         s.Rand,
+		CsvLoader:    s.CsvLoader,
+		Sequences:    s.Sequences,
 	})
 }
 
@@ -177,11 +469,118 @@ type UnitOfWork struct {
 	Readonly   bool
 	Statements []Statement
 	Autocommit bool
+	// Of each transaction of the unit, unless its statements set one of their own; zero for none
+	Timeout time.Duration
+	// Of the session to run the unit in; read for scripts with :mode read, so a cluster routes auto-commit queries
+	// to its readers, as it does read transactions
+	AccessMode neo4j.AccessMode
+	// Nothing needs the records these statements return
+	DiscardResults bool
+	// Retries the transactions of the unit rather than the driver, if set, see Script.Retry
+	Retry *RetryPolicy
+	// Transactions, by Statement.Transaction, that end in :rollback rather than :commit
+	RolledBack map[int]bool
+
+	// Whether the script uses :begin, whether one of its transactions is open as it's evaluated, how many it has
+	// started, and whether the next statement can go in the last one, see transaction
+	explicit bool
+	open     bool
+	started  int
+	joinable bool
+	// Set if the script is evaluated up to a :gset, rather than to the end, see evalFrom
+	paused *pausedEval
+	// Set in preflight mode if the commands after a :gset failed on the nulls it set, so the statements after
+	// weren't checked
+	unchecked bool
+}
+
+// The transaction the next statement goes in: all of them go in the same one unless the script uses :begin,
+// in which case a statement outside :begin and :commit or :rollback gets one of its own. An auto-commit
+// statement is always one of its own, and the statements after it go in a new one.
+func (u *UnitOfWork) transaction(autocommit bool) int {
+	switch {
+	case autocommit:
+		u.started++
+		u.joinable = false
+	case u.open, u.joinable:
+	default:
+		u.started++
+		u.joinable = !u.explicit
+	}
+	return u.started - 1
+}
+
+// A run of statements in the same transaction, as far as the unit is evaluated; a :gset in it can add more
+type unitTransaction struct {
+	// Index in UnitOfWork.Statements of its first statement, and its Statement.Transaction
+	start       int
+	transaction int
+	statements  []Statement
+	rolledBack  bool
+	// Run as an auto-commit query rather than in a transaction function; there's one statement if so
+	autocommit bool
+	// The shortest timeout of its statements, or else that of the unit; zero for none
+	timeout time.Duration
+}
+
+// The transactions of the unit, in the order they run, as far as it is evaluated; there's always at least one,
+// so a unit without statements still runs an empty transaction
+func (u UnitOfWork) transactions() []unitTransaction {
+	txs := []unitTransaction{u.transactionAt(0)}
+	for start := len(txs[0].statements); start < len(u.Statements); start += len(txs[len(txs)-1].statements) {
+		txs = append(txs, u.transactionAt(start))
+	}
+	return txs
+}
+
+// The transaction of the statement at start, up to the last statement of it the unit is evaluated to; past the
+// last statement, an empty one
+func (u UnitOfWork) transactionAt(start int) unitTransaction {
+	if start >= len(u.Statements) {
+		return unitTransaction{start: start, timeout: u.Timeout}
+	}
+	end := start + 1
+	for end < len(u.Statements) && u.Statements[end].Transaction == u.Statements[start].Transaction {
+		end++
+	}
+	tx := unitTransaction{
+		start:       start,
+		transaction: u.Statements[start].Transaction,
+		statements:  u.Statements[start:end],
+		rolledBack:  u.RolledBack[u.Statements[start].Transaction],
+		autocommit:  u.Statements[start].Autocommit,
+	}
+	for _, s := range tx.statements {
+		timeout := s.Timeout
+		if timeout == 0 {
+			timeout = u.Timeout
+		}
+		if timeout > 0 && (tx.timeout == 0 || timeout < tx.timeout) {
+			tx.timeout = timeout
+		}
+	}
+	return tx
 }
 
 type Statement struct {
 	Query  string
 	Params map[string]interface{}
+	// Emitted by :lock; stays where it is when the script is shuffled, so locks are still taken in the declared order
+	Lock bool
+	// Id of the query or :lock that emitted this, see QueryCommand.Id; identifies the statement across runs of
+	// the script, for --statement-latencies
+	Command int
+	// Which of the transactions of the unit this runs in, counting from zero; always zero unless the script
+	// uses :begin or :autocommit
+	Transaction int
+	// Run on its own as an auto-commit query, see :autocommit and :opt autocommit
+	Autocommit bool
+	// Set by a :timeout before the query, to bound the transaction it runs in; zero for the timeout of the unit
+	Timeout time.Duration
+	// Checked against the records the statement returns, see :assert
+	Assertions []ResultAssertion
+	// Columns of the first record the statement returns to set as variables, see :gset
+	Gset []string
 }
 
 type Command interface {
@@ -189,17 +588,32 @@ type Command interface {
 }
 
 type QueryCommand struct {
+	// Numbers the queries and :locks of the script, :include files and all, in the order they're written in,
+	// counting from zero; the statements a query emits, in an :if branch, a :for body or wherever, carry it
+	Id    int
 	Query string
 	// Parameters used in the above query
 	RemoteParams []string
 	// Locally substituted parameters
 	LocalParams []string
+	// Set by an :autocommit before the query, to run it as an auto-commit query
+	Autocommit bool
+	// Set by a :timeout before the query, see Statement.Timeout
+	Timeout time.Duration
+	// Set by :assert commands after the query, to check what it returns
+	Assertions []ResultAssertion
+	// Set by a :gset after the query, see Statement.Gset
+	Gset []string
 }
 
 func (c QueryCommand) Execute(ctx *ScriptContext, uow *UnitOfWork) error {
 	params := make(map[string]interface{})
 	for _, pname := range c.RemoteParams {
-		params[pname] = ctx.Vars[pname]
+		value, found := ctx.Vars[pname]
+		if !found && ctx.StrictParams {
+			return fmt.Errorf("query uses $%s, but that parameter is not defined; set it with -D or :set", pname)
+		}
+		params[pname] = value
 	}
 	query := c.Query
 	if len(c.LocalParams) > 0 {
@@ -211,9 +625,19 @@ func (c QueryCommand) Execute(ctx *ScriptContext, uow *UnitOfWork) error {
 			query = strings.ReplaceAll(query, fmt.Sprintf("$$%s", pname), literal)
 		}
 	}
+	autocommit := c.Autocommit || uow.Autocommit
+	if c.Autocommit && uow.open {
+		return fmt.Errorf(":autocommit query inside :begin; it commits on its own, so it needs to be outside of the transaction")
+	}
 	uow.Statements = append(uow.Statements, Statement{
-		Query:  query,
-		Params: params,
+		Query:       query,
+		Params:      params,
+		Command:     c.Id,
+		Transaction: uow.transaction(autocommit),
+		Autocommit:  autocommit,
+		Timeout:     c.Timeout,
+		Assertions:  newResultAssertions(c.Assertions, ctx),
+		Gset:        c.Gset,
 	})
 	return nil
 }
@@ -232,6 +656,10 @@ func varToCypherLiteral(v interface{}) (string, error) {
 		}
 	case string:
 		return fmt.Sprintf("\"%s\"", v), nil // TODO escaping
+	case time.Time:
+		return fmt.Sprintf("datetime(\"%s\")", v.Format(time.RFC3339Nano)), nil
+	case neo4j.Date:
+		return fmt.Sprintf("date(\"%s\")", time.Time(v).Format("2006-01-02")), nil
 	case []interface{}:
 		var sb strings.Builder
 		sb.WriteString("[")
@@ -266,29 +694,363 @@ func (c SetCommand) Execute(ctx *ScriptContext, uow *UnitOfWork) error {
 	return nil
 }
 
+// :if <condition> .. :elif <condition> .. :else .. :endif; runs the commands of the first branch whose condition
+// is true, or those of :else if none is
+type IfCommand struct {
+	Branches []IfBranch
+	Else     []Command
+}
+
+type IfBranch struct {
+	Condition Expression
+	Commands  []Command
+}
+
+func (c IfCommand) Execute(ctx *ScriptContext, uow *UnitOfWork) error {
+	if ctx.PreflightMode {
+		// Every branch is run, so every query the script may send is checked, and a write in a branch that is
+		// rarely taken still marks the script as one that writes
+		for _, branch := range c.Branches {
+			if err := executeAll(branch.Commands, ctx, uow); err != nil {
+				return err
+			}
+		}
+		return executeAll(c.Else, ctx, uow)
+	}
+	for _, branch := range c.Branches {
+		value, err := branch.Condition.Eval(ctx)
+		if err != nil {
+			return err
+		}
+		taken, ok := value.(bool)
+		if !ok {
+			return fmt.Errorf(":if and :elif need a boolean condition, got %s (which is %T)", branch.Condition.String(), value)
+		}
+		if taken {
+			return executeAll(branch.Commands, ctx, uow)
+		}
+	}
+	return executeAll(c.Else, ctx, uow)
+}
+
+// :for <var> in <list> .. :endfor; runs the commands once for each item of the list, with the variable set to it
+type ForCommand struct {
+	VarName  string
+	Items    Expression
+	Commands []Command
+}
+
+func (c ForCommand) Execute(ctx *ScriptContext, uow *UnitOfWork) error {
+	value, err := c.Items.Eval(ctx)
+	if err != nil {
+		return err
+	}
+	items, ok := value.([]interface{})
+	if !ok {
+		return fmt.Errorf(":for needs a list to loop over, got %s (which is %T)", c.Items.String(), value)
+	}
+	if ctx.PreflightMode {
+		// Once is enough to check the queries in the loop; they run even if the list is empty, so they're checked
+		// all the same
+		ctx.Vars[c.VarName] = nil
+		if len(items) > 0 {
+			ctx.Vars[c.VarName] = items[0]
+		}
+		return executeAll(c.Commands, ctx, uow)
+	}
+	for _, item := range items {
+		ctx.Vars[c.VarName] = item
+		if err := executeAll(c.Commands, ctx, uow); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func executeAll(commands []Command, ctx *ScriptContext, uow *UnitOfWork) error {
+	for _, cmd := range commands {
+		if err := cmd.Execute(ctx, uow); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// :params "<path>" [sequential|random]; sets the columns of the next row of a CSV file as variables, like
+// --params-file does for all scripts. Sequential rows are handed out in order across all clients, and start over
+// from the first row once they are used up.
+type ParamsCommand struct {
+	File   *ParamsFile
+	Random bool
+}
+
+func (c ParamsCommand) Execute(ctx *ScriptContext, uow *UnitOfWork) error {
+	var row map[string]interface{}
+	if ctx.PreflightMode {
+		// Checking the script shouldn't use up a row
+		row = c.File.FirstRow()
+	} else if c.Random {
+		row = c.File.Pick(ctx.Rand)
+	} else {
+		row, _ = c.File.Next(ctx.Rand)
+	}
+	for name, value := range row {
+		ctx.Vars[name] = value
+	}
+	return nil
+}
+
+// :shell <command line> or :setshell <var> <command line>; runs the line with the shell of the system, with the
+// variables it uses as $var or ${var} in the environment of the shell, so their values are never read as part of
+// the command line. :setshell sets the variable to what the command writes to stdout, trimmed, and as a number
+// if it is one.
+type ShellCommand struct {
+	Line string
+	// Set for :setshell
+	VarName string
+}
+
+var shellVarPattern = regexp.MustCompile(`\$(?:\{([A-Za-z_][A-Za-z0-9_]*)\}|([A-Za-z_][A-Za-z0-9_]*))`)
+
+// The names of the variables the line reads, $var or ${var}, in order and with repeats
+func shellVarNames(line string) []string {
+	var names []string
+	for _, ref := range shellVarPattern.FindAllStringSubmatch(line, -1) {
+		names = append(names, ref[1]+ref[2])
+	}
+	return names
+}
+
+func (c ShellCommand) Execute(ctx *ScriptContext, uow *UnitOfWork) error {
+	if ctx.PreflightMode {
+		// Checking the script shouldn't start data generators or chaos tooling
+		if c.VarName != "" {
+			ctx.Vars[c.VarName] = nil
+		}
+		return nil
+	}
+	// Names that aren't variables of the script are left to the shell, like $HOME
+	env := os.Environ()
+	for _, name := range shellVarNames(c.Line) {
+		value, found := ctx.Vars[name]
+		if !found {
+			continue
+		}
+		str, err := toString(value)
+		if err != nil {
+			return errors.Wrapf(err, "in '%s'", c.Line)
+		}
+		env = append(env, name+"="+str)
+	}
+
+	stderr := ctx.Stderr
+	if stderr == nil {
+		stderr = ioutil.Discard
+	}
+	cmd := shellCommand(c.Line)
+	cmd.Env = env
+	cmd.Stderr = stderr
+	if c.VarName == "" {
+		cmd.Stdout = stderr
+		if err := cmd.Run(); err != nil {
+			return errors.Wrapf(err, ":shell %s failed", c.Line)
+		}
+		return nil
+	}
+	out, err := cmd.Output()
+	if err != nil {
+		return errors.Wrapf(err, ":setshell %s %s failed", c.VarName, c.Line)
+	}
+	ctx.Vars[c.VarName] = csvParseCell(strings.TrimSpace(string(out)))
+	return nil
+}
+
+// :begin; the queries up to the next :commit or :rollback run in a transaction of their own
+type BeginCommand struct{}
+
+func (c BeginCommand) Execute(ctx *ScriptContext, uow *UnitOfWork) error {
+	if uow.open {
+		return fmt.Errorf(":begin inside a transaction; transactions don't nest, end the one before with :commit or :rollback")
+	}
+	uow.open = true
+	uow.started++
+	return nil
+}
+
+// :commit or :rollback; ends the transaction the last :begin started. A rolled back transaction runs its queries
+// as any other, and is then rolled back rather than committed, so nothing it wrote counts towards write volume.
+type EndCommand struct {
+	Rollback bool
+}
+
+func (c EndCommand) Execute(ctx *ScriptContext, uow *UnitOfWork) error {
+	if !uow.open {
+		if c.Rollback {
+			return fmt.Errorf(":rollback without a :begin before it")
+		}
+		return fmt.Errorf(":commit without a :begin before it")
+	}
+	uow.open = false
+	if c.Rollback {
+		if uow.RolledBack == nil {
+			uow.RolledBack = make(map[int]bool)
+		}
+		uow.RolledBack[uow.started-1] = true
+	}
+	return nil
+}
+
 type SleepCommand struct {
 	Duration Expression
 	Unit     time.Duration
+	// Whether the script gave the unit, rather than leaving it at seconds
+	UnitGiven bool
 }
 
 func (c SleepCommand) Execute(ctx *ScriptContext, uow *UnitOfWork) error {
-	sleepNumber, err := c.Duration.Eval(ctx)
+	duration, err := c.Evaluate(ctx)
 	if err != nil {
 		return err
 	}
-	sleepInt, ok := sleepNumber.(int64)
-	if !ok {
-		return fmt.Errorf("\\sleep must be given an integer expression, got %v", sleepNumber)
-	}
 
 	if ctx.PreflightMode {
 		return nil
 	}
 
-	time.Sleep(time.Duration(sleepInt) * c.Unit)
+	time.Sleep(duration)
 	return nil
 }
 
+// How long to sleep for; the expression gives a number of units, whole or not, or a duration as a string, like
+// "250ms", which has its own unit
+func (c SleepCommand) Evaluate(ctx *ScriptContext) (time.Duration, error) {
+	value, err := c.Duration.Eval(ctx)
+	if err != nil {
+		return 0, err
+	}
+	var duration time.Duration
+	switch v := value.(type) {
+	case int64:
+		duration = time.Duration(v) * c.Unit
+	case float64:
+		duration = time.Duration(v * float64(c.Unit))
+	case string:
+		if c.UnitGiven {
+			return 0, fmt.Errorf(":sleep with a unit needs a number, got '%s', which has a unit of its own", v)
+		}
+		duration, err = time.ParseDuration(v)
+		if err != nil {
+			return 0, fmt.Errorf(":sleep needs a number or a duration like \"250ms\", got '%s'", v)
+		}
+	default:
+		return 0, fmt.Errorf(":sleep needs a number or a duration like \"250ms\", got %v", value)
+	}
+	if duration < 0 {
+		return 0, fmt.Errorf(":sleep can't sleep for a negative duration, got %s", duration)
+	}
+	return duration, nil
+}
+
+// Order in which :lock takes its locks
+type LockOrder int
+
+const (
+	LockAscending  LockOrder = 0
+	LockDescending LockOrder = 1
+	// In the order the expression lists the keys
+	LockGiven LockOrder = 2
+)
+
+// The parameter :lock passes its keys in
+const LockKeysParam = "nbLockKeys"
+
+// Takes write locks on the nodes with the given label and keys, one at a time, in a declared order. Transactions
+// that lock overlapping nodes in a consistent order queue up behind each other rather than deadlock, so this
+// lets a script model an application that orders its locks, or, with LockGiven, one that doesn't.
+type LockCommand struct {
+	// See QueryCommand.Id
+	Id       int
+	Label    string
+	Property string
+	// Evaluates to a single key, or to a list of keys
+	Keys  Expression
+	Order LockOrder
+}
+
+func (c LockCommand) Execute(ctx *ScriptContext, uow *UnitOfWork) error {
+	value, err := c.Keys.Eval(ctx)
+	if err != nil {
+		return err
+	}
+	keys, ok := value.([]interface{})
+	if !ok {
+		keys = []interface{}{value}
+	} else {
+		// The expression may be a list literal we'd otherwise sort in place
+		keys = append([]interface{}(nil), keys...)
+	}
+
+	if c.Order != LockGiven {
+		var sortErr error
+		sort.SliceStable(keys, func(i, j int) bool {
+			cmp, err := compareLockKeys(keys[i], keys[j])
+			if err != nil {
+				sortErr = err
+			}
+			if c.Order == LockDescending {
+				return cmp > 0
+			}
+			return cmp < 0
+		})
+		if sortErr != nil {
+			return errors.Wrapf(sortErr, ":lock %s.%s can't order its keys", c.Label, c.Property)
+		}
+	}
+
+	// The SET does nothing, but the server takes the node's write lock to do it, and holds it until the
+	// transaction ends. UNWIND hands rows to MATCH one at a time, so locks are taken in list order.
+	uow.Statements = append(uow.Statements, Statement{
+		Query: fmt.Sprintf("UNWIND $%s AS key MATCH (n:`%s` {`%s`: key}) SET n._lock = n._lock",
+			LockKeysParam, c.Label, c.Property),
+		Params:      map[string]interface{}{LockKeysParam: keys},
+		Lock:        true,
+		Command:     c.Id,
+		Transaction: uow.transaction(uow.Autocommit),
+		Autocommit:  uow.Autocommit,
+	})
+	return nil
+}
+
+// Numbers compare with numbers, strings with strings; anything else can't be ordered
+func compareLockKeys(a, b interface{}) (int, error) {
+	if as, ok := a.(string); ok {
+		if bs, ok := b.(string); ok {
+			return strings.Compare(as, bs), nil
+		}
+	} else if af, ok := lockKeyNumber(a); ok {
+		if bf, ok := lockKeyNumber(b); ok {
+			switch {
+			case af < bf:
+				return -1, nil
+			case af > bf:
+				return 1, nil
+			}
+			return 0, nil
+		}
+	}
+	return 0, fmt.Errorf("keys need to be all numbers or all strings, got %v and %v", a, b)
+}
+
+func lockKeyNumber(v interface{}) (float64, bool) {
+	switch v := v.(type) {
+	case int64:
+		return float64(v), true
+	case float64:
+		return v, true
+	}
+	return 0, false
+}
+
 // Validates that a workload doesn't have syntax errors etc, and tells us if it is read-only
 func WorkloadPreflight(driver neo4j.Driver, dbName string, script Script, vars map[string]interface{},
 	csvLoader *CsvLoader) (readonly bool, err error) {
@@ -330,13 +1092,18 @@ func WorkloadPreflight(driver neo4j.Driver, dbName string, script Script, vars m
 	if err != nil {
 		return false, errors.Wrapf(err, "script '%s' failed preflight checks", script.Name)
 	}
-	readonly = readonlyRaw.(bool)
+	// What the queries that weren't checked do isn't known, so they may write
+	readonly = readonlyRaw.(bool) && !unitOfWork.unchecked
 	return
 }
 
 func createVars(globalVars map[string]interface{}, workerId int64) map[string]interface{} {
 	vars := make(map[string]interface{})
 	vars[WorkerIdVar] = workerId
+	// As the only client, unless ClientWorkload.Next says otherwise
+	vars[ClientIdVar] = int64(0)
+	vars[NumClientsVar] = int64(1)
+	vars[IterationVar] = int64(0)
 	for k, v := range globalVars {
 		vars[k] = v
 	}
@@ -48,3 +48,244 @@ func TestChooseWeightedWorkload(t *testing.T) {
 	assert.InDelta(t, b.Weight, bNorm, maxDiffOnB, "seed=%d", seed)
 	assert.InDelta(t, c.Weight, cNorm, maxDiffOnC, "seed=%d", seed)
 }
+
+func TestUndefinedParams(t *testing.T) {
+	script, err := Parse("undefined", `
+:set a 1
+RETURN $a, $b, $$c, {d}, $scale, $nbWorkerId;
+:set b 2
+RETURN $b, $e;`, 1)
+	assert.NoError(t, err)
+
+	assert.Equal(t, []string{"b", "c", "d", "e"}, script.UndefinedParams(map[string]interface{}{"scale": int64(1)}))
+	assert.Equal(t, []string{"b", "e"}, script.UndefinedParams(map[string]interface{}{"scale": int64(1), "c": 1, "d": 1}))
+}
+
+func TestUndefinedParamsInBranches(t *testing.T) {
+	script, err := Parse("undefined", `
+:if $scale > 1
+:set a 1
+:else
+RETURN $b;
+:endif
+:for i in [1, 2]
+RETURN $i;
+:endfor
+RETURN $a;`, 1)
+	assert.NoError(t, err)
+
+	assert.Equal(t, []string{"b"}, script.UndefinedParams(map[string]interface{}{"scale": int64(1)}))
+}
+
+func TestUndefinedParamsAreOnlyThoseOfQueries(t *testing.T) {
+	script, err := Parse("undefined", ":init\nRETURN $seeded;\n:endinit\n:set a $b\n:sleep $pause ms\nRETURN $a, $c;", 1)
+	assert.NoError(t, err)
+
+	// Validate reports all of them, the :init and what neobench reads itself as well
+	assert.Equal(t, []string{"c"}, script.UndefinedParams(map[string]interface{}{}))
+	assert.Equal(t, []string{"b", "c", "pause", "seeded"}, script.Validate(map[string]interface{}{}).Undefined)
+}
+
+func TestStrictParamsFailsAtRuntime(t *testing.T) {
+	script, err := Parse("strict", `RETURN $nope;`, 1)
+	assert.NoError(t, err)
+
+	_, err = script.Eval(ScriptContext{
+		StrictParams: true,
+		Vars:         map[string]interface{}{},
+		Rand:         rand.New(rand.NewSource(1337)),
+	})
+	assert.EqualError(t, err, "query uses $nope, but that parameter is not defined; set it with -D or :set")
+}
+
+func TestHeadToHeadAlternatesScripts(t *testing.T) {
+	a, err := Parse("a", "RETURN 'a';", 1)
+	assert.NoError(t, err)
+	// Weights are ignored in head-to-head mode
+	b, err := Parse("b", "RETURN 'b';", 100)
+	assert.NoError(t, err)
+	wrk := Workload{
+		Variables:  map[string]interface{}{},
+		Scripts:    NewScripts(a, b),
+		Rand:       rand.New(rand.NewSource(1337)),
+		HeadToHead: true,
+	}
+
+	client := wrk.NewClient()
+	previous := ""
+	for i := 0; i < 10; i++ {
+		uow, err := client.Next(0)
+		assert.NoError(t, err)
+		assert.NotEqual(t, previous, uow.ScriptName)
+		previous = uow.ScriptName
+	}
+}
+
+func TestScriptsWithARateGetClientsOfTheirOwn(t *testing.T) {
+	reads, err := Parse("reads", `RETURN "reads";`, 1)
+	assert.NoError(t, err)
+	other, err := Parse("other", `RETURN "other";`, 1)
+	assert.NoError(t, err)
+	writes, err := Parse("writes", `RETURN "writes";`, 1)
+	assert.NoError(t, err)
+	writes.Rate = 100
+	wrk := Workload{
+		Variables: map[string]interface{}{},
+		Scripts:   NewScripts(reads, other, writes),
+		Rand:      rand.New(rand.NewSource(1337)),
+	}
+
+	plans := wrk.PlanClients(2, false, 0, nil)
+
+	assert.Len(t, plans, 4)
+	for _, plan := range plans[:2] {
+		assert.Equal(t, time.Duration(0), plan.TransactionRate)
+		for i := 0; i < 20; i++ {
+			uow, err := plan.Workload.Next(0)
+			assert.NoError(t, err)
+			assert.NotEqual(t, "writes", uow.ScriptName)
+		}
+	}
+	// 50 each, to make 100 between them, in throughput mode too
+	for _, plan := range plans[2:] {
+		assert.Equal(t, 20*time.Millisecond, plan.TransactionRate)
+		uow, err := plan.Workload.Next(0)
+		assert.NoError(t, err)
+		assert.Equal(t, "writes", uow.ScriptName)
+	}
+
+	assert.Nil(t, plans[0].Schedule)
+	assert.NotNil(t, plans[2].Schedule)
+	assert.Same(t, plans[2].Schedule, plans[3].Schedule)
+
+	plans = wrk.PlanClients(2, true, 10, nil)
+	assert.Equal(t, 200*time.Millisecond, plans[0].TransactionRate)
+	assert.Equal(t, 20*time.Millisecond, plans[3].TransactionRate)
+	// Clients at the same rate share a schedule
+	assert.Same(t, plans[0].Schedule, plans[1].Schedule)
+	assert.NotSame(t, plans[1].Schedule, plans[2].Schedule)
+}
+
+func TestClientsKnowWhichOfTheirGroupTheyAre(t *testing.T) {
+	reads, err := Parse("reads", `RETURN $client_id, $num_clients;`, 1)
+	assert.NoError(t, err)
+	writes, err := Parse("writes", `RETURN $client_id, $num_clients;`, 1)
+	assert.NoError(t, err)
+	writes.Rate = 100
+	wrk := Workload{
+		Variables: map[string]interface{}{},
+		Scripts:   NewScripts(reads, writes),
+		Rand:      rand.New(rand.NewSource(1337)),
+	}
+
+	ids := make([]interface{}, 0)
+	for i, plan := range wrk.PlanClients(3, false, 0, nil) {
+		uow, err := plan.Workload.Next(int64(i))
+		assert.NoError(t, err)
+		assert.Equal(t, int64(3), uow.Statements[0].Params["num_clients"])
+		ids = append(ids, uow.Statements[0].Params["client_id"])
+	}
+	// The clients of the script with a rate of its own count from 0 again
+	assert.Equal(t, []interface{}{int64(0), int64(1), int64(2), int64(0), int64(1), int64(2)}, ids)
+
+	// As set with -D, they stay as they are
+	wrk.Variables = map[string]interface{}{"client_id": int64(7)}
+	uow, err := wrk.PlanClients(3, false, 0, nil)[1].Workload.Next(1)
+	assert.NoError(t, err)
+	assert.Equal(t, int64(7), uow.Statements[0].Params["client_id"])
+
+	assert.Equal(t, []string{}, reads.UndefinedParams(map[string]interface{}{}))
+}
+
+func TestIterationCountsTheTransactionsOfEachClient(t *testing.T) {
+	script, err := Parse("iteration", `:set key $iteration * $num_clients + $client_id
+RETURN $key;`, 1)
+	assert.NoError(t, err)
+	wrk := Workload{
+		Variables: map[string]interface{}{},
+		Scripts:   NewScripts(script),
+		Rand:      rand.New(rand.NewSource(1337)),
+	}
+
+	keys := make(map[interface{}]bool)
+	for i, plan := range wrk.PlanClients(2, false, 0, nil) {
+		for n := 0; n < 3; n++ {
+			uow, err := plan.Workload.Next(int64(i))
+			assert.NoError(t, err)
+			keys[uow.Statements[0].Params["key"]] = true
+		}
+	}
+	assert.Equal(t, map[interface{}]bool{int64(0): true, int64(1): true, int64(2): true, int64(3): true, int64(4): true, int64(5): true}, keys)
+}
+
+func TestStatementsKnowTheCommandThatEmittedThem(t *testing.T) {
+	script, err := Parse("s", `
+:set a 1
+MATCH (n) RETURN n;
+:set b 2
+CREATE (n);`, 1)
+	assert.NoError(t, err)
+	script.Shuffle = true
+
+	for seed := int64(0); seed < 5; seed++ {
+		uow, err := script.Eval(ScriptContext{
+			Vars: map[string]interface{}{},
+			Rand: rand.New(rand.NewSource(seed)),
+		})
+		assert.NoError(t, err)
+		for _, statement := range uow.Statements {
+			if statement.Query == "MATCH (n) RETURN n" {
+				assert.Equal(t, 0, statement.Command)
+			} else {
+				assert.Equal(t, 1, statement.Command)
+			}
+		}
+	}
+}
+
+func TestStatementsInBranchesKnowTheirOwnQuery(t *testing.T) {
+	script, err := Parse("s", `
+:set read random(0, 2)
+:if $read = 1
+MATCH (n) RETURN n;
+:else
+CREATE (n);
+:endif
+RETURN 1;`, 1)
+	assert.NoError(t, err)
+
+	commands := make(map[string]int)
+	for seed := int64(0); seed < 10; seed++ {
+		uow, err := script.Eval(ScriptContext{
+			Vars: map[string]interface{}{},
+			Rand: rand.New(rand.NewSource(seed)),
+		})
+		assert.NoError(t, err)
+		for _, statement := range uow.Statements {
+			commands[statement.Query] = statement.Command
+		}
+	}
+	assert.Equal(t, map[string]int{"MATCH (n) RETURN n": 0, "CREATE (n)": 1, "RETURN 1": 2}, commands)
+}
+
+func TestStatementsInLoopsKnowTheirOwnQuery(t *testing.T) {
+	script, err := Parse("s", `
+RETURN 0;
+:for i in range(1, 3)
+MATCH (n {id: $i}) RETURN n;
+CREATE (n {id: $i});
+:endfor`, 1)
+	assert.NoError(t, err)
+	uow, err := script.Eval(ScriptContext{
+		Vars: map[string]interface{}{},
+		Rand: rand.New(rand.NewSource(1337)),
+	})
+	assert.NoError(t, err)
+
+	// Each iteration runs the same two queries, so they're recorded as the same two statements
+	commands := make([]int, 0, len(uow.Statements))
+	for _, statement := range uow.Statements {
+		commands = append(commands, statement.Command)
+	}
+	assert.Equal(t, []int{0, 1, 2, 1, 2, 1, 2}, commands)
+}
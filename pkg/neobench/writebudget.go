@@ -0,0 +1,148 @@
+package neobench
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+)
+
+// An amount of data written; with --write-budget, only one of these is set
+type WriteVolume struct {
+	// Nodes plus relationships created
+	Rows int64
+	// Estimated from the size of the parameters sent with queries that wrote something; the server does
+	// not report how much it actually stored
+	Bytes int64
+}
+
+func (v WriteVolume) IsZero() bool {
+	return v.Rows == 0 && v.Bytes == 0
+}
+
+func (v WriteVolume) String() string {
+	if v.Bytes > 0 {
+		return fmt.Sprintf("%dB", v.Bytes)
+	}
+	return fmt.Sprintf("%drows", v.Rows)
+}
+
+var byteUnits = []struct {
+	suffix     string
+	multiplier int64
+}{
+	// Longest suffixes first, so "MB" is not mistaken for "B"
+	{"KB", 1000},
+	{"MB", 1000 * 1000},
+	{"GB", 1000 * 1000 * 1000},
+	{"TB", 1000 * 1000 * 1000 * 1000},
+	{"B", 1},
+}
+
+// Parses a --write-budget value, either a row count like "1000000rows" or a byte size like "10GB"
+func ParseWriteVolume(raw string) (WriteVolume, error) {
+	trimmed := strings.TrimSpace(raw)
+	if strings.HasSuffix(trimmed, "rows") {
+		rows, err := strconv.ParseInt(strings.TrimSpace(strings.TrimSuffix(trimmed, "rows")), 10, 64)
+		if err != nil || rows <= 0 {
+			return WriteVolume{}, fmt.Errorf("invalid write budget '%s', expected a positive number of rows, like 1000000rows", raw)
+		}
+		return WriteVolume{Rows: rows}, nil
+	}
+	upper := strings.ToUpper(trimmed)
+	for _, unit := range byteUnits {
+		if !strings.HasSuffix(upper, unit.suffix) {
+			continue
+		}
+		amount, err := strconv.ParseFloat(strings.TrimSpace(strings.TrimSuffix(upper, unit.suffix)), 64)
+		if err != nil || amount <= 0 {
+			break
+		}
+		return WriteVolume{Bytes: int64(amount * float64(unit.multiplier))}, nil
+	}
+	return WriteVolume{}, fmt.Errorf("invalid write budget '%s', expected rows (ex: 1000000rows) or bytes (ex: 500MB, 10GB)", raw)
+}
+
+// Shared by all workers in a run; tracks the total volume written and signals once the limit is reached
+type WriteBudget struct {
+	limit WriteVolume
+	rows  int64
+	bytes int64
+
+	exhausted chan struct{}
+	once      sync.Once
+}
+
+func NewWriteBudget(limit WriteVolume) *WriteBudget {
+	return &WriteBudget{
+		limit:     limit,
+		exhausted: make(chan struct{}),
+	}
+}
+
+// Adds to the volume written so far; safe to call from multiple workers. A nil budget ignores this.
+func (b *WriteBudget) Spend(written WriteVolume) {
+	if b == nil {
+		return
+	}
+	rows := atomic.AddInt64(&b.rows, written.Rows)
+	bytes := atomic.AddInt64(&b.bytes, written.Bytes)
+	if (b.limit.Rows > 0 && rows >= b.limit.Rows) || (b.limit.Bytes > 0 && bytes >= b.limit.Bytes) {
+		b.once.Do(func() { close(b.exhausted) })
+	}
+}
+
+// Closed once the budget is spent; for a nil budget this returns a nil channel, which never fires
+func (b *WriteBudget) Exhausted() <-chan struct{} {
+	if b == nil {
+		return nil
+	}
+	return b.exhausted
+}
+
+// How much of the budget has been spent, from 0 to 1
+func (b *WriteBudget) Progress() float64 {
+	if b == nil {
+		return 0
+	}
+	progress := float64(0)
+	if b.limit.Rows > 0 {
+		progress = float64(atomic.LoadInt64(&b.rows)) / float64(b.limit.Rows)
+	} else if b.limit.Bytes > 0 {
+		progress = float64(atomic.LoadInt64(&b.bytes)) / float64(b.limit.Bytes)
+	}
+	if progress > 1 {
+		return 1
+	}
+	return progress
+}
+
+// Rough size of a parameter value as sent over the wire; used to estimate bytes written
+func estimateSize(value interface{}) int64 {
+	switch v := value.(type) {
+	case nil:
+		return 1
+	case bool:
+		return 1
+	case string:
+		return int64(len(v))
+	case []byte:
+		return int64(len(v))
+	case int64, float64, int, int32, float32:
+		return 8
+	case []interface{}:
+		size := int64(0)
+		for _, item := range v {
+			size += estimateSize(item)
+		}
+		return size
+	case map[string]interface{}:
+		size := int64(0)
+		for key, item := range v {
+			size += int64(len(key)) + estimateSize(item)
+		}
+		return size
+	}
+	return 8
+}
@@ -0,0 +1,89 @@
+package neobench
+
+import (
+	"github.com/stretchr/testify/assert"
+	"math/rand"
+	"testing"
+	"time"
+)
+
+func TestParseWriteVolume(t *testing.T) {
+	tc := []struct {
+		raw      string
+		expected WriteVolume
+	}{
+		{"1000rows", WriteVolume{Rows: 1000}},
+		{"500B", WriteVolume{Bytes: 500}},
+		{"10KB", WriteVolume{Bytes: 10 * 1000}},
+		{"1.5MB", WriteVolume{Bytes: 1500 * 1000}},
+		{"10gb", WriteVolume{Bytes: 10 * 1000 * 1000 * 1000}},
+	}
+	for _, c := range tc {
+		t.Run(c.raw, func(t *testing.T) {
+			actual, err := ParseWriteVolume(c.raw)
+			assert.NoError(t, err)
+			assert.Equal(t, c.expected, actual)
+		})
+	}
+
+	for _, raw := range []string{"", "10", "-5rows", "1.5rows", "GB", "10 apples"} {
+		_, err := ParseWriteVolume(raw)
+		assert.Error(t, err, raw)
+	}
+}
+
+func TestWriteBudgetIsSharedAcrossWorkers(t *testing.T) {
+	budget := NewWriteBudget(WriteVolume{Rows: 10})
+
+	budget.Spend(WriteVolume{Rows: 6})
+	assert.InDelta(t, 0.6, budget.Progress(), 0.001)
+	select {
+	case <-budget.Exhausted():
+		t.Fatal("budget should not be exhausted yet")
+	default:
+	}
+
+	budget.Spend(WriteVolume{Rows: 6})
+	budget.Spend(WriteVolume{Rows: 1})
+	assert.Equal(t, float64(1), budget.Progress())
+	select {
+	case <-budget.Exhausted():
+	default:
+		t.Fatal("budget should be exhausted")
+	}
+}
+
+func TestWorkerStopsWhenWriteBudgetIsExhausted(t *testing.T) {
+	r := rand.New(rand.NewSource(1337))
+	clock := &fakeSpaceTimeContinuum{currentTime: time.Date(2020, 1, 1, 1, 1, 1, 1, time.UTC)}
+	driver := &fakeDriver{
+		clock:      clock,
+		r:          r,
+		minLatency: 2 * time.Millisecond,
+		maxLatency: 20 * time.Millisecond,
+	}
+	w := Worker{
+		workerId: 0,
+		driver:   driver,
+		now:      clock.now,
+		sleep:    clock.sleepUnlessStopped,
+	}
+	budget := NewWriteBudget(WriteVolume{Bytes: 100})
+	// Another worker spent the budget
+	budget.Spend(WriteVolume{Bytes: 100})
+
+	// Would run forever without the budget, since no stop is requested and there's no transaction limit
+	result := w.RunBenchmark(newTestWorkload(r), "", 0, 0, make(chan struct{}), budget, NewResultRecorder(0, time.Time{}))
+
+	assert.NoError(t, result.Error)
+	assert.Empty(t, result.Scripts)
+}
+
+func TestEstimateSize(t *testing.T) {
+	assert.Equal(t, int64(0), estimateSize(map[string]interface{}{}))
+	// "name" + "bob", "ids" + 2 * 8
+	assert.Equal(t, int64(4+3+3+16), estimateSize(map[string]interface{}{
+		"name": "bob",
+		"ids":  []interface{}{int64(1), int64(2)},
+	}))
+}